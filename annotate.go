@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"converter/config"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// runAnnotate implements `converter annotate <conversion-id> [annotation...]`,
+// which sets operator annotations such as hold, skip or force-engine=soffice
+// on a conversion. With --clear the named annotations, or all of them, are
+// removed instead. Without annotations it only prints the current ones.
+func runAnnotate(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("annotate", flag.ExitOnError)
+	remove := fs.Bool("clear", false, "remove the named annotations, or all of them when none are named")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		return fmt.Errorf("usage: converter annotate [--clear] <conversion-id> [hold|skip|force-engine=<engine>...]")
+	}
+	conversionID, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid conversion id %q", fs.Arg(0))
+	}
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	annotations := services.NewJobAnnotations(redisClient, cfg.RedisPrefix, time.Duration(cfg.AnnotationTTL)*time.Second)
+	if *remove {
+		if err := annotations.Clear(ctx, conversionID, fs.Args()[1:]...); err != nil {
+			return err
+		}
+	} else {
+		for _, arg := range fs.Args()[1:] {
+			name, value, _ := strings.Cut(arg, "=")
+			if err := annotations.Set(ctx, conversionID, name, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	current, err := annotations.Get(ctx, conversionID)
+	if err != nil {
+		return err
+	}
+	slog.Info("Conversion annotations", "conversion_id", conversionID, "annotations", current)
+	return nil
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"converter/logging"
+	"converter/services"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 1000
+)
+
+// requireAdmin rejects requests without the configured bearer token.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid admin token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// GET /admin/queues
+func (s *Server) handleQueueSummary(w http.ResponseWriter, r *http.Request) {
+	lengths := make(map[string]int64)
+	for _, name := range services.QueueNames() {
+		n, err := s.queueAdmin.Length(r.Context(), name)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		lengths[name] = n
+	}
+	writeJSON(w, http.StatusOK, lengths)
+}
+
+// GET /admin/queues/{queue}?offset=0&limit=50
+func (s *Server) handleListQueue(w http.ResponseWriter, r *http.Request) {
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultListLimit)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		writeError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+		return
+	}
+
+	jobs, err := s.queueAdmin.List(r.Context(), r.PathValue("queue"), offset, limit)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"queue": r.PathValue("queue"), "offset": offset, "jobs": jobs})
+}
+
+// DELETE /admin/queues/{queue}
+func (s *Server) handlePurgeQueue(w http.ResponseWriter, r *http.Request) {
+	queue := r.PathValue("queue")
+	removed, err := s.queueAdmin.Purge(r.Context(), queue)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	logging.From(r.Context()).Info("Purged queue", "component", "admin", "queue", queue, "removed", removed)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"queue": queue, "removed": removed})
+}
+
+// GET /admin/conversions/{id}
+func (s *Server) handleGetConversion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversion id")
+		return
+	}
+
+	status, err := s.queueAdmin.Status(r.Context(), id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	queue, err := s.queueAdmin.Locate(r.Context(), id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	record, err := s.db.GetConversion(r.Context(), id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		writeAdminError(w, err)
+		return
+	}
+	if len(status) == 0 && queue == "" && record == nil {
+		writeError(w, http.StatusNotFound, "conversion not found")
+		return
+	}
+	annotations, err := s.queueAdmin.Annotations(r.Context(), id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"conversionId": id, "status": status, "queue": queue, "database": record, "annotations": annotations})
+}
+
+// POST /admin/conversions/{id}/requeue[?userInitiated=true]
+func (s *Server) handleRequeue(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversion id")
+		return
+	}
+
+	userInitiated, _ := strconv.ParseBool(r.URL.Query().Get("userInitiated"))
+	job, err := s.queueAdmin.Requeue(r.Context(), id, userInitiated)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	logging.From(r.Context()).Info("Requeued failed conversion", "component", "admin", "conversion_id", id, "user_initiated", userInitiated)
+	writeJSON(w, http.StatusOK, job)
+}
+
+// POST /admin/conversions/{id}/trash
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversion id")
+		return
+	}
+
+	job, err := s.queueAdmin.Trash(r.Context(), id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	logging.From(r.Context()).Info("Queued trashing of conversion outputs", "component", "admin", "conversion_id", id)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// POST /admin/conversions/{id}/restore
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversion id")
+		return
+	}
+
+	job, err := s.queueAdmin.Restore(r.Context(), id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	logging.From(r.Context()).Info("Queued restore of conversion outputs", "component", "admin", "conversion_id", id)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// PUT /admin/conversions/{id}/annotations/{name}[?value=soffice]
+func (s *Server) handleAnnotate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversion id")
+		return
+	}
+
+	name, value := r.PathValue("name"), r.URL.Query().Get("value")
+	if err := s.queueAdmin.Annotate(r.Context(), id, name, value); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	logging.From(r.Context()).Info("Annotated conversion", "component", "admin", "conversion_id", id, "annotation", name, "value", value)
+	s.writeAnnotations(w, r, id)
+}
+
+// DELETE /admin/conversions/{id}/annotations[/{name}]
+func (s *Server) handleClearAnnotation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid conversion id")
+		return
+	}
+
+	var names []string
+	if name := r.PathValue("name"); name != "" {
+		names = append(names, name)
+	}
+	if err := s.queueAdmin.ClearAnnotations(r.Context(), id, names...); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	logging.From(r.Context()).Info("Cleared conversion annotations", "component", "admin", "conversion_id", id, "annotations", names)
+	s.writeAnnotations(w, r, id)
+}
+
+func (s *Server) writeAnnotations(w http.ResponseWriter, r *http.Request, id int) {
+	annotations, err := s.queueAdmin.Annotations(r.Context(), id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"conversionId": id, "annotations": annotations})
+}
+
+func queryInt(r *http.Request, name string, fallback int64) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownQueue), errors.Is(err, services.ErrJobNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrQueueProtected):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrInvalidAnnotation):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"converter/config"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestAdminRoutes_RequireToken(t *testing.T) {
+	t.Parallel()
+
+	s := NewServer(&config.Config{AdminToken: "secret"}, nil, nil, nil)
+
+	for _, header := range []string{"", "Bearer wrong", "secret"} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/queues", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status = %d, want 401", header, rec.Code)
+		}
+	}
+}
+
+func TestAdminRoutes_DisabledWithoutToken(t *testing.T) {
+	t.Parallel()
+
+	s := NewServer(&config.Config{}, nil, nil, nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/queues/pending", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestAdminAnnotate_RejectsInvalid(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{AdminToken: "secret"}
+	admin := services.NewQueueAdmin(redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"}), cfg, nil)
+	s := NewServer(cfg, nil, admin, nil)
+
+	for _, target := range []string{
+		"/admin/conversions/7/annotations/priority",
+		"/admin/conversions/7/annotations/force-engine?value=chromium",
+		"/admin/conversions/7/annotations/hold?value=yes",
+	} {
+		req := httptest.NewRequest(http.MethodPut, target, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: status = %d, want 400", target, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"converter/services"
+)
+
+type estimateResponse struct {
+	Extension               string  `json:"extension"`
+	SizeBucket              string  `json:"sizeBucket"`
+	Samples                 int     `json:"samples"`
+	EstimatedDurationMs     int64   `json:"estimatedDurationMs"`
+	P90DurationMs           int64   `json:"p90DurationMs"`
+	P99DurationMs           int64   `json:"p99DurationMs"`
+	SuggestedTimeoutSeconds int     `json:"suggestedTimeoutSeconds"`
+	WorkerSeconds           float64 `json:"workerSeconds"`
+}
+
+// handleEstimate returns the expected duration and worker cost of converting
+// a file, from recorded percentiles for its extension and size bucket:
+// GET /api/estimate?extension=docx&sizeBytes=524288
+func (s *Server) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	extension := strings.ToLower(strings.TrimPrefix(r.URL.Query().Get("extension"), "."))
+	if extension == "" {
+		writeError(w, http.StatusBadRequest, "extension is required")
+		return
+	}
+
+	sizeBytes, err := strconv.ParseInt(r.URL.Query().Get("sizeBytes"), 10, 64)
+	if err != nil || sizeBytes < 0 {
+		writeError(w, http.StatusBadRequest, "sizeBytes must be a non-negative integer")
+		return
+	}
+
+	stats, err := s.perfStats.Percentiles(r.Context(), extension, sizeBytes)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "performance history unavailable")
+		return
+	}
+
+	resp := estimateResponse{
+		Extension:               extension,
+		SizeBucket:              services.SizeBucket(sizeBytes),
+		Samples:                 stats.Samples,
+		EstimatedDurationMs:     stats.P50.Milliseconds(),
+		P90DurationMs:           stats.P90.Milliseconds(),
+		P99DurationMs:           stats.P99.Milliseconds(),
+		SuggestedTimeoutSeconds: s.config.ConversionTimeout,
+		WorkerSeconds:           stats.P50.Seconds(),
+	}
+	// Same rule the workers apply with CONVERSION_ADAPTIVE_TIMEOUT enabled
+	if timeout, ok := stats.SuggestedTimeout(
+		s.config.AdaptiveTimeoutFactor,
+		s.config.AdaptiveTimeoutMinSamples,
+		time.Duration(s.config.AdaptiveTimeoutMin)*time.Second,
+		time.Duration(s.config.AdaptiveTimeoutMax)*time.Second,
+	); ok {
+		resp.SuggestedTimeoutSeconds = int(math.Ceil(timeout.Seconds()))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const healthCheckTimeout = 3 * time.Second
+
+// HealthCheck reports whether a dependency is reachable.
+type HealthCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// AddLivenessCheck registers a check for /healthz, which Kubernetes uses to
+// restart the pod. Only register checks a restart can fix. Every liveness
+// check is also a readiness check.
+func (s *Server) AddLivenessCheck(name string, check HealthCheck) {
+	s.liveness = append(s.liveness, namedCheck{name: name, check: check})
+}
+
+// AddReadinessCheck registers a check for /readyz, which takes the pod out
+// of rotation while a dependency is unreachable.
+func (s *Server) AddReadinessCheck(name string, check HealthCheck) {
+	s.readiness = append(s.readiness, namedCheck{name: name, check: check})
+}
+
+// GET /healthz
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, r, s.liveness)
+}
+
+// GET /readyz
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, r, append(append([]namedCheck{}, s.liveness...), s.readiness...))
+}
+
+// writeHealth runs the checks concurrently and responds 503 if any failed.
+func writeHealth(w http.ResponseWriter, r *http.Request, checks []namedCheck) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]checkResult, len(checks))
+	healthy := true
+
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c namedCheck) {
+			defer wg.Done()
+			result := checkResult{Status: "ok"}
+			if err := c.check(ctx); err != nil {
+				result = checkResult{Status: "fail", Error: err.Error()}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[c.name] = result
+			if result.Status != "ok" {
+				healthy = false
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if !healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, map[string]interface{}{"healthy": healthy, "checks": results})
+}
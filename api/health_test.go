@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"converter/config"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	t.Parallel()
+
+	s := NewServer(&config.Config{}, nil, nil, nil)
+	s.AddLivenessCheck("redis", func(ctx context.Context) error { return nil })
+	s.AddReadinessCheck("gotenberg", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	cases := map[string]int{
+		"/healthz": http.StatusOK,
+		"/readyz":  http.StatusServiceUnavailable,
+	}
+	for path, want := range cases {
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, want)
+		}
+
+		var body struct {
+			Checks map[string]checkResult `json:"checks"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: failed to decode body: %v", path, err)
+		}
+		if body.Checks["redis"].Status != "ok" {
+			t.Errorf("%s: redis check = %+v, want ok", path, body.Checks["redis"])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"converter/config"
+	"converter/logging"
+	"converter/services"
+)
+
+// Server is the converter's embedded HTTP API.
+type Server struct {
+	config     *config.Config
+	perfStats  *services.PerformanceStats
+	queueAdmin *services.QueueAdmin
+	db         *services.DatabaseService
+	mux        *http.ServeMux
+	liveness   []namedCheck
+	readiness  []namedCheck
+}
+
+func NewServer(cfg *config.Config, perfStats *services.PerformanceStats, queueAdmin *services.QueueAdmin, db *services.DatabaseService) *Server {
+	s := &Server{
+		config:     cfg,
+		perfStats:  perfStats,
+		queueAdmin: queueAdmin,
+		db:         db,
+		mux:        http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /healthz", s.handleHealthz)
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+	s.mux.HandleFunc("GET /api/estimate", s.handleEstimate)
+
+	// Admin routes are only served when a token is configured
+	if cfg.AdminToken != "" {
+		s.mux.HandleFunc("GET /admin/queues", s.requireAdmin(s.handleQueueSummary))
+		s.mux.HandleFunc("GET /admin/queues/{queue}", s.requireAdmin(s.handleListQueue))
+		s.mux.HandleFunc("DELETE /admin/queues/{queue}", s.requireAdmin(s.handlePurgeQueue))
+		s.mux.HandleFunc("GET /admin/conversions/{id}", s.requireAdmin(s.handleGetConversion))
+		s.mux.HandleFunc("POST /admin/conversions/{id}/requeue", s.requireAdmin(s.handleRequeue))
+		s.mux.HandleFunc("POST /admin/conversions/{id}/trash", s.requireAdmin(s.handleTrash))
+		s.mux.HandleFunc("POST /admin/conversions/{id}/restore", s.requireAdmin(s.handleRestore))
+		s.mux.HandleFunc("PUT /admin/conversions/{id}/annotations/{name}", s.requireAdmin(s.handleAnnotate))
+		s.mux.HandleFunc("DELETE /admin/conversions/{id}/annotations/{name}", s.requireAdmin(s.handleClearAnnotation))
+		s.mux.HandleFunc("DELETE /admin/conversions/{id}/annotations", s.requireAdmin(s.handleClearAnnotation))
+	}
+
+	return s
+}
+
+// Run serves until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) {
+	srv := &http.Server{
+		Addr:              s.config.HTTPAddr,
+		Handler:           withRequestID(s.mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("API listening", "component", "api", "addr", s.config.HTTPAddr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Error("API server stopped", "component", "api", "error", err)
+	}
+}
+
+// withRequestID tags each request's context with the caller's X-Request-Id,
+// or a generated one, and echoes it back.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := logging.WithTraceID(r.Context(), r.Header.Get("X-Request-Id"))
+		w.Header().Set("X-Request-Id", logging.TraceID(ctx))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,124 @@
+package bench
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"converter/services"
+)
+
+func gotenbergURL(tb testing.TB) string {
+	url := os.Getenv("BENCH_GOTENBERG_URL")
+	if url == "" {
+		tb.Skip("BENCH_GOTENBERG_URL not set")
+	}
+	return url
+}
+
+func loadGolden(tb testing.TB) []Golden {
+	golden, err := LoadGolden("testdata")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return golden
+}
+
+// stage copies a golden input into a temp dir, since conversion writes its
+// output next to the input.
+func stage(tb testing.TB, g Golden) string {
+	data, err := os.ReadFile(filepath.Join("testdata", g.File))
+	if err != nil {
+		tb.Fatalf("failed to read %s: %v", g.File, err)
+	}
+	path := filepath.Join(tb.TempDir(), g.File)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		tb.Fatalf("failed to stage %s: %v", g.File, err)
+	}
+	return path
+}
+
+func TestGoldenFiles_Exist(t *testing.T) {
+	for _, g := range loadGolden(t) {
+		if _, err := os.Stat(filepath.Join("testdata", g.File)); err != nil {
+			t.Errorf("golden input %s: %v", g.File, err)
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	gotenberg := services.NewGotenbergService(gotenbergURL(t), 0, services.RequestIdentity{})
+	if _, err := exec.LookPath("pdfinfo"); err != nil {
+		t.Skip("poppler-utils not installed")
+	}
+	tools := services.NewPDFToolsService()
+
+	for _, g := range loadGolden(t) {
+		t.Run(g.File, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			output, err := gotenberg.ConvertToPDFA(ctx, stage(t, g), g.Extension(), services.ConvertOptions{})
+			if err != nil {
+				t.Fatalf("conversion failed: %v", err)
+			}
+
+			problems, err := Check(ctx, tools, g, output)
+			if err != nil {
+				t.Fatalf("failed to inspect output: %v", err)
+			}
+			for _, p := range problems {
+				t.Error(p)
+			}
+		})
+	}
+}
+
+// BenchmarkConvert measures sequential conversion latency per document.
+func BenchmarkConvert(b *testing.B) {
+	gotenberg := services.NewGotenbergService(gotenbergURL(b), 0, services.RequestIdentity{})
+
+	for _, g := range loadGolden(b) {
+		b.Run(g.File, func(b *testing.B) {
+			input := stage(b, g)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				output, err := gotenberg.ConvertToPDFA(context.Background(), input, g.Extension(), services.ConvertOptions{})
+				if err != nil {
+					b.Fatalf("conversion failed: %v", err)
+				}
+				os.Remove(output)
+			}
+		})
+	}
+}
+
+// BenchmarkConvertConcurrent measures throughput with many in-flight
+// conversions, like a pool of workers sharing one Gotenberg. Use -cpu to
+// vary the concurrency, e.g. -cpu 1,4,8.
+func BenchmarkConvertConcurrent(b *testing.B) {
+	gotenberg := services.NewGotenbergService(gotenbergURL(b), 0, services.RequestIdentity{})
+	golden := loadGolden(b)
+
+	b.RunParallel(func(pb *testing.PB) {
+		dir := b.TempDir()
+		inputs := make([]string, len(golden))
+		for i, g := range golden {
+			data, _ := os.ReadFile(filepath.Join("testdata", g.File))
+			inputs[i] = filepath.Join(dir, g.File)
+			os.WriteFile(inputs[i], data, 0644)
+		}
+
+		for i := 0; pb.Next(); i++ {
+			g := golden[i%len(golden)]
+			output, err := gotenberg.ConvertToPDFA(context.Background(), inputs[i%len(golden)], g.Extension(), services.ConvertOptions{})
+			if err != nil {
+				b.Errorf("conversion of %s failed: %v", g.File, err)
+				return
+			}
+			os.Remove(output)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+// Package bench holds golden documents and the expected characteristics of
+// their conversions. Its tests and benchmarks run the real pipeline against
+// a local Gotenberg:
+//
+//	BENCH_GOTENBERG_URL=http://localhost:3000 go test ./bench -bench . -benchtime 20x
+//
+// Without BENCH_GOTENBERG_URL they are skipped.
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"converter/services"
+)
+
+// Range is an inclusive bound; a zero Max means unbounded.
+type Range struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+}
+
+func (r Range) Contains(v int64) bool {
+	return v >= r.Min && (r.Max == 0 || v <= r.Max)
+}
+
+// Golden describes one input document and what its PDF/A must look like.
+type Golden struct {
+	File  string   `json:"file"`
+	Pages Range    `json:"pages"`
+	Bytes Range    `json:"bytes"`
+	Text  []string `json:"text"`
+}
+
+func (g Golden) Extension() string {
+	return strings.TrimPrefix(filepath.Ext(g.File), ".")
+}
+
+// LoadGolden reads testdata/golden.json from dir.
+func LoadGolden(dir string) ([]Golden, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "golden.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read golden file: %w", err)
+	}
+
+	var golden struct {
+		Documents []Golden `json:"documents"`
+	}
+	if err := json.Unmarshal(data, &golden); err != nil {
+		return nil, fmt.Errorf("failed to parse golden file: %w", err)
+	}
+	return golden.Documents, nil
+}
+
+// Check compares a converted PDF against the golden characteristics and
+// returns every mismatch.
+func Check(ctx context.Context, tools *services.PDFToolsService, g Golden, pdfPath string) ([]string, error) {
+	var problems []string
+
+	stat, err := os.Stat(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	if !g.Bytes.Contains(stat.Size()) {
+		problems = append(problems, fmt.Sprintf("size %d bytes outside [%d, %d]", stat.Size(), g.Bytes.Min, g.Bytes.Max))
+	}
+
+	info, err := tools.Info(ctx, pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	pages, _ := strconv.ParseInt(info["Pages"], 10, 64)
+	if !g.Pages.Contains(pages) {
+		problems = append(problems, fmt.Sprintf("%d pages outside [%d, %d]", pages, g.Pages.Min, g.Pages.Max))
+	}
+
+	textPath, err := tools.ExtractText(ctx, pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(textPath)
+	text, err := os.ReadFile(textPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, snippet := range g.Text {
+		if !strings.Contains(string(text), snippet) {
+			problems = append(problems, fmt.Sprintf("text layer is missing %q", snippet))
+		}
+	}
+
+	return problems, nil
+}
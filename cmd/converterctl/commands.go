@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"converter/config"
+	"converter/services"
+)
+
+// selectFlags are the flags choosing the failed jobs a command acts on.
+type selectFlags struct {
+	all    *bool
+	user   *int
+	match  *string
+	dryRun *bool
+}
+
+func addSelectFlags(fs *flag.FlagSet, verb string) *selectFlags {
+	return &selectFlags{
+		all:    fs.Bool("all", false, verb+" every failed job"),
+		user:   fs.Int("user", 0, "only "+verb+" this userId's jobs"),
+		match:  fs.String("match", "", "only "+verb+" jobs whose last error contains this text"),
+		dryRun: fs.Bool("dry-run", false, "list the jobs that would be affected and change nothing"),
+	}
+}
+
+// filter builds the selection from the flags and the conversion IDs in
+// args. Without --all, something has to narrow it down, so a forgotten
+// argument can't act on the whole queue.
+func (s *selectFlags) filter(args []string) (services.FailedFilter, error) {
+	filter := services.FailedFilter{UserID: *s.user, Match: *s.match}
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil || id <= 0 {
+			return filter, fmt.Errorf("invalid conversion id %q", arg)
+		}
+		filter.ConversionIDs = append(filter.ConversionIDs, id)
+	}
+
+	narrowed := len(filter.ConversionIDs) > 0 || filter.UserID != 0 || filter.Match != ""
+	switch {
+	case *s.all && narrowed:
+		return filter, errors.New("--all can't be combined with conversion ids, --user or --match")
+	case !*s.all && !narrowed:
+		return filter, errors.New("name conversion ids, or select with --user, --match or --all")
+	}
+	return filter, nil
+}
+
+// runList implements `converterctl list`.
+func runList(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	user := fs.Int("user", 0, "only list this userId's jobs")
+	match := fs.String("match", "", "only list jobs whose last error contains this text")
+	offset := fs.Int("offset", 0, "skip this many jobs")
+	limit := fs.Int("limit", 50, "list at most this many jobs")
+	asJSON := fs.Bool("json", false, "print the entries and their status as JSON")
+	fs.Parse(args)
+
+	redisClient, err := connectRedis(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	admin := services.NewQueueAdmin(redisClient, cfg, nil)
+	entries, err := admin.FailedJobs(ctx, services.FailedFilter{UserID: *user, Match: *match, Limit: *offset + *limit})
+	if err != nil {
+		return err
+	}
+	entries = entries[min(*offset, len(entries)):]
+	return printEntries(ctx, os.Stdout, admin, entries, *asJSON)
+}
+
+// failedEntry is a failed job with the status hash holding its last error.
+type failedEntry struct {
+	services.QueuedJob
+	Status map[string]string `json:"status,omitempty"`
+}
+
+func printEntries(ctx context.Context, w io.Writer, admin *services.QueueAdmin, entries []services.QueuedJob, asJSON bool) error {
+	listed := make([]failedEntry, 0, len(entries))
+	for _, entry := range entries {
+		listed = append(listed, failedEntry{QueuedJob: entry.Redacted()})
+		if entry.Job == nil {
+			continue
+		}
+		status, err := admin.Status(ctx, entry.Job.ConversionID)
+		if err != nil {
+			return fmt.Errorf("failed to read status of conversion %d: %w", entry.Job.ConversionID, err)
+		}
+		listed[len(listed)-1].Status = status
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(listed)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONVERSION\tUSER\tEXTENSION\tRETRIES\tCREATED\tERROR")
+	for _, entry := range listed {
+		job := entry.Job
+		if job == nil {
+			fmt.Fprintf(tw, "-\t-\t-\t-\t-\tunparseable entry: %.60s\n", entry.Raw)
+			continue
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%d/%d\t%s\t%s\n", job.ConversionID, job.UserID, job.InputExtension,
+			job.RetryCount, job.MaxRetries, job.CreatedAt.UTC().Format(time.RFC3339), oneLine(entry.Status["error"], 80))
+	}
+	return tw.Flush()
+}
+
+// oneLine shortens an error message to a single table cell.
+func oneLine(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > width {
+		return s[:width-3] + "..."
+	}
+	return s
+}
+
+// runInspect implements `converterctl inspect <conversion-id>`.
+func runInspect(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: converterctl inspect <conversion-id>")
+	}
+	conversionID, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid conversion id %q", fs.Arg(0))
+	}
+
+	redisClient, err := connectRedis(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	admin := services.NewQueueAdmin(redisClient, cfg, nil)
+	queue, err := admin.Locate(ctx, conversionID)
+	if err != nil {
+		return err
+	}
+	status, err := admin.Status(ctx, conversionID)
+	if err != nil {
+		return fmt.Errorf("failed to read status: %w", err)
+	}
+	annotations, err := admin.Annotations(ctx, conversionID)
+	if err != nil {
+		return err
+	}
+	failed, err := admin.FailedJobs(ctx, services.FailedFilter{ConversionIDs: []int{conversionID}})
+	if err != nil {
+		return err
+	}
+	if queue == "" && len(status) == 0 {
+		return fmt.Errorf("conversion %d is in no queue and has no status", conversionID)
+	}
+	for i := range failed {
+		failed[i] = failed[i].Redacted()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]interface{}{
+		"conversionId": conversionID,
+		"queue":        queue,
+		"status":       status,
+		"annotations":  annotations,
+		"failed":       failed,
+	})
+}
+
+// runRequeue implements `converterctl requeue`.
+func runRequeue(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("requeue", flag.ExitOnError)
+	selection := addSelectFlags(fs, "requeue")
+	userInitiated := fs.Bool("user-initiated", false, "requeue to the retry lane, as a user's retry")
+	fs.Parse(args)
+	filter, err := selection.filter(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	redisClient, err := connectRedis(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	if *selection.dryRun {
+		return dryRun(ctx, services.NewQueueAdmin(redisClient, cfg, nil), filter)
+	}
+
+	// Requeued conversions go back to pending in the database too
+	dbSvc, err := services.NewDatabaseService(cfg.DatabaseURL, "")
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer dbSvc.Close()
+	dbUpdater := services.NewStatusUpdater(dbSvc, cfg.DBUpdateQueueSize, cfg.DBUpdateMaxRetries)
+	go dbUpdater.Run()
+
+	requeued, err := services.NewQueueAdmin(redisClient, cfg, dbUpdater).RequeueFailed(ctx, filter, *userInitiated)
+	dbUpdater.Close()
+	fmt.Printf("requeued %d failed jobs\n", len(requeued))
+	return err
+}
+
+// runPurge implements `converterctl purge`.
+func runPurge(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	selection := addSelectFlags(fs, "purge")
+	fs.Parse(args)
+	filter, err := selection.filter(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	redisClient, err := connectRedis(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	admin := services.NewQueueAdmin(redisClient, cfg, nil)
+	if *selection.dryRun {
+		return dryRun(ctx, admin, filter)
+	}
+	purged, err := admin.PurgeFailed(ctx, filter)
+	fmt.Printf("purged %d failed jobs\n", purged)
+	return err
+}
+
+func dryRun(ctx context.Context, admin *services.QueueAdmin, filter services.FailedFilter) error {
+	entries, err := admin.FailedJobs(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if err := printEntries(ctx, os.Stdout, admin, entries, false); err != nil {
+		return err
+	}
+	fmt.Printf("dry run: %d failed jobs selected\n", len(entries))
+	return nil
+}
+
+// queueStats are the queue depths and what the failed queue holds.
+type queueStats struct {
+	Queues            map[string]int64 `json:"queues"`
+	FailedByUser      map[int]int      `json:"failedByUser"`
+	FailedByExtension map[string]int   `json:"failedByExtension"`
+	OldestFailure     *time.Time       `json:"oldestFailureCreatedAt,omitempty"`
+}
+
+// runStats implements `converterctl stats`.
+func runStats(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the statistics as JSON")
+	fs.Parse(args)
+
+	redisClient, err := connectRedis(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	admin := services.NewQueueAdmin(redisClient, cfg, nil)
+	stats := queueStats{Queues: map[string]int64{}}
+	for _, name := range services.QueueNames() {
+		if stats.Queues[name], err = admin.Length(ctx, name); err != nil {
+			return fmt.Errorf("failed to read length of %s: %w", name, err)
+		}
+	}
+	failed, err := admin.FailedJobs(ctx, services.FailedFilter{})
+	if err != nil {
+		return err
+	}
+	stats.summarizeFailed(failed)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+	return stats.print(os.Stdout)
+}
+
+func (s *queueStats) summarizeFailed(entries []services.QueuedJob) {
+	s.FailedByUser = map[int]int{}
+	s.FailedByExtension = map[string]int{}
+	for _, entry := range entries {
+		job := entry.Job
+		if job == nil {
+			continue
+		}
+		s.FailedByUser[job.UserID]++
+		s.FailedByExtension[job.InputExtension]++
+		if !job.CreatedAt.IsZero() && (s.OldestFailure == nil || job.CreatedAt.Before(*s.OldestFailure)) {
+			created := job.CreatedAt.UTC()
+			s.OldestFailure = &created
+		}
+	}
+}
+
+// statsTopN is how many users and extensions the text output lists.
+const statsTopN = 10
+
+func (s *queueStats) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "QUEUE\tDEPTH")
+	for _, name := range services.QueueNames() {
+		fmt.Fprintf(tw, "%s\t%d\n", name, s.Queues[name])
+	}
+
+	fmt.Fprintln(tw, "\nFAILED BY USER\tJOBS")
+	users := make([]int, 0, len(s.FailedByUser))
+	for user := range s.FailedByUser {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if s.FailedByUser[users[i]] != s.FailedByUser[users[j]] {
+			return s.FailedByUser[users[i]] > s.FailedByUser[users[j]]
+		}
+		return users[i] < users[j]
+	})
+	for _, user := range users[:min(statsTopN, len(users))] {
+		fmt.Fprintf(tw, "%d\t%d\n", user, s.FailedByUser[user])
+	}
+
+	fmt.Fprintln(tw, "\nFAILED BY EXTENSION\tJOBS")
+	extensions := make([]string, 0, len(s.FailedByExtension))
+	for extension := range s.FailedByExtension {
+		extensions = append(extensions, extension)
+	}
+	sort.Slice(extensions, func(i, j int) bool {
+		if s.FailedByExtension[extensions[i]] != s.FailedByExtension[extensions[j]] {
+			return s.FailedByExtension[extensions[i]] > s.FailedByExtension[extensions[j]]
+		}
+		return extensions[i] < extensions[j]
+	})
+	for _, extension := range extensions[:min(statsTopN, len(extensions))] {
+		fmt.Fprintf(tw, "%s\t%d\n", extension, s.FailedByExtension[extension])
+	}
+
+	if s.OldestFailure != nil {
+		fmt.Fprintf(tw, "\noldest failed job created\t%s\n", s.OldestFailure.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"converter/models"
+	"converter/services"
+)
+
+func TestSelectFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    services.FailedFilter
+		wantErr bool
+	}{
+		{"ids", []string{"12", "13"}, services.FailedFilter{ConversionIDs: []int{12, 13}}, false},
+		{"user", []string{"--user", "42"}, services.FailedFilter{UserID: 42}, false},
+		{"user and match", []string{"--user", "42", "--match", "timeout"}, services.FailedFilter{UserID: 42, Match: "timeout"}, false},
+		{"all", []string{"--all"}, services.FailedFilter{}, false},
+		{"nothing selected", nil, services.FailedFilter{}, true},
+		{"all narrowed", []string{"--all", "--user", "42"}, services.FailedFilter{}, true},
+		{"bad id", []string{"abc"}, services.FailedFilter{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			selection := addSelectFlags(fs, "test")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			got, err := selection.filter(fs.Args())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("filter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeFailed(t *testing.T) {
+	t.Parallel()
+
+	older := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	entries := []services.QueuedJob{
+		{Job: &models.ConversionJob{ConversionID: 1, UserID: 42, InputExtension: "docx", CreatedAt: older.Add(time.Hour)}},
+		{Job: &models.ConversionJob{ConversionID: 2, UserID: 42, InputExtension: "xlsx", CreatedAt: older}},
+		{Job: &models.ConversionJob{ConversionID: 3, UserID: 7, InputExtension: "docx", CreatedAt: older.Add(2 * time.Hour)}},
+		{Raw: "not json"},
+	}
+
+	var stats queueStats
+	stats.summarizeFailed(entries)
+	if want := map[int]int{42: 2, 7: 1}; !reflect.DeepEqual(stats.FailedByUser, want) {
+		t.Errorf("FailedByUser = %v, want %v", stats.FailedByUser, want)
+	}
+	if want := map[string]int{"docx": 2, "xlsx": 1}; !reflect.DeepEqual(stats.FailedByExtension, want) {
+		t.Errorf("FailedByExtension = %v, want %v", stats.FailedByExtension, want)
+	}
+	if stats.OldestFailure == nil || !stats.OldestFailure.Equal(older) {
+		t.Errorf("OldestFailure = %v, want %v", stats.OldestFailure, older)
+	}
+}
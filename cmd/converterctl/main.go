@@ -0,0 +1,67 @@
+// Command converterctl manages the failed queue of the conversion service
+// from the command line: it lists, inspects, requeues and purges failed
+// jobs and prints queue statistics, using the service's own configuration
+// and Redis.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"converter/config"
+	"converter/logging"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const usage = `usage: converterctl <command> [flags] [args]
+
+commands:
+  list      list failed jobs, the most recent failure first
+  inspect   show a conversion's job, status and annotations
+  requeue   move failed jobs back to their pending queue
+  purge     delete failed jobs
+  stats     print queue depths and a breakdown of the failed queue
+
+Run converterctl <command> -h for a command's flags.`
+
+var commands = map[string]func(ctx context.Context, cfg *config.Config, args []string) error{
+	"list":    runList,
+	"inspect": runInspect,
+	"requeue": runRequeue,
+	"purge":   runPurge,
+	"stats":   runStats,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	run, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "converterctl: unknown command %q\n\n%s\n", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+	logging.Setup(cfg.LogFormat, cfg.LogLevel)
+	if err := run(context.Background(), cfg, os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "converterctl %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func connectRedis(ctx context.Context, cfg *config.Config) (*redis.Client, error) {
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		redisClient.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return redisClient, nil
+}
@@ -7,25 +7,210 @@ import (
 	"strings"
 )
 
+// retryLane suffixes the pending queue for the user-initiated retry lane.
+const retryLane = "retry"
+
+// Version is stamped at build time with -ldflags "-X converter/config.Version=...".
+var Version = "dev"
+
+// defaultSupportedExtensions are the input formats the LibreOffice, Markdown,
+// image and email routes handle.
+var defaultSupportedExtensions = []string{
+	"doc", "docx", "odt", "rtf",
+	"xls", "xlsx", "ods",
+	"ppt", "pptx", "odp",
+	"pdf", "txt", "html", "md", "markdown",
+	"jpg", "jpeg", "png", "tif", "tiff", "bmp", "gif", "heic", "heif", "webp",
+	"eml", "msg",
+}
+
 type Config struct {
-	RedisAddr         string
-	RedisPassword     string
-	RedisDB           int
-	RedisPrefix       string
-	PendingQueue      string
-	ProcessingQueue   string
-	FailedQueue       string
-	WorkerCount       int
-	GotenbergURL      string
-	S3Bucket          string
-	S3Region          string
-	AWSS3AccessKey    string
-	AWSS3SecretKey    string
-	S3Endpoint        string
-	S3UsePathStyle    bool
-	DatabaseURL       string
-	ConversionTimeout int
-	MaxRetries        int
+	RedisAddr                 string
+	RedisPassword             string
+	RedisDB                   int
+	RedisPrefix               string
+	PendingQueue              string
+	ProcessingQueue           string
+	ClaimTokens               bool
+	QueueBackend              string
+	StreamGroup               string
+	FailedQueue               string
+	LowPriorityQueue          string
+	HighPriorityQueue         string
+	RetryLaneQueue            string
+	PriorityWeights           map[string]string
+	RejectionStream           string
+	DelayedQueue              string
+	WorkerCount               int
+	RetryLaneWorkers          int
+	ClaimStrategy             string
+	ClaimBlockTimeout         int
+	ClaimIdleSleepMs          int
+	ClaimErrorSleepMs         int
+	GotenbergURL              string
+	GotenbergMaxResponseBytes int64
+	PDFAConformance           string
+	PDFUA                     bool
+	MarkdownTemplate          string
+	S3Bucket                  string
+	S3Region                  string
+	AWSS3AccessKey            string
+	AWSS3SecretKey            string
+	S3Endpoint                string
+	S3UsePathStyle            bool
+	S3RateLimit               float64
+	S3RateBurst               int
+	DatabaseURL               string
+	DatabaseReadURL           string
+	ConversionTimeout         int
+	AdaptiveTimeout           bool
+	AdaptiveTimeoutFactor     float64
+	AdaptiveTimeoutMinSamples int
+	AdaptiveTimeoutMin        int
+	AdaptiveTimeoutMax        int
+	MaxRetries                int
+	LeaseInterval             int
+	LeaseTTL                  int
+	DBUpdateQueueSize         int
+	DBUpdateMaxRetries        int
+	Region                    string
+	AuditEnabled              bool
+	AuditS3Bucket             string
+	AuditS3Prefix             string
+	AuditObjectLockMode       string
+	AuditRetentionDays        int
+	OutputEncryptionKeyID     string
+	KMSEndpoint               string
+	DedupPrefix               string
+	DedupTenants              []string
+	PriorityAgingThreshold    int
+	PriorityAgingInterval     int
+	MetricsAddr               string
+	LogFormat                 string
+	LogLevel                  string
+	HTTPAddr                  string
+	AdminToken                string
+	FeatureFlagCacheTTL       int
+	SNSEndpoint               string
+	EventsRoute               string
+	EventsWebhookURL          string
+	EventsWebhookSecret       string
+	EventsTopic               string
+	EventsPubSubEndpoint      string
+	EventsTimeout             int
+	CallbackSecret            string
+	CallbackMaxAttempts       int
+	CallbackAllowedHosts      []string
+	TenantConfigCacheTTL      int
+	JournalDir                string
+	TempDir                   string
+	FastTempDir               string
+	FastTempMaxJobBytes       int64
+	FastTempMaxBytes          int64
+	InstanceID                string
+	UserAgent                 string
+	OutboundHeaders           map[string]string
+	Standby                   bool
+	StandbyControlKey         string
+	MaintenanceWindows        string
+	SupportedExtensions       []string
+	DetectFormat              bool
+	ImageNormalize            bool
+	ImageMaxDPI               int
+	EmailAppendAttachments    bool
+	EmailMaxAttachments       int
+	Annotations               bool
+	MergeMaxInputs            int
+	CostPeakWindows           string
+	CostPeakEngine            string
+	CostPeakDemote            bool
+	SofficePath               string
+	Thumbnails                bool
+	ThumbnailSizes            map[string]string
+	ThumbnailFormat           string
+	ThumbnailS3Prefix         string
+	FileLock                  bool
+	FileLockRetryDelay        int
+	DBRetryColumns            bool
+	JobSigningSecrets         []string
+	JobSignatureEnforce       bool
+	QuarantineQueue           string
+	RedisMemoryWarnPercent    int
+	QueueMirror               bool
+	QueueMirrorInterval       int
+	QueueDriver               string
+	SQSQueueURL               string
+	SQSEndpoint               string
+	SQSVisibilityTimeout      int
+	HTMLAssetMax              int
+	HTMLAssetConcurrency      int
+	RabbitMQURL               string
+	RabbitMQQueue             string
+	KafkaBrokers              []string
+	KafkaTopic                string
+	KafkaGroup                string
+	KafkaDLQTopic             string
+	NATSURL                   string
+	NATSStream                string
+	NATSSubject               string
+	NATSDLQSubject            string
+	NATSConsumer              string
+	NATSAckWait               int
+	AnnotationTTL             int
+	HoldRecheckDelay          int
+	LanguageDetection         bool
+	LanguageSamplePages       int
+	StorageDriver             string
+	GCSBucket                 string
+	GCSCredentialsFile        string
+	TrashPrefix               string
+	TrashRetentionDays        int
+	TrashPurgeInterval        int
+	GotenbergAPIVersion       int
+	GotenbergStartupWait      int
+	LocalStorageRoot          string
+	AWSSessionToken           string
+	AWSMaxAttempts            int
+	AWSRetryMode              string
+	JobKinds                  []string
+	StreamMaxBytes            int64
+	AlertWebhookURL           string
+	TempMinFreeBytes          int64
+	RetentionClasses          map[string]string
+	MaxInputBytes             int64
+	ClamAVAddr                string
+	Rollups                   bool
+	RollupInterval            int
+	InputSources              []string
+	InputURLAllowedHosts      []string
+	AzureSASTokens            map[string]string
+	Routes                    map[string]string
+	GotenbergWebhookURL       string
+	GotenbergWebhookAddr      string
+	GotenbergWebhookInFlight  int
+	ChromiumDebugCapture      bool
+	ChromiumDebugPrefix       string
+	MaxRecoveries             int
+	BreakerThreshold          int
+	BreakerCooldown           int
+	MaxInFlight               int
+	MaxRSSBytes               int64
+	MergeDownloadConcurrency  int
+	TenantRateLimit           float64
+	TenantRateBurst           int
+	ShutdownReportKey         string
+	PreviewDPI                int
+	PreviewWatermark          string
+	GotenbergWarmupIdle       int
+	ShutdownGrace             int
+	IdempotencyTTL            int
+	MetricLabels              []string
+	LabelPriorities           map[string]string
+	TextQualityPages          int
+	PasswordTTL               int
+	AuditQueueSize            int
+
+	pendingQueueBase string
 }
 
 func Load() *Config {
@@ -43,28 +228,60 @@ func Load() *Config {
 	// lib/pq supports "key=value" connection strings and this avoids
 	// URI escaping issues for special characters in passwords.
 	// Build connection string with optional SSL certificate parameters
-	var dbURL string
-	if dbPassword != "" {
-		dbURL = fmt.Sprintf(
-			"host=%s port=%s dbname=%s user=%s password=%s sslmode=%s",
-			dbHost, dbPort, dbName, dbUser, dbPassword, dbSSLMode,
-		)
-	} else {
-		dbURL = fmt.Sprintf(
-			"host=%s port=%s dbname=%s user=%s sslmode=%s",
-			dbHost, dbPort, dbName, dbUser, dbSSLMode,
-		)
+	buildDSN := func(host string, port string, user string, password string) string {
+		var dsn string
+		if password != "" {
+			dsn = fmt.Sprintf(
+				"host=%s port=%s dbname=%s user=%s password=%s sslmode=%s",
+				host, port, dbName, user, password, dbSSLMode,
+			)
+		} else {
+			dsn = fmt.Sprintf(
+				"host=%s port=%s dbname=%s user=%s sslmode=%s",
+				host, port, dbName, user, dbSSLMode,
+			)
+		}
+
+		// Append SSL certificate paths if provided
+		if dbSSLCert != "" {
+			dsn += fmt.Sprintf(" sslcert=%s", dbSSLCert)
+		}
+		if dbSSLKey != "" {
+			dsn += fmt.Sprintf(" sslkey=%s", dbSSLKey)
+		}
+		if dbSSLRootCert != "" {
+			dsn += fmt.Sprintf(" sslrootcert=%s", dbSSLRootCert)
+		}
+		return dsn
 	}
+	dbURL := buildDSN(dbHost, dbPort, dbUser, dbPassword)
 
-	// Append SSL certificate paths if provided
-	if dbSSLCert != "" {
-		dbURL += fmt.Sprintf(" sslcert=%s", dbSSLCert)
+	// Query-heavy reads go to a replica when one is configured; it shares the
+	// database name and SSL settings with the primary
+	var dbReadURL string
+	if readHost := getEnv("DB_READ_HOST", ""); readHost != "" {
+		dbReadURL = buildDSN(
+			readHost,
+			getEnv("DB_READ_PORT", dbPort),
+			getEnv("DB_READ_USERNAME", dbUser),
+			getEnv("DB_READ_PASSWORD", dbPassword),
+		)
 	}
-	if dbSSLKey != "" {
-		dbURL += fmt.Sprintf(" sslkey=%s", dbSSLKey)
+
+	// A regional deployment only consumes its own region's queues so documents
+	// never leave their data-residency zone
+	region := getEnv("CONVERSION_REGION", "")
+	pendingQueueBase := applyPrefix(getEnv("CONVERSION_PENDING_QUEUE", "conversion:pending"), redisPrefix)
+
+	instanceID := getEnv("INSTANCE_ID", "")
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
 	}
-	if dbSSLRootCert != "" {
-		dbURL += fmt.Sprintf(" sslrootcert=%s", dbSSLRootCert)
+
+	// Shutdown reports are only stored when a key is set
+	shutdownReportKey := getEnv("SHUTDOWN_REPORT_KEY", "")
+	if shutdownReportKey != "" {
+		shutdownReportKey = applyPrefix(shutdownReportKey, redisPrefix)
 	}
 
 	return &Config{
@@ -72,28 +289,230 @@ func Load() *Config {
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 		RedisDB:       getEnvInt("REDIS_CONVERSION_DB", 3),
 		RedisPrefix:   redisPrefix,
-		PendingQueue:  applyPrefix(getEnv("CONVERSION_PENDING_QUEUE", "conversion:pending"), redisPrefix),
-		ProcessingQueue: applyPrefix(
+		PendingQueue:  regionQueue(pendingQueueBase, region),
+		ProcessingQueue: regionQueue(applyPrefix(
 			getEnv("CONVERSION_PROCESSING_QUEUE", "conversion:processing"),
 			redisPrefix,
-		),
-		FailedQueue: applyPrefix(
+		), region),
+		FailedQueue: regionQueue(applyPrefix(
 			getEnv("CONVERSION_FAILED_QUEUE", "conversion:failed"),
 			redisPrefix,
-		),
-		WorkerCount:       getEnvInt("CONVERSION_WORKER_COUNT", 3),
-		GotenbergURL:      getEnv("GOTENBERG_URL", "http://gotenberg:3000"),
-		S3Bucket:          getEnv("AWS_BUCKET", "paperpulse"),
+		), region),
+		LowPriorityQueue:          regionQueue(priorityQueue(pendingQueueBase, "low"), region),
+		HighPriorityQueue:         regionQueue(priorityQueue(pendingQueueBase, "high"), region),
+		RetryLaneQueue:            regionQueue(pendingQueueBase+":"+retryLane, region),
+		PriorityWeights:           getEnvMap("CONVERSION_PRIORITY_WEIGHTS"),
+		RejectionStream:           applyPrefix(getEnv("CONVERSION_REJECTION_STREAM", "conversion:rejections"), redisPrefix),
+		ClaimTokens:               getEnvBool("CONVERSION_CLAIM_TOKENS", true),
+		QueueBackend:              getEnv("CONVERSION_QUEUE_BACKEND", "list"),
+		StreamGroup:               getEnv("CONVERSION_STREAM_GROUP", "converter"),
+		DelayedQueue:              regionQueue(applyPrefix(getEnv("CONVERSION_DELAYED_QUEUE", "conversion:delayed"), redisPrefix), region),
+		WorkerCount:               getEnvInt("CONVERSION_WORKER_COUNT", 3),
+		RetryLaneWorkers:          getEnvInt("CONVERSION_RETRY_LANE_WORKERS", 1),
+		ClaimStrategy:             getEnv("CONVERSION_CLAIM_STRATEGY", "block-high"),
+		ClaimBlockTimeout:         getEnvInt("CONVERSION_CLAIM_BLOCK_SECONDS", 2),
+		ClaimIdleSleepMs:          getEnvInt("CONVERSION_CLAIM_IDLE_MS", 500),
+		ClaimErrorSleepMs:         getEnvInt("CONVERSION_CLAIM_ERROR_BACKOFF_MS", 5000),
+		GotenbergURL:              getEnv("GOTENBERG_URL", "http://gotenberg:3000"),
+		GotenbergMaxResponseBytes: getEnvInt64("GOTENBERG_MAX_RESPONSE_BYTES", 512*1024*1024),
+		PDFAConformance:           getEnv("PDFA_CONFORMANCE", "PDF/A-2b"),
+		PDFUA:                     getEnvBool("PDFA_PDFUA", false),
+		MarkdownTemplate:          getEnv("MARKDOWN_TEMPLATE", ""),
+		S3Bucket:                  getEnv("AWS_BUCKET", "paperpulse"),
 		// Prefer unified S3_* vars, fall back to legacy AWS_* vars for compatibility
-		S3Region:          getEnvWithFallback("S3_REGION", "AWS_DEFAULT_REGION", "us-east-1"),
-		AWSS3AccessKey:    getEnvWithFallback("S3_KEY", "AWS_ACCESS_KEY_ID", ""),
-		AWSS3SecretKey:    getEnvWithFallback("S3_SECRET", "AWS_SECRET_ACCESS_KEY", ""),
-		S3Endpoint:        getEnv("S3_ENDPOINT", ""),
-		S3UsePathStyle:    getEnvBool("S3_USE_PATH_STYLE_ENDPOINT", false),
-		DatabaseURL:       dbURL,
-		ConversionTimeout: getEnvInt("CONVERSION_TIMEOUT", 120),
-		MaxRetries:        getEnvInt("CONVERSION_MAX_RETRIES", 3),
+		S3Region:                  getEnvWithFallback("S3_REGION", "AWS_DEFAULT_REGION", "us-east-1"),
+		AWSS3AccessKey:            getEnvWithFallback("S3_KEY", "AWS_ACCESS_KEY_ID", ""),
+		AWSS3SecretKey:            getEnvWithFallback("S3_SECRET", "AWS_SECRET_ACCESS_KEY", ""),
+		S3Endpoint:                getEnv("S3_ENDPOINT", ""),
+		S3UsePathStyle:            getEnvBool("S3_USE_PATH_STYLE_ENDPOINT", false),
+		S3RateLimit:               getEnvFloat("S3_RATE_LIMIT", 0),
+		S3RateBurst:               getEnvInt("S3_RATE_BURST", 10),
+		DatabaseURL:               dbURL,
+		DatabaseReadURL:           dbReadURL,
+		ConversionTimeout:         getEnvInt("CONVERSION_TIMEOUT", 120),
+		AdaptiveTimeout:           getEnvBool("CONVERSION_ADAPTIVE_TIMEOUT", false),
+		AdaptiveTimeoutFactor:     getEnvFloat("CONVERSION_ADAPTIVE_TIMEOUT_FACTOR", 1.5),
+		AdaptiveTimeoutMinSamples: getEnvInt("CONVERSION_ADAPTIVE_TIMEOUT_MIN_SAMPLES", 20),
+		AdaptiveTimeoutMin:        getEnvInt("CONVERSION_ADAPTIVE_TIMEOUT_MIN", 10),
+		AdaptiveTimeoutMax:        getEnvInt("CONVERSION_ADAPTIVE_TIMEOUT_MAX", 900),
+		MaxRetries:                getEnvInt("CONVERSION_MAX_RETRIES", 3),
+		LeaseInterval:             getEnvInt("CONVERSION_LEASE_INTERVAL", 30),
+		LeaseTTL:                  getEnvInt("CONVERSION_LEASE_TTL", 90),
+		DBUpdateQueueSize:         getEnvInt("DB_UPDATE_QUEUE_SIZE", 1000),
+		DBUpdateMaxRetries:        getEnvInt("DB_UPDATE_MAX_RETRIES", 5),
+		Region:                    region,
+		AuditEnabled:              getEnvBool("AUDIT_LOG_ENABLED", false),
+		AuditS3Bucket:             getEnv("AUDIT_S3_BUCKET", ""),
+		AuditS3Prefix:             getEnv("AUDIT_S3_PREFIX", "conversion-audit"),
+		AuditObjectLockMode:       strings.ToUpper(getEnv("AUDIT_OBJECT_LOCK_MODE", "")),
+		AuditRetentionDays:        getEnvInt("AUDIT_RETENTION_DAYS", 0),
+		OutputEncryptionKeyID:     getEnv("OUTPUT_ENCRYPTION_KMS_KEY_ID", ""),
+		KMSEndpoint:               getEnv("KMS_ENDPOINT", ""),
+		DedupPrefix:               getEnv("OUTPUT_DEDUP_PREFIX", "cas"),
+		DedupTenants:              getEnvList("OUTPUT_DEDUP_TENANTS"),
+		PriorityAgingThreshold:    getEnvInt("CONVERSION_PRIORITY_AGING_SECONDS", 600),
+		PriorityAgingInterval:     getEnvInt("CONVERSION_PRIORITY_AGING_INTERVAL", 30),
+		MetricsAddr:               getEnv("METRICS_ADDR", ":9090"),
+		LogFormat:                 getEnv("LOG_FORMAT", "text"),
+		LogLevel:                  getEnv("LOG_LEVEL", "info"),
+		HTTPAddr:                  getEnv("HTTP_ADDR", ":8080"),
+		AdminToken:                getEnv("ADMIN_TOKEN", ""),
+		FeatureFlagCacheTTL:       getEnvInt("FEATURE_FLAG_CACHE_SECONDS", 30),
+		SNSEndpoint:               getEnv("SNS_ENDPOINT", ""),
+		EventsRoute:               getEnv("EVENTS_ROUTE", "none"),
+		EventsWebhookURL:          getEnv("EVENTS_WEBHOOK_URL", ""),
+		EventsWebhookSecret:       getEnv("EVENTS_WEBHOOK_SECRET", ""),
+		EventsTopic:               getEnv("EVENTS_TOPIC", ""),
+		EventsPubSubEndpoint:      getEnv("EVENTS_PUBSUB_ENDPOINT", ""),
+		EventsTimeout:             getEnvInt("EVENTS_TIMEOUT_SECONDS", 10),
+		CallbackSecret:            getEnv("CALLBACK_SECRET", ""),
+		CallbackMaxAttempts:       getEnvInt("CALLBACK_MAX_ATTEMPTS", 3),
+		CallbackAllowedHosts:      getEnvList("CALLBACK_ALLOWED_HOSTS"),
+		TenantConfigCacheTTL:      getEnvInt("TENANT_CONFIG_CACHE_SECONDS", 30),
+		JournalDir:                getEnv("CONVERSION_JOURNAL_DIR", "/tmp/conversions/journal"),
+		TempDir:                   getEnv("CONVERSION_TEMP_DIR", "/tmp/conversions"),
+		FastTempDir:               getEnv("CONVERSION_FAST_TEMP_DIR", ""),
+		FastTempMaxJobBytes:       getEnvInt64("CONVERSION_FAST_TEMP_MAX_JOB_BYTES", 32*1024*1024),
+		FastTempMaxBytes:          getEnvInt64("CONVERSION_FAST_TEMP_MAX_BYTES", 256*1024*1024),
+		InstanceID:                instanceID,
+		UserAgent:                 getEnv("SERVICE_USER_AGENT", "paperpulse-converter/"+Version),
+		OutboundHeaders:           getEnvMap("OUTBOUND_HEADERS"),
+		Standby:                   getEnvBool("CONVERSION_STANDBY", false),
+		StandbyControlKey:         applyPrefix(getEnv("CONVERSION_STANDBY_CONTROL_KEY", "conversion:control:standby"), redisPrefix),
+		MaintenanceWindows:        getEnv("MAINTENANCE_WINDOWS", ""),
+		SupportedExtensions:       getEnvListDefault("CONVERSION_SUPPORTED_EXTENSIONS", defaultSupportedExtensions),
+		DetectFormat:              getEnvBool("CONVERSION_DETECT_FORMAT", true),
+		ImageNormalize:            getEnvBool("IMAGE_NORMALIZE", true),
+		ImageMaxDPI:               getEnvInt("IMAGE_MAX_DPI", 300),
+		EmailAppendAttachments:    getEnvBool("EMAIL_APPEND_ATTACHMENTS", false),
+		EmailMaxAttachments:       getEnvInt("EMAIL_MAX_ATTACHMENTS", 20),
+		Annotations:               getEnvBool("CONVERSION_ANNOTATIONS", true),
+		MergeMaxInputs:            getEnvInt("MERGE_MAX_INPUTS", 50),
+		CostPeakWindows:           getEnv("COST_PEAK_WINDOWS", ""),
+		CostPeakEngine:            getEnv("COST_PEAK_ENGINE", "soffice"),
+		CostPeakDemote:            getEnvBool("COST_PEAK_DEMOTE", true),
+		SofficePath:               getEnv("SOFFICE_PATH", "soffice"),
+		Thumbnails:                getEnvBool("THUMBNAILS_ENABLED", false),
+		ThumbnailSizes:            getEnvMap("THUMBNAIL_SIZES"),
+		ThumbnailFormat:           getEnv("THUMBNAIL_FORMAT", "png"),
+		ThumbnailS3Prefix:         getEnv("THUMBNAIL_S3_PREFIX", "thumbnails"),
+		FileLock:                  getEnvBool("CONVERSION_FILE_LOCK", true),
+		FileLockRetryDelay:        getEnvInt("CONVERSION_FILE_LOCK_RETRY_SECONDS", 5),
+		DBRetryColumns:            getEnvBool("DB_RETRY_COLUMNS", false),
+		JobSigningSecrets:         getEnvList("JOB_SIGNING_SECRETS"),
+		JobSignatureEnforce:       getEnvBool("JOB_SIGNATURE_ENFORCE", true),
+		QuarantineQueue:           regionQueue(applyPrefix(getEnv("CONVERSION_QUARANTINE_QUEUE", "conversion:quarantine"), redisPrefix), region),
+		RedisMemoryWarnPercent:    getEnvInt("REDIS_MEMORY_WARN_PERCENT", 90),
+		QueueMirror:               getEnvBool("QUEUE_MIRROR_ENABLED", false),
+		QueueMirrorInterval:       getEnvInt("QUEUE_MIRROR_INTERVAL", 30),
+		QueueDriver:               getEnv("QUEUE_DRIVER", "redis"),
+		SQSQueueURL:               getEnv("SQS_QUEUE_URL", ""),
+		SQSEndpoint:               getEnv("SQS_ENDPOINT", ""),
+		SQSVisibilityTimeout:      getEnvInt("SQS_VISIBILITY_TIMEOUT", 120),
+		HTMLAssetMax:              getEnvInt("HTML_ASSET_MAX", 50),
+		HTMLAssetConcurrency:      getEnvInt("HTML_ASSET_CONCURRENCY", 4),
+		RabbitMQURL:               getEnv("RABBITMQ_URL", ""),
+		RabbitMQQueue:             getEnv("RABBITMQ_QUEUE", "conversions"),
+		KafkaBrokers:              getEnvList("KAFKA_BROKERS"),
+		KafkaTopic:                getEnv("KAFKA_TOPIC", "conversions"),
+		KafkaGroup:                getEnv("KAFKA_GROUP", "converter"),
+		KafkaDLQTopic:             getEnv("KAFKA_DLQ_TOPIC", "conversions.dlq"),
+		NATSURL:                   getEnv("NATS_URL", ""),
+		NATSStream:                getEnv("NATS_STREAM", "CONVERSIONS"),
+		NATSSubject:               getEnv("NATS_SUBJECT", "conversions.jobs"),
+		NATSDLQSubject:            getEnv("NATS_DLQ_SUBJECT", "conversions.failed"),
+		NATSConsumer:              getEnv("NATS_CONSUMER", "converter"),
+		NATSAckWait:               getEnvInt("NATS_ACK_WAIT", 0),
+		AnnotationTTL:             getEnvInt("CONVERSION_ANNOTATION_TTL", 7*24*3600),
+		HoldRecheckDelay:          getEnvInt("CONVERSION_HOLD_RECHECK_SECONDS", 60),
+		LanguageDetection:         getEnvBool("LANGUAGE_DETECTION_ENABLED", true),
+		LanguageSamplePages:       getEnvInt("LANGUAGE_SAMPLE_PAGES", 10),
+		StorageDriver:             getEnv("STORAGE_DRIVER", "s3"),
+		GCSBucket:                 getEnv("GCS_BUCKET", ""),
+		GCSCredentialsFile:        getEnv("GCS_CREDENTIALS_FILE", ""),
+		TrashPrefix:               getEnv("TRASH_PREFIX", "trash"),
+		TrashRetentionDays:        getEnvInt("TRASH_RETENTION_DAYS", 30),
+		TrashPurgeInterval:        getEnvInt("TRASH_PURGE_INTERVAL", 3600),
+		GotenbergAPIVersion:       getEnvInt("GOTENBERG_API_VERSION", 0),
+		GotenbergStartupWait:      getEnvInt("GOTENBERG_STARTUP_WAIT", 60),
+		LocalStorageRoot:          getEnv("LOCAL_STORAGE_ROOT", ""),
+		AWSSessionToken:           getEnv("AWS_SESSION_TOKEN", ""),
+		AWSMaxAttempts:            getEnvInt("AWS_MAX_ATTEMPTS", 3),
+		AWSRetryMode:              getEnv("AWS_RETRY_MODE", "standard"),
+		JobKinds:                  getEnvList("CONVERSION_JOB_KINDS"),
+		StreamMaxBytes:            getEnvInt64("CONVERSION_STREAM_MAX_BYTES", 0),
+		AlertWebhookURL:           getEnv("ALERT_WEBHOOK_URL", ""),
+		TempMinFreeBytes:          getEnvInt64("CONVERSION_TEMP_MIN_FREE_BYTES", 0),
+		RetentionClasses:          getEnvMap("CONVERSION_RETENTION_CLASSES"),
+		MaxInputBytes:             getEnvInt64("CONVERSION_MAX_INPUT_BYTES", 0),
+		ClamAVAddr:                getEnv("CLAMAV_ADDR", ""),
+		Rollups:                   getEnvBool("METRICS_ROLLUP_ENABLED", false),
+		RollupInterval:            getEnvInt("METRICS_ROLLUP_INTERVAL", 60),
+		InputSources:              getEnvList("CONVERSION_INPUT_SOURCES"),
+		InputURLAllowedHosts:      getEnvList("INPUT_URL_ALLOWED_HOSTS"),
+		AzureSASTokens:            getEnvMap("AZURE_STORAGE_SAS_TOKENS"),
+		Routes:                    getEnvMap("CONVERSION_ROUTES"),
+		GotenbergWebhookURL:       getEnv("GOTENBERG_WEBHOOK_URL", ""),
+		GotenbergWebhookAddr:      getEnv("GOTENBERG_WEBHOOK_ADDR", ":8091"),
+		GotenbergWebhookInFlight:  getEnvInt("GOTENBERG_WEBHOOK_MAX_IN_FLIGHT", 32),
+		ChromiumDebugCapture:      getEnvBool("CHROMIUM_DEBUG_CAPTURE", false),
+		ChromiumDebugPrefix:       getEnv("CHROMIUM_DEBUG_PREFIX", "debug/chromium/"),
+		MaxRecoveries:             getEnvInt("CONVERSION_MAX_RECOVERIES", 3),
+		BreakerThreshold:          getEnvInt("CIRCUIT_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:           getEnvInt("CIRCUIT_BREAKER_COOLDOWN", 30),
+		MaxInFlight:               getEnvInt("CONVERSION_MAX_IN_FLIGHT", 0),
+		MaxRSSBytes:               getEnvInt64("CONVERSION_MAX_RSS_BYTES", 0),
+		MergeDownloadConcurrency:  getEnvInt("MERGE_DOWNLOAD_CONCURRENCY", 4),
+		TenantRateLimit:           getEnvFloat("TENANT_RATE_LIMIT", 0),
+		TenantRateBurst:           getEnvInt("TENANT_RATE_BURST", 10),
+		ShutdownReportKey:         shutdownReportKey,
+		PreviewDPI:                getEnvInt("PREVIEW_DPI", 72),
+		PreviewWatermark:          getEnv("PREVIEW_WATERMARK", "PREVIEW"),
+		GotenbergWarmupIdle:       getEnvInt("GOTENBERG_WARMUP_IDLE", 0),
+		ShutdownGrace:             getEnvInt("SHUTDOWN_GRACE", 20),
+		IdempotencyTTL:            getEnvInt("IDEMPOTENCY_TTL", 86400),
+		MetricLabels:              getEnvList("CONVERSION_METRIC_LABELS"),
+		LabelPriorities:           getEnvMap("CONVERSION_LABEL_PRIORITIES"),
+		TextQualityPages:          getEnvInt("TEXT_QUALITY_PAGES", 20),
+		PasswordTTL:               getEnvInt("CONVERSION_PASSWORD_TTL", 86400),
+		AuditQueueSize:            getEnvInt("AUDIT_QUEUE_SIZE", 1000),
+		pendingQueueBase:          pendingQueueBase,
+	}
+}
+
+// PendingQueueFor returns the pending queue for a priority ("high",
+// "normal"/"" or "low") as consumed by deployments in region.
+func (c *Config) PendingQueueFor(priority string, region string) string {
+	return regionQueue(priorityQueue(c.pendingQueueBase, priority), region)
+}
+
+// RetryLaneQueueFor returns the lane for user-initiated retries as consumed
+// by deployments in region.
+func (c *Config) RetryLaneQueueFor(region string) string {
+	return regionQueue(c.pendingQueueBase+":"+retryLane, region)
+}
+
+// KindQueueFor returns the queue jobs of one kind are handed to when a
+// worker that doesn't take the kind claims them, as consumed by deployments
+// in region.
+func (c *Config) KindQueueFor(kind string, region string) string {
+	return regionQueue(c.pendingQueueBase+":kind:"+kind, region)
+}
+
+// priorityQueue keeps normal priority on the unsuffixed pending queue so
+// producers that predate priorities keep working.
+func priorityQueue(base string, priority string) string {
+	if priority == "" || priority == "normal" {
+		return base
+	}
+	return base + ":" + priority
+}
+
+func regionQueue(queue string, region string) string {
+	if region == "" {
+		return queue
 	}
+	return queue + ":" + region
 }
 
 func getEnv(key, fallback string) string {
@@ -122,6 +541,51 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+func getEnvInt64(key string, fallback int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if intVal, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return intVal
+		}
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return fallback
+}
+
+// getEnvMap parses "Key=Value,Other=Value" pairs.
+func getEnvMap(key string) map[string]string {
+	values := map[string]string{}
+	for _, pair := range getEnvList(key) {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return values
+}
+
+func getEnvListDefault(key string, fallback []string) []string {
+	if values := getEnvList(key); len(values) > 0 {
+		return values
+	}
+	return fallback
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
 func applyPrefix(key string, prefix string) string {
 	if prefix == "" {
 		return key
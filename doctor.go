@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"converter/config"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// doctorCheck is one line of the doctor report. run returns what it found,
+// or the error that fails the check.
+type doctorCheck struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+// runDoctor implements `converter doctor`, which checks that Redis,
+// Postgres, storage and Gotenberg are reachable with the configured
+// credentials and allow what the workers do, and prints a pass/fail report.
+// It fails when any check does.
+func runDoctor(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "time limit for each check")
+	probeKey := fs.String("key", "", "storage key of the probe object (default doctor/<host>-<time>.txt)")
+	fs.Parse(args)
+
+	if *probeKey == "" {
+		host, _ := os.Hostname()
+		*probeKey = fmt.Sprintf("doctor/%s-%s.txt", host, time.Now().UTC().Format("20060102T150405Z"))
+	}
+
+	checks := []doctorCheck{
+		{"redis", func(ctx context.Context) (string, error) { return doctorRedis(ctx, cfg) }},
+		{"postgres", func(ctx context.Context) (string, error) { return doctorPostgres(ctx, cfg) }},
+		{"storage", func(ctx context.Context) (string, error) { return doctorStorage(ctx, cfg, *probeKey) }},
+		{"gotenberg", func(ctx context.Context) (string, error) { return doctorGotenberg(ctx, cfg) }},
+	}
+	if cfg.ClamAVAddr != "" {
+		checks = append(checks, doctorCheck{"clamav", func(ctx context.Context) (string, error) {
+			return cfg.ClamAVAddr + " answers PING", services.NewClamAV(cfg.ClamAVAddr).Ping(ctx)
+		}})
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tDETAIL")
+	failed := 0
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		detail, err := check.run(ctx)
+		cancel()
+		result := "PASS"
+		if err != nil {
+			result, detail = "FAIL", err.Error()
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", check.name, result, detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// doctorRedis connects and writes, reads and deletes a short-lived key.
+func doctorRedis(ctx context.Context, cfg *config.Config) (string, error) {
+	redisClient, err := connectRedis(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	defer redisClient.Close()
+
+	key := cfg.RedisPrefix + "conversion:doctor"
+	if err := redisClient.Set(ctx, key, "ok", time.Minute).Err(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if value, err := redisClient.Get(ctx, key).Result(); err != nil || value != "ok" {
+		return "", fmt.Errorf("failed to read back %s: %v", key, orMismatch(err))
+	}
+	if err := redisClient.Del(ctx, key).Err(); err != nil {
+		return "", fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+
+	detail := cfg.RedisAddr + ", read/write/delete ok"
+	if policy, err := redisClient.ConfigGet(ctx, "maxmemory-policy").Result(); err == nil && policy["maxmemory-policy"] != "" {
+		detail += ", maxmemory-policy " + policy["maxmemory-policy"]
+	}
+	return detail, nil
+}
+
+// doctorPostgres connects to the primary and the replica, and checks the
+// privileges on the tables the enabled features write.
+func doctorPostgres(ctx context.Context, cfg *config.Config) (string, error) {
+	dbSvc, err := services.NewDatabaseService(cfg.DatabaseURL, cfg.DatabaseReadURL)
+	if err != nil {
+		return "", err
+	}
+	defer dbSvc.Close()
+	if err := dbSvc.Ping(ctx); err != nil {
+		return "", err
+	}
+
+	tables := map[string]string{
+		"file_conversions": "SELECT, UPDATE",
+		"conversion_trash": "SELECT, INSERT, DELETE",
+	}
+	if cfg.AuditEnabled {
+		tables["conversion_audit_log"] = "SELECT, INSERT"
+	}
+	if cfg.QueueMirror {
+		tables["conversion_queue_mirror"] = "SELECT, INSERT, DELETE"
+	}
+	if cfg.Rollups {
+		tables["conversion_rollups"] = "INSERT, UPDATE"
+	}
+	if err := dbSvc.CheckPrivileges(ctx, tables); err != nil {
+		return "", err
+	}
+
+	detail := fmt.Sprintf("privileges ok on %d tables", len(tables))
+	if cfg.DatabaseReadURL != "" {
+		detail += ", read replica reachable"
+	}
+	return detail, nil
+}
+
+// doctorStorage writes, reads back and deletes a probe object.
+func doctorStorage(ctx context.Context, cfg *config.Config, key string) (string, error) {
+	storage, err := services.NewStorage(ctx, cfg, services.NewS3RateLimiter(cfg))
+	if err != nil {
+		return "", fmt.Errorf("failed to set up storage: %w", err)
+	}
+	if err := storage.Ping(ctx); err != nil {
+		return "", err
+	}
+
+	content := "converter doctor probe " + config.Version
+	if err := storage.UploadStream(ctx, strings.NewReader(content), key, "text/plain"); err != nil {
+		return "", fmt.Errorf("write probe %s: %w", key, err)
+	}
+	body, err := storage.Open(ctx, key)
+	if err != nil {
+		storage.Delete(ctx, key)
+		return "", fmt.Errorf("read probe %s: %w", key, err)
+	}
+	read, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || string(read) != content {
+		storage.Delete(ctx, key)
+		return "", fmt.Errorf("read probe %s: %v", key, orMismatch(err))
+	}
+	if err := storage.Delete(ctx, key); err != nil {
+		return "", fmt.Errorf("delete probe %s: %w", key, err)
+	}
+	if _, err := storage.Stat(ctx, key); !errors.Is(err, services.ErrObjectNotFound) {
+		return "", fmt.Errorf("probe %s still there after delete: %v", key, err)
+	}
+	return cfg.StorageDriver + ", read/write/delete ok", nil
+}
+
+// doctorGotenberg detects the version and converts a one-line text file to
+// PDF/A.
+func doctorGotenberg(ctx context.Context, cfg *config.Config) (string, error) {
+	gotenbergSvc := services.NewGotenbergService(cfg.GotenbergURL, cfg.GotenbergMaxResponseBytes, services.NewRequestIdentity(cfg))
+	version := ""
+	if cfg.GotenbergAPIVersion != 0 {
+		if err := gotenbergSvc.SetAPIVersion(cfg.GotenbergAPIVersion); err != nil {
+			return "", err
+		}
+		version = fmt.Sprintf("%d (configured)", cfg.GotenbergAPIVersion)
+	} else {
+		detected, err := gotenbergSvc.DetectVersion(ctx)
+		if err != nil {
+			return "", err
+		}
+		version = detected
+	}
+
+	started := time.Now()
+	if err := gotenbergSvc.Warm(ctx, services.ConvertOptions{Conformance: cfg.PDFAConformance}); err != nil {
+		return "", fmt.Errorf("test conversion failed: %w", err)
+	}
+	return fmt.Sprintf("version %s, test conversion to %s in %s", version, cfg.PDFAConformance, time.Since(started).Round(time.Millisecond)), nil
+}
+
+// orMismatch describes a failed read back: its error, or the content not
+// matching what was written.
+func orMismatch(err error) string {
+	if err != nil && err != redis.Nil {
+		return err.Error()
+	}
+	return "content does not match"
+}
@@ -0,0 +1,84 @@
+// Package logging configures the process-wide slog logger and carries a
+// per-job logger (worker, conversion and trace IDs) through the context.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type ctxKey int
+
+const (
+	loggerKey ctxKey = iota
+	traceIDKey
+)
+
+// Setup installs the default logger. format is "json" or "text"; level is
+// one of debug, info, warn, error. Output from the standard log package is
+// routed through the same handler.
+func Setup(format string, level string) {
+	slog.SetDefault(slog.New(newHandler(os.Stderr, format, level)))
+	log.SetFlags(0)
+}
+
+func newHandler(w io.Writer, format string, level string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+	if strings.EqualFold(format, "json") {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// With returns a context whose logger carries the extra attributes.
+func With(ctx context.Context, args ...any) context.Context {
+	return context.WithValue(ctx, loggerKey, From(ctx).With(args...))
+}
+
+// From returns the context's logger, or the default logger.
+func From(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// WithTraceID stores the trace ID and adds it to the context's logger. An
+// empty ID is replaced with a generated one.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	if traceID == "" {
+		traceID = NewTraceID()
+	}
+	ctx = context.WithValue(ctx, traceIDKey, traceID)
+	return With(ctx, "trace_id", traceID)
+}
+
+// TraceID returns the trace ID stored by WithTraceID, or "".
+func TraceID(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey).(string)
+	return id
+}
+
+func NewTraceID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestWith_CarriesAttributes(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	base := slog.New(newHandler(&buf, "json", "info"))
+	ctx := context.WithValue(context.Background(), loggerKey, base)
+
+	ctx = With(ctx, "worker_id", 3)
+	ctx = WithTraceID(ctx, "trace-1")
+	From(ctx).Info("conversion completed", "conversion_id", 42)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("output is not JSON: %v (%s)", err, buf.String())
+	}
+	if entry["worker_id"] != float64(3) || entry["trace_id"] != "trace-1" || entry["conversion_id"] != float64(42) {
+		t.Fatalf("entry = %v", entry)
+	}
+	if TraceID(ctx) != "trace-1" {
+		t.Fatalf("TraceID = %q, want trace-1", TraceID(ctx))
+	}
+}
+
+func TestWithTraceID_GeneratesWhenEmpty(t *testing.T) {
+	t.Parallel()
+
+	if id := TraceID(WithTraceID(context.Background(), "")); len(id) != 32 {
+		t.Fatalf("generated trace ID = %q, want 32 hex characters", id)
+	}
+}
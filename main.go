@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
-	"log"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 	"time"
 
+	"converter/api"
 	"converter/config"
+	"converter/logging"
+	"converter/metrics"
+	"converter/queue"
+	"converter/schedule"
 	"converter/services"
 	"converter/worker"
 
@@ -17,10 +24,77 @@ import (
 )
 
 func main() {
-	log.Println("Starting PaperPulse Conversion Service...")
-
 	// Load configuration
 	cfg := config.Load()
+	logging.Setup(cfg.LogFormat, cfg.LogLevel)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate-queue" {
+		if err := runMigrateQueue(cfg, os.Args[2:]); err != nil {
+			fatal("Queue migration failed", "error", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(cfg, os.Args[2:]); err != nil {
+			fatal("Replay failed", "error", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "annotate" {
+		if err := runAnnotate(cfg, os.Args[2:]); err != nil {
+			fatal("Annotate failed", "error", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "restore-queue" {
+		if err := runRestoreQueue(cfg, os.Args[2:]); err != nil {
+			fatal("Queue restore failed", "error", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "snapshot-queue" {
+		if err := runSnapshotQueue(cfg, os.Args[2:]); err != nil {
+			fatal("Queue snapshot failed", "error", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "restore-snapshot" {
+		if err := runRestoreSnapshot(cfg, os.Args[2:]); err != nil {
+			fatal("Snapshot restore failed", "error", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "requeue-failed" {
+		if err := runRequeueFailed(cfg, os.Args[2:]); err != nil {
+			fatal("Bulk requeue failed", "error", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "requeue-report" {
+		if err := runRequeueReport(cfg, os.Args[2:]); err != nil {
+			fatal("Requeue report failed", "error", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		if err := runDoctor(cfg, os.Args[2:]); err != nil {
+			fatal("Doctor found problems", "error", err)
+		}
+		return
+	}
+
+	runOnce := flag.Bool("run-once", false, "process jobs until the pending queue is empty, then exit")
+	flag.Parse()
+
+	slog.Info("Starting PaperPulse Conversion Service", "version", config.Version)
 
 	// Initialize Redis client
 	redisClient := redis.NewClient(&redis.Options{
@@ -32,22 +106,219 @@ func main() {
 	// Test Redis connection
 	ctx := context.Background()
 	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		fatal("Failed to connect to Redis", "error", err)
 	}
-	log.Println("Connected to Redis successfully")
+	slog.Info("Connected to Redis successfully")
 
 	// Initialize database service
-	dbSvc, err := services.NewDatabaseService(cfg.DatabaseURL)
+	dbSvc, err := services.NewDatabaseService(cfg.DatabaseURL, cfg.DatabaseReadURL)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", "error", err)
 	}
 	defer dbSvc.Close()
-	log.Println("Connected to database successfully")
+	slog.Info("Connected to database successfully", "read_replica", cfg.DatabaseReadURL != "")
+
+	// Start background DB status updater
+	dbUpdater := services.NewStatusUpdater(dbSvc, cfg.DBUpdateQueueSize, cfg.DBUpdateMaxRetries)
+	go dbUpdater.Run()
+
+	// Open the bucket inputs are read from and outputs written to
+	if cfg.StorageDriver == services.StorageGCS && cfg.GCSBucket == "" {
+		fatal("GCS_BUCKET is required with STORAGE_DRIVER=gcs")
+	}
+	if cfg.StorageDriver == services.StorageLocal && cfg.LocalStorageRoot == "" {
+		fatal("LOCAL_STORAGE_ROOT is required with STORAGE_DRIVER=local")
+	}
+	// One S3_RATE_LIMIT bucket for every S3 client in the process
+	s3Limiter := services.NewS3RateLimiter(cfg)
+	storage, err := services.NewStorage(ctx, cfg, s3Limiter)
+	if err != nil {
+		fatal("Failed to set up storage", "error", err)
+	}
+
+	// Shared by the SQS, SNS, KMS and audit clients
+	awsCfg, err := services.NewAWSConfig(ctx, cfg)
+	if err != nil {
+		fatal("Failed to set up AWS clients", "error", err)
+	}
 
 	// Create worker pool
-	pool := worker.NewPool(cfg, redisClient, dbSvc)
+	pool := worker.NewPool(cfg, awsCfg, redisClient, dbSvc, dbUpdater, storage, s3Limiter)
+	pool.SetRunOnce(*runOnce)
+
+	if err := (services.EventRoute{Type: cfg.EventsRoute, URL: cfg.EventsWebhookURL, Topic: cfg.EventsTopic}).Validate(); err != nil {
+		fatal("Invalid EVENTS_ROUTE", "error", err)
+	}
+
+	if !services.ValidPDFAConformance(cfg.PDFAConformance) {
+		fatal("Invalid PDFA_CONFORMANCE", "value", cfg.PDFAConformance)
+	}
+
+	windows, err := schedule.ParseWindows(cfg.MaintenanceWindows)
+	if err != nil {
+		fatal("Invalid MAINTENANCE_WINDOWS", "error", err)
+	}
+	pool.SetMaintenanceWindows(windows)
+
+	peakWindows, err := schedule.ParsePeriods(cfg.CostPeakWindows)
+	if err != nil {
+		fatal("Invalid COST_PEAK_WINDOWS", "error", err)
+	}
+	if err := worker.ValidateCostConfig(cfg.CostPeakEngine); err != nil {
+		fatal("Invalid COST_PEAK_ENGINE", "error", err)
+	}
+	pool.SetPeakWindows(peakWindows)
+
+	thumbnailSizes, err := worker.ParseThumbnailSizes(cfg.ThumbnailSizes)
+	if err != nil {
+		fatal("Invalid THUMBNAIL_SIZES", "error", err)
+	}
+	if !worker.ValidThumbnailFormat(cfg.ThumbnailFormat) {
+		fatal("Invalid THUMBNAIL_FORMAT", "value", cfg.ThumbnailFormat)
+	}
+	pool.SetThumbnailSizes(thumbnailSizes)
+
+	retentionClasses, err := services.ParseRetentionClasses(cfg.RetentionClasses)
+	if err != nil {
+		fatal("Invalid CONVERSION_RETENTION_CLASSES", "error", err)
+	}
+	if len(retentionClasses) > 0 && cfg.StorageDriver != services.StorageS3 {
+		fatal("CONVERSION_RETENTION_CLASSES needs S3 Object Lock", "storage_driver", cfg.StorageDriver)
+	}
+	pool.SetRetentionClasses(retentionClasses)
+
+	markdownTemplate, err := services.LoadMarkdownTemplate(cfg.MarkdownTemplate)
+	if err != nil {
+		fatal("Invalid MARKDOWN_TEMPLATE", "error", err)
+	}
+	pool.SetMarkdownTemplate(markdownTemplate)
+
+	if err := worker.ValidateClaimConfig(cfg.ClaimStrategy, cfg.ClaimBlockTimeout, cfg.ClaimIdleSleepMs, cfg.ClaimErrorSleepMs); err != nil {
+		fatal("Invalid claim configuration", "error", err)
+	}
+	if err := services.ValidateQueueBackend(cfg.QueueBackend, cfg.StreamGroup); err != nil {
+		fatal("Invalid queue backend", "error", err)
+	}
+	jobKinds, err := services.ParseJobKinds(cfg.JobKinds)
+	if err != nil {
+		fatal("Invalid CONVERSION_JOB_KINDS", "error", err)
+	}
+	// Kind queues live in Redis; a queue source has nowhere to hand jobs on
+	if jobKinds != nil && cfg.QueueDriver != queue.DriverRedis {
+		fatal("CONVERSION_JOB_KINDS needs QUEUE_DRIVER=redis", "queue_driver", cfg.QueueDriver)
+	}
+	pool.SetJobKinds(jobKinds)
+	routes, err := services.ParseRoutes(cfg.Routes)
+	if err != nil {
+		fatal("Invalid CONVERSION_ROUTES", "error", err)
+	}
+	pool.SetRoutes(routes)
+	metricLabels, err := services.ParseMetricLabels(cfg.MetricLabels)
+	if err != nil {
+		fatal("Invalid CONVERSION_METRIC_LABELS", "error", err)
+	}
+	pool.SetMetricLabels(metricLabels)
+	labelRules, err := services.ParseLabelPriorities(cfg.LabelPriorities)
+	if err != nil {
+		fatal("Invalid CONVERSION_LABEL_PRIORITIES", "error", err)
+	}
+	pool.SetLabelPriorities(labelRules)
+	if cfg.GotenbergWebhookURL != "" {
+		if cfg.GotenbergWebhookInFlight <= 0 {
+			fatal("Invalid GOTENBERG_WEBHOOK_MAX_IN_FLIGHT", "value", cfg.GotenbergWebhookInFlight)
+		}
+		pool.EnableWebhooks(cfg.GotenbergWebhookURL, cfg.GotenbergWebhookInFlight)
+	}
+	switch cfg.QueueDriver {
+	case queue.DriverRedis:
+	case queue.DriverSQS:
+		if cfg.SQSQueueURL == "" {
+			fatal("SQS_QUEUE_URL is required with QUEUE_DRIVER=sqs")
+		}
+		// SQS accepts visibility timeouts up to 12 hours
+		if cfg.SQSVisibilityTimeout < 3 || cfg.SQSVisibilityTimeout > 43200 {
+			fatal("Invalid SQS_VISIBILITY_TIMEOUT", "value", cfg.SQSVisibilityTimeout)
+		}
+		visibility := time.Duration(cfg.SQSVisibilityTimeout) * time.Second
+		pool.SetSource(queue.NewSQS(services.NewSQSClient(awsCfg, cfg), cfg.SQSQueueURL, visibility), visibility)
+	case queue.DriverRabbitMQ:
+		if cfg.RabbitMQURL == "" || cfg.RabbitMQQueue == "" {
+			fatal("RABBITMQ_URL and RABBITMQ_QUEUE are required with QUEUE_DRIVER=rabbitmq")
+		}
+		// One unacked delivery per worker; the broker holds the rest
+		rabbit := queue.NewRabbitMQ(cfg.RabbitMQURL, cfg.RabbitMQQueue, cfg.WorkerCount)
+		if err := rabbit.Connect(); err != nil {
+			fatal("Failed to set up RabbitMQ", "error", err)
+		}
+		defer rabbit.Close()
+		pool.SetSource(rabbit, 0)
+	case queue.DriverKafka:
+		if len(cfg.KafkaBrokers) == 0 || cfg.KafkaTopic == "" || cfg.KafkaGroup == "" || cfg.KafkaDLQTopic == "" {
+			fatal("KAFKA_BROKERS, KAFKA_TOPIC, KAFKA_GROUP and KAFKA_DLQ_TOPIC are required with QUEUE_DRIVER=kafka")
+		}
+		kafkaSource, err := queue.NewKafka(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaGroup, cfg.KafkaDLQTopic)
+		if err != nil {
+			fatal("Failed to set up Kafka", "error", err)
+		}
+		defer kafkaSource.Close()
+		go kafkaSource.Run(ctx)
+		pool.SetSource(kafkaSource, 0)
+	case queue.DriverNATS:
+		if cfg.NATSURL == "" || cfg.NATSStream == "" || cfg.NATSSubject == "" || cfg.NATSDLQSubject == "" || cfg.NATSConsumer == "" {
+			fatal("NATS_URL, NATS_STREAM, NATS_SUBJECT, NATS_DLQ_SUBJECT and NATS_CONSUMER are required with QUEUE_DRIVER=nats")
+		}
+		if cfg.NATSAckWait < 0 {
+			fatal("Invalid NATS_ACK_WAIT", "value", cfg.NATSAckWait)
+		}
+		// Long enough for a conversion to finish without a progress report
+		ackWait := time.Duration(cfg.NATSAckWait) * time.Second
+		if ackWait == 0 {
+			ackWait = worker.LongestTimeout(cfg) + 30*time.Second
+		}
+		natsSource, err := queue.NewNATS(ctx, queue.NATSConfig{
+			URL:           cfg.NATSURL,
+			Stream:        cfg.NATSStream,
+			Subject:       cfg.NATSSubject,
+			DLQSubject:    cfg.NATSDLQSubject,
+			Consumer:      cfg.NATSConsumer,
+			AckWait:       ackWait,
+			MaxAckPending: cfg.WorkerCount,
+		})
+		if err != nil {
+			fatal("Failed to set up NATS JetStream", "error", err)
+		}
+		defer natsSource.Close()
+		pool.SetSource(natsSource, ackWait)
+	default:
+		fatal("Invalid QUEUE_DRIVER", "value", cfg.QueueDriver)
+	}
+
+	// Fail here rather than with a 400 from Gotenberg on every job
+	if err := pool.SetGotenbergVersion(ctx); err != nil {
+		fatal("Failed to set up the Gotenberg API version", "url", cfg.GotenbergURL, "error", err)
+	}
+
+	// A Redis that evicts keys without a TTL can silently drop queued jobs
+	pool.CheckRedisEviction(ctx)
+
+	// Let producers feature-detect this deployment
+	pool.PublishCapabilities(ctx)
+
+	// Clean up after jobs interrupted by a previous crash
+	pool.ReconcileJournal(ctx)
+
+	// Gotenberg delivers webhook conversions here, in run-once mode too
+	if cfg.GotenbergWebhookURL != "" {
+		go func() {
+			slog.Info("Serving Gotenberg webhooks", "addr", cfg.GotenbergWebhookAddr, "url", cfg.GotenbergWebhookURL)
+			if err := http.ListenAndServe(cfg.GotenbergWebhookAddr, pool.WebhookHandler()); err != nil {
+				slog.Error("Webhook server stopped", "error", err)
+			}
+		}()
+	}
 
 	// Start workers
+	started := time.Now()
 	var wg sync.WaitGroup
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -57,7 +328,28 @@ func main() {
 			defer wg.Done()
 			pool.StartWorker(ctx, workerID)
 		}(i)
-		log.Printf("Started worker %d", i)
+		slog.Info("Started worker", "worker_id", i)
+	}
+	for i := cfg.WorkerCount; i < cfg.WorkerCount+cfg.RetryLaneWorkers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			pool.StartRetryLaneWorker(ctx, workerID)
+		}(i)
+		slog.Info("Started retry lane worker", "worker_id", i)
+	}
+
+	// Start delayed retry scheduler; it runs outside the worker wait group so
+	// run-once mode can wait on workers alone
+	delayedDone := make(chan struct{})
+	go func() {
+		defer close(delayedDone)
+		pool.DelayedLoop(ctx)
+	}()
+
+	if *runOnce {
+		runUntilDrained(&wg, pool, dbUpdater, redisClient, cancel, delayedDone)
+		return
 	}
 
 	// Start stale job recovery goroutine
@@ -67,33 +359,199 @@ func main() {
 		pool.RecoveryLoop(ctx)
 	}()
 
-	log.Printf("Started %d conversion workers", cfg.WorkerCount)
-	log.Printf("Listening on Redis queue: %s", cfg.PendingQueue)
-	log.Printf("Gotenberg URL: %s", cfg.GotenbergURL)
-	log.Println("Service is ready to process conversions")
+	// Watch for promotion when running as a hot standby
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pool.StandbyLoop(ctx)
+	}()
+
+	// Start low priority aging goroutine
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pool.AgingLoop(ctx)
+	}()
+
+	// Watch Redis memory and mirror the backlog to the database
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pool.MemoryGuardLoop(ctx)
+	}()
+	if cfg.TempMinFreeBytes > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.DiskGuardLoop(ctx)
+		}()
+	}
+	if cfg.MaxRSSBytes > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.MemoryPressureLoop(ctx)
+		}()
+	}
+	if cfg.GotenbergWarmupIdle > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.WarmupLoop(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pool.QueueMirrorLoop(ctx)
+	}()
+
+	// Delete trashed outputs past their retention
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pool.TrashPurgeLoop(ctx)
+	}()
+
+	// Add finished conversions to the hourly rollups
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pool.RollupLoop(ctx)
+	}()
+
+	if cfg.MetricsAddr != "" {
+		go func() {
+			slog.Info("Serving metrics", "addr", cfg.MetricsAddr, "path", "/metrics")
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler())
+			if err := http.ListenAndServe(cfg.MetricsAddr, mux); err != nil {
+				slog.Error("Metrics server stopped", "error", err)
+			}
+		}()
+	}
+
+	// Start HTTP API
+	if cfg.HTTPAddr != "" {
+		server := api.NewServer(cfg,
+			services.NewPerformanceStats(redisClient, cfg.RedisPrefix),
+			services.NewQueueAdmin(redisClient, cfg, dbUpdater),
+			dbSvc)
+
+		// A broken Redis or Postgres connection may be fixed by a restart;
+		// Gotenberg and storage outages only stop routing work to this pod
+		server.AddLivenessCheck("redis", func(ctx context.Context) error { return redisClient.Ping(ctx).Err() })
+		server.AddLivenessCheck("postgres", dbSvc.Ping)
+		gotenbergSvc := services.NewGotenbergService(cfg.GotenbergURL, cfg.GotenbergMaxResponseBytes, services.NewRequestIdentity(cfg))
+		server.AddReadinessCheck("gotenberg", gotenbergSvc.Health)
+		server.AddReadinessCheck(cfg.StorageDriver, storage.Ping)
+		if cfg.ClamAVAddr != "" {
+			server.AddReadinessCheck("clamav", services.NewClamAV(cfg.ClamAVAddr).Ping)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server.Run(ctx)
+		}()
+	}
+
+	slog.Info("Service is ready to process conversions",
+		"workers", cfg.WorkerCount,
+		"retry_lane_workers", cfg.RetryLaneWorkers,
+		"claim_strategy", cfg.ClaimStrategy,
+		"queue_driver", cfg.QueueDriver,
+		"queue_backend", cfg.QueueBackend,
+		"queues", []string{cfg.RetryLaneQueue, cfg.HighPriorityQueue, cfg.PendingQueue, cfg.LowPriorityQueue},
+		"job_kinds", cfg.JobKinds,
+		"gotenberg_url", cfg.GotenbergURL,
+	)
 
 	// Wait for shutdown signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	<-sigChan
 
-	log.Println("Shutdown signal received, stopping workers...")
+	slog.Info("Shutdown signal received, stopping workers", "grace", cfg.ShutdownGrace)
 	cancel()
 
 	// Wait for workers to finish with timeout
 	done := make(chan struct{})
 	go func() {
 		wg.Wait()
+		<-delayedDone
 		close(done)
 	}()
 
+	graceful := true
 	select {
 	case <-done:
-		log.Println("All workers stopped gracefully")
-	case <-time.After(30 * time.Second):
-		log.Println("Shutdown timeout, forcing exit")
+		slog.Info("All workers stopped gracefully")
+		flushRollups(pool)
+		pool.CloseAudit()
+		dbUpdater.Close()
+		slog.Info("Pending DB status updates flushed")
+	case <-time.After(time.Duration(cfg.ShutdownGrace)*time.Second + 10*time.Second):
+		slog.Warn("Shutdown timeout, forcing exit")
+		graceful = false
 	}
+	publishShutdownReport(pool, started, graceful)
 
 	redisClient.Close()
-	log.Println("Conversion service stopped")
+	slog.Info("Conversion service stopped")
+}
+
+// runUntilDrained waits for run-once workers to empty the queue (or for a
+// shutdown signal) and logs a summary for the batch.
+func runUntilDrained(wg *sync.WaitGroup, pool *worker.Pool, dbUpdater *services.StatusUpdater, redisClient *redis.Client, cancel context.CancelFunc, delayedDone <-chan struct{}) {
+	started := time.Now()
+	slog.Info("Running in run-once mode")
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		slog.Info("Shutdown signal received, stopping run-once batch")
+		cancel()
+	}()
+
+	wg.Wait()
+	cancel()
+	<-delayedDone
+	flushRollups(pool)
+	pool.CloseAudit()
+	dbUpdater.Close()
+	redisClient.Close()
+
+	stats := pool.Stats()
+	slog.Info("Run-once summary",
+		"completed", stats.Completed,
+		"failed", stats.Failed,
+		"retried", stats.Retried,
+		"elapsed", time.Since(started).Round(time.Second).String(),
+	)
+}
+
+// publishShutdownReport logs the summary of the run, and stores it for
+// deploy tooling when SHUTDOWN_REPORT_KEY is set.
+func publishShutdownReport(pool *worker.Pool, started time.Time, graceful bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := pool.PublishShutdownReport(ctx, pool.ShutdownReport(ctx, started, graceful)); err != nil {
+		slog.Error("Failed to publish shutdown report", "error", err)
+	}
+}
+
+// flushRollups writes the rollup counts of the last jobs once the workers
+// stopped.
+func flushRollups(pool *worker.Pool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pool.FlushRollups(ctx)
+}
+
+// fatal logs at error level and exits, like log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
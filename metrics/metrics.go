@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A minimal in-process registry rendered in the Prometheus text format, so
+// the service can be scraped without pulling in the full client library.
+
+type metric struct {
+	kind  string
+	help  string
+	value atomic.Int64
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]*metric{}
+	help     = map[string]string{}
+)
+
+// Describe registers help text for a metric family.
+func Describe(name string, text string) {
+	mu.Lock()
+	defer mu.Unlock()
+	help[name] = text
+}
+
+// Inc adds one to the counter name{labels}. Labels are key/value pairs.
+func Inc(name string, labels ...string) {
+	Add(name, 1, labels...)
+}
+
+func Add(name string, delta int64, labels ...string) {
+	get("counter", name, labels).value.Add(delta)
+}
+
+// Set records the current value of the gauge name{labels}.
+func Set(name string, value int64, labels ...string) {
+	get("gauge", name, labels).value.Store(value)
+}
+
+// Value returns the current value of name{labels}, or 0 if never recorded.
+func Value(name string, labels ...string) int64 {
+	mu.RLock()
+	defer mu.RUnlock()
+	if m, ok := registry[key(name, labels)]; ok {
+		return m.value.Load()
+	}
+	return 0
+}
+
+func get(kind string, name string, labels []string) *metric {
+	k := key(name, labels)
+
+	mu.RLock()
+	m, ok := registry[k]
+	mu.RUnlock()
+	if ok {
+		return m
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if m, ok := registry[k]; ok {
+		return m
+	}
+	m = &metric{kind: kind}
+	registry[k] = m
+	return m
+}
+
+func key(name string, labels []string) string {
+	if len(labels) < 2 {
+		return name
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// WriteText renders every metric in the Prometheus exposition format.
+func WriteText(w io.Writer) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	keys := make([]string, 0, len(registry))
+	for k := range registry {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	described := map[string]bool{}
+	for _, k := range keys {
+		name, _, _ := strings.Cut(k, "{")
+		if !described[name] {
+			described[name] = true
+			if text, ok := help[name]; ok {
+				fmt.Fprintf(w, "# HELP %s %s\n", name, text)
+			}
+			fmt.Fprintf(w, "# TYPE %s %s\n", name, registry[k].kind)
+		}
+		fmt.Fprintf(w, "%s %d\n", k, registry[k].value.Load())
+	}
+}
+
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteText(w)
+	})
+}
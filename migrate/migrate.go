@@ -0,0 +1,192 @@
+// Package migrate moves queued jobs between Redis key layouts (a new
+// REDIS_PREFIX) or from lists to streams, without dropping entries.
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"converter/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend is the destination structure for list queues.
+type Backend string
+
+const (
+	BackendList   Backend = "list"
+	BackendStream Backend = "stream"
+)
+
+// StreamField is the stream entry field holding the job payload.
+const StreamField = "job"
+
+// Queue is one source queue and where its entries go.
+type Queue struct {
+	Name   string
+	Source string
+	Dest   string
+	// Sorted marks the delayed retry set; its scores are preserved and it is
+	// always migrated set to set.
+	Sorted bool
+	// ListOnly keeps a list queue a list when converting to streams; the
+	// failed queue is a list on either worker backend.
+	ListOnly bool
+}
+
+// InvalidKey is where entries that don't parse as a job are parked, so
+// nothing is dropped and they can be inspected after the run.
+func (q Queue) InvalidKey() string {
+	return q.Source + ":migrate-invalid"
+}
+
+// Result counts what happened to one queue.
+type Result struct {
+	Queue   string
+	Moved   int64
+	Invalid int64
+}
+
+// Migrator moves entries in batches. Each batch is a single Lua script, so
+// every entry is in exactly one place at any time; an interrupted run is
+// resumed by running it again.
+type Migrator struct {
+	client    *redis.Client
+	backend   Backend
+	batchSize int
+}
+
+func NewMigrator(client *redis.Client, backend Backend, batchSize int) (*Migrator, error) {
+	if backend != BackendList && backend != BackendStream {
+		return nil, fmt.Errorf("unknown backend %q", backend)
+	}
+	if batchSize < 1 {
+		batchSize = 100
+	}
+	return &Migrator{client: client, backend: backend, batchSize: batchSize}, nil
+}
+
+// validEntry mirrors Valid in Lua: the payload must decode to an object
+// with a numeric conversionId.
+var validEntry = `
+local function valid(v)
+	local ok, job = pcall(cjson.decode, v)
+	return ok and type(job) == 'table' and type(job.conversionId) == 'number'
+end
+`
+
+// moveListScript pops up to ARGV[2] entries from the tail of KEYS[1] (oldest
+// first) and pushes them onto KEYS[2] as a list or stream, so FIFO order is
+// kept. Returns {moved, invalid}.
+var moveListScript = redis.NewScript(validEntry + `
+local moved, invalid = 0, 0
+for i = 1, tonumber(ARGV[2]) do
+	local v = redis.call('RPOP', KEYS[1])
+	if not v then
+		break
+	end
+	if not valid(v) then
+		redis.call('LPUSH', KEYS[3], v)
+		invalid = invalid + 1
+	elseif ARGV[1] == 'stream' then
+		redis.call('XADD', KEYS[2], '*', ARGV[3], v)
+		moved = moved + 1
+	else
+		redis.call('LPUSH', KEYS[2], v)
+		moved = moved + 1
+	end
+end
+return {moved, invalid}
+`)
+
+// moveSortedScript moves up to ARGV[1] members of the delayed set, keeping
+// each member's retry time. Returns {moved, invalid}.
+var moveSortedScript = redis.NewScript(validEntry + `
+local moved, invalid = 0, 0
+local entries = redis.call('ZRANGE', KEYS[1], 0, tonumber(ARGV[1]) - 1, 'WITHSCORES')
+for i = 1, #entries, 2 do
+	local v, score = entries[i], entries[i + 1]
+	redis.call('ZREM', KEYS[1], v)
+	if valid(v) then
+		redis.call('ZADD', KEYS[2], score, v)
+		moved = moved + 1
+	else
+		redis.call('LPUSH', KEYS[3], v)
+		invalid = invalid + 1
+	end
+end
+return {moved, invalid}
+`)
+
+// Move drains q.Source into q.Dest.
+func (m *Migrator) Move(ctx context.Context, q Queue) (Result, error) {
+	result := Result{Queue: q.Name}
+	if q.Source == q.Dest {
+		return result, fmt.Errorf("queue %s: source and destination are both %s", q.Name, q.Source)
+	}
+
+	for {
+		var counts []int64
+		var err error
+		keys := []string{q.Source, q.Dest, q.InvalidKey()}
+		if q.Sorted {
+			counts, err = moveSortedScript.Run(ctx, m.client, keys, m.batchSize).Int64Slice()
+		} else {
+			backend := m.backend
+			if q.ListOnly {
+				backend = BackendList
+			}
+			counts, err = moveListScript.Run(ctx, m.client, keys, string(backend), m.batchSize, StreamField).Int64Slice()
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to migrate %s: %w", q.Name, err)
+		}
+
+		result.Moved += counts[0]
+		result.Invalid += counts[1]
+		if counts[0]+counts[1] == 0 {
+			return result, nil
+		}
+		slog.Info("Migration progress", "queue", q.Name, "moved", result.Moved, "invalid", result.Invalid)
+	}
+}
+
+// Check reports what Move would do without changing anything.
+func (m *Migrator) Check(ctx context.Context, q Queue) (Result, error) {
+	result := Result{Queue: q.Name}
+
+	var entries []string
+	var err error
+	if q.Sorted {
+		entries, err = m.client.ZRange(ctx, q.Source, 0, -1).Result()
+	} else {
+		entries, err = m.client.LRange(ctx, q.Source, 0, -1).Result()
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to read %s: %w", q.Source, err)
+	}
+
+	for _, e := range entries {
+		if Valid(e) {
+			result.Moved++
+		} else {
+			result.Invalid++
+		}
+	}
+	return result, nil
+}
+
+// Valid reports whether a queue entry is a job payload worth migrating.
+func Valid(entry string) bool {
+	var job struct {
+		ConversionID *json.Number `json:"conversionId"`
+	}
+	if err := json.Unmarshal([]byte(entry), &job); err != nil || job.ConversionID == nil {
+		return false
+	}
+	var full models.ConversionJob
+	return json.Unmarshal([]byte(entry), &full) == nil
+}
@@ -0,0 +1,20 @@
+package migrate
+
+import "testing"
+
+func TestValid(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]bool{
+		`{"conversionId":42,"fileGuid":"abc"}`: true,
+		`{"conversionId":"42"}`:                false,
+		`{"fileGuid":"abc"}`:                   false,
+		`not json`:                             false,
+		`[]`:                                   false,
+	}
+	for entry, want := range cases {
+		if got := Valid(entry); got != want {
+			t.Errorf("Valid(%s) = %v, want %v", entry, got, want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"converter/config"
+	"converter/migrate"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// runMigrateQueue implements `converter migrate-queue`, moving pending,
+// failed and delayed jobs from one key prefix to another and optionally from
+// lists to streams. Workers on the old layout should be stopped first; the
+// processing queue is never touched.
+func runMigrateQueue(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("migrate-queue", flag.ExitOnError)
+	fromPrefix := fs.String("from-prefix", "", "key prefix the jobs are currently under (defaults to REDIS_PREFIX)")
+	toPrefix := fs.String("to-prefix", "", "key prefix to move the jobs to (defaults to REDIS_PREFIX)")
+	backend := fs.String("to-backend", string(migrate.BackendList), "destination structure for the retry, high, pending and low queues: list or stream")
+	queues := fs.String("queues", "retry,high,pending,low,office,html,email,image,merge,failed,delayed", "comma-separated queues to migrate")
+	batch := fs.Int("batch", 100, "entries moved per atomic batch")
+	dryRun := fs.Bool("dry-run", false, "validate and count entries without moving them")
+	fs.Parse(args)
+
+	if !isFlagSet(fs, "from-prefix") {
+		*fromPrefix = cfg.RedisPrefix
+	}
+	if !isFlagSet(fs, "to-prefix") {
+		*toPrefix = cfg.RedisPrefix
+	}
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	migrator, err := migrate.NewMigrator(redisClient, migrate.Backend(*backend), *batch)
+	if err != nil {
+		return err
+	}
+
+	plan, err := migrationPlan(cfg, strings.Split(*queues, ","), *fromPrefix, *toPrefix, migrate.Backend(*backend))
+	if err != nil {
+		return err
+	}
+
+	for _, q := range plan {
+		if q.Source == q.Dest {
+			slog.Info("Queue already migrated, skipping", "queue", q.Name, "dest", q.Dest)
+			continue
+		}
+
+		var result migrate.Result
+		if *dryRun {
+			result, err = migrator.Check(ctx, q)
+		} else {
+			result, err = migrator.Move(ctx, q)
+		}
+		if err != nil {
+			return err
+		}
+
+		if *dryRun {
+			slog.Info("Dry run", "queue", q.Name, "source", q.Source, "dest", q.Dest, "would_move", result.Moved, "invalid", result.Invalid)
+		} else {
+			slog.Info("Queue migrated", "queue", q.Name, "source", q.Source, "dest", q.Dest, "moved", result.Moved)
+		}
+		if result.Invalid > 0 && !*dryRun {
+			slog.Warn("Invalid entries parked", "queue", q.Name, "invalid", result.Invalid, "key", q.InvalidKey())
+		}
+	}
+	return nil
+}
+
+// migrationPlan maps queue names to source and destination keys by swapping
+// the prefix on the configured queue keys. Stream destinations get a
+// ":stream" suffix so a list can be converted under the same prefix.
+func migrationPlan(cfg *config.Config, names []string, fromPrefix string, toPrefix string, backend migrate.Backend) ([]migrate.Queue, error) {
+	keys := map[string]string{
+		"retry":   cfg.RetryLaneQueue,
+		"high":    cfg.HighPriorityQueue,
+		"pending": cfg.PendingQueue,
+		"low":     cfg.LowPriorityQueue,
+		"failed":  cfg.FailedQueue,
+		"delayed": cfg.DelayedQueue,
+	}
+	for _, kind := range services.JobKinds() {
+		keys[kind] = cfg.KindQueueFor(kind, cfg.Region)
+	}
+
+	plan := make([]migrate.Queue, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key, ok := keys[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown queue %q (processing cannot be migrated)", name)
+		}
+
+		base := strings.TrimPrefix(key, cfg.RedisPrefix)
+		q := migrate.Queue{
+			Name:     name,
+			Source:   fromPrefix + base,
+			Dest:     toPrefix + base,
+			Sorted:   name == "delayed",
+			ListOnly: name == "failed",
+		}
+		if backend == migrate.BackendStream && !q.Sorted && !q.ListOnly {
+			q.Dest += ":stream"
+		}
+		plan = append(plan, q)
+	}
+	return plan, nil
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
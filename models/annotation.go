@@ -0,0 +1,22 @@
+package models
+
+// AnnotationCode classifies a non-fatal difference between the source
+// document and its archived PDF.
+type AnnotationCode string
+
+const (
+	AnnotationFontSubstituted    AnnotationCode = "font_substituted"
+	AnnotationMacrosDropped      AnnotationCode = "macros_dropped"
+	AnnotationCommentsOmitted    AnnotationCode = "comments_omitted"
+	AnnotationMediaDropped       AnnotationCode = "media_dropped"
+	AnnotationHiddenSheets       AnnotationCode = "hidden_sheets_omitted"
+	AnnotationPrintArea          AnnotationCode = "print_area_only"
+	AnnotationAttachmentsOmitted AnnotationCode = "attachments_omitted"
+)
+
+// Annotation tells the user their archived copy may differ from the
+// original, and how.
+type Annotation struct {
+	Code    AnnotationCode `json:"code"`
+	Message string         `json:"message"`
+}
@@ -1,17 +1,161 @@
 package models
 
-import "time"
+import (
+	"path"
+	"time"
+)
 
 type ConversionJob struct {
-	ConversionID    int       `json:"conversionId"`
-	FileID          int       `json:"fileId"`
-	FileGUID        string    `json:"fileGuid"`
-	UserID          int       `json:"userId"`
-	InputS3Path     string    `json:"inputS3Path"`
-	OutputS3Path    string    `json:"outputS3Path"`
-	InputExtension  string    `json:"inputExtension"`
-	RetryCount      int       `json:"retryCount"`
-	MaxRetries      int       `json:"maxRetries"`
-	CreatedAt       time.Time `json:"createdAt"`
-	Timeout         int       `json:"timeout"`
+	ConversionID      int              `json:"conversionId"`
+	FileID            int              `json:"fileId"`
+	FileGUID          string           `json:"fileGuid"`
+	UserID            int              `json:"userId"`
+	Type              JobType          `json:"type,omitempty"`
+	InputS3Path       string           `json:"inputS3Path"`
+	InputS3Paths      []string         `json:"inputS3Paths,omitempty"`
+	OutputS3Path      string           `json:"outputS3Path"`
+	InputExtension    string           `json:"inputExtension"`
+	Assets            []HTMLAsset      `json:"assets,omitempty"`
+	RetryCount        int              `json:"retryCount"`
+	MaxRetries        int              `json:"maxRetries"`
+	CreatedAt         time.Time        `json:"createdAt"`
+	Timeout           int              `json:"timeout"`
+	Outputs           []OutputArtifact `json:"outputs,omitempty"`
+	Bundle            bool             `json:"bundle,omitempty"`
+	Region            string           `json:"region,omitempty"`
+	EncryptionKeyID   string           `json:"encryptionKeyId,omitempty"`
+	Accessible        bool             `json:"accessible,omitempty"`
+	PDFAConformance   string           `json:"pdfaConformance,omitempty"`
+	Flatten           bool             `json:"flatten,omitempty"`
+	Split             *SplitOptions    `json:"split,omitempty"`
+	AppendAttachments bool             `json:"appendAttachments,omitempty"`
+	Thumbnails        bool             `json:"thumbnails,omitempty"`
+	Priority          Priority         `json:"priority,omitempty"`
+	UserInitiated     bool             `json:"userInitiated,omitempty"`
+	CostDeferred      bool             `json:"costDeferred,omitempty"`
+	CallbackURL       string           `json:"callbackUrl,omitempty"`
+	TraceID           string           `json:"traceId,omitempty"`
+	// Deadline is when the result stops being useful; a job still running
+	// then is expired. The conversion:control:<id> hash can move it.
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// RetentionClass names an entry of CONVERSION_RETENTION_CLASSES whose
+	// S3 Object Lock settings the outputs are written with.
+	RetentionClass string `json:"retentionClass,omitempty"`
+	// Header and Footer are HTML printed at the top and bottom of every
+	// page of an HTML or Markdown conversion.
+	Header string `json:"header,omitempty"`
+	Footer string `json:"footer,omitempty"`
+	// Password opens a password-protected office document.
+	Password string `json:"password,omitempty"`
+	// PasswordRef names the secret holding the password instead, at
+	// conversion:secret:<ref>, so the password isn't in the payload. It
+	// is resolved when the job is converted.
+	PasswordRef string `json:"passwordRef,omitempty"`
+	// Recoveries counts the times recovery requeued the job after its
+	// worker disappeared. Requeues from the failed queue keep it.
+	Recoveries int `json:"recoveries,omitempty"`
+	// RateReservation names the slot a job requeued by its tenant's rate
+	// limit already holds. It counts only while the limiter still has it,
+	// so a producer can't skip the limit by setting it.
+	RateReservation string `json:"rateReservation,omitempty"`
+	// IdempotencyKey identifies copies of one job within its tenant, so
+	// a copy enqueued after the job completed is skipped. Jobs without it
+	// are always converted.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	// Labels are the producer's name/value tags, such as the channel the
+	// document came in on. They are logged with the job and can break
+	// down its metrics and set its priority.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// JobType selects what a job does with its inputs. An empty type converts
+// the single input.
+type JobType string
+
+const (
+	JobTypeConvert JobType = "convert"
+	JobTypeMerge   JobType = "merge"
+	// JobTypeTrash moves a completed conversion's outputs to the trash
+	// prefix, and JobTypeRestore moves them back.
+	JobTypeTrash   JobType = "trash"
+	JobTypeRestore JobType = "restore"
+)
+
+// IsMerge reports whether the job merges InputS3Paths, in order, into one
+// PDF/A instead of converting InputS3Path.
+func (j *ConversionJob) IsMerge() bool {
+	return j.Type == JobTypeMerge
+}
+
+// MovesOutputs reports whether the job trashes or restores the outputs of
+// an earlier conversion instead of converting anything.
+func (j *ConversionJob) MovesOutputs() bool {
+	return j.Type == JobTypeTrash || j.Type == JobTypeRestore
+}
+
+// HTMLAsset is a file an HTML input refers to, such as an image or a
+// stylesheet. Chromium resolves references by bare file name, so the page
+// must refer to the asset by FileName.
+type HTMLAsset struct {
+	S3Path string `json:"s3Path"`
+	Name   string `json:"name,omitempty"`
+}
+
+// FileName is the name the asset is uploaded under: Name, or the base name
+// of its key when Name is empty.
+func (a HTMLAsset) FileName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return path.Base(a.S3Path)
+}
+
+type ArtifactKind string
+
+const (
+	ArtifactPDFA      ArtifactKind = "pdfa"
+	ArtifactPDF       ArtifactKind = "pdf"
+	ArtifactText      ArtifactKind = "text"
+	ArtifactThumbnail ArtifactKind = "thumbnail"
+	// ArtifactPreview is a watermarked, low-resolution copy of the PDF for
+	// sharing without exposing the archival output.
+	ArtifactPreview ArtifactKind = "preview"
+)
+
+// Valid reports whether the kind is one the worker can produce.
+func (k ArtifactKind) Valid() bool {
+	switch k {
+	case ArtifactPDFA, ArtifactPDF, ArtifactText, ArtifactThumbnail, ArtifactPreview:
+		return true
+	}
+	return false
+}
+
+// OutputArtifact is an extra artifact produced from the same conversion and
+// uploaded to its own destination key.
+type OutputArtifact struct {
+	Kind   ArtifactKind `json:"kind"`
+	S3Path string       `json:"s3Path"`
+	Width  int          `json:"width,omitempty"`
+	// Watermark and DPI override PREVIEW_WATERMARK and PREVIEW_DPI for a
+	// preview.
+	Watermark string `json:"watermark,omitempty"`
+	DPI       int    `json:"dpi,omitempty"`
+}
+
+// Split modes understood by Gotenberg's split route.
+const (
+	SplitIntervals = "intervals"
+	SplitPages     = "pages"
+)
+
+// SplitOptions cuts the converted PDF into parts uploaded alongside the
+// primary output. With "intervals", Span is the number of pages per part
+// ("10"); with "pages" it is a page range list ("1-3,5"), and Unify puts
+// the selected pages in a single part instead of one part per page.
+type SplitOptions struct {
+	Mode     string `json:"mode"`
+	Span     string `json:"span"`
+	Unify    bool   `json:"unify,omitempty"`
+	S3Prefix string `json:"s3Prefix,omitempty"`
 }
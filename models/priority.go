@@ -0,0 +1,22 @@
+package models
+
+type Priority string
+
+const (
+	PriorityHigh   Priority = "high"
+	PriorityNormal Priority = "normal"
+	PriorityLow    Priority = "low"
+)
+
+// Priorities lists every priority from most to least urgent.
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// Normalize maps an empty or unknown priority to normal.
+func (p Priority) Normalize() Priority {
+	switch p {
+	case PriorityHigh, PriorityLow:
+		return p
+	default:
+		return PriorityNormal
+	}
+}
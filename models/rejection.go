@@ -0,0 +1,11 @@
+package models
+
+// RejectionReason classifies why a job was refused before any processing.
+type RejectionReason string
+
+const (
+	RejectMalformed         RejectionReason = "malformed"
+	RejectUnsupportedFormat RejectionReason = "unsupported_format"
+	RejectTooLarge          RejectionReason = "too_large"
+	RejectQuota             RejectionReason = "quota_exceeded"
+)
@@ -0,0 +1,75 @@
+package models
+
+import "fmt"
+
+type ConversionStatus string
+
+const (
+	StatusPending    ConversionStatus = "pending"
+	StatusProcessing ConversionStatus = "processing"
+	StatusCompleted  ConversionStatus = "completed"
+	StatusFailed     ConversionStatus = "failed"
+	StatusCancelled  ConversionStatus = "cancelled"
+	StatusExpired    ConversionStatus = "expired"
+	// StatusRejectedInfected is a job whose input the virus scan flagged.
+	StatusRejectedInfected ConversionStatus = "rejected_infected"
+	// StatusPasswordRequired is a job whose input is password protected and
+	// came without the right password.
+	StatusPasswordRequired ConversionStatus = "password_required"
+)
+
+// transitions lists the legal next states for every state. Processing may
+// re-enter itself because a retried job is claimed again. Pending may fail
+// directly, for a job rejected when it is claimed, before it is processed.
+var transitions = map[ConversionStatus][]ConversionStatus{
+	StatusPending:    {StatusProcessing, StatusFailed, StatusCancelled, StatusExpired},
+	StatusProcessing: {StatusProcessing, StatusPending, StatusCompleted, StatusFailed, StatusCancelled, StatusExpired, StatusRejectedInfected, StatusPasswordRequired},
+	StatusFailed:     {StatusPending},
+	StatusCompleted:  {},
+	StatusCancelled:  {},
+	StatusExpired:    {},
+	// Infected inputs are never converted, so they can't be requeued
+	StatusRejectedInfected: {},
+	// The producer enqueues a new job with the password instead
+	StatusPasswordRequired: {},
+}
+
+// ErrIllegalTransition is returned when a status change is not allowed by
+// the conversion state machine.
+type ErrIllegalTransition struct {
+	ConversionID int
+	To           ConversionStatus
+}
+
+func (e *ErrIllegalTransition) Error() string {
+	return fmt.Sprintf("illegal status transition to %q for conversion %d", e.To, e.ConversionID)
+}
+
+func (s ConversionStatus) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+func (s ConversionStatus) Terminal() bool {
+	return len(transitions[s]) == 0
+}
+
+func (s ConversionStatus) CanTransitionTo(next ConversionStatus) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Predecessors returns every state from which next may be entered.
+func Predecessors(next ConversionStatus) []ConversionStatus {
+	var from []ConversionStatus
+	for _, s := range []ConversionStatus{StatusPending, StatusProcessing, StatusCompleted, StatusFailed, StatusCancelled, StatusExpired, StatusRejectedInfected, StatusPasswordRequired} {
+		if s.CanTransitionTo(next) {
+			from = append(from, s)
+		}
+	}
+	return from
+}
@@ -0,0 +1,34 @@
+package models
+
+import "testing"
+
+func TestConversionStatus_Transitions(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		from, to ConversionStatus
+		allowed  bool
+	}{
+		{StatusPending, StatusProcessing, true},
+		{StatusProcessing, StatusProcessing, true},
+		{StatusProcessing, StatusCompleted, true},
+		{StatusFailed, StatusPending, true},
+		{StatusCompleted, StatusProcessing, false},
+		{StatusPending, StatusCompleted, false},
+		{StatusPending, StatusFailed, true},
+		{StatusCancelled, StatusPending, false},
+		{StatusProcessing, StatusRejectedInfected, true},
+		{StatusRejectedInfected, StatusPending, false},
+		{StatusProcessing, StatusPasswordRequired, true},
+	}
+
+	for _, c := range cases {
+		if got := c.from.CanTransitionTo(c.to); got != c.allowed {
+			t.Errorf("%s -> %s: expected %v, got %v", c.from, c.to, c.allowed, got)
+		}
+	}
+
+	if !StatusExpired.Terminal() || StatusProcessing.Terminal() {
+		t.Fatal("unexpected terminal classification")
+	}
+}
@@ -0,0 +1,305 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaNotBeforeHeader holds the Unix millisecond time before which a
+// delayed job must not be handed out.
+const kafkaNotBeforeHeader = "not-before"
+
+// partitionReader reads one partition of the topic, from an offset the
+// consumer sets itself.
+type partitionReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	SetOffset(offset int64) error
+}
+
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// kafkaDelivery is a message handed to a worker. Its partition waits on
+// settle for the worker's verdict before reading on.
+type kafkaDelivery struct {
+	msg    kafka.Message
+	done   <-chan struct{}
+	settle chan bool
+	result chan error
+}
+
+// Kafka is a Source backed by a Kafka topic read as a consumer group. Each
+// assigned partition hands out one message at a time and reads on only once
+// that message has been acked, so its jobs are converted in order and every
+// ack commits the offset right after it. Jobs that failed for good are
+// produced to the dead-letter topic.
+type Kafka struct {
+	brokers  []string
+	topic    string
+	dlqTopic string
+	group    *kafka.ConsumerGroup
+	writer   messageWriter
+	closer   func() error
+
+	deliveries chan *kafkaDelivery
+
+	mu       sync.Mutex
+	inFlight map[string]*kafkaDelivery
+}
+
+// NewKafka joins the consumer group groupID on topic. Partitions are only
+// read once Run is started.
+func NewKafka(brokers []string, topic string, groupID string, dlqTopic string) (*Kafka, error) {
+	group, err := kafka.NewConsumerGroup(kafka.ConsumerGroupConfig{
+		ID:                    groupID,
+		Brokers:               brokers,
+		Topics:                []string{topic},
+		StartOffset:           kafka.FirstOffset,
+		WatchPartitionChanges: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to join Kafka consumer group: %w", err)
+	}
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Balancer:     &kafka.LeastBytes{},
+		RequiredAcks: kafka.RequireAll,
+	}
+	k := newKafka(topic, dlqTopic, writer)
+	k.brokers = brokers
+	k.group = group
+	k.closer = func() error {
+		err := group.Close()
+		if werr := writer.Close(); err == nil {
+			err = werr
+		}
+		return err
+	}
+	return k, nil
+}
+
+func newKafka(topic string, dlqTopic string, writer messageWriter) *Kafka {
+	return &Kafka{
+		topic:      topic,
+		dlqTopic:   dlqTopic,
+		writer:     writer,
+		deliveries: make(chan *kafkaDelivery),
+		inFlight:   make(map[string]*kafkaDelivery),
+	}
+}
+
+// Run follows the group's generations until ctx is done or the source is
+// closed, reading every partition assigned to this instance.
+func (k *Kafka) Run(ctx context.Context) {
+	for {
+		gen, err := k.group.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, kafka.ErrGroupClosed) {
+				return
+			}
+			slog.Error("Failed to join Kafka consumer group generation", "component", "kafka", "error", err)
+			if !sleepContext(ctx, time.Second) {
+				return
+			}
+			continue
+		}
+
+		assignments := gen.Assignments[k.topic]
+		slog.Info("Kafka partitions assigned", "component", "kafka", "generation", gen.ID, "partitions", len(assignments))
+		for _, assignment := range assignments {
+			partition, offset := assignment.ID, assignment.Offset
+			gen.Start(func(ctx context.Context) {
+				reader := kafka.NewReader(kafka.ReaderConfig{
+					Brokers:   k.brokers,
+					Topic:     k.topic,
+					Partition: partition,
+					MaxWait:   time.Second,
+				})
+				defer reader.Close()
+
+				if err := reader.SetOffset(offset); err != nil {
+					slog.Error("Failed to seek Kafka partition", "component", "kafka", "partition", partition, "error", err)
+					return
+				}
+				k.servePartition(ctx, reader, partition, func(next int64) error {
+					return gen.CommitOffsets(map[string]map[int]int64{k.topic: {partition: next}})
+				})
+			})
+		}
+	}
+}
+
+// servePartition hands the partition's messages to workers one at a time
+// until ctx is done. An ack commits the offset after the message; a release
+// seeks back so the message is read again.
+func (k *Kafka) servePartition(ctx context.Context, reader partitionReader, partition int, commit func(next int64) error) {
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("Failed to fetch from Kafka", "component", "kafka", "partition", partition, "error", err)
+			if !sleepContext(ctx, time.Second) {
+				return
+			}
+			continue
+		}
+		if !sleepContext(ctx, time.Until(notBefore(msg))) {
+			return
+		}
+
+		delivery := &kafkaDelivery{msg: msg, done: ctx.Done(), settle: make(chan bool), result: make(chan error, 1)}
+		select {
+		case k.deliveries <- delivery:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case ack := <-delivery.settle:
+			if ack {
+				delivery.result <- commit(msg.Offset + 1)
+			} else {
+				delivery.result <- reader.SetOffset(msg.Offset)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (k *Kafka) Receive(ctx context.Context, wait time.Duration) (*Message, error) {
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	} else {
+		expired := make(chan time.Time)
+		close(expired)
+		timeout = expired
+	}
+
+	select {
+	case delivery := <-k.deliveries:
+		handle := strconv.Itoa(delivery.msg.Partition) + ":" + strconv.FormatInt(delivery.msg.Offset, 10)
+		k.mu.Lock()
+		k.inFlight[handle] = delivery
+		k.mu.Unlock()
+		return &Message{Body: string(delivery.msg.Value), Handle: handle}, nil
+	case <-ctx.Done():
+		return nil, nil
+	case <-timeout:
+		return nil, nil
+	}
+}
+
+// Ack commits the offset after the delivered message.
+func (k *Kafka) Ack(ctx context.Context, handle string) error {
+	if err := k.settle(ctx, handle, true); err != nil {
+		return fmt.Errorf("failed to commit Kafka offset: %w", err)
+	}
+	return nil
+}
+
+// Release seeks the message's partition back so it is delivered again.
+func (k *Kafka) Release(ctx context.Context, handle string) error {
+	if err := k.settle(ctx, handle, false); err != nil {
+		return fmt.Errorf("failed to release Kafka message: %w", err)
+	}
+	return nil
+}
+
+func (k *Kafka) settle(ctx context.Context, handle string, ack bool) error {
+	k.mu.Lock()
+	delivery, ok := k.inFlight[handle]
+	delete(k.inFlight, handle)
+	k.mu.Unlock()
+	if !ok {
+		return ErrStaleDelivery
+	}
+
+	select {
+	case delivery.settle <- ack:
+	case <-delivery.done:
+		return ErrStaleDelivery
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return <-delivery.result
+}
+
+// Extend does nothing: a partition keeps its message until it is acked,
+// as long as this instance stays in the group.
+func (k *Kafka) Extend(ctx context.Context, handle string, visibility time.Duration) error {
+	return nil
+}
+
+// Send produces a job to the topic. Kafka has no delayed delivery, so a
+// delayed job carries the time it is due, and the partition that reads it
+// holds it back until then.
+func (k *Kafka) Send(ctx context.Context, body string, delay time.Duration) error {
+	msg := kafka.Message{Topic: k.topic, Value: []byte(body)}
+	if delay > 0 {
+		due := time.Now().Add(delay).UnixMilli()
+		msg.Headers = []kafka.Header{{Key: kafkaNotBeforeHeader, Value: []byte(strconv.FormatInt(due, 10))}}
+	}
+	if err := k.writer.WriteMessages(ctx, msg); err != nil {
+		return fmt.Errorf("failed to produce to Kafka: %w", err)
+	}
+	return nil
+}
+
+// DeadLetter produces a job that failed for good to the dead-letter topic.
+func (k *Kafka) DeadLetter(ctx context.Context, body string) error {
+	if err := k.writer.WriteMessages(ctx, kafka.Message{Topic: k.dlqTopic, Value: []byte(body)}); err != nil {
+		return fmt.Errorf("failed to produce to Kafka dead-letter topic: %w", err)
+	}
+	return nil
+}
+
+// Close leaves the consumer group. Uncommitted messages are read again by
+// whichever member takes over their partitions.
+func (k *Kafka) Close() error {
+	if k.closer == nil {
+		return nil
+	}
+	return k.closer()
+}
+
+func notBefore(msg kafka.Message) time.Time {
+	for _, header := range msg.Headers {
+		if header.Key != kafkaNotBeforeHeader {
+			continue
+		}
+		if ms, err := strconv.ParseInt(string(header.Value), 10, 64); err == nil {
+			return time.UnixMilli(ms)
+		}
+	}
+	return time.Time{}
+}
+
+// sleepContext waits for d and reports false if ctx was done first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
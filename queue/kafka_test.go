@@ -0,0 +1,190 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type fakePartition struct {
+	mu       sync.Mutex
+	messages []kafka.Message
+	next     int
+	seeks    []int64
+}
+
+func (f *fakePartition) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	f.mu.Lock()
+	if f.next < len(f.messages) {
+		msg := f.messages[f.next]
+		f.next++
+		f.mu.Unlock()
+		return msg, nil
+	}
+	f.mu.Unlock()
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (f *fakePartition) SetOffset(offset int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seeks = append(f.seeks, offset)
+	for i, msg := range f.messages {
+		if msg.Offset == offset {
+			f.next = i
+		}
+	}
+	return nil
+}
+
+type fakeWriter struct {
+	written []kafka.Message
+}
+
+func (f *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.written = append(f.written, msgs...)
+	return nil
+}
+
+func servePartition(t *testing.T, k *Kafka, reader *fakePartition) (commits chan int64, stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	commits = make(chan int64, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		k.servePartition(ctx, reader, 0, func(next int64) error {
+			commits <- next
+			return nil
+		})
+	}()
+	return commits, func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestKafka_OneMessageInFlightPerPartition(t *testing.T) {
+	t.Parallel()
+
+	k := newKafka("conversions", "conversions.dlq", &fakeWriter{})
+	reader := &fakePartition{messages: []kafka.Message{
+		{Offset: 10, Value: []byte(`{"conversionId":7}`)},
+		{Offset: 11, Value: []byte(`{"conversionId":8}`)},
+	}}
+	commits, stop := servePartition(t, k, reader)
+	defer stop()
+	ctx := context.Background()
+
+	first, err := k.Receive(ctx, time.Second)
+	if err != nil || first == nil || first.Body != `{"conversionId":7}` {
+		t.Fatalf("Receive = %+v, %v", first, err)
+	}
+	// The partition waits for the first message to be acked
+	if msg, _ := k.Receive(ctx, 50*time.Millisecond); msg != nil {
+		t.Fatalf("second message handed out before the first was acked: %+v", msg)
+	}
+
+	if err := k.Ack(ctx, first.Handle); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-commits; got != 11 {
+		t.Errorf("committed offset %d, want 11", got)
+	}
+
+	second, err := k.Receive(ctx, time.Second)
+	if err != nil || second == nil || second.Body != `{"conversionId":8}` {
+		t.Fatalf("Receive = %+v, %v", second, err)
+	}
+	if err := k.Ack(ctx, first.Handle); !errors.Is(err, ErrStaleDelivery) {
+		t.Errorf("second Ack of the same handle = %v, want ErrStaleDelivery", err)
+	}
+}
+
+func TestKafka_ReleaseRedelivers(t *testing.T) {
+	t.Parallel()
+
+	k := newKafka("conversions", "conversions.dlq", &fakeWriter{})
+	reader := &fakePartition{messages: []kafka.Message{
+		{Offset: 10, Value: []byte(`{"conversionId":7}`)},
+		{Offset: 11, Value: []byte(`{"conversionId":8}`)},
+	}}
+	commits, stop := servePartition(t, k, reader)
+	defer stop()
+	ctx := context.Background()
+
+	msg, err := k.Receive(ctx, time.Second)
+	if err != nil || msg == nil {
+		t.Fatalf("Receive = %+v, %v", msg, err)
+	}
+	if err := k.Release(ctx, msg.Handle); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := k.Receive(ctx, time.Second)
+	if err != nil || again == nil || again.Body != `{"conversionId":7}` {
+		t.Fatalf("Receive after release = %+v, %v; want the same job", again, err)
+	}
+	if len(commits) != 0 {
+		t.Errorf("release committed an offset")
+	}
+}
+
+func TestKafka_HoldsDelayedMessages(t *testing.T) {
+	t.Parallel()
+
+	writer := &fakeWriter{}
+	k := newKafka("conversions", "conversions.dlq", writer)
+	if err := k.Send(context.Background(), `{"conversionId":7}`, 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if len(writer.written) != 1 || writer.written[0].Topic != "conversions" {
+		t.Fatalf("written = %+v", writer.written)
+	}
+
+	delayed := writer.written[0]
+	delayed.Offset = 10
+	reader := &fakePartition{messages: []kafka.Message{delayed}}
+	_, stop := servePartition(t, k, reader)
+	defer stop()
+
+	if msg, _ := k.Receive(context.Background(), 50*time.Millisecond); msg != nil {
+		t.Fatalf("delayed message handed out early: %+v", msg)
+	}
+	msg, err := k.Receive(context.Background(), time.Second)
+	if err != nil || msg == nil {
+		t.Fatalf("Receive = %+v, %v; want the job once it is due", msg, err)
+	}
+}
+
+func TestKafka_DeadLetter(t *testing.T) {
+	t.Parallel()
+
+	writer := &fakeWriter{}
+	k := newKafka("conversions", "conversions.dlq", writer)
+	if err := k.DeadLetter(context.Background(), `{"conversionId":7}`); err != nil {
+		t.Fatal(err)
+	}
+	if len(writer.written) != 1 || writer.written[0].Topic != "conversions.dlq" || len(writer.written[0].Headers) != 0 {
+		t.Fatalf("written = %+v", writer.written)
+	}
+}
+
+func TestNotBefore(t *testing.T) {
+	t.Parallel()
+
+	due := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	msg := kafka.Message{Headers: []kafka.Header{{Key: kafkaNotBeforeHeader, Value: []byte(strconv.FormatInt(due.UnixMilli(), 10))}}}
+	if got := notBefore(msg); !got.Equal(due) {
+		t.Errorf("notBefore = %v, want %v", got, due)
+	}
+	if got := notBefore(kafka.Message{}); !got.IsZero() {
+		t.Errorf("notBefore without header = %v, want zero", got)
+	}
+}
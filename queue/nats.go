@@ -0,0 +1,237 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsNotBeforeHeader holds the Unix millisecond time before which a
+// delayed job must not be handed out.
+const natsNotBeforeHeader = "Not-Before"
+
+// natsConsumer is the part of a JetStream pull consumer NATS uses, so tests
+// can stand in for the server.
+type natsConsumer interface {
+	Fetch(batch int, opts ...jetstream.FetchOpt) (jetstream.MessageBatch, error)
+	FetchNoWait(batch int) (jetstream.MessageBatch, error)
+}
+
+type natsPublisher interface {
+	PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
+}
+
+// NATS is a Source backed by a durable JetStream pull consumer. A delivery
+// that isn't acked within the consumer's ack wait is delivered again, so
+// the ack wait is set from the conversion timeout and workers still
+// converting report progress to restart it. Jobs that failed for good are
+// published to the dead-letter subject, which the stream also captures.
+type NATS struct {
+	conn       *nats.Conn
+	consumer   natsConsumer
+	publisher  natsPublisher
+	subject    string
+	dlqSubject string
+
+	mu       sync.Mutex
+	inFlight map[string]jetstream.Msg
+}
+
+// NATSConfig names the JetStream stream and durable consumer jobs are read
+// from.
+type NATSConfig struct {
+	URL        string
+	Stream     string
+	Subject    string
+	DLQSubject string
+	Consumer   string
+	// AckWait is how long a delivery may go without an ack or a progress
+	// report before it is delivered again.
+	AckWait time.Duration
+	// MaxAckPending caps unacked deliveries across all workers.
+	MaxAckPending int
+}
+
+// NewNATS connects to the server and sets up the durable consumer. The
+// stream is created capturing Subject and DLQSubject when it doesn't exist;
+// an existing stream is used as it is and must capture both. The consumer
+// is created, or updated to the configured ack wait.
+func NewNATS(ctx context.Context, cfg NATSConfig) (*NATS, error) {
+	conn, err := nats.Connect(cfg.URL, nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open JetStream: %w", err)
+	}
+
+	stream, err := js.Stream(ctx, cfg.Stream)
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		stream, err = js.CreateStream(ctx, jetstream.StreamConfig{
+			Name:     cfg.Stream,
+			Subjects: []string{cfg.Subject, cfg.DLQSubject},
+			Storage:  jetstream.FileStorage,
+		})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set up JetStream stream: %w", err)
+	}
+
+	consumer, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       cfg.Consumer,
+		FilterSubject: cfg.Subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+		MaxAckPending: cfg.MaxAckPending,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set up JetStream consumer: %w", err)
+	}
+
+	n := newNATS(consumer, js, cfg.Subject, cfg.DLQSubject)
+	n.conn = conn
+	return n, nil
+}
+
+func newNATS(consumer natsConsumer, publisher natsPublisher, subject string, dlqSubject string) *NATS {
+	return &NATS{
+		consumer:   consumer,
+		publisher:  publisher,
+		subject:    subject,
+		dlqSubject: dlqSubject,
+		inFlight:   make(map[string]jetstream.Msg),
+	}
+}
+
+// Receive fetches the next job. A delayed job that isn't due yet is handed
+// back to the server to be delivered again when it is, and nothing is
+// returned.
+func (n *NATS) Receive(ctx context.Context, wait time.Duration) (*Message, error) {
+	var batch jetstream.MessageBatch
+	var err error
+	if wait > 0 {
+		batch, err = n.consumer.Fetch(1, jetstream.FetchMaxWait(wait))
+	} else {
+		batch, err = n.consumer.FetchNoWait(1)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from JetStream: %w", err)
+	}
+
+	var msg jetstream.Msg
+	select {
+	case msg = <-batch.Messages():
+	case <-ctx.Done():
+		return nil, nil
+	}
+	if msg == nil {
+		if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) {
+			return nil, fmt.Errorf("failed to fetch from JetStream: %w", err)
+		}
+		return nil, nil
+	}
+
+	if remaining := time.Until(natsNotBefore(msg)); remaining > 0 {
+		if err := msg.NakWithDelay(remaining); err != nil {
+			return nil, fmt.Errorf("failed to hold back delayed JetStream message: %w", err)
+		}
+		return nil, nil
+	}
+
+	meta, err := msg.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JetStream message metadata: %w", err)
+	}
+	handle := strconv.FormatUint(meta.Sequence.Stream, 10) + ":" + strconv.FormatUint(meta.NumDelivered, 10)
+	n.mu.Lock()
+	n.inFlight[handle] = msg
+	n.mu.Unlock()
+	return &Message{Body: string(msg.Data()), Handle: handle}, nil
+}
+
+// Ack acks the delivery and waits for the server to confirm it.
+func (n *NATS) Ack(ctx context.Context, handle string) error {
+	n.mu.Lock()
+	msg, ok := n.inFlight[handle]
+	delete(n.inFlight, handle)
+	n.mu.Unlock()
+	if !ok {
+		return ErrStaleDelivery
+	}
+	if err := msg.DoubleAck(ctx); err != nil {
+		return fmt.Errorf("failed to ack JetStream message: %w", err)
+	}
+	return nil
+}
+
+// Extend reports progress on the delivery, which restarts its ack wait.
+// The consumer's ack wait applies whatever visibility asks for.
+func (n *NATS) Extend(ctx context.Context, handle string, visibility time.Duration) error {
+	n.mu.Lock()
+	msg, ok := n.inFlight[handle]
+	n.mu.Unlock()
+	if !ok {
+		return ErrStaleDelivery
+	}
+	if err := msg.InProgress(); err != nil {
+		return fmt.Errorf("failed to extend JetStream ack wait: %w", err)
+	}
+	return nil
+}
+
+// Send publishes a job to the subject. JetStream has no delayed delivery,
+// so a delayed job carries the time it is due and is held back when it is
+// received early.
+func (n *NATS) Send(ctx context.Context, body string, delay time.Duration) error {
+	msg := nats.NewMsg(n.subject)
+	msg.Data = []byte(body)
+	if delay > 0 {
+		msg.Header.Set(natsNotBeforeHeader, strconv.FormatInt(time.Now().Add(delay).UnixMilli(), 10))
+	}
+	if _, err := n.publisher.PublishMsg(ctx, msg); err != nil {
+		return fmt.Errorf("failed to publish to JetStream: %w", err)
+	}
+	return nil
+}
+
+// DeadLetter publishes a job that failed for good to the dead-letter
+// subject.
+func (n *NATS) DeadLetter(ctx context.Context, body string) error {
+	msg := nats.NewMsg(n.dlqSubject)
+	msg.Data = []byte(body)
+	if _, err := n.publisher.PublishMsg(ctx, msg); err != nil {
+		return fmt.Errorf("failed to publish to JetStream dead-letter subject: %w", err)
+	}
+	return nil
+}
+
+// Close drains the connection. Unacked deliveries are delivered again once
+// their ack wait runs out.
+func (n *NATS) Close() error {
+	if n.conn == nil {
+		return nil
+	}
+	return n.conn.Drain()
+}
+
+func natsNotBefore(msg jetstream.Msg) time.Time {
+	value := msg.Headers().Get(natsNotBeforeHeader)
+	if value == "" {
+		return time.Time{}
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,146 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type fakeJetStreamMsg struct {
+	jetstream.Msg
+	data       string
+	headers    nats.Header
+	seq        uint64
+	delivered  uint64
+	acked      bool
+	inProgress int
+	nakDelay   time.Duration
+}
+
+func (m *fakeJetStreamMsg) Data() []byte         { return []byte(m.data) }
+func (m *fakeJetStreamMsg) Headers() nats.Header { return m.headers }
+
+func (m *fakeJetStreamMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	return &jetstream.MsgMetadata{Sequence: jetstream.SequencePair{Stream: m.seq}, NumDelivered: m.delivered}, nil
+}
+
+func (m *fakeJetStreamMsg) DoubleAck(context.Context) error {
+	m.acked = true
+	return nil
+}
+
+func (m *fakeJetStreamMsg) InProgress() error {
+	m.inProgress++
+	return nil
+}
+
+func (m *fakeJetStreamMsg) NakWithDelay(delay time.Duration) error {
+	m.nakDelay = delay
+	return nil
+}
+
+type fakeBatch struct {
+	msgs chan jetstream.Msg
+}
+
+func (b fakeBatch) Messages() <-chan jetstream.Msg { return b.msgs }
+func (b fakeBatch) Error() error                   { return nil }
+
+type fakeConsumer struct {
+	pending []*fakeJetStreamMsg
+}
+
+func (c *fakeConsumer) Fetch(batch int, _ ...jetstream.FetchOpt) (jetstream.MessageBatch, error) {
+	return c.FetchNoWait(batch)
+}
+
+func (c *fakeConsumer) FetchNoWait(int) (jetstream.MessageBatch, error) {
+	msgs := make(chan jetstream.Msg, 1)
+	if len(c.pending) > 0 {
+		msgs <- c.pending[0]
+		c.pending = c.pending[1:]
+	}
+	close(msgs)
+	return fakeBatch{msgs: msgs}, nil
+}
+
+type fakePublisher struct {
+	published []*nats.Msg
+}
+
+func (p *fakePublisher) PublishMsg(_ context.Context, msg *nats.Msg, _ ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	p.published = append(p.published, msg)
+	return &jetstream.PubAck{}, nil
+}
+
+func TestNATS_ReceiveAckExtend(t *testing.T) {
+	t.Parallel()
+
+	msg := &fakeJetStreamMsg{data: `{"conversionId":7}`, headers: nats.Header{}, seq: 42, delivered: 2}
+	n := newNATS(&fakeConsumer{pending: []*fakeJetStreamMsg{msg}}, &fakePublisher{}, "conversions.jobs", "conversions.failed")
+	ctx := context.Background()
+
+	got, err := n.Receive(ctx, time.Second)
+	if err != nil || got == nil || got.Body != `{"conversionId":7}` || got.Handle != "42:2" {
+		t.Fatalf("Receive = %+v, %v", got, err)
+	}
+	if err := n.Extend(ctx, got.Handle, time.Minute); err != nil || msg.inProgress != 1 {
+		t.Fatalf("Extend = %v, progress reports %d", err, msg.inProgress)
+	}
+	if err := n.Ack(ctx, got.Handle); err != nil || !msg.acked {
+		t.Fatalf("Ack = %v, acked %v", err, msg.acked)
+	}
+	if err := n.Ack(ctx, got.Handle); !errors.Is(err, ErrStaleDelivery) {
+		t.Errorf("second Ack = %v, want ErrStaleDelivery", err)
+	}
+
+	if got, err := n.Receive(ctx, 0); got != nil || err != nil {
+		t.Errorf("Receive on an empty consumer = %+v, %v", got, err)
+	}
+}
+
+func TestNATS_HoldsBackDelayedMessages(t *testing.T) {
+	t.Parallel()
+
+	publisher := &fakePublisher{}
+	n := newNATS(&fakeConsumer{}, publisher, "conversions.jobs", "conversions.failed")
+	if err := n.Send(context.Background(), `{"conversionId":7}`, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if len(publisher.published) != 1 || publisher.published[0].Subject != "conversions.jobs" {
+		t.Fatalf("published = %+v", publisher.published)
+	}
+
+	header := publisher.published[0].Header
+	due, err := strconv.ParseInt(header.Get(natsNotBeforeHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("missing %s header: %v", natsNotBeforeHeader, err)
+	}
+
+	msg := &fakeJetStreamMsg{data: `{"conversionId":7}`, headers: header, seq: 1, delivered: 1}
+	n.consumer = &fakeConsumer{pending: []*fakeJetStreamMsg{msg}}
+	if got, err := n.Receive(context.Background(), time.Second); got != nil || err != nil {
+		t.Fatalf("Receive = %+v, %v; want the early delivery held back", got, err)
+	}
+	if remaining := time.Until(time.UnixMilli(due)); msg.nakDelay <= 0 || msg.nakDelay > remaining+time.Second {
+		t.Errorf("held back for %v, want about %v", msg.nakDelay, remaining)
+	}
+}
+
+func TestNATS_DeadLetter(t *testing.T) {
+	t.Parallel()
+
+	publisher := &fakePublisher{}
+	n := newNATS(&fakeConsumer{}, publisher, "conversions.jobs", "conversions.failed")
+	if err := n.DeadLetter(context.Background(), `{"conversionId":7}`); err != nil {
+		t.Fatal(err)
+	}
+	if len(publisher.published) != 1 || publisher.published[0].Subject != "conversions.failed" {
+		t.Fatalf("published = %+v", publisher.published)
+	}
+}
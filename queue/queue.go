@@ -0,0 +1,62 @@
+// Package queue abstracts job sources other than the Redis queues, so the
+// worker can claim jobs from a managed queue service instead.
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Queue drivers selectable with QUEUE_DRIVER.
+const (
+	DriverRedis    = "redis"
+	DriverSQS      = "sqs"
+	DriverRabbitMQ = "rabbitmq"
+	DriverKafka    = "kafka"
+	DriverNATS     = "nats"
+)
+
+// ErrStaleDelivery is returned when acking a delivery this consumer no
+// longer holds, because its connection closed or its partition was
+// reassigned. The broker delivers the message again.
+var ErrStaleDelivery = errors.New("delivery is no longer held by this consumer")
+
+// Message is one delivery of a job.
+type Message struct {
+	Body string
+	// Handle identifies this delivery for Ack and Extend. A redelivered job
+	// gets a new handle.
+	Handle string
+}
+
+// Source is a queue with at-least-once delivery: a received message is
+// hidden from other consumers until it is acked, or until its visibility
+// timeout runs out and it is delivered again.
+type Source interface {
+	// Receive waits up to wait for a message and returns nil when none
+	// arrived.
+	Receive(ctx context.Context, wait time.Duration) (*Message, error)
+	// Ack deletes a delivered message for good.
+	Ack(ctx context.Context, handle string) error
+	// Extend keeps a delivered message hidden for visibility from now.
+	Extend(ctx context.Context, handle string, visibility time.Duration) error
+	// Send enqueues a job, hidden for delay first.
+	Send(ctx context.Context, body string, delay time.Duration) error
+}
+
+// DeadLetterer is a Source with its own place for jobs that failed for
+// good, used instead of the failed queue.
+type DeadLetterer interface {
+	DeadLetter(ctx context.Context, body string) error
+}
+
+// Committer is a Source whose acks commit a position in a log instead of
+// deleting one message, so an acked job can't be taken back. The worker acks
+// its deliveries only once the job's status is in the database, and
+// releases them to be delivered again when that write fails.
+type Committer interface {
+	Source
+	// Release hands a delivery back to be received again.
+	Release(ctx context.Context, handle string) error
+}
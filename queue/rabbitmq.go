@@ -0,0 +1,312 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// rabbitChannel is the part of an AMQP channel RabbitMQ uses, so tests can
+// stand in for the broker.
+type rabbitChannel interface {
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Ack(tag uint64, multiple bool) error
+	// Publish returns once the broker has confirmed the message.
+	Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error
+}
+
+// confirmChannel publishes in confirm mode, so a retry is on the broker
+// before the delivery it replaces is acked.
+type confirmChannel struct {
+	*amqp.Channel
+}
+
+func (c confirmChannel) Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	confirmation, err := c.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+	if err != nil {
+		return err
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("broker refused the message")
+	}
+	return nil
+}
+
+// RabbitMQ is a Source backed by one durable RabbitMQ queue. Deliveries are
+// acked manually, and the broker hands out at most prefetch unacked ones,
+// one per worker. Jobs that failed for good go through the dead-letter
+// exchange <queue>.dlx to the queue <queue>.failed, as do messages the
+// broker dead-letters itself.
+type RabbitMQ struct {
+	dial     func() (rabbitChannel, io.Closer, error)
+	queue    string
+	prefetch int
+
+	mu         sync.Mutex
+	conn       io.Closer
+	channel    rabbitChannel
+	deliveries <-chan amqp.Delivery
+	generation uint64
+}
+
+// NewRabbitMQ consumes from queue on the broker at url. The connection is
+// opened by Connect, or by the first call that needs it.
+func NewRabbitMQ(url string, queue string, prefetch int) *RabbitMQ {
+	return &RabbitMQ{dial: dialRabbitMQ(url), queue: queue, prefetch: prefetch}
+}
+
+func dialRabbitMQ(url string) func() (rabbitChannel, io.Closer, error) {
+	return func() (rabbitChannel, io.Closer, error) {
+		conn, err := amqp.Dial(url)
+		if err != nil {
+			return nil, nil, err
+		}
+		ch, err := conn.Channel()
+		if err == nil {
+			err = ch.Confirm(false)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		return confirmChannel{ch}, conn, nil
+	}
+}
+
+func (r *RabbitMQ) deadLetterExchange() string {
+	return r.queue + ".dlx"
+}
+
+// FailedQueue is the queue dead-lettered jobs end up in.
+func (r *RabbitMQ) FailedQueue() string {
+	return r.queue + ".failed"
+}
+
+// Connect opens the connection and declares the queues, so a broker that is
+// unreachable or has the queue declared with other arguments is reported at
+// startup.
+func (r *RabbitMQ) Connect() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.connectLocked()
+}
+
+func (r *RabbitMQ) connectLocked() error {
+	if r.channel != nil {
+		return nil
+	}
+
+	ch, conn, err := r.dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	deliveries, err := r.declare(ch)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	r.conn = conn
+	r.channel = ch
+	r.deliveries = deliveries
+	r.generation++
+	return nil
+}
+
+func (r *RabbitMQ) declare(ch rabbitChannel) (<-chan amqp.Delivery, error) {
+	if err := ch.ExchangeDeclare(r.deadLetterExchange(), amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+	if _, err := ch.QueueDeclare(r.FailedQueue(), true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare failed queue: %w", err)
+	}
+	if err := ch.QueueBind(r.FailedQueue(), "", r.deadLetterExchange(), false, nil); err != nil {
+		return nil, fmt.Errorf("failed to bind failed queue: %w", err)
+	}
+	args := amqp.Table{"x-dead-letter-exchange": r.deadLetterExchange()}
+	if _, err := ch.QueueDeclare(r.queue, true, false, false, false, args); err != nil {
+		return nil, fmt.Errorf("failed to declare queue %s: %w", r.queue, err)
+	}
+	if err := ch.Qos(r.prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+	deliveries, err := ch.Consume(r.queue, "", false, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume from %s: %w", r.queue, err)
+	}
+	return deliveries, nil
+}
+
+// reset drops a channel that closed under us; the next call reconnects.
+func (r *RabbitMQ) reset(generation uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.generation != generation || r.conn == nil {
+		return
+	}
+	r.conn.Close()
+	r.conn = nil
+	r.channel = nil
+	r.deliveries = nil
+}
+
+// Close closes the connection. Unacked deliveries go back to the queue.
+func (r *RabbitMQ) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	r.channel = nil
+	r.deliveries = nil
+	return err
+}
+
+func (r *RabbitMQ) Receive(ctx context.Context, wait time.Duration) (*Message, error) {
+	r.mu.Lock()
+	err := r.connectLocked()
+	deliveries, generation := r.deliveries, r.generation
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	} else {
+		expired := make(chan time.Time)
+		close(expired)
+		timeout = expired
+	}
+
+	select {
+	case delivery, ok := <-deliveries:
+		if !ok {
+			r.reset(generation)
+			return nil, fmt.Errorf("RabbitMQ channel closed")
+		}
+		return &Message{Body: string(delivery.Body), Handle: rabbitHandle(generation, delivery.DeliveryTag)}, nil
+	case <-ctx.Done():
+		return nil, nil
+	case <-timeout:
+		return nil, nil
+	}
+}
+
+// Ack acks a delivery on the channel it came from. Delivery tags are only
+// valid on that channel, so the handle carries which connection it was.
+func (r *RabbitMQ) Ack(ctx context.Context, handle string) error {
+	generation, tag, err := parseRabbitHandle(handle)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.channel == nil || r.generation != generation {
+		return ErrStaleDelivery
+	}
+	if err := r.channel.Ack(tag, false); err != nil {
+		return fmt.Errorf("failed to ack RabbitMQ delivery: %w", err)
+	}
+	return nil
+}
+
+// Extend does nothing: RabbitMQ keeps a delivery with its consumer until it
+// is acked or the channel closes, however long that takes, up to the
+// broker's consumer_timeout.
+func (r *RabbitMQ) Extend(ctx context.Context, handle string, visibility time.Duration) error {
+	return nil
+}
+
+// Send publishes a job to the queue. RabbitMQ has no delayed delivery, so a
+// delayed job waits in <queue>.delay.<seconds> until its TTL runs out and
+// the queue dead-letters it onto the job queue. Each delay has its own wait
+// queue, since messages only expire from the head of a queue, and idle wait
+// queues delete themselves.
+func (r *RabbitMQ) Send(ctx context.Context, body string, delay time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.connectLocked(); err != nil {
+		return err
+	}
+
+	key := r.queue
+	if delay > 0 {
+		seconds := int64((delay + time.Second - 1) / time.Second)
+		key = fmt.Sprintf("%s.delay.%d", r.queue, seconds)
+		args := amqp.Table{
+			"x-message-ttl":             seconds * 1000,
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": r.queue,
+			"x-expires":                 seconds*1000 + int64(10*time.Minute/time.Millisecond),
+		}
+		if _, err := r.channel.QueueDeclare(key, true, false, false, false, args); err != nil {
+			return fmt.Errorf("failed to declare delay queue %s: %w", key, err)
+		}
+	}
+
+	if err := r.channel.Publish(ctx, "", key, jobPublishing(body)); err != nil {
+		return fmt.Errorf("failed to publish to RabbitMQ: %w", err)
+	}
+	return nil
+}
+
+// DeadLetter publishes a job that failed for good to the dead-letter
+// exchange.
+func (r *RabbitMQ) DeadLetter(ctx context.Context, body string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.connectLocked(); err != nil {
+		return err
+	}
+	if err := r.channel.Publish(ctx, r.deadLetterExchange(), "", jobPublishing(body)); err != nil {
+		return fmt.Errorf("failed to dead-letter RabbitMQ message: %w", err)
+	}
+	return nil
+}
+
+func jobPublishing(body string) amqp.Publishing {
+	return amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         []byte(body),
+	}
+}
+
+func rabbitHandle(generation uint64, tag uint64) string {
+	return strconv.FormatUint(generation, 10) + ":" + strconv.FormatUint(tag, 10)
+}
+
+func parseRabbitHandle(handle string) (generation uint64, tag uint64, err error) {
+	g, t, ok := strings.Cut(handle, ":")
+	if ok {
+		generation, err = strconv.ParseUint(g, 10, 64)
+		if err == nil {
+			tag, err = strconv.ParseUint(t, 10, 64)
+		}
+	}
+	if !ok || err != nil {
+		return 0, 0, fmt.Errorf("invalid RabbitMQ delivery handle %q", handle)
+	}
+	return generation, tag, nil
+}
@@ -0,0 +1,201 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type published struct {
+	exchange string
+	key      string
+	body     string
+}
+
+type fakeRabbit struct {
+	deliveries chan amqp.Delivery
+	declared   map[string]amqp.Table
+	prefetch   int
+	acked      []uint64
+	published  []published
+	closed     bool
+}
+
+func newFakeRabbit() *fakeRabbit {
+	return &fakeRabbit{deliveries: make(chan amqp.Delivery, 10), declared: map[string]amqp.Table{}}
+}
+
+func (f *fakeRabbit) Qos(prefetchCount, _ int, _ bool) error {
+	f.prefetch = prefetchCount
+	return nil
+}
+
+func (f *fakeRabbit) ExchangeDeclare(string, string, bool, bool, bool, bool, amqp.Table) error {
+	return nil
+}
+
+func (f *fakeRabbit) QueueDeclare(name string, _, _, _, _ bool, args amqp.Table) (amqp.Queue, error) {
+	f.declared[name] = args
+	return amqp.Queue{Name: name}, nil
+}
+
+func (f *fakeRabbit) QueueBind(string, string, string, bool, amqp.Table) error {
+	return nil
+}
+
+func (f *fakeRabbit) Consume(string, string, bool, bool, bool, bool, amqp.Table) (<-chan amqp.Delivery, error) {
+	return f.deliveries, nil
+}
+
+func (f *fakeRabbit) Ack(tag uint64, _ bool) error {
+	f.acked = append(f.acked, tag)
+	return nil
+}
+
+func (f *fakeRabbit) Publish(_ context.Context, exchange, key string, msg amqp.Publishing) error {
+	f.published = append(f.published, published{exchange, key, string(msg.Body)})
+	return nil
+}
+
+func (f *fakeRabbit) Close() error {
+	f.closed = true
+	return nil
+}
+
+func fakeRabbitMQ(channels ...*fakeRabbit) *RabbitMQ {
+	r := &RabbitMQ{queue: "conversions", prefetch: 3}
+	r.dial = func() (rabbitChannel, io.Closer, error) {
+		if len(channels) == 0 {
+			return nil, nil, errors.New("connection refused")
+		}
+		ch := channels[0]
+		channels = channels[1:]
+		return ch, ch, nil
+	}
+	return r
+}
+
+func TestRabbitMQ_ReceiveAndAck(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeRabbit()
+	source := fakeRabbitMQ(fake)
+	if err := source.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if fake.prefetch != 3 {
+		t.Errorf("prefetch = %d, want 3", fake.prefetch)
+	}
+	if got := fake.declared["conversions"]["x-dead-letter-exchange"]; got != "conversions.dlx" {
+		t.Errorf("x-dead-letter-exchange = %v, want conversions.dlx", got)
+	}
+	if _, ok := fake.declared["conversions.failed"]; !ok {
+		t.Error("expected the failed queue to be declared")
+	}
+
+	fake.deliveries <- amqp.Delivery{Body: []byte(`{"conversionId":7}`), DeliveryTag: 42}
+	msg, err := source.Receive(context.Background(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg == nil || msg.Body != `{"conversionId":7}` {
+		t.Fatalf("Receive = %+v", msg)
+	}
+	if err := source.Ack(context.Background(), msg.Handle); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.acked) != 1 || fake.acked[0] != 42 {
+		t.Errorf("acked = %v, want [42]", fake.acked)
+	}
+
+	msg, err = source.Receive(context.Background(), 0)
+	if err != nil || msg != nil {
+		t.Fatalf("Receive on an empty queue = %+v, %v; want nil, nil", msg, err)
+	}
+}
+
+func TestRabbitMQ_ReconnectsAfterChannelClose(t *testing.T) {
+	t.Parallel()
+
+	first, second := newFakeRabbit(), newFakeRabbit()
+	source := fakeRabbitMQ(first, second)
+
+	first.deliveries <- amqp.Delivery{Body: []byte(`{"conversionId":7}`), DeliveryTag: 1}
+	msg, err := source.Receive(context.Background(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	close(first.deliveries)
+	if _, err := source.Receive(context.Background(), time.Second); err == nil {
+		t.Fatal("expected an error when the channel closes")
+	}
+	if !first.closed {
+		t.Error("expected the closed connection to be released")
+	}
+
+	// The broker redelivers the message on the new channel
+	second.deliveries <- amqp.Delivery{Body: []byte(`{"conversionId":7}`), DeliveryTag: 1}
+	redelivered, err := source.Receive(context.Background(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redelivered.Handle == msg.Handle {
+		t.Errorf("expected a new handle after reconnecting, got %q twice", msg.Handle)
+	}
+	if err := source.Ack(context.Background(), msg.Handle); !errors.Is(err, ErrStaleDelivery) {
+		t.Errorf("Ack of the old delivery = %v, want ErrStaleDelivery", err)
+	}
+	if len(second.acked) != 0 {
+		t.Errorf("old delivery tag was acked on the new channel: %v", second.acked)
+	}
+}
+
+func TestRabbitMQ_SendAndDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeRabbit()
+	source := fakeRabbitMQ(fake)
+	ctx := context.Background()
+
+	if err := source.Send(ctx, `{"conversionId":7}`, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Send(ctx, `{"conversionId":8}`, 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.DeadLetter(ctx, `{"conversionId":9}`); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []published{
+		{"", "conversions", `{"conversionId":7}`},
+		{"", "conversions.delay.2", `{"conversionId":8}`},
+		{"conversions.dlx", "", `{"conversionId":9}`},
+	}
+	if len(fake.published) != len(want) {
+		t.Fatalf("published = %+v, want %+v", fake.published, want)
+	}
+	for i := range want {
+		if fake.published[i] != want[i] {
+			t.Errorf("published[%d] = %+v, want %+v", i, fake.published[i], want[i])
+		}
+	}
+
+	args := fake.declared["conversions.delay.2"]
+	if args["x-message-ttl"] != int64(2000) || args["x-dead-letter-routing-key"] != "conversions" {
+		t.Errorf("delay queue arguments = %v", args)
+	}
+}
+
+func TestRabbitMQ_ConnectFailure(t *testing.T) {
+	t.Parallel()
+
+	if err := fakeRabbitMQ().Connect(); err == nil {
+		t.Fatal("expected an error when the broker is unreachable")
+	}
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// SQS limits on receive waits and message delays.
+const (
+	sqsMaxWait  = 20 * time.Second
+	sqsMaxDelay = 15 * time.Minute
+)
+
+// SQSClient is the part of the SQS API a Source needs.
+type SQSClient interface {
+	ReceiveMessage(ctx context.Context, in *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, in *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, in *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+	SendMessage(ctx context.Context, in *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// SQS is a Source backed by one Amazon SQS queue.
+type SQS struct {
+	client     SQSClient
+	url        string
+	visibility time.Duration
+}
+
+// NewSQS receives from the queue at url, hiding each message for
+// visibility until the worker extends or acks it.
+func NewSQS(client SQSClient, url string, visibility time.Duration) *SQS {
+	return &SQS{client: client, url: url, visibility: visibility}
+}
+
+func (s *SQS) Receive(ctx context.Context, wait time.Duration) (*Message, error) {
+	if wait > sqsMaxWait {
+		wait = sqsMaxWait
+	}
+	out, err := s.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(s.url),
+		MaxNumberOfMessages: 1,
+		WaitTimeSeconds:     int32(wait / time.Second),
+		VisibilityTimeout:   int32(s.visibility / time.Second),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive from SQS: %w", err)
+	}
+	if len(out.Messages) == 0 {
+		return nil, nil
+	}
+	msg := out.Messages[0]
+	return &Message{Body: aws.ToString(msg.Body), Handle: aws.ToString(msg.ReceiptHandle)}, nil
+}
+
+func (s *SQS) Ack(ctx context.Context, handle string) error {
+	_, err := s.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(s.url),
+		ReceiptHandle: aws.String(handle),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete SQS message: %w", err)
+	}
+	return nil
+}
+
+func (s *SQS) Extend(ctx context.Context, handle string, visibility time.Duration) error {
+	_, err := s.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(s.url),
+		ReceiptHandle:     aws.String(handle),
+		VisibilityTimeout: int32(visibility / time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to extend SQS message visibility: %w", err)
+	}
+	return nil
+}
+
+// Send enqueues body. Delays past the SQS maximum of 15 minutes are
+// shortened to it.
+func (s *SQS) Send(ctx context.Context, body string, delay time.Duration) error {
+	if delay > sqsMaxDelay {
+		delay = sqsMaxDelay
+	}
+	_, err := s.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:     aws.String(s.url),
+		MessageBody:  aws.String(body),
+		DelaySeconds: int32(delay / time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send SQS message: %w", err)
+	}
+	return nil
+}
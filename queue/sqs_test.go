@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+type fakeSQS struct {
+	SQSClient
+	receive *sqs.ReceiveMessageInput
+	send    *sqs.SendMessageInput
+	change  *sqs.ChangeMessageVisibilityInput
+	pending []types.Message
+}
+
+func (f *fakeSQS) ReceiveMessage(_ context.Context, in *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	f.receive = in
+	out := &sqs.ReceiveMessageOutput{Messages: f.pending}
+	f.pending = nil
+	return out, nil
+}
+
+func (f *fakeSQS) SendMessage(_ context.Context, in *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.send = in
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ChangeMessageVisibility(_ context.Context, in *sqs.ChangeMessageVisibilityInput, _ ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.change = in
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func TestSQS_Receive(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeSQS{pending: []types.Message{{Body: aws.String(`{"conversionId":7}`), ReceiptHandle: aws.String("handle-1")}}}
+	source := NewSQS(fake, "https://sqs.eu-west-1.amazonaws.com/1/conversions", 2*time.Minute)
+
+	msg, err := source.Receive(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg == nil || msg.Body != `{"conversionId":7}` || msg.Handle != "handle-1" {
+		t.Fatalf("Receive = %+v", msg)
+	}
+	// Long polls are capped at the SQS maximum of 20 seconds
+	if got := fake.receive.WaitTimeSeconds; got != 20 {
+		t.Errorf("WaitTimeSeconds = %d, want 20", got)
+	}
+	if got := fake.receive.VisibilityTimeout; got != 120 {
+		t.Errorf("VisibilityTimeout = %d, want 120", got)
+	}
+
+	msg, err = source.Receive(context.Background(), time.Second)
+	if err != nil || msg != nil {
+		t.Fatalf("Receive on an empty queue = %+v, %v; want nil, nil", msg, err)
+	}
+}
+
+func TestSQS_SendAndExtend(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeSQS{}
+	source := NewSQS(fake, "queue-url", time.Minute)
+
+	if err := source.Send(context.Background(), `{"conversionId":7}`, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.send.DelaySeconds; got != 900 {
+		t.Errorf("DelaySeconds = %d, want the 900s maximum", got)
+	}
+
+	if err := source.Extend(context.Background(), "handle-1", 90*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if aws.ToString(fake.change.ReceiptHandle) != "handle-1" || fake.change.VisibilityTimeout != 90 {
+		t.Errorf("ChangeMessageVisibility = %+v", fake.change)
+	}
+}
@@ -0,0 +1,391 @@
+// Package replay runs recorded conversion history through a proposed
+// configuration and estimates the queue latencies it would have produced.
+// It is a discrete-event simulation: no Redis, Gotenberg or S3 is touched.
+package replay
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"time"
+
+	"converter/models"
+	"converter/services"
+)
+
+// Job is one conversion reconstructed from its audit records.
+type Job struct {
+	ConversionID int
+	TenantID     int
+	Priority     models.Priority
+	Arrival      time.Time
+	// Attempts are the recorded run times in order; all but the last
+	// failed and were retried.
+	Attempts []time.Duration
+	// Failed is set when the last attempt failed too.
+	Failed bool
+}
+
+// Scenario is a configuration to evaluate.
+type Scenario struct {
+	Name    string
+	Workers int
+	// Timeout caps each attempt; an attempt that ran longer in history
+	// times out and is retried. Zero keeps the recorded durations.
+	Timeout time.Duration
+	// TenantCap limits how many of one tenant's jobs run at once. Zero is
+	// unlimited.
+	TenantCap  int
+	MaxRetries int
+	// Weights are the claim weights per priority; defaults to 8/3/1.
+	Weights map[models.Priority]int
+}
+
+// Result summarises one scenario run.
+type Result struct {
+	Scenario      Scenario
+	Jobs          int
+	Completed     int
+	Failed        int
+	TimedOut      int
+	WaitP50       time.Duration
+	WaitP90       time.Duration
+	WaitP99       time.Duration
+	WaitMax       time.Duration
+	LatencyP50    time.Duration
+	LatencyP90    time.Duration
+	LatencyP99    time.Duration
+	MaxQueueDepth int
+	Utilization   float64
+	Makespan      time.Duration
+}
+
+var defaultWeights = map[models.Priority]int{
+	models.PriorityHigh:   8,
+	models.PriorityNormal: 3,
+	models.PriorityLow:    1,
+}
+
+// FromAudit groups audit records into jobs. Arrival is the job's enqueue
+// time; records written before enqueuedAt existed fall back to when the
+// first attempt started.
+func FromAudit(records []services.AuditRecord) []Job {
+	byID := make(map[int][]services.AuditRecord)
+	for _, r := range records {
+		if r.ConversionID == 0 {
+			continue
+		}
+		byID[r.ConversionID] = append(byID[r.ConversionID], r)
+	}
+
+	jobs := make([]Job, 0, len(byID))
+	for id, attempts := range byID {
+		sort.Slice(attempts, func(i, j int) bool {
+			if attempts[i].RetryCount != attempts[j].RetryCount {
+				return attempts[i].RetryCount < attempts[j].RetryCount
+			}
+			return attempts[i].RecordedAt.Before(attempts[j].RecordedAt)
+		})
+
+		first := attempts[0]
+		job := Job{
+			ConversionID: id,
+			TenantID:     first.UserID,
+			Priority:     models.Priority(first.Priority).Normalize(),
+			Arrival:      first.EnqueuedAt,
+		}
+		if job.Arrival.IsZero() {
+			job.Arrival = first.StartedAt
+		}
+		if job.Arrival.IsZero() {
+			job.Arrival = first.RecordedAt.Add(-time.Duration(first.DurationMs) * time.Millisecond)
+		}
+		for _, a := range attempts {
+			job.Attempts = append(job.Attempts, time.Duration(a.DurationMs)*time.Millisecond)
+		}
+		job.Failed = attempts[len(attempts)-1].Outcome != "completed"
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Arrival.Before(jobs[j].Arrival) })
+	return jobs
+}
+
+// retryBackoff mirrors the worker: 2^n seconds, at most 30s.
+func retryBackoff(retry int) time.Duration {
+	delay := time.Duration(math.Pow(2, float64(retry))) * time.Second
+	if delay > 30*time.Second {
+		delay = 30 * time.Second
+	}
+	return delay
+}
+
+// attempt is one run of a job waiting for or holding a worker.
+type attempt struct {
+	job     *jobState
+	readyAt time.Duration
+	seq     int
+}
+
+type jobState struct {
+	Job
+	next      int // index into Attempts
+	retries   int
+	firstWait time.Duration
+	started   bool
+	doneAt    time.Duration
+	outcome   string
+}
+
+// Simulate replays jobs through the scenario. Times are measured from the
+// first arrival.
+func Simulate(jobs []Job, sc Scenario) Result {
+	if sc.Workers <= 0 {
+		sc.Workers = 1
+	}
+	weights := make([]int, len(models.Priorities))
+	for i, priority := range models.Priorities {
+		weights[i] = defaultWeights[priority]
+		if w, ok := sc.Weights[priority]; ok {
+			weights[i] = w
+		}
+	}
+
+	result := Result{Scenario: sc, Jobs: len(jobs)}
+	if len(jobs) == 0 {
+		return result
+	}
+	origin := jobs[0].Arrival
+	for _, job := range jobs {
+		if job.Arrival.Before(origin) {
+			origin = job.Arrival
+		}
+	}
+
+	states := make([]*jobState, len(jobs))
+	ready := &attemptHeap{}
+	seq := 0
+	for i := range jobs {
+		states[i] = &jobState{Job: jobs[i]}
+		heap.Push(ready, attempt{job: states[i], readyAt: jobs[i].Arrival.Sub(origin), seq: seq})
+		seq++
+	}
+
+	queues := make([][]*jobState, len(models.Priorities))
+	current := make([]int, len(models.Priorities))
+	running := &completionHeap{}
+	inFlight := make(map[int]int)
+	free := sc.Workers
+	var busy, now time.Duration
+
+	queued := func() int {
+		n := 0
+		for _, q := range queues {
+			n += len(q)
+		}
+		return n
+	}
+
+	for ready.Len() > 0 || running.Len() > 0 || queued() > 0 {
+		// Advance to the next arrival or completion
+		next := time.Duration(math.MaxInt64)
+		if ready.Len() > 0 {
+			next = (*ready)[0].readyAt
+		}
+		if running.Len() > 0 && (*running)[0].at < next {
+			next = (*running)[0].at
+		}
+		if next == time.Duration(math.MaxInt64) {
+			break
+		}
+		now = next
+
+		for running.Len() > 0 && (*running)[0].at <= now {
+			c := heap.Pop(running).(completion)
+			free++
+			inFlight[c.job.TenantID]--
+			if follow, ok := finishAttempt(c.job, c.timedOut, sc.MaxRetries, now); ok {
+				heap.Push(ready, attempt{job: c.job, readyAt: follow, seq: seq})
+				seq++
+			}
+		}
+		for ready.Len() > 0 && (*ready)[0].readyAt <= now {
+			a := heap.Pop(ready).(attempt)
+			i := priorityIndex(a.job.Priority)
+			queues[i] = append(queues[i], a.job)
+		}
+		if depth := queued(); depth > result.MaxQueueDepth {
+			result.MaxQueueDepth = depth
+		}
+
+		for free > 0 {
+			job := dispatch(queues, weights, current, inFlight, sc.TenantCap)
+			if job == nil {
+				break
+			}
+			if !job.started {
+				job.started = true
+				job.firstWait = now - job.Arrival.Sub(origin)
+			}
+
+			run := job.Attempts[job.next]
+			timedOut := sc.Timeout > 0 && run > sc.Timeout
+			if timedOut {
+				run = sc.Timeout
+			}
+			busy += run
+			free--
+			inFlight[job.TenantID]++
+			heap.Push(running, completion{job: job, at: now + run, timedOut: timedOut})
+		}
+	}
+
+	var waits, latencies []time.Duration
+	for _, s := range states {
+		switch s.outcome {
+		case "completed":
+			result.Completed++
+		case "timed_out":
+			result.TimedOut++
+		default:
+			result.Failed++
+		}
+		waits = append(waits, s.firstWait)
+		latencies = append(latencies, s.doneAt-s.Arrival.Sub(origin))
+	}
+
+	result.Makespan = now
+	if now > 0 {
+		result.Utilization = float64(busy) / (float64(now) * float64(sc.Workers))
+	}
+	result.WaitP50, result.WaitP90, result.WaitP99, result.WaitMax = percentiles(waits)
+	result.LatencyP50, result.LatencyP90, result.LatencyP99, _ = percentiles(latencies)
+	return result
+}
+
+// finishAttempt records the end of an attempt and returns when the next one
+// becomes ready, if there is one. Recorded failures are retried as they
+// were in history; timeouts introduced by the scenario are retried until
+// MaxRetries is used up.
+func finishAttempt(job *jobState, timedOut bool, maxRetries int, now time.Duration) (time.Duration, bool) {
+	job.doneAt = now
+
+	if timedOut {
+		if job.retries >= maxRetries {
+			job.outcome = "timed_out"
+			return 0, false
+		}
+		job.retries++
+		return now + retryBackoff(job.retries), true
+	}
+
+	if job.next == len(job.Attempts)-1 {
+		job.outcome = "completed"
+		if job.Failed {
+			job.outcome = "failed"
+		}
+		return 0, false
+	}
+	if job.retries >= maxRetries {
+		job.outcome = "failed"
+		return 0, false
+	}
+	job.next++
+	job.retries++
+	return now + retryBackoff(job.retries), true
+}
+
+// dispatch picks the next job like the worker's fair scheduler: smooth
+// weighted round-robin for the preferred priority, then strict priority
+// order. Jobs of tenants at their cap are skipped.
+func dispatch(queues [][]*jobState, weights, current []int, inFlight map[int]int, tenantCap int) *jobState {
+	total, best := 0, -1
+	for i, w := range weights {
+		current[i] += w
+		total += w
+		if w > 0 && (best < 0 || current[i] > current[best]) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		current[best] -= total
+	}
+
+	order := make([]int, 0, len(queues))
+	if best >= 0 {
+		order = append(order, best)
+	}
+	for i := range queues {
+		if i != best {
+			order = append(order, i)
+		}
+	}
+
+	for _, i := range order {
+		for j, job := range queues[i] {
+			if tenantCap > 0 && inFlight[job.TenantID] >= tenantCap {
+				continue
+			}
+			queues[i] = append(queues[i][:j], queues[i][j+1:]...)
+			return job
+		}
+	}
+	return nil
+}
+
+func priorityIndex(p models.Priority) int {
+	for i, priority := range models.Priorities {
+		if priority == p {
+			return i
+		}
+	}
+	return priorityIndex(models.PriorityNormal)
+}
+
+func percentiles(values []time.Duration) (p50, p90, p99, max time.Duration) {
+	if len(values) == 0 {
+		return
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	at := func(q float64) time.Duration {
+		return values[int(math.Ceil(q*float64(len(values))))-1]
+	}
+	return at(0.50), at(0.90), at(0.99), values[len(values)-1]
+}
+
+type attemptHeap []attempt
+
+func (h attemptHeap) Len() int { return len(h) }
+func (h attemptHeap) Less(i, j int) bool {
+	if h[i].readyAt != h[j].readyAt {
+		return h[i].readyAt < h[j].readyAt
+	}
+	return h[i].seq < h[j].seq
+}
+func (h attemptHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *attemptHeap) Push(x any)   { *h = append(*h, x.(attempt)) }
+func (h *attemptHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type completion struct {
+	job      *jobState
+	at       time.Duration
+	timedOut bool
+}
+
+type completionHeap []completion
+
+func (h completionHeap) Len() int           { return len(h) }
+func (h completionHeap) Less(i, j int) bool { return h[i].at < h[j].at }
+func (h completionHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *completionHeap) Push(x any)        { *h = append(*h, x.(completion)) }
+func (h *completionHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
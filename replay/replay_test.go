@@ -0,0 +1,130 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"converter/models"
+	"converter/services"
+)
+
+var t0 = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+func job(id, tenant int, arrival time.Duration, attempts ...time.Duration) Job {
+	return Job{
+		ConversionID: id,
+		TenantID:     tenant,
+		Priority:     models.PriorityNormal,
+		Arrival:      t0.Add(arrival),
+		Attempts:     attempts,
+	}
+}
+
+func TestSimulate_MoreWorkersCutWait(t *testing.T) {
+	t.Parallel()
+
+	jobs := []Job{
+		job(1, 1, 0, 10*time.Second),
+		job(2, 2, 0, 10*time.Second),
+	}
+
+	one := Simulate(jobs, Scenario{Workers: 1, MaxRetries: 3})
+	if one.WaitMax != 10*time.Second || one.Makespan != 20*time.Second {
+		t.Fatalf("one worker: expected max wait 10s and makespan 20s, got %v and %v", one.WaitMax, one.Makespan)
+	}
+	if one.Completed != 2 || one.Utilization != 1 {
+		t.Fatalf("one worker: expected 2 completed at full utilization, got %+v", one)
+	}
+
+	two := Simulate(jobs, Scenario{Workers: 2, MaxRetries: 3})
+	if two.WaitMax != 0 || two.Makespan != 10*time.Second {
+		t.Fatalf("two workers: expected no wait and makespan 10s, got %v and %v", two.WaitMax, two.Makespan)
+	}
+}
+
+func TestSimulate_TimeoutRetriesThenGivesUp(t *testing.T) {
+	t.Parallel()
+
+	jobs := []Job{job(1, 1, 0, 90*time.Second)}
+
+	r := Simulate(jobs, Scenario{Workers: 1, Timeout: 60 * time.Second, MaxRetries: 1})
+	if r.TimedOut != 1 || r.Completed != 0 {
+		t.Fatalf("expected the job to time out, got %+v", r)
+	}
+	// Two 60s attempts with a 2s backoff in between
+	if r.LatencyP50 != 122*time.Second {
+		t.Fatalf("expected latency 122s, got %v", r.LatencyP50)
+	}
+}
+
+func TestSimulate_RecordedRetriesAndFailures(t *testing.T) {
+	t.Parallel()
+
+	retried := job(1, 1, 0, 5*time.Second, 5*time.Second)
+	failed := job(2, 2, 0, time.Second)
+	failed.Failed = true
+
+	r := Simulate([]Job{retried, failed}, Scenario{Workers: 2, MaxRetries: 3})
+	if r.Completed != 1 || r.Failed != 1 {
+		t.Fatalf("expected one completed and one failed, got %+v", r)
+	}
+	if r.LatencyP99 != 12*time.Second {
+		t.Fatalf("expected retried job to finish after 5s+2s+5s, got %v", r.LatencyP99)
+	}
+}
+
+func TestSimulate_TenantCap(t *testing.T) {
+	t.Parallel()
+
+	jobs := []Job{
+		job(1, 7, 0, 10*time.Second),
+		job(2, 7, 0, 10*time.Second),
+		job(3, 7, 0, 10*time.Second),
+		job(4, 8, time.Second, 10*time.Second),
+	}
+
+	uncapped := Simulate(jobs, Scenario{Workers: 3, MaxRetries: 3})
+	capped := Simulate(jobs, Scenario{Workers: 3, TenantCap: 1, MaxRetries: 3})
+
+	// Uncapped, tenant 7 fills every worker and tenant 8 waits 9s; with a
+	// cap of one, tenant 8 starts on arrival
+	if uncapped.WaitMax != 9*time.Second {
+		t.Fatalf("uncapped: expected max wait 9s, got %v", uncapped.WaitMax)
+	}
+	if capped.Makespan != 30*time.Second {
+		t.Fatalf("capped: expected tenant 7 to run serially over 30s, got %v", capped.Makespan)
+	}
+	for _, s := range []Result{uncapped, capped} {
+		if s.Completed != 4 {
+			t.Fatalf("expected all jobs completed, got %+v", s)
+		}
+	}
+}
+
+func TestFromAudit(t *testing.T) {
+	t.Parallel()
+
+	records := []services.AuditRecord{
+		{ConversionID: 1, UserID: 7, Outcome: "completed", RetryCount: 1, DurationMs: 3000, EnqueuedAt: t0, RecordedAt: t0.Add(time.Minute)},
+		{ConversionID: 1, UserID: 7, Outcome: "retrying", RetryCount: 0, DurationMs: 1000, EnqueuedAt: t0, Priority: "high", RecordedAt: t0.Add(10 * time.Second)},
+		{ConversionID: 2, UserID: 8, Outcome: "failed", DurationMs: 2000, RecordedAt: t0.Add(-time.Minute)},
+	}
+
+	jobs := FromAudit(records)
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+
+	// Legacy record without timeline fields arrives when it started
+	if jobs[0].ConversionID != 2 || !jobs[0].Arrival.Equal(t0.Add(-time.Minute-2*time.Second)) || !jobs[0].Failed {
+		t.Fatalf("unexpected legacy job: %+v", jobs[0])
+	}
+
+	got := jobs[1]
+	if got.ConversionID != 1 || got.Priority != models.PriorityHigh || got.Failed || !got.Arrival.Equal(t0) {
+		t.Fatalf("unexpected job: %+v", got)
+	}
+	if len(got.Attempts) != 2 || got.Attempts[0] != time.Second || got.Attempts[1] != 3*time.Second {
+		t.Fatalf("expected attempts ordered by retry count, got %v", got.Attempts)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"converter/config"
+	"converter/models"
+	"converter/replay"
+	"converter/services"
+)
+
+// runReplay implements `converter replay`, which runs recorded audit history
+// through the current configuration and one or more proposed ones and
+// prints the estimated queue latencies side by side.
+func runReplay(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	auditPath := fs.String("audit", "", "file of audit records, one JSON object per line (\"-\" for stdin); reads conversion_audit_log when empty")
+	since := fs.Duration("since", 24*time.Hour, "how much audit history to read from the database")
+	instances := fs.Int("instances", 1, "number of converter instances; worker counts are per instance")
+	workers := fs.String("workers", "", "comma-separated worker counts per instance to evaluate (defaults to WORKER_COUNT)")
+	timeout := fs.Int("timeout", 0, "proposed per-attempt timeout in seconds (defaults to CONVERSION_TIMEOUT)")
+	tenantCap := fs.Int("tenant-cap", 0, "proposed maximum concurrent jobs per tenant (0 is unlimited)")
+	maxRetries := fs.Int("max-retries", cfg.MaxRetries, "retries before a job is failed")
+	fs.Parse(args)
+
+	records, err := loadAuditRecords(cfg, *auditPath, *since)
+	if err != nil {
+		return err
+	}
+	jobs := replay.FromAudit(records)
+	if len(jobs) == 0 {
+		return fmt.Errorf("no audit records to replay")
+	}
+	slog.Info("Replaying audit history", "records", len(records), "jobs", len(jobs))
+
+	weights := make(map[models.Priority]int)
+	for priority, value := range cfg.PriorityWeights {
+		if weight, err := strconv.Atoi(value); err == nil && weight >= 0 {
+			weights[models.Priority(priority)] = weight
+		}
+	}
+
+	proposedTimeout := cfg.ConversionTimeout
+	if *timeout > 0 {
+		proposedTimeout = *timeout
+	}
+
+	scenarios := []replay.Scenario{{
+		Name:       "current",
+		Workers:    cfg.WorkerCount * *instances,
+		Timeout:    time.Duration(cfg.ConversionTimeout) * time.Second,
+		MaxRetries: *maxRetries,
+		Weights:    weights,
+	}}
+	counts := []string{strconv.Itoa(cfg.WorkerCount)}
+	if *workers != "" {
+		counts = strings.Split(*workers, ",")
+	}
+	for _, count := range counts {
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid worker count %q", count)
+		}
+		scenarios = append(scenarios, replay.Scenario{
+			Name:       "proposed",
+			Workers:    n * *instances,
+			Timeout:    time.Duration(proposedTimeout) * time.Second,
+			TenantCap:  *tenantCap,
+			MaxRetries: *maxRetries,
+			Weights:    weights,
+		})
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCENARIO\tWORKERS\tTIMEOUT\tTENANT CAP\tCOMPLETED\tFAILED\tTIMED OUT\tWAIT P50\tWAIT P90\tWAIT P99\tWAIT MAX\tLATENCY P50\tLATENCY P99\tMAX DEPTH\tUTILIZATION")
+	for _, sc := range scenarios {
+		r := replay.Simulate(jobs, sc)
+		tenantCapLabel := "-"
+		if sc.TenantCap > 0 {
+			tenantCapLabel = strconv.Itoa(sc.TenantCap)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%.0f%%\n",
+			sc.Name, sc.Workers, sc.Timeout, tenantCapLabel,
+			r.Completed, r.Failed, r.TimedOut,
+			r.WaitP50.Round(time.Second), r.WaitP90.Round(time.Second), r.WaitP99.Round(time.Second), r.WaitMax.Round(time.Second),
+			r.LatencyP50.Round(time.Second), r.LatencyP99.Round(time.Second),
+			r.MaxQueueDepth, r.Utilization*100)
+	}
+	return tw.Flush()
+}
+
+// loadAuditRecords reads JSON-lines audit records from path, or the last
+// since of conversion_audit_log (on the read replica) when path is empty.
+func loadAuditRecords(cfg *config.Config, path string, since time.Duration) ([]services.AuditRecord, error) {
+	if path == "" {
+		dbSvc, err := services.NewDatabaseService(cfg.DatabaseURL, cfg.DatabaseReadURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer dbSvc.Close()
+		return dbSvc.AuditRecordsSince(context.Background(), time.Now().Add(-since))
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var records []services.AuditRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record services.AuditRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("failed to decode audit record on line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+
+	"converter/config"
+	"converter/services"
+)
+
+// requeueReportPoll is how often requeue-failed checks whether the
+// requeued conversions have settled.
+const requeueReportPoll = 15 * time.Second
+
+// runRequeueFailed implements `converter requeue-failed`, the bulk form of
+// the admin API's requeue for after an incident. It requeues the failed
+// jobs, records the batch in storage, waits up to --wait for them to run
+// again and writes a report of how they went next to the batch, posting
+// its summary to ALERT_WEBHOOK_URL when that is set.
+func runRequeueFailed(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("requeue-failed", flag.ExitOnError)
+	match := fs.String("match", "", "only requeue jobs whose last error contains this text")
+	limit := fs.Int("limit", 0, "requeue at most this many jobs (0 for all)")
+	userInitiated := fs.Bool("user-initiated", false, "requeue as user-initiated, which resets the retry count")
+	wait := fs.Duration("wait", 30*time.Minute, "how long to wait for the requeued jobs before reporting (0 reports at once)")
+	prefix := fs.String("prefix", "requeue-reports", "storage prefix the batch and report are written under")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	dbSvc, err := services.NewDatabaseService(cfg.DatabaseURL, "")
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer dbSvc.Close()
+	dbUpdater := services.NewStatusUpdater(dbSvc, cfg.DBUpdateQueueSize, cfg.DBUpdateMaxRetries)
+	go dbUpdater.Run()
+
+	redisClient, err := connectRedis(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	storage, err := services.NewStorage(ctx, cfg, services.NewS3RateLimiter(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to set up storage: %w", err)
+	}
+
+	admin := services.NewQueueAdmin(redisClient, cfg, dbUpdater)
+	batch := &services.RequeueBatch{StartedAt: time.Now().UTC(), Match: *match}
+	batch.ID = batch.StartedAt.Format("20060102T150405Z")
+	batch.Conversions, err = admin.RequeueFailed(ctx, services.FailedFilter{Match: *match, Limit: *limit}, *userInitiated)
+	// Drain the status updates before reporting, and record what was
+	// requeued even if the run stopped part way
+	dbUpdater.Close()
+	batchKey := path.Join(*prefix, batch.ID, "batch.json")
+	if writeErr := writeJSON(ctx, storage, batchKey, batch); writeErr != nil {
+		return errors.Join(err, writeErr)
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("Requeued failed conversions", "batch", batch.ID, "key", batchKey, "requeued", len(batch.Conversions))
+
+	report, err := admin.RequeueReport(ctx, batch)
+	for err == nil && !report.Settled && time.Since(batch.StartedAt) < *wait {
+		time.Sleep(requeueReportPoll)
+		report, err = admin.RequeueReport(ctx, batch)
+	}
+	if err != nil {
+		return err
+	}
+	return publishRequeueReport(ctx, cfg, storage, path.Join(*prefix, batch.ID), report)
+}
+
+// runRequeueReport implements `converter requeue-report`, which reports on
+// a batch written by requeue-failed again, e.g. when it was still running
+// when requeue-failed stopped waiting.
+func runRequeueReport(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("requeue-report", flag.ExitOnError)
+	key := fs.String("key", "", "storage key of the batch.json to report on (required)")
+	fs.Parse(args)
+
+	if *key == "" {
+		return errors.New("--key is required")
+	}
+
+	ctx := context.Background()
+	storage, err := services.NewStorage(ctx, cfg, services.NewS3RateLimiter(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to set up storage: %w", err)
+	}
+	body, err := storage.Open(ctx, *key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	var batch services.RequeueBatch
+	if err := json.NewDecoder(body).Decode(&batch); err != nil {
+		return fmt.Errorf("failed to read requeue batch: %w", err)
+	}
+
+	redisClient, err := connectRedis(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	report, err := services.NewQueueAdmin(redisClient, cfg, nil).RequeueReport(ctx, &batch)
+	if err != nil {
+		return err
+	}
+	return publishRequeueReport(ctx, cfg, storage, path.Dir(*key), report)
+}
+
+// publishRequeueReport writes the report next to its batch and posts its
+// summary to the alert channel. A failed post is logged, since the report
+// itself is already in storage.
+func publishRequeueReport(ctx context.Context, cfg *config.Config, storage services.Storage, dir string, report *services.RequeueReport) error {
+	key := path.Join(dir, "report.json")
+	if err := writeJSON(ctx, storage, key, report); err != nil {
+		return err
+	}
+	slog.Info("Requeue report written", "key", key, "requeued", report.Requeued,
+		"outcomes", report.Outcomes, "settled", report.Settled)
+
+	if cfg.AlertWebhookURL == "" {
+		return nil
+	}
+	if err := services.PostAlert(ctx, cfg.AlertWebhookURL, report.Summary()+"\n"+key); err != nil {
+		slog.Warn("Failed to post requeue report", "error", err)
+	}
+	return nil
+}
+
+// writeJSON writes v to storage as indented JSON.
+func writeJSON(ctx context.Context, storage services.Storage, key string, v interface{}) error {
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", key, err)
+	}
+	return storage.UploadStream(ctx, bytes.NewReader(body), key, "application/json")
+}
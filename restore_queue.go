@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"converter/config"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// runRestoreQueue implements `converter restore-queue`, which puts back
+// jobs that conversion_queue_mirror still lists as unfinished but that are
+// in no Redis queue, e.g. after Redis evicted part of the backlog. Workers
+// should be stopped first, or a job finishing during the run may be
+// restored and converted twice.
+func runRestoreQueue(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("restore-queue", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "list the jobs that would be restored without queueing them")
+	fs.Parse(args)
+
+	dbSvc, err := services.NewDatabaseService(cfg.DatabaseURL, "")
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer dbSvc.Close()
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	// Read the mirror before Redis, so a job claimed in between is seen
+	// in processing rather than missing
+	mirrored, err := dbSvc.UnfinishedMirroredJobs(ctx)
+	if err != nil {
+		return err
+	}
+	queued, err := services.NewQueueAdmin(redisClient, cfg, nil).Queued(ctx)
+	if err != nil {
+		return err
+	}
+
+	jobQueue := services.NewJobQueue(redisClient, cfg.QueueBackend, cfg.StreamGroup)
+	restored := 0
+	for _, job := range mirrored {
+		if _, ok := queued[job.ConversionID]; ok {
+			continue
+		}
+		if *dryRun {
+			slog.Info("Would restore conversion", "conversion_id", job.ConversionID, "queue", job.Queue)
+			restored++
+			continue
+		}
+		if err := jobQueue.Push(ctx, job.Queue, job.Payload); err != nil {
+			return fmt.Errorf("failed to restore conversion %d: %w", job.ConversionID, err)
+		}
+		slog.Info("Restored conversion", "conversion_id", job.ConversionID, "queue", job.Queue)
+		restored++
+	}
+
+	slog.Info("Queue restore finished", "mirrored", len(mirrored), "restored", restored, "dry_run", *dryRun)
+	return nil
+}
@@ -0,0 +1,128 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type WindowMode string
+
+const (
+	// ModePause stops consumption entirely.
+	ModePause WindowMode = "pause"
+	// ModePriorityOnly only consumes the priority queues, leaving bulk lanes.
+	ModePriorityOnly WindowMode = "priority-only"
+)
+
+// Window is a recurring maintenance window starting at every minute matched
+// by Cron and lasting Duration.
+type Window struct {
+	Cron     *Cron
+	Duration time.Duration
+	Mode     WindowMode
+	Spec     string
+}
+
+// ParseWindows parses "cron|duration|mode" entries separated by ";", e.g.
+// "0 2 * * 0|2h|pause;30 1 * * *|30m|priority-only".
+func ParseWindows(spec string) ([]Window, error) {
+	var windows []Window
+
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, "|")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("maintenance window %q must be cron|duration|mode", entry)
+		}
+
+		cron, err := ParseCron(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %q: %w", entry, err)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("maintenance window %q: invalid duration", entry)
+		}
+		mode := WindowMode(strings.TrimSpace(parts[2]))
+		if mode != ModePause && mode != ModePriorityOnly {
+			return nil, fmt.Errorf("maintenance window %q: unknown mode %q", entry, mode)
+		}
+
+		windows = append(windows, Window{Cron: cron, Duration: duration, Mode: mode, Spec: entry})
+	}
+
+	return windows, nil
+}
+
+// Active reports whether t falls inside an occurrence of the window.
+func (w Window) Active(t time.Time) bool {
+	start := t.Truncate(time.Minute)
+	for offset := time.Duration(0); offset < w.Duration; offset += time.Minute {
+		if w.Cron.Matches(start.Add(-offset)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveMode returns the most restrictive mode among windows active at t,
+// or "" when none is.
+func ActiveMode(windows []Window, t time.Time) WindowMode {
+	var mode WindowMode
+	for _, w := range windows {
+		if !w.Active(t) {
+			continue
+		}
+		if w.Mode == ModePause {
+			return ModePause
+		}
+		mode = w.Mode
+	}
+	return mode
+}
+
+// ParsePeriods parses "cron|duration" entries separated by ";", e.g.
+// "0 9 * * 1-5|8h", for recurring periods that carry no mode.
+func ParsePeriods(spec string) ([]Window, error) {
+	var windows []Window
+
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, "|")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("window %q must be cron|duration", entry)
+		}
+
+		cron, err := ParseCron(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", entry, err)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("window %q: invalid duration", entry)
+		}
+
+		windows = append(windows, Window{Cron: cron, Duration: duration, Spec: entry})
+	}
+
+	return windows, nil
+}
+
+// AnyActive reports whether t falls inside any of the windows.
+func AnyActive(windows []Window, t time.Time) bool {
+	for _, w := range windows {
+		if w.Active(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Matches(t *testing.T) {
+	t.Parallel()
+
+	cron, err := ParseCron("*/15 2-4 * * 0,6")
+	if err != nil {
+		t.Fatalf("ParseCron failed: %v", err)
+	}
+
+	// 2024-06-01 is a Saturday
+	if !cron.Matches(time.Date(2024, 6, 1, 3, 45, 0, 0, time.UTC)) {
+		t.Error("expected Saturday 03:45 to match")
+	}
+	if cron.Matches(time.Date(2024, 6, 1, 3, 44, 0, 0, time.UTC)) {
+		t.Error("expected 03:44 not to match */15")
+	}
+	if cron.Matches(time.Date(2024, 6, 3, 3, 45, 0, 0, time.UTC)) {
+		t.Error("expected Monday not to match")
+	}
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestWindows_ActiveMode(t *testing.T) {
+	t.Parallel()
+
+	windows, err := ParseWindows("0 2 * * *|2h|pause; 0 1 * * *|90m|priority-only")
+	if err != nil {
+		t.Fatalf("ParseWindows failed: %v", err)
+	}
+
+	at := func(h, m int) time.Time { return time.Date(2024, 6, 1, h, m, 0, 0, time.UTC) }
+
+	cases := map[time.Time]WindowMode{
+		at(0, 59): "",
+		at(1, 15): ModePriorityOnly,
+		at(2, 15): ModePause,
+		at(3, 59): ModePause,
+		at(4, 0):  "",
+	}
+	for ts, want := range cases {
+		if got := ActiveMode(windows, ts); got != want {
+			t.Errorf("at %s expected %q, got %q", ts.Format("15:04"), want, got)
+		}
+	}
+
+	if _, err := ParseWindows("0 2 * * *|2h|nap"); err == nil {
+		t.Error("expected unknown mode to be rejected")
+	}
+}
+
+func TestPeriods_AnyActive(t *testing.T) {
+	t.Parallel()
+
+	windows, err := ParsePeriods("0 9 * * 1-5|8h; 0 20 * * *|1h")
+	if err != nil {
+		t.Fatalf("ParsePeriods failed: %v", err)
+	}
+
+	// 2024-06-03 is a Monday, 2024-06-01 a Saturday
+	cases := map[time.Time]bool{
+		time.Date(2024, 6, 3, 8, 59, 0, 0, time.UTC):  false,
+		time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC):  true,
+		time.Date(2024, 6, 3, 17, 0, 0, 0, time.UTC):  false,
+		time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC):  false,
+		time.Date(2024, 6, 1, 20, 30, 0, 0, time.UTC): true,
+	}
+	for ts, want := range cases {
+		if got := AnyActive(windows, ts); got != want {
+			t.Errorf("at %s expected %v, got %v", ts.Format("Mon 15:04"), want, got)
+		}
+	}
+
+	for _, bad := range []string{"0 9 * * 1-5|8h|pause", "0 9 * * 1-5|soon"} {
+		if _, err := ParsePeriods(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"converter/models"
+)
+
+// inspectLimit bounds how much of any one package part is read.
+const inspectLimit = 16 << 20
+
+var mediaExtensions = map[string]bool{
+	".mp4": true, ".m4v": true, ".mov": true, ".avi": true, ".wmv": true, ".mpg": true, ".mpeg": true,
+	".mp3": true, ".wav": true, ".m4a": true, ".wma": true, ".ogg": true,
+}
+
+var (
+	docxFontPattern   = regexp.MustCompile(`<w:font w:name="([^"]+)"`)
+	xlsxFontPattern   = regexp.MustCompile(`<name val="([^"]+)"`)
+	pptxFontPattern   = regexp.MustCompile(`<a:(?:latin|ea|cs) typeface="([^"+][^"]*)"`)
+	odfFontPattern    = regexp.MustCompile(`<style:font-face style:name="([^"]+)"`)
+	sheetPattern      = regexp.MustCompile(`<sheet [^>]*name="([^"]+)"[^>]*>`)
+	sheetStatePattern = regexp.MustCompile(`state="(hidden|veryHidden)"`)
+	printAreaPattern  = regexp.MustCompile(`<definedName [^>]*name="_xlnm\.Print_Area"[^>]*localSheetId="(\d+)"|<definedName [^>]*localSheetId="(\d+)"[^>]*name="_xlnm\.Print_Area"`)
+)
+
+// SourceInspection is what the source document holds that may not survive
+// conversion.
+type SourceInspection struct {
+	Annotations []models.Annotation
+	// Fonts are the font families the document asks for, to compare with
+	// the ones embedded in the output.
+	Fonts []string
+}
+
+// InspectSource looks inside Office and OpenDocument files for content the
+// PDF can't carry. Other formats yield an empty inspection.
+func InspectSource(localPath string, extension string) (*SourceInspection, error) {
+	inspection := &SourceInspection{}
+	switch extension {
+	case "docx", "docm", "dotx", "dotm", "xlsx", "xlsm", "xltx", "xltm", "pptx", "pptm", "ppsx", "potx",
+		"odt", "ott", "ods", "ots", "odp", "otp":
+		r, err := zip.OpenReader(localPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open package: %w", err)
+		}
+		defer r.Close()
+		inspection.inspectPackage(r.File)
+	case "doc", "dot", "xls", "xlt", "ppt", "pps", "pot":
+		f, err := os.Open(localPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, cfbScanLimit))
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(data, utf16le("_VBA_PROJECT")) {
+			inspection.add(models.AnnotationMacrosDropped, "Macros are not carried into the PDF")
+		}
+	}
+	return inspection, nil
+}
+
+func (s *SourceInspection) add(code models.AnnotationCode, message string) {
+	s.Annotations = append(s.Annotations, models.Annotation{Code: code, Message: message})
+}
+
+func (s *SourceInspection) inspectPackage(files []*zip.File) {
+	var macros, comments bool
+	var media int
+	fonts := make(map[string]bool)
+	var workbook []byte
+
+	for _, f := range files {
+		name := f.Name
+		switch {
+		case strings.HasSuffix(name, "vbaProject.bin"), strings.HasPrefix(name, "Basic/"):
+			macros = true
+		case name == "word/comments.xml", strings.HasPrefix(name, "xl/comments"), strings.HasPrefix(name, "ppt/comments/"):
+			comments = true
+		case mediaExtensions[strings.ToLower(path.Ext(name))]:
+			media++
+		case name == "word/fontTable.xml":
+			collect(fonts, docxFontPattern, readPart(f))
+		case name == "xl/styles.xml":
+			collect(fonts, xlsxFontPattern, readPart(f))
+		case name == "xl/workbook.xml":
+			workbook = readPart(f)
+		case strings.HasPrefix(name, "ppt/theme/"), strings.HasPrefix(name, "ppt/slides/slide"):
+			collect(fonts, pptxFontPattern, readPart(f))
+		case name == "content.xml", name == "styles.xml":
+			part := readPart(f)
+			collect(fonts, odfFontPattern, part)
+			if name == "content.xml" && bytes.Contains(part, []byte("<office:annotation")) {
+				comments = true
+			}
+		}
+	}
+
+	if macros {
+		s.add(models.AnnotationMacrosDropped, "Macros are not carried into the PDF")
+	}
+	if comments {
+		s.add(models.AnnotationCommentsOmitted, "Comments are not included in the PDF")
+	}
+	if media > 0 {
+		s.add(models.AnnotationMediaDropped, fmt.Sprintf("%d audio or video file(s) can't be played in the PDF", media))
+	}
+	if workbook != nil {
+		s.inspectWorkbook(workbook)
+	}
+
+	for font := range fonts {
+		s.Fonts = append(s.Fonts, font)
+	}
+	sort.Strings(s.Fonts)
+}
+
+// inspectWorkbook reports hidden sheets, which aren't printed, and sheets
+// with a print area, of which only that range is printed.
+func (s *SourceInspection) inspectWorkbook(workbook []byte) {
+	var names, hidden []string
+	for _, m := range sheetPattern.FindAllSubmatch(workbook, -1) {
+		names = append(names, string(m[1]))
+		if sheetStatePattern.Match(m[0]) {
+			hidden = append(hidden, string(m[1]))
+		}
+	}
+	if len(hidden) > 0 {
+		s.add(models.AnnotationHiddenSheets, "Hidden sheets are not included: "+strings.Join(hidden, ", "))
+	}
+
+	var limited []string
+	for _, m := range printAreaPattern.FindAllSubmatch(workbook, -1) {
+		index, _ := strconv.Atoi(string(m[1]) + string(m[2]))
+		if index < len(names) {
+			limited = append(limited, names[index])
+		}
+	}
+	if len(limited) > 0 {
+		s.add(models.AnnotationPrintArea, "Only the print area is included for sheets: "+strings.Join(limited, ", "))
+	}
+}
+
+func readPart(f *zip.File) []byte {
+	rc, err := f.Open()
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(io.LimitReader(rc, inspectLimit))
+	return data
+}
+
+func collect(set map[string]bool, pattern *regexp.Regexp, data []byte) {
+	for _, m := range pattern.FindAllSubmatch(data, -1) {
+		set[string(m[1])] = true
+	}
+}
+
+// FontSubstitution compares the fonts a document asks for with those
+// embedded in its PDF. Documents declare fonts they never use, so a missing
+// font alone proves nothing; it was substituted only when the output also
+// embeds fonts the document never asked for. It returns the missing and the
+// replacement fonts, or nil when nothing was substituted.
+func FontSubstitution(requested []string, embedded []string) (missing []string, replacements []string) {
+	requestedKeys := make([]string, len(requested))
+	for i, font := range requested {
+		requestedKeys[i] = fontKey(font)
+	}
+
+	found := make(map[int]bool)
+	seen := make(map[string]bool)
+	for _, font := range embedded {
+		key := fontKey(font)
+		matched := false
+		for i, want := range requestedKeys {
+			if want != "" && strings.HasPrefix(key, want) {
+				found[i] = true
+				matched = true
+			}
+		}
+		// LibreOffice draws bullets and symbols with its own font
+		if !matched && !strings.HasPrefix(key, "opensymbol") && !seen[key] {
+			seen[key] = true
+			replacements = append(replacements, stripSubset(font))
+		}
+	}
+	if len(replacements) == 0 {
+		return nil, nil
+	}
+	for i, font := range requested {
+		if !found[i] {
+			missing = append(missing, font)
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	return missing, replacements
+}
+
+// fontKey normalizes a font name for comparison: subset prefixes and
+// spacing are dropped, so "ABCDEF+TimesNewRomanPSMT" matches the family
+// "Times New Roman".
+func fontKey(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(stripSubset(name)))
+}
+
+// stripSubset drops the six-letter tag PDF writers prefix to subset fonts.
+func stripSubset(name string) string {
+	if i := strings.Index(name, "+"); i == 6 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"converter/models"
+)
+
+func writePackage(t *testing.T, name string, parts map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for partName, content := range parts {
+		w, err := zw.Create(partName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func codes(annotations []models.Annotation) []models.AnnotationCode {
+	var out []models.AnnotationCode
+	for _, a := range annotations {
+		out = append(out, a.Code)
+	}
+	return out
+}
+
+func TestInspectSourceDocument(t *testing.T) {
+	t.Parallel()
+
+	path := writePackage(t, "report.docm", map[string]string{
+		"word/document.xml":   `<w:document/>`,
+		"word/vbaProject.bin": "macro",
+		"word/comments.xml":   `<w:comments/>`,
+		"word/media/clip.mp4": "video",
+		"word/media/logo.png": "image",
+		"word/fontTable.xml":  `<w:fonts><w:font w:name="Calibri"><w:panose1/></w:font><w:font w:name="Times New Roman"/></w:fonts>`,
+	})
+
+	inspection, err := InspectSource(path, "docm")
+	if err != nil {
+		t.Fatalf("InspectSource() error = %v", err)
+	}
+	want := []models.AnnotationCode{models.AnnotationMacrosDropped, models.AnnotationCommentsOmitted, models.AnnotationMediaDropped}
+	if got := codes(inspection.Annotations); !reflect.DeepEqual(got, want) {
+		t.Errorf("annotations = %v, want %v", got, want)
+	}
+	if want := []string{"Calibri", "Times New Roman"}; !reflect.DeepEqual(inspection.Fonts, want) {
+		t.Errorf("fonts = %v, want %v", inspection.Fonts, want)
+	}
+}
+
+func TestInspectSourceWorkbook(t *testing.T) {
+	t.Parallel()
+
+	path := writePackage(t, "budget.xlsx", map[string]string{
+		"xl/workbook.xml": `<workbook><sheets>
+			<sheet name="Summary" sheetId="1" r:id="rId1"/>
+			<sheet name="Lookup" sheetId="2" state="veryHidden" r:id="rId2"/>
+			<sheet name="Detail" sheetId="3" r:id="rId3"/>
+		</sheets><definedNames>
+			<definedName name="_xlnm.Print_Area" localSheetId="2">Detail!$A$1:$F$40</definedName>
+		</definedNames></workbook>`,
+	})
+
+	inspection, err := InspectSource(path, "xlsx")
+	if err != nil {
+		t.Fatalf("InspectSource() error = %v", err)
+	}
+	want := []models.Annotation{
+		{Code: models.AnnotationHiddenSheets, Message: "Hidden sheets are not included: Lookup"},
+		{Code: models.AnnotationPrintArea, Message: "Only the print area is included for sheets: Detail"},
+	}
+	if !reflect.DeepEqual(inspection.Annotations, want) {
+		t.Errorf("annotations = %v, want %v", inspection.Annotations, want)
+	}
+}
+
+func TestInspectSourcePlainFormats(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	inspection, err := InspectSource(path, "txt")
+	if err != nil || len(inspection.Annotations) != 0 {
+		t.Errorf("InspectSource(txt) = %v, %v, want no annotations", inspection, err)
+	}
+
+	if _, err := InspectSource(path, "docx"); err == nil {
+		t.Error("InspectSource() on a corrupt package returned no error")
+	}
+}
+
+func TestFontSubstitution(t *testing.T) {
+	t.Parallel()
+
+	requested := []string{"Calibri", "Times New Roman", "Wingdings"}
+
+	// Unused declared fonts alone are not a substitution
+	missing, _ := FontSubstitution(requested, []string{"BAAAAA+TimesNewRomanPSMT", "CAAAAA+OpenSymbol"})
+	if missing != nil {
+		t.Errorf("missing = %v, want none", missing)
+	}
+
+	missing, replacements := FontSubstitution(requested, []string{"BAAAAA+Carlito", "CAAAAA+TimesNewRomanPSMT"})
+	if want := []string{"Calibri", "Wingdings"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("missing = %v, want %v", missing, want)
+	}
+	if want := []string{"Carlito"}; !reflect.DeepEqual(replacements, want) {
+		t.Errorf("replacements = %v, want %v", replacements, want)
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"converter/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// AuditRecord is an immutable description of what a conversion read, wrote
+// and with which engine, kept as evidence for compliance audits.
+type AuditRecord struct {
+	ConversionID int               `json:"conversionId"`
+	FileID       int               `json:"fileId"`
+	FileGUID     string            `json:"fileGuid"`
+	UserID       int               `json:"userId"`
+	Outcome      string            `json:"outcome"`
+	Engine       string            `json:"engine"`
+	WorkerID     int               `json:"workerId"`
+	InputS3Path  string            `json:"inputS3Path"`
+	InputS3Paths []string          `json:"inputS3Paths,omitempty"`
+	InputSHA256  string            `json:"inputSha256,omitempty"`
+	OutputS3Path string            `json:"outputS3Path,omitempty"`
+	OutputSHA256 string            `json:"outputSha256,omitempty"`
+	Artifacts    map[string]string `json:"artifacts,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	RetryCount   int               `json:"retryCount"`
+	Priority     string            `json:"priority,omitempty"`
+	EnqueuedAt   time.Time         `json:"enqueuedAt"`
+	StartedAt    time.Time         `json:"startedAt"`
+	DurationMs   int64             `json:"durationMs,omitempty"`
+	RecordedAt   time.Time         `json:"recordedAt"`
+}
+
+// AuditService appends audit records to the database and, when configured,
+// to an S3 bucket with object lock so entries can't be altered afterwards.
+type AuditService struct {
+	db            *DatabaseService
+	s3Svc         *S3Service
+	bucket        string
+	prefix        string
+	lockMode      string
+	retentionDays int
+}
+
+func NewAuditService(cfg *config.Config, db *DatabaseService, s3Svc *S3Service) *AuditService {
+	return &AuditService{
+		db:            db,
+		s3Svc:         s3Svc,
+		bucket:        cfg.AuditS3Bucket,
+		prefix:        cfg.AuditS3Prefix,
+		lockMode:      cfg.AuditObjectLockMode,
+		retentionDays: cfg.AuditRetentionDays,
+	}
+}
+
+func (a *AuditService) Record(ctx context.Context, record *AuditRecord) error {
+	record.RecordedAt = time.Now().UTC()
+
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	if err := a.db.InsertAuditRecord(ctx, record.ConversionID, record.Outcome, payload); err != nil {
+		return fmt.Errorf("failed to insert audit record: %w", err)
+	}
+
+	if a.bucket == "" {
+		return nil
+	}
+
+	key := path.Join(a.prefix, record.RecordedAt.Format("2006/01/02"),
+		fmt.Sprintf("%d-%s-%d.json", record.ConversionID, record.Outcome, record.RecordedAt.UnixNano()))
+
+	sum := md5.Sum(payload)
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(a.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(payload),
+		ContentType: aws.String("application/json"),
+		ContentMD5:  aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+	}
+	if a.lockMode != "" && a.retentionDays > 0 {
+		input.ObjectLockMode = types.ObjectLockMode(a.lockMode)
+		input.ObjectLockRetainUntilDate = aws.Time(record.RecordedAt.AddDate(0, 0, a.retentionDays))
+	}
+
+	if _, err := a.s3Svc.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to write audit record to S3: %w", err)
+	}
+	return nil
+}
+
+// FileSHA256 returns the hex SHA-256 of a local file.
+func FileSHA256(localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
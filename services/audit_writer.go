@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+)
+
+// auditWriteTimeout bounds one record's database insert and S3 put.
+const auditWriteTimeout = 30 * time.Second
+
+// AuditWriter records audit entries from a single background goroutine, so
+// a slow database or audit bucket never holds up a conversion. Records are
+// written in the order they are queued.
+type AuditWriter struct {
+	audit   *AuditService
+	records chan *AuditRecord
+	done    chan struct{}
+	// mu guards closed: senders hold it for reading, so Close can't close
+	// records under a send.
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewAuditWriter(audit *AuditService, queueSize int) *AuditWriter {
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	return &AuditWriter{
+		audit:   audit,
+		records: make(chan *AuditRecord, queueSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// Record queues a copy of the record, so the caller can go on filling in
+// its own for the next outcome. A record that arrives after Close is
+// logged and dropped.
+func (w *AuditWriter) Record(record *AuditRecord) {
+	entry := *record
+	entry.Artifacts = maps.Clone(record.Artifacts)
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		slog.Warn("Audit writer closed, dropping record", "component", "audit", "conversion_id", entry.ConversionID, "outcome", entry.Outcome)
+		return
+	}
+
+	select {
+	case w.records <- &entry:
+	default:
+		slog.Warn("Audit queue full, waiting to enqueue", "component", "audit", "conversion_id", entry.ConversionID, "outcome", entry.Outcome)
+		w.records <- &entry
+	}
+}
+
+// Run writes queued records until Close is called and the queue is
+// drained.
+func (w *AuditWriter) Run() {
+	defer close(w.done)
+
+	for record := range w.records {
+		ctx, cancel := context.WithTimeout(context.Background(), auditWriteTimeout)
+		err := w.audit.Record(ctx, record)
+		cancel()
+		if err != nil {
+			slog.Error("Failed to record audit entry", "component", "audit", "conversion_id", record.ConversionID, "outcome", record.Outcome, "error", err)
+		}
+	}
+}
+
+// Close stops accepting records and waits for the queue to drain.
+func (w *AuditWriter) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.records)
+	}
+	w.mu.Unlock()
+	<-w.done
+}
@@ -0,0 +1,27 @@
+package services
+
+import "testing"
+
+func TestAuditWriter_Record(t *testing.T) {
+	t.Parallel()
+
+	w := NewAuditWriter(nil, 10)
+	record := &AuditRecord{ConversionID: 7, Outcome: "retrying", Artifacts: map[string]string{"thumbnail": "a.png"}}
+	w.Record(record)
+
+	// The worker goes on filling in its record for the next outcome
+	record.Outcome = "completed"
+	record.Artifacts["thumbnail"] = "b.png"
+
+	queued := <-w.records
+	if queued.Outcome != "retrying" || queued.Artifacts["thumbnail"] != "a.png" {
+		t.Errorf("queued record = %+v, want the record as it was when queued", queued)
+	}
+
+	// No records are left, so there is nothing to write
+	close(w.done)
+	w.Close()
+
+	// A late record must not panic with a send on the closed channel
+	w.Record(record)
+}
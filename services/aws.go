@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"converter/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// NewAWSConfig loads the configuration shared by the S3, SQS, SNS and KMS
+// clients. Static keys are used when S3_KEY/S3_SECRET (or AWS_ACCESS_KEY_ID/
+// AWS_SECRET_ACCESS_KEY) are set; otherwise the SDK's default chain finds
+// credentials, such as an EKS service account (IRSA), an ECS task role or
+// the instance profile.
+func NewAWSConfig(ctx context.Context, cfg *config.Config) (aws.Config, error) {
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(cfg.S3Region),
+	}
+
+	switch aws.RetryMode(cfg.AWSRetryMode) {
+	case aws.RetryModeStandard, aws.RetryModeAdaptive:
+		opts = append(opts, awsconfig.WithRetryMode(aws.RetryMode(cfg.AWSRetryMode)))
+	default:
+		return aws.Config{}, fmt.Errorf("unknown AWS_RETRY_MODE %q", cfg.AWSRetryMode)
+	}
+	if cfg.AWSMaxAttempts > 0 {
+		opts = append(opts, awsconfig.WithRetryMaxAttempts(cfg.AWSMaxAttempts))
+	}
+
+	if cfg.AWSS3AccessKey != "" && cfg.AWSS3SecretKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AWSS3AccessKey, cfg.AWSS3SecretKey, cfg.AWSSessionToken)))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	NewRequestIdentity(cfg).applyToAWS(&awsCfg)
+	return awsCfg, nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"converter/metrics"
+)
+
+func init() {
+	metrics.Describe("conversion_circuit_state", "State of the circuit breaker around a dependency (0 closed, 1 half-open, 2 open)")
+	metrics.Describe("conversion_circuit_transitions_total", "Circuit breaker state changes, by dependency and new state")
+}
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitHalfOpen = "half-open"
+	CircuitOpen     = "open"
+)
+
+var circuitGauge = map[string]int64{CircuitClosed: 0, CircuitHalfOpen: 1, CircuitOpen: 2}
+
+// Breaker is a circuit breaker around a dependency. threshold consecutive
+// Failed calls open it; after cooldown it is half-open and Allow lets one
+// caller through at a time to probe the dependency, whose next Succeeded
+// closes it again and whose next Failed reopens it.
+type Breaker struct {
+	mu        sync.Mutex
+	name      string
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	probedAt  time.Time
+	now       func() time.Time
+}
+
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	b := &Breaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     CircuitClosed,
+		now:       time.Now,
+	}
+	metrics.Set("conversion_circuit_state", 0, "dependency", name)
+	return b
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the breaker's state, moving an open breaker whose cooldown
+// has passed to half-open.
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cooledDown()
+	return b.state
+}
+
+// Allow reports whether work that depends on the dependency may start. A
+// half-open breaker allows one probe per cooldown, so a probe that never
+// reaches the dependency doesn't keep it half-open forever.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cooledDown()
+
+	switch b.state {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if now := b.now(); b.probedAt.IsZero() || now.Sub(b.probedAt) >= b.cooldown {
+			b.probedAt = now
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Breaker) Succeeded() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	if b.state != CircuitClosed {
+		b.transition(CircuitClosed)
+	}
+}
+
+func (b *Breaker) Failed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cooledDown()
+
+	b.failures++
+	switch {
+	case b.state == CircuitHalfOpen:
+		b.transition(CircuitOpen)
+	case b.state == CircuitOpen:
+		// Calls still in flight keep it open from now
+		b.openedAt = b.now()
+	case b.failures >= b.threshold:
+		b.transition(CircuitOpen)
+	}
+}
+
+func (b *Breaker) cooledDown() {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.transition(CircuitHalfOpen)
+	}
+}
+
+func (b *Breaker) transition(state string) {
+	from := b.state
+	b.state = state
+	switch state {
+	case CircuitOpen:
+		b.openedAt = b.now()
+	case CircuitHalfOpen:
+		b.probedAt = time.Time{}
+	}
+
+	metrics.Set("conversion_circuit_state", circuitGauge[state], "dependency", b.name)
+	metrics.Inc("conversion_circuit_transitions_total", "dependency", b.name, "state", state)
+	if state == CircuitOpen {
+		slog.Warn("Circuit breaker opened", "component", "breaker", "dependency", b.name, "from", from,
+			"consecutive_failures", b.failures, "cooldown", b.cooldown.String())
+	} else {
+		slog.Info("Circuit breaker state changed", "component", "breaker", "dependency", b.name, "from", from, "to", state)
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreaker_OpensAndProbes(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	breaker := NewBreaker("test-opens", 3, 30*time.Second)
+	breaker.now = func() time.Time { return now }
+
+	breaker.Failed()
+	breaker.Failed()
+	breaker.Succeeded()
+	breaker.Failed()
+	breaker.Failed()
+	if !breaker.Allow() {
+		t.Fatal("expected a success to reset the consecutive failures")
+	}
+	breaker.Failed()
+	if breaker.Allow() || breaker.State() != CircuitOpen {
+		t.Fatalf("expected the third consecutive failure to open the breaker, got %s", breaker.State())
+	}
+
+	now = now.Add(30 * time.Second)
+	if state := breaker.State(); state != CircuitHalfOpen {
+		t.Fatalf("expected half-open after the cooldown, got %s", state)
+	}
+	if !breaker.Allow() {
+		t.Fatal("expected the half-open breaker to allow a probe")
+	}
+	if breaker.Allow() {
+		t.Fatal("expected only one probe per cooldown")
+	}
+
+	breaker.Failed()
+	if state := breaker.State(); state != CircuitOpen {
+		t.Fatalf("expected a failed probe to reopen the breaker, got %s", state)
+	}
+
+	now = now.Add(30 * time.Second)
+	if !breaker.Allow() {
+		t.Fatal("expected a new probe after another cooldown")
+	}
+	breaker.Succeeded()
+	if state := breaker.State(); state != CircuitClosed || !breaker.Allow() {
+		t.Fatalf("expected a successful probe to close the breaker, got %s", state)
+	}
+}
+
+func TestGotenbergService_ReportsToBreaker(t *testing.T) {
+	t.Parallel()
+
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	breaker := NewBreaker("test-gotenberg", 2, time.Minute)
+	svc := NewGotenbergService(server.URL, 0, RequestIdentity{})
+	svc.SetBreaker(breaker)
+	send := func() {
+		svc.send(context.Background(), "/forms/libreoffice/convert", strings.NewReader(""), "text/plain")
+	}
+
+	send()
+	status.Store(http.StatusBadRequest)
+	send()
+	status.Store(http.StatusServiceUnavailable)
+	send()
+	if state := breaker.State(); state != CircuitClosed {
+		t.Fatalf("expected a 400 to count as Gotenberg being up, got %s", state)
+	}
+	send()
+	if state := breaker.State(); state != CircuitOpen {
+		t.Fatalf("expected consecutive 503s to open the breaker, got %s", state)
+	}
+}
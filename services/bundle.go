@@ -0,0 +1,90 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const bundleManifestName = "manifest.json"
+
+type BundleEntry struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	LocalPath   string `json:"-"`
+}
+
+type BundleManifest struct {
+	ConversionID int           `json:"conversionId"`
+	FileGUID     string        `json:"fileGuid"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	Entries      []BundleEntry `json:"entries"`
+}
+
+// WriteBundle packages the manifest's entries into a ZIP at zipPath, with
+// manifest.json written last so it carries every entry's size and checksum.
+func WriteBundle(zipPath string, manifest *BundleManifest) error {
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+
+	for i := range manifest.Entries {
+		entry := &manifest.Entries[i]
+		if entry.Name == bundleManifestName {
+			return fmt.Errorf("bundle entry name %q is reserved", entry.Name)
+		}
+		if err := addBundleEntry(zw, entry); err != nil {
+			return err
+		}
+	}
+
+	w, err := zw.Create(bundleManifestName)
+	if err != nil {
+		return fmt.Errorf("failed to add manifest: %w", err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize bundle: %w", err)
+	}
+	return nil
+}
+
+func addBundleEntry(zw *zip.Writer, entry *BundleEntry) error {
+	in, err := os.Open(entry.LocalPath)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle entry %s: %w", entry.Name, err)
+	}
+	defer in.Close()
+
+	w, err := zw.Create(entry.Name)
+	if err != nil {
+		return fmt.Errorf("failed to add bundle entry %s: %w", entry.Name, err)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), in)
+	if err != nil {
+		return fmt.Errorf("failed to write bundle entry %s: %w", entry.Name, err)
+	}
+
+	entry.Size = size
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// JobSchemaVersion is the version of the job payload this converter reads.
+// It changes only when a field changes meaning or a new field must be
+// understood for a job to convert correctly; optional fields are announced
+// as features instead.
+const JobSchemaVersion = 1
+
+// Features a deployment can announce in its capabilities.
+const (
+	FeatureAccessible      = "accessible"
+	FeatureFlatten         = "flatten"
+	FeatureSplit           = "split"
+	FeatureMerge           = "merge"
+	FeatureEmail           = "email"
+	FeatureHTMLAssets      = "html_assets"
+	FeaturePrintTemplates  = "print_templates"
+	FeatureOutputs         = "outputs"
+	FeatureBundle          = "bundle"
+	FeatureThumbnails      = "thumbnails"
+	FeatureEncryption      = "encryption"
+	FeatureRetention       = "retention"
+	FeatureFormatDetection = "format_detection"
+	FeatureVirusScan       = "virus_scan"
+	FeatureCallbacks       = "callbacks"
+	FeatureDeadlines       = "deadlines"
+	FeatureTrash           = "trash"
+	FeaturePassword        = "password"
+)
+
+// Capabilities describe what a deployment accepts, so producers can
+// feature-detect instead of assuming.
+type Capabilities struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Version       string `json:"version"`
+	// Extensions are the accepted input extensions; empty accepts any.
+	Extensions       []string `json:"extensions"`
+	JobTypes         []string `json:"jobTypes"`
+	JobKinds         []string `json:"jobKinds"`
+	Features         []string `json:"features"`
+	GotenbergVersion int      `json:"gotenbergVersion"`
+	PDFAConformance  string   `json:"pdfaConformance"`
+	RetentionClasses []string `json:"retentionClasses,omitempty"`
+	// InputSources are the URI schemes merge parts may be named with.
+	InputSources []string `json:"inputSources,omitempty"`
+	// MaxInputBytes is CONVERSION_MAX_INPUT_BYTES, 0 for no limit.
+	MaxInputBytes int64     `json:"maxInputBytes"`
+	PublishedAt   time.Time `json:"publishedAt"`
+}
+
+// Supports reports whether the deployment announced the feature.
+func (c *Capabilities) Supports(feature string) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// CapabilitiesKey is the well-known key capabilities are published under.
+func CapabilitiesKey(prefix string) string {
+	return prefix + "conversion:capabilities"
+}
+
+// PublishCapabilities writes the capabilities as JSON to the key, without
+// a TTL, replacing what any earlier deployment wrote. Features are sorted
+// so unchanged capabilities produce the same document.
+func PublishCapabilities(ctx context.Context, client *redis.Client, key string, caps *Capabilities) error {
+	sort.Strings(caps.Features)
+	encoded, err := json.Marshal(caps)
+	if err != nil {
+		return fmt.Errorf("failed to encode capabilities: %w", err)
+	}
+	if err := client.Set(ctx, key, encoded, 0).Err(); err != nil {
+		return fmt.Errorf("failed to publish capabilities: %w", err)
+	}
+	return nil
+}
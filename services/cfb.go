@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Compound File Binary (OLE2) constants. Outlook .msg files are compound
+// files whose streams hold the MAPI properties.
+const (
+	cfbEndOfChain = 0xFFFFFFFE
+	cfbNoStream   = 0xFFFFFFFF
+	cfbDirSize    = 128
+
+	cfbTypeStorage = 1
+	cfbTypeStream  = 2
+	cfbTypeRoot    = 5
+)
+
+var cfbSignature = []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")
+
+type cfbEntry struct {
+	name  string
+	typ   byte
+	left  uint32
+	right uint32
+	child uint32
+	start uint32
+	size  uint64
+}
+
+// compoundFile is a read-only view of an in-memory compound file. It reads
+// just enough of the format to walk storages and read streams.
+type compoundFile struct {
+	data           []byte
+	sectorSize     int
+	miniSectorSize int
+	miniCutoff     uint64
+	fat            []uint32
+	miniFAT        []uint32
+	miniStream     []byte
+	entries        []cfbEntry
+}
+
+func openCompoundFile(data []byte) (*compoundFile, error) {
+	if len(data) < 512 || !bytes.HasPrefix(data, cfbSignature) {
+		return nil, fmt.Errorf("not a compound file")
+	}
+
+	le := binary.LittleEndian
+	sectorShift := le.Uint16(data[0x1E:])
+	miniShift := le.Uint16(data[0x20:])
+	if sectorShift != 9 && sectorShift != 12 || miniShift != 6 {
+		return nil, fmt.Errorf("unsupported compound file sector size")
+	}
+	c := &compoundFile{
+		data:           data,
+		sectorSize:     1 << sectorShift,
+		miniSectorSize: 1 << miniShift,
+		miniCutoff:     uint64(le.Uint32(data[0x38:])),
+	}
+
+	// The FAT sectors are listed in the header's DIFAT and any chained
+	// DIFAT sectors
+	var fatSectors []uint32
+	for i := 0; i < 109; i++ {
+		if s := le.Uint32(data[0x4C+i*4:]); s < cfbEndOfChain {
+			fatSectors = append(fatSectors, s)
+		}
+	}
+	perSector := c.sectorSize/4 - 1
+	difat := le.Uint32(data[0x44:])
+	for n := 0; difat < cfbEndOfChain; n++ {
+		sector, err := c.sector(difat)
+		if err != nil || n > len(data)/c.sectorSize {
+			return nil, fmt.Errorf("corrupt DIFAT chain")
+		}
+		for i := 0; i < perSector; i++ {
+			if s := le.Uint32(sector[i*4:]); s < cfbEndOfChain {
+				fatSectors = append(fatSectors, s)
+			}
+		}
+		difat = le.Uint32(sector[perSector*4:])
+	}
+	for _, s := range fatSectors {
+		sector, err := c.sector(s)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(sector); i += 4 {
+			c.fat = append(c.fat, le.Uint32(sector[i:]))
+		}
+	}
+
+	dir, err := c.chain(le.Uint32(data[0x30:]), c.fat, c.sector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	for off := 0; off+cfbDirSize <= len(dir); off += cfbDirSize {
+		e := parseCFBEntry(dir[off : off+cfbDirSize])
+		if c.sectorSize == 512 {
+			// Version 3 files may leave garbage in the high size bits
+			e.size &= 0xFFFFFFFF
+		}
+		c.entries = append(c.entries, e)
+	}
+	if len(c.entries) == 0 || c.entries[0].typ != cfbTypeRoot {
+		return nil, fmt.Errorf("compound file has no root entry")
+	}
+
+	if first := le.Uint32(data[0x3C:]); first < cfbEndOfChain {
+		miniFAT, err := c.chain(first, c.fat, c.sector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mini FAT: %w", err)
+		}
+		for i := 0; i+4 <= len(miniFAT); i += 4 {
+			c.miniFAT = append(c.miniFAT, le.Uint32(miniFAT[i:]))
+		}
+	}
+	if root := c.entries[0]; root.start < cfbEndOfChain {
+		c.miniStream, err = c.chain(root.start, c.fat, c.sector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mini stream: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func parseCFBEntry(b []byte) cfbEntry {
+	le := binary.LittleEndian
+	nameLen := int(le.Uint16(b[0x40:]))
+	if nameLen > 64 {
+		nameLen = 64
+	}
+	units := make([]uint16, 0, nameLen/2)
+	for i := 0; i+1 < nameLen; i += 2 {
+		if u := le.Uint16(b[i:]); u != 0 {
+			units = append(units, u)
+		}
+	}
+	return cfbEntry{
+		name:  string(utf16.Decode(units)),
+		typ:   b[0x42],
+		left:  le.Uint32(b[0x44:]),
+		right: le.Uint32(b[0x48:]),
+		child: le.Uint32(b[0x4C:]),
+		start: le.Uint32(b[0x74:]),
+		size:  le.Uint64(b[0x78:]),
+	}
+}
+
+func (c *compoundFile) sector(n uint32) ([]byte, error) {
+	off := (int(n) + 1) * c.sectorSize
+	if n >= cfbEndOfChain || off+c.sectorSize > len(c.data) {
+		return nil, fmt.Errorf("sector %d out of range", n)
+	}
+	return c.data[off : off+c.sectorSize], nil
+}
+
+func (c *compoundFile) miniSector(n uint32) ([]byte, error) {
+	off := int(n) * c.miniSectorSize
+	if n >= cfbEndOfChain || off+c.miniSectorSize > len(c.miniStream) {
+		return nil, fmt.Errorf("mini sector %d out of range", n)
+	}
+	return c.miniStream[off : off+c.miniSectorSize], nil
+}
+
+// chain concatenates the sectors of a FAT chain. A chain longer than the
+// table means it loops.
+func (c *compoundFile) chain(start uint32, table []uint32, read func(uint32) ([]byte, error)) ([]byte, error) {
+	var out []byte
+	for s, n := start, 0; s != cfbEndOfChain; n++ {
+		if int(s) >= len(table) || n > len(table) {
+			return nil, fmt.Errorf("corrupt sector chain")
+		}
+		sector, err := read(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sector...)
+		s = table[s]
+	}
+	return out, nil
+}
+
+// stream returns the contents of a stream entry.
+func (c *compoundFile) stream(e cfbEntry) ([]byte, error) {
+	var data []byte
+	var err error
+	if e.size < c.miniCutoff {
+		data, err = c.chain(e.start, c.miniFAT, c.miniSector)
+	} else {
+		data, err = c.chain(e.start, c.fat, c.sector)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) < e.size {
+		return nil, fmt.Errorf("stream %s is truncated", e.name)
+	}
+	return data[:e.size], nil
+}
+
+// children returns the directory indexes of the entries directly under a
+// storage. They are kept in a red-black tree of siblings, walked here
+// without recursion.
+func (c *compoundFile) children(storage int) []int {
+	var out []int
+	seen := make(map[uint32]bool)
+	stack := []uint32{c.entries[storage].child}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == cfbNoStream || int(id) >= len(c.entries) || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, int(id))
+		stack = append(stack, c.entries[id].left, c.entries[id].right)
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+)
+
+// ErrConsoleExceptions is returned when Chromium was told to fail on
+// exceptions in the page's console and there were some. The error carries
+// Gotenberg's list of them.
+var ErrConsoleExceptions = errors.New("page logged console exceptions")
+
+// ScreenshotHTML renders the page of ConvertHTML as a PNG to outputPath,
+// to see what Chromium saw when printing it failed. With failOnConsole it
+// fails with ErrConsoleExceptions instead when the page logged any.
+// Gotenberg 7 has no screenshot routes.
+func (g *GotenbergService) ScreenshotHTML(ctx context.Context, inputPath string, assets map[string]string, outputPath string, failOnConsole bool) error {
+	return g.screenshot(ctx, "/forms/chromium/screenshot/html", func(writer *multipart.Writer) error {
+		return writeHTMLFiles(writer, inputPath, assets)
+	}, outputPath, failOnConsole)
+}
+
+// ScreenshotMarkdown renders the page of ConvertMarkdown as a PNG to
+// outputPath, like ScreenshotHTML.
+func (g *GotenbergService) ScreenshotMarkdown(ctx context.Context, inputPath string, outputPath string, failOnConsole bool) error {
+	return g.screenshot(ctx, "/forms/chromium/screenshot/markdown", func(writer *multipart.Writer) error {
+		return g.writeMarkdownFiles(writer, inputPath)
+	}, outputPath, failOnConsole)
+}
+
+func (g *GotenbergService) screenshot(ctx context.Context, route string, writeFiles func(*multipart.Writer) error, outputPath string, failOnConsole bool) error {
+	if g.apiVersion == GotenbergV7 {
+		return fmt.Errorf("%w: gotenberg 7 can't take screenshots", ErrUnsupportedGotenberg)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := writeFiles(writer); err != nil {
+		return err
+	}
+	writer.WriteField("format", "png")
+	if failOnConsole {
+		writer.WriteField("failOnConsoleExceptions", "true")
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	resp, err := g.send(ctx, route, body, writer.FormDataContentType())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return g.writeLimited(resp.Body, outputPath)
+}
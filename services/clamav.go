@@ -0,0 +1,156 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is how much of the file goes into each INSTREAM chunk.
+const clamdChunkSize = 64 << 10
+
+// clamdTimeout bounds a command when the context has no deadline.
+const clamdTimeout = 5 * time.Minute
+
+// ScanResult is clamd's verdict on one file.
+type ScanResult struct {
+	Infected bool
+	// Signature is the matching signature of an infected file.
+	Signature string
+	ScannedAt time.Time
+}
+
+// Metadata describes the scan for the conversion metadata.
+func (r *ScanResult) Metadata() map[string]interface{} {
+	metadata := map[string]interface{}{
+		"engine":     "clamav",
+		"result":     "clean",
+		"scanned_at": r.ScannedAt.Format(time.RFC3339),
+	}
+	if r.Infected {
+		metadata["result"] = "infected"
+		metadata["signature"] = r.Signature
+	}
+	return metadata
+}
+
+// ClamAV scans files with a clamd daemon at CLAMAV_ADDR, a host:port or
+// the path of its Unix socket.
+type ClamAV struct {
+	addr string
+}
+
+func NewClamAV(addr string) *ClamAV {
+	return &ClamAV{addr: addr}
+}
+
+// ScanFile streams the file to clamd with INSTREAM, so clamd needn't see
+// the worker's disk.
+func (c *ClamAV) ScanFile(ctx context.Context, path string) (*ScanResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file to scan: %w", err)
+	}
+	defer file.Close()
+	return c.Scan(ctx, file)
+}
+
+// Scan streams r to clamd and returns its verdict. A file larger than
+// clamd's StreamMaxLength is an error, not a clean result.
+func (c *ClamAV) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	reply, err := c.command(ctx, "INSTREAM", func(conn net.Conn) error {
+		w := bufio.NewWriterSize(conn, clamdChunkSize+4)
+		buf := make([]byte, clamdChunkSize)
+		for {
+			n, err := io.ReadFull(r, buf)
+			if n > 0 {
+				binary.Write(w, binary.BigEndian, uint32(n))
+				w.Write(buf[:n])
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read file to scan: %w", err)
+			}
+		}
+		binary.Write(w, binary.BigEndian, uint32(0))
+		return w.Flush()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseClamdReply(reply)
+}
+
+// Ping checks that clamd answers.
+func (c *ClamAV) Ping(ctx context.Context) error {
+	reply, err := c.command(ctx, "PING", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+	return nil
+}
+
+// command sends a null-terminated clamd command, lets send write its
+// payload and reads the reply.
+func (c *ClamAV) command(ctx context.Context, name string, send func(net.Conn) error) (string, error) {
+	network := "tcp"
+	if strings.HasPrefix(c.addr, "/") {
+		network = "unix"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, c.addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(clamdTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte("z" + name + "\x00")); err != nil {
+		return "", fmt.Errorf("failed to send clamd command: %w", err)
+	}
+	if send != nil {
+		if err := send(conn); err != nil {
+			return "", err
+		}
+	}
+
+	reply, err := io.ReadAll(io.LimitReader(conn, 4096))
+	if err != nil {
+		return "", fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// parseClamdReply reads an INSTREAM reply: "stream: OK",
+// "stream: <signature> FOUND" or "<message> ERROR".
+func parseClamdReply(reply string) (*ScanResult, error) {
+	result := &ScanResult{ScannedAt: time.Now().UTC()}
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case verdict == "OK":
+		return result, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		result.Infected = true
+		result.Signature = strings.TrimSuffix(verdict, " FOUND")
+		return result, nil
+	default:
+		return nil, fmt.Errorf("clamd scan failed: %s", reply)
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeClamd answers one INSTREAM or PING per connection, flagging streams
+// that contain "EICAR".
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveClamd(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	switch command {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+		return
+	case "zINSTREAM\x00":
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	var stream bytes.Buffer
+	for {
+		var size uint32
+		if binary.Read(r, binary.BigEndian, &size) != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if _, err := io.CopyN(&stream, r, int64(size)); err != nil {
+			return
+		}
+	}
+	switch {
+	case bytes.Contains(stream.Bytes(), []byte("EICAR")):
+		conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+	case stream.Len() > 200<<10:
+		conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+	default:
+		conn.Write([]byte("stream: OK\x00"))
+	}
+}
+
+func TestClamAV_Scan(t *testing.T) {
+	t.Parallel()
+
+	scanner := NewClamAV(fakeClamd(t))
+	ctx := context.Background()
+
+	if err := scanner.Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	clean, err := scanner.Scan(ctx, strings.NewReader(strings.Repeat("quarterly report ", 10000)))
+	if err != nil || clean.Infected {
+		t.Fatalf("clean file: got %+v, %v", clean, err)
+	}
+
+	// The signature straddles two INSTREAM chunks
+	infected, err := scanner.Scan(ctx, strings.NewReader(strings.Repeat("x", clamdChunkSize-2)+"EICAR"))
+	if err != nil || !infected.Infected || infected.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("infected file: got %+v, %v", infected, err)
+	}
+	if metadata := infected.Metadata(); metadata["result"] != "infected" || metadata["signature"] != "Eicar-Test-Signature" {
+		t.Fatalf("unexpected metadata %v", metadata)
+	}
+
+	if _, err := scanner.Scan(ctx, bytes.NewReader(make([]byte, 300<<10))); err == nil {
+		t.Fatal("a clamd error must not pass as clean")
+	}
+}
+
+func TestClamAV_Unreachable(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	if _, err := NewClamAV(addr).Scan(context.Background(), strings.NewReader("data")); err == nil {
+		t.Fatal("expected an error when clamd is down")
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		reply     string
+		infected  bool
+		signature string
+		wantErr   bool
+	}{
+		{"stream: OK", false, "", false},
+		{"stream: Win.Test.EICAR_HDB-1 FOUND", true, "Win.Test.EICAR_HDB-1", false},
+		{"INSTREAM size limit exceeded. ERROR", false, "", true},
+		{"", false, "", true},
+	}
+
+	for _, c := range cases {
+		result, err := parseClamdReply(c.reply)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%q: unexpected error %v", c.reply, err)
+			continue
+		}
+		if err == nil && (result.Infected != c.infected || result.Signature != c.signature) {
+			t.Errorf("%q: got %+v", c.reply, result)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CompletedJobs remembers the idempotency keys of completed jobs at
+// conversion:completed:<key> for ttl, with the key the output was written
+// to, so a job enqueued twice isn't converted twice.
+type CompletedJobs struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewCompletedJobs(client *redis.Client, prefix string, ttl time.Duration) *CompletedJobs {
+	return &CompletedJobs{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (c *CompletedJobs) key(idempotencyKey string) string {
+	return c.prefix + "conversion:completed:" + idempotencyKey
+}
+
+// Completed reports whether a job with the idempotency key completed, and
+// where its output went.
+func (c *CompletedJobs) Completed(ctx context.Context, idempotencyKey string) (string, bool, error) {
+	outputPath, err := c.client.Get(ctx, c.key(idempotencyKey)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read completed job: %w", err)
+	}
+	return outputPath, true, nil
+}
+
+// MarkCompleted records that the job with the idempotency key completed.
+func (c *CompletedJobs) MarkCompleted(ctx context.Context, idempotencyKey string, outputPath string) error {
+	if err := c.client.Set(ctx, c.key(idempotencyKey), outputPath, c.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to record completed job: %w", err)
+	}
+	return nil
+}
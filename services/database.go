@@ -4,41 +4,90 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
-	_ "github.com/lib/pq"
+	"converter/models"
+
+	"github.com/lib/pq"
 )
 
+// DatabaseService writes through db and serves query-heavy reads from
+// readDB, which is a replica when one is configured and db otherwise.
 type DatabaseService struct {
-	db *sql.DB
+	db     *sql.DB
+	readDB *sql.DB
+}
+
+// ConversionRecord is the worker-managed part of a file_conversions row.
+type ConversionRecord struct {
+	ID           int        `json:"id"`
+	Status       string     `json:"status"`
+	OutputS3Path *string    `json:"outputS3Path"`
+	ErrorMessage *string    `json:"errorMessage"`
+	RetryCount   int        `json:"retryCount"`
+	StartedAt    *time.Time `json:"startedAt"`
+	CompletedAt  *time.Time `json:"completedAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+func NewDatabaseService(databaseURL string, readURL string) (*DatabaseService, error) {
+	db, err := openDatabase(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if readURL == "" {
+		return &DatabaseService{db: db, readDB: db}, nil
+	}
+
+	readDB, err := openDatabase(readURL)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("read replica: %w", err)
+	}
+
+	return &DatabaseService{db: db, readDB: readDB}, nil
+}
+
+// NewDatabaseServiceWithDB wraps a database that is already open, for
+// both writes and reads.
+func NewDatabaseServiceWithDB(db *sql.DB) *DatabaseService {
+	return &DatabaseService{db: db, readDB: db}
 }
 
-func NewDatabaseService(databaseURL string) (*DatabaseService, error) {
-	db, err := sql.Open("postgres", databaseURL)
+func openDatabase(url string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DatabaseService{db: db}, nil
+	return db, nil
 }
 
-func (d *DatabaseService) UpdateConversionStatus(ctx context.Context, conversionID int, status string, outputPath string, metadata map[string]interface{}) error {
+func (d *DatabaseService) UpdateConversionStatus(ctx context.Context, conversionID int, status models.ConversionStatus, outputPath string, metadata map[string]interface{}) error {
+	if !status.Valid() {
+		return fmt.Errorf("unknown conversion status %q", status)
+	}
+
 	query := `UPDATE file_conversions SET status = $1, updated_at = $2`
-	args := []interface{}{status, time.Now()}
+	args := []interface{}{string(status), time.Now()}
 	argIndex := 3
 
-	if status == "processing" {
+	if status == models.StatusProcessing {
 		query += fmt.Sprintf(`, started_at = $%d`, argIndex)
 		args = append(args, time.Now())
 		argIndex++
 	}
 
-	if status == "completed" {
+	if status == models.StatusCompleted {
 		query += fmt.Sprintf(`, completed_at = $%d, output_s3_path = $%d`, argIndex, argIndex+1)
 		args = append(args, time.Now(), outputPath)
 		argIndex += 2
@@ -51,11 +100,28 @@ func (d *DatabaseService) UpdateConversionStatus(ctx context.Context, conversion
 		}
 	}
 
-	query += fmt.Sprintf(` WHERE id = $%d`, argIndex)
-	args = append(args, conversionID)
+	// Only move rows whose current status is a legal predecessor
+	query += fmt.Sprintf(` WHERE id = $%d AND status = ANY($%d)`, argIndex, argIndex+1)
+	args = append(args, conversionID, pq.Array(statusStrings(models.Predecessors(status))))
 
-	_, err := d.db.ExecContext(ctx, query, args...)
-	return err
+	result, err := d.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &models.ErrIllegalTransition{ConversionID: conversionID, To: status}
+	}
+
+	return nil
+}
+
+func statusStrings(statuses []models.ConversionStatus) []string {
+	out := make([]string, len(statuses))
+	for i, s := range statuses {
+		out[i] = string(s)
+	}
+	return out
 }
 
 func (d *DatabaseService) UpdateConversionError(ctx context.Context, conversionID int, errorMsg string) error {
@@ -70,6 +136,324 @@ func (d *DatabaseService) IncrementRetryCount(ctx context.Context, conversionID
 	return err
 }
 
+// UpdateRetrySchedule records when a failed conversion will be retried and
+// how many attempts it has left. A nil nextRetryAt clears the schedule once
+// no retry is left. The next_retry_at and retries_remaining columns are
+// optional; see DB_RETRY_COLUMNS.
+func (d *DatabaseService) UpdateRetrySchedule(ctx context.Context, conversionID int, nextRetryAt *time.Time, remaining int) error {
+	query := `UPDATE file_conversions SET next_retry_at = $1, retries_remaining = $2, updated_at = $3 WHERE id = $4`
+	_, err := d.db.ExecContext(ctx, query, nextRetryAt, remaining, time.Now(), conversionID)
+	return err
+}
+
+// InsertAuditRecord appends to conversion_audit_log; rows are never updated.
+func (d *DatabaseService) InsertAuditRecord(ctx context.Context, conversionID int, outcome string, record []byte) error {
+	query := `INSERT INTO conversion_audit_log (conversion_id, outcome, record, created_at) VALUES ($1, $2, $3, $4)`
+	_, err := d.db.ExecContext(ctx, query, conversionID, outcome, record, time.Now())
+	return err
+}
+
+// AuditRecordsSince reads audit records appended after since from the
+// replica, oldest first.
+func (d *DatabaseService) AuditRecordsSince(ctx context.Context, since time.Time) ([]AuditRecord, error) {
+	query := `SELECT record FROM conversion_audit_log WHERE created_at >= $1 ORDER BY id`
+
+	rows, err := d.readDB.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var records []AuditRecord
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, fmt.Errorf("failed to scan audit record: %w", err)
+		}
+		var record AuditRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, fmt.Errorf("failed to decode audit record: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// GetConversion reads a conversion from the replica, so the row may lag
+// the primary slightly. Returns sql.ErrNoRows if it doesn't exist.
+func (d *DatabaseService) GetConversion(ctx context.Context, conversionID int) (*ConversionRecord, error) {
+	query := `SELECT id, status, output_s3_path, error_message, retry_count, started_at, completed_at, updated_at
+		FROM file_conversions WHERE id = $1`
+
+	var r ConversionRecord
+	err := d.readDB.QueryRowContext(ctx, query, conversionID).Scan(
+		&r.ID, &r.Status, &r.OutputS3Path, &r.ErrorMessage, &r.RetryCount, &r.StartedAt, &r.CompletedAt, &r.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// MirroredJob is a queued job as last seen by the queue mirror.
+type MirroredJob struct {
+	ConversionID int
+	Queue        string
+	Payload      string
+}
+
+// MirrorQueuedJobs records the jobs currently queued in conversion_queue_mirror,
+// replacing each conversion's earlier row.
+func (d *DatabaseService) MirrorQueuedJobs(ctx context.Context, jobs []MirroredJob) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin mirror transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO conversion_queue_mirror (conversion_id, queue, payload, mirrored_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (conversion_id) DO UPDATE SET queue = EXCLUDED.queue, payload = EXCLUDED.payload, mirrored_at = EXCLUDED.mirrored_at
+		WHERE conversion_queue_mirror.payload IS DISTINCT FROM EXCLUDED.payload`
+	now := time.Now()
+	for _, job := range jobs {
+		if _, err := tx.ExecContext(ctx, query, job.ConversionID, job.Queue, job.Payload, now); err != nil {
+			return fmt.Errorf("failed to mirror conversion %d: %w", job.ConversionID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// PruneQueueMirror drops mirrored jobs whose conversion is no longer pending
+// or processing, so the mirror only holds work that could still be lost.
+func (d *DatabaseService) PruneQueueMirror(ctx context.Context) (int64, error) {
+	query := `DELETE FROM conversion_queue_mirror m WHERE NOT EXISTS (
+		SELECT 1 FROM file_conversions c WHERE c.id = m.conversion_id AND c.status IN ($1, $2))`
+	result, err := d.db.ExecContext(ctx, query, models.StatusPending, models.StatusProcessing)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune queue mirror: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// UnfinishedMirroredJobs returns the mirrored jobs whose conversion is still
+// pending or processing, read from the primary so the restore sees the
+// latest statuses.
+func (d *DatabaseService) UnfinishedMirroredJobs(ctx context.Context) ([]MirroredJob, error) {
+	query := `SELECT m.conversion_id, m.queue, m.payload FROM conversion_queue_mirror m
+		JOIN file_conversions c ON c.id = m.conversion_id
+		WHERE c.status IN ($1, $2) ORDER BY m.conversion_id`
+
+	rows, err := d.db.QueryContext(ctx, query, models.StatusPending, models.StatusProcessing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query queue mirror: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []MirroredJob
+	for rows.Next() {
+		var job MirroredJob
+		if err := rows.Scan(&job.ConversionID, &job.Queue, &job.Payload); err != nil {
+			return nil, fmt.Errorf("failed to scan mirrored job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// ConversionOutputKeys returns the keys of every object a completed
+// conversion wrote: its output, artifacts, thumbnails and split parts. It
+// reads the primary, so a conversion that just completed is seen.
+func (d *DatabaseService) ConversionOutputKeys(ctx context.Context, conversionID int) ([]string, error) {
+	query := `SELECT output_s3_path, metadata FROM file_conversions WHERE id = $1`
+
+	var outputPath sql.NullString
+	var metadata []byte
+	if err := d.db.QueryRowContext(ctx, query, conversionID).Scan(&outputPath, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to read conversion outputs: %w", err)
+	}
+	return outputKeys(outputPath.String, metadata), nil
+}
+
+// outputKeys collects the output path and the keys recorded in the
+// conversion metadata, without duplicates.
+func outputKeys(outputPath string, metadata []byte) []string {
+	var recorded struct {
+		Artifacts  map[string]string `json:"artifacts"`
+		Thumbnails map[string]string `json:"thumbnails"`
+		Split      []string          `json:"split"`
+	}
+	// Metadata written by older versions may lack any of these
+	json.Unmarshal(metadata, &recorded)
+
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(key string) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	add(outputPath)
+	for _, name := range sortedKeys(recorded.Artifacts) {
+		add(recorded.Artifacts[name])
+	}
+	for _, name := range sortedKeys(recorded.Thumbnails) {
+		add(recorded.Thumbnails[name])
+	}
+	for _, key := range recorded.Split {
+		add(key)
+	}
+	return keys
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TrashedConversion is a conversion whose outputs are in the trash.
+type TrashedConversion struct {
+	ConversionID int       `json:"conversionId"`
+	Keys         []string  `json:"keys"`
+	TrashedAt    time.Time `json:"trashedAt"`
+	PurgeAfter   time.Time `json:"purgeAfter"`
+}
+
+// RecordTrash records in conversion_trash that the conversion's outputs are
+// being moved to the trash, replacing an earlier row.
+func (d *DatabaseService) RecordTrash(ctx context.Context, trashed TrashedConversion) error {
+	query := `INSERT INTO conversion_trash (conversion_id, keys, trashed_at, purge_after) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (conversion_id) DO UPDATE SET keys = EXCLUDED.keys, trashed_at = EXCLUDED.trashed_at, purge_after = EXCLUDED.purge_after`
+	if _, err := d.db.ExecContext(ctx, query, trashed.ConversionID, pq.Array(trashed.Keys), trashed.TrashedAt, trashed.PurgeAfter); err != nil {
+		return fmt.Errorf("failed to record trashed outputs: %w", err)
+	}
+	return nil
+}
+
+// TrashedOutputs reads the conversion's conversion_trash row from the
+// primary. Returns sql.ErrNoRows if its outputs aren't in the trash.
+func (d *DatabaseService) TrashedOutputs(ctx context.Context, conversionID int) (*TrashedConversion, error) {
+	return scanTrash(d.db.QueryRowContext(ctx,
+		`SELECT conversion_id, keys, trashed_at, purge_after FROM conversion_trash WHERE conversion_id = $1`, conversionID))
+}
+
+// ExpiredTrash returns up to limit conversions whose outputs have been in
+// the trash past their retention, oldest first.
+func (d *DatabaseService) ExpiredTrash(ctx context.Context, now time.Time, limit int) ([]TrashedConversion, error) {
+	query := `SELECT conversion_id, keys, trashed_at, purge_after FROM conversion_trash
+		WHERE purge_after <= $1 ORDER BY purge_after LIMIT $2`
+
+	rows, err := d.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+
+	var expired []TrashedConversion
+	for rows.Next() {
+		trashed, err := scanTrash(rows)
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, *trashed)
+	}
+	return expired, rows.Err()
+}
+
+func scanTrash(row interface{ Scan(dest ...any) error }) (*TrashedConversion, error) {
+	var t TrashedConversion
+	if err := row.Scan(&t.ConversionID, pq.Array(&t.Keys), &t.TrashedAt, &t.PurgeAfter); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan trashed outputs: %w", err)
+	}
+	return &t, nil
+}
+
+// DeleteTrash drops the conversion's conversion_trash row once its outputs
+// were restored or purged.
+func (d *DatabaseService) DeleteTrash(ctx context.Context, conversionID int) error {
+	if _, err := d.db.ExecContext(ctx, `DELETE FROM conversion_trash WHERE conversion_id = $1`, conversionID); err != nil {
+		return fmt.Errorf("failed to delete trash record: %w", err)
+	}
+	return nil
+}
+
+// AddRollups adds the counts to their conversion_rollups rows, creating
+// the rows that don't exist yet.
+func (d *DatabaseService) AddRollups(ctx context.Context, rollups []Rollup) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin rollup transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO conversion_rollups (hour, extension, completed, failed, duration_ms, updated_at) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (hour, extension) DO UPDATE SET
+			completed = conversion_rollups.completed + EXCLUDED.completed,
+			failed = conversion_rollups.failed + EXCLUDED.failed,
+			duration_ms = conversion_rollups.duration_ms + EXCLUDED.duration_ms,
+			updated_at = EXCLUDED.updated_at`
+	now := time.Now().UTC()
+	for _, rollup := range rollups {
+		if _, err := tx.ExecContext(ctx, query, rollup.Hour, rollup.Extension, rollup.Completed, rollup.Failed, rollup.DurationMs, now); err != nil {
+			return fmt.Errorf("failed to add rollup for %s %s: %w", rollup.Extension, rollup.Hour.Format(time.RFC3339), err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollups: %w", err)
+	}
+	return nil
+}
+
+// CheckPrivileges checks that each table exists and that the connected
+// role holds the listed privileges on it, such as "SELECT, UPDATE".
+func (d *DatabaseService) CheckPrivileges(ctx context.Context, tables map[string]string) error {
+	names := make([]string, 0, len(tables))
+	for table := range tables {
+		names = append(names, table)
+	}
+	sort.Strings(names)
+
+	for _, table := range names {
+		var exists, granted bool
+		if err := d.db.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to look up table %s: %w", table, err)
+		}
+		if !exists {
+			return fmt.Errorf("table %s does not exist", table)
+		}
+		if err := d.db.QueryRowContext(ctx, `SELECT has_table_privilege($1, $2)`, table, tables[table]).Scan(&granted); err != nil {
+			return fmt.Errorf("failed to check privileges on %s: %w", table, err)
+		}
+		if !granted {
+			return fmt.Errorf("missing %s on table %s", tables[table], table)
+		}
+	}
+	return nil
+}
+
+// Ping checks the primary connection, and the replica when one is in use.
+func (d *DatabaseService) Ping(ctx context.Context) error {
+	if err := d.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	if d.readDB != d.db {
+		if err := d.readDB.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to ping read replica: %w", err)
+		}
+	}
+	return nil
+}
+
 func (d *DatabaseService) Close() error {
+	if d.readDB != d.db {
+		d.readDB.Close()
+	}
 	return d.db.Close()
 }
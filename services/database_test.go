@@ -0,0 +1,27 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOutputKeys(t *testing.T) {
+	t.Parallel()
+
+	metadata := []byte(`{
+		"artifacts": {"text": "out/a.txt", "markdown": "out/a.md"},
+		"thumbnails": {"small": "out/a-small.png", "large": "out/a-large.png"},
+		"split": ["out/a-1.pdf", "out/a-2.pdf", "out/a.pdf"]
+	}`)
+	want := []string{"out/a.pdf", "out/a.md", "out/a.txt", "out/a-large.png", "out/a-small.png", "out/a-1.pdf", "out/a-2.pdf"}
+	if got := outputKeys("out/a.pdf", metadata); !reflect.DeepEqual(got, want) {
+		t.Errorf("outputKeys = %v, want %v", got, want)
+	}
+
+	if got := outputKeys("out/a.pdf", []byte("not json")); !reflect.DeepEqual(got, []string{"out/a.pdf"}) {
+		t.Errorf("outputKeys with unreadable metadata = %v", got)
+	}
+	if got := outputKeys("", nil); len(got) != 0 {
+		t.Errorf("outputKeys without outputs = %v", got)
+	}
+}
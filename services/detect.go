@@ -0,0 +1,342 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// sniffLen is how much of the file is read for magic numbers and the text
+// check.
+const sniffLen = 8192
+
+// cfbScanLimit bounds how much of an OLE2 compound file is searched for the
+// stream names that tell Word, Excel and PowerPoint apart.
+const cfbScanLimit = 4 << 20
+
+// formatFamilies maps a detected format to every extension that is
+// consistent with it, so a .jpeg JPEG or a .dotx Word template isn't
+// reported as a mismatch.
+var formatFamilies = map[string][]string{
+	"pdf":  {"pdf"},
+	"rtf":  {"rtf"},
+	"png":  {"png"},
+	"jpg":  {"jpg", "jpeg", "jfif"},
+	"gif":  {"gif"},
+	"tiff": {"tif", "tiff"},
+	"bmp":  {"bmp"},
+	"webp": {"webp"},
+	"heic": {"heic", "heif"},
+	"docx": {"docx", "docm", "dotx", "dotm"},
+	"xlsx": {"xlsx", "xlsm", "xltx", "xltm"},
+	"pptx": {"pptx", "pptm", "ppsx", "potx"},
+	"odt":  {"odt", "ott"},
+	"ods":  {"ods", "ots"},
+	"odp":  {"odp", "otp"},
+	"doc":  {"doc", "dot", "wps"},
+	"xls":  {"xls", "xlt"},
+	"ppt":  {"ppt", "pps", "pot"},
+	"html": {"html", "htm", "xhtml"},
+	"eml":  {"eml"},
+	"msg":  {"msg"},
+}
+
+// unconvertibleFormats are contents DetectFormat recognises that no route
+// converts, with how they are described when a job is rejected for them.
+var unconvertibleFormats = map[string]string{
+	"exe":     "a Windows executable",
+	"elf":     "a Linux executable",
+	"macho":   "a macOS executable",
+	"zip":     "a ZIP archive",
+	"rar":     "a RAR archive",
+	"7z":      "a 7-Zip archive",
+	"gz":      "a gzip archive",
+	"zipbomb": "a ZIP bomb",
+}
+
+// A ZIP package is taken for a bomb when the sizes its directory declares
+// add up to more than zipBombMaxBytes, or to more than zipBombMinBytes at a
+// compression ratio above zipBombRatio. Office documents stay far below.
+const (
+	zipBombMaxBytes = 4 << 30
+	zipBombMinBytes = 100 << 20
+	zipBombRatio    = 100
+)
+
+// Unconvertible describes a detected format no route converts, such as an
+// executable or an archive, so the job can be rejected without trying.
+func Unconvertible(detected string) (string, bool) {
+	description, ok := unconvertibleFormats[detected]
+	return description, ok
+}
+
+// DetectFormat sniffs the file's content and returns the canonical
+// extension of its format, or "" when the content isn't recognised. Plain
+// UTF-8 text is reported as "txt". Executables, archives and ZIP bombs are
+// reported as one of the formats Unconvertible describes.
+func DetectFormat(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return "pdf", nil
+	case bytes.HasPrefix(head, []byte(`{\rtf`)):
+		return "rtf", nil
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return "png", nil
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return "jpg", nil
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return "gif", nil
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return "tiff", nil
+	case bytes.HasPrefix(head, []byte("BM")) && len(head) > 10 && bytes.Equal(head[6:10], []byte{0, 0, 0, 0}):
+		return "bmp", nil
+	case len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) && string(head[8:12]) == "WEBP":
+		return "webp", nil
+	case len(head) >= 12 && string(head[4:8]) == "ftyp" && isHEIFBrand(string(head[8:12])):
+		return "heic", nil
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return detectZip(path), nil
+	case bytes.HasPrefix(head, []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")):
+		return detectCompoundFile(f), nil
+	case isPortableExecutable(head):
+		return "exe", nil
+	case bytes.HasPrefix(head, []byte("\x7fELF")):
+		return "elf", nil
+	case bytes.HasPrefix(head, []byte("\xcf\xfa\xed\xfe")), bytes.HasPrefix(head, []byte("\xce\xfa\xed\xfe")),
+		bytes.HasPrefix(head, []byte("\xfe\xed\xfa\xcf")), bytes.HasPrefix(head, []byte("\xfe\xed\xfa\xce")):
+		return "macho", nil
+	case bytes.HasPrefix(head, []byte("Rar!\x1a\x07")):
+		return "rar", nil
+	case bytes.HasPrefix(head, []byte("7z\xbc\xaf\x27\x1c")):
+		return "7z", nil
+	case bytes.HasPrefix(head, []byte("\x1f\x8b")):
+		return "gz", nil
+	}
+
+	text := bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	if !looksLikeText(text, n == sniffLen) {
+		return "", nil
+	}
+	lower := strings.ToLower(strings.TrimSpace(string(text)))
+	if strings.HasPrefix(lower, "<!doctype html") || strings.HasPrefix(lower, "<html") {
+		return "html", nil
+	}
+	if looksLikeEmail(lower) {
+		return "eml", nil
+	}
+	return "txt", nil
+}
+
+// isPortableExecutable reports whether head starts a Windows executable or
+// DLL: an MZ stub whose header offset points at the PE signature. Text that
+// merely starts with "MZ" doesn't qualify.
+func isPortableExecutable(head []byte) bool {
+	if len(head) < 64 || !bytes.HasPrefix(head, []byte("MZ")) {
+		return false
+	}
+	offset := int(binary.LittleEndian.Uint32(head[0x3c:0x40]))
+	return offset+4 <= len(head) && bytes.Equal(head[offset:offset+4], []byte("PE\x00\x00"))
+}
+
+// isHEIFBrand reports whether an ISO media ftyp brand is HEIC/HEIF, as
+// opposed to MP4 or other containers sharing the box layout.
+func isHEIFBrand(brand string) bool {
+	switch brand {
+	case "heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1":
+		return true
+	}
+	return false
+}
+
+// detectZip tells OOXML and OpenDocument packages apart by their contents.
+// Other document packages (drawings, Visio, EPUB) yield "", archives that
+// aren't documents at all "zip", and packages that would unpack to far
+// more than they hold "zipbomb".
+func detectZip(path string) string {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+
+	var compressed, uncompressed uint64
+	for _, file := range r.File {
+		compressed += file.CompressedSize64
+		uncompressed += file.UncompressedSize64
+	}
+	if uncompressed > zipBombMaxBytes || (uncompressed > zipBombMinBytes && uncompressed > compressed*zipBombRatio) {
+		return "zipbomb"
+	}
+
+	archive := true
+	for _, file := range r.File {
+		switch {
+		case file.Name == "[Content_Types].xml":
+			archive = false
+		case file.Name == "mimetype":
+			archive = false
+			rc, err := file.Open()
+			if err != nil {
+				return ""
+			}
+			mimetype, _ := io.ReadAll(io.LimitReader(rc, 128))
+			rc.Close()
+			switch strings.TrimSpace(string(mimetype)) {
+			case "application/vnd.oasis.opendocument.text":
+				return "odt"
+			case "application/vnd.oasis.opendocument.spreadsheet":
+				return "ods"
+			case "application/vnd.oasis.opendocument.presentation":
+				return "odp"
+			}
+		case strings.HasPrefix(file.Name, "word/"):
+			return "docx"
+		case strings.HasPrefix(file.Name, "xl/"):
+			return "xlsx"
+		case strings.HasPrefix(file.Name, "ppt/"):
+			return "pptx"
+		}
+	}
+	if archive {
+		return "zip"
+	}
+	return ""
+}
+
+// detectCompoundFile looks for the well-known stream names (stored as
+// UTF-16LE in the directory) of legacy Office binaries.
+func detectCompoundFile(f *os.File) string {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(f, cfbScanLimit))
+	if err != nil {
+		return ""
+	}
+
+	// Outlook messages come first: an attached Word document embedded as an
+	// OLE object would otherwise match WordDocument
+	streams := []struct{ name, ext string }{
+		{"__substg1.0_", "msg"},
+		{"WordDocument", "doc"},
+		{"Workbook", "xls"},
+		{"Book", "xls"},
+		{"PowerPoint Document", "ppt"},
+	}
+	for _, s := range streams {
+		if bytes.Contains(data, utf16le(s.name)) {
+			return s.ext
+		}
+	}
+	return ""
+}
+
+func utf16le(s string) []byte {
+	out := make([]byte, 0, len(s)*2)
+	for _, c := range []byte(s) {
+		out = append(out, c, 0)
+	}
+	return out
+}
+
+// looksLikeText reports whether b is NUL-free UTF-8. When the sample was
+// cut off, a multi-byte rune split at the end is tolerated.
+func looksLikeText(b []byte, truncated bool) bool {
+	if len(b) == 0 || bytes.IndexByte(b, 0) >= 0 {
+		return false
+	}
+	if truncated {
+		for i := 0; i < utf8.UTFMax && len(b) > 0 && !utf8.Valid(b); i++ {
+			b = b[:len(b)-1]
+		}
+	}
+	return utf8.Valid(b)
+}
+
+// ResolveExtension reconciles the declared extension with the detected
+// format. It returns the extension to convert with and whether the declared
+// one was missing or contradicted by the content. Unrecognised content
+// never overrides the declaration.
+func ResolveExtension(declared string, detected string) (string, bool) {
+	declared = strings.ToLower(strings.TrimPrefix(declared, "."))
+	if detected == "" {
+		return declared, false
+	}
+	if declared == "" {
+		return detected, true
+	}
+
+	if detected == "txt" {
+		// Text covers csv, md, xml and friends; only a binary format's
+		// extension contradicts it
+		if isBinaryExtension(declared) {
+			return detected, true
+		}
+		return declared, false
+	}
+
+	for _, ext := range formatFamilies[detected] {
+		if ext == declared {
+			return declared, false
+		}
+	}
+	return detected, true
+}
+
+// looksLikeEmail reports whether lowercased text opens with an RFC 5322
+// header block carrying a From header and at least one other header only
+// messages have.
+func looksLikeEmail(lower string) bool {
+	var from, other bool
+	for _, line := range strings.Split(lower, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			break
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue // folded continuation
+		}
+		name, _, ok := strings.Cut(line, ":")
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return false
+		}
+		switch name {
+		case "from":
+			from = true
+		case "date", "message-id", "received", "mime-version", "return-path":
+			other = true
+		}
+	}
+	return from && other
+}
+
+func isBinaryExtension(ext string) bool {
+	for format, exts := range formatFamilies {
+		if format == "html" || format == "eml" {
+			continue
+		}
+		for _, e := range exts {
+			if e == ext {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeZip(t *testing.T, names ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create zip entry: %v", err)
+		}
+		if name == "mimetype" {
+			w.Write([]byte("application/vnd.oasis.opendocument.spreadsheet"))
+		} else {
+			w.Write([]byte("<xml/>"))
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// zipBomb is a package whose directory declares far more than it holds.
+func zipBomb(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "word/document.xml",
+		Method:             zip.Deflate,
+		CompressedSize64:   1 << 10,
+		UncompressedSize64: 1 << 30,
+	})
+	if err != nil {
+		t.Fatalf("failed to create zip entry: %v", err)
+	}
+	w.Write(make([]byte, 1<<10))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func portableExecutable() []byte {
+	exe := make([]byte, 256)
+	copy(exe, "MZ")
+	exe[0x3c] = 0x80
+	copy(exe[0x80:], "PE\x00\x00")
+	return exe
+}
+
+func TestDetectFormat(t *testing.T) {
+	t.Parallel()
+
+	cfb := append([]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), make([]byte, 504)...)
+	cfb = append(cfb, utf16le("WordDocument")...)
+	msg := append([]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), make([]byte, 504)...)
+	msg = append(msg, utf16le("__substg1.0_0037001F")...)
+
+	cases := map[string]struct {
+		content []byte
+		want    string
+	}{
+		"pdf":     {[]byte("%PDF-1.7\n..."), "pdf"},
+		"rtf":     {[]byte(`{\rtf1\ansi Hello}`), "rtf"},
+		"png":     {[]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "png"},
+		"jpeg":    {[]byte("\xff\xd8\xff\xe0\x00\x10JFIF"), "jpg"},
+		"tiff":    {[]byte("II*\x00\x08\x00\x00\x00"), "tiff"},
+		"heic":    {[]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"), "heic"},
+		"mp4":     {[]byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2"), ""},
+		"docx":    {writeZip(t, "[Content_Types].xml", "word/document.xml"), "docx"},
+		"xlsx":    {writeZip(t, "[Content_Types].xml", "xl/workbook.xml"), "xlsx"},
+		"ods":     {writeZip(t, "mimetype", "content.xml"), "ods"},
+		"zip":     {writeZip(t, "photos/a.txt"), "zip"},
+		"vsdx":    {writeZip(t, "[Content_Types].xml", "visio/document.xml"), ""},
+		"zipbomb": {zipBomb(t), "zipbomb"},
+		"exe":     {portableExecutable(), "exe"},
+		"mz-text": {[]byte("MZ,Mozambique\n" + strings.Repeat("row,1\n", 20)), "txt"},
+		"elf":     {[]byte("\x7fELF\x02\x01\x01\x00"), "elf"},
+		"rar":     {[]byte("Rar!\x1a\x07\x01\x00"), "rar"},
+		"gz":      {[]byte("\x1f\x8b\x08\x00"), "gz"},
+		"doc":     {cfb, "doc"},
+		"msg":     {msg, "msg"},
+		"eml":     {[]byte("Return-Path: <a@example.com>\r\nFrom: Alice <a@example.com>\r\nSubject: Hi\r\n\r\nBody\r\n"), "eml"},
+		"memo":    {[]byte("From: the desk of Alice\nRe: budget\n"), "txt"},
+		"html":    {[]byte("\xef\xbb\xbf  <!DOCTYPE html><html><body>hi</body></html>"), "html"},
+		"text":    {[]byte("name,amount\nalice,3\n"), "txt"},
+		"bm-text": {[]byte("BMW service report\n"), "txt"},
+		"binary":  {[]byte{0x00, 0x01, 0x02, 0xfe, 0xff}, ""},
+	}
+
+	dir := t.TempDir()
+	for name, tc := range cases {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, tc.content, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		got, err := DetectFormat(path)
+		if err != nil {
+			t.Fatalf("%s: DetectFormat failed: %v", name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: expected %q, got %q", name, tc.want, got)
+		}
+	}
+}
+
+func TestResolveExtension(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		declared, detected string
+		want               string
+		changed            bool
+	}{
+		{"docx", "docx", "docx", false},
+		{".DOCX", "docx", "docx", false},
+		{"jpeg", "jpg", "jpeg", false},
+		{"", "pdf", "pdf", true},
+		{"doc", "docx", "docx", true},
+		{"pdf", "png", "png", true},
+		{"csv", "txt", "csv", false},
+		{"md", "txt", "md", false},
+		{"docx", "txt", "txt", true},
+		{"eml", "txt", "eml", false},
+		{"", "eml", "eml", true},
+		{"xlsx", "", "xlsx", false},
+		{"", "", "", false},
+	}
+
+	for _, tc := range cases {
+		got, changed := ResolveExtension(tc.declared, tc.detected)
+		if got != tc.want || changed != tc.changed {
+			t.Errorf("ResolveExtension(%q, %q) = %q, %v; want %q, %v", tc.declared, tc.detected, got, changed, tc.want, tc.changed)
+		}
+	}
+}
+
+func TestUnconvertible(t *testing.T) {
+	t.Parallel()
+
+	if description, ok := Unconvertible("exe"); !ok || description != "a Windows executable" {
+		t.Errorf("Unconvertible(exe) = %q, %v", description, ok)
+	}
+	for _, detected := range []string{"docx", "txt", ""} {
+		if _, ok := Unconvertible(detected); ok {
+			t.Errorf("Unconvertible(%q) = true, want false", detected)
+		}
+	}
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxMIMEDepth bounds how deeply nested multiparts are followed.
+const maxMIMEDepth = 10
+
+//go:embed templates/email.html
+var emailTemplateSource string
+
+var emailTemplate = template.Must(template.New("email").Funcs(template.FuncMap{
+	"size": formatSize,
+}).Parse(emailTemplateSource))
+
+// metaRefreshPattern matches <meta http-equiv="refresh"> tags, which would
+// let a message navigate Chromium away from the rendered page.
+var metaRefreshPattern = regexp.MustCompile(`(?i)<meta[^>]+http-equiv\s*=\s*["']?refresh[^>]*>`)
+
+// Email is a parsed message: the headers shown on the first page, the body
+// and its attachments.
+type Email struct {
+	From        string
+	To          string
+	Cc          string
+	Subject     string
+	Date        time.Time
+	Text        string
+	HTML        string
+	Attachments []EmailAttachment
+}
+
+// EmailAttachment is a file attached to a message. Inline attachments are
+// images the HTML body references by Content-ID.
+type EmailAttachment struct {
+	Name        string
+	ContentType string
+	ContentID   string
+	Inline      bool
+	Data        []byte
+}
+
+// IsEmailExtension reports whether ext is a MIME (.eml) or Outlook (.msg)
+// message.
+func IsEmailExtension(ext string) bool {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "eml", "msg":
+		return true
+	}
+	return false
+}
+
+// ParseEmail reads a .eml or .msg file.
+func ParseEmail(path string, extension string) (*Email, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	var email *Email
+	if bytes.HasPrefix(data, cfbSignature) || strings.EqualFold(strings.TrimPrefix(extension, "."), "msg") {
+		email, err = parseMSG(data)
+	} else {
+		email, err = parseEML(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range email.Attachments {
+		a := &email.Attachments[i]
+		a.Inline = a.ContentID != "" && strings.Contains(email.HTML, "cid:"+a.ContentID)
+	}
+	return email, nil
+}
+
+func parseEML(data []byte) (*Email, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	email := &Email{
+		From:    decodeHeader(msg.Header.Get("From")),
+		To:      decodeHeader(msg.Header.Get("To")),
+		Cc:      decodeHeader(msg.Header.Get("Cc")),
+		Subject: decodeHeader(msg.Header.Get("Subject")),
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		email.Date = date
+	}
+	if err := email.addPart(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return nil, fmt.Errorf("failed to parse message body: %w", err)
+	}
+	return email, nil
+}
+
+// addPart walks one MIME part. The first inline text/plain and text/html
+// parts are the body; everything else is an attachment.
+func (e *Email) addPart(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") && depth < maxMIMEDepth {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := e.addPart(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransferEncoding(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("failed to decode %s part: %w", mediaType, err)
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	name := decodeHeader(dispositionParams["filename"])
+	if name == "" {
+		name = decodeHeader(params["name"])
+	}
+
+	if disposition != "attachment" && name == "" {
+		switch {
+		case mediaType == "text/plain" && e.Text == "":
+			e.Text = toUTF8(data, params["charset"])
+			return nil
+		case mediaType == "text/html" && e.HTML == "":
+			e.HTML = toUTF8(data, params["charset"])
+			return nil
+		}
+	}
+
+	if name == "" {
+		name = "attachment"
+		if mediaType == "message/rfc822" {
+			name = "message.eml"
+		} else if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	e.Attachments = append(e.Attachments, EmailAttachment{
+		Name:        filepath.Base(name),
+		ContentType: mediaType,
+		ContentID:   strings.Trim(header.Get("Content-ID"), "<> "),
+		Data:        data,
+	})
+	return nil
+}
+
+func decodeTransferEncoding(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+var wordDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(toUTF8(data, charset)), nil
+	},
+}
+
+// decodeHeader decodes RFC 2047 encoded words, keeping the raw value when
+// they are malformed.
+func decodeHeader(value string) string {
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// toUTF8 converts text in the declared charset. Only UTF-8 and the Latin-1
+// family are converted; anything else is kept with invalid bytes replaced.
+func toUTF8(data []byte, charset string) string {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "iso-8859-1", "iso-8859-15", "latin1", "windows-1252", "cp1252":
+		if !utf8.Valid(data) {
+			runes := make([]rune, len(data))
+			for i, b := range data {
+				runes[i] = rune(b)
+			}
+			return string(runes)
+		}
+	}
+	return strings.ToValidUTF8(string(data), "�")
+}
+
+// MAPI property IDs read from Outlook messages.
+const (
+	mapiSubject           = "0037"
+	mapiSenderName        = "0C1A"
+	mapiSenderEmail       = "0C1F"
+	mapiSenderSMTP        = "5D01"
+	mapiDisplayTo         = "0E04"
+	mapiDisplayCc         = "0E03"
+	mapiBody              = "1000"
+	mapiBodyHTML          = "1013"
+	mapiAttachData        = "3701"
+	mapiAttachLongName    = "3707"
+	mapiAttachName        = "3704"
+	mapiAttachDisplayName = "3001"
+	mapiAttachMIMEType    = "370E"
+	mapiAttachContentID   = "3712"
+
+	mapiClientSubmitTime    = 0x0039
+	mapiMessageDeliveryTime = 0x0E06
+	mapiTypeSystime         = 0x0040
+)
+
+// msgStorage is one storage of an Outlook message: the message itself or an
+// attachment, with its property streams keyed by "<id><type>".
+type msgStorage struct {
+	cf    *compoundFile
+	index int
+	props map[string]cfbEntry
+}
+
+func newMSGStorage(cf *compoundFile, index int) *msgStorage {
+	s := &msgStorage{cf: cf, index: index, props: make(map[string]cfbEntry)}
+	for _, child := range cf.children(index) {
+		e := cf.entries[child]
+		if e.typ == cfbTypeStream && strings.HasPrefix(e.name, "__substg1.0_") {
+			s.props[strings.ToUpper(strings.TrimPrefix(e.name, "__substg1.0_"))] = e
+		}
+	}
+	return s
+}
+
+// string returns a string property, stored as UTF-16 (001F) or 8-bit
+// (001E) text.
+func (s *msgStorage) string(id string) string {
+	if e, ok := s.props[id+"001F"]; ok {
+		if data, err := s.cf.stream(e); err == nil {
+			return strings.TrimRight(decodeUTF16(data), "\x00")
+		}
+	}
+	if e, ok := s.props[id+"001E"]; ok {
+		if data, err := s.cf.stream(e); err == nil {
+			return strings.TrimRight(toUTF8(data, "windows-1252"), "\x00")
+		}
+	}
+	return ""
+}
+
+func (s *msgStorage) binary(id string) []byte {
+	if e, ok := s.props[id+"0102"]; ok {
+		if data, err := s.cf.stream(e); err == nil {
+			return data
+		}
+	}
+	return nil
+}
+
+// time reads a PT_SYSTIME property from the fixed-size property stream,
+// whose header is 32 bytes for the top-level message.
+func (s *msgStorage) time(id uint16) time.Time {
+	for _, child := range s.cf.children(s.index) {
+		e := s.cf.entries[child]
+		if e.name != "__properties_version1.0" {
+			continue
+		}
+		data, err := s.cf.stream(e)
+		if err != nil {
+			return time.Time{}
+		}
+		for off := 32; off+16 <= len(data); off += 16 {
+			tag := binary.LittleEndian.Uint32(data[off:])
+			if uint16(tag>>16) == id && uint16(tag) == mapiTypeSystime {
+				return fileTime(binary.LittleEndian.Uint64(data[off+8:]))
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// fileTime converts a Windows FILETIME (100ns ticks since 1601) to UTC.
+func fileTime(ticks uint64) time.Time {
+	if ticks == 0 {
+		return time.Time{}
+	}
+	const epochDelta = 116444736000000000
+	return time.Unix(0, int64(ticks-epochDelta)*100).UTC()
+}
+
+func decodeUTF16(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
+
+func parseMSG(data []byte) (*Email, error) {
+	cf, err := openCompoundFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Outlook message: %w", err)
+	}
+
+	root := newMSGStorage(cf, 0)
+	email := &Email{
+		From:    root.string(mapiSenderName),
+		To:      root.string(mapiDisplayTo),
+		Cc:      root.string(mapiDisplayCc),
+		Subject: root.string(mapiSubject),
+		Text:    root.string(mapiBody),
+		Date:    root.time(mapiClientSubmitTime),
+	}
+	if email.Date.IsZero() {
+		email.Date = root.time(mapiMessageDeliveryTime)
+	}
+
+	address := root.string(mapiSenderSMTP)
+	if address == "" {
+		address = root.string(mapiSenderEmail)
+	}
+	if strings.Contains(address, "@") && address != email.From {
+		if email.From == "" {
+			email.From = address
+		} else {
+			email.From = fmt.Sprintf("%s <%s>", email.From, address)
+		}
+	}
+
+	// The HTML body is stored as bytes in the message's code page; UTF-8 is
+	// by far the most common and Windows-1252 covers the rest
+	if html := root.binary(mapiBodyHTML); html != nil {
+		email.HTML = toUTF8(html, "windows-1252")
+	} else {
+		email.HTML = root.string(mapiBodyHTML)
+	}
+
+	for _, child := range cf.children(0) {
+		e := cf.entries[child]
+		if e.typ != cfbTypeStorage || !strings.HasPrefix(e.name, "__attach_version1.0_") {
+			continue
+		}
+		attachment := newMSGStorage(cf, child)
+		name := attachment.string(mapiAttachLongName)
+		if name == "" {
+			name = attachment.string(mapiAttachName)
+		}
+		if name == "" {
+			name = attachment.string(mapiAttachDisplayName)
+		}
+		if name == "" {
+			name = "attachment"
+		}
+		// Embedded messages and OLE objects have no data stream; they are
+		// still listed
+		email.Attachments = append(email.Attachments, EmailAttachment{
+			Name:        filepath.Base(name),
+			ContentType: attachment.string(mapiAttachMIMEType),
+			ContentID:   attachment.string(mapiAttachContentID),
+			Data:        attachment.binary(mapiAttachData),
+		})
+	}
+	return email, nil
+}
+
+// emailPage is what templates/email.html renders.
+type emailPage struct {
+	*Email
+	Body        template.HTML
+	Attachments []EmailAttachment
+}
+
+// renderEmail builds the HTML page Chromium prints: a header block, the
+// list of attachments and the body. Inline images are returned as extra
+// files keyed by the name the page refers to them by.
+func renderEmail(email *Email) ([]byte, map[string][]byte, error) {
+	page := emailPage{Email: email}
+	resources := make(map[string][]byte)
+
+	body := email.HTML
+	for i, a := range email.Attachments {
+		if !a.Inline {
+			page.Attachments = append(page.Attachments, a)
+			continue
+		}
+		name := fmt.Sprintf("inline-%03d%s", i+1, strings.ToLower(filepath.Ext(a.Name)))
+		resources[name] = a.Data
+		body = strings.ReplaceAll(body, "cid:"+a.ContentID, name)
+	}
+
+	if body != "" {
+		page.Body = template.HTML(metaRefreshPattern.ReplaceAllString(body, ""))
+	} else {
+		page.Body = template.HTML("<pre>" + template.HTMLEscapeString(email.Text) + "</pre>")
+	}
+
+	var out bytes.Buffer
+	if err := emailTemplate.Execute(&out, page); err != nil {
+		return nil, nil, fmt.Errorf("failed to render email: %w", err)
+	}
+	return out.Bytes(), resources, nil
+}
+
+func formatSize(a EmailAttachment) string {
+	n := len(a.Data)
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+const testEML = "From: =?UTF-8?Q?J=C3=BCrgen?= <j@example.com>\r\n" +
+	"To: team@example.com\r\n" +
+	"Subject: Quarterly <report>\r\n" +
+	"Date: Tue, 03 Mar 2026 09:30:00 +0100\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/related; boundary=rel\r\n" +
+	"\r\n" +
+	"--rel\r\n" +
+	"Content-Type: multipart/alternative; boundary=alt\r\n" +
+	"\r\n" +
+	"--alt\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Gr=C3=BC=C3=9Fe\r\n" +
+	"--alt\r\n" +
+	"Content-Type: text/html; charset=iso-8859-1\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"<meta http-equiv=3D\"refresh\" content=3D\"0;url=3Dfile:///etc/passwd\"><p>Gr=FC=DFe</p><img src=3D\"cid:logo@x\">\r\n" +
+	"--alt--\r\n" +
+	"--rel\r\n" +
+	"Content-Type: image/png\r\n" +
+	"Content-ID: <logo@x>\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"iVBORw0KGgo=\r\n" +
+	"--rel--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"Content-Disposition: attachment; filename*=UTF-8''Rechnung%20M%C3%A4rz.pdf\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjcK\r\n" +
+	"--outer--\r\n"
+
+func writeTestFile(t *testing.T, name string, content []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestParseEmail_EML(t *testing.T) {
+	t.Parallel()
+
+	email, err := ParseEmail(writeTestFile(t, "mail.eml", []byte(testEML)), "eml")
+	if err != nil {
+		t.Fatalf("ParseEmail failed: %v", err)
+	}
+
+	if email.From != "Jürgen <j@example.com>" || email.Subject != "Quarterly <report>" {
+		t.Errorf("unexpected headers: from=%q subject=%q", email.From, email.Subject)
+	}
+	if !email.Date.Equal(time.Date(2026, 3, 3, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected date: %v", email.Date)
+	}
+	if strings.TrimSpace(email.Text) != "Grüße" {
+		t.Errorf("unexpected text body: %q", email.Text)
+	}
+	if !strings.Contains(email.HTML, "<p>Grüße</p>") {
+		t.Errorf("expected the Latin-1 HTML body to be converted, got %q", email.HTML)
+	}
+
+	if len(email.Attachments) != 2 {
+		t.Fatalf("expected 2 attachments, got %d", len(email.Attachments))
+	}
+	logo, invoice := email.Attachments[0], email.Attachments[1]
+	if !logo.Inline || logo.ContentID != "logo@x" || !bytes.HasPrefix(logo.Data, []byte("\x89PNG")) {
+		t.Errorf("unexpected inline image: %+v", logo)
+	}
+	if invoice.Inline || invoice.Name != "Rechnung März.pdf" || string(invoice.Data) != "%PDF-1.7\n" {
+		t.Errorf("unexpected attachment: %+v", invoice)
+	}
+}
+
+func TestRenderEmail(t *testing.T) {
+	t.Parallel()
+
+	email, err := ParseEmail(writeTestFile(t, "mail.eml", []byte(testEML)), "eml")
+	if err != nil {
+		t.Fatalf("ParseEmail failed: %v", err)
+	}
+	page, resources, err := renderEmail(email)
+	if err != nil {
+		t.Fatalf("renderEmail failed: %v", err)
+	}
+	html := string(page)
+
+	for _, want := range []string{
+		"Quarterly &lt;report&gt;",
+		"Jürgen &lt;j@example.com&gt;",
+		"Rechnung März.pdf (9 bytes)",
+		`<img src="inline-001.png">`,
+		"Content-Security-Policy",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected page to contain %q", want)
+		}
+	}
+	if strings.Contains(html, "refresh") {
+		t.Error("expected meta refresh to be stripped")
+	}
+	if _, ok := resources["inline-001.png"]; !ok || len(resources) != 1 {
+		t.Errorf("expected one inline resource, got %v", resources)
+	}
+
+	text, _, err := renderEmail(&Email{Subject: "Plain", Text: "a < b"})
+	if err != nil {
+		t.Fatalf("renderEmail failed: %v", err)
+	}
+	if !strings.Contains(string(text), "<pre>a &lt; b</pre>") {
+		t.Errorf("expected escaped text body, got %s", text)
+	}
+}
+
+// cfbNode is a storage or stream for buildCompoundFile.
+type cfbNode struct {
+	name     string
+	data     []byte
+	children []cfbNode
+}
+
+// buildCompoundFile writes a version 3 compound file with every stream in
+// the mini stream: header, one FAT sector, the directory, the mini FAT and
+// the mini stream.
+func buildCompoundFile(t *testing.T, children []cfbNode) []byte {
+	t.Helper()
+	le := binary.LittleEndian
+
+	type flat struct {
+		node  cfbNode
+		typ   byte
+		child uint32
+		right uint32
+		start uint32
+	}
+	entries := []flat{{node: cfbNode{name: "Root Entry", children: children}, typ: cfbTypeRoot, child: cfbNoStream, right: cfbNoStream}}
+	var miniStream []byte
+	var miniFAT []uint32
+	for i := 0; i < len(entries); i++ {
+		if entries[i].typ == 0 {
+			entries[i].typ = cfbTypeStream
+			if entries[i].node.children != nil {
+				entries[i].typ = cfbTypeStorage
+			}
+		}
+		if entries[i].typ == cfbTypeStream {
+			entries[i].start = cfbEndOfChain
+			if n := len(entries[i].node.data); n > 0 {
+				entries[i].start = uint32(len(miniFAT))
+				sectors := (n + 63) / 64
+				for s := 0; s < sectors; s++ {
+					next := uint32(len(miniFAT) + 1)
+					if s == sectors-1 {
+						next = cfbEndOfChain
+					}
+					miniFAT = append(miniFAT, next)
+				}
+				padded := make([]byte, sectors*64)
+				copy(padded, entries[i].node.data)
+				miniStream = append(miniStream, padded...)
+			}
+		}
+		// Siblings are chained through their right pointers
+		for j, child := range entries[i].node.children {
+			id := uint32(len(entries))
+			if j == 0 {
+				entries[i].child = id
+			} else {
+				entries[id-1].right = id
+			}
+			entries = append(entries, flat{node: child, child: cfbNoStream, right: cfbNoStream})
+		}
+	}
+
+	dirSectors := (len(entries)*cfbDirSize + 511) / 512
+	miniFATSectors := (len(miniFAT)*4 + 511) / 512
+	streamSectors := (len(miniStream) + 511) / 512
+	if 1+dirSectors+miniFATSectors+streamSectors > 128 {
+		t.Fatal("test compound file too large")
+	}
+
+	fat := make([]uint32, 128)
+	for i := range fat {
+		fat[i] = cfbNoStream
+	}
+	fat[0] = 0xFFFFFFFD // FAT sector
+	next := uint32(1)
+	chain := func(n int) uint32 {
+		if n == 0 {
+			return cfbEndOfChain
+		}
+		start := next
+		for i := 0; i < n; i++ {
+			fat[next] = next + 1
+			if i == n-1 {
+				fat[next] = cfbEndOfChain
+			}
+			next++
+		}
+		return start
+	}
+	dirStart := chain(dirSectors)
+	miniFATStart := chain(miniFATSectors)
+	streamStart := chain(streamSectors)
+
+	header := make([]byte, 512)
+	copy(header, cfbSignature)
+	le.PutUint16(header[0x1A:], 0x3E)
+	le.PutUint16(header[0x1C:], 3)
+	le.PutUint16(header[0x1E:], 9)
+	le.PutUint16(header[0x20:], 6)
+	le.PutUint32(header[0x2C:], 1)
+	le.PutUint32(header[0x30:], dirStart)
+	le.PutUint32(header[0x38:], 4096)
+	le.PutUint32(header[0x3C:], miniFATStart)
+	le.PutUint32(header[0x40:], uint32(miniFATSectors))
+	le.PutUint32(header[0x44:], cfbEndOfChain)
+	for i := 0; i < 109; i++ {
+		le.PutUint32(header[0x4C+i*4:], cfbNoStream)
+	}
+	le.PutUint32(header[0x4C:], 0)
+
+	out := bytes.NewBuffer(header)
+	sector := make([]byte, 512)
+	for i, v := range fat {
+		le.PutUint32(sector[i*4:], v)
+	}
+	out.Write(sector)
+
+	dir := make([]byte, dirSectors*512)
+	for i, e := range entries {
+		b := dir[i*cfbDirSize:]
+		name := utf16.Encode([]rune(e.node.name))
+		for j, u := range name {
+			le.PutUint16(b[j*2:], u)
+		}
+		le.PutUint16(b[0x40:], uint16(len(name)*2+2))
+		b[0x42] = e.typ
+		le.PutUint32(b[0x44:], cfbNoStream)
+		le.PutUint32(b[0x48:], e.right)
+		le.PutUint32(b[0x4C:], e.child)
+		switch e.typ {
+		case cfbTypeRoot:
+			le.PutUint32(b[0x74:], streamStart)
+			le.PutUint64(b[0x78:], uint64(len(miniStream)))
+		case cfbTypeStream:
+			le.PutUint32(b[0x74:], e.start)
+			le.PutUint64(b[0x78:], uint64(len(e.node.data)))
+		}
+	}
+	out.Write(dir)
+
+	miniFATBytes := make([]byte, miniFATSectors*512)
+	for i := range miniFATBytes {
+		miniFATBytes[i] = 0xFF
+	}
+	for i, v := range miniFAT {
+		le.PutUint32(miniFATBytes[i*4:], v)
+	}
+	out.Write(miniFATBytes)
+
+	padded := make([]byte, streamSectors*512)
+	copy(padded, miniStream)
+	out.Write(padded)
+	return out.Bytes()
+}
+
+func utf16Bytes(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+func TestParseEmail_MSG(t *testing.T) {
+	t.Parallel()
+
+	// 2026-03-03T08:30:00Z as a FILETIME
+	props := make([]byte, 32+16)
+	binary.LittleEndian.PutUint32(props[32:], mapiClientSubmitTime<<16|mapiTypeSystime)
+	binary.LittleEndian.PutUint64(props[40:], uint64(time.Date(2026, 3, 3, 8, 30, 0, 0, time.UTC).UnixNano()/100+116444736000000000))
+
+	data := buildCompoundFile(t, []cfbNode{
+		{name: "__properties_version1.0", data: props},
+		{name: "__substg1.0_0037001F", data: utf16Bytes("Quarterly report")},
+		{name: "__substg1.0_0C1A001F", data: utf16Bytes("Jürgen")},
+		{name: "__substg1.0_5D01001F", data: utf16Bytes("j@example.com")},
+		{name: "__substg1.0_0E04001F", data: utf16Bytes("Team")},
+		{name: "__substg1.0_1000001F", data: utf16Bytes(strings.Repeat("Numbers attached. ", 10))},
+		{name: "__attach_version1.0_#00000000", children: []cfbNode{
+			{name: "__substg1.0_3707001F", data: utf16Bytes("figures.xlsx")},
+			{name: "__substg1.0_37010102", data: []byte("PK\x03\x04 spreadsheet")},
+		}},
+	})
+
+	email, err := ParseEmail(writeTestFile(t, "mail.msg", data), "msg")
+	if err != nil {
+		t.Fatalf("ParseEmail failed: %v", err)
+	}
+	if email.Subject != "Quarterly report" || email.From != "Jürgen <j@example.com>" || email.To != "Team" {
+		t.Errorf("unexpected headers: %+v", email)
+	}
+	if !strings.HasPrefix(email.Text, "Numbers attached.") || len(email.Text) != 180 {
+		t.Errorf("unexpected body: %q", email.Text)
+	}
+	if !email.Date.Equal(time.Date(2026, 3, 3, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected date: %v", email.Date)
+	}
+	if len(email.Attachments) != 1 || email.Attachments[0].Name != "figures.xlsx" || string(email.Attachments[0].Data) != "PK\x03\x04 spreadsheet" {
+		t.Errorf("unexpected attachments: %+v", email.Attachments)
+	}
+}
+
+func TestParseEmail_RejectsCorruptMSG(t *testing.T) {
+	t.Parallel()
+
+	data := append(append([]byte{}, cfbSignature...), make([]byte, 600)...)
+	if _, err := ParseEmail(writeTestFile(t, "mail.msg", data), "msg"); err == nil {
+		t.Fatal("expected a corrupt compound file to be rejected")
+	}
+}
+
+func TestGotenbergService_ConvertEmail(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/forms/chromium/convert/html" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("failed to parse form: %v", err)
+		}
+		names := map[string]bool{}
+		for _, fh := range r.MultipartForm.File["files"] {
+			names[fh.Filename] = true
+		}
+		if !names["index.html"] || !names["inline-001.png"] {
+			t.Errorf("unexpected files: %v", names)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("%PDF-1.7")),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	inputPath := writeTestFile(t, "mail.eml", []byte(testEML))
+	email, err := ParseEmail(inputPath, "eml")
+	if err != nil {
+		t.Fatalf("ParseEmail failed: %v", err)
+	}
+	outputPath, err := svc.ConvertEmail(context.Background(), inputPath, email, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("ConvertEmail failed: %v", err)
+	}
+	if outputPath != inputPath+".converted.pdf" {
+		t.Errorf("unexpected output path: %s", outputPath)
+	}
+}
+
+func TestGotenbergService_MergeKeepsOrder(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/forms/pdfengines/merge" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("failed to parse form: %v", err)
+		}
+		var got []string
+		for _, fh := range r.MultipartForm.File["files"] {
+			f, _ := fh.Open()
+			content, _ := io.ReadAll(f)
+			f.Close()
+			got = append(got, fh.Filename+"="+string(content))
+		}
+		if strings.Join(got, ",") != "000.pdf=body,001.pdf=attachment" {
+			t.Errorf("unexpected merge parts: %v", got)
+		}
+		if r.FormValue("pdfa") != DefaultPDFAConformance {
+			t.Errorf("expected merged output to be PDF/A, got %q", r.FormValue("pdfa"))
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("%PDF-1.7")),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	dir := t.TempDir()
+	body := filepath.Join(dir, "z-body.pdf")
+	attachment := filepath.Join(dir, "a-attachment.pdf")
+	os.WriteFile(body, []byte("body"), 0644)
+	os.WriteFile(attachment, []byte("attachment"), 0644)
+
+	if err := svc.Merge(context.Background(), []string{body, attachment}, filepath.Join(dir, "merged.pdf"), ConvertOptions{}); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+}
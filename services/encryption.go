@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"converter/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+const (
+	EncryptionAlgorithm = "AES-256-GCM-CHUNKED"
+	encryptionChunkSize = 64 * 1024
+	encryptionMagic     = "PPENC1"
+)
+
+// DataKey is a per-job envelope key: the plaintext encrypts outputs and is
+// discarded, the ciphertext (wrapped by the tenant's KMS key) is recorded.
+type DataKey struct {
+	KeyID      string
+	Plaintext  []byte
+	Ciphertext []byte
+}
+
+// EncryptionMetadata is stored alongside the conversion so consumers can
+// unwrap the data key with KMS and decrypt the object.
+type EncryptionMetadata struct {
+	Algorithm        string `json:"algorithm"`
+	KMSKeyID         string `json:"kmsKeyId"`
+	EncryptedDataKey string `json:"encryptedDataKey"`
+	ChunkSize        int    `json:"chunkSize"`
+}
+
+type EncryptionService struct {
+	kms *kms.Client
+}
+
+func NewEncryptionService(awsCfg aws.Config, cfg *config.Config) *EncryptionService {
+	// An empty endpoint falls back to the regional AWS KMS endpoint even when
+	// S3 is pointed at MinIO/Ceph
+	return &EncryptionService{
+		kms: kms.NewFromConfig(awsCfg, func(o *kms.Options) {
+			if cfg.KMSEndpoint != "" {
+				o.BaseEndpoint = aws.String(cfg.KMSEndpoint)
+			}
+		}),
+	}
+}
+
+func (e *EncryptionService) GenerateDataKey(ctx context.Context, kmsKeyID string) (*DataKey, error) {
+	out, err := e.kms.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(kmsKeyID),
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	return &DataKey{
+		KeyID:      aws.ToString(out.KeyId),
+		Plaintext:  out.Plaintext,
+		Ciphertext: out.CiphertextBlob,
+	}, nil
+}
+
+func (k *DataKey) Metadata() EncryptionMetadata {
+	return EncryptionMetadata{
+		Algorithm:        EncryptionAlgorithm,
+		KMSKeyID:         k.KeyID,
+		EncryptedDataKey: base64.StdEncoding.EncodeToString(k.Ciphertext),
+		ChunkSize:        encryptionChunkSize,
+	}
+}
+
+// EncryptFile seals src into dst as a sequence of GCM chunks. Each chunk's
+// nonce is derived from a random per-file base and the chunk index, and the
+// final chunk is flagged in its AAD so truncation is detected.
+func EncryptFile(src string, dst string, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open plaintext: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create ciphertext: %w", err)
+	}
+	defer out.Close()
+
+	baseNonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(baseNonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	if _, err := out.Write(append([]byte(encryptionMagic), baseNonce...)); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	buf := make([]byte, encryptionChunkSize)
+	next := make([]byte, encryptionChunkSize)
+	n, err := io.ReadFull(in, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("failed to read plaintext: %w", err)
+	}
+
+	for index := uint64(0); ; index++ {
+		m, err := io.ReadFull(in, next)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read plaintext: %w", err)
+		}
+		final := m == 0
+
+		sealed := gcm.Seal(nil, chunkNonce(baseNonce, index), buf[:n], chunkAAD(index, final))
+		if _, err := out.Write(sealed); err != nil {
+			return fmt.Errorf("failed to write ciphertext: %w", err)
+		}
+
+		if final {
+			return nil
+		}
+		buf, next = next, buf
+		n = m
+	}
+}
+
+// DecryptFile reverses EncryptFile.
+func DecryptFile(src string, dst string, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open ciphertext: %w", err)
+	}
+	defer in.Close()
+
+	header := make([]byte, len(encryptionMagic)+gcm.NonceSize())
+	if _, err := io.ReadFull(in, header); err != nil || string(header[:len(encryptionMagic)]) != encryptionMagic {
+		return errors.New("ciphertext header is missing or invalid")
+	}
+	baseNonce := header[len(encryptionMagic):]
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create plaintext: %w", err)
+	}
+	defer out.Close()
+
+	sealedSize := encryptionChunkSize + gcm.Overhead()
+	buf := make([]byte, sealedSize)
+	next := make([]byte, sealedSize)
+	n, err := io.ReadFull(in, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return errors.New("ciphertext is truncated")
+	}
+
+	for index := uint64(0); ; index++ {
+		m, err := io.ReadFull(in, next)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read ciphertext: %w", err)
+		}
+		final := m == 0
+
+		plain, err := gcm.Open(nil, chunkNonce(baseNonce, index), buf[:n], chunkAAD(index, final))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt chunk %d: %w", index, err)
+		}
+		if _, err := out.Write(plain); err != nil {
+			return fmt.Errorf("failed to write plaintext: %w", err)
+		}
+
+		if final {
+			return nil
+		}
+		buf, next = next, buf
+		n = m
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(base []byte, index uint64) []byte {
+	nonce := make([]byte, len(base))
+	copy(nonce, base)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^index)
+	return nonce
+}
+
+func chunkAAD(index uint64, final bool) []byte {
+	aad := make([]byte, 9)
+	binary.BigEndian.PutUint64(aad, index)
+	if final {
+		aad[8] = 1
+	}
+	return aad
+}
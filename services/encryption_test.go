@@ -0,0 +1,68 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptFile_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	for _, size := range []int{0, 10, encryptionChunkSize, encryptionChunkSize*2 + 7} {
+		dir := t.TempDir()
+		plain := make([]byte, size)
+		_, _ = rand.Read(plain)
+
+		src := filepath.Join(dir, "plain")
+		enc := filepath.Join(dir, "enc")
+		dec := filepath.Join(dir, "dec")
+		if err := os.WriteFile(src, plain, 0644); err != nil {
+			t.Fatalf("failed to write plaintext: %v", err)
+		}
+
+		if err := EncryptFile(src, enc, key); err != nil {
+			t.Fatalf("EncryptFile(%d bytes) failed: %v", size, err)
+		}
+		if err := DecryptFile(enc, dec, key); err != nil {
+			t.Fatalf("DecryptFile(%d bytes) failed: %v", size, err)
+		}
+
+		got, _ := os.ReadFile(dec)
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("round trip mismatch for %d bytes", size)
+		}
+	}
+}
+
+func TestDecryptFile_DetectsTruncation(t *testing.T) {
+	t.Parallel()
+
+	key := make([]byte, 32)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "plain")
+	enc := filepath.Join(dir, "enc")
+	if err := os.WriteFile(src, make([]byte, encryptionChunkSize*2), 0644); err != nil {
+		t.Fatalf("failed to write plaintext: %v", err)
+	}
+	if err := EncryptFile(src, enc, key); err != nil {
+		t.Fatalf("EncryptFile failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(enc)
+	truncated := data[:len(data)-(encryptionChunkSize+16)]
+	if err := os.WriteFile(enc, truncated, 0644); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+
+	if err := DecryptFile(enc, filepath.Join(dir, "dec"), key); err == nil {
+		t.Fatal("expected truncated ciphertext to fail")
+	}
+}
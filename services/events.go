@@ -0,0 +1,377 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"converter/config"
+	"converter/metrics"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+func init() {
+	metrics.Describe("conversion_events_total", "Conversion events delivered, by route type and outcome")
+}
+
+// Event route types.
+const (
+	EventRouteNone    = "none"
+	EventRouteWebhook = "webhook"
+	EventRouteSNS     = "sns"
+	EventRoutePubSub  = "pubsub"
+)
+
+// Conversion event names.
+const (
+	EventConversionCompleted = "conversion.completed"
+	EventConversionFailed    = "conversion.failed"
+	// A completed conversion's outputs were moved to or back from the trash.
+	EventConversionTrashed  = "conversion.trashed"
+	EventConversionRestored = "conversion.restored"
+)
+
+const (
+	defaultPubSubEndpoint = "https://pubsub.googleapis.com"
+	gceTokenURL           = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// EventRoute says where a tenant's conversion events go. Topic is an SNS
+// topic ARN or a Pub/Sub topic of the form projects/<project>/topics/<name>.
+// Webhook bodies are signed with Secret when it is set.
+type EventRoute struct {
+	Type   string `json:"type"`
+	URL    string `json:"url,omitempty"`
+	Topic  string `json:"topic,omitempty"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// Validate checks that the route has the destination its type needs.
+func (r EventRoute) Validate() error {
+	switch r.Type {
+	case "", EventRouteNone:
+		return nil
+	case EventRouteWebhook:
+		if r.URL == "" {
+			return fmt.Errorf("webhook route requires a url")
+		}
+	case EventRouteSNS, EventRoutePubSub:
+		if r.Topic == "" {
+			return fmt.Errorf("%s route requires a topic", r.Type)
+		}
+	default:
+		return fmt.Errorf("unknown event route type %q", r.Type)
+	}
+	return nil
+}
+
+// ConversionEvent is the payload published when a conversion reaches a
+// terminal state.
+type ConversionEvent struct {
+	Event        string `json:"event"`
+	ConversionID int    `json:"conversionId"`
+	FileGUID     string `json:"fileGuid"`
+	UserID       int    `json:"userId"`
+	Status       string `json:"status"`
+	OutputS3Path string `json:"outputS3Path,omitempty"`
+	Error        string `json:"error,omitempty"`
+	// DurationMs is the time from the job's creation to this event.
+	DurationMs int64     `json:"durationMs,omitempty"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// EventRouter resolves a tenant's route at publish time, so route changes
+// in the tenant config take effect within one cache TTL without a restart.
+type EventRouter struct {
+	tenants      *TenantConfigs
+	defaultRoute EventRoute
+	callback     callbackSettings
+	http         *http.Client
+	sns          *sns.Client
+
+	pubsubEndpoint string
+	tokenMu        sync.Mutex
+	token          string
+	tokenExpiry    time.Time
+}
+
+func NewEventRouter(awsCfg aws.Config, cfg *config.Config, tenants *TenantConfigs) *EventRouter {
+	r := &EventRouter{
+		tenants: tenants,
+		defaultRoute: EventRoute{
+			Type:   cfg.EventsRoute,
+			URL:    cfg.EventsWebhookURL,
+			Topic:  cfg.EventsTopic,
+			Secret: cfg.EventsWebhookSecret,
+		},
+		http: &http.Client{Timeout: time.Duration(cfg.EventsTimeout) * time.Second},
+		sns: sns.NewFromConfig(awsCfg, func(o *sns.Options) {
+			if cfg.SNSEndpoint != "" {
+				o.BaseEndpoint = aws.String(cfg.SNSEndpoint)
+			}
+		}),
+		pubsubEndpoint: cfg.EventsPubSubEndpoint,
+		callback: callbackSettings{
+			secret:       cfg.CallbackSecret,
+			maxAttempts:  cfg.CallbackMaxAttempts,
+			allowedHosts: cfg.CallbackAllowedHosts,
+		},
+	}
+	if r.pubsubEndpoint == "" {
+		r.pubsubEndpoint = defaultPubSubEndpoint
+	}
+	return r
+}
+
+// DefaultRoute is the deployment-wide route from the environment.
+func (r *EventRouter) DefaultRoute() EventRoute {
+	return r.defaultRoute
+}
+
+// Route returns the tenant's own route when it has one, else the
+// deployment default.
+func (r *EventRouter) Route(ctx context.Context, tenantID int) EventRoute {
+	if tc := r.tenants.Get(ctx, tenantID); tc.Events != nil {
+		return *tc.Events
+	}
+	return r.defaultRoute
+}
+
+// Publish delivers event to the tenant's route. A "none" route is a no-op.
+func (r *EventRouter) Publish(ctx context.Context, tenantID int, event ConversionEvent) error {
+	route := r.Route(ctx, tenantID)
+	if route.Type == "" || route.Type == EventRouteNone {
+		return nil
+	}
+	if err := route.Validate(); err != nil {
+		metrics.Inc("conversion_events_total", "route", route.Type, "outcome", "invalid")
+		return err
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	switch route.Type {
+	case EventRouteWebhook:
+		err = r.publishWebhook(ctx, route, event.Event, body)
+	case EventRouteSNS:
+		err = r.publishSNS(ctx, route, event.Event, body)
+	case EventRoutePubSub:
+		err = r.publishPubSub(ctx, route, event.Event, body)
+	}
+
+	if err != nil {
+		metrics.Inc("conversion_events_total", "route", route.Type, "outcome", "error")
+		return err
+	}
+	metrics.Inc("conversion_events_total", "route", route.Type, "outcome", "delivered")
+	return nil
+}
+
+// Callback POSTs event to a job's own callbackUrl, signed with
+// CALLBACK_SECRET like a webhook route, retrying failed deliveries with a
+// doubling backoff up to CALLBACK_MAX_ATTEMPTS times.
+func (r *EventRouter) Callback(ctx context.Context, callbackURL string, event ConversionEvent) error {
+	if err := r.callback.validate(callbackURL); err != nil {
+		return err
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	route := EventRoute{Type: EventRouteWebhook, URL: callbackURL, Secret: r.callback.secret}
+	attempts := r.callback.maxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		err = r.publishWebhook(ctx, route, event.Event, body)
+		if err == nil {
+			metrics.Inc("conversion_events_total", "route", "callback", "outcome", "delivered")
+			return nil
+		}
+		if attempt >= attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			metrics.Inc("conversion_events_total", "route", "callback", "outcome", "error")
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	metrics.Inc("conversion_events_total", "route", "callback", "outcome", "error")
+	return fmt.Errorf("callback failed after %d attempts: %w", attempts, err)
+}
+
+// ValidateCallbackURL checks a job's callbackUrl: http(s) only and, when
+// CALLBACK_ALLOWED_HOSTS is set, one of those hosts.
+func (r *EventRouter) ValidateCallbackURL(callbackURL string) error {
+	return r.callback.validate(callbackURL)
+}
+
+type callbackSettings struct {
+	secret       string
+	maxAttempts  int
+	allowedHosts []string
+}
+
+func (c callbackSettings) validate(callbackURL string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("callbackUrl must be an absolute http(s) URL")
+	}
+	if len(c.allowedHosts) == 0 {
+		return nil
+	}
+	for _, host := range c.allowedHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("callbackUrl host %s is not allowed", u.Hostname())
+}
+
+func (r *EventRouter) publishWebhook(ctx context.Context, route EventRoute, name string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", route.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Pulse-Event", name)
+	if route.Secret != "" {
+		req.Header.Set("X-Pulse-Signature", "sha256="+SignWebhook(route.Secret, body))
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of body, sent as
+// X-Pulse-Signature: sha256=<hex> so receivers can verify the sender.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (r *EventRouter) publishSNS(ctx context.Context, route EventRoute, name string, body []byte) error {
+	_, err := r.sns.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(route.Topic),
+		Message:  aws.String(string(body)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"event": {DataType: aws.String("String"), StringValue: aws.String(name)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to SNS: %w", err)
+	}
+	return nil
+}
+
+func (r *EventRouter) publishPubSub(ctx context.Context, route EventRoute, name string, body []byte) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"messages": []map[string]interface{}{{
+			"data":       base64.StdEncoding.EncodeToString(body),
+			"attributes": map[string]string{"event": name},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal pubsub message: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s:publish", strings.TrimSuffix(r.pubsubEndpoint, "/"), route.Topic)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create pubsub request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// The emulator (any non-default endpoint) takes no credentials
+	if r.pubsubEndpoint == defaultPubSubEndpoint {
+		token, err := r.gceToken(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("pubsub request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("pubsub returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
+
+// gceToken fetches an access token for the instance's service account from
+// the GCE/GKE metadata server, cached until shortly before it expires.
+func (r *EventRouter) gceToken(ctx context.Context) (string, error) {
+	r.tokenMu.Lock()
+	defer r.tokenMu.Unlock()
+
+	if r.token != "" && time.Now().Before(r.tokenExpiry) {
+		return r.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", gceTokenURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch pubsub token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode pubsub token: %w", err)
+	}
+
+	r.token = token.AccessToken
+	r.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return r.token, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestRouter(defaultRoute EventRoute, tenants map[int]TenantConfig) *EventRouter {
+	return &EventRouter{
+		tenants: &TenantConfigs{
+			cacheTTL: time.Hour,
+			tenants:  tenants,
+			loadedAt: time.Now(),
+		},
+		defaultRoute: defaultRoute,
+		http:         &http.Client{Timeout: time.Second},
+	}
+}
+
+func TestEventRoute_Validate(t *testing.T) {
+	t.Parallel()
+
+	valid := []EventRoute{
+		{},
+		{Type: EventRouteNone},
+		{Type: EventRouteWebhook, URL: "https://example.com/hook"},
+		{Type: EventRouteSNS, Topic: "arn:aws:sns:eu-west-1:123:conversions"},
+		{Type: EventRoutePubSub, Topic: "projects/p/topics/conversions"},
+	}
+	for _, route := range valid {
+		if err := route.Validate(); err != nil {
+			t.Errorf("expected %+v to be valid, got %v", route, err)
+		}
+	}
+
+	invalid := []EventRoute{
+		{Type: EventRouteWebhook},
+		{Type: EventRouteSNS},
+		{Type: EventRoutePubSub},
+		{Type: "kafka", Topic: "conversions"},
+	}
+	for _, route := range invalid {
+		if err := route.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", route)
+		}
+	}
+}
+
+func TestEventRouter_RouteFallsBackToDefault(t *testing.T) {
+	t.Parallel()
+
+	tenantRoute := EventRoute{Type: EventRouteSNS, Topic: "arn:aws:sns:eu-west-1:123:tenant-7"}
+	router := newTestRouter(
+		EventRoute{Type: EventRouteWebhook, URL: "https://example.com/hook"},
+		map[int]TenantConfig{7: {Events: &tenantRoute}, 8: {}},
+	)
+
+	if got := router.Route(context.Background(), 7); got != tenantRoute {
+		t.Fatalf("expected tenant route, got %+v", got)
+	}
+	if got := router.Route(context.Background(), 8); got.Type != EventRouteWebhook {
+		t.Fatalf("tenant without events config should use default, got %+v", got)
+	}
+	if got := router.Route(context.Background(), 9); got.Type != EventRouteWebhook {
+		t.Fatalf("unknown tenant should use default, got %+v", got)
+	}
+}
+
+func TestEventRouter_PublishWebhookSigned(t *testing.T) {
+	t.Parallel()
+
+	var gotEvent ConversionEvent
+	var gotSignature, gotName string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotSignature = r.Header.Get("X-Pulse-Signature")
+		gotName = r.Header.Get("X-Pulse-Event")
+		if gotSignature != "sha256="+SignWebhook("s3cret", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &gotEvent)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	route := EventRoute{Type: EventRouteWebhook, URL: srv.URL, Secret: "s3cret"}
+	router := newTestRouter(EventRoute{Type: EventRouteNone}, map[int]TenantConfig{42: {Events: &route}})
+
+	event := ConversionEvent{Event: EventConversionCompleted, ConversionID: 5, UserID: 42, OutputS3Path: "out/5.pdf"}
+	if err := router.Publish(context.Background(), 42, event); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if gotName != EventConversionCompleted || gotEvent.ConversionID != 5 || gotEvent.OutputS3Path != "out/5.pdf" {
+		t.Fatalf("unexpected delivery: name=%q event=%+v", gotName, gotEvent)
+	}
+
+	// Tenants on the default "none" route publish nothing
+	if err := router.Publish(context.Background(), 1, event); err != nil {
+		t.Fatalf("none route should be a no-op, got %v", err)
+	}
+}
+
+func TestEventRouter_PublishWebhookError(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	router := newTestRouter(EventRoute{Type: EventRouteWebhook, URL: srv.URL}, map[int]TenantConfig{})
+	if err := router.Publish(context.Background(), 1, ConversionEvent{Event: EventConversionFailed}); err == nil {
+		t.Fatal("expected non-2xx webhook response to fail")
+	}
+}
+
+func TestEventRouter_CallbackRetries(t *testing.T) {
+	t.Parallel()
+
+	attempts := 0
+	var gotEvent ConversionEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Pulse-Signature") != "sha256="+SignWebhook("cb-secret", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &gotEvent)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	router := newTestRouter(EventRoute{Type: EventRouteNone}, map[int]TenantConfig{})
+	router.callback = callbackSettings{secret: "cb-secret", maxAttempts: 2}
+
+	event := ConversionEvent{Event: EventConversionFailed, ConversionID: 9, Status: "failed", Error: "boom", DurationMs: 1500}
+	if err := router.Callback(context.Background(), srv.URL, event); err != nil {
+		t.Fatalf("Callback failed: %v", err)
+	}
+	if attempts != 2 || gotEvent.Status != "failed" || gotEvent.Error != "boom" || gotEvent.DurationMs != 1500 {
+		t.Fatalf("unexpected delivery after %d attempts: %+v", attempts, gotEvent)
+	}
+
+	router.callback.maxAttempts = 1
+	attempts = 0
+	if err := router.Callback(context.Background(), srv.URL, event); err == nil {
+		t.Fatal("expected a failed single attempt to return an error")
+	}
+}
+
+func TestCallbackSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	open := callbackSettings{}
+	if err := open.validate("https://app.example.com/hooks/pdf"); err != nil {
+		t.Errorf("expected https URL to be accepted, got %v", err)
+	}
+	for _, bad := range []string{"ftp://example.com/x", "/hooks/pdf", "not a url"} {
+		if err := open.validate(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+
+	restricted := callbackSettings{allowedHosts: []string{"app.example.com"}}
+	if err := restricted.validate("https://APP.example.com:8443/hooks"); err != nil {
+		t.Errorf("expected allowed host to be accepted, got %v", err)
+	}
+	if err := restricted.validate("http://169.254.169.254/latest"); err == nil {
+		t.Error("expected host outside CALLBACK_ALLOWED_HOSTS to be rejected")
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// FailedFilter selects failed queue entries. Its zero value selects all of
+// them.
+type FailedFilter struct {
+	// ConversionIDs selects these conversions only.
+	ConversionIDs []int
+	// UserID selects one tenant's conversions.
+	UserID int
+	// Match selects the conversions whose last error contains it.
+	Match string
+	// Limit caps the entries selected; 0 or less selects every match.
+	Limit int
+}
+
+// selectsAll reports whether the filter matches every entry, including
+// ones that don't parse.
+func (f FailedFilter) selectsAll() bool {
+	return len(f.ConversionIDs) == 0 && f.UserID == 0 && f.Match == ""
+}
+
+// FailedJobs returns the failed queue entries the filter selects, the most
+// recent failure first. Entries that don't parse only match a filter that
+// selects everything.
+func (a *QueueAdmin) FailedJobs(ctx context.Context, filter FailedFilter) ([]QueuedJob, error) {
+	raw, err := a.client.LRange(ctx, a.config.FailedQueue, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read failed queue: %w", err)
+	}
+
+	ids := make(map[int]bool, len(filter.ConversionIDs))
+	for _, id := range filter.ConversionIDs {
+		ids[id] = true
+	}
+
+	var selected []QueuedJob
+	for _, entry := range decodeEntries(raw) {
+		if filter.Limit > 0 && len(selected) >= filter.Limit {
+			break
+		}
+		if !filter.selectsAll() {
+			job := entry.Job
+			if job == nil || (len(ids) > 0 && !ids[job.ConversionID]) || (filter.UserID != 0 && job.UserID != filter.UserID) {
+				continue
+			}
+			if filter.Match != "" {
+				status, err := a.Status(ctx, job.ConversionID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read status of conversion %d: %w", job.ConversionID, err)
+				}
+				if !strings.Contains(status["error"], filter.Match) {
+					continue
+				}
+			}
+		}
+		selected = append(selected, entry)
+	}
+	return selected, nil
+}
+
+// PurgeFailed deletes the failed queue entries the filter selects and
+// returns how many were removed. The conversions stay failed.
+func (a *QueueAdmin) PurgeFailed(ctx context.Context, filter FailedFilter) (int64, error) {
+	entries, err := a.FailedJobs(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	var purged int64
+	for _, entry := range entries {
+		removed, err := a.client.LRem(ctx, a.config.FailedQueue, 1, entry.Raw).Result()
+		if err != nil {
+			return purged, fmt.Errorf("failed to remove job from failed queue: %w", err)
+		}
+		purged += removed
+	}
+	return purged, nil
+}
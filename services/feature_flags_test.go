@@ -0,0 +1,34 @@
+package services
+
+import "testing"
+
+func TestFeatureFlag_AppliesTo(t *testing.T) {
+	t.Parallel()
+
+	if (FeatureFlag{Enabled: false, Tenants: []int{1}}).appliesTo("f", 1) {
+		t.Fatal("disabled flag must not apply")
+	}
+	if !(FeatureFlag{Enabled: true}).appliesTo("f", 7) {
+		t.Fatal("enabled flag without targeting should apply to everyone")
+	}
+	if !(FeatureFlag{Enabled: true, Tenants: []int{7}}).appliesTo("f", 7) {
+		t.Fatal("listed tenant should be enabled")
+	}
+	if (FeatureFlag{Enabled: true, Tenants: []int{7}}).appliesTo("f", 8) {
+		t.Fatal("unlisted tenant should be disabled")
+	}
+
+	half := FeatureFlag{Enabled: true, Percentage: 50}
+	enabled := 0
+	for tenant := 0; tenant < 1000; tenant++ {
+		if half.appliesTo("rollout", tenant) {
+			enabled++
+		}
+		if half.appliesTo("rollout", tenant) != half.appliesTo("rollout", tenant) {
+			t.Fatal("rollout bucket must be stable")
+		}
+	}
+	if enabled < 400 || enabled > 600 {
+		t.Fatalf("expected roughly half of tenants enabled, got %d/1000", enabled)
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"converter/config"
+
+	"cloud.google.com/go/storage"
+	"github.com/googleapis/gax-go/v2/callctx"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// GCSService is the Storage for a Google Cloud Storage bucket. It
+// authenticates with GCS_CREDENTIALS_FILE when set, otherwise with the
+// application default credentials, such as a GKE workload identity.
+type GCSService struct {
+	client  *storage.Client
+	bucket  *storage.BucketHandle
+	headers []string
+}
+
+func NewGCSService(ctx context.Context, cfg *config.Config) (*GCSService, error) {
+	return newGCSService(ctx, cfg.GCSBucket, NewRequestIdentity(cfg), gcsOptions(cfg)...)
+}
+
+// gcsOptions are the client options for the deployment's credentials and
+// user agent.
+func gcsOptions(cfg *config.Config) []option.ClientOption {
+	var opts []option.ClientOption
+	if cfg.GCSCredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.GCSCredentialsFile))
+	}
+	if cfg.UserAgent != "" {
+		opts = append(opts, option.WithUserAgent(cfg.UserAgent))
+	}
+	return opts
+}
+
+func newGCSService(ctx context.Context, bucket string, identity RequestIdentity, opts ...option.ClientOption) (*GCSService, error) {
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	var headers []string
+	for k, v := range identity.Headers {
+		headers = append(headers, k, v)
+	}
+	return &GCSService{client: client, bucket: client.Bucket(bucket), headers: headers}, nil
+}
+
+// withIdentity adds the outbound headers to the calls made with ctx.
+func (g *GCSService) withIdentity(ctx context.Context) context.Context {
+	if len(g.headers) == 0 {
+		return ctx
+	}
+	return callctx.SetHeaders(ctx, g.headers...)
+}
+
+// Download stores the object at localPath, which the caller picks with a
+// TempLease.
+func (g *GCSService) Download(ctx context.Context, key string, localPath string) error {
+	reader, err := g.bucket.Object(key).NewReader(g.withIdentity(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to download from GCS: %w", gcsError(err))
+	}
+	defer reader.Close()
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("failed to download from GCS: %w", err)
+	}
+	return nil
+}
+
+func (g *GCSService) Upload(ctx context.Context, localPath string, key string, contentType string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return g.UploadStream(ctx, file, key, contentType)
+}
+
+func (g *GCSService) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	reader, err := g.bucket.Object(key).NewReader(g.withIdentity(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from GCS: %w", gcsError(err))
+	}
+	return reader, nil
+}
+
+func (g *GCSService) UploadStream(ctx context.Context, r io.Reader, key string, contentType string) error {
+	// Cancelling the writer's context is the only way to abandon an upload
+	// without leaving a partial object
+	ctx, cancel := context.WithCancel(g.withIdentity(ctx))
+	defer cancel()
+
+	writer := g.bucket.Object(key).NewWriter(ctx)
+	writer.ContentType = contentType
+	if _, err := io.Copy(writer, r); err != nil {
+		cancel()
+		writer.Close()
+		return fmt.Errorf("failed to upload to GCS: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to upload to GCS: %w", err)
+	}
+	return nil
+}
+
+// Stat reads the object's size and content type without downloading it.
+func (g *GCSService) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	attrs, err := g.bucket.Object(key).Attrs(g.withIdentity(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat GCS object: %w", gcsError(err))
+	}
+	return &ObjectInfo{Size: attrs.Size, ContentType: attrs.ContentType}, nil
+}
+
+func (g *GCSService) Copy(ctx context.Context, from string, to string) error {
+	_, err := g.bucket.Object(to).CopierFrom(g.bucket.Object(from)).Run(g.withIdentity(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to copy GCS object: %w", gcsError(err))
+	}
+	return nil
+}
+
+func (g *GCSService) Delete(ctx context.Context, key string) error {
+	err := g.bucket.Object(key).Delete(g.withIdentity(ctx))
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete GCS object: %w", err)
+	}
+	return nil
+}
+
+// Ping lists at most one object, which needs no more than the object
+// permissions conversions need anyway.
+func (g *GCSService) Ping(ctx context.Context) error {
+	it := g.bucket.Objects(g.withIdentity(ctx), &storage.Query{})
+	it.PageInfo().MaxSize = 1
+	if _, err := it.Next(); err != nil && !errors.Is(err, iterator.Done) {
+		return fmt.Errorf("failed to reach GCS bucket: %w", err)
+	}
+	return nil
+}
+
+func (g *GCSService) Cleanup(path string) error {
+	return removeLocal(path)
+}
+
+// gcsError maps a missing object to ErrObjectNotFound.
+func gcsError(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return ErrObjectNotFound
+	}
+	return err
+}
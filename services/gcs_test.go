@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/api/option"
+)
+
+func TestGCSService_Stat(t *testing.T) {
+	t.Parallel()
+
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		switch r.URL.Path {
+		case "/storage/v1/b/paperpulse/o/inputs/a.docx":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"bucket":"paperpulse","name":"inputs/a.docx","size":"2048","contentType":"application/msword"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":404,"message":"No such object"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	identity := RequestIdentity{Headers: map[string]string{"X-Instance-Id": "worker-1"}}
+	gcs, err := newGCSService(context.Background(), "paperpulse", identity,
+		option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := gcs.Stat(context.Background(), "inputs/a.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 2048 || info.ContentType != "application/msword" {
+		t.Errorf("Stat = %+v", info)
+	}
+	if got := headers.Get("X-Instance-Id"); got != "worker-1" {
+		t.Errorf("X-Instance-Id = %q, want worker-1", got)
+	}
+
+	if _, err := gcs.Stat(context.Background(), "inputs/missing.docx"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat of a missing object = %v, want ErrObjectNotFound", err)
+	}
+}
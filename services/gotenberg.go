@@ -1,37 +1,170 @@
 package services
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"converter/logging"
 )
 
 type GotenbergService struct {
-	baseURL string
-	client  *http.Client
+	baseURL          string
+	client           *http.Client
+	maxResponseBytes int64
+	identity         RequestIdentity
+	markdown         []byte
+	// apiVersion is the Gotenberg major version requests are written for;
+	// 0 until DetectVersion or SetAPIVersion, which means the current one.
+	apiVersion      int
+	breaker         *Breaker
+	lastLibreOffice atomic.Int64
 }
 
-const pdfaConformance = "PDF/A-2b"
+// DefaultPDFAConformance is used when neither the deployment nor the job
+// picks a level. PDF/A-2b is the modern archival standard with better
+// compression than 1b.
+const DefaultPDFAConformance = "PDF/A-2b"
+
+// pdfaConformanceLevels are the PDF/A levels Gotenberg's LibreOffice route
+// accepts.
+var pdfaConformanceLevels = []string{"PDF/A-1b", "PDF/A-2b", "PDF/A-3b"}
 
-func NewGotenbergService(baseURL string) *GotenbergService {
+// ValidPDFAConformance reports whether level is a PDF/A level Gotenberg can
+// produce.
+func ValidPDFAConformance(level string) bool {
+	for _, allowed := range pdfaConformanceLevels {
+		if level == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// SatisfiesConformance reports whether a PDF claiming the claimed level
+// meets the requested one. Levels a (accessible) and u (Unicode) are
+// stricter than b, so they satisfy b of the same part.
+func SatisfiesConformance(claimed string, requested string) bool {
+	if requested == "" {
+		requested = DefaultPDFAConformance
+	}
+	if claimed == "" || len(claimed) != len(requested) {
+		return false
+	}
+	part, level := claimed[:len(claimed)-1], claimed[len(claimed)-1:]
+	if part != requested[:len(requested)-1] {
+		return false
+	}
+	return level == requested[len(requested)-1:] || requested[len(requested)-1:] == "b"
+}
+
+// ErrPasswordRequired is returned when LibreOffice can't open a document
+// because it is password protected and no password, or a wrong one, was
+// given.
+var ErrPasswordRequired = errors.New("document is password protected")
+
+// passwordHints are the phrases of Gotenberg's 400 answers for a document
+// LibreOffice couldn't open for want of the right password.
+var passwordHints = []string{"password may be required", "password is required", "wrong password"}
+
+// passwordProtected reports whether a Gotenberg error body says the
+// document needs a password.
+func passwordProtected(body string) bool {
+	body = strings.ToLower(body)
+	for _, hint := range passwordHints {
+		if strings.Contains(body, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrResponseTooLarge is returned when Gotenberg's output exceeds the
+// configured maximum response size.
+var ErrResponseTooLarge = errors.New("gotenberg response exceeds maximum size")
+
+func NewGotenbergService(baseURL string, maxResponseBytes int64, identity RequestIdentity) *GotenbergService {
 	return &GotenbergService{
 		baseURL: baseURL,
 		client: &http.Client{
 			Timeout: 0, // Use context timeout instead
 		},
+		maxResponseBytes: maxResponseBytes,
+		identity:         identity,
+	}
+}
+
+// SetBreaker has every conversion request report to breaker: transport
+// errors and 5xx responses as failures, anything else as Gotenberg being
+// up.
+func (g *GotenbergService) SetBreaker(breaker *Breaker) {
+	g.breaker = breaker
+}
+
+// Health calls Gotenberg's /health route, which reports on its Chromium and
+// LibreOffice modules.
+func (g *GotenbergService) Health(ctx context.Context) error {
+	status, body, err := g.get(ctx, "/health")
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("gotenberg returned status %d: %s", status, body)
+	}
+	return nil
+}
+
+// ConvertOptions are per-job switches for the Gotenberg conversion routes.
+type ConvertOptions struct {
+	// Accessible requests tagged PDF/UA output (structure tree, alt text
+	// carried over from the source document).
+	Accessible bool
+	// Conformance is the PDF/A level to produce; DefaultPDFAConformance when
+	// empty.
+	Conformance string
+	// Flatten merges form fields and annotations into the page content so
+	// the output can't be edited.
+	Flatten bool
+	// Engine converts office documents with EngineSoffice instead of
+	// Gotenberg when set; other formats always go through Gotenberg.
+	Engine string
+	// Header and Footer are print templates for Chromium routes; see
+	// PrintTemplate.
+	Header string
+	Footer string
+	// Password opens a password-protected office document; only the
+	// LibreOffice route of Gotenberg 8 takes it.
+	Password string
+}
+
+func (g *GotenbergService) ConvertToPDFA(ctx context.Context, inputPath string, extension string, opts ConvertOptions) (string, error) {
+	body, contentType, err := g.libreOfficeForm(inputPath, opts)
+	if err != nil {
+		return "", err
+	}
+
+	outputPath := inputPath + ".converted.pdf"
+	if err := g.post(ctx, "/forms/libreoffice/convert", body, contentType, outputPath); err != nil {
+		return "", err
 	}
+	return outputPath, nil
 }
 
-func (g *GotenbergService) ConvertToPDFA(ctx context.Context, inputPath string, extension string) (string, error) {
+// libreOfficeForm builds the multipart form of the LibreOffice route.
+func (g *GotenbergService) libreOfficeForm(inputPath string, opts ConvertOptions) (*bytes.Buffer, string, error) {
 	// Open input file
 	file, err := os.Open(inputPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to open input file: %w", err)
+		return nil, "", fmt.Errorf("failed to open input file: %w", err)
 	}
 	defer file.Close()
 
@@ -42,53 +175,452 @@ func (g *GotenbergService) ConvertToPDFA(ctx context.Context, inputPath string,
 	// Add file
 	part, err := writer.CreateFormFile("files", filepath.Base(inputPath))
 	if err != nil {
-		return "", fmt.Errorf("failed to create form file: %w", err)
+		return nil, "", fmt.Errorf("failed to create form file: %w", err)
 	}
 
 	if _, err := io.Copy(part, file); err != nil {
-		return "", fmt.Errorf("failed to copy file: %w", err)
+		return nil, "", fmt.Errorf("failed to copy file: %w", err)
 	}
 
-	// Add PDF/A-2b option (modern archival standard with better compression)
-	writer.WriteField("pdfa", pdfaConformance)
+	g.writeOutputFields(writer, opts)
+	writePassword(writer, opts)
 
 	// Close writer
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to close writer: %w", err)
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
+// ConvertStream converts an office document read from r without touching
+// disk: the input is piped into the multipart request as it is read, and the
+// PDF comes back as the response body, failing with ErrResponseTooLarge once
+// it crosses the size limit. name is the file name Gotenberg sees, which
+// tells LibreOffice the format. The caller closes the returned body.
+func (g *GotenbergService) ConvertStream(ctx context.Context, r io.Reader, name string, opts ConvertOptions) (io.ReadCloser, error) {
+	bodyReader, bodyWriter := io.Pipe()
+	writer := multipart.NewWriter(bodyWriter)
+	go func() {
+		part, err := writer.CreateFormFile("files", name)
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			g.writeOutputFields(writer, opts)
+			writePassword(writer, opts)
+			err = writer.Close()
+		}
+		bodyWriter.CloseWithError(err)
+	}()
+
+	resp, err := g.send(ctx, "/forms/libreoffice/convert", bodyReader, writer.FormDataContentType())
+	if err != nil {
+		// Stops the writer if Gotenberg answered before reading everything
+		bodyReader.CloseWithError(err)
+		return nil, err
+	}
+	reader, err := g.pdfBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &limitedBody{reader: reader, closer: resp.Body, remaining: g.maxResponseBytes, limited: g.maxResponseBytes > 0}, nil
+}
+
+// limitedBody is a response body that fails with ErrResponseTooLarge once
+// more than the limit has been read.
+type limitedBody struct {
+	reader    io.Reader
+	closer    io.Closer
+	remaining int64
+	limited   bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if b.limited {
+		b.remaining -= int64(n)
+		if b.remaining < 0 {
+			return n, ErrResponseTooLarge
+		}
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.closer.Close()
+}
+
+// ConvertMarkdown renders a Markdown file to PDF/A through Chromium, wrapped
+// in the markdown HTML template, since LibreOffice would treat it as plain
+// text.
+func (g *GotenbergService) ConvertMarkdown(ctx context.Context, inputPath string, opts ConvertOptions) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := g.writeMarkdownFiles(writer, inputPath); err != nil {
+		return "", err
+	}
+
+	g.writeOutputFields(writer, opts)
+	if err := writePrintTemplates(writer, opts); err != nil {
+		return "", err
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	outputPath := inputPath + ".converted.pdf"
+	if err := g.post(ctx, "/forms/chromium/convert/markdown", body, writer.FormDataContentType(), outputPath); err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// writeMarkdownFiles adds the markdown template as index.html and the
+// document it renders.
+func (g *GotenbergService) writeMarkdownFiles(writer *multipart.Writer, inputPath string) error {
+	markdown, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{"index.html", g.markdownTemplate()},
+		{markdownFileName, markdown},
+	}
+	for _, f := range files {
+		part, err := writer.CreateFormFile("files", f.name)
+		if err != nil {
+			return fmt.Errorf("failed to create form file: %w", err)
+		}
+		if _, err := part.Write(f.content); err != nil {
+			return fmt.Errorf("failed to copy file: %w", err)
+		}
+	}
+	return nil
+}
+
+// ConvertHTML prints an HTML page to PDF/A through Chromium. The page goes
+// up as index.html and every asset, keyed by file name, beside it, so the
+// page's references to images and stylesheets resolve.
+func (g *GotenbergService) ConvertHTML(ctx context.Context, inputPath string, assets map[string]string, opts ConvertOptions) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := writeHTMLFiles(writer, inputPath, assets); err != nil {
+		return "", err
+	}
+
+	g.writeOutputFields(writer, opts)
+	if err := writePrintTemplates(writer, opts); err != nil {
+		return "", err
+	}
+
 	if err := writer.Close(); err != nil {
 		return "", fmt.Errorf("failed to close writer: %w", err)
 	}
 
-	// Create request
-	url := fmt.Sprintf("%s/forms/libreoffice/convert", g.baseURL)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	outputPath := inputPath + ".converted.pdf"
+	if err := g.post(ctx, "/forms/chromium/convert/html", body, writer.FormDataContentType(), outputPath); err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// writeHTMLFiles adds the page as index.html and its assets beside it.
+func writeHTMLFiles(writer *multipart.Writer, inputPath string, assets map[string]string) error {
+	files := map[string]string{"index.html": inputPath}
+	for name, assetPath := range assets {
+		files[name] = assetPath
+	}
+	for name, filePath := range files {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		part, err := writer.CreateFormFile("files", name)
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ConvertPDFToPDFA converts an existing PDF (e.g. one assembled from an
+// image) to PDF/A with Gotenberg's PDF engines.
+func (g *GotenbergService) ConvertPDFToPDFA(ctx context.Context, inputPath string, opts ConvertOptions) (string, error) {
+	file, err := os.Open(inputPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to open input file: %w", err)
 	}
+	defer file.Close()
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("files", filepath.Base(inputPath))
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return "", fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	g.writeOutputFields(writer, opts)
+
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	outputPath := inputPath + ".converted.pdf"
+	if err := g.post(ctx, "/forms/pdfengines/convert", body, writer.FormDataContentType(), outputPath); err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// ConvertEmail prints a parsed message to PDF/A through Chromium: the
+// rendered page goes up as index.html with any inline images beside it.
+func (g *GotenbergService) ConvertEmail(ctx context.Context, inputPath string, email *Email, opts ConvertOptions) (string, error) {
+	page, resources, err := renderEmail(email)
+	if err != nil {
+		return "", err
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	files := map[string][]byte{"index.html": page}
+	for name, data := range resources {
+		files[name] = data
+	}
+	for name, content := range files {
+		part, err := writer.CreateFormFile("files", name)
+		if err != nil {
+			return "", fmt.Errorf("failed to create form file: %w", err)
+		}
+		if _, err := part.Write(content); err != nil {
+			return "", fmt.Errorf("failed to copy file: %w", err)
+		}
+	}
+
+	g.writeOutputFields(writer, opts)
+
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	outputPath := inputPath + ".converted.pdf"
+	if err := g.post(ctx, "/forms/chromium/convert/html", body, writer.FormDataContentType(), outputPath); err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// Merge concatenates PDFs in the given order into one PDF/A. Gotenberg
+// merges in file name order, so the parts are uploaded under numbered
+// names.
+func (g *GotenbergService) Merge(ctx context.Context, inputPaths []string, outputPath string, opts ConvertOptions) error {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for i, inputPath := range inputPaths {
+		file, err := os.Open(inputPath)
+		if err != nil {
+			return fmt.Errorf("failed to open input file: %w", err)
+		}
+		part, err := writer.CreateFormFile("files", fmt.Sprintf("%03d.pdf", i))
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to copy file: %w", err)
+		}
+	}
+
+	g.writeOutputFields(writer, opts)
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	return g.post(ctx, "/forms/pdfengines/merge", body, writer.FormDataContentType(), outputPath)
+}
+
+// writeOutputFields adds the conformance level, the PDF/UA switch for
+// accessible output and flattening. Every Gotenberg route used here accepts
+// all three, except that 7 knows neither switch, so they are left out.
+func (g *GotenbergService) writeOutputFields(writer *multipart.Writer, opts ConvertOptions) {
+	conformance := opts.Conformance
+	if conformance == "" {
+		conformance = DefaultPDFAConformance
+	}
+	writer.WriteField(g.pdfaField(), conformance)
+
+	if g.apiVersion == GotenbergV7 {
+		return
+	}
+	if opts.Accessible {
+		writer.WriteField("pdfua", "true")
+	}
+	if opts.Flatten {
+		writer.WriteField("flatten", "true")
+	}
+}
+
+// writePassword adds the document password for the LibreOffice route.
+func writePassword(writer *multipart.Writer, opts ConvertOptions) {
+	if opts.Password != "" {
+		writer.WriteField("password", opts.Password)
+	}
+}
+
+// post sends a multipart form to a Gotenberg route and saves the PDF it
+// returns to outputPath.
+func (g *GotenbergService) post(ctx context.Context, route string, body io.Reader, contentType string, outputPath string) error {
+	resp, err := g.send(ctx, route, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Save response to temporary file
+	if err := g.saveResponse(resp, outputPath); err != nil {
+		os.Remove(outputPath)
+		return err
+	}
+	return nil
+}
+
+// send posts a multipart form to a Gotenberg route and returns the response
+// when it succeeded. The caller closes the body.
+func (g *GotenbergService) send(ctx context.Context, route string, body io.Reader, contentType string) (*http.Response, error) {
+	return g.do(ctx, route, body, contentType, nil, http.StatusOK)
+}
+
+// do posts a multipart form with extra headers and returns the response
+// when it has the expected status. The caller closes the body.
+func (g *GotenbergService) do(ctx context.Context, route string, body io.Reader, contentType string, headers map[string]string, expected int) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", g.baseURL+route, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	g.identity.Apply(req.Header)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	// Lets Gotenberg's own logs be correlated with the job
+	if traceID := logging.TraceID(ctx); traceID != "" {
+		req.Header.Set("Gotenberg-Trace", traceID)
+	}
 
 	// Send request
 	resp, err := g.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("gotenberg request failed: %w", err)
+		// The job giving up says nothing about Gotenberg
+		if ctx.Err() == nil {
+			g.recordOutcome(true)
+		}
+		return nil, fmt.Errorf("gotenberg request failed: %w", err)
+	}
+	g.recordOutcome(resp.StatusCode >= http.StatusInternalServerError)
+	if resp.StatusCode < http.StatusInternalServerError {
+		g.recordLibreOffice(route)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != expected {
+		defer resp.Body.Close()
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("gotenberg returned status %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, statusError(resp.StatusCode, string(bodyBytes))
 	}
+	return resp, nil
+}
 
-	// Save response to temporary file
-	outputPath := inputPath + ".converted.pdf"
+func (g *GotenbergService) recordOutcome(failed bool) {
+	switch {
+	case g.breaker == nil:
+	case failed:
+		g.breaker.Failed()
+	default:
+		g.breaker.Succeeded()
+	}
+}
+
+// statusError is the error for a failed conversion, wrapping
+// ErrPasswordRequired when LibreOffice wanted a password and
+// ErrConsoleExceptions when Chromium was told to fail on them.
+func statusError(status int, body string) error {
+	if status == http.StatusBadRequest && passwordProtected(body) {
+		return fmt.Errorf("%w: gotenberg returned status %d: %s", ErrPasswordRequired, status, body)
+	}
+	if status == http.StatusConflict && strings.Contains(strings.ToLower(body), "console exceptions") {
+		return fmt.Errorf("%w: %s", ErrConsoleExceptions, body)
+	}
+	return fmt.Errorf("gotenberg returned status %d: %s", status, body)
+}
+
+// saveResponse streams a PDF response body to disk, aborting as soon as the
+// size limit is crossed.
+func (g *GotenbergService) saveResponse(resp *http.Response, outputPath string) error {
+	reader, err := g.pdfBody(resp)
+	if err != nil {
+		return err
+	}
+	return g.writeLimited(reader, outputPath)
+}
+
+// pdfBody checks a response before its body is read, rejecting bodies that
+// aren't a PDF (e.g. a reverse proxy's HTML error page served with 200) and
+// ones that announce more than the size limit.
+func (g *GotenbergService) pdfBody(resp *http.Response) (*bufio.Reader, error) {
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, fmt.Errorf("gotenberg returned HTML instead of PDF")
+	}
+
+	if g.maxResponseBytes > 0 && resp.ContentLength > g.maxResponseBytes {
+		return nil, fmt.Errorf("%w: %d bytes", ErrResponseTooLarge, resp.ContentLength)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	magic, err := reader.Peek(5)
+	if err != nil || string(magic) != "%PDF-" {
+		return nil, fmt.Errorf("gotenberg response is not a PDF (starts with %q)", magic)
+	}
+	return reader, nil
+}
+
+// writeLimited streams r to outputPath, failing with ErrResponseTooLarge
+// once more than the configured maximum has been written.
+func (g *GotenbergService) writeLimited(r io.Reader, outputPath string) error {
 	outFile, err := os.Create(outputPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to create output file: %w", err)
+		return fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer outFile.Close()
 
-	if _, err := io.Copy(outFile, resp.Body); err != nil {
-		return "", fmt.Errorf("failed to save converted file: %w", err)
+	body := r
+	if g.maxResponseBytes > 0 {
+		body = io.LimitReader(r, g.maxResponseBytes+1)
 	}
 
-	return outputPath, nil
+	written, err := io.Copy(outFile, body)
+	if err != nil {
+		return fmt.Errorf("failed to save converted file: %w", err)
+	}
+	if g.maxResponseBytes > 0 && written > g.maxResponseBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, g.maxResponseBytes)
+	}
+
+	return nil
 }
@@ -3,20 +3,23 @@ package services
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 type roundTripFunc func(*http.Request) (*http.Response, error)
 
 func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
 
-func assertMultipartPDFAField(t *testing.T, r *http.Request, expectedPath string) {
+func assertMultipartPDFAField(t *testing.T, r *http.Request, expectedPath string, expectedPDFA string) {
 	t.Helper()
 
 	if r.URL.Path != expectedPath {
@@ -50,17 +53,17 @@ func assertMultipartPDFAField(t *testing.T, r *http.Request, expectedPath string
 		_ = part.Close()
 	}
 
-	if pdfaValue != pdfaConformance {
-		t.Fatalf("expected pdfa=%q, got %q", pdfaConformance, pdfaValue)
+	if pdfaValue != expectedPDFA {
+		t.Fatalf("expected pdfa=%q, got %q", expectedPDFA, pdfaValue)
 	}
 }
 
 func TestGotenbergService_ConvertToPDFA_UsesPDFA2b(t *testing.T) {
 	t.Parallel()
 
-	svc := NewGotenbergService("http://example.invalid")
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
 	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
-		assertMultipartPDFAField(t, r, "/forms/libreoffice/convert")
+		assertMultipartPDFAField(t, r, "/forms/libreoffice/convert", DefaultPDFAConformance)
 		return &http.Response{
 			StatusCode: http.StatusOK,
 			Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.4\n%EOF\n"))),
@@ -74,7 +77,7 @@ func TestGotenbergService_ConvertToPDFA_UsesPDFA2b(t *testing.T) {
 		t.Fatalf("failed to write temp input: %v", err)
 	}
 
-	outputPath, err := svc.ConvertToPDFA(context.Background(), inputPath, "docx")
+	outputPath, err := svc.ConvertToPDFA(context.Background(), inputPath, "docx", ConvertOptions{})
 	if err != nil {
 		t.Fatalf("ConvertToPDFA failed: %v", err)
 	}
@@ -87,3 +90,475 @@ func TestGotenbergService_ConvertToPDFA_UsesPDFA2b(t *testing.T) {
 		t.Fatal("expected non-empty output")
 	}
 }
+
+func TestGotenbergService_ConvertToPDFA_UsesRequestedConformance(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		assertMultipartPDFAField(t, r, "/forms/libreoffice/convert", "PDF/A-1b")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.4\n%EOF\n"))),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	inputPath := filepath.Join(t.TempDir(), "input.docx")
+	if err := os.WriteFile(inputPath, []byte("dummy"), 0644); err != nil {
+		t.Fatalf("failed to write temp input: %v", err)
+	}
+
+	if _, err := svc.ConvertToPDFA(context.Background(), inputPath, "docx", ConvertOptions{Conformance: "PDF/A-1b"}); err != nil {
+		t.Fatalf("ConvertToPDFA failed: %v", err)
+	}
+}
+
+func TestGotenbergService_ConvertToPDFA_Password(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("failed to parse form: %v", err)
+		}
+		if r.FormValue("password") == "s3cret" {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.4\n%EOF\n"))),
+				Header:     make(http.Header),
+			}, nil
+		}
+		body := "LibreOffice failed to process a document: a password may be required, or, if one has been given, it is invalid. In any case, the exact cause is uncertain."
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	inputPath := filepath.Join(t.TempDir(), "input.docx")
+	if err := os.WriteFile(inputPath, []byte("dummy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.ConvertToPDFA(context.Background(), inputPath, "docx", ConvertOptions{Password: "s3cret"}); err != nil {
+		t.Fatalf("ConvertToPDFA with the password failed: %v", err)
+	}
+	for _, password := range []string{"", "wrong"} {
+		if _, err := svc.ConvertToPDFA(context.Background(), inputPath, "docx", ConvertOptions{Password: password}); !errors.Is(err, ErrPasswordRequired) {
+			t.Errorf("password %q: expected ErrPasswordRequired, got %v", password, err)
+		}
+	}
+}
+
+func TestPasswordProtected(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		body string
+		want bool
+	}{
+		{"LibreOffice failed to process a document: a password may be required, or, if one has been given, it is invalid.", true},
+		{"Wrong password", true},
+		{"LibreOffice failed to process a document: possible causes include malformed page ranges", false},
+		{"Internal Server Error", false},
+	}
+	for _, c := range cases {
+		if got := passwordProtected(c.body); got != c.want {
+			t.Errorf("passwordProtected(%q) = %v, want %v", c.body, got, c.want)
+		}
+	}
+}
+
+func TestValidPDFAConformance(t *testing.T) {
+	for _, level := range []string{"PDF/A-1b", "PDF/A-2b", "PDF/A-3b"} {
+		if !ValidPDFAConformance(level) {
+			t.Errorf("expected %s to be valid", level)
+		}
+	}
+	for _, level := range []string{"", "PDF/A-2u", "pdf/a-2b"} {
+		if ValidPDFAConformance(level) {
+			t.Errorf("expected %q to be invalid", level)
+		}
+	}
+}
+
+func TestGotenbergService_ConvertToPDFA_RejectsHTMLBody(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("<html><body>502 Bad Gateway</body></html>"))),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	inputPath := filepath.Join(t.TempDir(), "input.docx")
+	if err := os.WriteFile(inputPath, []byte("dummy"), 0644); err != nil {
+		t.Fatalf("failed to write temp input: %v", err)
+	}
+
+	if _, err := svc.ConvertToPDFA(context.Background(), inputPath, "docx", ConvertOptions{}); err == nil {
+		t.Fatal("expected HTML body to be rejected")
+	}
+	if _, err := os.Stat(inputPath + ".converted.pdf"); !os.IsNotExist(err) {
+		t.Fatal("expected no output file to be left behind")
+	}
+}
+
+func TestGotenbergService_ConvertToPDFA_EnforcesMaxResponseSize(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 16, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Body:          io.NopCloser(bytes.NewReader(append([]byte("%PDF-1.4\n"), make([]byte, 64)...))),
+			Header:        make(http.Header),
+			ContentLength: -1,
+		}, nil
+	})
+
+	inputPath := filepath.Join(t.TempDir(), "input.docx")
+	if err := os.WriteFile(inputPath, []byte("dummy"), 0644); err != nil {
+		t.Fatalf("failed to write temp input: %v", err)
+	}
+
+	_, err := svc.ConvertToPDFA(context.Background(), inputPath, "docx", ConvertOptions{})
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+}
+
+func TestGotenbergService_ConvertStream(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		assertMultipartPDFAField(t, r, "/forms/libreoffice/convert", DefaultPDFAConformance)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.7\nconverted"))),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	body, err := svc.ConvertStream(context.Background(), bytes.NewReader([]byte("dummy")), "input.docx", ConvertOptions{})
+	if err != nil {
+		t.Fatalf("ConvertStream: %v", err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil || string(got) != "%PDF-1.7\nconverted" {
+		t.Fatalf("body = %q, %v", got, err)
+	}
+}
+
+func TestGotenbergService_ConvertStream_EnforcesMaxResponseSize(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 16, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		io.Copy(io.Discard, r.Body)
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Body:          io.NopCloser(bytes.NewReader(append([]byte("%PDF-1.4\n"), make([]byte, 64)...))),
+			Header:        make(http.Header),
+			ContentLength: -1,
+		}, nil
+	})
+
+	body, err := svc.ConvertStream(context.Background(), bytes.NewReader([]byte("dummy")), "input.docx", ConvertOptions{})
+	if err != nil {
+		t.Fatalf("ConvertStream: %v", err)
+	}
+	defer body.Close()
+	if _, err := io.ReadAll(body); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+}
+
+func TestGotenbergService_ConvertToPDFA_SendsIdentity(t *testing.T) {
+	t.Parallel()
+
+	identity := RequestIdentity{
+		UserAgent: "paperpulse-converter/1.2.3",
+		Headers:   map[string]string{"X-Instance-Id": "converter-0"},
+	}
+	svc := NewGotenbergService("http://example.invalid", 0, identity)
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if ua := r.Header.Get("User-Agent"); ua != identity.UserAgent {
+			t.Errorf("expected User-Agent %q, got %q", identity.UserAgent, ua)
+		}
+		if id := r.Header.Get("X-Instance-Id"); id != "converter-0" {
+			t.Errorf("expected X-Instance-Id header, got %q", id)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.4\n%EOF\n"))),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	inputPath := filepath.Join(t.TempDir(), "input.docx")
+	if err := os.WriteFile(inputPath, []byte("dummy"), 0644); err != nil {
+		t.Fatalf("failed to write temp input: %v", err)
+	}
+	if _, err := svc.ConvertToPDFA(context.Background(), inputPath, "docx", ConvertOptions{}); err != nil {
+		t.Fatalf("ConvertToPDFA failed: %v", err)
+	}
+}
+
+func TestGotenbergService_ConvertMarkdown(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.SetMarkdownTemplate([]byte(`<html><body>{{ toHTML "content.md" }}</body></html>`))
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/forms/chromium/convert/markdown" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("failed to parse form: %v", err)
+		}
+		files := map[string]string{}
+		for _, fh := range r.MultipartForm.File["files"] {
+			f, _ := fh.Open()
+			b, _ := io.ReadAll(f)
+			f.Close()
+			files[fh.Filename] = string(b)
+		}
+		if files["content.md"] != "# Title\n" {
+			t.Errorf("expected markdown uploaded as content.md, got %v", files)
+		}
+		if files["index.html"] != `<html><body>{{ toHTML "content.md" }}</body></html>` {
+			t.Errorf("expected configured template as index.html, got %q", files["index.html"])
+		}
+		if got := r.FormValue("pdfa"); got != "PDF/A-3b" {
+			t.Errorf("expected pdfa=PDF/A-3b, got %q", got)
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.4\n%EOF\n"))),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	inputPath := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(inputPath, []byte("# Title\n"), 0644); err != nil {
+		t.Fatalf("failed to write temp input: %v", err)
+	}
+
+	if _, err := svc.ConvertMarkdown(context.Background(), inputPath, ConvertOptions{Conformance: "PDF/A-3b"}); err != nil {
+		t.Fatalf("ConvertMarkdown failed: %v", err)
+	}
+}
+
+func TestGotenbergService_ConvertHTML(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/forms/chromium/convert/html" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("failed to parse form: %v", err)
+		}
+		files := map[string]string{}
+		for _, fh := range r.MultipartForm.File["files"] {
+			f, _ := fh.Open()
+			b, _ := io.ReadAll(f)
+			f.Close()
+			files[fh.Filename] = string(b)
+		}
+		if files["index.html"] != `<img src="logo.png">` {
+			t.Errorf("expected page uploaded as index.html, got %v", files)
+		}
+		if files["logo.png"] != "png" || files["site.css"] != "css" {
+			t.Errorf("expected assets uploaded by name, got %v", files)
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.4\n%EOF\n"))),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	inputPath := write("page.html", `<img src="logo.png">`)
+	assets := map[string]string{
+		"logo.png": write("a1", "png"),
+		"site.css": write("a2", "css"),
+	}
+
+	if _, err := svc.ConvertHTML(context.Background(), inputPath, assets, ConvertOptions{}); err != nil {
+		t.Fatalf("ConvertHTML failed: %v", err)
+	}
+}
+
+func TestGotenbergService_ConvertHTML_PrintTemplates(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("failed to parse form: %v", err)
+		}
+		files := map[string]string{}
+		for _, fh := range r.MultipartForm.File["files"] {
+			f, _ := fh.Open()
+			b, _ := io.ReadAll(f)
+			f.Close()
+			files[fh.Filename] = string(b)
+		}
+		if !strings.Contains(files["footer.html"], `Page <span class="pageNumber"></span> of <span class="totalPages"></span>`) {
+			t.Errorf("expected footer with page numbers, got %q", files["footer.html"])
+		}
+		if _, ok := files["header.html"]; ok {
+			t.Error("header.html sent without a header")
+		}
+		if r.FormValue("marginBottom") == "" || r.FormValue("marginTop") != "" {
+			t.Errorf("expected only a bottom margin, got top %q bottom %q", r.FormValue("marginTop"), r.FormValue("marginBottom"))
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.4\n%EOF\n"))),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	inputPath := filepath.Join(t.TempDir(), "page.html")
+	if err := os.WriteFile(inputPath, []byte("<p>report</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := ConvertOptions{Footer: "Page {{pageNumber}} of {{totalPages}}"}
+	if _, err := svc.ConvertHTML(context.Background(), inputPath, nil, opts); err != nil {
+		t.Fatalf("ConvertHTML failed: %v", err)
+	}
+}
+
+func TestPrintTemplate(t *testing.T) {
+	t.Parallel()
+
+	fragment := string(PrintTemplate("{{title}} – {{date}}"))
+	if !strings.HasPrefix(fragment, "<!DOCTYPE html>") || !strings.Contains(fragment, `<span class="title"></span> – <span class="date"></span>`) {
+		t.Errorf("fragment = %q, want it wrapped with its variables filled in", fragment)
+	}
+
+	document := `<html><body style="font-size: 12px">{{pageNumber}}</body></html>`
+	if got := string(PrintTemplate(document)); got != `<html><body style="font-size: 12px"><span class="pageNumber"></span></body></html>` {
+		t.Errorf("document = %q, want it sent as it is", got)
+	}
+}
+
+func TestLoadMarkdownTemplate(t *testing.T) {
+	t.Parallel()
+
+	tmpl, err := LoadMarkdownTemplate("")
+	if err != nil || !bytes.Contains(tmpl, []byte(`toHTML "content.md"`)) {
+		t.Fatalf("expected built-in template, got %q (err=%v)", tmpl, err)
+	}
+
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.html")
+	os.WriteFile(good, []byte(`<main>{{ toHTML "content.md" }}</main>`), 0644)
+	if _, err := LoadMarkdownTemplate(good); err != nil {
+		t.Fatalf("expected custom template to load, got %v", err)
+	}
+
+	bad := filepath.Join(dir, "bad.html")
+	os.WriteFile(bad, []byte(`<main>{{ toHTML "index.md" }}</main>`), 0644)
+	if _, err := LoadMarkdownTemplate(bad); err == nil {
+		t.Fatal("expected template without content.md to be rejected")
+	}
+}
+
+func TestGotenbergService_ConvertPDFToPDFA(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		assertMultipartPDFAField(t, r, "/forms/pdfengines/convert", "PDF/A-1b")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.4\n%EOF\n"))),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	inputPath := filepath.Join(t.TempDir(), "scan.jpg.image.pdf")
+	if err := os.WriteFile(inputPath, []byte("%PDF-1.4\n"), 0644); err != nil {
+		t.Fatalf("failed to write temp input: %v", err)
+	}
+
+	outputPath, err := svc.ConvertPDFToPDFA(context.Background(), inputPath, ConvertOptions{Conformance: "PDF/A-1b"})
+	if err != nil {
+		t.Fatalf("ConvertPDFToPDFA failed: %v", err)
+	}
+	if outputPath != inputPath+".converted.pdf" {
+		t.Fatalf("unexpected output path %s", outputPath)
+	}
+}
+
+func TestSatisfiesConformance(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		claimed, requested string
+		want               bool
+	}{
+		{"PDF/A-2b", "PDF/A-2b", true},
+		{"PDF/A-2u", "PDF/A-2b", true},
+		{"PDF/A-2a", "", true},
+		{"PDF/A-1b", "PDF/A-2b", false},
+		{"PDF/A-3b", "PDF/A-2b", false},
+		{"PDF/A-2b", "PDF/A-2u", false},
+		{"", "PDF/A-2b", false},
+	}
+	for _, c := range cases {
+		if got := SatisfiesConformance(c.claimed, c.requested); got != c.want {
+			t.Errorf("SatisfiesConformance(%q, %q) = %v, want %v", c.claimed, c.requested, got, c.want)
+		}
+	}
+}
+
+func TestGotenbergService_Warm(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		assertMultipartPDFAField(t, r, "/forms/libreoffice/convert", "PDF/A-1b")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.4\n%EOF\n"))),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	if !svc.LastLibreOffice().IsZero() {
+		t.Fatal("expected no LibreOffice use before the first request")
+	}
+	before := time.Now()
+	if err := svc.Warm(context.Background(), ConvertOptions{Conformance: "PDF/A-1b"}); err != nil {
+		t.Fatalf("Warm failed: %v", err)
+	}
+	if last := svc.LastLibreOffice(); last.Before(before) {
+		t.Fatalf("expected the warm-up to count as LibreOffice use, last use %v", last)
+	}
+}
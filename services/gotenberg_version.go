@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Gotenberg major versions whose routes and form fields this client speaks.
+// 7 names the PDF/A field pdfFormat and can't produce PDF/UA, flattened or
+// split output; 8 is the API the rest of this file is written against.
+const (
+	GotenbergV7 = 7
+	GotenbergV8 = 8
+)
+
+// ErrUnsupportedGotenberg is returned for a Gotenberg version this client
+// can't talk to.
+var ErrUnsupportedGotenberg = errors.New("unsupported gotenberg version")
+
+// ParseGotenbergMajor reads the major version from a version string such as
+// "8.5.1" or "v7.10.2".
+func ParseGotenbergMajor(version string) (int, error) {
+	major, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("unrecognized gotenberg version %q", version)
+	}
+	return n, nil
+}
+
+// DetectVersion asks Gotenberg for its version and switches the form fields
+// to match. Version 8 answers on /version; 7 has no such route but does
+// answer on /health, which older versions lack.
+func (g *GotenbergService) DetectVersion(ctx context.Context) (string, error) {
+	status, body, err := g.get(ctx, "/version")
+	if err != nil {
+		return "", err
+	}
+
+	var version string
+	switch status {
+	case http.StatusOK:
+		version = strings.TrimSpace(body)
+	case http.StatusNotFound:
+		if status, _, err := g.get(ctx, "/health"); err != nil {
+			return "", err
+		} else if status != http.StatusOK {
+			return "", fmt.Errorf("%w: neither /version nor /health answers, so it predates 7", ErrUnsupportedGotenberg)
+		}
+		version = "7"
+	default:
+		return "", fmt.Errorf("gotenberg returned status %d for /version: %s", status, body)
+	}
+
+	major, err := ParseGotenbergMajor(version)
+	if err != nil {
+		return "", err
+	}
+	if err := g.SetAPIVersion(major); err != nil {
+		return "", fmt.Errorf("%w (found %s)", err, version)
+	}
+	return version, nil
+}
+
+// SetAPIVersion makes requests use the routes and form fields of the given
+// Gotenberg major version.
+func (g *GotenbergService) SetAPIVersion(major int) error {
+	if major != GotenbergV7 && major != GotenbergV8 {
+		return fmt.Errorf("%w: %d, only %d and %d are supported", ErrUnsupportedGotenberg, major, GotenbergV7, GotenbergV8)
+	}
+	g.apiVersion = major
+	return nil
+}
+
+// APIVersion is the Gotenberg major version requests are made for.
+func (g *GotenbergService) APIVersion() int {
+	return g.apiVersion
+}
+
+// MissingFeature names the first output option the Gotenberg version can't
+// produce, or returns "" when it supports them all.
+func (g *GotenbergService) MissingFeature(accessible bool, flatten bool, split bool) string {
+	if g.apiVersion != GotenbergV7 {
+		return ""
+	}
+	switch {
+	case accessible:
+		return "PDF/UA output"
+	case flatten:
+		return "flattening"
+	case split:
+		return "splitting"
+	}
+	return ""
+}
+
+// pdfaField is the form field carrying the PDF/A level.
+func (g *GotenbergService) pdfaField() string {
+	if g.apiVersion == GotenbergV7 {
+		return "pdfFormat"
+	}
+	return "pdfa"
+}
+
+// get requests a Gotenberg route and returns its status and the start of
+// its body.
+func (g *GotenbergService) get(ctx context.Context, route string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", g.baseURL+route, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	g.identity.Apply(req.Header)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("gotenberg request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return resp.StatusCode, string(body), nil
+}
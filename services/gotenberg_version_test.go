@@ -0,0 +1,130 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// gotenbergRoutes answers GET requests with the body for their route, and
+// 404 for routes it doesn't list.
+func gotenbergRoutes(routes map[string]string) roundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		body, ok := routes[r.URL.Path]
+		status := http.StatusOK
+		if !ok {
+			status = http.StatusNotFound
+		}
+		return &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+			Header:     make(http.Header),
+		}, nil
+	}
+}
+
+func TestGotenbergService_DetectVersion(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		routes  map[string]string
+		want    string
+		wantAPI int
+		wantErr error
+	}{
+		{"version route", map[string]string{"/version": "8.5.1\n", "/health": `{"status":"up"}`}, "8.5.1", GotenbergV8, nil},
+		{"health only", map[string]string{"/health": `{"status":"up"}`}, "7", GotenbergV7, nil},
+		{"neither", map[string]string{}, "", 0, ErrUnsupportedGotenberg},
+		{"future major", map[string]string{"/version": "9.0.0"}, "", 0, ErrUnsupportedGotenberg},
+	}
+	for _, c := range cases {
+		svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+		svc.client.Transport = gotenbergRoutes(c.routes)
+
+		got, err := svc.DetectVersion(context.Background())
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("%s: DetectVersion error = %v, want %v", c.name, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != c.want || svc.apiVersion != c.wantAPI {
+			t.Errorf("%s: DetectVersion = %q, %v (API %d), want %q (API %d)", c.name, got, err, svc.apiVersion, c.want, c.wantAPI)
+		}
+	}
+}
+
+func TestParseGotenbergMajor(t *testing.T) {
+	t.Parallel()
+
+	for version, want := range map[string]int{"8.5.1": 8, "v7.10.2": 7, "8": 8} {
+		if got, err := ParseGotenbergMajor(version); err != nil || got != want {
+			t.Errorf("ParseGotenbergMajor(%q) = %d, %v, want %d", version, got, err, want)
+		}
+	}
+	if _, err := ParseGotenbergMajor("snapshot"); err == nil {
+		t.Error("ParseGotenbergMajor accepted a version without a number")
+	}
+}
+
+func TestGotenbergService_V7FormFields(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	if err := svc.SetAPIVersion(GotenbergV7); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := make(map[string]string)
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			b, _ := io.ReadAll(part)
+			if part.FileName() == "" {
+				fields[part.FormName()] = string(b)
+			}
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.4\n%EOF\n"))),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	inputPath := filepath.Join(t.TempDir(), "input.docx")
+	if err := os.WriteFile(inputPath, []byte("dummy"), 0644); err != nil {
+		t.Fatalf("failed to write temp input: %v", err)
+	}
+	if _, err := svc.ConvertToPDFA(context.Background(), inputPath, "docx", ConvertOptions{Accessible: true}); err != nil {
+		t.Fatalf("ConvertToPDFA failed: %v", err)
+	}
+
+	if fields["pdfFormat"] != DefaultPDFAConformance {
+		t.Errorf("pdfFormat = %q, want %q", fields["pdfFormat"], DefaultPDFAConformance)
+	}
+	for _, name := range []string{"pdfa", "pdfua"} {
+		if _, ok := fields[name]; ok {
+			t.Errorf("field %s sent to Gotenberg 7", name)
+		}
+	}
+
+	if got := svc.MissingFeature(false, false, true); got != "splitting" {
+		t.Errorf("MissingFeature(split) = %q, want splitting", got)
+	}
+	if got := NewGotenbergService("", 0, RequestIdentity{}).MissingFeature(true, true, true); got != "" {
+		t.Errorf("MissingFeature on Gotenberg 8 = %q, want none", got)
+	}
+}
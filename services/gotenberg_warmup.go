@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Warm converts a one-line text file to PDF/A with LibreOffice and discards
+// the result. The first conversion after Gotenberg starts, or after it sat
+// idle, pays for loading LibreOffice's profile and fonts; a warm-up pays
+// for it instead of a job.
+func (g *GotenbergService) Warm(ctx context.Context, opts ConvertOptions) error {
+	dir, err := os.MkdirTemp("", "converter-warmup")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	inputPath := filepath.Join(dir, "warmup.txt")
+	if err := os.WriteFile(inputPath, []byte("converter warm-up conversion\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write warm-up document: %w", err)
+	}
+	_, err = g.ConvertToPDFA(ctx, inputPath, "txt", opts)
+	return err
+}
+
+// LastLibreOffice returns when Gotenberg last answered a LibreOffice
+// request, or the zero time if it never has.
+func (g *GotenbergService) LastLibreOffice() time.Time {
+	if nanos := g.lastLibreOffice.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
+
+// recordLibreOffice notes that Gotenberg answered a request to route.
+func (g *GotenbergService) recordLibreOffice(route string) {
+	if strings.HasPrefix(route, "/forms/libreoffice/") {
+		g.lastLibreOffice.Store(time.Now().UnixNano())
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Webhook has Gotenberg deliver a conversion's result by callback instead
+// of in the response: it answers 204 right away, then POSTs the PDF to URL
+// or the failure to ErrorURL.
+type Webhook struct {
+	URL      string
+	ErrorURL string
+}
+
+func (w Webhook) headers() map[string]string {
+	return map[string]string{
+		"Gotenberg-Webhook-Url":          w.URL,
+		"Gotenberg-Webhook-Error-Url":    w.ErrorURL,
+		"Gotenberg-Webhook-Method":       http.MethodPost,
+		"Gotenberg-Webhook-Error-Method": http.MethodPost,
+	}
+}
+
+// SubmitToPDFA sends an office document to the LibreOffice route with the
+// webhook headers. It returns once Gotenberg accepted the conversion; the
+// result arrives at the webhook.
+func (g *GotenbergService) SubmitToPDFA(ctx context.Context, inputPath string, opts ConvertOptions, hook Webhook) error {
+	body, contentType, err := g.libreOfficeForm(inputPath, opts)
+	if err != nil {
+		return err
+	}
+	resp, err := g.do(ctx, "/forms/libreoffice/convert", body, contentType, hook.headers(), http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SaveWebhookResult saves the PDF of a webhook callback to outputPath, with
+// the checks and size limit of a synchronous response.
+func (g *GotenbergService) SaveWebhookResult(r *http.Request, outputPath string) error {
+	return g.saveResponse(&http.Response{Header: r.Header, ContentLength: r.ContentLength, Body: r.Body}, outputPath)
+}
+
+// WebhookError reads the failure Gotenberg POSTs to the error URL, a JSON
+// object with the status and message it would have answered with.
+func WebhookError(r *http.Request) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		return fmt.Errorf("failed to read gotenberg webhook error: %w", err)
+	}
+	var failure struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &failure); err != nil || failure.Status == 0 {
+		return fmt.Errorf("gotenberg webhook error: %s", strings.TrimSpace(string(body)))
+	}
+	return statusError(failure.Status, failure.Message)
+}
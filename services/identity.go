@@ -0,0 +1,52 @@
+package services
+
+import (
+	"net/http"
+
+	"converter/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// RequestIdentity is stamped on every outbound request so the storage and
+// Gotenberg teams can attribute traffic to this service and instance.
+type RequestIdentity struct {
+	UserAgent string
+	Headers   map[string]string
+}
+
+func NewRequestIdentity(cfg *config.Config) RequestIdentity {
+	headers := map[string]string{}
+	for k, v := range cfg.OutboundHeaders {
+		headers[k] = v
+	}
+	if cfg.InstanceID != "" {
+		headers["X-Instance-Id"] = cfg.InstanceID
+	}
+	return RequestIdentity{
+		UserAgent: cfg.UserAgent,
+		Headers:   headers,
+	}
+}
+
+func (i RequestIdentity) Apply(header http.Header) {
+	if i.UserAgent != "" {
+		header.Set("User-Agent", i.UserAgent)
+	}
+	for k, v := range i.Headers {
+		header.Set(k, v)
+	}
+}
+
+// applyToAWS adds the identity to every AWS SDK request, keeping the SDK's
+// own user agent for compatibility with AWS support tooling.
+func (i RequestIdentity) applyToAWS(awsCfg *aws.Config) {
+	if i.UserAgent != "" {
+		awsCfg.APIOptions = append(awsCfg.APIOptions, awsmiddleware.AddUserAgentKey(i.UserAgent))
+	}
+	for k, v := range i.Headers {
+		awsCfg.APIOptions = append(awsCfg.APIOptions, smithyhttp.SetHeaderValue(k, v))
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a4LongEdgeInches bounds scanned pages: anything larger than an A4 page at
+// the target DPI is downscaled.
+const a4LongEdgeInches = 11.69
+
+var imageExtensions = map[string]bool{
+	"jpg": true, "jpeg": true, "png": true,
+	"tif": true, "tiff": true, "bmp": true, "gif": true,
+	"heic": true, "heif": true, "webp": true,
+}
+
+// IsImageExtension reports whether ext is a raster image format.
+func IsImageExtension(ext string) bool {
+	return imageExtensions[strings.ToLower(strings.TrimPrefix(ext, "."))]
+}
+
+// ImagingService prepares scanned images with ImageMagick before PDF assembly.
+type ImagingService struct {
+	maxDPI int
+}
+
+func NewImagingService(maxDPI int) *ImagingService {
+	return &ImagingService{maxDPI: maxDPI}
+}
+
+// ToPDF assembles the image into a PDF with ImageMagick, one page per frame
+// so multi-page TIFFs keep all their pages. With normalize set it also
+// rotates by EXIF orientation, deskews and downscales to at most maxDPI on
+// an A4 page in the same pass; phone-camera scans are often 12+ megapixels,
+// which bloats the PDF and hurts OCR.
+func (s *ImagingService) ToPDF(ctx context.Context, inputPath string, normalize bool) (string, error) {
+	outputPath := inputPath + ".image.pdf"
+
+	args := []string{inputPath, "-auto-orient"}
+	if normalize {
+		longEdge := int(a4LongEdgeInches * float64(s.maxDPI))
+		dpi := strconv.Itoa(s.maxDPI)
+		args = append(args,
+			"-deskew", "40%",
+			"-resize", fmt.Sprintf("%dx%d>", longEdge, longEdge),
+			"-units", "PixelsPerInch",
+			"-density", dpi,
+		)
+	}
+	// PDF/A forbids transparency
+	args = append(args, "-background", "white", "-alpha", "remove", "-alpha", "off", outputPath)
+
+	if err := run(ctx, "magick", args...); err != nil {
+		return "", fmt.Errorf("failed to convert image to PDF: %w", err)
+	}
+	return outputPath, nil
+}
+
+// ToWebP re-encodes a PNG (a rendered thumbnail) as WebP, which is usually
+// a fraction of the size for page previews.
+func (s *ImagingService) ToWebP(ctx context.Context, pngPath string) (string, error) {
+	outputPath := strings.TrimSuffix(pngPath, ".png") + ".webp"
+	if err := run(ctx, "magick", pngPath, "-quality", "80", outputPath); err != nil {
+		return "", fmt.Errorf("failed to encode WebP: %w", err)
+	}
+	return outputPath, nil
+}
+
+// Preview renders the PDF as a low-resolution copy for sharing: every page
+// is rasterized at dpi and stamped with watermark across its middle, so
+// neither the text layer nor a full-quality page ever leaves the archive.
+func (s *ImagingService) Preview(ctx context.Context, pdfPath string, dpi int, watermark string) (string, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(pdfPath), "preview-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create preview directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := run(ctx, "pdftoppm", "-jpeg", "-jpegopt", "quality=70", "-r", strconv.Itoa(dpi), pdfPath, filepath.Join(dir, "page")); err != nil {
+		return "", fmt.Errorf("failed to rasterize preview pages: %w", err)
+	}
+	// pdftoppm pads the page numbers to the same width, so names sort
+	// in page order
+	pages, err := filepath.Glob(filepath.Join(dir, "page-*.jpg"))
+	if err != nil || len(pages) == 0 {
+		return "", fmt.Errorf("failed to rasterize preview pages: no pages written")
+	}
+	sort.Strings(pages)
+
+	outputPath := pdfPath + ".preview.pdf"
+	args := append(pages,
+		"-gravity", "center",
+		"-fill", "rgba(128,128,128,0.35)",
+		"-pointsize", strconv.Itoa(dpi*5/6),
+		"-annotate", "330x330+0+0", annotationText(watermark),
+		"-units", "PixelsPerInch",
+		"-density", strconv.Itoa(dpi),
+		"-compress", "JPEG",
+		"-quality", "70",
+		outputPath,
+	)
+	if err := run(ctx, "magick", args...); err != nil {
+		return "", fmt.Errorf("failed to watermark preview: %w", err)
+	}
+	return outputPath, nil
+}
+
+// annotationText escapes text for -annotate, which would otherwise expand
+// %-escapes and read the text from a file when it starts with @.
+func annotationText(text string) string {
+	text = strings.ReplaceAll(text, "%", "%%")
+	if strings.HasPrefix(text, "@") {
+		text = `\` + text
+	}
+	return text
+}
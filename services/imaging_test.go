@@ -0,0 +1,19 @@
+package services
+
+import "testing"
+
+func TestAnnotationText(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"PREVIEW":           "PREVIEW",
+		"100% confidential": "100%% confidential",
+		"@/etc/passwd":      `\@/etc/passwd`,
+		"shared by @acme":   "shared by @acme",
+	}
+	for text, want := range cases {
+		if got := annotationText(text); got != want {
+			t.Errorf("annotationText(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
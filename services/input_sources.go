@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"converter/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// Schemes a merge part can be named with instead of a key in the
+// configured storage, enabled with CONVERSION_INPUT_SOURCES.
+const (
+	SourceS3    = "s3"
+	SourceGCS   = "gs"
+	SourceAzure = "az"
+	SourceHTTPS = "https"
+)
+
+// ErrInputTooLarge is returned when an input fetched by URL is larger than
+// CONVERSION_MAX_INPUT_BYTES.
+var ErrInputTooLarge = errors.New("input exceeds maximum size")
+
+// azureBlobHost is where az://<account>/... objects are read from.
+const azureBlobHost = "blob.core.windows.net"
+
+// InputSource is an input named by URI: s3://<bucket>/<key>,
+// gs://<bucket>/<key>, az://<account>/<container>/<blob> or an https URL.
+type InputSource struct {
+	Scheme string
+	// Bucket is the bucket, the Azure storage account or the URL's host.
+	Bucket string
+	// Key is the object key, the container and blob, or the URL's path.
+	Key string
+	url *url.URL
+}
+
+// ParseInputSource reads an input URI. It returns false for a plain key in
+// the configured storage.
+func ParseInputSource(input string) (InputSource, bool, error) {
+	scheme, rest, ok := strings.Cut(input, "://")
+	if !ok {
+		return InputSource{}, false, nil
+	}
+	u, err := url.Parse(input)
+	if err != nil {
+		return InputSource{}, true, fmt.Errorf("invalid input URI: %w", err)
+	}
+
+	source := InputSource{Scheme: strings.ToLower(scheme), Bucket: u.Host, url: u}
+	switch source.Scheme {
+	case SourceHTTPS:
+		source.Key = u.Path
+	case SourceS3, SourceGCS, SourceAzure:
+		// Keys are taken as written, not URL-decoded
+		_, source.Key, _ = strings.Cut(rest, "/")
+	default:
+		return InputSource{}, true, fmt.Errorf("unsupported input scheme %q", scheme)
+	}
+	if source.Bucket == "" || strings.Trim(source.Key, "/") == "" {
+		return InputSource{}, true, fmt.Errorf("input URI %s names no object", source)
+	}
+	if source.Scheme == SourceAzure && !strings.Contains(source.Key, "/") {
+		return InputSource{}, true, fmt.Errorf("input URI %s names no container and blob", source)
+	}
+	return source, true, nil
+}
+
+// String is the URI without its query, which may carry a signature.
+func (s InputSource) String() string {
+	if s.Scheme == SourceHTTPS {
+		return "https://" + s.Bucket + s.Key
+	}
+	return s.Scheme + "://" + s.Bucket + "/" + s.Key
+}
+
+// RedactInput is the input as it may be logged or recorded: input URIs
+// lose their query, plain keys are kept.
+func RedactInput(input string) string {
+	if source, isURI, err := ParseInputSource(input); isURI && err == nil {
+		return source.String()
+	}
+	return input
+}
+
+// ValidateInputSource checks that the source's scheme is enabled and, for
+// https, that its host is allowed.
+func ValidateInputSource(source InputSource, enabled []string, allowedHosts []string) error {
+	if !containsFold(enabled, source.Scheme) {
+		return fmt.Errorf("input scheme %s:// is not enabled", source.Scheme)
+	}
+	if source.Scheme == SourceHTTPS && !containsFold(allowedHosts, source.url.Hostname()) {
+		return fmt.Errorf("input host %s is not allowed", source.url.Hostname())
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// InputSources downloads inputs named by URI, and plain keys from the
+// configured storage. Clients for other buckets are created on first use,
+// with the deployment's AWS or Google credentials.
+type InputSources struct {
+	storage  Storage
+	awsCfg   aws.Config
+	cfg      *config.Config
+	client   *http.Client
+	azureSAS map[string]string
+	mu       sync.Mutex
+	buckets  map[string]Storage
+	// s3Limit is the process's S3 rate limiter, shared by the buckets
+	// opened for s3:// inputs.
+	s3Limit *TokenBucket
+}
+
+func NewInputSources(awsCfg aws.Config, cfg *config.Config, storage Storage, s3Limiter *TokenBucket) *InputSources {
+	s := &InputSources{
+		storage:  storage,
+		awsCfg:   awsCfg,
+		cfg:      cfg,
+		s3Limit:  s3Limiter,
+		azureSAS: cfg.AzureSASTokens,
+		buckets:  make(map[string]Storage),
+	}
+	s.client = &http.Client{
+		// A redirect must stay on an allowed host
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			if req.URL.Scheme != SourceHTTPS || !containsFold(cfg.InputURLAllowedHosts, req.URL.Hostname()) {
+				return fmt.Errorf("redirect to %s is not allowed", req.URL.Host)
+			}
+			return nil
+		},
+	}
+	return s
+}
+
+// Download stores the input at localPath.
+func (s *InputSources) Download(ctx context.Context, input string, localPath string) error {
+	source, isURI, err := ParseInputSource(input)
+	if err != nil {
+		return err
+	}
+	if !isURI {
+		return s.storage.Download(ctx, input, localPath)
+	}
+	if err := ValidateInputSource(source, s.cfg.InputSources, s.cfg.InputURLAllowedHosts); err != nil {
+		return err
+	}
+
+	switch source.Scheme {
+	case SourceS3, SourceGCS:
+		bucket, err := s.bucket(ctx, source)
+		if err != nil {
+			return err
+		}
+		return bucket.Download(ctx, source.Key, localPath)
+	case SourceAzure:
+		blobURL := "https://" + source.Bucket + "." + azureBlobHost + "/" + source.Key
+		if sas := s.azureSAS[source.Bucket]; sas != "" {
+			blobURL += "?" + strings.TrimPrefix(sas, "?")
+		}
+		return s.fetch(ctx, blobURL, localPath)
+	default:
+		return s.fetch(ctx, source.url.String(), localPath)
+	}
+}
+
+// bucket returns the storage for an s3:// or gs:// source's bucket: the
+// configured storage when it is that bucket, else a client for it.
+func (s *InputSources) bucket(ctx context.Context, source InputSource) (Storage, error) {
+	if (source.Scheme == SourceS3 && s.cfg.StorageDriver == StorageS3 && source.Bucket == s.cfg.S3Bucket) ||
+		(source.Scheme == SourceGCS && s.cfg.StorageDriver == StorageGCS && source.Bucket == s.cfg.GCSBucket) {
+		return s.storage, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := source.Scheme + "://" + source.Bucket
+	if bucket, ok := s.buckets[name]; ok {
+		return bucket, nil
+	}
+	bucket, err := s.openBucket(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	s.buckets[name] = bucket
+	return bucket, nil
+}
+
+func (s *InputSources) openBucket(ctx context.Context, source InputSource) (Storage, error) {
+	if source.Scheme == SourceGCS {
+		// The client outlives the job that first needs it
+		return newGCSService(context.WithoutCancel(ctx), source.Bucket, NewRequestIdentity(s.cfg), gcsOptions(s.cfg)...)
+	}
+	bucket := NewS3Service(s.awsCfg, s.cfg, s.s3Limit)
+	bucket.bucket = source.Bucket
+	return bucket, nil
+}
+
+// fetch downloads an https URL to localPath, failing with ErrInputTooLarge
+// once it is past CONVERSION_MAX_INPUT_BYTES, or up front when the server
+// says it will be.
+func (s *InputSources) fetch(ctx context.Context, rawURL string, localPath string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	NewRequestIdentity(s.cfg).Apply(req.Header)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The URL may carry a SAS token or signature, so it is left out
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to fetch input: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("failed to fetch input: %w", ErrObjectNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("failed to fetch input: status %d", resp.StatusCode)
+	}
+	maxBytes := s.cfg.MaxInputBytes
+	if maxBytes > 0 && resp.ContentLength > maxBytes {
+		return fmt.Errorf("%w: input is %d bytes, the limit is %d", ErrInputTooLarge, resp.ContentLength, maxBytes)
+	}
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %w", err)
+	}
+	defer file.Close()
+	body := io.Reader(resp.Body)
+	if maxBytes > 0 {
+		body = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	written, err := io.Copy(file, body)
+	if err != nil {
+		return fmt.Errorf("failed to fetch input: %w", err)
+	}
+	if maxBytes > 0 && written > maxBytes {
+		return fmt.Errorf("%w: input is over the limit of %d bytes", ErrInputTooLarge, maxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"converter/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestParseInputSource(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		input  string
+		isURI  bool
+		scheme string
+		bucket string
+		key    string
+		ok     bool
+	}{
+		{"in/a.docx", false, "", "", "", true},
+		{"s3://archive/2024/a b.pdf", true, SourceS3, "archive", "2024/a b.pdf", true},
+		{"gs://scans/a.pdf", true, SourceGCS, "scans", "a.pdf", true},
+		{"az://acct/contracts/2024/a.docx", true, SourceAzure, "acct", "contracts/2024/a.docx", true},
+		{"https://files.example.com/a.pdf?sig=abc", true, SourceHTTPS, "files.example.com", "/a.pdf", true},
+		{"az://acct/a.docx", true, "", "", "", false},
+		{"s3://archive/", true, "", "", "", false},
+		{"ftp://host/a.pdf", true, "", "", "", false},
+	}
+	for _, c := range cases {
+		source, isURI, err := ParseInputSource(c.input)
+		if isURI != c.isURI || (err == nil) != c.ok {
+			t.Errorf("%s: isURI=%v err=%v, want isURI=%v ok=%v", c.input, isURI, err, c.isURI, c.ok)
+			continue
+		}
+		if err == nil && isURI && (source.Scheme != c.scheme || source.Bucket != c.bucket || source.Key != c.key) {
+			t.Errorf("%s: got %+v", c.input, source)
+		}
+	}
+
+	if got := RedactInput("https://files.example.com/a.pdf?sig=abc"); got != "https://files.example.com/a.pdf" {
+		t.Errorf("RedactInput kept the query: %s", got)
+	}
+}
+
+func TestValidateInputSource(t *testing.T) {
+	t.Parallel()
+
+	parse := func(input string) InputSource {
+		source, _, err := ParseInputSource(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return source
+	}
+	enabled := []string{"s3", "https"}
+	allowed := []string{"files.example.com"}
+
+	if err := ValidateInputSource(parse("s3://archive/a.pdf"), enabled, allowed); err != nil {
+		t.Errorf("enabled scheme refused: %v", err)
+	}
+	if err := ValidateInputSource(parse("gs://scans/a.pdf"), enabled, allowed); err == nil {
+		t.Error("disabled scheme accepted")
+	}
+	if err := ValidateInputSource(parse("https://FILES.example.com/a.pdf"), enabled, allowed); err != nil {
+		t.Errorf("allowed host refused: %v", err)
+	}
+	if err := ValidateInputSource(parse("https://169.254.169.254/latest/meta-data"), enabled, allowed); err == nil {
+		t.Error("host outside the allow list accepted")
+	}
+}
+
+func TestInputSources_Download(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a.pdf":
+			w.Write([]byte("%PDF-1.4 remote"))
+		case "/big.pdf":
+			w.Write([]byte("%PDF-1.4 far too large for the limit"))
+		case "/chunked.pdf":
+			// Flushing first leaves out the Content-Length
+			w.(http.Flusher).Flush()
+			w.Write([]byte("%PDF-1.4 far too large for the limit"))
+		case "/elsewhere":
+			http.Redirect(w, r, "https://other.example.com/a.pdf", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	host, _ := url.Parse(server.URL)
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "local.pdf"), []byte("%PDF-1.4 local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	local, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := NewInputSources(aws.Config{}, &config.Config{
+		InputSources:         []string{SourceHTTPS},
+		InputURLAllowedHosts: []string{host.Hostname()},
+		MaxInputBytes:        20,
+	}, local, nil)
+	sources.client.Transport = server.Client().Transport
+
+	dir := t.TempDir()
+	download := func(input string) (string, error) {
+		localPath := filepath.Join(dir, "part.pdf")
+		if err := sources.Download(context.Background(), input, localPath); err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(localPath)
+		return string(content), err
+	}
+
+	if content, err := download("local.pdf"); err != nil || content != "%PDF-1.4 local" {
+		t.Errorf("plain key: %q, %v", content, err)
+	}
+	if content, err := download(server.URL + "/a.pdf"); err != nil || content != "%PDF-1.4 remote" {
+		t.Errorf("https: %q, %v", content, err)
+	}
+	if _, err := download(server.URL + "/missing.pdf"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("missing URL: expected ErrObjectNotFound, got %v", err)
+	}
+	if _, err := download(server.URL + "/big.pdf"); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("large URL: expected ErrInputTooLarge, got %v", err)
+	}
+	if _, err := download(server.URL + "/chunked.pdf"); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("large URL without a length: expected ErrInputTooLarge, got %v", err)
+	}
+	if _, err := download(server.URL + "/elsewhere"); err == nil {
+		t.Error("redirect off the allow list followed")
+	}
+	if _, err := download("gs://scans/a.pdf"); err == nil {
+		t.Error("disabled scheme downloaded")
+	}
+}
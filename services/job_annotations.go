@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Operator annotations on a single conversion, honored when a worker claims
+// the job.
+const (
+	// AnnotationHold keeps the job waiting: it is put back unclaimed until
+	// the annotation is cleared.
+	AnnotationHold = "hold"
+	// AnnotationSkip cancels the job without converting it.
+	AnnotationSkip = "skip"
+	// AnnotationForceEngine converts an office document with the named
+	// engine whatever the cost policy says.
+	AnnotationForceEngine = "force-engine"
+)
+
+var ErrInvalidAnnotation = errors.New("invalid annotation")
+
+// ValidateAnnotation checks an annotation before it is stored. hold and skip
+// take no value; force-engine names an engine.
+func ValidateAnnotation(name string, value string) error {
+	switch name {
+	case AnnotationHold, AnnotationSkip:
+		if value != "" {
+			return fmt.Errorf("%w: %s takes no value", ErrInvalidAnnotation, name)
+		}
+		return nil
+	case AnnotationForceEngine:
+		if value != EngineGotenberg && value != EngineSoffice {
+			return fmt.Errorf("%w: %s must be %s or %s", ErrInvalidAnnotation, name, EngineGotenberg, EngineSoffice)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown annotation %q", ErrInvalidAnnotation, name)
+	}
+}
+
+// JobAnnotations stores operator annotations in the
+// conversion:annotations:<id> hash, one field per annotation. Hashes expire
+// after ttl so annotations on jobs that are long gone don't pile up.
+type JobAnnotations struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewJobAnnotations(client *redis.Client, prefix string, ttl time.Duration) *JobAnnotations {
+	return &JobAnnotations{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (a *JobAnnotations) key(conversionID int) string {
+	return fmt.Sprintf("%sconversion:annotations:%d", a.prefix, conversionID)
+}
+
+// Get returns the conversion's annotations, empty if there are none.
+func (a *JobAnnotations) Get(ctx context.Context, conversionID int) (map[string]string, error) {
+	annotations, err := a.client.HGetAll(ctx, a.key(conversionID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read annotations: %w", err)
+	}
+	return annotations, nil
+}
+
+// Set adds or replaces one annotation and restarts the hash's TTL.
+func (a *JobAnnotations) Set(ctx context.Context, conversionID int, name string, value string) error {
+	if err := ValidateAnnotation(name, value); err != nil {
+		return err
+	}
+	key := a.key(conversionID)
+	pipe := a.client.TxPipeline()
+	pipe.HSet(ctx, key, name, value)
+	if a.ttl > 0 {
+		pipe.Expire(ctx, key, a.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store annotation: %w", err)
+	}
+	return nil
+}
+
+// Clear removes the named annotations, or all of them when none are named.
+func (a *JobAnnotations) Clear(ctx context.Context, conversionID int, names ...string) error {
+	var err error
+	if len(names) == 0 {
+		err = a.client.Del(ctx, a.key(conversionID)).Err()
+	} else {
+		err = a.client.HDel(ctx, a.key(conversionID), names...).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to clear annotations: %w", err)
+	}
+	return nil
+}
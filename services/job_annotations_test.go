@@ -0,0 +1,34 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateAnnotation(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{AnnotationHold, "", true},
+		{AnnotationSkip, "", true},
+		{AnnotationHold, "yes", false},
+		{AnnotationForceEngine, EngineSoffice, true},
+		{AnnotationForceEngine, EngineGotenberg, true},
+		{AnnotationForceEngine, "", false},
+		{AnnotationForceEngine, "chromium", false},
+		{"priority", "high", false},
+	}
+	for _, c := range cases {
+		err := ValidateAnnotation(c.name, c.value)
+		if (err == nil) != c.ok {
+			t.Errorf("ValidateAnnotation(%q, %q) = %v, want ok=%v", c.name, c.value, err, c.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidAnnotation) {
+			t.Errorf("ValidateAnnotation(%q, %q) = %v, want ErrInvalidAnnotation", c.name, c.value, err)
+		}
+	}
+}
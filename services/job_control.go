@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Fields of the conversion:control:<id> hash, which producers write to
+// change a job after enqueueing it.
+const (
+	// ControlCancel cancels the job when set to anything but "", "0" or
+	// "false".
+	ControlCancel = "cancel"
+	// ControlDeadline replaces the job's deadline, as a Unix timestamp or an
+	// RFC 3339 time.
+	ControlDeadline = "deadline"
+)
+
+// JobControl is what a producer asked for after enqueueing a job.
+type JobControl struct {
+	Cancelled bool
+	// Deadline is zero when the producer didn't set one.
+	Deadline time.Time
+}
+
+// ParseJobControl reads the fields of a control hash. Unknown fields are
+// ignored so producers can keep their own bookkeeping there.
+func ParseJobControl(fields map[string]string) (JobControl, error) {
+	var control JobControl
+	switch fields[ControlCancel] {
+	case "", "0", "false":
+	default:
+		control.Cancelled = true
+	}
+
+	if value := fields[ControlDeadline]; value != "" {
+		deadline, err := parseDeadline(value)
+		if err != nil {
+			return control, err
+		}
+		control.Deadline = deadline
+	}
+	return control, nil
+}
+
+func parseDeadline(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	deadline, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deadline %q: want a Unix timestamp or an RFC 3339 time", value)
+	}
+	return deadline, nil
+}
+
+// JobControls reads the conversion:control:<id> hashes. Producers own the
+// hashes and should let them expire; workers only read them.
+type JobControls struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewJobControls(client *redis.Client, prefix string) *JobControls {
+	return &JobControls{client: client, prefix: prefix}
+}
+
+func (c *JobControls) key(conversionID int) string {
+	return fmt.Sprintf("%sconversion:control:%d", c.prefix, conversionID)
+}
+
+// Get returns the producer's control for the conversion, zero if there is
+// none.
+func (c *JobControls) Get(ctx context.Context, conversionID int) (JobControl, error) {
+	fields, err := c.client.HGetAll(ctx, c.key(conversionID)).Result()
+	if err != nil {
+		return JobControl{}, fmt.Errorf("failed to read job control: %w", err)
+	}
+	return ParseJobControl(fields)
+}
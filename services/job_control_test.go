@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseJobControl(t *testing.T) {
+	t.Parallel()
+
+	deadline := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		fields    map[string]string
+		cancelled bool
+		deadline  time.Time
+		ok        bool
+	}{
+		{map[string]string{}, false, time.Time{}, true},
+		{map[string]string{ControlCancel: "1"}, true, time.Time{}, true},
+		{map[string]string{ControlCancel: "true"}, true, time.Time{}, true},
+		{map[string]string{ControlCancel: "0"}, false, time.Time{}, true},
+		{map[string]string{ControlCancel: "false", "owner": "preview"}, false, time.Time{}, true},
+		{map[string]string{ControlDeadline: "1772366400"}, false, deadline, true},
+		{map[string]string{ControlDeadline: "2026-03-01T13:00:00+01:00"}, false, deadline, true},
+		{map[string]string{ControlDeadline: "tomorrow"}, false, time.Time{}, false},
+	}
+	for _, c := range cases {
+		control, err := ParseJobControl(c.fields)
+		if (err == nil) != c.ok {
+			t.Errorf("ParseJobControl(%v) error = %v, want ok=%v", c.fields, err, c.ok)
+			continue
+		}
+		if control.Cancelled != c.cancelled || !control.Deadline.Equal(c.deadline) {
+			t.Errorf("ParseJobControl(%v) = %+v, want cancelled=%v deadline=%v", c.fields, control, c.cancelled, c.deadline)
+		}
+	}
+}
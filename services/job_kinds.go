@@ -0,0 +1,74 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"converter/models"
+)
+
+// Job kinds group conversions by the resources they need, so instances can
+// be limited to some of them with CONVERSION_JOB_KINDS.
+const (
+	// KindOffice is LibreOffice work: office documents and PDF inputs.
+	KindOffice = "office"
+	// KindHTML is Chromium work: HTML and Markdown inputs.
+	KindHTML = "html"
+	// KindEmail is emails, rendered with Chromium with their attachments
+	// converted by LibreOffice.
+	KindEmail = "email"
+	// KindImage is images, normalized in process before the PDF/A pass.
+	KindImage = "image"
+	// KindMerge is merge jobs, which convert and combine several inputs.
+	KindMerge = "merge"
+)
+
+// JobKinds lists every job kind.
+func JobKinds() []string {
+	return []string{KindOffice, KindHTML, KindEmail, KindImage, KindMerge}
+}
+
+// KindOf is the kind of a conversion job, by its type and declared
+// extension. Jobs that trash or restore outputs have no kind and may run
+// anywhere.
+func KindOf(job *models.ConversionJob) string {
+	switch {
+	case job.MovesOutputs():
+		return ""
+	case job.IsMerge():
+		return KindMerge
+	case IsEmailExtension(job.InputExtension):
+		return KindEmail
+	case IsImageExtension(job.InputExtension):
+		return KindImage
+	case IsHTMLExtension(job.InputExtension), IsMarkdownExtension(job.InputExtension):
+		return KindHTML
+	}
+	return KindOffice
+}
+
+// ParseJobKinds turns CONVERSION_JOB_KINDS into a set. An empty list takes
+// every kind and returns nil.
+func ParseJobKinds(names []string) (map[string]bool, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	kinds := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !isJobKind(name) {
+			return nil, fmt.Errorf("unknown job kind %q, want one of %s", name, strings.Join(JobKinds(), ", "))
+		}
+		kinds[name] = true
+	}
+	return kinds, nil
+}
+
+func isJobKind(name string) bool {
+	for _, kind := range JobKinds() {
+		if name == kind {
+			return true
+		}
+	}
+	return false
+}
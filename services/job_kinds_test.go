@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+
+	"converter/models"
+)
+
+func TestKindOf(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		job  models.ConversionJob
+		want string
+	}{
+		{models.ConversionJob{InputExtension: "docx"}, KindOffice},
+		{models.ConversionJob{InputExtension: "pdf"}, KindOffice},
+		{models.ConversionJob{InputExtension: "html"}, KindHTML},
+		{models.ConversionJob{InputExtension: "md"}, KindHTML},
+		{models.ConversionJob{InputExtension: "eml"}, KindEmail},
+		{models.ConversionJob{InputExtension: "png"}, KindImage},
+		{models.ConversionJob{Type: models.JobTypeMerge, InputExtension: "docx"}, KindMerge},
+		{models.ConversionJob{Type: models.JobTypeTrash}, ""},
+	}
+	for _, c := range cases {
+		if got := KindOf(&c.job); got != c.want {
+			t.Errorf("KindOf(%s %q) = %q, want %q", c.job.Type, c.job.InputExtension, got, c.want)
+		}
+	}
+}
+
+func TestParseJobKinds(t *testing.T) {
+	t.Parallel()
+
+	kinds, err := ParseJobKinds(nil)
+	if err != nil || kinds != nil {
+		t.Fatalf("ParseJobKinds(nil) = %v, %v, want every kind", kinds, err)
+	}
+
+	kinds, err = ParseJobKinds([]string{"office", " HTML "})
+	if err != nil {
+		t.Fatalf("ParseJobKinds: %v", err)
+	}
+	if len(kinds) != 2 || !kinds[KindOffice] || !kinds[KindHTML] {
+		t.Fatalf("ParseJobKinds = %v, want office and html", kinds)
+	}
+
+	if _, err := ParseJobKinds([]string{"office", "ocr"}); err == nil {
+		t.Fatal("ParseJobKinds accepted an unknown kind")
+	}
+}
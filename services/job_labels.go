@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"converter/models"
+)
+
+// Limits on the labels of a single job, which end up in every log line
+// about it.
+const (
+	maxJobLabels     = 16
+	maxJobLabelValue = 128
+)
+
+// labelNamePattern is what Prometheus allows as a label name, which job
+// label names must be so they can become metric labels.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels are the metric labels the job metrics set themselves.
+var reservedLabels = map[string]bool{"result": true}
+
+// ValidateJobLabels checks the labels a producer put on a job.
+func ValidateJobLabels(labels map[string]string) error {
+	if len(labels) > maxJobLabels {
+		return fmt.Errorf("job has %d labels, at most %d are allowed", len(labels), maxJobLabels)
+	}
+	for name, value := range labels {
+		if !labelNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if len(value) > maxJobLabelValue {
+			return fmt.Errorf("label %s is longer than %d bytes", name, maxJobLabelValue)
+		}
+	}
+	return nil
+}
+
+// ParseMetricLabels checks CONVERSION_METRIC_LABELS, the job labels the
+// job metrics are broken down by.
+func ParseMetricLabels(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !labelNamePattern.MatchString(name) || reservedLabels[name] {
+			return nil, fmt.Errorf("invalid metric label %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("metric label %q is listed twice", name)
+		}
+		seen[name] = true
+	}
+	return names, nil
+}
+
+// LabelPriority gives the jobs carrying a label value a priority.
+type LabelPriority struct {
+	Label    string
+	Value    string
+	Priority models.Priority
+}
+
+// LabelPriorities are the rules of CONVERSION_LABEL_PRIORITIES. A nil
+// LabelPriorities has no rules.
+type LabelPriorities []LabelPriority
+
+// ParseLabelPriorities reads CONVERSION_LABEL_PRIORITIES,
+// label:value=priority pairs such as plan:enterprise=high.
+func ParseLabelPriorities(rules map[string]string) (LabelPriorities, error) {
+	var priorities LabelPriorities
+	for match, priority := range rules {
+		label, value, ok := strings.Cut(match, ":")
+		if !ok || !labelNamePattern.MatchString(label) || value == "" {
+			return nil, fmt.Errorf("invalid label rule %q, want label:value=priority", match)
+		}
+		if priorityRank(models.Priority(priority)) < 0 {
+			return nil, fmt.Errorf("unknown priority %q for %s", priority, match)
+		}
+		priorities = append(priorities, LabelPriority{Label: label, Value: value, Priority: models.Priority(priority)})
+	}
+	sort.Slice(priorities, func(i, j int) bool {
+		if priorities[i].Label != priorities[j].Label {
+			return priorities[i].Label < priorities[j].Label
+		}
+		return priorities[i].Value < priorities[j].Value
+	})
+	return priorities, nil
+}
+
+// For returns the priority the rules give a job with labels. When several
+// rules match, the highest priority wins.
+func (p LabelPriorities) For(labels map[string]string) (models.Priority, bool) {
+	best, found := models.Priority(""), false
+	for _, rule := range p {
+		if labels[rule.Label] != rule.Value {
+			continue
+		}
+		if !found || priorityRank(rule.Priority) < priorityRank(best) {
+			best, found = rule.Priority, true
+		}
+	}
+	return best, found
+}
+
+// priorityRank is the priority's place in models.Priorities, most urgent
+// first, or -1 for an unknown priority.
+func priorityRank(priority models.Priority) int {
+	for i, p := range models.Priorities {
+		if p == priority {
+			return i
+		}
+	}
+	return -1
+}
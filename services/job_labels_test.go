@@ -0,0 +1,95 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"converter/models"
+)
+
+func TestLabelPriorities(t *testing.T) {
+	t.Parallel()
+
+	rules, err := ParseLabelPriorities(map[string]string{
+		"plan:enterprise":     "high",
+		"source:email-ingest": "low",
+		"source:bulk-import":  "low",
+	})
+	if err != nil {
+		t.Fatalf("ParseLabelPriorities() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   models.Priority
+		found  bool
+	}{
+		{"no labels", nil, "", false},
+		{"unmatched value", map[string]string{"plan": "free"}, "", false},
+		{"one rule", map[string]string{"source": "bulk-import"}, models.PriorityLow, true},
+		{"highest wins", map[string]string{"source": "email-ingest", "plan": "enterprise"}, models.PriorityHigh, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, found := rules.For(tt.labels)
+			if got != tt.want || found != tt.found {
+				t.Errorf("For() = %q, %v, want %q, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestParseLabelPriorities_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, rules := range []map[string]string{
+		{"plan": "high"},
+		{"plan:": "high"},
+		{"my-plan:enterprise": "high"},
+		{"plan:enterprise": "urgent"},
+	} {
+		if _, err := ParseLabelPriorities(rules); err == nil {
+			t.Errorf("ParseLabelPriorities(%v) accepted an invalid rule", rules)
+		}
+	}
+}
+
+func TestValidateJobLabels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"valid", map[string]string{"source": "email-ingest", "plan": "enterprise"}, false},
+		{"invalid name", map[string]string{"ingest-channel": "email"}, true},
+		{"long value", map[string]string{"source": strings.Repeat("x", maxJobLabelValue+1)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := ValidateJobLabels(tt.labels); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJobLabels() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseMetricLabels(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ParseMetricLabels([]string{"source", "plan"}); err != nil {
+		t.Errorf("ParseMetricLabels() error = %v", err)
+	}
+	for _, names := range [][]string{{"result"}, {"source", "source"}, {"ingest-channel"}} {
+		if _, err := ParseMetricLabels(names); err == nil {
+			t.Errorf("ParseMetricLabels(%v) accepted invalid names", names)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"converter/migrate"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Queue backends for the claimable queues (retry lane, high, pending, low).
+// The failed queue and the delayed set keep their structure either way.
+const (
+	QueueBackendList   = "list"
+	QueueBackendStream = "stream"
+)
+
+// StreamKey is the stream holding a claimable queue's jobs under the stream
+// backend, the same key `converter migrate-queue --to-backend=stream` writes.
+func StreamKey(queue string) string {
+	return queue + ":stream"
+}
+
+// StreamPayload returns the job payload of a stream entry, or "" when the
+// entry has no job field.
+func StreamPayload(msg redis.XMessage) string {
+	payload, _ := msg.Values[migrate.StreamField].(string)
+	return payload
+}
+
+// IsNoGroup reports whether err is Redis refusing a command because the
+// stream or its consumer group doesn't exist yet.
+func IsNoGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+// JobQueue reads and writes the claimable queues on the configured backend.
+// With streams, a consumer group tracks which entries have been delivered;
+// delivered entries are deleted once acked, so a stream holds exactly the
+// jobs that are waiting or in flight.
+type JobQueue struct {
+	client  *redis.Client
+	backend string
+	group   string
+}
+
+func NewJobQueue(client *redis.Client, backend string, group string) *JobQueue {
+	return &JobQueue{client: client, backend: backend, group: group}
+}
+
+// ValidateQueueBackend checks CONVERSION_QUEUE_BACKEND and the stream group.
+func ValidateQueueBackend(backend string, group string) error {
+	switch backend {
+	case QueueBackendList:
+		return nil
+	case QueueBackendStream:
+		if group == "" {
+			return fmt.Errorf("stream backend needs a consumer group")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown queue backend %q", backend)
+	}
+}
+
+func (q *JobQueue) Streams() bool {
+	return q.backend == QueueBackendStream
+}
+
+func (q *JobQueue) Group() string {
+	return q.group
+}
+
+// Push adds a job behind everything already waiting in queue.
+func (q *JobQueue) Push(ctx context.Context, queue string, payload interface{}) error {
+	if q.Streams() {
+		return q.client.XAdd(ctx, &redis.XAddArgs{
+			Stream: StreamKey(queue),
+			Values: map[string]interface{}{migrate.StreamField: payload},
+		}).Err()
+	}
+	return q.client.LPush(ctx, queue, payload).Err()
+}
+
+// Length counts the jobs waiting in queue, not those already claimed.
+func (q *JobQueue) Length(ctx context.Context, queue string) (int64, error) {
+	if !q.Streams() {
+		return q.client.LLen(ctx, queue).Result()
+	}
+
+	key := StreamKey(queue)
+	length, err := q.client.XLen(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	inFlight, err := q.inFlightCount(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return length - inFlight, nil
+}
+
+// Waiting returns up to limit waiting entries of queue starting at offset,
+// the next to be claimed first.
+func (q *JobQueue) Waiting(ctx context.Context, queue string, offset int64, limit int64) ([]string, error) {
+	if !q.Streams() {
+		raw, err := q.client.LRange(ctx, queue, -(offset + limit), -(offset + 1)).Result()
+		reverse(raw)
+		return raw, err
+	}
+
+	msgs, err := q.waitingEntries(ctx, StreamKey(queue), offset+limit)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(msgs)) <= offset {
+		return nil, nil
+	}
+	raw := make([]string, 0, len(msgs)-int(offset))
+	for _, msg := range msgs[offset:] {
+		raw = append(raw, StreamPayload(msg))
+	}
+	return raw, nil
+}
+
+// WaitingEntries returns up to count waiting stream entries of queue, oldest
+// first. Entries past the group's last delivered ID have never been
+// claimed; everything before it is either in flight or already deleted.
+func (q *JobQueue) WaitingEntries(ctx context.Context, queue string, count int64) ([]redis.XMessage, error) {
+	return q.waitingEntries(ctx, StreamKey(queue), count)
+}
+
+func (q *JobQueue) waitingEntries(ctx context.Context, key string, count int64) ([]redis.XMessage, error) {
+	start := "-"
+	lastDelivered, err := q.lastDelivered(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if lastDelivered != "" {
+		start = "(" + lastDelivered
+	}
+	if count <= 0 {
+		return q.client.XRange(ctx, key, start, "+").Result()
+	}
+	return q.client.XRangeN(ctx, key, start, "+", count).Result()
+}
+
+// InFlight returns the payloads of the jobs claimed from the given queues
+// and not yet acked.
+func (q *JobQueue) InFlight(ctx context.Context, queues []string) ([]string, error) {
+	var raw []string
+	for _, queue := range queues {
+		key := StreamKey(queue)
+		pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: key,
+			Group:  q.group,
+			Start:  "-",
+			End:    "+",
+			Count:  1000,
+		}).Result()
+		if IsNoGroup(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range pending {
+			msgs, err := q.client.XRange(ctx, key, entry.ID, entry.ID).Result()
+			if err != nil {
+				return nil, err
+			}
+			for _, msg := range msgs {
+				raw = append(raw, StreamPayload(msg))
+			}
+		}
+	}
+	return raw, nil
+}
+
+// InFlightCount counts the jobs claimed from the given queues and not yet
+// acked.
+func (q *JobQueue) InFlightCount(ctx context.Context, queues []string) (int64, error) {
+	var total int64
+	for _, queue := range queues {
+		n, err := q.inFlightCount(ctx, StreamKey(queue))
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (q *JobQueue) inFlightCount(ctx context.Context, key string) (int64, error) {
+	pending, err := q.client.XPending(ctx, key, q.group).Result()
+	if IsNoGroup(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return pending.Count, nil
+}
+
+// Purge deletes the jobs waiting in queue, leaving claimed ones to finish,
+// and returns how many were removed.
+func (q *JobQueue) Purge(ctx context.Context, queue string) (int64, error) {
+	if !q.Streams() {
+		pipe := q.client.TxPipeline()
+		length := pipe.LLen(ctx, queue)
+		pipe.Del(ctx, queue)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return 0, err
+		}
+		return length.Val(), nil
+	}
+
+	key := StreamKey(queue)
+	msgs, err := q.waitingEntries(ctx, key, 0)
+	if err != nil || len(msgs) == 0 {
+		return 0, err
+	}
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+	}
+	return q.client.XDel(ctx, key, ids...).Result()
+}
+
+// lastDelivered is the ID of the last entry the group handed out, or "" if
+// the group doesn't exist yet and every entry is waiting.
+func (q *JobQueue) lastDelivered(ctx context.Context, key string) (string, error) {
+	groups, err := q.client.XInfoGroups(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil || strings.HasPrefix(err.Error(), "ERR no such key") {
+			return "", nil
+		}
+		return "", err
+	}
+	for _, g := range groups {
+		if g.Name == q.group {
+			return g.LastDeliveredID, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,38 @@
+package services
+
+import "testing"
+
+func TestValidateQueueBackend(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		backend string
+		group   string
+		ok      bool
+	}{
+		{QueueBackendList, "", true},
+		{QueueBackendStream, "converter", true},
+		{QueueBackendStream, "", false},
+		{"kafka", "converter", false},
+	}
+	for _, c := range cases {
+		if err := ValidateQueueBackend(c.backend, c.group); (err == nil) != c.ok {
+			t.Errorf("ValidateQueueBackend(%q, %q) = %v, want ok=%v", c.backend, c.group, err, c.ok)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	t.Parallel()
+
+	s := []string{"a", "b", "c", "d"}
+	if got := page(s, 1, 2); len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Errorf("page(1, 2) = %v, want [b c]", got)
+	}
+	if got := page(s, 3, 5); len(got) != 1 || got[0] != "d" {
+		t.Errorf("page(3, 5) = %v, want [d]", got)
+	}
+	if got := page(s, 4, 1); got != nil {
+		t.Errorf("page(4, 1) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrSecretNotFound is returned for a secret reference that names no
+// secret, because it was never stored or has expired.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// JobSecrets holds job passwords at conversion:secret:<ref>, so payloads
+// can carry the reference instead. Producers store their own; the worker
+// stores a job's plaintext password for ttl when it writes the job back to
+// a queue.
+type JobSecrets struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewJobSecrets(client *redis.Client, prefix string, ttl time.Duration) *JobSecrets {
+	return &JobSecrets{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (s *JobSecrets) key(ref string) string {
+	return s.prefix + "conversion:secret:" + ref
+}
+
+// Resolve returns the secret the reference names.
+func (s *JobSecrets) Resolve(ctx context.Context, ref string) (string, error) {
+	secret, err := s.client.Get(ctx, s.key(ref)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrSecretNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return secret, nil
+}
+
+// Store keeps the secret under a new random reference and returns it.
+func (s *JobSecrets) Store(ctx context.Context, secret string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret reference: %w", err)
+	}
+	ref := hex.EncodeToString(buf)
+	if err := s.client.Set(ctx, s.key(ref), secret, s.ttl).Err(); err != nil {
+		return "", fmt.Errorf("failed to store secret: %w", err)
+	}
+	return ref, nil
+}
+
+// claimTokenPrefix starts a payload the worker tagged when claiming it.
+const claimTokenPrefix = `{"claimToken":"`
+
+// Seal returns the payload with its plaintext password moved into a new
+// secret and the secret's reference in its place. A payload that already
+// has a reference just loses the password. If the secret can't be stored,
+// the password is still dropped and the error returned with the sealed
+// payload. Payloads without a password are returned unchanged.
+func (s *JobSecrets) Seal(ctx context.Context, payload string, signingSecrets []string) (string, error) {
+	return withoutPassword(payload, signingSecrets, func(password string) (string, error) {
+		return s.Store(ctx, password)
+	})
+}
+
+// RedactPassword returns the payload without its password, for showing or
+// keeping a job where the password mustn't be. The job can no longer open
+// a protected document unless it has a passwordRef.
+func RedactPassword(payload string, signingSecrets []string) string {
+	redacted, _ := withoutPassword(payload, signingSecrets, nil)
+	return redacted
+}
+
+// withoutPassword drops the payload's password field, adding a passwordRef
+// from store when there is a store and no reference yet. A claim token
+// stays the first field, and a payload signed with one of signingSecrets
+// is signed again with the first.
+func withoutPassword(payload string, signingSecrets []string, store func(password string) (string, error)) (string, error) {
+	claimToken, body := "", payload
+	if strings.HasPrefix(payload, claimTokenPrefix) {
+		if end := strings.Index(payload[len(claimTokenPrefix):], `"`); end >= 0 {
+			claimToken = payload[:len(claimTokenPrefix)+end+1]
+			body = "{" + strings.TrimPrefix(payload[len(claimToken):], ",")
+		}
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal([]byte(body), &fields) != nil {
+		return payload, nil
+	}
+	var password string
+	if json.Unmarshal(fields["password"], &password) != nil || password == "" {
+		return payload, nil
+	}
+	signed := len(signingSecrets) > 0 && VerifyJob(signingSecrets, body) == nil
+	delete(fields, "signature")
+	delete(fields, "password")
+
+	var storeErr error
+	if _, ok := fields["passwordRef"]; !ok && store != nil {
+		ref, err := store(password)
+		if err != nil {
+			storeErr = err
+		} else {
+			fields["passwordRef"], _ = json.Marshal(ref)
+		}
+	}
+
+	stripped, err := json.Marshal(fields)
+	if err != nil {
+		return payload, fmt.Errorf("failed to encode job without its password: %w", err)
+	}
+	if signed {
+		stripped = SignJob(signingSecrets[0], stripped)
+	}
+	if claimToken != "" {
+		separator := ","
+		if string(stripped) == "{}" {
+			separator = ""
+		}
+		return claimToken + separator + string(stripped[1:]), storeErr
+	}
+	return string(stripped), storeErr
+}
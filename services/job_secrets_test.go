@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithoutPassword(t *testing.T) {
+	t.Parallel()
+
+	store := func(string) (string, error) { return "ref1", nil }
+	cases := []struct {
+		name    string
+		payload string
+		store   func(string) (string, error)
+		want    string
+	}{
+		{"no password", `{"conversionId":7, "fileGuid":"abc"}`, store, `{"conversionId":7, "fileGuid":"abc"}`},
+		{"redacted", `{"conversionId":7,"password":"hunter2"}`, nil, `{"conversionId":7}`},
+		{"sealed", `{"conversionId":7,"password":"hunter2"}`, store, `{"conversionId":7,"passwordRef":"ref1"}`},
+		{"has reference", `{"conversionId":7,"password":"hunter2","passwordRef":"old"}`, store, `{"conversionId":7,"passwordRef":"old"}`},
+		{"claim token", `{"claimToken":"abc","conversionId":7,"password":"hunter2"}`, store, `{"claimToken":"abc","conversionId":7,"passwordRef":"ref1"}`},
+		{"not an object", `[1,2]`, store, `[1,2]`},
+	}
+	for _, c := range cases {
+		got, err := withoutPassword(c.payload, nil, c.store)
+		if err != nil || got != c.want {
+			t.Errorf("%s: withoutPassword = %s, %v, want %s", c.name, got, err, c.want)
+		}
+	}
+}
+
+func TestWithoutPassword_Signed(t *testing.T) {
+	t.Parallel()
+
+	signed := string(SignJob("secret", []byte(`{"conversionId":7,"password":"hunter2"}`)))
+	got := RedactPassword(signed, []string{"secret"})
+	if strings.Contains(got, "hunter2") {
+		t.Fatalf("redacted payload %s still has the password", got)
+	}
+	if err := VerifyJob([]string{"secret"}, got); err != nil {
+		t.Fatalf("VerifyJob(%s) = %v, want nil", got, err)
+	}
+
+	// A payload that didn't verify isn't signed by the worker
+	if got := RedactPassword(signed, []string{"other"}); strings.Contains(got, "signature") {
+		t.Fatalf("redacted payload %s kept a signature it can't vouch for", got)
+	}
+}
+
+func TestWithoutPassword_StoreFails(t *testing.T) {
+	t.Parallel()
+
+	failing := func(string) (string, error) { return "", errors.New("redis down") }
+	got, err := withoutPassword(`{"conversionId":7,"password":"hunter2"}`, nil, failing)
+	if err == nil || got != `{"conversionId":7}` {
+		t.Fatalf("withoutPassword = %s, %v, want the password dropped and the error", got, err)
+	}
+}
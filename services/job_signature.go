@@ -0,0 +1,59 @@
+package services
+
+import (
+	"crypto/hmac"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrJobUnsigned         = errors.New("job is not signed")
+	ErrJobSignatureInvalid = errors.New("job signature does not match")
+)
+
+const jobSignaturePrefix = `{"signature":"`
+
+// SignJob signs a job payload for the queue. The signature is the
+// HMAC-SHA256 of the compact payload, prepended as its first field:
+//
+//	{"signature":"sha256=<hex>",<rest of the payload>
+//
+// so the signed bytes are recovered by removing that field again, whatever
+// JSON encoder the producer used.
+func SignJob(secret string, payload []byte) []byte {
+	body := strings.TrimLeft(string(payload), " \t\r\n")
+	if !strings.HasPrefix(body, "{") {
+		return payload
+	}
+
+	rest := strings.TrimLeft(body[1:], " \t\r\n")
+	separator := ","
+	if strings.HasPrefix(rest, "}") {
+		separator = ""
+	}
+	signature := "sha256=" + SignWebhook(secret, []byte("{"+rest))
+	return []byte(jobSignaturePrefix + signature + `"` + separator + rest)
+}
+
+// VerifyJob checks a payload signed by SignJob against each secret, so
+// producers can move to a new secret while workers still accept the old
+// one.
+func VerifyJob(secrets []string, payload string) error {
+	if !strings.HasPrefix(payload, jobSignaturePrefix) {
+		return ErrJobUnsigned
+	}
+	end := strings.Index(payload[len(jobSignaturePrefix):], `"`)
+	if end < 0 {
+		return ErrJobUnsigned
+	}
+	signature := payload[len(jobSignaturePrefix) : len(jobSignaturePrefix)+end]
+	signed := "{" + strings.TrimPrefix(payload[len(jobSignaturePrefix)+end+1:], ",")
+
+	for _, secret := range secrets {
+		expected := "sha256=" + SignWebhook(secret, []byte(signed))
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrJobSignatureInvalid
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignJob_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	payload := `{"conversionId":7,"fileGuid":"abc","callbackUrl":"https://app.example.com/hook"}`
+	signed := string(SignJob("secret", []byte(payload)))
+	if !strings.HasPrefix(signed, `{"signature":"sha256=`) {
+		t.Fatalf("signed payload = %s, want a leading signature field", signed)
+	}
+	if err := VerifyJob([]string{"secret"}, signed); err != nil {
+		t.Fatalf("VerifyJob = %v, want nil", err)
+	}
+
+	// The producer recipe: sign the compact JSON, then splice the field in
+	recipe := `{"signature":"sha256=` + SignWebhook("secret", []byte(payload)) + `",` + payload[1:]
+	if recipe != signed {
+		t.Fatalf("recipe = %s, want %s", recipe, signed)
+	}
+
+	if got := string(SignJob("secret", []byte("{}"))); VerifyJob([]string{"secret"}, got) != nil {
+		t.Fatalf("signed empty object %s doesn't verify", got)
+	}
+}
+
+func TestVerifyJob_Rejects(t *testing.T) {
+	t.Parallel()
+
+	signed := string(SignJob("secret", []byte(`{"conversionId":7,"outputS3Path":"out/a.pdf"}`)))
+	tampered := strings.Replace(signed, "out/a.pdf", "out/b.pdf", 1)
+
+	cases := []struct {
+		name    string
+		secrets []string
+		payload string
+		want    error
+	}{
+		{"unsigned", []string{"secret"}, `{"conversionId":7}`, ErrJobUnsigned},
+		{"tampered", []string{"secret"}, tampered, ErrJobSignatureInvalid},
+		{"wrong secret", []string{"other"}, signed, ErrJobSignatureInvalid},
+		{"rotated", []string{"new", "secret"}, signed, nil},
+	}
+	for _, c := range cases {
+		if err := VerifyJob(c.secrets, c.payload); !errors.Is(err, c.want) {
+			t.Errorf("%s: VerifyJob = %v, want %v", c.name, err, c.want)
+		}
+	}
+}
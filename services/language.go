@@ -0,0 +1,158 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Minimum evidence before a language is reported: stopword hits for
+// Latin-script languages, letters for the others.
+const (
+	minStopwordHits  = 8
+	minScriptLetters = 40
+)
+
+// LanguageGuess is the primary language of a document's text layer.
+type LanguageGuess struct {
+	// Code is the ISO 639-1 code, e.g. "de".
+	Code string `json:"code"`
+	// OCR is the Tesseract language to scan the document's pages with,
+	// e.g. "deu".
+	OCR string `json:"ocr"`
+	// Confidence is how clearly Code beat the runner-up, from 0.5 (a tie)
+	// to 1.
+	Confidence float64 `json:"confidence"`
+}
+
+// tesseractLanguages maps ISO 639-1 codes to Tesseract traineddata names.
+var tesseractLanguages = map[string]string{
+	"en": "eng", "de": "deu", "fr": "fra", "es": "spa", "it": "ita",
+	"nl": "nld", "pt": "por", "sv": "swe", "da": "dan", "nb": "nor",
+	"fi": "fin", "pl": "pol", "ru": "rus", "uk": "ukr", "el": "ell",
+	"ar": "ara", "he": "heb", "ja": "jpn", "ko": "kor", "zh": "chi_sim",
+	"th": "tha",
+}
+
+// stopwords are frequent words that set the Latin-script languages apart.
+// Words shared between languages count for each of them.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "that", "for", "it", "with", "as", "was", "on", "are", "be", "this", "by", "not", "have", "from", "which"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "sich", "auf", "für", "des", "dem", "von", "auch", "werden", "wird"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "pour", "que", "dans", "qui", "pas", "sur", "du", "au", "avec", "il", "ce", "sont", "par"},
+	"es": {"el", "la", "los", "las", "y", "que", "del", "por", "con", "una", "para", "es", "se", "no", "en", "lo", "como", "más", "pero", "sus"},
+	"it": {"il", "di", "che", "la", "per", "un", "una", "non", "con", "sono", "del", "della", "le", "gli", "è", "nel", "alla", "anche", "come", "questo"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "op", "te", "niet", "zijn", "met", "voor", "die", "ook", "aan", "worden", "wordt", "bij", "naar"},
+	"pt": {"o", "os", "as", "que", "do", "da", "em", "um", "uma", "para", "com", "não", "é", "se", "dos", "das", "mais", "pelo", "pela", "ao"},
+	"sv": {"och", "att", "det", "som", "är", "på", "för", "med", "inte", "av", "till", "den", "har", "jag", "om", "ett", "var", "kan", "från", "också"},
+	"da": {"og", "at", "det", "som", "er", "på", "for", "med", "ikke", "af", "til", "den", "har", "jeg", "et", "fra", "kan", "også", "blev", "efter"},
+	"nb": {"og", "at", "det", "som", "er", "på", "for", "med", "ikke", "av", "til", "den", "har", "jeg", "et", "fra", "kan", "også", "ble", "etter"},
+	"fi": {"ja", "on", "ei", "se", "että", "hän", "oli", "ovat", "mutta", "tai", "kun", "myös", "kuin", "joka", "mitä", "tämä", "olla", "sen", "ole", "niin"},
+	"pl": {"i", "w", "nie", "na", "się", "że", "z", "do", "jest", "to", "jak", "ale", "co", "dla", "od", "po", "przez", "oraz", "tak", "które"},
+}
+
+var stopwordLanguages = func() map[string][]string {
+	index := make(map[string][]string)
+	for language, words := range stopwords {
+		for _, word := range words {
+			index[word] = append(index[word], language)
+		}
+	}
+	return index
+}()
+
+// DetectLanguage guesses the primary language of text, or returns nil when
+// there is too little text to tell. Scripts used by one language family
+// (Greek, Arabic, Hebrew, Thai, Hangul, kana, Han, Cyrillic) decide by
+// themselves; Latin-script text is told apart by its stopwords.
+func DetectLanguage(text string) *LanguageGuess {
+	scripts := make(map[string]int)
+	latin, total := 0, 0
+	ukrainian := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		total++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["ru"]++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				ukrainian++
+			}
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			scripts["ja"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["zh"]++
+		}
+	}
+
+	// Japanese mixes kana with Han; any real share of kana decides it
+	if scripts["ja"] > 0 && scripts["ja"]*10 >= scripts["ja"]+scripts["zh"] {
+		scripts["ja"] += scripts["zh"]
+		delete(scripts, "zh")
+	}
+	// Ukrainian has letters Russian lacks
+	if ukrainian > 0 && ukrainian*100 >= scripts["ru"] {
+		scripts["uk"] = scripts["ru"]
+		delete(scripts, "ru")
+	}
+
+	script, letters := "", 0
+	for code, n := range scripts {
+		if n > letters || (n == letters && code < script) {
+			script, letters = code, n
+		}
+	}
+	if letters > latin {
+		if letters < minScriptLetters {
+			return nil
+		}
+		return newLanguageGuess(script, float64(letters)/float64(total))
+	}
+
+	return detectLatin(text)
+}
+
+func detectLatin(text string) *LanguageGuess {
+	hits := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for _, language := range stopwordLanguages[word] {
+			hits[language]++
+		}
+	}
+
+	best, second := "", 0
+	for language, n := range hits {
+		if n > hits[best] || (n == hits[best] && language < best) {
+			best = language
+		}
+	}
+	for language, n := range hits {
+		if language != best && n > second {
+			second = n
+		}
+	}
+	if best == "" || hits[best] < minStopwordHits {
+		return nil
+	}
+	return newLanguageGuess(best, float64(hits[best])/float64(hits[best]+second))
+}
+
+func newLanguageGuess(code string, confidence float64) *LanguageGuess {
+	if confidence > 1 {
+		confidence = 1
+	}
+	return &LanguageGuess{Code: code, OCR: tesseractLanguages[code], Confidence: float64(int(confidence*100+0.5)) / 100}
+}
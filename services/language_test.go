@@ -0,0 +1,80 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		text string
+		code string
+		ocr  string
+	}{
+		{
+			name: "english",
+			text: "The contract is signed by both parties and it remains in force for the term that is set out in the schedule, which was agreed on the date of this letter.",
+			code: "en",
+			ocr:  "eng",
+		},
+		{
+			name: "german",
+			text: "Der Vertrag wird von beiden Parteien unterzeichnet und ist für die Dauer gültig, die in der Anlage festgelegt ist. Die Kündigung ist nicht möglich, auch wenn sich das Angebot ändert.",
+			code: "de",
+			ocr:  "deu",
+		},
+		{
+			name: "french",
+			text: "Le contrat est signé par les deux parties et il reste en vigueur pour la durée qui est fixée dans une annexe. La résiliation sur demande du client est possible avec un préavis.",
+			code: "fr",
+			ocr:  "fra",
+		},
+		{
+			name: "russian",
+			text: "Договор подписывается обеими сторонами и действует в течение срока, указанного в приложении к настоящему договору.",
+			code: "ru",
+			ocr:  "rus",
+		},
+		{
+			name: "ukrainian",
+			text: "Договір підписується обома сторонами і діє протягом строку, який визначено в додатку до цього договору.",
+			code: "uk",
+			ocr:  "ukr",
+		},
+		{
+			name: "japanese",
+			text: strings.Repeat("この契約は両当事者によって署名されます。", 3),
+			code: "ja",
+			ocr:  "jpn",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			guess := DetectLanguage(tt.text)
+			if guess == nil {
+				t.Fatal("DetectLanguage = nil")
+			}
+			if guess.Code != tt.code || guess.OCR != tt.ocr {
+				t.Errorf("DetectLanguage = %+v, want %s/%s", guess, tt.code, tt.ocr)
+			}
+			if guess.Confidence < 0.5 || guess.Confidence > 1 {
+				t.Errorf("confidence %v out of range", guess.Confidence)
+			}
+		})
+	}
+}
+
+func TestDetectLanguage_TooLittleText(t *testing.T) {
+	t.Parallel()
+
+	for _, text := range []string{"", "Invoice 2024-001", "Счёт", "12 345,00 EUR"} {
+		if guess := DetectLanguage(text); guess != nil {
+			t.Errorf("DetectLanguage(%q) = %+v, want nil", text, guess)
+		}
+	}
+}
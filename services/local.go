@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// LocalStorage is the Storage for a directory on a mounted volume, such as
+// an NFS share or a hostPath, for deployments without object storage. Keys
+// are paths below the root.
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage root: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("storage root %s is not a directory", root)
+	}
+	return &LocalStorage{root: root}, nil
+}
+
+// path maps a key to its file. Keys that are absolute or climb out of the
+// root with .. are refused rather than cleaned, since they can only come
+// from a broken or hostile producer.
+func (l *LocalStorage) path(key string) (string, error) {
+	if !fs.ValidPath(key) || key == "." {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+func (l *LocalStorage) Download(ctx context.Context, key string, localPath string) error {
+	src, err := l.open(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from local storage: %w", err)
+	}
+	defer src.Close()
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, src); err != nil {
+		return fmt.Errorf("failed to read from local storage: %w", err)
+	}
+	return nil
+}
+
+// Upload stores the file under its key. The volume has nowhere to keep the
+// content type, so Stat guesses it from the extension instead.
+func (l *LocalStorage) Upload(ctx context.Context, localPath string, key string, contentType string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if err := l.write(key, file); err != nil {
+		return fmt.Errorf("failed to write to local storage: %w", err)
+	}
+	return nil
+}
+
+func (l *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := l.open(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from local storage: %w", err)
+	}
+	return file, nil
+}
+
+func (l *LocalStorage) UploadStream(ctx context.Context, r io.Reader, key string, contentType string) error {
+	if err := l.write(key, r); err != nil {
+		return fmt.Errorf("failed to write to local storage: %w", err)
+	}
+	return nil
+}
+
+func (l *LocalStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat local object: %w", err)
+	}
+	info, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return nil, fmt.Errorf("failed to stat local object: %w", ErrObjectNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat local object: %w", err)
+	}
+	return &ObjectInfo{Size: info.Size(), ContentType: mime.TypeByExtension(path.Ext(key))}, nil
+}
+
+func (l *LocalStorage) Copy(ctx context.Context, from string, to string) error {
+	src, err := l.open(from)
+	if err != nil {
+		return fmt.Errorf("failed to copy local object: %w", err)
+	}
+	defer src.Close()
+
+	if err := l.write(to, src); err != nil {
+		return fmt.Errorf("failed to copy local object: %w", err)
+	}
+	return nil
+}
+
+func (l *LocalStorage) Delete(ctx context.Context, key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return fmt.Errorf("failed to delete local object: %w", err)
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete local object: %w", err)
+	}
+	return nil
+}
+
+// Ping checks that the root is still there, which catches a volume that was
+// unmounted or a stale NFS handle.
+func (l *LocalStorage) Ping(ctx context.Context) error {
+	if _, err := os.Stat(l.root); err != nil {
+		return fmt.Errorf("failed to reach storage root: %w", err)
+	}
+	return nil
+}
+
+func (l *LocalStorage) Cleanup(path string) error {
+	return removeLocal(path)
+}
+
+// open opens the object's file, mapping a missing one to ErrObjectNotFound.
+func (l *LocalStorage) open(key string) (*os.File, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return file, err
+}
+
+// write stores r under the key through a temporary file in the same
+// directory, so readers never see a partial object.
+func (l *LocalStorage) write(key string, r io.Reader) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// CreateTemp makes the file readable by its owner only
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	local, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	src := filepath.Join(t.TempDir(), "a.pdf")
+	if err := os.WriteFile(src, []byte("%PDF-1.7"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Upload(ctx, src, "out/2024/a.pdf", "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := local.Stat(ctx, "out/2024/a.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 8 || info.ContentType != "application/pdf" {
+		t.Errorf("Stat = %+v", info)
+	}
+
+	if err := local.Copy(ctx, "out/2024/a.pdf", "trash/out/2024/a.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Delete(ctx, "out/2024/a.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Delete(ctx, "out/2024/a.pdf"); err != nil {
+		t.Errorf("deleting a missing object = %v", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "b.pdf")
+	if err := local.Download(ctx, "trash/out/2024/a.pdf", dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "%PDF-1.7" {
+		t.Errorf("downloaded %q", data)
+	}
+
+	if _, err := local.Stat(ctx, "out/2024/a.pdf"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat of a deleted object = %v, want ErrObjectNotFound", err)
+	}
+	if _, err := local.Stat(ctx, "out"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat of a directory = %v, want ErrObjectNotFound", err)
+	}
+	if err := local.Copy(ctx, "out/missing.pdf", "out/b.pdf"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Copy of a missing object = %v, want ErrObjectNotFound", err)
+	}
+	if err := local.Ping(ctx); err != nil {
+		t.Errorf("Ping = %v", err)
+	}
+}
+
+func TestLocalStorage_RefusesKeysOutsideRoot(t *testing.T) {
+	t.Parallel()
+
+	local, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"../etc/passwd", "/etc/passwd", "out/../../x.pdf", ""} {
+		if _, err := local.Stat(context.Background(), key); err == nil || errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("Stat(%q) = %v, want the key refused", key, err)
+		}
+	}
+}
+
+func TestLocalStorage_Stream(t *testing.T) {
+	t.Parallel()
+
+	local, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := local.UploadStream(ctx, strings.NewReader("%PDF-1.7"), "out/doc.pdf", "application/pdf"); err != nil {
+		t.Fatalf("UploadStream: %v", err)
+	}
+	body, err := local.Open(ctx, "out/doc.pdf")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || string(got) != "%PDF-1.7" {
+		t.Fatalf("Open read %q, %v", got, err)
+	}
+
+	if _, err := local.Open(ctx, "out/missing.pdf"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("Open of a missing key = %v, want ErrObjectNotFound", err)
+	}
+}
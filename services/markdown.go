@@ -0,0 +1,63 @@
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// markdownFileName is the name the Markdown input is uploaded under; the
+// wrapper template renders it with {{ toHTML "content.md" }}.
+const markdownFileName = "content.md"
+
+//go:embed templates/markdown.html
+var defaultMarkdownTemplate []byte
+
+// IsMarkdownExtension reports whether ext is a Markdown document.
+func IsMarkdownExtension(ext string) bool {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "md", "markdown":
+		return true
+	}
+	return false
+}
+
+// IsHTMLExtension reports whether ext is an HTML page.
+func IsHTMLExtension(ext string) bool {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "html", "htm", "xhtml":
+		return true
+	}
+	return false
+}
+
+// LoadMarkdownTemplate reads the HTML wrapper for Markdown conversions, or
+// returns the built-in one when path is empty.
+func LoadMarkdownTemplate(path string) ([]byte, error) {
+	if path == "" {
+		return defaultMarkdownTemplate, nil
+	}
+
+	tmpl, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read markdown template: %w", err)
+	}
+	if !bytes.Contains(tmpl, []byte(markdownFileName)) {
+		return nil, fmt.Errorf("markdown template %s never renders %q; add {{ toHTML %q }}", path, markdownFileName, markdownFileName)
+	}
+	return tmpl, nil
+}
+
+// SetMarkdownTemplate replaces the built-in Markdown wrapper template.
+func (g *GotenbergService) SetMarkdownTemplate(tmpl []byte) {
+	g.markdown = tmpl
+}
+
+func (g *GotenbergService) markdownTemplate() []byte {
+	if g.markdown == nil {
+		return defaultMarkdownTemplate
+	}
+	return g.markdown
+}
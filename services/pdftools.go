@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PDFToolsService wraps the poppler-utils binaries shipped in the converter
+// image for work Gotenberg doesn't do (text extraction, rasterizing).
+type PDFToolsService struct{}
+
+func NewPDFToolsService() *PDFToolsService {
+	return &PDFToolsService{}
+}
+
+// ExtractText writes the PDF's text layer to a sibling .txt file.
+func (t *PDFToolsService) ExtractText(ctx context.Context, pdfPath string) (string, error) {
+	outputPath := pdfPath + ".txt"
+	if err := run(ctx, "pdftotext", "-enc", "UTF-8", "-layout", pdfPath, outputPath); err != nil {
+		return "", fmt.Errorf("failed to extract text: %w", err)
+	}
+	return outputPath, nil
+}
+
+// RenderThumbnail rasterizes the first page to a PNG scaled to width pixels.
+func (t *PDFToolsService) RenderThumbnail(ctx context.Context, pdfPath string, width int) (string, error) {
+	outputBase := fmt.Sprintf("%s.thumb-%d", pdfPath, width)
+	if err := run(ctx, "pdftoppm", "-png", "-singlefile", "-f", "1", "-l", "1",
+		"-scale-to-x", strconv.Itoa(width), "-scale-to-y", "-1", pdfPath, outputBase); err != nil {
+		return "", fmt.Errorf("failed to render thumbnail: %w", err)
+	}
+	return outputBase + ".png", nil
+}
+
+// SampleText returns the text layer of the PDF's first pages.
+func (t *PDFToolsService) SampleText(ctx context.Context, pdfPath string, pages int) (string, error) {
+	text, err := output(ctx, "pdftotext", "-enc", "UTF-8", "-l", strconv.Itoa(pages), pdfPath, "-")
+	if err != nil {
+		return "", fmt.Errorf("failed to extract text: %w", err)
+	}
+	return text, nil
+}
+
+func run(ctx context.Context, name string, args ...string) error {
+	_, err := output(ctx, name, args...)
+	return err
+}
+
+// AccessibilityReport summarizes how usable a PDF is with assistive
+// technology, based on its structure tree.
+type AccessibilityReport struct {
+	Tagged   bool   `json:"tagged"`
+	Title    string `json:"title,omitempty"`
+	Headings int    `json:"headings"`
+	Figures  int    `json:"figures"`
+	Score    int    `json:"score"`
+}
+
+// Info returns pdfinfo's "Key: value" fields.
+func (t *PDFToolsService) Info(ctx context.Context, pdfPath string) (map[string]string, error) {
+	out, err := output(ctx, "pdfinfo", pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			info[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return info, nil
+}
+
+// Accessibility scores a PDF out of 100: tagged (40), has a document title
+// (20), uses heading structure (20) and has a non-empty structure tree (20).
+func (t *PDFToolsService) Accessibility(ctx context.Context, pdfPath string) (*AccessibilityReport, error) {
+	info, err := t.Info(ctx, pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF info: %w", err)
+	}
+
+	report := &AccessibilityReport{
+		Tagged: info["Tagged"] == "yes",
+		Title:  info["Title"],
+	}
+
+	structure := ""
+	if report.Tagged {
+		if structure, err = output(ctx, "pdfinfo", "-struct", pdfPath); err != nil {
+			return nil, fmt.Errorf("failed to read structure tree: %w", err)
+		}
+	}
+	for _, line := range strings.Split(structure, "\n") {
+		element := strings.Fields(line)
+		if len(element) == 0 {
+			continue
+		}
+		switch element[0] {
+		case "H", "H1", "H2", "H3", "H4", "H5", "H6":
+			report.Headings++
+		case "Figure":
+			report.Figures++
+		}
+	}
+
+	if report.Tagged {
+		report.Score += 40
+	}
+	if report.Title != "" {
+		report.Score += 20
+	}
+	if report.Headings > 0 {
+		report.Score += 20
+	}
+	if strings.TrimSpace(structure) != "" {
+		report.Score += 20
+	}
+	return report, nil
+}
+
+// PDFSummary describes a produced PDF for search and billing, so
+// downstream services needn't download it.
+type PDFSummary struct {
+	Pages    int    `json:"pages"`
+	Version  string `json:"version,omitempty"`
+	Producer string `json:"producer,omitempty"`
+	// Width and Height are the first page's size in points.
+	Width    float64 `json:"width,omitempty"`
+	Height   float64 `json:"height,omitempty"`
+	PageSize string  `json:"pageSize,omitempty"`
+}
+
+// Summary reads the page count, PDF version, producer and page size.
+func (t *PDFToolsService) Summary(ctx context.Context, pdfPath string) (*PDFSummary, error) {
+	info, err := t.Info(ctx, pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF info: %w", err)
+	}
+	return summarizeInfo(info), nil
+}
+
+// pageSizePattern matches pdfinfo's "595.276 x 841.89 pts (A4)".
+var pageSizePattern = regexp.MustCompile(`^([\d.]+) x ([\d.]+) pts(?: \(([^)]+)\))?`)
+
+func summarizeInfo(info map[string]string) *PDFSummary {
+	summary := &PDFSummary{
+		Version:  info["PDF version"],
+		Producer: info["Producer"],
+	}
+	summary.Pages, _ = strconv.Atoi(info["Pages"])
+	if m := pageSizePattern.FindStringSubmatch(info["Page size"]); m != nil {
+		summary.Width, _ = strconv.ParseFloat(m[1], 64)
+		summary.Height, _ = strconv.ParseFloat(m[2], 64)
+		summary.PageSize = m[3]
+	}
+	return summary
+}
+
+// PDFAClaim returns the PDF/A level a PDF declares in its XMP metadata, e.g.
+// "PDF/A-2b", or "" when it declares none. The claim isn't verified.
+func (t *PDFToolsService) PDFAClaim(ctx context.Context, pdfPath string) (string, error) {
+	xmp, err := output(ctx, "pdfinfo", "-meta", pdfPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read PDF metadata: %w", err)
+	}
+	return parsePDFAIdentification(xmp), nil
+}
+
+// pdfaIDPattern matches the pdfaid:part and pdfaid:conformance properties,
+// written either as attributes or as elements.
+var pdfaIDPattern = regexp.MustCompile(`pdfaid:(part|conformance)\s*(?:=\s*["']([^"']*)["']|>\s*([^<\s]*)\s*<)`)
+
+func parsePDFAIdentification(xmp string) string {
+	var part, conformance string
+	for _, m := range pdfaIDPattern.FindAllStringSubmatch(xmp, -1) {
+		value := m[2] + m[3]
+		if value == "" {
+			continue // a closing tag
+		}
+		if m[1] == "part" {
+			part = value
+		} else {
+			conformance = strings.ToLower(value)
+		}
+	}
+	if part == "" || conformance == "" {
+		return ""
+	}
+	return "PDF/A-" + part + conformance
+}
+
+// Fonts returns the names of the fonts embedded in a PDF, as pdffonts
+// lists them.
+func (t *PDFToolsService) Fonts(ctx context.Context, pdfPath string) ([]string, error) {
+	out, err := output(ctx, "pdffonts", pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fonts: %w", err)
+	}
+	return parsePDFFonts(out), nil
+}
+
+// parsePDFFonts reads the name column of pdffonts output, below the dashed
+// rule under the header.
+func parsePDFFonts(out string) []string {
+	var fonts []string
+	body := false
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "---") {
+			body = true
+			continue
+		}
+		if fields := strings.Fields(line); body && len(fields) > 0 && fields[0] != "[none]" {
+			fonts = append(fonts, fields[0])
+		}
+	}
+	return fonts
+}
+
+func output(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
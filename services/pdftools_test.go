@@ -0,0 +1,61 @@
+package services
+
+import "testing"
+
+func TestParsePDFAIdentification(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		`<rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/" pdfaid:part="2" pdfaid:conformance="B"/>`: "PDF/A-2b",
+		`<rdf:Description><pdfaid:part>1</pdfaid:part>
+		 <pdfaid:conformance>A</pdfaid:conformance></rdf:Description>`: "PDF/A-1a",
+		`<rdf:Description pdfaid:part='3'/>`:                             "",
+		`<rdf:Description><dc:title>Report</dc:title></rdf:Description>`: "",
+	}
+	for xmp, want := range cases {
+		if got := parsePDFAIdentification(xmp); got != want {
+			t.Errorf("parsePDFAIdentification(%q) = %q, want %q", xmp, got, want)
+		}
+	}
+}
+
+func TestParsePDFFonts(t *testing.T) {
+	t.Parallel()
+
+	out := `name                                 type              encoding         emb sub uni object ID
+------------------------------------ ----------------- ---------------- --- --- --- ---------
+BAAAAA+LiberationSerif               TrueType          WinAnsi          yes yes no      12  0
+CAAAAA+OpenSymbol                    TrueType          WinAnsi          yes yes no      17  0
+[none]                               Type 3            Custom           yes no  no      20  0
+`
+	got := parsePDFFonts(out)
+	want := []string{"BAAAAA+LiberationSerif", "CAAAAA+OpenSymbol"}
+	if len(got) != len(want) {
+		t.Fatalf("parsePDFFonts() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("font %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSummarizeInfo(t *testing.T) {
+	t.Parallel()
+
+	got := summarizeInfo(map[string]string{
+		"Producer":    "LibreOffice 7.6",
+		"Pages":       "12",
+		"PDF version": "1.7",
+		"Page size":   "595.276 x 841.89 pts (A4)",
+	})
+	want := PDFSummary{Pages: 12, Version: "1.7", Producer: "LibreOffice 7.6", Width: 595.276, Height: 841.89, PageSize: "A4"}
+	if *got != want {
+		t.Errorf("summarizeInfo() = %+v, want %+v", *got, want)
+	}
+
+	got = summarizeInfo(map[string]string{"Pages": "1", "Page size": "612 x 792 pts"})
+	if got.Width != 612 || got.Height != 792 || got.PageSize != "" {
+		t.Errorf("summarizeInfo() without a named size = %+v", *got)
+	}
+}
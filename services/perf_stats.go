@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const perfSampleLimit = 1000
+
+// sizeBuckets are upper bounds (bytes) used to group duration samples.
+var sizeBuckets = []struct {
+	name  string
+	limit int64
+}{
+	{"lt100k", 100 * 1024},
+	{"lt1m", 1024 * 1024},
+	{"lt10m", 10 * 1024 * 1024},
+	{"lt50m", 50 * 1024 * 1024},
+	{"gte50m", -1},
+}
+
+// DurationPercentiles summarizes recorded conversion durations.
+type DurationPercentiles struct {
+	Samples int           `json:"samples"`
+	P50     time.Duration `json:"p50"`
+	P90     time.Duration `json:"p90"`
+	P99     time.Duration `json:"p99"`
+}
+
+// PerformanceStats keeps the most recent conversion durations per extension
+// and size bucket in capped Redis lists, shared by all instances.
+type PerformanceStats struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewPerformanceStats(client *redis.Client, prefix string) *PerformanceStats {
+	return &PerformanceStats{client: client, prefix: prefix}
+}
+
+func SizeBucket(sizeBytes int64) string {
+	for _, b := range sizeBuckets {
+		if b.limit < 0 || sizeBytes < b.limit {
+			return b.name
+		}
+	}
+	return sizeBuckets[len(sizeBuckets)-1].name
+}
+
+func (s *PerformanceStats) key(extension string, bucket string) string {
+	return fmt.Sprintf("%sconversion:perf:%s:%s", s.prefix, strings.ToLower(extension), bucket)
+}
+
+// Record adds a sample for the extension and for the "all" aggregate.
+func (s *PerformanceStats) Record(ctx context.Context, extension string, sizeBytes int64, duration time.Duration) error {
+	bucket := SizeBucket(sizeBytes)
+	ms := duration.Milliseconds()
+
+	pipe := s.client.TxPipeline()
+	for _, key := range []string{s.key(extension, bucket), s.key("all", bucket)} {
+		pipe.LPush(ctx, key, ms)
+		pipe.LTrim(ctx, key, 0, perfSampleLimit-1)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Percentiles returns duration percentiles for the extension's size bucket,
+// falling back to all extensions when the extension has no history.
+func (s *PerformanceStats) Percentiles(ctx context.Context, extension string, sizeBytes int64) (*DurationPercentiles, error) {
+	bucket := SizeBucket(sizeBytes)
+
+	for _, ext := range []string{extension, "all"} {
+		values, err := s.client.LRange(ctx, s.key(ext, bucket), 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(values) > 0 {
+			return percentiles(values), nil
+		}
+	}
+
+	return &DurationPercentiles{}, nil
+}
+
+func percentiles(values []string) *DurationPercentiles {
+	samples := make([]int64, 0, len(values))
+	for _, v := range values {
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			samples = append(samples, ms)
+		}
+	}
+	if len(samples) == 0 {
+		return &DurationPercentiles{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	at := func(q float64) time.Duration {
+		idx := int(q*float64(len(samples))+0.5) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		if idx >= len(samples) {
+			idx = len(samples) - 1
+		}
+		return time.Duration(samples[idx]) * time.Millisecond
+	}
+
+	return &DurationPercentiles{
+		Samples: len(samples),
+		P50:     at(0.50),
+		P90:     at(0.90),
+		P99:     at(0.99),
+	}
+}
+
+// SuggestedTimeout scales p99 by factor and clamps it to [min, max]. It
+// reports false when there are fewer than minSamples samples to trust.
+func (d *DurationPercentiles) SuggestedTimeout(factor float64, minSamples int, min time.Duration, max time.Duration) (time.Duration, bool) {
+	if d.Samples == 0 || d.Samples < minSamples {
+		return 0, false
+	}
+
+	timeout := time.Duration(float64(d.P99) * factor)
+	if timeout < min {
+		timeout = min
+	}
+	if max > 0 && timeout > max {
+		timeout = max
+	}
+	return timeout, true
+}
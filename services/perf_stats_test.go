@@ -0,0 +1,60 @@
+package services
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSizeBucket(t *testing.T) {
+	t.Parallel()
+
+	cases := map[int64]string{
+		0:                "lt100k",
+		100*1024 - 1:     "lt100k",
+		100 * 1024:       "lt1m",
+		5 * 1024 * 1024:  "lt10m",
+		50 * 1024 * 1024: "gte50m",
+	}
+	for size, want := range cases {
+		if got := SizeBucket(size); got != want {
+			t.Errorf("SizeBucket(%d) = %s, want %s", size, got, want)
+		}
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	t.Parallel()
+
+	values := make([]string, 0, 100)
+	for i := 100; i >= 1; i-- {
+		values = append(values, strconv.Itoa(i))
+	}
+
+	got := percentiles(values)
+	if got.Samples != 100 {
+		t.Fatalf("Samples = %d, want 100", got.Samples)
+	}
+	if got.P50 != 50*time.Millisecond || got.P90 != 90*time.Millisecond || got.P99 != 99*time.Millisecond {
+		t.Fatalf("percentiles = %v/%v/%v, want 50ms/90ms/99ms", got.P50, got.P90, got.P99)
+	}
+}
+
+func TestSuggestedTimeout(t *testing.T) {
+	t.Parallel()
+
+	stats := &DurationPercentiles{Samples: 50, P99: 40 * time.Second}
+
+	if _, ok := stats.SuggestedTimeout(1.5, 100, 0, 0); ok {
+		t.Fatal("expected too few samples to be rejected")
+	}
+	if got, _ := stats.SuggestedTimeout(1.5, 20, 10*time.Second, 0); got != 60*time.Second {
+		t.Fatalf("timeout = %v, want 60s", got)
+	}
+	if got, _ := stats.SuggestedTimeout(1.5, 20, 10*time.Second, 45*time.Second); got != 45*time.Second {
+		t.Fatalf("timeout = %v, want clamped to 45s", got)
+	}
+	if got, _ := stats.SuggestedTimeout(0.1, 20, 10*time.Second, 0); got != 10*time.Second {
+		t.Fatalf("timeout = %v, want raised to 10s", got)
+	}
+}
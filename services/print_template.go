@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"mime/multipart"
+	"strings"
+)
+
+// MaxPrintTemplateBytes limits a job's header or footer, which travel in
+// the job payload.
+const MaxPrintTemplateBytes = 64 << 10
+
+// printTemplateMargin is the top or bottom margin, in inches, given to a
+// page with a header or footer; Chromium prints them inside the margin.
+const printTemplateMargin = "0.8"
+
+// printVariables are the placeholders a header or footer may use, replaced
+// by the elements Chromium fills in on every page.
+var printVariables = strings.NewReplacer(
+	"{{pageNumber}}", `<span class="pageNumber"></span>`,
+	"{{totalPages}}", `<span class="totalPages"></span>`,
+	"{{date}}", `<span class="date"></span>`,
+	"{{title}}", `<span class="title"></span>`,
+)
+
+// printTemplateStyle makes a bare header or footer legible: Chromium
+// prints them at a near-zero font size without margins otherwise.
+const printTemplateStyle = `<style>body { font-family: sans-serif; font-size: 9px; margin: 0 1cm; width: 100%; }</style>`
+
+// PrintTemplate turns a job's header or footer into the document Chromium
+// prints on every page. The {{pageNumber}}, {{totalPages}}, {{date}} and
+// {{title}} placeholders are filled in per page. A fragment is wrapped in
+// a document with a default style; a full document is sent as it is.
+func PrintTemplate(template string) []byte {
+	html := printVariables.Replace(template)
+	if strings.Contains(strings.ToLower(html), "<html") {
+		return []byte(html)
+	}
+	return []byte("<!DOCTYPE html><html><head>" + printTemplateStyle + "</head><body>" + html + "</body></html>")
+}
+
+// writePrintTemplates adds the header and footer, with room for them in
+// the page margins, to a Chromium form.
+func writePrintTemplates(writer *multipart.Writer, opts ConvertOptions) error {
+	templates := []struct {
+		name     string
+		template string
+		margin   string
+	}{
+		{"header.html", opts.Header, "marginTop"},
+		{"footer.html", opts.Footer, "marginBottom"},
+	}
+	for _, t := range templates {
+		if t.template == "" {
+			continue
+		}
+		part, err := writer.CreateFormFile("files", t.name)
+		if err != nil {
+			return fmt.Errorf("failed to create form file: %w", err)
+		}
+		if _, err := part.Write(PrintTemplate(t.template)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", t.name, err)
+		}
+		writer.WriteField(t.margin, printTemplateMargin)
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"converter/config"
+	"converter/logging"
+	"converter/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrUnknownQueue   = errors.New("unknown queue")
+	ErrJobNotFound    = errors.New("job not found")
+	ErrQueueProtected = errors.New("queue holds in-flight jobs and cannot be purged")
+)
+
+// QueuedJob is a raw queue entry with its decoded job, when it parses.
+type QueuedJob struct {
+	Raw string                `json:"raw"`
+	Job *models.ConversionJob `json:"job,omitempty"`
+}
+
+// Redacted returns the entry without the job's password, for showing it.
+func (j QueuedJob) Redacted() QueuedJob {
+	redacted := QueuedJob{Raw: RedactPassword(j.Raw, nil)}
+	if j.Job != nil {
+		job := *j.Job
+		job.Password = ""
+		redacted.Job = &job
+	}
+	return redacted
+}
+
+// QueueAdmin implements the operator actions behind the admin API. Queues
+// are addressed by name: high, pending, low, processing, failed, delayed,
+// quarantine.
+type QueueAdmin struct {
+	client      *redis.Client
+	config      *config.Config
+	status      *StatusStore
+	dbUpdater   *StatusUpdater
+	jobs        *JobQueue
+	annotations *JobAnnotations
+	secrets     *JobSecrets
+}
+
+func NewQueueAdmin(client *redis.Client, cfg *config.Config, dbUpdater *StatusUpdater) *QueueAdmin {
+	return &QueueAdmin{
+		client:      client,
+		config:      cfg,
+		status:      NewStatusStore(client),
+		dbUpdater:   dbUpdater,
+		jobs:        NewJobQueue(client, cfg.QueueBackend, cfg.StreamGroup),
+		annotations: NewJobAnnotations(client, cfg.RedisPrefix, time.Duration(cfg.AnnotationTTL)*time.Second),
+		secrets:     NewJobSecrets(client, cfg.RedisPrefix, time.Duration(cfg.PasswordTTL)*time.Second),
+	}
+}
+
+// claimable reports whether the named queue is one workers claim from,
+// stored on the configured backend.
+func claimable(name string) bool {
+	switch name {
+	case "retry", "high", "pending", "low":
+		return true
+	}
+	return isJobKind(name)
+}
+
+// claimableQueues are the queues whose claimed jobs make up processing
+// under the stream backend.
+func (a *QueueAdmin) claimableQueues() []string {
+	queues := []string{a.config.RetryLaneQueue, a.config.HighPriorityQueue, a.config.PendingQueue, a.config.LowPriorityQueue}
+	for _, kind := range JobKinds() {
+		queues = append(queues, a.config.KindQueueFor(kind, a.config.Region))
+	}
+	return queues
+}
+
+// streamProcessing reports whether processing is the consumer group's
+// pending entries rather than a list.
+func (a *QueueAdmin) streamProcessing(name string) bool {
+	return name == "processing" && a.jobs.Streams()
+}
+
+func (a *QueueAdmin) queueKey(name string) (string, error) {
+	switch name {
+	case "retry":
+		return a.config.RetryLaneQueue, nil
+	case "high":
+		return a.config.HighPriorityQueue, nil
+	case "pending":
+		return a.config.PendingQueue, nil
+	case "low":
+		return a.config.LowPriorityQueue, nil
+	case "processing":
+		return a.config.ProcessingQueue, nil
+	case "failed":
+		return a.config.FailedQueue, nil
+	case "delayed":
+		return a.config.DelayedQueue, nil
+	case "quarantine":
+		return a.config.QuarantineQueue, nil
+	}
+	if isJobKind(name) {
+		return a.config.KindQueueFor(name, a.config.Region), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownQueue, name)
+}
+
+// QueueNames lists the queues in the order a job normally moves through them.
+// The kind queues, named after their kind, hold jobs handed on by workers
+// that don't take the kind.
+func QueueNames() []string {
+	names := []string{"retry", "high", "pending", "low"}
+	names = append(names, JobKinds()...)
+	return append(names, "delayed", "processing", "failed", "quarantine")
+}
+
+func (a *QueueAdmin) Length(ctx context.Context, name string) (int64, error) {
+	key, err := a.queueKey(name)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case name == "delayed":
+		return a.client.ZCard(ctx, key).Result()
+	case claimable(name):
+		return a.jobs.Length(ctx, key)
+	case a.streamProcessing(name):
+		return a.jobs.InFlightCount(ctx, a.claimableQueues())
+	}
+	return a.client.LLen(ctx, key).Result()
+}
+
+// List returns up to limit entries starting at offset. Lists are read from
+// the tail, so the first entry is the next job to be claimed; delayed
+// entries are ordered by retry time. Passwords are redacted.
+func (a *QueueAdmin) List(ctx context.Context, name string, offset int64, limit int64) ([]QueuedJob, error) {
+	key, err := a.queueKey(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []string
+	switch {
+	case name == "delayed":
+		raw, err = a.client.ZRange(ctx, key, offset, offset+limit-1).Result()
+	case claimable(name):
+		raw, err = a.jobs.Waiting(ctx, key, offset, limit)
+	case a.streamProcessing(name):
+		raw, err = a.jobs.InFlight(ctx, a.claimableQueues())
+		raw = page(raw, offset, limit)
+	default:
+		raw, err = a.client.LRange(ctx, key, -(offset + limit), -(offset + 1)).Result()
+		reverse(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue %s: %w", name, err)
+	}
+
+	jobs := decodeEntries(raw)
+	for i := range jobs {
+		jobs[i] = jobs[i].Redacted()
+	}
+	return jobs, nil
+}
+
+func decodeEntries(raw []string) []QueuedJob {
+	jobs := make([]QueuedJob, 0, len(raw))
+	for _, r := range raw {
+		entry := QueuedJob{Raw: r}
+		var job models.ConversionJob
+		if json.Unmarshal([]byte(r), &job) == nil {
+			entry.Job = &job
+		}
+		jobs = append(jobs, entry)
+	}
+	return jobs
+}
+
+func page(s []string, offset int64, limit int64) []string {
+	if offset >= int64(len(s)) {
+		return nil
+	}
+	s = s[offset:]
+	if limit < int64(len(s)) {
+		s = s[:limit]
+	}
+	return s
+}
+
+func reverse(s []string) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// Locate returns the name of the queue currently holding the conversion,
+// or "" if it is in none of them.
+func (a *QueueAdmin) Locate(ctx context.Context, conversionID int) (string, error) {
+	for _, name := range QueueNames() {
+		entries, err := a.entries(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range entries {
+			if entry.Job != nil && entry.Job.ConversionID == conversionID {
+				return name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Queued maps every conversion in any queue to the name of the queue
+// holding it.
+func (a *QueueAdmin) Queued(ctx context.Context) (map[int]string, error) {
+	queued := make(map[int]string)
+	for _, name := range QueueNames() {
+		entries, err := a.entries(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Job != nil {
+				queued[entry.Job.ConversionID] = name
+			}
+		}
+	}
+	return queued, nil
+}
+
+// entries reads every entry of the named queue.
+func (a *QueueAdmin) entries(ctx context.Context, name string) ([]QueuedJob, error) {
+	key, err := a.queueKey(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []string
+	switch {
+	case name == "delayed":
+		raw, err = a.client.ZRange(ctx, key, 0, -1).Result()
+	case claimable(name):
+		raw, err = a.jobs.Waiting(ctx, key, 0, 0)
+	case a.streamProcessing(name):
+		raw, err = a.jobs.InFlight(ctx, a.claimableQueues())
+	default:
+		raw, err = a.client.LRange(ctx, key, 0, -1).Result()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue %s: %w", name, err)
+	}
+	return decodeEntries(raw), nil
+}
+
+// Status returns the conversion:status:<id> hash, empty if there is none.
+func (a *QueueAdmin) Status(ctx context.Context, conversionID int) (map[string]string, error) {
+	return a.client.HGetAll(ctx, StatusKey(conversionID)).Result()
+}
+
+// Annotations returns the operator annotations on a conversion.
+func (a *QueueAdmin) Annotations(ctx context.Context, conversionID int) (map[string]string, error) {
+	return a.annotations.Get(ctx, conversionID)
+}
+
+// Annotate sets an operator annotation, honored the next time a worker
+// claims the conversion.
+func (a *QueueAdmin) Annotate(ctx context.Context, conversionID int, name string, value string) error {
+	return a.annotations.Set(ctx, conversionID, name, value)
+}
+
+// ClearAnnotations removes the named annotations, or all of them.
+func (a *QueueAdmin) ClearAnnotations(ctx context.Context, conversionID int, names ...string) error {
+	return a.annotations.Clear(ctx, conversionID, names...)
+}
+
+// Requeue moves a failed conversion back to its pending queue with its
+// retry count reset. A user-initiated requeue goes to the retry lane.
+func (a *QueueAdmin) Requeue(ctx context.Context, conversionID int, userInitiated bool) (*models.ConversionJob, error) {
+	raw, err := a.client.LRange(ctx, a.config.FailedQueue, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read failed queue: %w", err)
+	}
+
+	for _, r := range raw {
+		var job models.ConversionJob
+		if json.Unmarshal([]byte(r), &job) != nil || job.ConversionID != conversionID {
+			continue
+		}
+
+		if err := a.requeueEntry(ctx, r, &job, userInitiated); err != nil {
+			return nil, err
+		}
+		return &job, nil
+	}
+
+	return nil, ErrJobNotFound
+}
+
+// requeueEntry moves one raw failed queue entry, parsed as job, back to its
+// pending queue.
+func (a *QueueAdmin) requeueEntry(ctx context.Context, raw string, job *models.ConversionJob, userInitiated bool) error {
+	removed, err := a.client.LRem(ctx, a.config.FailedQueue, 1, raw).Result()
+	if err != nil {
+		return fmt.Errorf("failed to remove job from failed queue: %w", err)
+	}
+	if removed == 0 {
+		// Someone else requeued or purged it in the meantime
+		return ErrJobNotFound
+	}
+
+	// Move the status back to pending before the job is claimable, so the
+	// worker's processing transition is legal
+	a.dbUpdater.UpdateStatus(job.ConversionID, models.StatusPending, "", nil)
+	if err := a.status.Set(ctx, job.ConversionID, models.StatusPending, nil); err != nil {
+		logging.From(ctx).Error("Failed to reset Redis status", "component", "admin", "conversion_id", job.ConversionID, "error", err)
+	}
+
+	job.RetryCount = 0
+	job.UserInitiated = userInitiated
+	jobJSON, _ := json.Marshal(job)
+	// Re-sign only what a trusted producer signed in the first place
+	if secrets := a.config.JobSigningSecrets; len(secrets) > 0 && VerifyJob(secrets, raw) == nil {
+		jobJSON = SignJob(secrets[0], jobJSON)
+	}
+	queue := a.config.PendingQueueFor(string(job.Priority.Normalize()), job.Region)
+	if job.UserInitiated {
+		queue = a.config.RetryLaneQueueFor(job.Region)
+	}
+	if err := a.jobs.Push(ctx, queue, jobJSON); err != nil {
+		return fmt.Errorf("failed to push job to %s: %w", queue, err)
+	}
+	return nil
+}
+
+// Trash queues a job that moves the conversion's outputs to the trash.
+func (a *QueueAdmin) Trash(ctx context.Context, conversionID int) (*models.ConversionJob, error) {
+	return a.pushOutputJob(ctx, conversionID, models.JobTypeTrash)
+}
+
+// Restore queues a job that moves the conversion's outputs back from the
+// trash.
+func (a *QueueAdmin) Restore(ctx context.Context, conversionID int) (*models.ConversionJob, error) {
+	return a.pushOutputJob(ctx, conversionID, models.JobTypeRestore)
+}
+
+func (a *QueueAdmin) pushOutputJob(ctx context.Context, conversionID int, jobType models.JobType) (*models.ConversionJob, error) {
+	job := models.ConversionJob{
+		ConversionID: conversionID,
+		Type:         jobType,
+		MaxRetries:   a.config.MaxRetries,
+		CreatedAt:    time.Now(),
+		Region:       a.config.Region,
+	}
+	jobJSON, _ := json.Marshal(job)
+	if secrets := a.config.JobSigningSecrets; len(secrets) > 0 {
+		jobJSON = SignJob(secrets[0], jobJSON)
+	}
+	queue := a.config.PendingQueueFor("", job.Region)
+	if err := a.jobs.Push(ctx, queue, jobJSON); err != nil {
+		return nil, fmt.Errorf("failed to push job to %s: %w", queue, err)
+	}
+	return &job, nil
+}
+
+// Purge deletes every entry of the queue and returns how many were removed.
+func (a *QueueAdmin) Purge(ctx context.Context, name string) (int64, error) {
+	key, err := a.queueKey(name)
+	if err != nil {
+		return 0, err
+	}
+	if name == "processing" {
+		return 0, ErrQueueProtected
+	}
+	if claimable(name) {
+		n, err := a.jobs.Purge(ctx, key)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge queue %s: %w", name, err)
+		}
+		return n, nil
+	}
+
+	pipe := a.client.TxPipeline()
+	var length *redis.IntCmd
+	if name == "delayed" {
+		length = pipe.ZCard(ctx, key)
+	} else {
+		length = pipe.LLen(ctx, key)
+	}
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to purge queue %s: %w", name, err)
+	}
+	return length.Val(), nil
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"converter/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrQueuesNotEmpty is returned when a snapshot would be restored on top of
+// queued jobs, which would duplicate them.
+var ErrQueuesNotEmpty = errors.New("queues already hold jobs")
+
+// QueueSnapshot is a copy of the conversion queues and status hashes, taken
+// to rebuild the backlog in a fresh Redis after the old one is lost.
+type QueueSnapshot struct {
+	TakenAt time.Time `json:"takenAt"`
+	Backend string    `json:"backend"`
+	// Queues holds the list and stream queues by name, the next entry to be
+	// claimed first for retry, high, pending, low and the kind queues, and
+	// head first for processing, failed and quarantine.
+	Queues map[string][]string `json:"queues"`
+	// Delayed holds the delayed retries with the time they are due.
+	Delayed []DelayedSnapshotEntry `json:"delayed"`
+	// Statuses holds the conversion:status:<id> hashes by key.
+	Statuses map[string]map[string]string `json:"statuses"`
+}
+
+// DelayedSnapshotEntry is a delayed retry and its due time as a Unix
+// timestamp, the sorted set's score.
+type DelayedSnapshotEntry struct {
+	Payload string  `json:"payload"`
+	DueAt   float64 `json:"dueAt"`
+}
+
+// Jobs counts the queued jobs in the snapshot.
+func (s *QueueSnapshot) Jobs() int {
+	n := len(s.Delayed)
+	for _, entries := range s.Queues {
+		n += len(entries)
+	}
+	return n
+}
+
+// WriteSnapshot encodes the snapshot as gzipped JSON.
+func WriteSnapshot(w io.Writer, snapshot *QueueSnapshot) error {
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(snapshot); err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return nil
+}
+
+// ReadSnapshot decodes a snapshot written by WriteSnapshot.
+func ReadSnapshot(r io.Reader) (*QueueSnapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	defer gz.Close()
+
+	var snapshot QueueSnapshot
+	if err := json.NewDecoder(gz).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// Snapshot copies every queue and status hash. Queues are read one after
+// the other while workers keep running, so a job moving between two reads
+// may be in the snapshot twice or not at all; the status hashes tell which.
+func (a *QueueAdmin) Snapshot(ctx context.Context) (*QueueSnapshot, error) {
+	snapshot := &QueueSnapshot{
+		TakenAt:  time.Now().UTC(),
+		Backend:  a.jobs.backend,
+		Queues:   make(map[string][]string),
+		Statuses: make(map[string]map[string]string),
+	}
+
+	for _, name := range QueueNames() {
+		if name == "delayed" {
+			continue
+		}
+		entries, err := a.entries(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		raw := make([]string, len(entries))
+		for i, entry := range entries {
+			if raw[i], err = a.secrets.Seal(ctx, entry.Raw, a.config.JobSigningSecrets); err != nil {
+				return nil, err
+			}
+		}
+		snapshot.Queues[name] = raw
+	}
+
+	delayed, err := a.client.ZRangeWithScores(ctx, a.config.DelayedQueue, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue delayed: %w", err)
+	}
+	for _, z := range delayed {
+		payload, _ := z.Member.(string)
+		if payload, err = a.secrets.Seal(ctx, payload, a.config.JobSigningSecrets); err != nil {
+			return nil, err
+		}
+		snapshot.Delayed = append(snapshot.Delayed, DelayedSnapshotEntry{Payload: payload, DueAt: z.Score})
+	}
+
+	iter := a.client.Scan(ctx, 0, "conversion:status:*", 1000).Iterator()
+	for iter.Next(ctx) {
+		fields, err := a.client.HGetAll(ctx, iter.Val()).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", iter.Val(), err)
+		}
+		if len(fields) > 0 {
+			snapshot.Statuses[iter.Val()] = fields
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan status keys: %w", err)
+	}
+	return snapshot, nil
+}
+
+// RestoreSnapshot puts the snapshot's jobs back and recreates the status
+// hashes that are missing. Unless force is set, it refuses to add jobs to
+// queues that aren't empty.
+//
+// Jobs that were in flight go back where they can be picked up again: with
+// the list backend into processing, where the recovery loop retries them
+// like a crashed worker's, and with streams to the queue a retry would use,
+// since a consumer group's pending entries can't be recreated.
+func (a *QueueAdmin) RestoreSnapshot(ctx context.Context, snapshot *QueueSnapshot, force bool) error {
+	if snapshot.Backend != a.jobs.backend {
+		return fmt.Errorf("snapshot was taken with the %s backend, not %s", snapshot.Backend, a.jobs.backend)
+	}
+	if !force {
+		for _, name := range QueueNames() {
+			n, err := a.Length(ctx, name)
+			if err != nil {
+				return fmt.Errorf("failed to read queue %s: %w", name, err)
+			}
+			if n > 0 {
+				return fmt.Errorf("%w: %s has %d", ErrQueuesNotEmpty, name, n)
+			}
+		}
+	}
+
+	for _, name := range QueueNames() {
+		entries := snapshot.Queues[name]
+		switch {
+		case name == "delayed":
+			continue
+		case claimable(name):
+			key, _ := a.queueKey(name)
+			for _, raw := range entries {
+				if err := a.jobs.Push(ctx, key, raw); err != nil {
+					return fmt.Errorf("failed to restore queue %s: %w", name, err)
+				}
+			}
+		case a.streamProcessing(name):
+			for _, raw := range entries {
+				if err := a.jobs.Push(ctx, a.retryTarget(raw), raw); err != nil {
+					return fmt.Errorf("failed to restore queue %s: %w", name, err)
+				}
+			}
+		case len(entries) > 0:
+			key, _ := a.queueKey(name)
+			values := make([]interface{}, len(entries))
+			for i, raw := range entries {
+				values[i] = raw
+			}
+			if err := a.client.RPush(ctx, key, values...).Err(); err != nil {
+				return fmt.Errorf("failed to restore queue %s: %w", name, err)
+			}
+		}
+	}
+
+	for _, entry := range snapshot.Delayed {
+		if err := a.client.ZAdd(ctx, a.config.DelayedQueue, redis.Z{Score: entry.DueAt, Member: entry.Payload}).Err(); err != nil {
+			return fmt.Errorf("failed to restore queue delayed: %w", err)
+		}
+	}
+
+	for key, fields := range snapshot.Statuses {
+		// A worker may have written a newer status since Redis came back
+		exists, err := a.client.Exists(ctx, key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", key, err)
+		}
+		if exists > 0 {
+			continue
+		}
+		if err := a.client.HSet(ctx, key, fields).Err(); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// retryTarget is the queue a job goes back to when it is retried.
+func (a *QueueAdmin) retryTarget(raw string) string {
+	var job models.ConversionJob
+	json.Unmarshal([]byte(raw), &job)
+	if job.UserInitiated {
+		return a.config.RetryLaneQueueFor(job.Region)
+	}
+	return a.config.PendingQueueFor(string(job.Priority.Normalize()), job.Region)
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueueSnapshot_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	snapshot := &QueueSnapshot{
+		TakenAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Backend: QueueBackendList,
+		Queues: map[string][]string{
+			"pending":    {`{"conversionId":1}`, `{"conversionId":2}`},
+			"processing": {`{"claimToken":"abc","conversionId":3}`},
+			"failed":     {},
+		},
+		Delayed:  []DelayedSnapshotEntry{{Payload: `{"conversionId":4}`, DueAt: 1714564800}},
+		Statuses: map[string]map[string]string{"conversion:status:1": {"status": "pending"}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, snapshot) {
+		t.Errorf("ReadSnapshot = %+v, want %+v", got, snapshot)
+	}
+	if got.Jobs() != 4 {
+		t.Errorf("Jobs() = %d, want 4", got.Jobs())
+	}
+
+	if _, err := ReadSnapshot(bytes.NewReader([]byte(`{"queues":{}}`))); err == nil {
+		t.Error("ReadSnapshot accepted a snapshot that isn't gzipped")
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket limiter whose refill rate adapts to
+// backpressure: Throttled halves the rate (down to minRate) and each
+// Succeeded call recovers it additively towards the configured maximum.
+type TokenBucket struct {
+	mu       sync.Mutex
+	maxRate  float64
+	minRate  float64
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+	now      func() time.Time
+}
+
+func NewTokenBucket(ratePerSecond float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		maxRate:  ratePerSecond,
+		minRate:  ratePerSecond / 16,
+		rate:     ratePerSecond,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		lastFill: time.Now(),
+		now:      time.Now,
+	}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	for {
+		delay := b.reserve()
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token if one is available, otherwise returns how long to
+// wait before one will be.
+func (b *TokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens += now.Sub(b.lastFill).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastFill = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *TokenBucket) Throttled() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rate /= 2
+	if b.rate < b.minRate {
+		b.rate = b.minRate
+	}
+	// Drain the bucket so the backoff takes effect immediately
+	b.tokens = 0
+}
+
+func (b *TokenBucket) Succeeded() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate < b.maxRate {
+		b.rate += b.maxRate / 100
+		if b.rate > b.maxRate {
+			b.rate = b.maxRate
+		}
+	}
+}
+
+func (b *TokenBucket) Rate() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rate
+}
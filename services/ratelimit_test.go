@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket_AdaptsToThrottling(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	bucket := NewTokenBucket(10, 2)
+	bucket.now = func() time.Time { return now }
+	bucket.lastFill = now
+
+	if bucket.reserve() != 0 || bucket.reserve() != 0 {
+		t.Fatal("expected burst tokens to be available immediately")
+	}
+	if wait := bucket.reserve(); wait != 100*time.Millisecond {
+		t.Fatalf("expected 100ms wait at 10 req/s, got %v", wait)
+	}
+
+	bucket.Throttled()
+	if rate := bucket.Rate(); rate != 5 {
+		t.Fatalf("expected rate to halve to 5, got %v", rate)
+	}
+	if wait := bucket.reserve(); wait != 200*time.Millisecond {
+		t.Fatalf("expected 200ms wait after throttling, got %v", wait)
+	}
+
+	for i := 0; i < 100; i++ {
+		bucket.Succeeded()
+	}
+	if rate := bucket.Rate(); rate != 10 {
+		t.Fatalf("expected rate to recover to 10, got %v", rate)
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisMemory is the part of INFO memory the memory guard watches.
+type RedisMemory struct {
+	Used   int64
+	Max    int64
+	Policy string
+}
+
+// ReadRedisMemory reads INFO memory, which managed Redis providers allow
+// even where CONFIG GET is disabled.
+func ReadRedisMemory(ctx context.Context, client *redis.Client) (*RedisMemory, error) {
+	info, err := client.Info(ctx, "memory").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Redis memory info: %w", err)
+	}
+	return parseRedisMemory(info), nil
+}
+
+func parseRedisMemory(info string) *RedisMemory {
+	m := &RedisMemory{}
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "used_memory":
+			m.Used, _ = strconv.ParseInt(value, 10, 64)
+		case "maxmemory":
+			m.Max, _ = strconv.ParseInt(value, 10, 64)
+		case "maxmemory_policy":
+			m.Policy = value
+		}
+	}
+	return m
+}
+
+// EvictionSafe reports whether Redis keeps every key without a TTL when it
+// runs out of memory. Queue keys have no TTL, so only noeviction and the
+// volatile-* policies can never drop queued jobs; writes fail instead, or
+// leases and caches are evicted.
+func (m *RedisMemory) EvictionSafe() bool {
+	return m.Max == 0 || m.Policy == "noeviction" || strings.HasPrefix(m.Policy, "volatile-")
+}
+
+// UsedPercent is used memory as a percentage of maxmemory, or 0 without a
+// limit.
+func (m *RedisMemory) UsedPercent() int64 {
+	if m.Max <= 0 {
+		return 0
+	}
+	return m.Used * 100 / m.Max
+}
@@ -0,0 +1,40 @@
+package services
+
+import "testing"
+
+func TestParseRedisMemory(t *testing.T) {
+	t.Parallel()
+
+	info := "# Memory\r\nused_memory:943718400\r\nused_memory_human:900.00M\r\nmaxmemory:1073741824\r\nmaxmemory_human:1.00G\r\nmaxmemory_policy:allkeys-lru\r\n"
+	m := parseRedisMemory(info)
+	if m.Used != 943718400 || m.Max != 1073741824 || m.Policy != "allkeys-lru" {
+		t.Fatalf("parseRedisMemory = %+v", m)
+	}
+	if m.EvictionSafe() {
+		t.Error("allkeys-lru with maxmemory set reported as eviction safe")
+	}
+	if got := m.UsedPercent(); got != 87 {
+		t.Errorf("UsedPercent = %d, want 87", got)
+	}
+}
+
+func TestRedisMemory_EvictionSafe(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		memory RedisMemory
+		safe   bool
+	}{
+		{RedisMemory{Max: 1 << 30, Policy: "noeviction"}, true},
+		{RedisMemory{Max: 1 << 30, Policy: "volatile-lru"}, true},
+		{RedisMemory{Max: 1 << 30, Policy: "allkeys-lfu"}, false},
+		{RedisMemory{Max: 1 << 30, Policy: "allkeys-random"}, false},
+		// Without maxmemory nothing is ever evicted
+		{RedisMemory{Max: 0, Policy: "allkeys-lru"}, true},
+	}
+	for _, c := range cases {
+		if got := c.memory.EvictionSafe(); got != c.safe {
+			t.Errorf("EvictionSafe(%+v) = %v, want %v", c.memory, got, c.safe)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"converter/logging"
+	"converter/models"
+)
+
+// RequeueBatch records a bulk requeue, so its outcome can be reported once
+// the jobs have run again.
+type RequeueBatch struct {
+	ID          string    `json:"id"`
+	StartedAt   time.Time `json:"startedAt"`
+	Match       string    `json:"match,omitempty"`
+	Conversions []int     `json:"conversions"`
+}
+
+// RequeueReport is the outcome of a bulk requeue.
+type RequeueReport struct {
+	Batch           string    `json:"batch"`
+	StartedAt       time.Time `json:"startedAt"`
+	FinishedAt      time.Time `json:"finishedAt"`
+	DurationSeconds int64     `json:"durationSeconds"`
+	Requeued        int       `json:"requeued"`
+	// Outcomes counts the requeued conversions by their current status;
+	// "unknown" when the status hash is gone.
+	Outcomes map[string]int `json:"outcomes"`
+	// StillFailing counts the conversions that failed again by error class,
+	// most frequent first.
+	StillFailing []ErrorClassCount `json:"stillFailing"`
+	// Settled is false while some conversions are still pending or
+	// processing.
+	Settled bool `json:"settled"`
+}
+
+// ErrorClassCount is how many conversions failed again with an error class.
+type ErrorClassCount struct {
+	Class string `json:"class"`
+	Count int    `json:"count"`
+}
+
+// ErrorClass groups failure messages by what failed: the text before the
+// first colon, such as "S3 download failed" or "office conversion failed",
+// without the details that make every message unique.
+func ErrorClass(message string) string {
+	class, _, _ := strings.Cut(message, ":")
+	class = strings.TrimSpace(class)
+	if class == "" {
+		return "unknown"
+	}
+	return class
+}
+
+// RequeueFailed moves the jobs the filter selects from the failed queue
+// back to their pending queues, like Requeue, and returns their conversion
+// IDs.
+func (a *QueueAdmin) RequeueFailed(ctx context.Context, filter FailedFilter, userInitiated bool) ([]int, error) {
+	entries, err := a.FailedJobs(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var requeued []int
+	for _, entry := range entries {
+		if entry.Job == nil {
+			continue
+		}
+		err := a.requeueEntry(ctx, entry.Raw, entry.Job, userInitiated)
+		if errors.Is(err, ErrJobNotFound) {
+			continue
+		}
+		if err != nil {
+			return requeued, err
+		}
+		logging.From(ctx).Info("Requeued failed conversion", "component", "admin", "conversion_id", entry.Job.ConversionID)
+		requeued = append(requeued, entry.Job.ConversionID)
+	}
+	return requeued, nil
+}
+
+// RequeueReport reads the current status of every conversion in the batch.
+func (a *QueueAdmin) RequeueReport(ctx context.Context, batch *RequeueBatch) (*RequeueReport, error) {
+	statuses := make([]map[string]string, 0, len(batch.Conversions))
+	for _, id := range batch.Conversions {
+		status, err := a.Status(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read status of conversion %d: %w", id, err)
+		}
+		statuses = append(statuses, status)
+	}
+	return BuildRequeueReport(batch, statuses, time.Now().UTC()), nil
+}
+
+// BuildRequeueReport summarizes the status hashes of a batch's conversions.
+func BuildRequeueReport(batch *RequeueBatch, statuses []map[string]string, now time.Time) *RequeueReport {
+	report := &RequeueReport{
+		Batch:           batch.ID,
+		StartedAt:       batch.StartedAt,
+		FinishedAt:      now,
+		DurationSeconds: int64(now.Sub(batch.StartedAt) / time.Second),
+		Requeued:        len(batch.Conversions),
+		Outcomes:        make(map[string]int),
+		StillFailing:    []ErrorClassCount{},
+		Settled:         true,
+	}
+
+	failing := make(map[string]int)
+	for _, status := range statuses {
+		outcome := status["status"]
+		if outcome == "" {
+			outcome = "unknown"
+		}
+		report.Outcomes[outcome]++
+		switch models.ConversionStatus(outcome) {
+		case models.StatusPending, models.StatusProcessing:
+			report.Settled = false
+		case models.StatusFailed:
+			failing[ErrorClass(status["error"])]++
+		}
+	}
+
+	for class, count := range failing {
+		report.StillFailing = append(report.StillFailing, ErrorClassCount{Class: class, Count: count})
+	}
+	sort.Slice(report.StillFailing, func(i, j int) bool {
+		if report.StillFailing[i].Count != report.StillFailing[j].Count {
+			return report.StillFailing[i].Count > report.StillFailing[j].Count
+		}
+		return report.StillFailing[i].Class < report.StillFailing[j].Class
+	})
+	return report
+}
+
+// Summary is the report as a few lines of text for the alert channel.
+func (r *RequeueReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Requeue %s: %d conversions requeued, %s", r.Batch, r.Requeued, time.Duration(r.DurationSeconds)*time.Second)
+	if !r.Settled {
+		b.WriteString(" (not settled yet)")
+	}
+
+	outcomes := make([]string, 0, len(r.Outcomes))
+	for outcome := range r.Outcomes {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Strings(outcomes)
+	for i, outcome := range outcomes {
+		if i == 0 {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s: %d", outcome, r.Outcomes[outcome])
+	}
+
+	for _, failing := range r.StillFailing {
+		fmt.Fprintf(&b, "\nstill failing: %s (%d)", failing.Class, failing.Count)
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorClass(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"S3 download failed: NoSuchKey: gone": "S3 download failed",
+		"Deadline passed":                     "Deadline passed",
+		"":                                    "unknown",
+		": no class":                          "unknown",
+	}
+	for message, want := range cases {
+		if got := ErrorClass(message); got != want {
+			t.Errorf("ErrorClass(%q) = %q, want %q", message, got, want)
+		}
+	}
+}
+
+func TestBuildRequeueReport(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	batch := &RequeueBatch{ID: "20240301T120000Z", StartedAt: started, Conversions: []int{1, 2, 3, 4, 5, 6}}
+	statuses := []map[string]string{
+		{"status": "completed"},
+		{"status": "completed"},
+		{"status": "failed", "error": "office conversion failed: timeout"},
+		{"status": "failed", "error": "S3 download failed: NoSuchKey"},
+		{"status": "failed", "error": "S3 download failed: AccessDenied"},
+		{},
+	}
+	report := BuildRequeueReport(batch, statuses, started.Add(90*time.Second))
+
+	if report.Requeued != 6 || report.DurationSeconds != 90 || !report.Settled {
+		t.Errorf("report = %+v, want 6 requeued, 90s and settled", report)
+	}
+	if report.Outcomes["completed"] != 2 || report.Outcomes["failed"] != 3 || report.Outcomes["unknown"] != 1 {
+		t.Errorf("Outcomes = %v", report.Outcomes)
+	}
+	want := []ErrorClassCount{{"S3 download failed", 2}, {"office conversion failed", 1}}
+	if len(report.StillFailing) != len(want) {
+		t.Fatalf("StillFailing = %v, want %v", report.StillFailing, want)
+	}
+	for i := range want {
+		if report.StillFailing[i] != want[i] {
+			t.Errorf("StillFailing[%d] = %v, want %v", i, report.StillFailing[i], want[i])
+		}
+	}
+
+	summary := report.Summary()
+	for _, part := range []string{"6 conversions requeued, 1m30s", "completed: 2, failed: 3, unknown: 1", "still failing: S3 download failed (2)"} {
+		if !strings.Contains(summary, part) {
+			t.Errorf("Summary() = %q, want it to contain %q", summary, part)
+		}
+	}
+}
+
+func TestBuildRequeueReport_Unsettled(t *testing.T) {
+	t.Parallel()
+
+	batch := &RequeueBatch{ID: "b", StartedAt: time.Now(), Conversions: []int{1, 2}}
+	report := BuildRequeueReport(batch, []map[string]string{{"status": "completed"}, {"status": "processing"}}, time.Now())
+	if report.Settled {
+		t.Error("report with a processing conversion is settled")
+	}
+	if !strings.Contains(report.Summary(), "not settled yet") {
+		t.Errorf("Summary() = %q, want it to say the batch isn't settled", report.Summary())
+	}
+}
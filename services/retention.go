@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Object Lock retention modes.
+const (
+	RetentionGovernance = "GOVERNANCE"
+	RetentionCompliance = "COMPLIANCE"
+)
+
+// Retention is the S3 Object Lock setting of a retention class: a mode with
+// a retention period in days, a legal hold, or both.
+type Retention struct {
+	Class     string
+	Mode      string
+	Days      int
+	LegalHold bool
+	// RetainUntil is set when the retention is applied to a job, so every
+	// output of the job is retained until the same time.
+	RetainUntil time.Time
+}
+
+// ParseRetentionClasses reads CONVERSION_RETENTION_CLASSES, which maps
+// class names to "<mode>:<days>", "hold" or "<mode>:<days>:hold", e.g.
+// "7y-legal-hold=COMPLIANCE:2557:hold,90d=GOVERNANCE:90".
+func ParseRetentionClasses(spec map[string]string) (map[string]Retention, error) {
+	classes := make(map[string]Retention, len(spec))
+	for name, value := range spec {
+		retention := Retention{Class: name}
+		for _, part := range strings.Split(value, ":") {
+			switch part = strings.TrimSpace(part); {
+			case strings.EqualFold(part, "hold"):
+				retention.LegalHold = true
+			case strings.EqualFold(part, RetentionGovernance), strings.EqualFold(part, RetentionCompliance):
+				retention.Mode = strings.ToUpper(part)
+			default:
+				days, err := strconv.Atoi(part)
+				if err != nil || days <= 0 {
+					return nil, fmt.Errorf("retention class %s: %q is not a mode, a number of days or hold", name, part)
+				}
+				retention.Days = days
+			}
+		}
+		if (retention.Mode == "") != (retention.Days == 0) {
+			return nil, fmt.Errorf("retention class %s needs both a mode and a number of days", name)
+		}
+		if retention.Mode == "" && !retention.LegalHold {
+			return nil, fmt.Errorf("retention class %s retains nothing", name)
+		}
+		classes[name] = retention
+	}
+	return classes, nil
+}
+
+// Applied is the retention for a job started at now.
+func (r Retention) Applied(now time.Time) Retention {
+	if r.Days > 0 {
+		r.RetainUntil = now.UTC().AddDate(0, 0, r.Days)
+	}
+	return r
+}
+
+// Metadata describes the retention for the conversion metadata.
+func (r Retention) Metadata() map[string]interface{} {
+	metadata := map[string]interface{}{"class": r.Class}
+	if r.Mode != "" {
+		metadata["mode"] = r.Mode
+		metadata["retain_until"] = r.RetainUntil.Format(time.RFC3339)
+	}
+	if r.LegalHold {
+		metadata["legal_hold"] = true
+	}
+	return metadata
+}
+
+type retentionKey struct{}
+
+// WithRetention makes S3 uploads made with the returned context write their
+// objects with the retention.
+func WithRetention(ctx context.Context, retention Retention) context.Context {
+	return context.WithValue(ctx, retentionKey{}, retention)
+}
+
+// RetentionFrom returns the retention set with WithRetention, if any.
+func RetentionFrom(ctx context.Context) (Retention, bool) {
+	retention, ok := ctx.Value(retentionKey{}).(Retention)
+	return retention, ok
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRetentionClasses(t *testing.T) {
+	t.Parallel()
+
+	classes, err := ParseRetentionClasses(map[string]string{
+		"7y-legal-hold": "compliance:2557:hold",
+		"90d":           "GOVERNANCE:90",
+		"hold":          "hold",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Retention{
+		"7y-legal-hold": {Class: "7y-legal-hold", Mode: RetentionCompliance, Days: 2557, LegalHold: true},
+		"90d":           {Class: "90d", Mode: RetentionGovernance, Days: 90},
+		"hold":          {Class: "hold", LegalHold: true},
+	}
+	for name, retention := range want {
+		if classes[name] != retention {
+			t.Errorf("class %s = %+v, want %+v", name, classes[name], retention)
+		}
+	}
+
+	for _, invalid := range []string{"GOVERNANCE", "90", "GOVERNANCE:0", "forever:90", ""} {
+		if _, err := ParseRetentionClasses(map[string]string{"bad": invalid}); err == nil {
+			t.Errorf("ParseRetentionClasses(bad=%q) succeeded", invalid)
+		}
+	}
+}
+
+func TestRetentionApplied(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 2, 29, 10, 0, 0, 0, time.UTC)
+	retention := Retention{Class: "1y", Mode: RetentionCompliance, Days: 365}.Applied(now)
+	if want := time.Date(2025, 2, 28, 10, 0, 0, 0, time.UTC); !retention.RetainUntil.Equal(want) {
+		t.Errorf("RetainUntil = %s, want %s", retention.RetainUntil, want)
+	}
+	metadata := retention.Metadata()
+	if metadata["mode"] != RetentionCompliance || metadata["retain_until"] != "2025-02-28T10:00:00Z" || metadata["legal_hold"] != nil {
+		t.Errorf("Metadata() = %v", metadata)
+	}
+
+	if hold := (Retention{Class: "hold", LegalHold: true}).Applied(now); !hold.RetainUntil.IsZero() {
+		t.Errorf("legal hold alone retains until %s", hold.RetainUntil)
+	}
+
+	ctx := WithRetention(context.Background(), retention)
+	if got, ok := RetentionFrom(ctx); !ok || got != retention {
+		t.Errorf("RetentionFrom = %+v, %v", got, ok)
+	}
+	if _, ok := RetentionFrom(context.Background()); ok {
+		t.Error("RetentionFrom found a retention on a bare context")
+	}
+}
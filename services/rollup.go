@@ -0,0 +1,109 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rollup counts the conversions of one extension that finished within an
+// hour (UTC). DurationMs sums the durations of the completed ones, so the
+// average is DurationMs / Completed.
+type Rollup struct {
+	Hour       time.Time
+	Extension  string
+	Completed  int
+	Failed     int
+	DurationMs int64
+}
+
+type rollupKey struct {
+	hour      time.Time
+	extension string
+}
+
+// Rollups accumulates finished conversions in memory until they are added
+// to conversion_rollups. Instances add their own counts to the same rows,
+// so the table holds the totals of every instance.
+type Rollups struct {
+	mu      sync.Mutex
+	pending map[rollupKey]*Rollup
+}
+
+func NewRollups() *Rollups {
+	return &Rollups{pending: make(map[rollupKey]*Rollup)}
+}
+
+// RollupExtension is the extension a conversion is rolled up under:
+// "merge" for merge jobs, "unknown" when it has none.
+func RollupExtension(extension string, merge bool) string {
+	if merge {
+		return "merge"
+	}
+	extension = strings.ToLower(strings.TrimPrefix(extension, "."))
+	if extension == "" {
+		return "unknown"
+	}
+	return extension
+}
+
+// Record counts a conversion that finished at the given time. Only
+// completed conversions add their duration.
+func (r *Rollups) Record(at time.Time, extension string, completed bool, duration time.Duration) {
+	rollup := Rollup{Hour: at.UTC().Truncate(time.Hour), Extension: extension}
+	if completed {
+		rollup.Completed = 1
+		rollup.DurationMs = duration.Milliseconds()
+	} else {
+		rollup.Failed = 1
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(rollup)
+}
+
+// Drain returns the accumulated rollups, oldest hour first, and starts
+// over.
+func (r *Rollups) Drain() []Rollup {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[rollupKey]*Rollup)
+	r.mu.Unlock()
+
+	drained := make([]Rollup, 0, len(pending))
+	for _, rollup := range pending {
+		drained = append(drained, *rollup)
+	}
+	sort.Slice(drained, func(i, j int) bool {
+		if !drained[i].Hour.Equal(drained[j].Hour) {
+			return drained[i].Hour.Before(drained[j].Hour)
+		}
+		return drained[i].Extension < drained[j].Extension
+	})
+	return drained
+}
+
+// Restore puts drained rollups back, after they couldn't be written, so
+// the next flush writes them along with the new counts.
+func (r *Rollups) Restore(rollups []Rollup) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rollup := range rollups {
+		r.add(rollup)
+	}
+}
+
+// add must be called with mu held.
+func (r *Rollups) add(rollup Rollup) {
+	key := rollupKey{hour: rollup.Hour, extension: rollup.Extension}
+	existing, ok := r.pending[key]
+	if !ok {
+		r.pending[key] = &rollup
+		return
+	}
+	existing.Completed += rollup.Completed
+	existing.Failed += rollup.Failed
+	existing.DurationMs += rollup.DurationMs
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRollups(t *testing.T) {
+	t.Parallel()
+
+	rollups := NewRollups()
+	nine := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	rollups.Record(nine.Add(10*time.Minute), "docx", true, 2*time.Second)
+	rollups.Record(nine.Add(50*time.Minute), "docx", true, 4*time.Second)
+	rollups.Record(nine.Add(55*time.Minute), "docx", false, 0)
+	rollups.Record(nine.Add(70*time.Minute), "docx", true, time.Second)
+	rollups.Record(nine.Add(5*time.Minute), "pdf", false, 0)
+
+	drained := rollups.Drain()
+	want := []Rollup{
+		{Hour: nine, Extension: "docx", Completed: 2, Failed: 1, DurationMs: 6000},
+		{Hour: nine, Extension: "pdf", Failed: 1},
+		{Hour: nine.Add(time.Hour), Extension: "docx", Completed: 1, DurationMs: 1000},
+	}
+	if len(drained) != len(want) {
+		t.Fatalf("got %d rollups, want %d: %+v", len(drained), len(want), drained)
+	}
+	for i := range want {
+		if !drained[i].Hour.Equal(want[i].Hour) || drained[i].Extension != want[i].Extension ||
+			drained[i].Completed != want[i].Completed || drained[i].Failed != want[i].Failed || drained[i].DurationMs != want[i].DurationMs {
+			t.Errorf("rollup %d = %+v, want %+v", i, drained[i], want[i])
+		}
+	}
+	if len(rollups.Drain()) != 0 {
+		t.Fatal("drain must start over")
+	}
+
+	// A failed write is added to the counts recorded since
+	rollups.Record(nine.Add(20*time.Minute), "pdf", true, 3*time.Second)
+	rollups.Restore(drained)
+	for _, rollup := range rollups.Drain() {
+		if rollup.Extension == "pdf" && (rollup.Completed != 1 || rollup.Failed != 1 || rollup.DurationMs != 3000) {
+			t.Errorf("restored pdf rollup = %+v", rollup)
+		}
+	}
+}
+
+func TestRollupExtension(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		extension string
+		merge     bool
+		want      string
+	}{
+		{"DOCX", false, "docx"},
+		{".pdf", false, "pdf"},
+		{"", false, "unknown"},
+		{"pdf", true, "merge"},
+	}
+	for _, c := range cases {
+		if got := RollupExtension(c.extension, c.merge); got != c.want {
+			t.Errorf("RollupExtension(%q, %v) = %q, want %q", c.extension, c.merge, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Route is the conversion strategy for an input extension.
+type Route string
+
+const (
+	// RouteLibreOffice converts office documents with LibreOffice, locally
+	// with soffice or through Gotenberg.
+	RouteLibreOffice Route = "libreoffice"
+	// RouteChromium renders HTML pages. Pages without assets or print
+	// templates are converted by LibreOffice like any other document.
+	RouteChromium Route = "chromium"
+	// RouteMarkdown renders Markdown to HTML and prints it with Chromium.
+	RouteMarkdown Route = "markdown"
+	// RouteEmail renders the message with Chromium and converts its
+	// attachments by their own routes.
+	RouteEmail Route = "email"
+	// RouteImage assembles images into a PDF with ImageMagick before the
+	// PDF/A pass.
+	RouteImage Route = "image"
+	// RoutePassThrough normalizes PDFs with Gotenberg's PDF engines, or
+	// keeps them as they are when they already conform.
+	RoutePassThrough Route = "passthrough"
+	// RouteUnsupported is an extension nothing converts. Jobs for it are
+	// rejected as unsupported_format before any work is done.
+	RouteUnsupported Route = "unsupported"
+)
+
+// libreOfficeExtensions are the formats LibreOffice imports reliably. Other
+// extensions can be routed to it with CONVERSION_ROUTES.
+var libreOfficeExtensions = []string{
+	// Writer
+	"doc", "docx", "docm", "dot", "dotx", "dotm", "odt", "ott", "fodt", "rtf", "txt", "wpd", "wps", "pages", "abw", "lwp", "sxw", "epub",
+	// Calc
+	"xls", "xlsx", "xlsm", "xlsb", "xlt", "xltx", "ods", "ots", "fods", "csv", "numbers", "sxc",
+	// Impress
+	"ppt", "pptx", "pptm", "pps", "ppsx", "pot", "potx", "odp", "otp", "fodp", "key", "sxi",
+	// Draw
+	"odg", "vsd", "vsdx", "svg",
+}
+
+// defaultRoutes maps every extension that has a converter to its route.
+var defaultRoutes = func() map[string]Route {
+	routes := map[string]Route{
+		"pdf":  RoutePassThrough,
+		"html": RouteChromium, "htm": RouteChromium, "xhtml": RouteChromium,
+		"md": RouteMarkdown, "markdown": RouteMarkdown,
+		"eml": RouteEmail, "msg": RouteEmail,
+	}
+	for ext := range imageExtensions {
+		routes[ext] = RouteImage
+	}
+	for _, ext := range libreOfficeExtensions {
+		routes[ext] = RouteLibreOffice
+	}
+	return routes
+}()
+
+// Routes is the routing table: the default routes with the operator's
+// CONVERSION_ROUTES overrides. A nil *Routes is the default table.
+type Routes struct {
+	overrides map[string]Route
+}
+
+// ParseRoutes reads CONVERSION_ROUTES, ext=route pairs. Overrides can send
+// more extensions to LibreOffice or refuse an extension; the other routes
+// are tied to the extensions their converters read.
+func ParseRoutes(pairs map[string]string) (*Routes, error) {
+	routes := &Routes{overrides: make(map[string]Route, len(pairs))}
+	for ext, name := range pairs {
+		ext = normalizeExtension(ext)
+		route := Route(strings.ToLower(strings.TrimSpace(name)))
+		switch {
+		case ext == "":
+			return nil, fmt.Errorf("route %q names no extension", name)
+		case route == RouteUnsupported:
+		case route == RouteLibreOffice:
+			if current, ok := defaultRoutes[ext]; ok && current != RouteLibreOffice {
+				return nil, fmt.Errorf(".%s is converted by the %s route and can't be sent to LibreOffice", ext, current)
+			}
+		default:
+			return nil, fmt.Errorf("unknown route %q for .%s, want %s or %s", name, ext, RouteLibreOffice, RouteUnsupported)
+		}
+		routes.overrides[ext] = route
+	}
+	return routes, nil
+}
+
+// For is the route of an extension, RouteUnsupported when nothing converts
+// it.
+func (r *Routes) For(ext string) Route {
+	ext = normalizeExtension(ext)
+	if r != nil {
+		if route, ok := r.overrides[ext]; ok {
+			return route
+		}
+	}
+	if route, ok := defaultRoutes[ext]; ok {
+		return route
+	}
+	return RouteUnsupported
+}
+
+// Extensions lists the extensions with a route, sorted.
+func (r *Routes) Extensions() []string {
+	var extensions []string
+	seen := make(map[string]bool)
+	add := func(ext string) {
+		if !seen[ext] && r.For(ext) != RouteUnsupported {
+			extensions = append(extensions, ext)
+		}
+		seen[ext] = true
+	}
+	for ext := range defaultRoutes {
+		add(ext)
+	}
+	if r != nil {
+		for ext := range r.overrides {
+			add(ext)
+		}
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
+func normalizeExtension(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+}
@@ -0,0 +1,50 @@
+package services
+
+import "testing"
+
+func TestRoutes(t *testing.T) {
+	t.Parallel()
+
+	routes, err := ParseRoutes(map[string]string{"wk1": "LibreOffice", ".docm": "unsupported"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		routes *Routes
+		ext    string
+		want   Route
+	}{
+		{nil, "DOCX", RouteLibreOffice},
+		{nil, ".pdf", RoutePassThrough},
+		{nil, "htm", RouteChromium},
+		{nil, "markdown", RouteMarkdown},
+		{nil, "msg", RouteEmail},
+		{nil, "heic", RouteImage},
+		{nil, "xyz", RouteUnsupported},
+		{nil, "", RouteUnsupported},
+		{routes, "wk1", RouteLibreOffice},
+		{routes, "docm", RouteUnsupported},
+		{routes, "docx", RouteLibreOffice},
+	}
+	for _, c := range cases {
+		if got := c.routes.For(c.ext); got != c.want {
+			t.Errorf("For(%q) = %s, want %s", c.ext, got, c.want)
+		}
+	}
+
+	for _, ext := range routes.Extensions() {
+		if ext == "docm" {
+			t.Error("Extensions lists an unsupported extension")
+		}
+	}
+
+	for _, pairs := range []map[string]string{
+		{"html": "libreoffice"},
+		{"xyz": "chromium"},
+		{"": "libreoffice"},
+	} {
+		if _, err := ParseRoutes(pairs); err == nil {
+			t.Errorf("ParseRoutes(%v) accepted", pairs)
+		}
+	}
+}
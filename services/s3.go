@@ -2,82 +2,168 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
 
 	"converter/config"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
 )
 
+// S3Service is the Storage for an S3 bucket or an S3-compatible gateway.
 type S3Service struct {
-	session    *session.Session
+	client     *s3.Client
 	bucket     string
-	downloader *s3manager.Downloader
-	uploader   *s3manager.Uploader
+	downloader *manager.Downloader
+	uploader   *manager.Uploader
+	breaker    *Breaker
 }
 
-func NewS3Service(cfg *config.Config) *S3Service {
-	awsCfg := &aws.Config{
-		Region: aws.String(cfg.S3Region),
-		Credentials: credentials.NewStaticCredentials(
-			cfg.AWSS3AccessKey,
-			cfg.AWSS3SecretKey,
-			"",
-		),
+// NewS3RateLimiter is the token bucket S3_RATE_LIMIT caps S3 traffic with,
+// or nil when it is off. A process makes one and gives it to every
+// S3Service it opens, so the cap is per instance however many buckets and
+// clients there are.
+func NewS3RateLimiter(cfg *config.Config) *TokenBucket {
+	if cfg.S3RateLimit <= 0 {
+		return nil
 	}
+	return NewTokenBucket(cfg.S3RateLimit, cfg.S3RateBurst)
+}
 
-	if cfg.S3Endpoint != "" {
-		awsCfg.Endpoint = aws.String(cfg.S3Endpoint)
-	}
+// NewS3Service opens the S3_BUCKET client. Every request waits on limiter,
+// the process's NewS3RateLimiter, unless it is nil.
+func NewS3Service(awsCfg aws.Config, cfg *config.Config, limiter *TokenBucket) *S3Service {
+	svc := &S3Service{bucket: cfg.S3Bucket}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.S3Endpoint)
+			// Gateways such as older MinIO and Ceph releases reject the
+			// checksums the SDK now sends by default
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+		}
+		o.UsePathStyle = cfg.S3UsePathStyle
+		if limiter != nil {
+			o.APIOptions = append(o.APIOptions, limitRequests(limiter))
+		}
+		o.APIOptions = append(o.APIOptions, svc.reportOutcomes)
+	})
 
-	if cfg.S3UsePathStyle {
-		awsCfg.S3ForcePathStyle = aws.Bool(true)
-	}
+	svc.client = client
+	svc.downloader = manager.NewDownloader(client)
+	svc.uploader = manager.NewUploader(client)
+	return svc
+}
 
-	sess := session.Must(session.NewSession(awsCfg))
+// SetBreaker has every S3 call report to breaker once the SDK's retries
+// are done: transport errors and 5xx responses as failures, anything else,
+// a missing key included, as the bucket being up.
+func (s *S3Service) SetBreaker(breaker *Breaker) {
+	s.breaker = breaker
+}
 
-	return &S3Service{
-		session:    sess,
-		bucket:     cfg.S3Bucket,
-		downloader: s3manager.NewDownloader(sess),
-		uploader:   s3manager.NewUploader(sess),
+func (s *S3Service) reportOutcomes(stack *middleware.Stack) error {
+	report := middleware.InitializeMiddlewareFunc("CircuitBreaker", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		switch {
+		case s.breaker == nil, ctx.Err() != nil:
+			// The job giving up says nothing about the bucket
+		case err == nil:
+			s.breaker.Succeeded()
+		default:
+			var respErr *awshttp.ResponseError
+			if errors.As(err, &respErr) && respErr.HTTPStatusCode() < http.StatusInternalServerError {
+				s.breaker.Succeeded()
+			} else {
+				s.breaker.Failed()
+			}
+		}
+		return out, metadata, err
+	})
+	return stack.Initialize.Add(report, middleware.Before)
+}
+
+// limitRequests gates every S3 attempt (including multipart parts and SDK
+// retries) on the instance-wide bucket, and slows the bucket down whenever
+// the gateway answers 503/SlowDown instead of letting retries pile up.
+func limitRequests(bucket *TokenBucket) func(*middleware.Stack) error {
+	limit := middleware.FinalizeMiddlewareFunc("RateLimit", func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		if err := bucket.Wait(ctx); err != nil {
+			return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+		}
+
+		out, metadata, err := next.HandleFinalize(ctx, in)
+		switch {
+		case err == nil:
+			bucket.Succeeded()
+		case isSlowDown(err):
+			bucket.Throttled()
+			slog.Warn("Throttled by storage gateway, reducing rate", "component", "s3", "rate", bucket.Rate())
+		}
+		return out, metadata, err
+	})
+
+	return func(stack *middleware.Stack) error {
+		// After the retry middleware, so each attempt is counted
+		return stack.Finalize.Insert(limit, "Retry", middleware.After)
 	}
 }
 
-func (s *S3Service) Download(ctx context.Context, s3Path string, fileGUID string, extension string) (string, error) {
-	// Create temp directory
-	tempDir := "/tmp/conversions"
-	os.MkdirAll(tempDir, 0755)
+func isSlowDown(err error) bool {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusServiceUnavailable {
+		return true
+	}
+	if s3ErrorCode(err) == "SlowDown" {
+		return true
+	}
+	return retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary
+}
 
-	localPath := filepath.Join(tempDir, fmt.Sprintf("%s.%s", fileGUID, extension))
+// s3ErrorCode returns the S3 error code of err, or "" when it has none.
+func s3ErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
 
-	// Create file
+// Download stores the object at localPath, which the caller picks with a
+// TempLease.
+func (s *S3Service) Download(ctx context.Context, key string, localPath string) error {
 	file, err := os.Create(localPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to create local file: %w", err)
+		return fmt.Errorf("failed to create local file: %w", err)
 	}
 	defer file.Close()
 
 	// Download from S3
-	_, err = s.downloader.DownloadWithContext(ctx, file, &s3.GetObjectInput{
+	_, err = s.downloader.Download(ctx, file, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(s3Path),
+		Key:    aws.String(key),
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("failed to download from S3: %w", err)
+		return fmt.Errorf("failed to download from S3: %w", err)
 	}
 
-	return localPath, nil
+	return nil
 }
 
-func (s *S3Service) Upload(ctx context.Context, localPath string, s3Path string) error {
+func (s *S3Service) Upload(ctx context.Context, localPath string, key string, contentType string) error {
 	// Open file
 	file, err := os.Open(localPath)
 	if err != nil {
@@ -85,13 +171,45 @@ func (s *S3Service) Upload(ctx context.Context, localPath string, s3Path string)
 	}
 	defer file.Close()
 
-	// Upload to S3
-	_, err = s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
-		Bucket:      aws.String(s.bucket),
-		Key:         aws.String(s3Path),
-		Body:        file,
-		ContentType: aws.String("application/pdf"),
+	return s.UploadStream(ctx, file, key, contentType)
+}
+
+// Open reads the object's body straight from S3.
+func (s *S3Service) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
 	})
+	if s3ErrorCode(err) == "NoSuchKey" {
+		return nil, fmt.Errorf("failed to download from S3: %w", ErrObjectNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from S3: %w", err)
+	}
+	return out.Body, nil
+}
+
+// UploadStream uploads r in parts buffered in memory. A failed multipart
+// upload is aborted, so no partial object is left. A retention set on ctx
+// with WithRetention locks the object as it is written; the bucket must
+// have Object Lock enabled.
+func (s *S3Service) UploadStream(ctx context.Context, r io.Reader, key string, contentType string) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        r,
+		ContentType: aws.String(contentType),
+	}
+	if retention, ok := RetentionFrom(ctx); ok {
+		if retention.Mode != "" {
+			input.ObjectLockMode = types.ObjectLockMode(retention.Mode)
+			input.ObjectLockRetainUntilDate = aws.Time(retention.RetainUntil)
+		}
+		if retention.LegalHold {
+			input.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+		}
+	}
+	_, err := s.uploader.Upload(ctx, input)
 
 	if err != nil {
 		return fmt.Errorf("failed to upload to S3: %w", err)
@@ -100,9 +218,56 @@ func (s *S3Service) Upload(ctx context.Context, localPath string, s3Path string)
 	return nil
 }
 
-func (s *S3Service) Cleanup(path string) error {
-	if path == "" {
-		return nil
+// Stat reads the object's size and content type without downloading it.
+func (s *S3Service) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if code := s3ErrorCode(err); code == "NotFound" || code == "NoSuchKey" {
+		return nil, fmt.Errorf("failed to stat S3 object: %w", ErrObjectNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat S3 object: %w", err)
 	}
-	return os.Remove(path)
+	return &ObjectInfo{Size: aws.ToInt64(out.ContentLength), ContentType: aws.ToString(out.ContentType)}, nil
+}
+
+func (s *S3Service) Copy(ctx context.Context, from string, to string) error {
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		CopySource: aws.String((&url.URL{Path: s.bucket + "/" + from}).EscapedPath()),
+		Key:        aws.String(to),
+	})
+	if s3ErrorCode(err) == "NoSuchKey" {
+		return fmt.Errorf("failed to copy S3 object: %w", ErrObjectNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy S3 object: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Service) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete S3 object: %w", err)
+	}
+	return nil
+}
+
+// Ping checks that the bucket is reachable with the configured credentials.
+func (s *S3Service) Ping(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	if err != nil {
+		return fmt.Errorf("failed to reach S3 bucket: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Service) Cleanup(path string) error {
+	return removeLocal(path)
 }
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Engines an office document can be converted with.
+const (
+	EngineGotenberg = "gotenberg"
+	EngineSoffice   = "soffice"
+)
+
+// sofficeExportFilters picks the PDF export filter of the LibreOffice
+// application that opens the format; Writer's is the fallback.
+var sofficeExportFilters = map[string]string{
+	"xls": "calc_pdf_Export", "xlsx": "calc_pdf_Export", "xlsm": "calc_pdf_Export", "ods": "calc_pdf_Export", "csv": "calc_pdf_Export",
+	"ppt": "impress_pdf_Export", "pptx": "impress_pdf_Export", "pps": "impress_pdf_Export", "ppsx": "impress_pdf_Export", "odp": "impress_pdf_Export",
+	"odg": "draw_pdf_Export", "vsd": "draw_pdf_Export", "vsdx": "draw_pdf_Export",
+}
+
+// sofficePDFVersions maps PDF/A levels to LibreOffice's SelectPdfVersion.
+var sofficePDFVersions = map[string]int{"PDF/A-1b": 1, "PDF/A-2b": 2, "PDF/A-3b": 3}
+
+// SofficeService converts office documents with a LibreOffice installed
+// next to the worker. It is slower than Gotenberg's pool but costs nothing
+// beyond the worker's own CPU.
+type SofficeService struct {
+	binary string
+}
+
+func NewSofficeService(binary string) *SofficeService {
+	return &SofficeService{binary: binary}
+}
+
+// Supports reports whether the local route can honour opts; it can't
+// flatten, which only Gotenberg's PDF engines do, or take a document
+// password.
+func (s *SofficeService) Supports(opts ConvertOptions) bool {
+	return !opts.Flatten && opts.Password == ""
+}
+
+// ConvertToPDFA converts an office document to PDF/A with soffice. Each
+// call gets its own LibreOffice profile so concurrent workers don't contend
+// for the profile lock.
+func (s *SofficeService) ConvertToPDFA(ctx context.Context, inputPath string, extension string, opts ConvertOptions) (string, error) {
+	workDir := inputPath + ".soffice"
+	if err := os.MkdirAll(workDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	filter, err := sofficeFilter(extension, opts)
+	if err != nil {
+		return "", err
+	}
+	if _, err := output(ctx, s.binary,
+		"-env:UserInstallation=file://"+filepath.ToSlash(filepath.Join(workDir, "profile")),
+		"--headless", "--norestore",
+		"--convert-to", filter,
+		"--outdir", workDir,
+		inputPath,
+	); err != nil {
+		return "", fmt.Errorf("soffice conversion failed: %w", err)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	outputPath := inputPath + ".converted.pdf"
+	if err := os.Rename(filepath.Join(workDir, base+".pdf"), outputPath); err != nil {
+		return "", fmt.Errorf("soffice produced no PDF: %w", err)
+	}
+	return outputPath, nil
+}
+
+// sofficeFilter builds the PDF export filter for the format, with its
+// options in LibreOffice's JSON syntax.
+func sofficeFilter(extension string, opts ConvertOptions) (string, error) {
+	conformance := opts.Conformance
+	if conformance == "" {
+		conformance = DefaultPDFAConformance
+	}
+	version, ok := sofficePDFVersions[conformance]
+	if !ok {
+		return "", fmt.Errorf("unsupported PDF/A conformance %s", conformance)
+	}
+
+	type option struct {
+		Type  string      `json:"type"`
+		Value interface{} `json:"value"`
+	}
+	options := map[string]option{
+		"SelectPdfVersion": {Type: "long", Value: version},
+	}
+	if opts.Accessible {
+		options["PDFUACompliance"] = option{Type: "boolean", Value: true}
+		options["UseTaggedPDF"] = option{Type: "boolean", Value: true}
+	}
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+	filter, ok := sofficeExportFilters[strings.ToLower(extension)]
+	if !ok {
+		filter = "writer_pdf_Export"
+	}
+	return "pdf:" + filter + ":" + string(encoded), nil
+}
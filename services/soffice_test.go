@@ -0,0 +1,31 @@
+package services
+
+import "testing"
+
+func TestSofficeFilter(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		extension string
+		opts      ConvertOptions
+		want      string
+	}{
+		{"docx", ConvertOptions{}, `pdf:writer_pdf_Export:{"SelectPdfVersion":{"type":"long","value":2}}`},
+		{"XLSX", ConvertOptions{Conformance: "PDF/A-1b"}, `pdf:calc_pdf_Export:{"SelectPdfVersion":{"type":"long","value":1}}`},
+		{"pptx", ConvertOptions{Conformance: "PDF/A-3b", Accessible: true},
+			`pdf:impress_pdf_Export:{"PDFUACompliance":{"type":"boolean","value":true},"SelectPdfVersion":{"type":"long","value":3},"UseTaggedPDF":{"type":"boolean","value":true}}`},
+	}
+	for _, c := range cases {
+		got, err := sofficeFilter(c.extension, c.opts)
+		if err != nil {
+			t.Fatalf("sofficeFilter(%q) error = %v", c.extension, err)
+		}
+		if got != c.want {
+			t.Errorf("sofficeFilter(%q) = %s, want %s", c.extension, got, c.want)
+		}
+	}
+
+	if _, err := sofficeFilter("docx", ConvertOptions{Conformance: "PDF/A-4"}); err == nil {
+		t.Error("sofficeFilter() accepted an unsupported conformance level")
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"converter/models"
+)
+
+var splitPagesPattern = regexp.MustCompile(`^\d+(-\d+)?(,\d+(-\d+)?)*$`)
+
+// ValidateSplit checks a job's split options before any work is done.
+func ValidateSplit(split models.SplitOptions) error {
+	switch split.Mode {
+	case models.SplitIntervals:
+		if n, err := strconv.Atoi(split.Span); err != nil || n <= 0 {
+			return fmt.Errorf("split span %q must be a positive page count", split.Span)
+		}
+	case models.SplitPages:
+		if !splitPagesPattern.MatchString(split.Span) {
+			return fmt.Errorf("split span %q must be a page range list like 1-3,5", split.Span)
+		}
+	default:
+		return fmt.Errorf("unknown split mode %q", split.Mode)
+	}
+	return nil
+}
+
+// Split cuts a PDF into parts with Gotenberg's split route and returns the
+// local part paths in page order. Parts keep the requested PDF/A level.
+func (g *GotenbergService) Split(ctx context.Context, pdfPath string, split models.SplitOptions, opts ConvertOptions) ([]string, error) {
+	file, err := os.Open(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer file.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("files", filepath.Base(pdfPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	writer.WriteField("splitMode", split.Mode)
+	writer.WriteField("splitSpan", split.Span)
+	if split.Unify {
+		writer.WriteField("splitUnify", "true")
+	}
+	// The input is already flattened if that was requested
+	opts.Flatten = false
+	g.writeOutputFields(writer, opts)
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	resp, err := g.send(ctx, "/forms/pdfengines/split", body, writer.FormDataContentType())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// A split that yields a single part comes back as a plain PDF
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/zip") {
+		partPath := splitPartPath(pdfPath, 0)
+		if err := g.saveResponse(resp, partPath); err != nil {
+			os.Remove(partPath)
+			return nil, err
+		}
+		return []string{partPath}, nil
+	}
+
+	zipPath := pdfPath + ".split.zip"
+	defer os.Remove(zipPath)
+	if err := g.writeLimited(resp.Body, zipPath); err != nil {
+		return nil, err
+	}
+
+	parts, err := g.extractSplitParts(zipPath, pdfPath)
+	if err != nil {
+		for _, p := range parts {
+			os.Remove(p)
+		}
+		return nil, err
+	}
+	return parts, nil
+}
+
+func splitPartPath(pdfPath string, index int) string {
+	return fmt.Sprintf("%s.part-%03d.pdf", pdfPath, index+1)
+}
+
+// extractSplitParts unpacks Gotenberg's archive. Its entries share a prefix
+// and end in a running number (doc_0.pdf ... doc_10.pdf), so ordering by
+// length then name restores page order. Each part is held to the same size
+// limit as a response, so a small archive can't expand without bound.
+func (g *GotenbergService) extractSplitParts(zipPath string, pdfPath string) ([]string, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open split archive: %w", err)
+	}
+	defer r.Close()
+
+	files := make([]*zip.File, 0, len(r.File))
+	for _, f := range r.File {
+		if strings.EqualFold(filepath.Ext(f.Name), ".pdf") {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("split archive contains no PDFs")
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if len(files[i].Name) != len(files[j].Name) {
+			return len(files[i].Name) < len(files[j].Name)
+		}
+		return files[i].Name < files[j].Name
+	})
+
+	parts := make([]string, 0, len(files))
+	for i, f := range files {
+		partPath := splitPartPath(pdfPath, i)
+		parts = append(parts, partPath)
+		if err := g.extractZipFile(f, partPath); err != nil {
+			return parts, err
+		}
+	}
+	return parts, nil
+}
+
+func (g *GotenbergService) extractZipFile(f *zip.File, outputPath string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s in split archive: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	return g.writeLimited(rc, outputPath)
+}
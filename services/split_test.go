@@ -0,0 +1,119 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"converter/models"
+)
+
+func TestValidateSplit(t *testing.T) {
+	t.Parallel()
+
+	valid := []models.SplitOptions{
+		{Mode: models.SplitIntervals, Span: "10"},
+		{Mode: models.SplitPages, Span: "1-3,5"},
+		{Mode: models.SplitPages, Span: "2", Unify: true},
+	}
+	for _, split := range valid {
+		if err := ValidateSplit(split); err != nil {
+			t.Errorf("expected %+v to be valid, got %v", split, err)
+		}
+	}
+
+	invalid := []models.SplitOptions{
+		{Mode: models.SplitIntervals, Span: "0"},
+		{Mode: models.SplitIntervals, Span: "1-3"},
+		{Mode: models.SplitPages, Span: "1-"},
+		{Mode: models.SplitPages, Span: ""},
+		{Mode: "size", Span: "10MB"},
+	}
+	for _, split := range invalid {
+		if err := ValidateSplit(split); err == nil {
+			t.Errorf("expected %+v to be rejected", split)
+		}
+	}
+}
+
+func TestGotenbergService_SplitOrdersArchiveParts(t *testing.T) {
+	t.Parallel()
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, i := range []int{10, 2, 0, 1, 3, 4, 5, 6, 7, 8, 9} {
+		w, _ := zw.Create(fmt.Sprintf("doc_%d.pdf", i))
+		fmt.Fprintf(w, "%%PDF-1.7 part %d", i)
+	}
+	zw.Close()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/forms/pdfengines/split" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.FormValue("splitMode") != "intervals" || r.FormValue("splitSpan") != "1" {
+			t.Errorf("unexpected split fields: mode=%q span=%q", r.FormValue("splitMode"), r.FormValue("splitSpan"))
+		}
+		if r.FormValue("flatten") != "" {
+			t.Error("split must not flatten again")
+		}
+		header := make(http.Header)
+		header.Set("Content-Type", "application/zip")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader(archive.Bytes())),
+			Header:     header,
+		}, nil
+	})
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	os.WriteFile(pdfPath, []byte("%PDF-1.7"), 0644)
+
+	parts, err := svc.Split(context.Background(), pdfPath, models.SplitOptions{Mode: models.SplitIntervals, Span: "1"}, ConvertOptions{Flatten: true})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(parts) != 11 {
+		t.Fatalf("expected 11 parts, got %d", len(parts))
+	}
+	for i, part := range parts {
+		data, _ := os.ReadFile(part)
+		if want := fmt.Sprintf("%%PDF-1.7 part %d", i); string(data) != want {
+			t.Fatalf("part %d: expected %q, got %q", i, want, data)
+		}
+	}
+	if _, err := os.Stat(pdfPath + ".split.zip"); !os.IsNotExist(err) {
+		t.Fatal("expected the archive to be removed")
+	}
+}
+
+func TestGotenbergService_SplitSinglePart(t *testing.T) {
+	t.Parallel()
+
+	svc := NewGotenbergService("http://example.invalid", 0, RequestIdentity{})
+	svc.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte("%PDF-1.7 unified"))),
+			Header:     http.Header{"Content-Type": []string{"application/pdf"}},
+		}, nil
+	})
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	os.WriteFile(pdfPath, []byte("%PDF-1.7"), 0644)
+
+	parts, err := svc.Split(context.Background(), pdfPath, models.SplitOptions{Mode: models.SplitPages, Span: "1-3", Unify: true}, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(parts) != 1 || parts[0] != pdfPath+".part-001.pdf" {
+		t.Fatalf("expected a single part, got %v", parts)
+	}
+}
@@ -0,0 +1,18 @@
+package services
+
+import (
+	"converter/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// NewSQSClient connects to SQS with the shared AWS configuration.
+// SQS_ENDPOINT overrides the endpoint independently of S3_ENDPOINT.
+func NewSQSClient(awsCfg aws.Config, cfg *config.Config) *sqs.Client {
+	return sqs.NewFromConfig(awsCfg, func(o *sqs.Options) {
+		if cfg.SQSEndpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.SQSEndpoint)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"converter/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StatusStore maintains the conversion:status:<id> hashes read by the
+// producer, applying the same state machine as the database.
+type StatusStore struct {
+	client *redis.Client
+}
+
+// setStatusScript updates the hash only when the current status (if any) is
+// one of the comma-separated predecessors in ARGV[1]. Returns 0 on rejection.
+var setStatusScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'status')
+if current then
+	local allowed = false
+	for s in string.gmatch(ARGV[1], '[^,]+') do
+		if s == current then
+			allowed = true
+			break
+		end
+	end
+	if not allowed then
+		return 0
+	end
+end
+for i = 2, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return 1
+`)
+
+func NewStatusStore(client *redis.Client) *StatusStore {
+	return &StatusStore{client: client}
+}
+
+func StatusKey(conversionID int) string {
+	return fmt.Sprintf("conversion:status:%d", conversionID)
+}
+
+func (s *StatusStore) Set(ctx context.Context, conversionID int, status models.ConversionStatus, fields map[string]interface{}) error {
+	if !status.Valid() {
+		return fmt.Errorf("unknown conversion status %q", status)
+	}
+
+	args := []interface{}{strings.Join(statusStrings(models.Predecessors(status)), ",")}
+	args = append(args, "status", string(status), "updated_at", time.Now().Format(time.RFC3339))
+	for k, v := range fields {
+		args = append(args, k, v)
+	}
+
+	ok, err := setStatusScript.Run(ctx, s.client, []string{StatusKey(conversionID)}, args...).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return &models.ErrIllegalTransition{ConversionID: conversionID, To: status}
+	}
+	return nil
+}
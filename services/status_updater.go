@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"converter/models"
+)
+
+// ErrUpdaterClosed is passed to a Then callback registered after Close.
+var ErrUpdaterClosed = errors.New("status updater is closed")
+
+// StatusUpdater applies database writes from a single background goroutine
+// so workers never wait on Postgres. Updates are applied in enqueue order.
+type StatusUpdater struct {
+	db         *DatabaseService
+	updates    chan statusUpdate
+	maxRetries int
+	done       chan struct{}
+	// mu guards closed: senders hold it for reading, so Close can't close
+	// updates under a send.
+	mu     sync.RWMutex
+	closed bool
+	// failed holds the first update given up on per conversion until Then
+	// reports it. Only the Run goroutine touches it.
+	failed map[int]error
+}
+
+type statusUpdate struct {
+	conversionID int
+	desc         string
+	apply        func(ctx context.Context) error
+	then         func(err error)
+}
+
+func NewStatusUpdater(db *DatabaseService, queueSize int, maxRetries int) *StatusUpdater {
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	return &StatusUpdater{
+		db:         db,
+		updates:    make(chan statusUpdate, queueSize),
+		maxRetries: maxRetries,
+		done:       make(chan struct{}),
+		failed:     make(map[int]error),
+	}
+}
+
+func (u *StatusUpdater) UpdateStatus(conversionID int, status models.ConversionStatus, outputPath string, metadata map[string]interface{}) {
+	u.enqueue(statusUpdate{
+		conversionID: conversionID,
+		desc:         "status " + string(status),
+		apply: func(ctx context.Context) error {
+			return u.db.UpdateConversionStatus(ctx, conversionID, status, outputPath, metadata)
+		},
+	})
+}
+
+func (u *StatusUpdater) UpdateError(conversionID int, errorMsg string) {
+	u.enqueue(statusUpdate{
+		conversionID: conversionID,
+		desc:         "error message",
+		apply: func(ctx context.Context) error {
+			return u.db.UpdateConversionError(ctx, conversionID, errorMsg)
+		},
+	})
+}
+
+func (u *StatusUpdater) IncrementRetryCount(conversionID int) {
+	u.enqueue(statusUpdate{
+		conversionID: conversionID,
+		desc:         "retry count",
+		apply: func(ctx context.Context) error {
+			return u.db.IncrementRetryCount(ctx, conversionID)
+		},
+	})
+}
+
+func (u *StatusUpdater) UpdateRetrySchedule(conversionID int, nextRetryAt *time.Time, remaining int) {
+	u.enqueue(statusUpdate{
+		conversionID: conversionID,
+		desc:         "retry schedule",
+		apply: func(ctx context.Context) error {
+			return u.db.UpdateRetrySchedule(ctx, conversionID, nextRetryAt, remaining)
+		},
+	})
+}
+
+// Then calls fn once every update queued so far has been applied, with the
+// error of the first update for conversionID that was given up on, or nil.
+// fn runs on the updater goroutine and must not block.
+func (u *StatusUpdater) Then(conversionID int, fn func(err error)) {
+	u.enqueue(statusUpdate{conversionID: conversionID, desc: "callback", then: fn})
+}
+
+// enqueue queues the update. An update that arrives after Close, from a
+// goroutine that outlived shutdown, is logged and dropped.
+func (u *StatusUpdater) enqueue(update statusUpdate) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	if u.closed {
+		slog.Warn("Status updater closed, dropping update", "component", "db_updater", "update", update.desc, "conversion_id", update.conversionID)
+		if update.then != nil {
+			update.then(ErrUpdaterClosed)
+		}
+		return
+	}
+
+	select {
+	case u.updates <- update:
+	default:
+		slog.Warn("Status update queue full, waiting to enqueue", "component", "db_updater", "update", update.desc, "conversion_id", update.conversionID)
+		u.updates <- update
+	}
+}
+
+// Run applies queued updates until Close is called and the queue is drained.
+func (u *StatusUpdater) Run() {
+	defer close(u.done)
+
+	for update := range u.updates {
+		if update.then != nil {
+			err := u.failed[update.conversionID]
+			delete(u.failed, update.conversionID)
+			update.then(err)
+			continue
+		}
+		if err := u.applyWithRetry(update); err != nil && u.failed[update.conversionID] == nil {
+			u.failed[update.conversionID] = err
+		}
+	}
+}
+
+// Close stops accepting updates and waits for the queue to drain.
+func (u *StatusUpdater) Close() {
+	u.mu.Lock()
+	if !u.closed {
+		u.closed = true
+		close(u.updates)
+	}
+	u.mu.Unlock()
+	<-u.done
+}
+
+func (u *StatusUpdater) applyWithRetry(update statusUpdate) error {
+	delay := 500 * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := update.apply(ctx)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		var illegal *models.ErrIllegalTransition
+		if errors.As(err, &illegal) {
+			slog.Warn("Status anomaly", "component", "db_updater", "conversion_id", update.conversionID, "error", err)
+			return nil
+		}
+
+		if attempt >= u.maxRetries {
+			slog.Error("Giving up on status update", "component", "db_updater",
+				"update", update.desc, "conversion_id", update.conversionID, "attempts", attempt+1, "error", err)
+			return err
+		}
+
+		slog.Warn("Failed to write status update", "component", "db_updater",
+			"update", update.desc, "conversion_id", update.conversionID, "attempt", attempt+1, "error", err)
+		time.Sleep(delay)
+		if delay < 10*time.Second {
+			delay *= 2
+		}
+	}
+}
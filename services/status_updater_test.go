@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStatusUpdater_Then(t *testing.T) {
+	t.Parallel()
+
+	u := NewStatusUpdater(nil, 10, 0)
+	go u.Run()
+	defer u.Close()
+
+	applied := 0
+	u.enqueue(statusUpdate{conversionID: 7, desc: "status", apply: func(context.Context) error {
+		applied++
+		return nil
+	}})
+	u.enqueue(statusUpdate{conversionID: 8, desc: "status", apply: func(context.Context) error {
+		return errors.New("connection refused")
+	}})
+
+	results := make(chan error, 3)
+	u.Then(7, func(err error) {
+		if applied != 1 {
+			t.Errorf("Then ran before the queued update was applied")
+		}
+		results <- err
+	})
+	u.Then(8, func(err error) { results <- err })
+	u.Then(8, func(err error) { results <- err })
+
+	if err := <-results; err != nil {
+		t.Errorf("conversion 7: Then got %v, want nil", err)
+	}
+	if err := <-results; err == nil {
+		t.Error("conversion 8: Then got nil, want the failed update's error")
+	}
+	// The failure is reported once
+	if err := <-results; err != nil {
+		t.Errorf("conversion 8 again: Then got %v, want nil", err)
+	}
+}
+
+func TestStatusUpdater_EnqueueAfterClose(t *testing.T) {
+	t.Parallel()
+
+	u := NewStatusUpdater(nil, 1, 0)
+	go u.Run()
+	u.Close()
+
+	// A late update must not panic with a send on the closed channel
+	u.IncrementRetryCount(7)
+	var got error
+	u.Then(7, func(err error) { got = err })
+	if !errors.Is(got, ErrUpdaterClosed) {
+		t.Errorf("Then after Close got %v, want ErrUpdaterClosed", got)
+	}
+	u.Close()
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"converter/config"
+)
+
+// Storage drivers selectable with STORAGE_DRIVER.
+const (
+	StorageS3    = "s3"
+	StorageGCS   = "gcs"
+	StorageLocal = "local"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+// Storage is the bucket job inputs are read from and outputs written to.
+// Keys are object names within the configured bucket.
+type Storage interface {
+	// Download stores the object at localPath.
+	Download(ctx context.Context, key string, localPath string) error
+	// Upload stores the local file as the object, replacing any there.
+	Upload(ctx context.Context, localPath string, key string, contentType string) error
+	// Open returns the object's content for reading without a local file.
+	// It returns an error wrapping ErrObjectNotFound when there is none.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// UploadStream stores what r yields as the object, replacing any there.
+	// When r fails, no object is left behind.
+	UploadStream(ctx context.Context, r io.Reader, key string, contentType string) error
+	// Stat describes the object without downloading it. It returns an
+	// error wrapping ErrObjectNotFound when there is none.
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Copy copies the object at from to the key to, replacing any there. It
+	// returns an error wrapping ErrObjectNotFound when from doesn't exist.
+	Copy(ctx context.Context, from string, to string) error
+	// Delete removes the object. Deleting a missing object succeeds.
+	Delete(ctx context.Context, key string) error
+	// Cleanup removes a local file left by Download or a conversion step.
+	Cleanup(path string) error
+	// Ping checks that the bucket is reachable with the configured
+	// credentials.
+	Ping(ctx context.Context) error
+}
+
+// NewStorage opens the storage STORAGE_DRIVER selects. An S3 bucket's
+// requests wait on s3Limiter, the process's NewS3RateLimiter.
+func NewStorage(ctx context.Context, cfg *config.Config, s3Limiter *TokenBucket) (Storage, error) {
+	switch cfg.StorageDriver {
+	case StorageS3:
+		awsCfg, err := NewAWSConfig(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewS3Service(awsCfg, cfg, s3Limiter), nil
+	case StorageGCS:
+		return NewGCSService(ctx, cfg)
+	case StorageLocal:
+		return NewLocalStorage(cfg.LocalStorageRoot)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+func removeLocal(path string) error {
+	if path == "" {
+		return nil
+	}
+	return os.Remove(path)
+}
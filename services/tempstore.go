@@ -0,0 +1,138 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"converter/config"
+	"converter/metrics"
+)
+
+// tempSizeFactor estimates a job's peak temp usage from its input size: the
+// input, the converted PDF, plus encrypted copies and artifacts.
+const tempSizeFactor = 4
+
+func init() {
+	metrics.Describe("conversion_temp_fast_bytes", "Bytes reserved on the fast temp directory")
+	metrics.Describe("conversion_temp_placements_total", "Jobs placed on each temp directory")
+}
+
+// TempStore places each job's temp files on the fast (tmpfs/emptyDir)
+// directory when its estimated size fits both the per-job cap and what is
+// left of the global budget, and on disk otherwise.
+type TempStore struct {
+	diskDir     string
+	fastDir     string
+	maxJobBytes int64
+	maxBytes    int64
+
+	mu       sync.Mutex
+	reserved int64
+}
+
+// TempLease is one job's temp directory, created below the fast or disk
+// directory. Release removes it and returns its reservation.
+type TempLease struct {
+	Dir   string
+	Fast  bool
+	bytes int64
+	store *TempStore
+}
+
+func NewTempStore(cfg *config.Config) *TempStore {
+	t := &TempStore{
+		diskDir:     cfg.TempDir,
+		fastDir:     cfg.FastTempDir,
+		maxJobBytes: cfg.FastTempMaxJobBytes,
+		maxBytes:    cfg.FastTempMaxBytes,
+	}
+	os.MkdirAll(t.diskDir, 0755)
+	if t.fastDir != "" {
+		if err := os.MkdirAll(t.fastDir, 0755); err != nil {
+			slog.Error("Fast temp directory unavailable, using disk only", "component", "temp", "dir", t.fastDir, "error", err)
+			t.fastDir = ""
+		}
+	}
+	return t
+}
+
+// Reserve creates a directory named after the job for a job whose input is
+// inputSize bytes, on the fast directory when it fits. A negative size
+// (unknown) always goes to disk.
+func (t *TempStore) Reserve(name string, inputSize int64) (*TempLease, error) {
+	lease := t.place(inputSize)
+	dir, err := os.MkdirTemp(lease.Dir, name+"-*")
+	if err != nil {
+		lease.Dir = ""
+		lease.Release()
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	lease.Dir = dir
+	return lease, nil
+}
+
+func (t *TempStore) place(inputSize int64) *TempLease {
+	estimate := inputSize * tempSizeFactor
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.fastDir == "" || inputSize < 0 || estimate > t.maxJobBytes || t.reserved+estimate > t.maxBytes {
+		metrics.Inc("conversion_temp_placements_total", "dir", "disk")
+		return &TempLease{Dir: t.diskDir}
+	}
+
+	t.reserved += estimate
+	metrics.Set("conversion_temp_fast_bytes", t.reserved)
+	metrics.Inc("conversion_temp_placements_total", "dir", "fast")
+	return &TempLease{Dir: t.fastDir, Fast: true, bytes: estimate, store: t}
+}
+
+// FastEnabled reports whether a fast directory is configured, so callers
+// can skip sizing the input when every job goes to disk anyway.
+func (t *TempStore) FastEnabled() bool {
+	return t.fastDir != ""
+}
+
+// Reserved is the number of bytes currently reserved on the fast directory.
+func (t *TempStore) Reserved() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.reserved
+}
+
+// FreeBytes is the space left on the disk temp directory's filesystem for
+// unprivileged writers.
+func (t *TempStore) FreeBytes() (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(t.diskDir, &stat); err != nil {
+		return 0, fmt.Errorf("failed to stat temp directory: %w", err)
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
+
+// Release removes the job's directory with whatever is left in it. It may
+// be called more than once.
+func (l *TempLease) Release() {
+	if l.Dir != "" {
+		os.RemoveAll(l.Dir)
+	}
+	if l.store == nil {
+		return
+	}
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	l.store.reserved -= l.bytes
+	metrics.Set("conversion_temp_fast_bytes", l.store.reserved)
+	l.store = nil
+}
+
+// LocalPath is where the job's input is stored; every derived temp file
+// (converted PDF, artifacts) shares it as a prefix.
+func (l *TempLease) LocalPath(fileGUID string, extension string) string {
+	return filepath.Join(l.Dir, fmt.Sprintf("%s.%s", fileGUID, extension))
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"converter/config"
+)
+
+func TestTempStore_Reserve(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	store := NewTempStore(&config.Config{
+		TempDir:             filepath.Join(dir, "disk"),
+		FastTempDir:         filepath.Join(dir, "fast"),
+		FastTempMaxJobBytes: 400,
+		FastTempMaxBytes:    600,
+	})
+
+	small := reserve(t, store, 100)
+	if !small.Fast || store.Reserved() != 400 {
+		t.Fatalf("small job: fast=%v reserved=%d, want fast with 400 reserved", small.Fast, store.Reserved())
+	}
+
+	if big := reserve(t, store, 101); big.Fast {
+		t.Fatal("job over the per-job cap must go to disk")
+	}
+	if overBudget := reserve(t, store, 60); overBudget.Fast {
+		t.Fatal("job exceeding the remaining budget must go to disk")
+	}
+	if unknown := reserve(t, store, -1); unknown.Fast {
+		t.Fatal("job of unknown size must go to disk")
+	}
+
+	small.Release()
+	small.Release()
+	if store.Reserved() != 0 {
+		t.Fatalf("reserved = %d after release, want 0", store.Reserved())
+	}
+	if again := reserve(t, store, 60); !again.Fast {
+		t.Fatal("released budget should be reusable")
+	}
+}
+
+func TestTempStore_DiskOnly(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	store := NewTempStore(&config.Config{TempDir: dir, FastTempMaxJobBytes: 1 << 20, FastTempMaxBytes: 1 << 30})
+
+	lease := reserve(t, store, 10)
+	if lease.Fast || filepath.Dir(lease.Dir) != dir || lease.LocalPath("abc", "docx") != filepath.Join(lease.Dir, "abc.docx") {
+		t.Fatalf("lease = %+v, want a job directory on disk", lease)
+	}
+
+	if err := os.WriteFile(lease.LocalPath("abc", "docx"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lease.Release()
+	if _, err := os.Stat(lease.Dir); !os.IsNotExist(err) {
+		t.Fatalf("job directory still exists after release: %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("temp directory removed with the job's: %v", err)
+	}
+}
+
+func TestTempStore_FreeBytes(t *testing.T) {
+	t.Parallel()
+
+	store := NewTempStore(&config.Config{TempDir: t.TempDir()})
+	if free, err := store.FreeBytes(); err != nil || free <= 0 {
+		t.Fatalf("FreeBytes() = %d, %v, want the free space", free, err)
+	}
+}
+
+func reserve(t *testing.T, store *TempStore, inputSize int64) *TempLease {
+	t.Helper()
+	lease, err := store.Reserve("1", inputSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lease
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TenantConfig is the JSON stored per tenant ID in the conversion:tenants
+// hash. Every field is optional; anything left unset falls back to the
+// deployment's environment configuration, so single-tenant installs never
+// need an entry at all.
+type TenantConfig struct {
+	Events *EventRoute `json:"events,omitempty"`
+	// CostPolicy overrides when the tenant's jobs take the cheap conversion
+	// path: "peak" (the default), "fast" or "economy".
+	CostPolicy string `json:"costPolicy,omitempty"`
+	// RateLimit and RateBurst override TENANT_RATE_LIMIT (jobs per second,
+	// 0 exempts the tenant) and TENANT_RATE_BURST.
+	RateLimit *float64 `json:"rateLimit,omitempty"`
+	RateBurst int      `json:"rateBurst,omitempty"`
+}
+
+// TenantConfigs reads per-tenant overrides from Redis and caches the whole
+// set for a short TTL, like FeatureFlags.
+type TenantConfigs struct {
+	client   *redis.Client
+	key      string
+	cacheTTL time.Duration
+
+	mu       sync.Mutex
+	tenants  map[int]TenantConfig
+	loadedAt time.Time
+}
+
+func NewTenantConfigs(client *redis.Client, key string, cacheTTL time.Duration) *TenantConfigs {
+	return &TenantConfigs{
+		client:   client,
+		key:      key,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// Get returns the tenant's overrides. Tenants without an entry, and Redis
+// errors with nothing cached, yield the zero TenantConfig.
+func (t *TenantConfigs) Get(ctx context.Context, tenantID int) TenantConfig {
+	return t.load(ctx)[tenantID]
+}
+
+func (t *TenantConfigs) load(ctx context.Context) map[int]TenantConfig {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tenants != nil && time.Since(t.loadedAt) < t.cacheTTL {
+		return t.tenants
+	}
+
+	raw, err := t.client.HGetAll(ctx, t.key).Result()
+	if err != nil {
+		slog.Error("Failed to load tenant config", "component", "tenants", "error", err)
+		return t.tenants
+	}
+
+	tenants := make(map[int]TenantConfig, len(raw))
+	for field, value := range raw {
+		tenantID, err := strconv.Atoi(field)
+		if err != nil {
+			slog.Warn("Ignoring tenant config with non-numeric ID", "component", "tenants", "tenant", field)
+			continue
+		}
+		var cfg TenantConfig
+		if err := json.Unmarshal([]byte(value), &cfg); err != nil {
+			slog.Warn("Ignoring malformed tenant config", "component", "tenants", "tenant", field, "error", err)
+			continue
+		}
+		tenants[tenantID] = cfg
+	}
+
+	t.tenants = tenants
+	t.loadedAt = time.Now()
+	return tenants
+}
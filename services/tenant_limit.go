@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// reserveScript takes the next slot of a tenant's rate limit with GCRA:
+// the key holds the time the tenant's next job is due, which moves on by
+// one interval per reservation. A job may run as long as that time is at
+// most burst-1 intervals ahead; otherwise it waits for its slot, which is
+// held for it, so jobs sent to wait are spread out instead of all retrying
+// at once.
+var reserveScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local tolerance = tonumber(ARGV[3])
+local due = tonumber(redis.call('GET', KEYS[1]) or now)
+if due < now then
+	due = now
+end
+local nextDue = due + interval
+redis.call('SET', KEYS[1], tostring(nextDue), 'PX', math.ceil(nextDue - now + tolerance) + 1000)
+local wait = due - tolerance - now
+if wait < 0 then
+	return 0
+end
+return math.ceil(wait)
+`)
+
+// TenantLimiter is a rate limit per tenant shared by every instance
+// through Redis, so one tenant's bulk upload can't take all the workers.
+type TenantLimiter struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewTenantLimiter(client *redis.Client, redisPrefix string) *TenantLimiter {
+	return &TenantLimiter{client: client, prefix: redisPrefix}
+}
+
+// Reserve takes the tenant's next slot at rate jobs per second, up to
+// burst at once, and returns how long the job must wait for it. A job that
+// must wait keeps its slot, so it shouldn't call Reserve again.
+func (l *TenantLimiter) Reserve(ctx context.Context, tenantID int, rate float64, burst int) (time.Duration, error) {
+	if burst < 1 {
+		burst = 1
+	}
+	interval := 1000 / rate
+	key := l.prefix + "conversion:tenant-rate:" + strconv.Itoa(tenantID)
+	waitMs, err := reserveScript.Run(ctx, l.client, []string{key},
+		time.Now().UnixMilli(), interval, interval*float64(burst-1)).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve tenant rate: %w", err)
+	}
+	return time.Duration(waitMs) * time.Millisecond, nil
+}
+
+func (l *TenantLimiter) reservationKey(reservation string) string {
+	return l.prefix + "conversion:tenant-reservation:" + reservation
+}
+
+// Hold records a slot a job waits for under a new random name, kept for
+// ttl, for the job to Redeem when it is claimed again.
+func (l *TenantLimiter) Hold(ctx context.Context, ttl time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate reservation: %w", err)
+	}
+	reservation := hex.EncodeToString(buf)
+	if err := l.client.Set(ctx, l.reservationKey(reservation), 1, ttl).Err(); err != nil {
+		return "", fmt.Errorf("failed to hold reservation: %w", err)
+	}
+	return reservation, nil
+}
+
+// Redeem reports whether the reservation was held, and releases it so it
+// counts once.
+func (l *TenantLimiter) Redeem(ctx context.Context, reservation string) (bool, error) {
+	removed, err := l.client.Del(ctx, l.reservationKey(reservation)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to redeem reservation: %w", err)
+	}
+	return removed == 1, nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// minPageCoverage is the share of a page its words must cover for the page
+// to count as having text, so a scanned page with only a stamped page
+// number doesn't.
+const minPageCoverage = 0.01
+
+var (
+	// bboxPagePattern and bboxWordPattern match the lines of pdftotext
+	// -bbox output.
+	bboxPagePattern = regexp.MustCompile(`<page width="([\d.]+)" height="([\d.]+)">`)
+	bboxWordPattern = regexp.MustCompile(`<word xMin="(-?[\d.]+)" yMin="(-?[\d.]+)" xMax="(-?[\d.]+)" yMax="(-?[\d.]+)">(.*)</word>`)
+)
+
+// TextQuality scores how far a PDF's text layer can be trusted, so search
+// can decide whether to index the extracted text or send the document for
+// OCR again. pdftotext has no OCR confidence, so the score rests on what
+// the text layer looks like.
+type TextQuality struct {
+	// Score is 0 to 100: CharacterConfidence times the share of sampled
+	// pages with text.
+	Score int `json:"score"`
+	// CharacterConfidence is the share of the text's characters that are
+	// letters, digits, punctuation or symbols, rather than the replacement,
+	// private-use and control characters left by broken font encodings
+	// and poor OCR.
+	CharacterConfidence float64 `json:"characterConfidence"`
+	// TextCoverage is the share of the sampled pages' area covered by
+	// words.
+	TextCoverage  float64 `json:"textCoverage"`
+	PagesWithText int     `json:"pagesWithText"`
+	PagesSampled  int     `json:"pagesSampled"`
+}
+
+// TextQuality scores the text layer of the PDF's first pages.
+func (t *PDFToolsService) TextQuality(ctx context.Context, pdfPath string, pages int) (*TextQuality, error) {
+	out, err := output(ctx, "pdftotext", "-bbox", "-enc", "UTF-8", "-l", strconv.Itoa(pages), pdfPath, "-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract text boxes: %w", err)
+	}
+	return scoreTextLayer(out), nil
+}
+
+// scoreTextLayer scores pdftotext -bbox output.
+func scoreTextLayer(bbox string) *TextQuality {
+	quality := &TextQuality{}
+	var pageArea, pageWords, totalArea, wordArea float64
+	var chars, readable int
+
+	endPage := func() {
+		if pageArea > 0 && pageWords/pageArea >= minPageCoverage {
+			quality.PagesWithText++
+		}
+	}
+	for _, line := range strings.Split(bbox, "\n") {
+		if m := bboxPagePattern.FindStringSubmatch(line); m != nil {
+			if quality.PagesSampled > 0 {
+				endPage()
+			}
+			quality.PagesSampled++
+			width, _ := strconv.ParseFloat(m[1], 64)
+			height, _ := strconv.ParseFloat(m[2], 64)
+			pageArea, pageWords = width*height, 0
+			totalArea += pageArea
+			continue
+		}
+		m := bboxWordPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		var box [4]float64
+		for i := range box {
+			box[i], _ = strconv.ParseFloat(m[i+1], 64)
+		}
+		if area := (box[2] - box[0]) * (box[3] - box[1]); area > 0 {
+			pageWords += area
+			wordArea += area
+		}
+		for _, r := range html.UnescapeString(m[5]) {
+			chars++
+			if readableRune(r) {
+				readable++
+			}
+		}
+	}
+	if quality.PagesSampled > 0 {
+		endPage()
+	}
+
+	if chars > 0 {
+		quality.CharacterConfidence = roundHundredths(float64(readable) / float64(chars))
+	}
+	if totalArea > 0 {
+		quality.TextCoverage = roundHundredths(wordArea / totalArea)
+	}
+	if quality.PagesSampled > 0 {
+		share := float64(quality.PagesWithText) / float64(quality.PagesSampled)
+		quality.Score = int(float64(readable)/float64(max(chars, 1))*share*100 + 0.5)
+	}
+	return quality
+}
+
+func readableRune(r rune) bool {
+	if r == unicode.ReplacementChar || unicode.Is(unicode.Co, r) {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsMark(r)
+}
+
+func roundHundredths(value float64) float64 {
+	return float64(int(value*100+0.5)) / 100
+}
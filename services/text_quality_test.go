@@ -0,0 +1,42 @@
+package services
+
+import "testing"
+
+func TestScoreTextLayer(t *testing.T) {
+	t.Parallel()
+
+	const textPage = `<page width="100.000000" height="100.000000">
+    <word xMin="10.000000" yMin="10.000000" xMax="60.000000" yMax="20.000000">Invoice</word>
+    <word xMin="10.000000" yMin="30.000000" xMax="40.000000" yMax="40.000000">&amp;</word>
+</page>
+`
+	const scannedPage = `<page width="100.000000" height="100.000000">
+    <word xMin="90.000000" yMin="95.000000" xMax="92.000000" yMax="97.000000">2</word>
+</page>
+`
+	const brokenPage = `<page width="100.000000" height="100.000000">
+    <word xMin="10.000000" yMin="10.000000" xMax="60.000000" yMax="20.000000">ab` + "\ufffd\ue000" + `</word>
+</page>
+`
+
+	tests := []struct {
+		name string
+		bbox string
+		want TextQuality
+	}{
+		{"empty", "", TextQuality{}},
+		{"clean text", textPage, TextQuality{Score: 100, CharacterConfidence: 1, TextCoverage: 0.08, PagesWithText: 1, PagesSampled: 1}},
+		{"half scanned", textPage + scannedPage, TextQuality{Score: 50, CharacterConfidence: 1, TextCoverage: 0.04, PagesWithText: 1, PagesSampled: 2}},
+		{"broken encoding", brokenPage, TextQuality{Score: 50, CharacterConfidence: 0.5, TextCoverage: 0.05, PagesWithText: 1, PagesSampled: 1}},
+		{"no text layer", `<page width="100.000000" height="100.000000">` + "\n</page>\n", TextQuality{PagesSampled: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := scoreTextLayer(tt.bbox); *got != tt.want {
+				t.Errorf("scoreTextLayer() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
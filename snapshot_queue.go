@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"time"
+
+	"converter/config"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// runSnapshotQueue implements `converter snapshot-queue`, which copies every
+// queue and status hash to storage so a lost Redis can be rebuilt with
+// `converter restore-snapshot`. It can run while workers are processing.
+func runSnapshotQueue(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("snapshot-queue", flag.ExitOnError)
+	prefix := fs.String("prefix", "queue-snapshots", "storage prefix the snapshot is written under")
+	key := fs.String("key", "", "storage key to write the snapshot to (defaults to <prefix>/<time>.json.gz)")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	redisClient, err := connectRedis(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	storage, err := services.NewStorage(ctx, cfg, services.NewS3RateLimiter(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to set up storage: %w", err)
+	}
+
+	snapshot, err := services.NewQueueAdmin(redisClient, cfg, nil).Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	if *key == "" {
+		*key = path.Join(*prefix, snapshot.TakenAt.Format("20060102T150405Z")+".json.gz")
+	}
+
+	file, err := os.CreateTemp("", "queue-snapshot-*.json.gz")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	err = services.WriteSnapshot(file, snapshot)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := storage.Upload(ctx, file.Name(), *key, "application/gzip"); err != nil {
+		return err
+	}
+	slog.Info("Queue snapshot written", "key", *key, "jobs", snapshot.Jobs(), "statuses", len(snapshot.Statuses))
+	return nil
+}
+
+// runRestoreSnapshot implements `converter restore-snapshot`, which puts the
+// jobs and status hashes of a snapshot back into Redis. It is meant for a
+// fresh Redis: queues that already hold jobs are refused without --force,
+// since restoring on top of them would queue those jobs twice.
+func runRestoreSnapshot(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("restore-snapshot", flag.ExitOnError)
+	key := fs.String("key", "", "storage key of the snapshot to restore (required)")
+	force := fs.Bool("force", false, "restore even though the queues aren't empty")
+	dryRun := fs.Bool("dry-run", false, "read the snapshot and report what it holds without restoring it")
+	fs.Parse(args)
+
+	if *key == "" {
+		return errors.New("--key is required")
+	}
+
+	ctx := context.Background()
+	storage, err := services.NewStorage(ctx, cfg, services.NewS3RateLimiter(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to set up storage: %w", err)
+	}
+
+	file, err := os.CreateTemp("", "queue-snapshot-*.json.gz")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	if err := storage.Download(ctx, *key, file.Name()); err != nil {
+		return err
+	}
+
+	file, err = os.Open(file.Name())
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot file: %w", err)
+	}
+	defer file.Close()
+	snapshot, err := services.ReadSnapshot(file)
+	if err != nil {
+		return err
+	}
+
+	for name, entries := range snapshot.Queues {
+		slog.Info("Snapshot queue", "queue", name, "jobs", len(entries))
+	}
+	slog.Info("Snapshot read", "key", *key, "taken_at", snapshot.TakenAt.Format(time.RFC3339),
+		"jobs", snapshot.Jobs(), "delayed", len(snapshot.Delayed), "statuses", len(snapshot.Statuses))
+	if *dryRun {
+		return nil
+	}
+
+	redisClient, err := connectRedis(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer redisClient.Close()
+
+	if err := services.NewQueueAdmin(redisClient, cfg, nil).RestoreSnapshot(ctx, snapshot, *force); err != nil {
+		return err
+	}
+	slog.Info("Queue snapshot restored", "key", *key, "jobs", snapshot.Jobs())
+	return nil
+}
+
+func connectRedis(ctx context.Context, cfg *config.Config) (*redis.Client, error) {
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		redisClient.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return redisClient, nil
+}
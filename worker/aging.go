@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"converter/metrics"
+	"converter/models"
+)
+
+const agingScanSize = 100
+
+func init() {
+	metrics.Describe("conversion_priority_promotions_total", "Low priority jobs promoted to the pending queue after waiting past the aging threshold")
+}
+
+// AgingLoop periodically promotes low priority jobs that have waited longer
+// than the aging threshold, so continuous normal traffic can't starve them.
+func (p *Pool) AgingLoop(ctx context.Context) {
+	interval := time.Duration(p.config.PriorityAgingInterval) * time.Second
+	if interval <= 0 || p.config.PriorityAgingThreshold <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	slog.Info("Starting priority aging loop", "component", "aging")
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Priority aging loop shutting down", "component", "aging")
+			return
+		case <-ticker.C:
+			p.promoteAgedJobs(ctx)
+		}
+	}
+}
+
+func (p *Pool) promoteAgedJobs(ctx context.Context) {
+	if !p.IsActive() || p.source != nil {
+		return
+	}
+
+	threshold := time.Duration(p.config.PriorityAgingThreshold) * time.Second
+	if p.jobQueue.Streams() {
+		p.recordPromotions(p.promoteAgedStreamJobs(ctx, threshold))
+		return
+	}
+
+	// Producers LPUSH, so the oldest jobs sit at the tail of the list
+	jobs, err := p.redisClient.LRange(ctx, p.config.LowPriorityQueue, -agingScanSize, -1).Result()
+	if err != nil {
+		slog.Error("Failed to read low priority queue", "component", "aging", "error", err)
+		return
+	}
+
+	promoted := 0
+	for i := len(jobs) - 1; i >= 0; i-- {
+		var job models.ConversionJob
+		if err := json.Unmarshal([]byte(jobs[i]), &job); err != nil {
+			continue
+		}
+		if job.CreatedAt.IsZero() || time.Since(job.CreatedAt) < threshold {
+			continue
+		}
+
+		removed, err := p.redisClient.LRem(ctx, p.config.LowPriorityQueue, 1, jobs[i]).Result()
+		if err != nil || removed == 0 {
+			// Claimed by a worker in the meantime
+			continue
+		}
+
+		// RPUSH onto the tail so the promoted job is claimed next
+		if err := p.redisClient.RPush(ctx, p.config.PendingQueue, jobs[i]).Err(); err != nil {
+			slog.Error("Failed to promote conversion, restoring", "component", "aging", "conversion_id", job.ConversionID, "error", err)
+			p.redisClient.RPush(ctx, p.config.LowPriorityQueue, jobs[i])
+			continue
+		}
+		promoted++
+	}
+
+	p.recordPromotions(promoted)
+}
+
+func (p *Pool) recordPromotions(promoted int) {
+	if promoted > 0 {
+		metrics.Add("conversion_priority_promotions_total", int64(promoted))
+		slog.Info("Promoted low priority jobs", "component", "aging", "promoted", promoted)
+	}
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_annotations_total", "Annotations recorded on completed jobs, by code")
+}
+
+// inspectSource reads the input before conversion for content the PDF won't
+// carry. Inspection is best effort: a failure is logged and the job goes on
+// without annotations.
+func (p *Pool) inspectSource(ctx context.Context, localPath string, extension string) *services.SourceInspection {
+	if !p.config.Annotations {
+		return nil
+	}
+	inspection, err := services.InspectSource(localPath, extension)
+	if err != nil {
+		logging.From(ctx).Warn("Source inspection failed", "error", err)
+		return nil
+	}
+	return inspection
+}
+
+// annotate combines the source inspection with what the output shows and
+// what became of email attachments into the job's annotations.
+func (p *Pool) annotate(ctx context.Context, inspection *services.SourceInspection, pdfPath string, attachments []attachmentResult) []models.Annotation {
+	if inspection == nil {
+		return nil
+	}
+	annotations := inspection.Annotations
+
+	if len(inspection.Fonts) > 0 {
+		embedded, err := p.pdfTools.Fonts(ctx, pdfPath)
+		if err != nil {
+			logging.From(ctx).Warn("Font check failed", "error", err)
+		} else if missing, replacements := services.FontSubstitution(inspection.Fonts, embedded); missing != nil {
+			annotations = append(annotations, models.Annotation{
+				Code:    models.AnnotationFontSubstituted,
+				Message: fmt.Sprintf("Fonts not available were substituted: %s (rendered with %s)", strings.Join(missing, ", "), strings.Join(replacements, ", ")),
+			})
+		}
+	}
+
+	var omitted []string
+	for _, a := range attachments {
+		if !a.Appended {
+			omitted = append(omitted, a.Name)
+		}
+	}
+	if len(omitted) > 0 {
+		annotations = append(annotations, models.Annotation{
+			Code:    models.AnnotationAttachmentsOmitted,
+			Message: "Attachments are not included in the PDF: " + strings.Join(omitted, ", "),
+		})
+	}
+
+	for _, a := range annotations {
+		metrics.Inc("conversion_annotations_total", "code", string(a.Code))
+	}
+	return annotations
+}
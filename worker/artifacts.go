@@ -0,0 +1,184 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"converter/models"
+	"converter/services"
+)
+
+const defaultThumbnailWidth = 320
+
+type renderedArtifact struct {
+	artifact    models.OutputArtifact
+	localPath   string
+	contentType string
+}
+
+// validateOutputs checks a job's extra outputs before anything is
+// downloaded, since a bad one would otherwise only fail after the
+// conversion and be retried to no end. Each needs a known kind, and a
+// destination key unless it goes into the bundle.
+func validateOutputs(job *models.ConversionJob) (models.RejectionReason, string) {
+	for _, artifact := range job.Outputs {
+		if !artifact.Kind.Valid() {
+			return models.RejectMalformed, fmt.Sprintf("unsupported artifact kind %q", artifact.Kind)
+		}
+		if artifact.S3Path == "" && !job.Bundle {
+			return models.RejectMalformed, fmt.Sprintf("artifact %q has no destination key", artifact.Kind)
+		}
+	}
+	return "", ""
+}
+
+// produceArtifacts derives the job's extra outputs from the converted PDF and
+// uploads each to its destination key. Returns kind -> key for metadata.
+func (p *Pool) produceArtifacts(ctx context.Context, job *models.ConversionJob, pdfPath string, dataKey *services.DataKey) (map[string]string, error) {
+	if len(job.Outputs) == 0 {
+		return nil, nil
+	}
+
+	rendered, err := p.renderArtifacts(ctx, job.Outputs, pdfPath)
+	defer p.cleanupArtifacts(rendered, pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded := make(map[string]string, len(rendered))
+	for _, r := range rendered {
+		if r.artifact.S3Path == "" {
+			return nil, fmt.Errorf("artifact %q has no destination key", r.artifact.Kind)
+		}
+		if err := p.uploadOutput(ctx, dataKey, r.localPath, r.artifact.S3Path, r.contentType); err != nil {
+			return nil, fmt.Errorf("artifact %q upload failed: %w", r.artifact.Kind, err)
+		}
+		uploaded[string(r.artifact.Kind)] = r.artifact.S3Path
+	}
+
+	return uploaded, nil
+}
+
+// uploadBundle packages the converted PDF/A, any split parts (under parts/)
+// and all extra artifacts into one ZIP (with manifest.json) uploaded to the
+// job's output key.
+func (p *Pool) uploadBundle(ctx context.Context, job *models.ConversionJob, pdfPath string, splitParts []string, dataKey *services.DataKey) ([]services.BundleEntry, error) {
+	rendered, err := p.renderArtifacts(ctx, job.Outputs, pdfPath)
+	defer p.cleanupArtifacts(rendered, pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &services.BundleManifest{
+		ConversionID: job.ConversionID,
+		FileGUID:     job.FileGUID,
+		CreatedAt:    time.Now().UTC(),
+		Entries: []services.BundleEntry{{
+			Name:        "document.pdf",
+			Kind:        string(models.ArtifactPDFA),
+			ContentType: "application/pdf",
+			LocalPath:   pdfPath,
+		}},
+	}
+
+	for i, part := range splitParts {
+		manifest.Entries = append(manifest.Entries, services.BundleEntry{
+			Name:        "parts/" + splitPartName(i),
+			Kind:        "split",
+			ContentType: "application/pdf",
+			LocalPath:   part,
+		})
+	}
+
+	for _, r := range rendered {
+		manifest.Entries = append(manifest.Entries, services.BundleEntry{
+			Name:        bundleEntryName(r),
+			Kind:        string(r.artifact.Kind),
+			ContentType: r.contentType,
+			LocalPath:   r.localPath,
+		})
+	}
+
+	zipPath := pdfPath + ".bundle.zip"
+	defer p.storage.Cleanup(zipPath)
+
+	if err := services.WriteBundle(zipPath, manifest); err != nil {
+		return nil, err
+	}
+	if err := p.uploadOutput(ctx, dataKey, zipPath, job.OutputS3Path, "application/zip"); err != nil {
+		return nil, err
+	}
+
+	return manifest.Entries, nil
+}
+
+func bundleEntryName(r renderedArtifact) string {
+	if r.artifact.S3Path != "" {
+		return path.Base(r.artifact.S3Path)
+	}
+	switch r.artifact.Kind {
+	case models.ArtifactText:
+		return "text.txt"
+	case models.ArtifactThumbnail:
+		return "thumbnail.png"
+	default:
+		return string(r.artifact.Kind) + ".pdf"
+	}
+}
+
+func (p *Pool) renderArtifacts(ctx context.Context, outputs []models.OutputArtifact, pdfPath string) ([]renderedArtifact, error) {
+	rendered := make([]renderedArtifact, 0, len(outputs))
+	for _, artifact := range outputs {
+		localPath, contentType, err := p.renderArtifact(ctx, artifact, pdfPath)
+		if err != nil {
+			return rendered, err
+		}
+		rendered = append(rendered, renderedArtifact{
+			artifact:    artifact,
+			localPath:   localPath,
+			contentType: contentType,
+		})
+	}
+	return rendered, nil
+}
+
+func (p *Pool) cleanupArtifacts(rendered []renderedArtifact, pdfPath string) {
+	for _, r := range rendered {
+		if r.localPath != pdfPath {
+			p.storage.Cleanup(r.localPath)
+		}
+	}
+}
+
+func (p *Pool) renderArtifact(ctx context.Context, artifact models.OutputArtifact, pdfPath string) (string, string, error) {
+	switch artifact.Kind {
+	case models.ArtifactPDFA, models.ArtifactPDF:
+		// The PDF/A output is a valid PDF, so both kinds reuse the single conversion
+		return pdfPath, "application/pdf", nil
+	case models.ArtifactText:
+		path, err := p.pdfTools.ExtractText(ctx, pdfPath)
+		return path, "text/plain; charset=utf-8", err
+	case models.ArtifactThumbnail:
+		width := artifact.Width
+		if width <= 0 {
+			width = defaultThumbnailWidth
+		}
+		path, err := p.pdfTools.RenderThumbnail(ctx, pdfPath, width)
+		return path, "image/png", err
+	case models.ArtifactPreview:
+		dpi := artifact.DPI
+		if dpi <= 0 || dpi > p.config.PreviewDPI {
+			dpi = p.config.PreviewDPI
+		}
+		watermark := artifact.Watermark
+		if watermark == "" {
+			watermark = p.config.PreviewWatermark
+		}
+		path, err := p.imagingSvc.Preview(ctx, pdfPath, dpi, watermark)
+		return path, "application/pdf", err
+	default:
+		return "", "", fmt.Errorf("unsupported artifact kind %q", artifact.Kind)
+	}
+}
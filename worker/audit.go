@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"log/slog"
+	"time"
+
+	"converter/models"
+	"converter/services"
+)
+
+const (
+	auditEngine            = "gotenberg-libreoffice"
+	markdownAuditEngine    = "gotenberg-chromium-markdown"
+	imageAuditEngine       = "imagemagick-gotenberg-pdfengines"
+	emailAuditEngine       = "gotenberg-chromium-email"
+	pdfAuditEngine         = "gotenberg-pdfengines"
+	passthroughAuditEngine = "passthrough"
+	mergeAuditEngine       = "gotenberg-pdfengines-merge"
+	sofficeAuditEngine     = "soffice-local"
+	htmlAuditEngine        = "gotenberg-chromium-html"
+)
+
+func newAuditRecord(workerID int, job *models.ConversionJob) *services.AuditRecord {
+	return &services.AuditRecord{
+		ConversionID: job.ConversionID,
+		FileID:       job.FileID,
+		FileGUID:     job.FileGUID,
+		UserID:       job.UserID,
+		Engine:       auditEngine,
+		WorkerID:     workerID,
+		InputS3Path:  job.InputS3Path,
+		InputS3Paths: redactInputs(job.InputS3Paths),
+		RetryCount:   job.RetryCount,
+		Priority:     string(job.Priority.Normalize()),
+		EnqueuedAt:   job.CreatedAt,
+		StartedAt:    time.Now(),
+	}
+}
+
+// redactInputs drops the queries of input URIs, which may carry
+// signatures, from the audit trail.
+func redactInputs(inputs []string) []string {
+	if inputs == nil {
+		return nil
+	}
+	redacted := make([]string, len(inputs))
+	for i, input := range inputs {
+		redacted[i] = services.RedactInput(input)
+	}
+	return redacted
+}
+
+// checksum hashes a local file for the audit trail; skipped when auditing
+// is disabled so the hot path doesn't pay for it.
+func (p *Pool) checksum(localPath string) string {
+	if p.audits == nil {
+		return ""
+	}
+	sum, err := services.FileSHA256(localPath)
+	if err != nil {
+		slog.Warn("Failed to checksum file for audit", "component", "audit", "path", localPath, "error", err)
+		return ""
+	}
+	return sum
+}
+
+// recordAudit queues the record with its outcome for the background audit
+// writer.
+func (p *Pool) recordAudit(record *services.AuditRecord, outcome string) {
+	if p.audits == nil {
+		return
+	}
+	record.Outcome = outcome
+	p.audits.Record(record)
+}
+
+// CloseAudit writes the audit records still queued, once the workers have
+// stopped.
+func (p *Pool) CloseAudit() {
+	if p.audits != nil {
+		p.audits.Close()
+	}
+}
@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"converter/metrics"
+)
+
+const (
+	backpressureInterval = time.Second
+	// Claiming resumes once the RSS is back under this share of
+	// CONVERSION_MAX_RSS_BYTES, so it doesn't flap around the limit
+	rssResumeRatio = 0.9
+)
+
+func init() {
+	metrics.Describe("conversion_in_flight", "Claims being handled, including ones waiting for a Gotenberg webhook")
+	metrics.Describe("conversion_rss_bytes", "Resident set size of the worker process")
+	metrics.Describe("conversion_memory_pressure", "1 while the RSS is above CONVERSION_MAX_RSS_BYTES and workers don't claim jobs")
+}
+
+// beginClaim counts a claim as in flight until the returned func is
+// called, and as abandoned if the shutdown interrupted it before it was
+// acked.
+func (p *Pool) beginClaim(ctx context.Context, result string) func() {
+	metrics.Set("conversion_in_flight", p.inFlight.Add(1))
+	p.unacked.Store(result, struct{}{})
+	return func() {
+		if _, unacked := p.unacked.LoadAndDelete(result); unacked && ctx.Err() != nil {
+			p.counters.abandoned.Add(1)
+		}
+		metrics.Set("conversion_in_flight", p.inFlight.Add(-1))
+	}
+}
+
+// saturated reports whether claiming now would take on more than the
+// instance can hold: CONVERSION_MAX_IN_FLIGHT claims, or a process over
+// CONVERSION_MAX_RSS_BYTES. Workers check before they claim, so several
+// idle ones can overshoot the in-flight limit by one claim each.
+func (p *Pool) saturated() bool {
+	if p.config.MaxInFlight > 0 && p.inFlight.Load() >= int64(p.config.MaxInFlight) {
+		return true
+	}
+	return p.memoryPressure.Load()
+}
+
+// MemoryPressureLoop stops the workers from claiming while the process's
+// RSS is above CONVERSION_MAX_RSS_BYTES, before a large spreadsheet gets
+// the pod OOM-killed mid-batch. Jobs already running finish, which is what
+// brings the RSS back down.
+func (p *Pool) MemoryPressureLoop(ctx context.Context) {
+	ticker := time.NewTicker(backpressureInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.checkMemory(); err != nil {
+			slog.Warn("Failed to read RSS, not guarding memory", "component", "memory", "error", err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkMemory updates the memory pressure flag.
+func (p *Pool) checkMemory() error {
+	rss, err := readRSS()
+	if err != nil {
+		return err
+	}
+	metrics.Set("conversion_rss_bytes", rss)
+
+	pressure := p.memoryPressure.Load()
+	if pressure {
+		pressure = float64(rss) >= float64(p.config.MaxRSSBytes)*rssResumeRatio
+	} else {
+		pressure = rss >= p.config.MaxRSSBytes
+	}
+	if pressure == p.memoryPressure.Load() {
+		return nil
+	}
+	p.memoryPressure.Store(pressure)
+	if pressure {
+		metrics.Set("conversion_memory_pressure", 1)
+		slog.Warn("Worker memory is nearly exhausted, not claiming jobs", "component", "memory",
+			"rss_bytes", rss, "max_rss_bytes", p.config.MaxRSSBytes, "in_flight", p.inFlight.Load())
+	} else {
+		metrics.Set("conversion_memory_pressure", 0)
+		slog.Info("Worker memory has room again, claiming jobs", "component", "memory", "rss_bytes", rss)
+	}
+	return nil
+}
+
+// readRSS reads the process's resident set size from /proc.
+func readRSS() (int64, error) {
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read /proc/self/statm: %w", err)
+	}
+	fields := strings.Fields(string(statm))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("unexpected /proc/self/statm: %q", statm)
+	}
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected /proc/self/statm: %q", statm)
+	}
+	return pages * int64(os.Getpagesize()), nil
+}
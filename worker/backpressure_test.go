@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"converter/config"
+)
+
+func TestCheckMemory(t *testing.T) {
+	t.Parallel()
+
+	rss, err := readRSS()
+	if err != nil {
+		t.Skipf("no RSS on this platform: %v", err)
+	}
+
+	cfg := &config.Config{MaxRSSBytes: 1}
+	p := &Pool{config: cfg}
+	if err := p.checkMemory(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.memoryPressure.Load() || !p.saturated() {
+		t.Fatalf("no memory pressure at %d bytes RSS with a 1 byte limit", rss)
+	}
+
+	// Just under the limit is still too close to resume
+	cfg.MaxRSSBytes = rss + rss/20
+	if err := p.checkMemory(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.memoryPressure.Load() {
+		t.Fatal("memory pressure lifted within the resume margin")
+	}
+
+	cfg.MaxRSSBytes = math.MaxInt64
+	if err := p.checkMemory(); err != nil {
+		t.Fatal(err)
+	}
+	if p.memoryPressure.Load() || p.saturated() {
+		t.Fatal("memory pressure with plenty of room")
+	}
+}
+
+func TestSaturatedInFlight(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{config: &config.Config{MaxInFlight: 2}}
+	first := p.beginClaim(context.Background(), "first")
+	if p.saturated() {
+		t.Fatal("saturated with one of two claims in flight")
+	}
+	second := p.beginClaim(context.Background(), "second")
+	if !p.saturated() {
+		t.Fatal("not saturated with two of two claims in flight")
+	}
+	first()
+	second()
+	if p.saturated() {
+		t.Fatal("saturated after the claims finished")
+	}
+}
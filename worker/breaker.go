@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"time"
+
+	"converter/services"
+)
+
+// breakerPollInterval is how often a worker held back by an open circuit
+// breaker checks it again.
+const breakerPollInterval = time.Second
+
+// setupBreakers puts a circuit breaker around Gotenberg and, when outputs go
+// to S3, the bucket, so an outage stops claims instead of burning through
+// every job's retries. CIRCUIT_BREAKER_THRESHOLD 0 disables them.
+func (p *Pool) setupBreakers() {
+	if p.config.BreakerThreshold <= 0 {
+		return
+	}
+	cooldown := time.Duration(p.config.BreakerCooldown) * time.Second
+
+	gotenberg := services.NewBreaker("gotenberg", p.config.BreakerThreshold, cooldown)
+	p.gotenbergSvc.SetBreaker(gotenberg)
+	p.breakers = append(p.breakers, gotenberg)
+
+	if s3, ok := p.storage.(*services.S3Service); ok {
+		bucket := services.NewBreaker("s3", p.config.BreakerThreshold, cooldown)
+		s3.SetBreaker(bucket)
+		p.breakers = append(p.breakers, bucket)
+	}
+}
+
+// breakerOpen reports whether a breaker keeps the worker from claiming. A
+// half-open breaker lets one claim through as the probe.
+func (p *Pool) breakerOpen() bool {
+	for _, breaker := range p.breakers {
+		if !breaker.Allow() {
+			return true
+		}
+	}
+	return false
+}
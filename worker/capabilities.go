@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+)
+
+// Capabilities describes what this deployment accepts. Every job kind is
+// listed whatever CONVERSION_JOB_KINDS says, because a kind this instance
+// doesn't take is handed on rather than refused. The Gotenberg version must
+// be set first.
+func (p *Pool) Capabilities() *services.Capabilities {
+	caps := &services.Capabilities{
+		SchemaVersion:    services.JobSchemaVersion,
+		Version:          config.Version,
+		Extensions:       p.routedExtensions(),
+		JobTypes:         []string{string(models.JobTypeConvert), string(models.JobTypeMerge), string(models.JobTypeTrash), string(models.JobTypeRestore)},
+		JobKinds:         services.JobKinds(),
+		GotenbergVersion: p.gotenbergSvc.APIVersion(),
+		PDFAConformance:  p.config.PDFAConformance,
+		MaxInputBytes:    p.config.MaxInputBytes,
+		InputSources:     p.config.InputSources,
+		PublishedAt:      time.Now().UTC(),
+		Features: []string{
+			services.FeatureMerge,
+			services.FeatureEmail,
+			services.FeatureHTMLAssets,
+			services.FeaturePrintTemplates,
+			services.FeatureOutputs,
+			services.FeatureBundle,
+			services.FeatureThumbnails,
+			services.FeatureEncryption,
+			services.FeatureCallbacks,
+			services.FeatureDeadlines,
+			services.FeatureTrash,
+		},
+	}
+
+	// Gotenberg 7 can't produce these, and jobs asking for them are rejected
+	if p.gotenbergSvc.MissingFeature(true, false, false) == "" {
+		caps.Features = append(caps.Features, services.FeatureAccessible)
+	}
+	if p.gotenbergSvc.MissingFeature(false, true, false) == "" {
+		caps.Features = append(caps.Features, services.FeatureFlatten)
+	}
+	if p.gotenbergSvc.MissingFeature(false, false, true) == "" {
+		caps.Features = append(caps.Features, services.FeatureSplit)
+	}
+	if p.gotenbergSvc.APIVersion() != services.GotenbergV7 {
+		caps.Features = append(caps.Features, services.FeaturePassword)
+	}
+	if p.config.DetectFormat {
+		caps.Features = append(caps.Features, services.FeatureFormatDetection)
+	}
+	if p.scanner != nil {
+		caps.Features = append(caps.Features, services.FeatureVirusScan)
+	}
+	if len(p.retention) > 0 {
+		caps.Features = append(caps.Features, services.FeatureRetention)
+		for class := range p.retention {
+			caps.RetentionClasses = append(caps.RetentionClasses, class)
+		}
+		sort.Strings(caps.RetentionClasses)
+	}
+	return caps
+}
+
+// routedExtensions lists the extensions jobs are accepted for: those of
+// CONVERSION_SUPPORTED_EXTENSIONS that have a route, or every routed one.
+func (p *Pool) routedExtensions() []string {
+	if len(p.config.SupportedExtensions) == 0 {
+		return p.routes.Extensions()
+	}
+	extensions := []string{}
+	for _, ext := range p.config.SupportedExtensions {
+		if p.routes.For(ext) != services.RouteUnsupported {
+			extensions = append(extensions, ext)
+		}
+	}
+	return extensions
+}
+
+// PublishCapabilities writes the deployment's capabilities to the
+// conversion:capabilities key for producers. A failure is logged, not
+// fatal: producers fall back to their own assumptions.
+func (p *Pool) PublishCapabilities(ctx context.Context) {
+	key := services.CapabilitiesKey(p.config.RedisPrefix)
+	caps := p.Capabilities()
+	if err := services.PublishCapabilities(ctx, p.redisClient, key, caps); err != nil {
+		slog.Warn("Failed to publish capabilities", "component", "capabilities", "key", key, "error", err)
+		return
+	}
+	slog.Info("Published capabilities", "component", "capabilities", "key", key, "schema_version", caps.SchemaVersion, "features", len(caps.Features))
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"testing"
+
+	"converter/config"
+	"converter/services"
+)
+
+func TestCapabilities(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{SupportedExtensions: []string{"docx", "html"}, DetectFormat: true}
+	pool := func(gotenbergVersion int) *Pool {
+		p := &Pool{config: cfg, gotenbergSvc: services.NewGotenbergService("http://gotenberg:3000", 0, services.RequestIdentity{})}
+		if err := p.gotenbergSvc.SetAPIVersion(gotenbergVersion); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	v8 := pool(services.GotenbergV8)
+	v8.SetRetentionClasses(map[string]services.Retention{"7y": {Class: "7y"}, "90d": {Class: "90d"}})
+	caps := v8.Capabilities()
+	if caps.SchemaVersion != services.JobSchemaVersion || caps.GotenbergVersion != services.GotenbergV8 {
+		t.Errorf("unexpected versions %+v", caps)
+	}
+	if len(caps.JobKinds) != len(services.JobKinds()) || len(caps.Extensions) != 2 {
+		t.Errorf("unexpected kinds %v or extensions %v", caps.JobKinds, caps.Extensions)
+	}
+	for _, feature := range []string{services.FeatureSplit, services.FeatureAccessible, services.FeaturePassword, services.FeatureFormatDetection, services.FeatureRetention} {
+		if !caps.Supports(feature) {
+			t.Errorf("missing feature %s in %v", feature, caps.Features)
+		}
+	}
+	if caps.Supports(services.FeatureVirusScan) {
+		t.Error("virus scanning announced without a scanner")
+	}
+	if len(caps.RetentionClasses) != 2 || caps.RetentionClasses[0] != "7y" {
+		t.Errorf("retention classes = %v", caps.RetentionClasses)
+	}
+
+	v7 := pool(services.GotenbergV7).Capabilities()
+	if v7.Supports(services.FeatureSplit) || v7.Supports(services.FeatureAccessible) || v7.Supports(services.FeatureFlatten) || v7.Supports(services.FeaturePassword) {
+		t.Errorf("gotenberg 7 announced output options it can't produce: %v", v7.Features)
+	}
+	if !v7.Supports(services.FeatureMerge) {
+		t.Error("merge must not depend on the gotenberg version")
+	}
+
+	// Without a supported list, every routed extension is accepted
+	all := &Pool{config: &config.Config{}, gotenbergSvc: v8.gotenbergSvc}
+	if extensions := all.Capabilities().Extensions; len(extensions) < len(cfg.SupportedExtensions) || extensions[0] == "" {
+		t.Errorf("routed extensions = %v", extensions)
+	}
+	if all.extensionSupported("xyz") || !all.extensionSupported("vsdx") {
+		t.Error("an empty supported list must still follow the routes")
+	}
+}
@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+// chromiumDebugTimeout bounds the screenshot renders and uploads of a
+// debug capture.
+const chromiumDebugTimeout = 30 * time.Second
+
+func init() {
+	metrics.Describe("conversion_chromium_debug_captures_total", "Debug captures of failed Chromium prints, by result (stored, failed)")
+}
+
+// debugCaptured is a failed Chromium print whose debug artifacts were
+// stored under prefix. Its message names the prefix, so the error recorded
+// for the job leads to them.
+type debugCaptured struct {
+	err    error
+	prefix string
+}
+
+func (e *debugCaptured) Error() string {
+	return e.err.Error() + " (debug artifacts: " + e.prefix + ")"
+}
+
+func (e *debugCaptured) Unwrap() error {
+	return e.err
+}
+
+// captureChromiumDebug stores what helps explain a failed Chromium print
+// under CHROMIUM_DEBUG_PREFIX<conversion id>/attempt-<n>/: a screenshot of
+// the page, the exceptions it logged to the console and the error. It
+// returns convErr, wrapped in a *debugCaptured when anything was stored.
+// screenshot renders the page like the failed print did.
+func (p *Pool) captureChromiumDebug(ctx context.Context, job *models.ConversionJob, convErr error, localPath string, screenshot func(ctx context.Context, outputPath string, failOnConsole bool) error) error {
+	if !p.config.ChromiumDebugCapture || errors.Is(convErr, context.Canceled) {
+		return convErr
+	}
+	logger := logging.From(ctx)
+
+	// The job's deadline may be what failed the print, and debug objects
+	// must not inherit the outputs' retention lock
+	captureCtx, cancel := context.WithTimeout(logging.WithTraceID(context.Background(), logging.TraceID(ctx)), chromiumDebugTimeout)
+	defer cancel()
+
+	prefix := fmt.Sprintf("%s%d/attempt-%d/", p.config.ChromiumDebugPrefix, job.ConversionID, job.RetryCount+1)
+	screenshotPath := localPath + ".debug.png"
+	defer os.Remove(screenshotPath)
+
+	// A page that logged exceptions fails the first render with them, and
+	// is rendered again for the screenshot
+	files := map[string]string{"error.txt": convErr.Error() + "\n"}
+	err := screenshot(captureCtx, screenshotPath, true)
+	if errors.Is(err, services.ErrConsoleExceptions) {
+		files["console.txt"] = err.Error() + "\n"
+		err = screenshot(captureCtx, screenshotPath, false)
+	} else if err == nil {
+		files["console.txt"] = "no console exceptions\n"
+	}
+
+	stored := 0
+	if err != nil {
+		logger.Warn("Failed to take debug screenshot", "error", err)
+	} else if err := p.storage.Upload(captureCtx, screenshotPath, prefix+"screenshot.png", "image/png"); err != nil {
+		logger.Warn("Failed to store debug screenshot", "error", err)
+	} else {
+		stored++
+	}
+	for name, content := range files {
+		if err := p.storage.UploadStream(captureCtx, strings.NewReader(content), prefix+name, "text/plain; charset=utf-8"); err != nil {
+			logger.Warn("Failed to store debug artifact", "artifact", name, "error", err)
+			continue
+		}
+		stored++
+	}
+
+	if stored == 0 {
+		metrics.Inc("conversion_chromium_debug_captures_total", "result", "failed")
+		return convErr
+	}
+	metrics.Inc("conversion_chromium_debug_captures_total", "result", "stored")
+	logger.Info("Stored Chromium debug artifacts", "prefix", prefix)
+	return &debugCaptured{err: convErr, prefix: prefix}
+}
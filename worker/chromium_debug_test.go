@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+)
+
+func TestCaptureChromiumDebug(t *testing.T) {
+	t.Parallel()
+
+	// The page logged an exception: the render failing on them reports it,
+	// the plain one returns the screenshot
+	gotenberg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/forms/chromium/screenshot/html" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.FormValue("failOnConsoleExceptions") == "true" {
+			http.Error(w, "Chromium console exceptions: TypeError: chart is undefined", http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG screenshot"))
+	}))
+	defer gotenberg.Close()
+
+	root := t.TempDir()
+	storage, err := services.NewLocalStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(t.TempDir(), "index.html")
+	if err := os.WriteFile(page, []byte("<html></html>"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	job := &models.ConversionJob{ConversionID: 4711, RetryCount: 1}
+	convErr := errors.New("gotenberg returned status 503: timeout")
+	screenshot := func(svc *services.GotenbergService) func(context.Context, string, bool) error {
+		return func(ctx context.Context, outputPath string, failOnConsole bool) error {
+			return svc.ScreenshotHTML(ctx, page, nil, outputPath, failOnConsole)
+		}
+	}
+
+	p := &Pool{
+		config:       &config.Config{ChromiumDebugCapture: true, ChromiumDebugPrefix: "debug/"},
+		storage:      storage,
+		gotenbergSvc: services.NewGotenbergService(gotenberg.URL, 0, services.RequestIdentity{}),
+	}
+	err = p.captureChromiumDebug(context.Background(), job, convErr, page, screenshot(p.gotenbergSvc))
+	if !errors.Is(err, convErr) || !strings.Contains(err.Error(), "debug/4711/attempt-2/") {
+		t.Fatalf("error = %v", err)
+	}
+	want := map[string]string{
+		"screenshot.png": "\x89PNG screenshot",
+		"console.txt":    "TypeError: chart is undefined",
+		"error.txt":      convErr.Error(),
+	}
+	for name, content := range want {
+		stored, err := os.ReadFile(filepath.Join(root, "debug/4711/attempt-2", name))
+		if err != nil || !strings.Contains(string(stored), content) {
+			t.Errorf("%s = %q, %v", name, stored, err)
+		}
+	}
+
+	// Off by default
+	p.config = &config.Config{}
+	if err := p.captureChromiumDebug(context.Background(), job, convErr, page, screenshot(p.gotenbergSvc)); err != convErr {
+		t.Errorf("capture without CHROMIUM_DEBUG_CAPTURE: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tagClaimScript swaps one untagged copy of the claimed payload in the
+// processing list (KEYS[1]) for the tagged copy. Untagged copies are
+// interchangeable, so which one is removed doesn't matter. Returns 0 if the
+// payload is no longer there, e.g. recovery already reclaimed it.
+var tagClaimScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 1 then
+	redis.call('LPUSH', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// tagClaim embeds a unique claim token in the payload the worker just moved
+// to the processing list. Every later LRem acks with the tagged payload, so
+// two identical jobs in flight can never remove each other's entry. Payloads
+// that aren't JSON objects are left as they are and rejected as malformed.
+// On error the untagged payload is returned so the job still runs.
+//
+// Stream entries and source messages are tagged with their entry ID or
+// delivery handle when claimed, so there is nothing left to do for them
+// here.
+func (p *Pool) tagClaim(ctx context.Context, raw string) (string, bool, error) {
+	if !p.config.ClaimTokens || p.jobQueue.Streams() || p.source != nil {
+		return raw, true, nil
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return raw, true, err
+	}
+
+	tagged, isObject := withClaimToken(raw, hex.EncodeToString(token))
+	if !isObject {
+		return raw, true, nil
+	}
+
+	ok, err := tagClaimScript.Run(ctx, p.redisClient, []string{p.config.ProcessingQueue}, raw, tagged).Int()
+	if err != nil {
+		return raw, true, err
+	}
+	return tagged, ok == 1, nil
+}
+
+// ack removes a claimed job from processing once it has finished or been
+// handed to another queue, and reports whether it was still there. On the
+// stream backend the entry is acked and deleted by the ID in its claim
+// token, and a source message is deleted by its delivery handle; on the
+// list backend the processing entry is removed by value.
+func (p *Pool) ack(ctx context.Context, jobJSON string) (int64, error) {
+	removed, err := p.removeClaim(ctx, jobJSON)
+	if err == nil {
+		p.unacked.Delete(jobJSON)
+	}
+	return removed, err
+}
+
+func (p *Pool) removeClaim(ctx context.Context, jobJSON string) (int64, error) {
+	if p.source != nil {
+		handle, ok := sourceHandle(jobJSON)
+		if !ok {
+			return 0, nil
+		}
+		return p.ackSource(ctx, jobJSON, handle)
+	}
+	if p.jobQueue.Streams() {
+		key, id, ok := streamClaim(jobJSON)
+		if !ok {
+			return 0, nil
+		}
+		return p.ackStream(ctx, key, id)
+	}
+	return p.redisClient.LRem(ctx, p.config.ProcessingQueue, 1, jobJSON).Result()
+}
+
+// withClaimToken prepends a claimToken field to a JSON object payload rather
+// than re-marshalling, so the producer's payload is preserved byte for byte.
+func withClaimToken(raw string, token string) (string, bool) {
+	body := strings.TrimLeft(raw, " \t\r\n")
+	if !strings.HasPrefix(body, "{") {
+		return raw, false
+	}
+
+	rest := strings.TrimLeft(body[1:], " \t\r\n")
+	separator := ","
+	if strings.HasPrefix(rest, "}") {
+		separator = ""
+	}
+	return `{"claimToken":"` + token + `"` + separator + rest, true
+}
+
+// withoutClaimToken restores the producer's payload before a tagged entry
+// is handed to another queue, so tokens don't pile up across claims.
+func withoutClaimToken(jobJSON string) string {
+	_, payload, ok := splitClaimToken(jobJSON)
+	if !ok {
+		return jobJSON
+	}
+	return payload
+}
+
+// splitClaimToken separates a tagged payload into its claim token and the
+// producer's payload.
+func splitClaimToken(jobJSON string) (token string, payload string, ok bool) {
+	const prefix = `{"claimToken":"`
+	if !strings.HasPrefix(jobJSON, prefix) {
+		return "", jobJSON, false
+	}
+	end := strings.Index(jobJSON[len(prefix):], `"`)
+	if end < 0 {
+		return "", jobJSON, false
+	}
+	rest := strings.TrimPrefix(jobJSON[len(prefix)+end+1:], ",")
+	return jobJSON[len(prefix) : len(prefix)+end], "{" + rest, true
+}
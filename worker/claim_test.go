@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"converter/models"
+)
+
+func TestWithClaimToken(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		raw  string
+		want string
+		ok   bool
+	}{
+		{`{"conversionId":7}`, `{"claimToken":"abc","conversionId":7}`, true},
+		{` { "conversionId":7}`, `{"claimToken":"abc","conversionId":7}`, true},
+		{`{}`, `{"claimToken":"abc"}`, true},
+		{`[1,2]`, `[1,2]`, false},
+		{`garbage`, `garbage`, false},
+	}
+	for _, c := range cases {
+		got, ok := withClaimToken(c.raw, "abc")
+		if got != c.want || ok != c.ok {
+			t.Errorf("withClaimToken(%q) = %q, %v; want %q, %v", c.raw, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestWithClaimToken_DecodesAsJob(t *testing.T) {
+	t.Parallel()
+
+	raw := `{"conversionId":7,"fileGuid":"abc","retryCount":1}`
+	a, _ := withClaimToken(raw, "one")
+	b, _ := withClaimToken(raw, "two")
+	if a == b {
+		t.Fatal("identical payloads must get distinct processing entries")
+	}
+
+	if got := withoutClaimToken(a); got != raw {
+		t.Fatalf("withoutClaimToken = %q, want %q", got, raw)
+	}
+	if got := withoutClaimToken(`{"claimToken":"one"}`); got != "{}" {
+		t.Fatalf("withoutClaimToken(empty) = %q, want {}", got)
+	}
+
+	var job models.ConversionJob
+	if err := json.Unmarshal([]byte(a), &job); err != nil {
+		t.Fatalf("tagged payload no longer decodes: %v", err)
+	}
+	if job.ConversionID != 7 || job.FileGUID != "abc" || job.RetryCount != 1 {
+		t.Fatalf("decoded job = %+v", job)
+	}
+}
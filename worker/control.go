@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_stopped_total", "Jobs stopped at a stage boundary because the producer cancelled them or their deadline passed, by status")
+}
+
+// stopStatus is the status a job stops with, or "" when it should go on.
+// The producer's control deadline replaces the one the job was enqueued
+// with, so it can be pushed back as well as brought forward.
+func stopStatus(job *models.ConversionJob, control services.JobControl, now time.Time) models.ConversionStatus {
+	if control.Cancelled {
+		return models.StatusCancelled
+	}
+	deadline := control.Deadline
+	if deadline.IsZero() && job.Deadline != nil {
+		deadline = *job.Deadline
+	}
+	if !deadline.IsZero() && now.After(deadline) {
+		return models.StatusExpired
+	}
+	return ""
+}
+
+// stopRequested checks the producer's control for the job at a stage
+// boundary. When the job was cancelled or its deadline passed, it is
+// finished as cancelled or expired and stopRequested returns true; the
+// caller drops the job. A stage that is already running isn't interrupted.
+// When the control can't be read, the job goes on.
+func (p *Pool) stopRequested(ctx context.Context, job *models.ConversionJob, jobJSON string, stage string) bool {
+	control, err := p.controls.Get(ctx, job.ConversionID)
+	if err != nil {
+		logging.From(ctx).Warn("Failed to read job control", "error", err)
+		return false
+	}
+
+	status := stopStatus(job, control, time.Now())
+	if status == "" {
+		return false
+	}
+	message := "Cancelled by producer"
+	if status == models.StatusExpired {
+		message = "Deadline passed"
+	}
+	logging.From(ctx).Info("Stopping conversion", "status", status, "stage", stage)
+	metrics.Inc("conversion_stopped_total", "status", string(status))
+
+	p.dbUpdater.UpdateStatus(job.ConversionID, status, "", nil)
+	p.dbUpdater.UpdateError(job.ConversionID, message)
+	if err := p.statusStore.Set(ctx, job.ConversionID, status, map[string]interface{}{
+		"error": message,
+	}); err != nil {
+		logStatusError(ctx, "Redis", err)
+	}
+	p.ack(ctx, jobJSON)
+	return true
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"converter/models"
+	"converter/services"
+)
+
+func TestStopStatus(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	cases := []struct {
+		name    string
+		job     models.ConversionJob
+		control services.JobControl
+		want    models.ConversionStatus
+	}{
+		{"no deadline", models.ConversionJob{}, services.JobControl{}, ""},
+		{"cancelled", models.ConversionJob{Deadline: &future}, services.JobControl{Cancelled: true}, models.StatusCancelled},
+		{"job deadline passed", models.ConversionJob{Deadline: &past}, services.JobControl{}, models.StatusExpired},
+		{"job deadline ahead", models.ConversionJob{Deadline: &future}, services.JobControl{}, ""},
+		{"control brings deadline forward", models.ConversionJob{Deadline: &future}, services.JobControl{Deadline: past}, models.StatusExpired},
+		{"control pushes deadline back", models.ConversionJob{Deadline: &past}, services.JobControl{Deadline: future}, ""},
+	}
+	for _, c := range cases {
+		if got := stopStatus(&c.job, c.control, now); got != c.want {
+			t.Errorf("%s: stopStatus = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"converter/logging"
+	"converter/services"
+)
+
+// convertFile converts a local file to PDF/A by its extension's route: PDFs
+// are normalized by Gotenberg's PDF engines (or kept as they are when they
+// already conform), images are assembled into a PDF with ImageMagick and
+// then normalized the same way, Markdown goes through Chromium and office
+// documents and plain HTML pages through LibreOffice, locally with soffice
+// when opts.Engine asks for it and Gotenberg otherwise. It returns the
+// output path and the engine for the audit trail.
+func (p *Pool) convertFile(ctx context.Context, localPath string, extension string, opts services.ConvertOptions) (string, string, error) {
+	route := p.routes.For(extension)
+	switch route {
+	case services.RoutePassThrough:
+		if p.conforms(ctx, localPath, opts) {
+			outputPath := localPath + ".converted.pdf"
+			if err := copyFile(localPath, outputPath); err != nil {
+				return "", passthroughAuditEngine, fmt.Errorf("PDF pass-through failed: %w", err)
+			}
+			return outputPath, passthroughAuditEngine, nil
+		}
+		outputPath, err := p.gotenbergSvc.ConvertPDFToPDFA(ctx, localPath, opts)
+		if err != nil {
+			return "", pdfAuditEngine, fmt.Errorf("PDF/A conversion failed: %w", err)
+		}
+		return outputPath, pdfAuditEngine, nil
+	case services.RouteImage:
+		imagePDF, err := p.imagingSvc.ToPDF(ctx, localPath, p.config.ImageNormalize)
+		if err != nil {
+			return "", imageAuditEngine, fmt.Errorf("image conversion failed: %w", err)
+		}
+		defer p.storage.Cleanup(imagePDF)
+		outputPath, err := p.gotenbergSvc.ConvertPDFToPDFA(ctx, imagePDF, opts)
+		if err != nil {
+			return "", imageAuditEngine, fmt.Errorf("PDF/A conversion failed: %w", err)
+		}
+		return outputPath, imageAuditEngine, nil
+	case services.RouteMarkdown:
+		outputPath, err := p.gotenbergSvc.ConvertMarkdown(ctx, localPath, opts)
+		if err != nil {
+			return "", markdownAuditEngine, fmt.Errorf("markdown conversion failed: %w", err)
+		}
+		return outputPath, markdownAuditEngine, nil
+	case services.RouteLibreOffice, services.RouteChromium:
+		if opts.Engine == services.EngineSoffice && p.sofficeSvc.Supports(opts) {
+			outputPath, err := p.sofficeSvc.ConvertToPDFA(ctx, localPath, extension, opts)
+			if err == nil {
+				return outputPath, sofficeAuditEngine, nil
+			}
+			logging.From(ctx).Warn("Local conversion failed, falling back to Gotenberg", "error", err)
+		}
+		outputPath, err := p.convertOffice(ctx, localPath, extension, opts)
+		if err != nil {
+			return "", auditEngine, fmt.Errorf("office conversion failed: %w", err)
+		}
+		return outputPath, auditEngine, nil
+	}
+	// Jobs are checked against the routes before they get here
+	return "", auditEngine, fmt.Errorf("no converter for .%s files on the %s route", strings.ToLower(strings.TrimPrefix(extension, ".")), route)
+}
+
+// conforms reports whether a PDF input can be delivered as it is: it must
+// declare the requested PDF/A level (or a stricter one of the same part)
+// and the job must not ask for tagging or flattening, which only a
+// conversion adds.
+func (p *Pool) conforms(ctx context.Context, pdfPath string, opts services.ConvertOptions) bool {
+	if opts.Accessible || opts.Flatten {
+		return false
+	}
+	claim, err := p.pdfTools.PDFAClaim(ctx, pdfPath)
+	if err != nil {
+		logging.From(ctx).Warn("Failed to read PDF/A identification, normalizing", "error", err)
+		return false
+	}
+	return services.SatisfiesConformance(claim, opts.Conformance)
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
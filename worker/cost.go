@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/schedule"
+	"converter/services"
+)
+
+// Per-tenant cost policies, set as costPolicy in the tenant config.
+const (
+	// CostPolicyPeak takes the economy path during peak windows only.
+	CostPolicyPeak = "peak"
+	// CostPolicyFast always takes the fast path.
+	CostPolicyFast = "fast"
+	// CostPolicyEconomy always takes the economy path.
+	CostPolicyEconomy = "economy"
+)
+
+func init() {
+	metrics.Describe("conversion_cost_path_total", "Jobs processed by cost path (fast or economy)")
+	metrics.Describe("conversion_cost_deferrals_total", "Normal priority jobs moved to the low priority queue on the economy path")
+}
+
+type costState struct {
+	mu      sync.Mutex
+	windows []schedule.Window
+	peak    bool
+}
+
+// ValidateCostConfig checks the engine used on the economy path.
+func ValidateCostConfig(engine string) error {
+	switch engine {
+	case services.EngineGotenberg, services.EngineSoffice:
+		return nil
+	default:
+		return fmt.Errorf("unknown engine %q", engine)
+	}
+}
+
+func (p *Pool) SetPeakWindows(windows []schedule.Window) {
+	p.cost.mu.Lock()
+	defer p.cost.mu.Unlock()
+	p.cost.windows = windows
+}
+
+// inPeak reports whether a peak window is active now and logs when one
+// starts or ends.
+func (p *Pool) inPeak() bool {
+	p.cost.mu.Lock()
+	defer p.cost.mu.Unlock()
+
+	if len(p.cost.windows) == 0 {
+		return false
+	}
+
+	peak := schedule.AnyActive(p.cost.windows, time.Now())
+	if peak != p.cost.peak {
+		if peak {
+			slog.Info("Entering peak window, taking the economy path", "component", "cost")
+		} else {
+			slog.Info("Peak window ended, taking the fast path", "component", "cost")
+		}
+		p.cost.peak = peak
+	}
+	return peak
+}
+
+// economyPath decides whether the job takes the cheap, slower path: the
+// local engine and, when COST_PEAK_DEMOTE is set, the low priority queue.
+// The tenant's cost policy overrides the peak windows.
+func (p *Pool) economyPath(ctx context.Context, job *models.ConversionJob) bool {
+	switch policy := p.tenants.Get(ctx, job.UserID).CostPolicy; policy {
+	case CostPolicyFast:
+		return false
+	case CostPolicyEconomy:
+		return true
+	case "", CostPolicyPeak:
+		return p.inPeak()
+	default:
+		logging.From(ctx).Warn("Unknown tenant cost policy, using peak windows", "policy", policy)
+		return p.inPeak()
+	}
+}
+
+// costEngine picks the engine for office documents. An operator's
+// force-engine annotation wins over the cost policy.
+func (p *Pool) costEngine(ctx context.Context, job *models.ConversionJob) string {
+	if engine := forcedEngine(ctx); engine != "" {
+		return engine
+	}
+	if p.economyPath(ctx, job) {
+		metrics.Inc("conversion_cost_path_total", "path", "economy")
+		return p.config.CostPeakEngine
+	}
+	metrics.Inc("conversion_cost_path_total", "path", "fast")
+	return services.EngineGotenberg
+}
+
+// deferForCost moves a normal priority job on the economy path to the low
+// priority queue instead of processing it now. A job is deferred at most
+// once, so aging can still bring it back, and high priority and
+// user-initiated jobs are never deferred. Reports whether the job was
+// handed off.
+func (p *Pool) deferForCost(ctx context.Context, job *models.ConversionJob, jobJSON string) bool {
+	if !p.config.CostPeakDemote || p.source != nil || job.CostDeferred || job.UserInitiated || job.Priority.Normalize() != models.PriorityNormal {
+		return false
+	}
+	if !p.economyPath(ctx, job) {
+		return false
+	}
+
+	deferred := *job
+	deferred.Priority = models.PriorityLow
+	deferred.CostDeferred = true
+	p.sealPassword(ctx, &deferred)
+	payload, err := json.Marshal(deferred)
+	if err != nil {
+		return false
+	}
+
+	target := p.config.PendingQueueFor(string(models.PriorityLow), job.Region)
+	if err := p.enqueue(ctx, target, string(p.signJob(jobJSON, payload))); err != nil {
+		logging.From(ctx).Error("Failed to defer conversion, processing it now", "error", err)
+		return false
+	}
+	p.ack(ctx, jobJSON)
+
+	logging.From(ctx).Info("Deferring conversion to the low priority queue", "queue", target)
+	metrics.Inc("conversion_cost_deferrals_total")
+	return true
+}
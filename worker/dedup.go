@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+
+	"converter/models"
+	"converter/services"
+)
+
+// uploadPrimary uploads the converted PDF and returns the key it now lives
+// at. For tenants with deduplication enabled the PDF is stored once under a
+// content-addressed key and the conversion references that key instead of
+// writing a second copy to job.OutputS3Path.
+func (p *Pool) uploadPrimary(ctx context.Context, job *models.ConversionJob, dataKey *services.DataKey, localPath string) (string, map[string]interface{}, error) {
+	// Encrypted outputs are unique per job, so there is nothing to share,
+	// and a shared object would keep the retention of whichever job wrote it
+	if dataKey != nil || job.RetentionClass != "" || !p.dedupEnabled(ctx, job.UserID) {
+		return job.OutputS3Path, nil, p.uploadOutput(ctx, dataKey, localPath, job.OutputS3Path, "application/pdf")
+	}
+
+	sum, err := services.FileSHA256(localPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to checksum output: %w", err)
+	}
+
+	casKey := path.Join(p.config.DedupPrefix, sum[:2], sum+".pdf")
+	_, err = p.storage.Stat(ctx, casKey)
+	exists := err == nil
+	if err != nil && !errors.Is(err, services.ErrObjectNotFound) {
+		return "", nil, err
+	}
+
+	if !exists {
+		if err := p.storage.Upload(ctx, localPath, casKey, "application/pdf"); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return casKey, map[string]interface{}{
+		"sha256":            sum,
+		"reused":            exists,
+		"requested_s3_path": job.OutputS3Path,
+	}, nil
+}
+
+func (p *Pool) dedupEnabled(ctx context.Context, userID int) bool {
+	if p.flags.Enabled(ctx, FlagOutputDedup, userID) {
+		return true
+	}
+	for _, tenant := range p.config.DedupTenants {
+		if tenant == "*" || tenant == strconv.Itoa(userID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"converter/migrate"
+	"converter/models"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const delayedBatchSize = 100
+
+// promoteScript moves one due member from the delayed set to its pending
+// queue atomically, so concurrent schedulers never promote a job twice and
+// a crash can't lose it between the two steps.
+var promoteScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 1 then
+	redis.call('LPUSH', KEYS[2], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// scheduleRetry stores the job in the delayed set scored by its retry time,
+// which survives restarts unlike an in-process timer.
+// With a queue source the source delays the message instead.
+func (p *Pool) scheduleRetry(ctx context.Context, jobJSON []byte, delay time.Duration) error {
+	if p.source != nil {
+		return p.source.Send(ctx, string(jobJSON), delay)
+	}
+	return p.redisClient.ZAdd(ctx, p.config.DelayedQueue, redis.Z{
+		Score:  float64(time.Now().Add(delay).UnixMilli()),
+		Member: jobJSON,
+	}).Err()
+}
+
+// DelayedLoop promotes due retries from the delayed set back to pending.
+func (p *Pool) DelayedLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	slog.Info("Starting delayed retry scheduler", "component", "delayed")
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Delayed retry scheduler shutting down", "component", "delayed")
+			return
+		case <-ticker.C:
+			p.promoteDueRetries(ctx)
+		}
+	}
+}
+
+func (p *Pool) promoteDueRetries(ctx context.Context) {
+	if !p.IsActive() {
+		return
+	}
+
+	due, err := p.redisClient.ZRangeByScore(ctx, p.config.DelayedQueue, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   formatScore(time.Now()),
+		Count: delayedBatchSize,
+	}).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Failed to read delayed retries", "component", "delayed", "error", err)
+		}
+		return
+	}
+
+	for _, jobJSON := range due {
+		var job models.ConversionJob
+		queue := p.config.PendingQueue
+		if err := json.Unmarshal([]byte(jobJSON), &job); err == nil {
+			queue = p.requeueTarget(&job)
+		}
+
+		var err error
+		if p.jobQueue.Streams() {
+			err = promoteStreamScript.Run(ctx, p.redisClient, []string{p.config.DelayedQueue, services.StreamKey(queue)}, jobJSON, migrate.StreamField).Err()
+		} else {
+			err = promoteScript.Run(ctx, p.redisClient, []string{p.config.DelayedQueue, queue}, jobJSON).Err()
+		}
+		if err != nil {
+			slog.Error("Failed to promote delayed retry", "component", "delayed", "conversion_id", job.ConversionID, "error", err)
+		}
+	}
+}
+
+func (p *Pool) hasDelayedRetries(ctx context.Context) bool {
+	n, err := p.redisClient.ZCard(ctx, p.config.DelayedQueue).Result()
+	return err != nil || n > 0
+}
+
+func formatScore(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
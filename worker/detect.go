@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_format_mismatches_total", "Inputs whose extension was missing or contradicted by their content, by detected format")
+}
+
+// contentRejection is returned by detectFormat for content no route can
+// convert, whatever the extension claims; the job is rejected with it.
+type contentRejection struct {
+	reason  models.RejectionReason
+	message string
+}
+
+func (r *contentRejection) Error() string {
+	return r.message
+}
+
+// detectFormat sniffs the downloaded input. When its extension is missing or
+// contradicted by the content, the file is renamed so Gotenberg picks the
+// right route, and job.InputExtension is corrected for the rest of the
+// pipeline and any retry. It returns the (possibly new) local path, or a
+// *contentRejection for executables, archives and ZIP bombs.
+func (p *Pool) detectFormat(ctx context.Context, job *models.ConversionJob, localPath string) (string, error) {
+	declared := job.InputExtension
+
+	detected, err := services.DetectFormat(localPath)
+	if err != nil {
+		return localPath, fmt.Errorf("failed to sniff input: %w", err)
+	}
+	if description, ok := services.Unconvertible(detected); ok {
+		metrics.Inc("conversion_format_mismatches_total", "detected", detected)
+		return localPath, unconvertibleContent(declared, detected, description)
+	}
+	ext, changed := services.ResolveExtension(declared, detected)
+	if !changed {
+		return localPath, nil
+	}
+
+	logging.From(ctx).Warn("Input extension does not match content, converting as detected format", "declared", declared, "detected", ext)
+	metrics.Inc("conversion_format_mismatches_total", "detected", ext)
+
+	resolved := strings.TrimSuffix(localPath, filepath.Ext(localPath)) + "." + ext
+	if err := os.Rename(localPath, resolved); err != nil {
+		return localPath, fmt.Errorf("failed to rename input: %w", err)
+	}
+	job.InputExtension = ext
+	return resolved, nil
+}
+
+func unconvertibleContent(declared string, detected string, description string) *contentRejection {
+	rejection := &contentRejection{reason: models.RejectUnsupportedFormat}
+	if detected == "zipbomb" {
+		rejection.reason = models.RejectTooLarge
+	}
+	declared = strings.ToLower(strings.TrimPrefix(declared, "."))
+	if declared == "" {
+		rejection.message = "file has no extension and is " + description
+	} else {
+		rejection.message = "file claims ." + declared + " but is " + description
+	}
+	return rejection
+}
+
+// SetRoutes sets the routing table parsed from CONVERSION_ROUTES; nil keeps
+// the default routes.
+func (p *Pool) SetRoutes(routes *services.Routes) {
+	p.routes = routes
+}
+
+// extensionSupported reports whether ext has a route and is in
+// CONVERSION_SUPPORTED_EXTENSIONS (every routed extension is when the list
+// is empty).
+func (p *Pool) extensionSupported(ext string) bool {
+	if p.routes.For(ext) == services.RouteUnsupported {
+		return false
+	}
+	if len(p.config.SupportedExtensions) == 0 {
+		return true
+	}
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	for _, allowed := range p.config.SupportedExtensions {
+		if ext == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package worker
+
+import (
+	"testing"
+
+	"converter/models"
+)
+
+func TestUnconvertibleContent(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		declared, detected, description string
+		reason                          models.RejectionReason
+		message                         string
+	}{
+		{"DOCX", "exe", "a Windows executable", models.RejectUnsupportedFormat, "file claims .docx but is a Windows executable"},
+		{"", "zip", "a ZIP archive", models.RejectUnsupportedFormat, "file has no extension and is a ZIP archive"},
+		{"xlsx", "zipbomb", "a ZIP bomb", models.RejectTooLarge, "file claims .xlsx but is a ZIP bomb"},
+	}
+	for _, c := range cases {
+		rejection := unconvertibleContent(c.declared, c.detected, c.description)
+		if rejection.reason != c.reason || rejection.message != c.message {
+			t.Errorf("unconvertibleContent(%q, %q) = %+v, want %s %q", c.declared, c.detected, rejection, c.reason, c.message)
+		}
+	}
+}
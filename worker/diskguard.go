@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"converter/metrics"
+)
+
+const diskGuardInterval = 5 * time.Second
+
+func init() {
+	metrics.Describe("conversion_temp_free_bytes", "Free space on the filesystem of CONVERSION_TEMP_DIR")
+	metrics.Describe("conversion_temp_disk_pressure", "1 while free temp space is below CONVERSION_TEMP_MIN_FREE_BYTES and workers don't claim jobs")
+}
+
+// DiskGuardLoop stops the workers from claiming while the temp disk has
+// less than CONVERSION_TEMP_MIN_FREE_BYTES free. Jobs already running
+// finish, and the backlog waits in Redis for this or another instance.
+func (p *Pool) DiskGuardLoop(ctx context.Context) {
+	p.checkDiskSpace()
+
+	ticker := time.NewTicker(diskGuardInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkDiskSpace()
+		}
+	}
+}
+
+// checkDiskSpace updates the disk pressure flag. A free space that can't be
+// read leaves it as it was.
+func (p *Pool) checkDiskSpace() {
+	free, err := p.tempStore.FreeBytes()
+	if err != nil {
+		slog.Warn("Failed to read free temp space", "component", "temp", "error", err)
+		return
+	}
+	metrics.Set("conversion_temp_free_bytes", free)
+
+	pressure := free < p.config.TempMinFreeBytes
+	if pressure == p.diskPressure.Load() {
+		return
+	}
+	p.diskPressure.Store(pressure)
+	if pressure {
+		metrics.Set("conversion_temp_disk_pressure", 1)
+		slog.Warn("Temp disk is nearly full, not claiming jobs", "component", "temp",
+			"dir", p.config.TempDir, "free_bytes", free, "min_free_bytes", p.config.TempMinFreeBytes)
+	} else {
+		metrics.Set("conversion_temp_disk_pressure", 0)
+		slog.Info("Temp disk has room again, claiming jobs", "component", "temp", "free_bytes", free)
+	}
+}
@@ -0,0 +1,27 @@
+package worker
+
+import (
+	"math"
+	"testing"
+
+	"converter/config"
+	"converter/services"
+)
+
+func TestCheckDiskSpace(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{TempDir: t.TempDir(), TempMinFreeBytes: math.MaxInt64}
+	p := &Pool{config: cfg, tempStore: services.NewTempStore(cfg)}
+
+	p.checkDiskSpace()
+	if !p.diskPressure.Load() {
+		t.Fatal("no disk pressure with less free space than required")
+	}
+
+	cfg.TempMinFreeBytes = 1
+	p.checkDiskSpace()
+	if p.diskPressure.Load() {
+		t.Fatal("disk pressure with more free space than required")
+	}
+}
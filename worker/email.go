@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"converter/logging"
+	"converter/models"
+	"converter/services"
+)
+
+// attachmentResult records what became of one attachment of an email.
+type attachmentResult struct {
+	Name     string `json:"name"`
+	Size     int    `json:"size"`
+	Appended bool   `json:"appended"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// convertEmail renders the message's headers and body to PDF/A and, when
+// the job or the deployment asks for it, converts each attachment through
+// its own route and appends it. An attachment that can't be converted is
+// skipped and reported rather than failing the email.
+func (p *Pool) convertEmail(ctx context.Context, job *models.ConversionJob, localPath string, opts services.ConvertOptions) (string, []attachmentResult, error) {
+	email, err := services.ParseEmail(localPath, job.InputExtension)
+	if err != nil {
+		return "", nil, err
+	}
+	bodyPath, err := p.gotenbergSvc.ConvertEmail(ctx, localPath, email, opts)
+	if err != nil {
+		return "", nil, err
+	}
+
+	appendAttachments := job.AppendAttachments || p.config.EmailAppendAttachments
+	parts := []string{bodyPath}
+	var results []attachmentResult
+	for i, a := range email.Attachments {
+		if a.Inline {
+			continue
+		}
+		result := attachmentResult{Name: a.Name, Size: len(a.Data)}
+		if appendAttachments {
+			if len(parts) > p.config.EmailMaxAttachments {
+				result.Reason = "attachment limit reached"
+			} else if partPath, reason := p.convertAttachment(ctx, localPath, i, a, opts); reason != "" {
+				result.Reason = reason
+			} else {
+				defer p.storage.Cleanup(partPath)
+				parts = append(parts, partPath)
+				result.Appended = true
+			}
+		}
+		results = append(results, result)
+	}
+
+	if len(parts) == 1 {
+		return bodyPath, results, nil
+	}
+	defer p.storage.Cleanup(bodyPath)
+
+	mergedPath := localPath + ".merged.pdf"
+	if err := p.gotenbergSvc.Merge(ctx, parts, mergedPath, opts); err != nil {
+		return "", nil, fmt.Errorf("failed to append attachments: %w", err)
+	}
+	return mergedPath, results, nil
+}
+
+// convertAttachment writes an attachment next to the message and converts
+// it to PDF/A. It returns the PDF to append, or why the attachment is left
+// out.
+func (p *Pool) convertAttachment(ctx context.Context, localPath string, index int, a services.EmailAttachment, opts services.ConvertOptions) (string, string) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(a.Name), "."))
+	switch {
+	case len(a.Data) == 0:
+		return "", "no content"
+	case services.IsEmailExtension(ext):
+		return "", "nested messages are not appended"
+	case ext != "pdf" && !p.extensionSupported(ext):
+		return "", "unsupported format"
+	}
+
+	attachmentPath := fmt.Sprintf("%s.attachment-%03d.%s", localPath, index+1, ext)
+	if err := os.WriteFile(attachmentPath, a.Data, 0600); err != nil {
+		logging.From(ctx).Warn("Failed to write email attachment", "attachment", a.Name, "error", err)
+		return "", "conversion failed"
+	}
+
+	// PDFs are made PDF/A by the merge itself
+	if ext == "pdf" {
+		return attachmentPath, ""
+	}
+	defer p.storage.Cleanup(attachmentPath)
+
+	partPath, _, err := p.convertFile(ctx, attachmentPath, ext, opts)
+	if err != nil {
+		logging.From(ctx).Warn("Failed to convert email attachment", "attachment", a.Name, "error", err)
+		return "", "conversion failed"
+	}
+	return partPath, ""
+}
@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+
+	"converter/models"
+	"converter/services"
+)
+
+// outputDataKey returns a fresh envelope key when the job (or deployment)
+// requires client-side encryption, or nil when outputs go up in plaintext.
+func (p *Pool) outputDataKey(ctx context.Context, job *models.ConversionJob) (*services.DataKey, error) {
+	keyID := job.EncryptionKeyID
+	if keyID == "" {
+		keyID = p.config.OutputEncryptionKeyID
+	}
+	if keyID == "" {
+		return nil, nil
+	}
+	return p.encryptionSvc.GenerateDataKey(ctx, keyID)
+}
+
+// uploadOutput uploads a local file, sealing it with the job's data key first
+// when one is set so the object is unreadable without the tenant's KMS key.
+func (p *Pool) uploadOutput(ctx context.Context, dataKey *services.DataKey, localPath string, s3Path string, contentType string) error {
+	if dataKey == nil {
+		return p.storage.Upload(ctx, localPath, s3Path, contentType)
+	}
+
+	encryptedPath := localPath + ".enc"
+	defer p.storage.Cleanup(encryptedPath)
+
+	if err := services.EncryptFile(localPath, encryptedPath, dataKey.Plaintext); err != nil {
+		return err
+	}
+	return p.storage.Upload(ctx, encryptedPath, s3Path, "application/octet-stream")
+}
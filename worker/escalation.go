@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_escalations_total", "Jobs quarantined after recovery requeued them CONVERSION_MAX_RECOVERIES times without them ever finishing")
+}
+
+// escalateJob moves a job recovery found abandoned once more, after
+// requeueing it CONVERSION_MAX_RECOVERIES times, to the quarantine queue
+// instead of the pending queue. Its conversion is failed with a message
+// saying why, and the alert channel is told, since something about the
+// input keeps killing workers and a person has to look at it.
+func (p *Pool) escalateJob(ctx context.Context, job *models.ConversionJob, jobJSON string) {
+	age := time.Since(job.CreatedAt).Round(time.Second)
+	message := fmt.Sprintf("Quarantined after recovery requeued it %d times without it finishing (%s since it was queued)", job.Recoveries, age)
+
+	p.sealPassword(ctx, job)
+	payload, _ := json.Marshal(job)
+	if err := p.redisClient.LPush(ctx, p.config.QuarantineQueue, string(p.signJob(jobJSON, payload))).Err(); err != nil {
+		// Leave it in processing for the next pass rather than lose it
+		slog.Error("Failed to quarantine abandoned job", "component", "recovery", "conversion_id", job.ConversionID, "error", err)
+		return
+	}
+	p.ack(ctx, jobJSON)
+	metrics.Inc("conversion_escalations_total")
+	slog.Warn("Quarantined job that keeps being abandoned", "component", "recovery",
+		"conversion_id", job.ConversionID, "recoveries", job.Recoveries, "age", age.String(), "queue", p.config.QuarantineQueue)
+
+	if !job.MovesOutputs() {
+		p.dbUpdater.UpdateStatus(job.ConversionID, models.StatusFailed, "", nil)
+		p.dbUpdater.UpdateError(job.ConversionID, message)
+		if err := p.statusStore.Set(ctx, job.ConversionID, models.StatusFailed, map[string]interface{}{"error": message}); err != nil {
+			logStatusError(ctx, "Redis", err)
+		}
+		p.publishEvent(ctx, job, services.EventConversionFailed, "", message)
+		p.recordRollup(job, false, 0)
+	}
+
+	if p.config.AlertWebhookURL != "" {
+		text := fmt.Sprintf("Conversion %d (%s, .%s) quarantined: %s", job.ConversionID, job.FileGUID, job.InputExtension, message)
+		if err := services.PostAlert(ctx, p.config.AlertWebhookURL, text); err != nil {
+			slog.Warn("Failed to post escalation alert", "component", "recovery", "conversion_id", job.ConversionID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"converter/logging"
+	"converter/models"
+	"converter/services"
+)
+
+// publishEvent tells the tenant's configured route (webhook, SNS, Pub/Sub or
+// none) that a conversion reached a terminal state or had its outputs
+// trashed or restored, and POSTs the same
+// event to the job's callbackUrl when it has one. Delivery failures are
+// logged but never change the job's outcome.
+func (p *Pool) publishEvent(ctx context.Context, job *models.ConversionJob, name string, outputPath string, errorMsg string) {
+	event := services.ConversionEvent{
+		Event:        name,
+		ConversionID: job.ConversionID,
+		FileGUID:     job.FileGUID,
+		UserID:       job.UserID,
+		Status:       string(models.StatusFailed),
+		OutputS3Path: outputPath,
+		Error:        errorMsg,
+		OccurredAt:   time.Now(),
+	}
+	if name != services.EventConversionFailed {
+		event.Status = string(models.StatusCompleted)
+	}
+	if !job.CreatedAt.IsZero() {
+		event.DurationMs = event.OccurredAt.Sub(job.CreatedAt).Milliseconds()
+	}
+	if err := p.events.Publish(ctx, job.UserID, event); err != nil {
+		logging.From(ctx).Warn("Failed to publish conversion event", "event", name, "error", err)
+	}
+	if job.CallbackURL != "" {
+		if err := p.events.Callback(ctx, job.CallbackURL, event); err != nil {
+			logging.From(ctx).Warn("Failed to deliver conversion callback", "event", name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeStatusRedis keeps conversion:status:<id> hashes in memory and runs
+// the status store's script against them, answering every other command
+// with redis.Nil, so status writes can be tested without a Redis server.
+type fakeStatusRedis struct {
+	mu     sync.Mutex
+	hashes map[string]map[string]string
+}
+
+func newFakeStatusClient(t *testing.T) (*redis.Client, *fakeStatusRedis) {
+	t.Helper()
+	fake := &fakeStatusRedis{hashes: make(map[string]map[string]string)}
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0", MaxRetries: -1})
+	client.AddHook(fake)
+	t.Cleanup(func() { client.Close() })
+	return client, fake
+}
+
+// status returns the status field of the conversion's hash.
+func (f *fakeStatusRedis) status(conversionID int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hashes["conversion:status:"+strconv.Itoa(conversionID)]["status"]
+}
+
+func (f *fakeStatusRedis) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (f *fakeStatusRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			cmd.SetErr(redis.Nil)
+		}
+		return redis.Nil
+	}
+}
+
+func (f *fakeStatusRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		args := cmd.Args()
+		var key string
+		if len(args) > 3 {
+			key, _ = args[3].(string)
+		}
+		if name := cmd.Name(); (name != "evalsha" && name != "eval") || !strings.HasPrefix(key, "conversion:status:") {
+			cmd.SetErr(redis.Nil)
+			return redis.Nil
+		}
+
+		// KEYS[1] is the hash; ARGV[1] the allowed predecessors, then
+		// field/value pairs
+		argv := args[4:]
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		hash := f.hashes[key]
+		if current, ok := hash["status"]; ok && !strings.Contains(","+argv[0].(string)+",", ","+current+",") {
+			cmd.(*redis.Cmd).SetVal(int64(0))
+			return nil
+		}
+		if hash == nil {
+			hash = make(map[string]string)
+			f.hashes[key] = hash
+		}
+		for i := 1; i+1 < len(argv); i += 2 {
+			hash[argv[i].(string)] = toString(argv[i+1])
+		}
+		cmd.(*redis.Cmd).SetVal(int64(1))
+		return nil
+	}
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	default:
+		return ""
+	}
+}
+
+// fakeConversions is a file_conversions table of ID to status behind a
+// database/sql driver. Status updates only move rows whose status is among
+// the predecessors the query names, like the real WHERE clause.
+type fakeConversions struct {
+	mu   sync.Mutex
+	rows map[int]string
+}
+
+var fakeConversionDBs sync.Map
+
+func init() {
+	sql.Register("fakeconversions", fakeConversionsDriver{})
+}
+
+// newFakeConversionsDB opens a table holding the given rows.
+func newFakeConversionsDB(t *testing.T, rows map[int]string) (*sql.DB, *fakeConversions) {
+	t.Helper()
+	table := &fakeConversions{rows: rows}
+	fakeConversionDBs.Store(t.Name(), table)
+	db, err := sql.Open("fakeconversions", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, table
+}
+
+func (f *fakeConversions) status(conversionID int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rows[conversionID]
+}
+
+type fakeConversionsDriver struct{}
+
+func (fakeConversionsDriver) Open(name string) (driver.Conn, error) {
+	table, ok := fakeConversionDBs.Load(name)
+	if !ok {
+		return nil, errors.New("no such fake database " + name)
+	}
+	return fakeConversionsConn{table.(*fakeConversions)}, nil
+}
+
+type fakeConversionsConn struct{ table *fakeConversions }
+
+func (c fakeConversionsConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c fakeConversionsConn) Close() error { return nil }
+
+func (c fakeConversionsConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("begin not supported")
+}
+
+func (c fakeConversionsConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !strings.HasPrefix(query, "UPDATE file_conversions SET status = $1") {
+		return driver.RowsAffected(1), nil
+	}
+	status := args[0].Value.(string)
+	id := int(args[len(args)-2].Value.(int64))
+	predecessors := strings.Split(strings.Trim(args[len(args)-1].Value.(string), "{}"), ",")
+
+	c.table.mu.Lock()
+	defer c.table.mu.Unlock()
+	for _, predecessor := range predecessors {
+		if strings.Trim(predecessor, `"`) == c.table.rows[id] {
+			c.table.rows[id] = status
+			return driver.RowsAffected(1), nil
+		}
+	}
+	return driver.RowsAffected(0), nil
+}
@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func init() {
+	metrics.Describe("conversion_file_lock_waits_total", "Jobs put back because another conversion of the same file was in flight")
+}
+
+// refreshFileLockScript extends the lock only while this worker holds it,
+// so a lock that expired and was taken over is never stretched.
+var refreshFileLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseFileLockScript deletes the lock only while this worker holds it.
+var releaseFileLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (p *Pool) fileLockKey(fileGUID string) string {
+	return p.config.RedisPrefix + "conversion:filelock:" + fileGUID
+}
+
+// lockFile serializes conversions of the same FileGUID across every worker
+// and instance, so two jobs for one document never share temp paths or
+// write its output keys at the same time. The lock expires like the job
+// lease when its holder dies. When another job holds it, this one goes back
+// through the delayed set without using a retry, and ok is false.
+func (p *Pool) lockFile(ctx context.Context, workerID int, job *models.ConversionJob, jobJSON string) (release func(), ok bool) {
+	ttl := time.Duration(p.config.LeaseTTL) * time.Second
+	if !p.config.FileLock || job.FileGUID == "" || ttl <= 0 {
+		return func() {}, true
+	}
+
+	key := p.fileLockKey(job.FileGUID)
+	holder := fmt.Sprintf("%s/%d/%d", p.config.InstanceID, workerID, job.ConversionID)
+	acquired, err := p.redisClient.SetNX(ctx, key, holder, ttl).Result()
+	if err != nil {
+		// Better a rare race than a stalled queue
+		logging.From(ctx).Warn("Failed to take file lock, converting unlocked", "error", err)
+		return func() {}, true
+	}
+	if !acquired {
+		p.waitForFile(ctx, jobJSON)
+		return nil, false
+	}
+
+	interval := time.Duration(p.config.LeaseInterval) * time.Second
+	if interval <= 0 {
+		interval = ttl / 3
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := refreshFileLockScript.Run(ctx, p.redisClient, []string{key}, holder, ttl.Milliseconds()).Err(); err != nil {
+					logging.From(ctx).Warn("Failed to refresh file lock", "error", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		releaseFileLockScript.Run(context.Background(), p.redisClient, []string{key}, holder)
+	}, true
+}
+
+// waitForFile puts a job whose file is locked back through the delayed set,
+// to be claimed again once the other conversion has had time to finish.
+func (p *Pool) waitForFile(ctx context.Context, jobJSON string) {
+	delay := time.Duration(p.config.FileLockRetryDelay) * time.Second
+	logging.From(ctx).Info("File is being converted by another job, retrying later", "delay", delay.String())
+	metrics.Inc("conversion_file_lock_waits_total")
+
+	if err := p.scheduleRetry(ctx, []byte(p.sealPayload(ctx, jobJSON)), delay); err != nil {
+		// Leave it in processing for recovery rather than lose it
+		logging.From(ctx).Error("Failed to put back locked conversion", "error", err)
+		return
+	}
+	p.ack(ctx, jobJSON)
+}
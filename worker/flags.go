@@ -0,0 +1,12 @@
+package worker
+
+// Feature flags evaluated per tenant (job.UserID) via services.FeatureFlags.
+const (
+	// FlagOutputDedup enables content-addressed output deduplication in
+	// addition to tenants listed in OUTPUT_DEDUP_TENANTS.
+	FlagOutputDedup = "output_dedup"
+
+	// FlagAccessiblePDF produces tagged PDF/UA output for every job of the
+	// tenant, not just jobs that set accessible.
+	FlagAccessiblePDF = "accessible_pdf"
+)
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"converter/services"
+)
+
+// gotenbergRetryInterval is how often an unreachable Gotenberg is asked for
+// its version again at startup.
+const gotenbergRetryInterval = 2 * time.Second
+
+// SetGotenbergVersion picks the Gotenberg API the workers speak:
+// GOTENBERG_API_VERSION when set, otherwise the version Gotenberg reports.
+// An unreachable Gotenberg is retried for GOTENBERG_STARTUP_WAIT seconds, so
+// starting both together works; an unsupported one fails at once.
+func (p *Pool) SetGotenbergVersion(ctx context.Context) error {
+	if p.config.GotenbergAPIVersion != 0 {
+		if err := p.gotenbergSvc.SetAPIVersion(p.config.GotenbergAPIVersion); err != nil {
+			return err
+		}
+		slog.Info("Using configured Gotenberg API version", "component", "gotenberg", "version", p.config.GotenbergAPIVersion)
+		return p.checkGotenbergFeatures()
+	}
+
+	deadline := time.Now().Add(time.Duration(p.config.GotenbergStartupWait) * time.Second)
+	for {
+		version, err := p.gotenbergSvc.DetectVersion(ctx)
+		if err == nil {
+			slog.Info("Detected Gotenberg version", "component", "gotenberg", "version", version)
+			return p.checkGotenbergFeatures()
+		}
+		if errors.Is(err, services.ErrUnsupportedGotenberg) || !time.Now().Before(deadline) {
+			return err
+		}
+		slog.Warn("Gotenberg version not available yet, retrying", "component", "gotenberg", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(gotenbergRetryInterval):
+		}
+	}
+}
+
+// checkGotenbergFeatures refuses deployment-wide options the Gotenberg
+// version can't honor.
+func (p *Pool) checkGotenbergFeatures() error {
+	if feature := p.gotenbergSvc.MissingFeature(p.config.PDFUA, false, false); feature != "" {
+		return fmt.Errorf("PDFA_PDFUA=true needs Gotenberg %d for %s", services.GotenbergV8, feature)
+	}
+	return nil
+}
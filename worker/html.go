@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"converter/models"
+	"converter/services"
+)
+
+// validateAssets checks the assets an HTML job refers to before anything
+// is downloaded. Chromium sees every file in one flat directory, so names
+// must be bare, unique and not collide with the page itself.
+func (p *Pool) validateAssets(job *models.ConversionJob) (models.RejectionReason, string) {
+	if len(job.Assets) == 0 {
+		return "", ""
+	}
+	if job.IsMerge() {
+		return models.RejectMalformed, "merge jobs can't carry assets"
+	}
+	if p.config.HTMLAssetMax > 0 && len(job.Assets) > p.config.HTMLAssetMax {
+		return models.RejectTooLarge, fmt.Sprintf("job has %d assets, the limit is %d", len(job.Assets), p.config.HTMLAssetMax)
+	}
+
+	seen := make(map[string]bool, len(job.Assets))
+	for _, asset := range job.Assets {
+		name := asset.FileName()
+		if asset.S3Path == "" {
+			return models.RejectMalformed, "asset " + name + " is missing s3Path"
+		}
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.EqualFold(name, "index.html") {
+			return models.RejectMalformed, "invalid asset name " + name
+		}
+		if seen[name] {
+			return models.RejectMalformed, "duplicate asset name " + name
+		}
+		seen[name] = true
+	}
+	return "", ""
+}
+
+// validatePrintTemplates checks a job's header and footer, which only the
+// Chromium routes for HTML and Markdown print.
+func validatePrintTemplates(job *models.ConversionJob) (models.RejectionReason, string) {
+	if job.Header == "" && job.Footer == "" {
+		return "", ""
+	}
+	if services.KindOf(job) != services.KindHTML {
+		return models.RejectMalformed, "header and footer need an HTML or Markdown input"
+	}
+	if len(job.Header) > services.MaxPrintTemplateBytes || len(job.Footer) > services.MaxPrintTemplateBytes {
+		return models.RejectTooLarge, fmt.Sprintf("header and footer are limited to %d bytes each", services.MaxPrintTemplateBytes)
+	}
+	return "", ""
+}
+
+// convertHTML downloads the page's assets and prints the page with them
+// through Chromium, so images and stylesheets aren't missing from the
+// output. Pages with a header or footer take this route too, since only
+// Chromium prints them.
+func (p *Pool) convertHTML(ctx context.Context, job *models.ConversionJob, localPath string, opts services.ConvertOptions) (string, error) {
+	assetDir := localPath + ".assets"
+	if err := os.MkdirAll(assetDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create asset directory: %w", err)
+	}
+	defer os.RemoveAll(assetDir)
+
+	assets, err := p.downloadAssets(ctx, job.Assets, assetDir)
+	if err != nil {
+		return "", err
+	}
+	outputPath, err := p.gotenbergSvc.ConvertHTML(ctx, localPath, assets, opts)
+	if err != nil {
+		return "", p.captureChromiumDebug(ctx, job, err, localPath, func(ctx context.Context, outputPath string, failOnConsole bool) error {
+			return p.gotenbergSvc.ScreenshotHTML(ctx, localPath, assets, outputPath, failOnConsole)
+		})
+	}
+	return outputPath, nil
+}
+
+// downloadAssets fetches the assets into dir, HTML_ASSET_CONCURRENCY at a
+// time, and returns file name -> local path. The first failure cancels the
+// downloads still running.
+func (p *Pool) downloadAssets(ctx context.Context, assets []models.HTMLAsset, dir string) (map[string]string, error) {
+	concurrency := p.config.HTMLAssetConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	paths := make(map[string]string, len(assets))
+	slots := make(chan struct{}, concurrency)
+
+	for _, asset := range assets {
+		wg.Add(1)
+		go func(asset models.HTMLAsset) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			name := asset.FileName()
+			localPath := filepath.Join(dir, name)
+			err := ctx.Err()
+			if err == nil {
+				err = p.storage.Download(ctx, asset.S3Path, localPath)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to download asset %s: %w", name, err)
+					cancel()
+				}
+				return
+			}
+			paths[name] = localPath
+		}(asset)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return paths, nil
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"strings"
+	"testing"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+)
+
+func TestValidateAssets(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{config: &config.Config{
+		SupportedExtensions: []string{"html", "pdf"},
+		MergeMaxInputs:      3,
+		HTMLAssetMax:        2,
+	}}
+
+	page := func(assets ...models.HTMLAsset) *models.ConversionJob {
+		return &models.ConversionJob{
+			ConversionID:   7,
+			InputS3Path:    "in/page.html",
+			OutputS3Path:   "out/page.pdf",
+			InputExtension: "html",
+			Assets:         assets,
+		}
+	}
+
+	cases := []struct {
+		name string
+		job  *models.ConversionJob
+		want models.RejectionReason
+	}{
+		{"no assets", page(), ""},
+		{"valid", page(models.HTMLAsset{S3Path: "in/logo.png"}, models.HTMLAsset{S3Path: "in/x/style.css", Name: "site.css"}), ""},
+		{"too many", page(models.HTMLAsset{S3Path: "a.png"}, models.HTMLAsset{S3Path: "b.png"}, models.HTMLAsset{S3Path: "c.png"}), models.RejectTooLarge},
+		{"missing s3Path", page(models.HTMLAsset{Name: "logo.png"}), models.RejectMalformed},
+		{"nested name", page(models.HTMLAsset{S3Path: "in/logo.png", Name: "img/logo.png"}), models.RejectMalformed},
+		{"parent name", page(models.HTMLAsset{S3Path: "in/logo.png", Name: ".."}), models.RejectMalformed},
+		{"replaces page", page(models.HTMLAsset{S3Path: "in/Index.HTML"}), models.RejectMalformed},
+		{"duplicate", page(models.HTMLAsset{S3Path: "a/logo.png"}, models.HTMLAsset{S3Path: "b/logo.png"}), models.RejectMalformed},
+		{"merge", &models.ConversionJob{
+			Type:         models.JobTypeMerge,
+			ConversionID: 7,
+			OutputS3Path: "out/merged.pdf",
+			InputS3Paths: []string{"in/a.pdf", "in/b.pdf"},
+			Assets:       []models.HTMLAsset{{S3Path: "in/logo.png"}},
+		}, models.RejectMalformed},
+	}
+	for _, c := range cases {
+		if got, message := p.validateJob(c.job); got != c.want {
+			t.Errorf("%s: validateJob() = %q (%s), want %q", c.name, got, message, c.want)
+		}
+	}
+}
+
+func TestValidatePrintTemplates(t *testing.T) {
+	t.Parallel()
+
+	job := func(ext string, header string) *models.ConversionJob {
+		return &models.ConversionJob{ConversionID: 7, InputS3Path: "in/a." + ext, OutputS3Path: "out/a.pdf", InputExtension: ext, Header: header}
+	}
+	cases := []struct {
+		name string
+		job  *models.ConversionJob
+		want models.RejectionReason
+	}{
+		{"none", job("docx", ""), ""},
+		{"html", job("html", "{{title}}"), ""},
+		{"markdown", job("md", "{{title}}"), ""},
+		{"office", job("docx", "{{title}}"), models.RejectMalformed},
+		{"oversized", job("html", strings.Repeat("x", services.MaxPrintTemplateBytes+1)), models.RejectTooLarge},
+	}
+	for _, c := range cases {
+		if got, message := validatePrintTemplates(c.job); got != c.want {
+			t.Errorf("%s: validatePrintTemplates() = %q (%s), want %q", c.name, got, message, c.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"strconv"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+)
+
+func init() {
+	metrics.Describe("conversion_duplicates_skipped_total", "Jobs acked without converting because a job with the same idempotency key already completed")
+}
+
+// idempotencyKey identifies the jobs that are copies of one another: the
+// producer's idempotencyKey, scoped to the tenant. Jobs without one have no
+// key and are never skipped, since the same conversion ID is enqueued again
+// on purpose to convert a document anew.
+func idempotencyKey(job *models.ConversionJob) string {
+	if job.IdempotencyKey == "" {
+		return ""
+	}
+	return "key:" + strconv.Itoa(job.UserID) + ":" + job.IdempotencyKey
+}
+
+// skipDuplicate acks a job whose idempotency key already completed within
+// IDEMPOTENCY_TTL, usually one a producer enqueued again after a timeout,
+// instead of converting and uploading it a second time. A store that can't
+// be reached lets the job through. Reports whether the job was skipped.
+func (p *Pool) skipDuplicate(ctx context.Context, job *models.ConversionJob, jobJSON string) bool {
+	if p.completedJobs == nil || idempotencyKey(job) == "" {
+		return false
+	}
+
+	logger := logging.From(ctx)
+	outputPath, completed, err := p.completedJobs.Completed(ctx, idempotencyKey(job))
+	if err != nil {
+		logger.Warn("Failed to check for a completed duplicate, converting", "error", err)
+		return false
+	}
+	if !completed {
+		return false
+	}
+
+	p.ack(ctx, jobJSON)
+	metrics.Inc("conversion_duplicates_skipped_total")
+	logger.Info("Conversion already completed, skipping duplicate", "result", "duplicate_skipped",
+		"idempotency_key", idempotencyKey(job), "output_s3_path", outputPath)
+	return true
+}
+
+// markCompleted records the job's idempotency key as completed, so copies
+// of it are skipped.
+func (p *Pool) markCompleted(ctx context.Context, job *models.ConversionJob, outputPath string) {
+	if p.completedJobs == nil || idempotencyKey(job) == "" {
+		return
+	}
+	if err := p.completedJobs.MarkCompleted(ctx, idempotencyKey(job), outputPath); err != nil {
+		logging.From(ctx).Warn("Failed to record completion, a duplicate would convert again", "error", err)
+	}
+}
@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		job  models.ConversionJob
+		want string
+	}{
+		{"no key", models.ConversionJob{ConversionID: 42, UserID: 7}, ""},
+		{"producer key", models.ConversionJob{ConversionID: 42, UserID: 7, IdempotencyKey: "upload-9"}, "key:7:upload-9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := idempotencyKey(&tt.job); got != tt.want {
+				t.Errorf("idempotencyKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipDuplicate_ConvertsWithoutStore(t *testing.T) {
+	t.Parallel()
+
+	job := &models.ConversionJob{ConversionID: 42}
+	p := &Pool{config: &config.Config{}}
+	if p.skipDuplicate(context.Background(), job, "{}") {
+		t.Error("skipped a job with idempotency disabled")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0", MaxRetries: -1})
+	p.completedJobs = services.NewCompletedJobs(client, "", time.Hour)
+	if p.skipDuplicate(context.Background(), job, "{}") {
+		t.Error("skipped a job when the store couldn't be reached")
+	}
+}
+
+// countingHook counts the commands sent to Redis.
+type countingHook struct{ commands *int }
+
+func (h countingHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h countingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		*h.commands++
+		return next(ctx, cmd)
+	}
+}
+
+func (h countingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestSkipDuplicate_ReconvertsWithoutKey(t *testing.T) {
+	t.Parallel()
+
+	// The producer enqueues the same conversion again to convert the
+	// document anew; without an idempotencyKey the store isn't consulted
+	var commands int
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0", MaxRetries: -1})
+	client.AddHook(countingHook{commands: &commands})
+	p := &Pool{config: &config.Config{}, completedJobs: services.NewCompletedJobs(client, "", time.Hour)}
+
+	job := &models.ConversionJob{ConversionID: 42, UserID: 7}
+	p.markCompleted(context.Background(), job, "out/a.pdf")
+	if p.skipDuplicate(context.Background(), job, "{}") {
+		t.Error("skipped a re-conversion without an idempotency key")
+	}
+	if commands != 0 {
+		t.Errorf("sent %d commands to the completed-jobs store, want 0", commands)
+	}
+}
@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"converter/logging"
+	"converter/models"
+)
+
+// journalEntry is persisted locally while a job is in flight so a crashed
+// worker can clean up and requeue deterministically on the next start.
+type journalEntry struct {
+	JobJSON    string    `json:"jobJson"`
+	WorkerID   int       `json:"workerId"`
+	Stage      string    `json:"stage"`
+	TempPrefix string    `json:"tempPrefix"`
+	TempDir    string    `json:"tempDir,omitempty"`
+	ClaimedAt  time.Time `json:"claimedAt"`
+
+	path   string
+	logger *slog.Logger
+}
+
+func (p *Pool) beginJournal(ctx context.Context, workerID int, job *models.ConversionJob, jobJSON string) *journalEntry {
+	entry := &journalEntry{
+		logger:    logging.From(ctx),
+		JobJSON:   jobJSON,
+		WorkerID:  workerID,
+		Stage:     "claimed",
+		ClaimedAt: time.Now(),
+	}
+	if p.config.JournalDir == "" {
+		return entry
+	}
+	entry.path = filepath.Join(p.config.JournalDir, fmt.Sprintf("%d.json", job.ConversionID))
+
+	if err := os.MkdirAll(p.config.JournalDir, 0755); err != nil {
+		entry.logger.Error("Failed to create journal dir", "error", err)
+	}
+	entry.write()
+	return entry
+}
+
+func (e *journalEntry) setStage(stage string) {
+	e.Stage = stage
+	e.write()
+}
+
+func (e *journalEntry) finish() {
+	if e.path == "" {
+		return
+	}
+	os.Remove(e.path)
+}
+
+func (e *journalEntry) write() {
+	if e.path == "" {
+		return
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	// Write-then-rename so a crash never leaves a torn entry
+	tmp := e.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		e.logger.Error("Failed to write journal", "error", err)
+		return
+	}
+	os.Rename(tmp, e.path)
+}
+
+// ReconcileJournal handles entries left behind by a previous crash: temp
+// files are deleted and the job is taken out of the processing queue and
+// retried (or failed) immediately instead of waiting for stale recovery.
+func (p *Pool) ReconcileJournal(ctx context.Context) {
+	if p.config.JournalDir == "" {
+		return
+	}
+
+	paths, err := filepath.Glob(filepath.Join(p.config.JournalDir, "*.json"))
+	if err != nil || len(paths) == 0 {
+		return
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			slog.Warn("Removing unreadable journal entry", "component", "journal", "path", path, "error", err)
+			os.Remove(path)
+			continue
+		}
+
+		p.reconcileEntry(ctx, &entry)
+		os.Remove(path)
+	}
+}
+
+func (p *Pool) reconcileEntry(ctx context.Context, entry *journalEntry) {
+	if entry.TempDir != "" {
+		os.RemoveAll(entry.TempDir)
+	}
+	if entry.TempPrefix != "" {
+		temps, _ := filepath.Glob(entry.TempPrefix + "*")
+		for _, temp := range temps {
+			os.Remove(temp)
+		}
+	}
+
+	var job models.ConversionJob
+	if err := json.Unmarshal([]byte(entry.JobJSON), &job); err != nil {
+		return
+	}
+
+	// Another instance may already have recovered it
+	removed, err := p.ack(ctx, entry.JobJSON)
+	if err != nil || removed == 0 {
+		slog.Info("Conversion no longer in processing queue, cleaned temp files only", "component", "journal", "conversion_id", job.ConversionID)
+		return
+	}
+
+	slog.Warn("Conversion was interrupted by a crash, requeueing", "component", "journal", "conversion_id", job.ConversionID, "stage", entry.Stage)
+
+	if job.RetryCount < job.MaxRetries {
+		job.RetryCount++
+		p.sealPassword(ctx, &job)
+		newJobJSON, _ := json.Marshal(job)
+		p.enqueue(ctx, p.requeueTarget(&job), string(p.signJob(entry.JobJSON, newJobJSON)))
+		p.dbUpdater.IncrementRetryCount(job.ConversionID)
+	} else {
+		p.pushFailed(ctx, entry.JobJSON)
+		p.dbUpdater.UpdateStatus(job.ConversionID, models.StatusFailed, "", nil)
+		p.dbUpdater.UpdateError(job.ConversionID, "Worker crashed during conversion")
+	}
+}
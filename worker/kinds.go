@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_kind_handoffs_total", "Claimed jobs handed to their kind queue because this instance doesn't take the kind, by kind")
+}
+
+// SetJobKinds limits the workers to the given kinds, as parsed by
+// services.ParseJobKinds; nil takes every kind.
+func (p *Pool) SetJobKinds(kinds map[string]bool) {
+	p.kinds = kinds
+}
+
+// takesKind reports whether this instance converts jobs of the kind. Jobs
+// without a kind run anywhere.
+func (p *Pool) takesKind(kind string) bool {
+	return p.kinds == nil || kind == "" || p.kinds[kind]
+}
+
+// kindQueues are the kind queues this instance claims from. They hold jobs
+// that have already waited in a shared queue, so they are served first.
+func (p *Pool) kindQueues() []string {
+	var queues []string
+	for _, kind := range services.JobKinds() {
+		if p.takesKind(kind) {
+			queues = append(queues, p.config.KindQueueFor(kind, p.config.Region))
+		}
+	}
+	return queues
+}
+
+// handOffKind moves a job this instance doesn't take to its kind's queue,
+// without using a retry, where only instances taking the kind claim it.
+func (p *Pool) handOffKind(ctx context.Context, job *models.ConversionJob, jobJSON string, kind string) {
+	target := p.config.KindQueueFor(kind, p.config.Region)
+	logging.From(ctx).Info("Conversion kind isn't taken here, handing it on", "kind", kind, "queue", target)
+	metrics.Inc("conversion_kind_handoffs_total", "kind", kind)
+
+	if err := p.jobQueue.Push(ctx, target, p.sealPayload(ctx, jobJSON)); err != nil {
+		// Leave it in processing for recovery rather than lose it
+		logging.From(ctx).Error("Failed to hand on conversion", "error", err)
+		return
+	}
+	p.ack(ctx, jobJSON)
+}
@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+
+	"converter/config"
+	"converter/services"
+)
+
+func TestKindQueues(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{Region: "eu"}
+	p := &Pool{config: cfg}
+	if got := len(p.kindQueues()); got != len(services.JobKinds()) {
+		t.Fatalf("unrestricted kindQueues = %d queues, want every kind", got)
+	}
+
+	p.SetJobKinds(map[string]bool{services.KindMerge: true})
+	want := []string{cfg.KindQueueFor(services.KindMerge, cfg.Region)}
+	if got := p.kindQueues(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("kindQueues = %v, want %v", got, want)
+	}
+	if p.takesKind(services.KindOffice) {
+		t.Fatal("merge-only pool takes office jobs")
+	}
+	if !p.takesKind("") {
+		t.Fatal("merge-only pool refuses jobs without a kind")
+	}
+}
@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_jobs_total", "Conversion attempts by result (completed, retried, failed) and the job labels in CONVERSION_METRIC_LABELS")
+	metrics.Describe("conversion_job_duration_milliseconds_total", "Time spent converting completed jobs, by the job labels in CONVERSION_METRIC_LABELS")
+}
+
+// SetMetricLabels breaks the job metrics down by the given job labels, as
+// parsed by services.ParseMetricLabels.
+func (p *Pool) SetMetricLabels(names []string) {
+	p.metricLabels = names
+}
+
+// SetLabelPriorities gives jobs the priority the rules set for their
+// labels.
+func (p *Pool) SetLabelPriorities(rules services.LabelPriorities) {
+	p.labelRules = rules
+}
+
+// jobMetricLabels appends the job's values of CONVERSION_METRIC_LABELS to
+// the metric label pairs in labels. A label the job doesn't carry is
+// empty, so every series has the same labels.
+func (p *Pool) jobMetricLabels(job *models.ConversionJob, labels ...string) []string {
+	for _, name := range p.metricLabels {
+		labels = append(labels, name, job.Labels[name])
+	}
+	return labels
+}
+
+// recordJobResult counts an attempt of the job by its result, and the time
+// a completed one took.
+func (p *Pool) recordJobResult(job *models.ConversionJob, result string, duration time.Duration) {
+	if job.MovesOutputs() {
+		return
+	}
+	metrics.Inc("conversion_jobs_total", p.jobMetricLabels(job, "result", result)...)
+	if result == "completed" {
+		metrics.Add("conversion_job_duration_milliseconds_total", duration.Milliseconds(), p.jobMetricLabels(job)...)
+	}
+}
+
+// applyLabelPriority gives the job the priority CONVERSION_LABEL_PRIORITIES
+// sets for its labels. The job is claimed already, so it isn't moved; the
+// priority decides whether it is deferred for cost and which queue its
+// retries go to.
+func (p *Pool) applyLabelPriority(ctx context.Context, job *models.ConversionJob) {
+	priority, ok := p.labelRules.For(job.Labels)
+	if !ok || priority == job.Priority.Normalize() {
+		return
+	}
+	logging.From(ctx).Debug("Label rule changes priority", "from", job.Priority.Normalize(), "to", priority)
+	job.Priority = priority
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+
+	"converter/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// claimRetryLane takes the next user-initiated retry, waiting on the lane by
+// the claim strategy when it is empty. Lane workers ignore priority-only maintenance windows
+// since someone is waiting on every job in the lane.
+func (p *Pool) claimRetryLane(ctx context.Context) (string, error) {
+	if p.source != nil {
+		return p.receiveJob(ctx)
+	}
+	result, err := p.moveToProcessing(ctx, p.config.RetryLaneQueue)
+	if err != redis.Nil || p.runOnce {
+		return result, err
+	}
+	return p.waitForJob(ctx, p.config.RetryLaneQueue)
+}
+
+// requeueTarget is the queue a job goes back to on retry or recovery:
+// user-initiated retries stay in the retry lane, everything else returns to
+// its priority queue.
+func (p *Pool) requeueTarget(job *models.ConversionJob) string {
+	if job.UserInitiated {
+		return p.config.RetryLaneQueue
+	}
+	return p.pendingQueue(job.Priority)
+}
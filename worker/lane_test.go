@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"testing"
+
+	"converter/config"
+	"converter/models"
+)
+
+func TestRequeueTarget(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{config: &config.Config{
+		PendingQueue:      "conversion:pending",
+		HighPriorityQueue: "conversion:pending:high",
+		LowPriorityQueue:  "conversion:pending:low",
+		RetryLaneQueue:    "conversion:pending:retry",
+	}}
+
+	cases := []struct {
+		job  models.ConversionJob
+		want string
+	}{
+		{models.ConversionJob{}, "conversion:pending"},
+		{models.ConversionJob{Priority: models.PriorityLow}, "conversion:pending:low"},
+		{models.ConversionJob{Priority: models.PriorityLow, UserInitiated: true}, "conversion:pending:retry"},
+	}
+	for _, c := range cases {
+		if got := p.requeueTarget(&c.job); got != c.want {
+			t.Errorf("requeueTarget(%+v) = %q, want %q", c.job, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"context"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_languages_total", "Completed jobs by detected language")
+}
+
+// detectLanguage guesses the primary language of the output's text layer
+// from its first pages. Detection is best effort: nil when it is disabled,
+// the text can't be read or there is too little of it to tell.
+func (p *Pool) detectLanguage(ctx context.Context, pdfPath string) *services.LanguageGuess {
+	if !p.config.LanguageDetection {
+		return nil
+	}
+	text, err := p.pdfTools.SampleText(ctx, pdfPath, p.config.LanguageSamplePages)
+	if err != nil {
+		logging.From(ctx).Warn("Language detection failed", "error", err)
+		return nil
+	}
+	guess := services.DetectLanguage(text)
+	code := "unknown"
+	if guess != nil {
+		code = guess.Code
+	}
+	metrics.Inc("conversion_languages_total", "language", code)
+	return guess
+}
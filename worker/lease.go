@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"converter/logging"
+)
+
+func (p *Pool) leaseKey(conversionID int) string {
+	return fmt.Sprintf("%sconversion:lease:%d", p.config.RedisPrefix, conversionID)
+}
+
+// startLease holds the job's lease, refreshing its TTL on every heartbeat
+// until the returned release function is called. The recovery loop only
+// reclaims jobs whose lease has expired.
+func (p *Pool) startLease(ctx context.Context, workerID int, conversionID int) func() {
+	interval := time.Duration(p.config.LeaseInterval) * time.Second
+	ttl := time.Duration(p.config.LeaseTTL) * time.Second
+	if interval <= 0 || ttl <= 0 {
+		return func() {}
+	}
+
+	key := p.leaseKey(conversionID)
+	holder := fmt.Sprintf("%s/%d", p.config.InstanceID, workerID)
+	heartbeat := func() {
+		if err := p.redisClient.Set(ctx, key, holder, ttl).Err(); err != nil {
+			logging.From(ctx).Warn("Failed to refresh lease", "error", err)
+		}
+	}
+	heartbeat()
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				heartbeat()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		p.redisClient.Del(context.Background(), key)
+	}
+}
+
+func (p *Pool) hasLease(ctx context.Context, conversionID int) bool {
+	n, err := p.redisClient.Exists(ctx, p.leaseKey(conversionID)).Result()
+	if err != nil {
+		// Err on the side of not reclaiming a job we can't check
+		return true
+	}
+	return n > 0
+}
@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"converter/schedule"
+)
+
+type maintenanceState struct {
+	mu      sync.Mutex
+	windows []schedule.Window
+	current schedule.WindowMode
+}
+
+func (p *Pool) SetMaintenanceWindows(windows []schedule.Window) {
+	p.maintenance.mu.Lock()
+	defer p.maintenance.mu.Unlock()
+	p.maintenance.windows = windows
+}
+
+// maintenanceMode returns the mode of any maintenance window active now and
+// logs when the pool enters or leaves one.
+func (p *Pool) maintenanceMode() schedule.WindowMode {
+	p.maintenance.mu.Lock()
+	defer p.maintenance.mu.Unlock()
+
+	if len(p.maintenance.windows) == 0 {
+		return ""
+	}
+
+	mode := schedule.ActiveMode(p.maintenance.windows, time.Now())
+	if mode != p.maintenance.current {
+		if mode == "" {
+			slog.Info("Maintenance window ended, resuming normal consumption", "component", "maintenance")
+		} else {
+			slog.Info("Entering maintenance window", "component", "maintenance", "mode", string(mode))
+		}
+		p.maintenance.current = mode
+	}
+	return mode
+}
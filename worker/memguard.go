@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"converter/metrics"
+	"converter/services"
+)
+
+const memoryGuardInterval = time.Minute
+
+func init() {
+	metrics.Describe("redis_memory_used_percent", "Redis used memory as a percentage of maxmemory, 0 without a limit")
+	metrics.Describe("redis_eviction_unsafe", "1 while Redis runs an eviction policy that can drop queued jobs")
+}
+
+// memoryState remembers what the guard last reported, so it logs when
+// something changes instead of on every pass.
+type memoryState struct {
+	policy string
+	high   bool
+}
+
+// CheckRedisEviction warns at startup when Redis may evict keys without a
+// TTL. Queues have none, so under an allkeys-* policy with maxmemory set a
+// full Redis silently drops queued jobs instead of refusing writes.
+func (p *Pool) CheckRedisEviction(ctx context.Context) {
+	memory, err := services.ReadRedisMemory(ctx, p.redisClient)
+	if err != nil {
+		slog.Warn("Could not verify the Redis eviction policy", "component", "memory", "error", err)
+		return
+	}
+	p.reportEviction(memory)
+}
+
+// MemoryGuardLoop watches Redis memory use and the eviction policy, which
+// can be changed at runtime with CONFIG SET.
+func (p *Pool) MemoryGuardLoop(ctx context.Context) {
+	ticker := time.NewTicker(memoryGuardInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			memory, err := services.ReadRedisMemory(ctx, p.redisClient)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("Failed to read Redis memory", "component", "memory", "error", err)
+				}
+				continue
+			}
+			p.reportEviction(memory)
+			p.reportMemory(memory)
+		}
+	}
+}
+
+func (p *Pool) reportEviction(memory *services.RedisMemory) {
+	unsafe := int64(0)
+	if !memory.EvictionSafe() {
+		unsafe = 1
+	}
+	metrics.Set("redis_eviction_unsafe", unsafe)
+
+	if memory.Policy == p.memory.policy {
+		return
+	}
+	p.memory.policy = memory.Policy
+	if unsafe == 1 {
+		slog.Error("REDIS EVICTION POLICY CAN DROP QUEUED JOBS: set maxmemory-policy to noeviction",
+			"component", "memory",
+			"policy", memory.Policy,
+			"maxmemory", memory.Max,
+			"queue_mirror", p.config.QueueMirror,
+		)
+	}
+}
+
+func (p *Pool) reportMemory(memory *services.RedisMemory) {
+	used := memory.UsedPercent()
+	metrics.Set("redis_memory_used_percent", used)
+
+	high := p.config.RedisMemoryWarnPercent > 0 && used >= int64(p.config.RedisMemoryWarnPercent)
+	if high == p.memory.high {
+		return
+	}
+	p.memory.high = high
+	if high {
+		slog.Warn("Redis memory is nearly full", "component", "memory", "used_percent", used, "policy", memory.Policy)
+	} else {
+		slog.Info("Redis memory back below the warning threshold", "component", "memory", "used_percent", used)
+	}
+}
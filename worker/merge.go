@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"converter/models"
+	"converter/services"
+)
+
+// validateMerge checks a merge job's inputs before anything is downloaded.
+func (p *Pool) validateMerge(job *models.ConversionJob) (models.RejectionReason, string) {
+	if job.ConversionID == 0 || job.OutputS3Path == "" {
+		return models.RejectMalformed, "merge job is missing conversionId or outputS3Path"
+	}
+	if len(job.InputS3Paths) < 2 {
+		return models.RejectMalformed, "merge job needs at least two inputS3Paths"
+	}
+	if p.config.MergeMaxInputs > 0 && len(job.InputS3Paths) > p.config.MergeMaxInputs {
+		return models.RejectTooLarge, fmt.Sprintf("merge job has %d inputs, the limit is %d", len(job.InputS3Paths), p.config.MergeMaxInputs)
+	}
+	for _, input := range job.InputS3Paths {
+		source, isURI, err := services.ParseInputSource(input)
+		if err == nil && isURI {
+			err = services.ValidateInputSource(source, p.config.InputSources, p.config.InputURLAllowedHosts)
+		}
+		if err != nil {
+			return models.RejectMalformed, "merge input " + services.RedactInput(input) + ": " + err.Error()
+		}
+		ext := partExtension(input)
+		if ext == "" || services.IsEmailExtension(ext) || (ext != "pdf" && !p.extensionSupported(ext)) {
+			return models.RejectUnsupportedFormat, "merge input " + services.RedactInput(input) + " is not in a supported format"
+		}
+	}
+	return "", ""
+}
+
+// mergeInputs downloads every part of a merge job, from the configured
+// storage or the source its URI names, converts those that aren't PDFs
+// through their own route and merges them, in the order given, into one
+// PDF/A. It returns the merged file.
+func (p *Pool) mergeInputs(ctx context.Context, job *models.ConversionJob, localPath string, opts services.ConvertOptions) (string, error) {
+	downloaded, err := p.downloadParts(ctx, job.InputS3Paths, localPath)
+	for _, partPath := range downloaded {
+		defer p.storage.Cleanup(partPath)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	parts := make([]string, 0, len(job.InputS3Paths))
+	for i, partPath := range downloaded {
+		ext := partExtension(job.InputS3Paths[i])
+		if _, err := p.scanInput(ctx, partPath); err != nil {
+			return "", fmt.Errorf("failed to scan part %d: %w", i+1, err)
+		}
+
+		// PDFs are made PDF/A by the merge itself
+		if ext != "pdf" {
+			converted, _, err := p.convertFile(ctx, partPath, ext, opts)
+			if err != nil {
+				return "", fmt.Errorf("failed to convert part %d: %w", i+1, err)
+			}
+			defer p.storage.Cleanup(converted)
+			partPath = converted
+		}
+		parts = append(parts, partPath)
+	}
+
+	mergedPath := localPath + ".merged.pdf"
+	if err := p.gotenbergSvc.Merge(ctx, parts, mergedPath, opts); err != nil {
+		return "", err
+	}
+	return mergedPath, nil
+}
+
+// downloadParts downloads the parts of a merge job next to localPath,
+// MERGE_DOWNLOAD_CONCURRENCY at a time, and returns their paths in the
+// order given. The first failure cancels the downloads still running. The
+// paths are returned with the error too, so the caller can remove whatever
+// was written.
+func (p *Pool) downloadParts(ctx context.Context, inputs []string, localPath string) ([]string, error) {
+	concurrency := p.config.MergeDownloadConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	paths := make([]string, len(inputs))
+	slots := make(chan struct{}, concurrency)
+
+	for i, input := range inputs {
+		paths[i] = fmt.Sprintf("%s.part-%03d.%s", localPath, i+1, partExtension(input))
+		wg.Add(1)
+		go func(i int, input string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			err := ctx.Err()
+			if err == nil {
+				err = p.sources.Download(ctx, input, paths[i])
+			}
+			if err == nil {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to download part %d: %w", i+1, err)
+				cancel()
+			}
+		}(i, input)
+	}
+	wg.Wait()
+
+	return paths, firstErr
+}
+
+// partExtension takes a merge part's format from its key, or from the path
+// of its URI.
+func partExtension(input string) string {
+	if source, isURI, err := services.ParseInputSource(input); isURI && err == nil {
+		input = source.Key
+	}
+	return strings.ToLower(strings.TrimPrefix(path.Ext(input), "."))
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestValidateMerge(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{config: &config.Config{
+		SupportedExtensions:  []string{"pdf", "docx", "eml"},
+		MergeMaxInputs:       3,
+		InputSources:         []string{"s3", "https"},
+		InputURLAllowedHosts: []string{"files.example.com"},
+	}}
+
+	merge := func(paths ...string) *models.ConversionJob {
+		return &models.ConversionJob{
+			Type:         models.JobTypeMerge,
+			ConversionID: 7,
+			OutputS3Path: "out/merged.pdf",
+			InputS3Paths: paths,
+		}
+	}
+
+	cases := []struct {
+		name string
+		job  *models.ConversionJob
+		want models.RejectionReason
+	}{
+		{"valid", merge("in/a.pdf", "in/b.DOCX"), ""},
+		{"single input", merge("in/a.pdf"), models.RejectMalformed},
+		{"too many inputs", merge("a.pdf", "b.pdf", "c.pdf", "d.pdf"), models.RejectTooLarge},
+		{"unsupported format", merge("in/a.pdf", "in/b.xlsx"), models.RejectUnsupportedFormat},
+		{"no extension", merge("in/a.pdf", "in/b"), models.RejectUnsupportedFormat},
+		{"email", merge("in/a.pdf", "in/b.eml"), models.RejectUnsupportedFormat},
+		{"mixed sources", merge("in/a.pdf", "s3://archive/b.docx", "https://files.example.com/c.pdf?sig=abc"), ""},
+		{"disabled source", merge("in/a.pdf", "gs://scans/b.pdf"), models.RejectMalformed},
+		{"host not allowed", merge("in/a.pdf", "https://evil.example.com/b.pdf"), models.RejectMalformed},
+		{"unsupported remote format", merge("in/a.pdf", "s3://archive/b.xlsx"), models.RejectUnsupportedFormat},
+		{"missing output", &models.ConversionJob{Type: models.JobTypeMerge, ConversionID: 7, InputS3Paths: []string{"a.pdf", "b.pdf"}}, models.RejectMalformed},
+	}
+	for _, c := range cases {
+		if got, message := p.validateJob(c.job); got != c.want {
+			t.Errorf("%s: validateJob() = %q (%s), want %q", c.name, got, message, c.want)
+		}
+	}
+
+	if got, _ := p.validateJob(&models.ConversionJob{Type: "stitch", ConversionID: 7}); got != models.RejectMalformed {
+		t.Errorf("unknown type: validateJob() = %q, want %q", got, models.RejectMalformed)
+	}
+}
+
+func TestDownloadParts(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	for _, name := range []string{"a.pdf", "b.docx", "c.pdf"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	storage, err := services.NewLocalStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{MergeDownloadConcurrency: 2}
+	p := &Pool{config: cfg, storage: storage, sources: services.NewInputSources(aws.Config{}, cfg, storage, nil)}
+	localPath := filepath.Join(t.TempDir(), "job")
+
+	paths, err := p.downloadParts(context.Background(), []string{"a.pdf", "b.docx", "c.pdf"}, localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"a.pdf", "b.docx", "c.pdf"} {
+		if !strings.HasSuffix(paths[i], want[1:]) {
+			t.Errorf("part %d at %s, want extension of %s", i+1, paths[i], want)
+		}
+		if content, err := os.ReadFile(paths[i]); err != nil || string(content) != want {
+			t.Errorf("part %d = %q, %v; want %q", i+1, content, err, want)
+		}
+	}
+
+	if _, err := p.downloadParts(context.Background(), []string{"a.pdf", "missing.pdf"}, localPath); err == nil || !strings.Contains(err.Error(), "part 2") {
+		t.Errorf("downloadParts() with a missing part = %v, want the part named", err)
+	}
+}
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"converter/models"
+	"converter/services"
+)
+
+// QueueMirrorLoop copies the queued jobs to conversion_queue_mirror every
+// QUEUE_MIRROR_INTERVAL seconds, so jobs lost from Redis, e.g. to eviction,
+// can be put back with `converter restore-queue`.
+func (p *Pool) QueueMirrorLoop(ctx context.Context) {
+	interval := time.Duration(p.config.QueueMirrorInterval) * time.Second
+	if !p.config.QueueMirror || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	slog.Info("Starting queue mirror", "component", "mirror", "interval", interval.String())
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Queue mirror shutting down", "component", "mirror")
+			return
+		case <-ticker.C:
+			p.mirrorQueues(ctx)
+		}
+	}
+}
+
+// mirrorQueues records every waiting and delayed job under the queue it
+// would be restored to. Claimed jobs keep the row from before their claim
+// until they finish and the row is pruned.
+func (p *Pool) mirrorQueues(ctx context.Context) {
+	if !p.IsActive() {
+		return
+	}
+
+	var jobs []services.MirroredJob
+	add := func(queue string, raw string) {
+		var job models.ConversionJob
+		if json.Unmarshal([]byte(raw), &job) != nil || job.ConversionID == 0 {
+			return
+		}
+		if queue == "" {
+			queue = p.requeueTarget(&job)
+		}
+		jobs = append(jobs, services.MirroredJob{ConversionID: job.ConversionID, Queue: queue, Payload: services.RedactPassword(raw, p.config.JobSigningSecrets)})
+	}
+
+	for _, queue := range p.claimableQueues() {
+		raw, err := p.jobQueue.Waiting(ctx, queue, 0, 0)
+		if err != nil {
+			slog.Error("Failed to read queue for mirroring", "component", "mirror", "queue", queue, "error", err)
+			return
+		}
+		for _, r := range raw {
+			add(queue, r)
+		}
+	}
+
+	delayed, err := p.redisClient.ZRange(ctx, p.config.DelayedQueue, 0, -1).Result()
+	if err != nil {
+		slog.Error("Failed to read delayed retries for mirroring", "component", "mirror", "error", err)
+		return
+	}
+	for _, r := range delayed {
+		add("", r)
+	}
+
+	if err := p.db.MirrorQueuedJobs(ctx, jobs); err != nil {
+		slog.Error("Failed to mirror queued jobs", "component", "mirror", "error", err)
+		return
+	}
+	pruned, err := p.db.PruneQueueMirror(ctx)
+	if err != nil {
+		slog.Error("Failed to prune queue mirror", "component", "mirror", "error", err)
+		return
+	}
+	slog.Debug("Mirrored queued jobs", "component", "mirror", "jobs", len(jobs), "pruned", pruned)
+}
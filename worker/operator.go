@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_operator_annotations_total", "Claimed jobs an operator annotation applied to, by annotation")
+}
+
+type forcedEngineKey struct{}
+
+// forcedEngine is the engine an operator pinned the job to, or "".
+func forcedEngine(ctx context.Context) string {
+	engine, _ := ctx.Value(forcedEngineKey{}).(string)
+	return engine
+}
+
+// applyAnnotations honors the operator's annotations on a claimed job. A
+// skipped job is cancelled and a held one goes back through the delayed set
+// to be looked at again later; either way ok is false and the job is done
+// with. A forced engine is carried in the returned context. When the
+// annotations can't be read, the job is converted as if it had none.
+func (p *Pool) applyAnnotations(ctx context.Context, job *models.ConversionJob, jobJSON string) (context.Context, bool) {
+	annotations, err := p.annotations.Get(ctx, job.ConversionID)
+	if err != nil {
+		logging.From(ctx).Warn("Failed to read operator annotations", "error", err)
+		return ctx, true
+	}
+
+	if _, ok := annotations[services.AnnotationSkip]; ok {
+		metrics.Inc("conversion_operator_annotations_total", "annotation", services.AnnotationSkip)
+		p.skipJob(ctx, job, jobJSON)
+		return ctx, false
+	}
+	if _, ok := annotations[services.AnnotationHold]; ok {
+		metrics.Inc("conversion_operator_annotations_total", "annotation", services.AnnotationHold)
+		p.holdJob(ctx, jobJSON)
+		return ctx, false
+	}
+	if engine := annotations[services.AnnotationForceEngine]; engine != "" {
+		metrics.Inc("conversion_operator_annotations_total", "annotation", services.AnnotationForceEngine)
+		logging.From(ctx).Info("Operator forced the conversion engine", "engine", engine)
+		ctx = context.WithValue(ctx, forcedEngineKey{}, engine)
+	}
+	return ctx, true
+}
+
+// skipJob cancels a job an operator marked to be skipped.
+func (p *Pool) skipJob(ctx context.Context, job *models.ConversionJob, jobJSON string) {
+	logging.From(ctx).Info("Operator skipped conversion, cancelling")
+
+	p.dbUpdater.UpdateStatus(job.ConversionID, models.StatusCancelled, "", nil)
+	p.dbUpdater.UpdateError(job.ConversionID, "Skipped by operator")
+	if err := p.statusStore.Set(ctx, job.ConversionID, models.StatusCancelled, map[string]interface{}{
+		"error": "Skipped by operator",
+	}); err != nil {
+		logStatusError(ctx, "Redis", err)
+	}
+	p.ack(ctx, jobJSON)
+}
+
+// holdJob puts a held job back through the delayed set without using a
+// retry, to be claimed and checked again after CONVERSION_HOLD_RECHECK_SECONDS.
+func (p *Pool) holdJob(ctx context.Context, jobJSON string) {
+	delay := time.Duration(p.config.HoldRecheckDelay) * time.Second
+	logging.From(ctx).Info("Conversion is on hold, checking again later", "delay", delay.String())
+
+	if err := p.scheduleRetry(ctx, []byte(p.sealPayload(ctx, jobJSON)), delay); err != nil {
+		// Leave it in processing for recovery rather than lose it
+		logging.From(ctx).Error("Failed to put back held conversion", "error", err)
+		return
+	}
+	p.ack(ctx, jobJSON)
+}
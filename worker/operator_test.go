@@ -0,0 +1,24 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+)
+
+func TestCostEngine_Forced(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{config: &config.Config{CostPeakEngine: services.EngineSoffice}}
+	ctx := context.WithValue(context.Background(), forcedEngineKey{}, services.EngineSoffice)
+
+	if got := p.costEngine(ctx, &models.ConversionJob{ConversionID: 7}); got != services.EngineSoffice {
+		t.Fatalf("costEngine = %q, want the forced engine", got)
+	}
+	if got := forcedEngine(context.Background()); got != "" {
+		t.Fatalf("forcedEngine without annotation = %q, want empty", got)
+	}
+}
@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"errors"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_password_required_total", "Password-protected inputs that came without the right password")
+}
+
+// rejectPasswordRequired finishes a job whose input LibreOffice couldn't
+// open for want of the right password as password_required. Retrying
+// can't help: the producer has to ask the user and enqueue a new job with
+// the password.
+func (p *Pool) rejectPasswordRequired(ctx context.Context, job *models.ConversionJob, jobJSON string, audit *services.AuditRecord) {
+	message := "Password required"
+	if job.Password != "" {
+		message = "Wrong password"
+	}
+	logging.From(ctx).Warn("Input is password protected", "password_given", job.Password != "")
+	metrics.Inc("conversion_password_required_total")
+	p.finishAs(ctx, job, jobJSON, audit, models.StatusPasswordRequired, message, nil, nil)
+}
+
+// resolvePassword fills in the password of a job that carries a
+// passwordRef. A reference that names no secret leaves the job without a
+// password, so a protected input ends as password_required.
+func (p *Pool) resolvePassword(ctx context.Context, job *models.ConversionJob) error {
+	if job.PasswordRef == "" || job.Password != "" {
+		return nil
+	}
+	password, err := p.jobSecrets.Resolve(ctx, job.PasswordRef)
+	if errors.Is(err, services.ErrSecretNotFound) {
+		logging.From(ctx).Warn("Job password reference has expired or was never stored")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	job.Password = password
+	return nil
+}
+
+// sealPassword moves the job's password into a secret before the job is
+// written back to a queue, so only its reference is in the payload. If the
+// secret can't be stored the password is dropped.
+func (p *Pool) sealPassword(ctx context.Context, job *models.ConversionJob) {
+	if job.Password == "" {
+		return
+	}
+	if job.PasswordRef == "" {
+		ref, err := p.jobSecrets.Store(ctx, job.Password)
+		if err != nil {
+			logging.From(ctx).Warn("Failed to store job password, dropping it", "error", err)
+		}
+		job.PasswordRef = ref
+	}
+	job.Password = ""
+}
+
+// sealPayload is the claimed payload as it goes to another queue: without
+// the claim token, and with its password sealed like sealPassword does.
+func (p *Pool) sealPayload(ctx context.Context, jobJSON string) string {
+	payload, err := p.jobSecrets.Seal(ctx, withoutClaimToken(jobJSON), p.config.JobSigningSecrets)
+	if err != nil {
+		logging.From(ctx).Warn("Failed to store job password, dropping it", "error", err)
+	}
+	return payload
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Claim strategies decide how a worker waits once every queue it serves
+// came up empty.
+const (
+	// ClaimBlockHigh blocks on the high priority queue so interactive jobs
+	// start promptly.
+	ClaimBlockHigh = "block-high"
+	// ClaimBlockPreferred blocks on the queue the fair scheduler picked for
+	// this claim.
+	ClaimBlockPreferred = "block-preferred"
+	// ClaimPoll never blocks and sleeps for the idle interval instead, for
+	// Redis proxies that drop or stall long-running blocking commands.
+	ClaimPoll = "poll"
+)
+
+// ValidateClaimConfig checks the claim strategy and its timings.
+func ValidateClaimConfig(strategy string, blockSeconds int, idleMs int, errorMs int) error {
+	switch strategy {
+	case ClaimBlockHigh, ClaimBlockPreferred:
+		// BLMOVE with a zero timeout blocks forever and would hang shutdown
+		if blockSeconds < 1 {
+			return fmt.Errorf("block timeout must be at least 1 second, got %d", blockSeconds)
+		}
+	case ClaimPoll:
+		if idleMs < 1 {
+			return fmt.Errorf("idle sleep must be at least 1ms, got %d", idleMs)
+		}
+	default:
+		return fmt.Errorf("unknown claim strategy %q", strategy)
+	}
+	if errorMs < 0 {
+		return fmt.Errorf("error backoff must not be negative, got %d", errorMs)
+	}
+	return nil
+}
+
+// moveToProcessing atomically moves the oldest entry of queue to the
+// processing queue, or returns redis.Nil when queue is empty. On the stream
+// backend the entry is claimed for the consumer group instead and stays in
+// its stream until acked.
+func (p *Pool) moveToProcessing(ctx context.Context, queue string) (string, error) {
+	if p.jobQueue.Streams() {
+		return p.readStream(ctx, queue, 0)
+	}
+	return p.redisClient.LMove(ctx, queue, p.config.ProcessingQueue, "RIGHT", "LEFT").Result()
+}
+
+// waitForJob is called after every served queue came up empty. With the
+// blocking strategy it waits up to CONVERSION_CLAIM_BLOCK_SECONDS for a job
+// on queue: on the list backend with BLMOVE, which moves the job to
+// processing, and on the stream backend with XREADGROUP, which claims the
+// entry for the consumer group. The polling strategy sleeps for the idle
+// interval instead, on either backend, and leaves the next claim to the
+// caller. Either way it returns redis.Nil when no job arrived.
+func (p *Pool) waitForJob(ctx context.Context, queue string) (string, error) {
+	if p.config.ClaimStrategy == ClaimPoll {
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(p.config.ClaimIdleSleepMs) * time.Millisecond):
+		}
+		return "", redis.Nil
+	}
+	if p.jobQueue.Streams() {
+		return p.readStream(ctx, queue, time.Duration(p.config.ClaimBlockTimeout)*time.Second)
+	}
+	return p.redisClient.BLMove(
+		ctx,
+		queue,
+		p.config.ProcessingQueue,
+		"RIGHT",
+		"LEFT",
+		time.Duration(p.config.ClaimBlockTimeout)*time.Second,
+	).Result()
+}
+
+// claimErrorBackoff pauses after a Redis error before the next claim.
+func (p *Pool) claimErrorBackoff(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Duration(p.config.ClaimErrorSleepMs) * time.Millisecond):
+	}
+}
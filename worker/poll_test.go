@@ -0,0 +1,27 @@
+package worker
+
+import "testing"
+
+func TestValidateClaimConfig(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		strategy          string
+		block, idle, errs int
+		ok                bool
+	}{
+		{ClaimBlockHigh, 2, 0, 5000, true},
+		{ClaimBlockPreferred, 1, 0, 0, true},
+		{ClaimBlockHigh, 0, 500, 5000, false},
+		{ClaimPoll, 0, 250, 1000, true},
+		{ClaimPoll, 2, 0, 1000, false},
+		{ClaimBlockHigh, 2, 0, -1, false},
+		{"brpoplpush", 2, 500, 5000, false},
+	}
+	for _, c := range cases {
+		err := ValidateClaimConfig(c.strategy, c.block, c.idle, c.errs)
+		if (err == nil) != c.ok {
+			t.Errorf("ValidateClaimConfig(%q, %d, %d, %d) = %v, want ok=%v", c.strategy, c.block, c.idle, c.errs, err, c.ok)
+		}
+	}
+}
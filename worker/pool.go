@@ -3,115 +3,647 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"converter/config"
+	"converter/logging"
 	"converter/models"
+	"converter/queue"
+	"converter/schedule"
 	"converter/services"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/redis/go-redis/v9"
 )
 
 type Pool struct {
-	config       *config.Config
-	redisClient  *redis.Client
-	gotenbergSvc *services.GotenbergService
-	s3Svc        *services.S3Service
-	dbSvc        *services.DatabaseService
+	config         *config.Config
+	redisClient    *redis.Client
+	gotenbergSvc   *services.GotenbergService
+	storage        services.Storage
+	dbUpdater      *services.StatusUpdater
+	statusStore    *services.StatusStore
+	pdfTools       *services.PDFToolsService
+	audits         *services.AuditWriter
+	encryptionSvc  *services.EncryptionService
+	flags          *services.FeatureFlags
+	counters       runCounters
+	promoted       atomic.Bool
+	maintenance    maintenanceState
+	scheduler      *fairScheduler
+	imagingSvc     *services.ImagingService
+	perfStats      *services.PerformanceStats
+	leaseMisses    map[int]bool
+	tempStore      *services.TempStore
+	events         *services.EventRouter
+	tenants        *services.TenantConfigs
+	sofficeSvc     *services.SofficeService
+	cost           costState
+	thumbnailSizes map[string]int
+	jobQueue       *services.JobQueue
+	source         queue.Source
+	visibility     time.Duration
+	db             *services.DatabaseService
+	memory         memoryState
+	diskPressure   atomic.Bool
+	memoryPressure atomic.Bool
+	inFlight       atomic.Int64
+	annotations    *services.JobAnnotations
+	controls       *services.JobControls
+	kinds          map[string]bool
+	retention      map[string]services.Retention
+	scanner        *services.ClamAV
+	rollups        *services.Rollups
+	sources        *services.InputSources
+	routes         *services.Routes
+	webhooks       *webhookConversions
+	breakers       []*services.Breaker
+	tenantLimiter  *services.TenantLimiter
+	completedJobs  *services.CompletedJobs
+	jobSecrets     *services.JobSecrets
+	metricLabels   []string
+	labelRules     services.LabelPriorities
+	unacked        sync.Map
+	runOnce        bool
 }
 
-func NewPool(cfg *config.Config, redisClient *redis.Client, dbSvc *services.DatabaseService) *Pool {
-	return &Pool{
-		config:       cfg,
-		redisClient:  redisClient,
-		gotenbergSvc: services.NewGotenbergService(cfg.GotenbergURL),
-		s3Svc:        services.NewS3Service(cfg),
-		dbSvc:        dbSvc,
+func NewPool(cfg *config.Config, awsCfg aws.Config, redisClient *redis.Client, dbSvc *services.DatabaseService, dbUpdater *services.StatusUpdater, storage services.Storage, s3Limiter *services.TokenBucket) *Pool {
+	p := &Pool{
+		config:        cfg,
+		redisClient:   redisClient,
+		db:            dbSvc,
+		gotenbergSvc:  services.NewGotenbergService(cfg.GotenbergURL, cfg.GotenbergMaxResponseBytes, services.NewRequestIdentity(cfg)),
+		storage:       storage,
+		dbUpdater:     dbUpdater,
+		statusStore:   services.NewStatusStore(redisClient),
+		pdfTools:      services.NewPDFToolsService(),
+		encryptionSvc: services.NewEncryptionService(awsCfg, cfg),
+		scheduler:     newFairScheduler(cfg.PriorityWeights),
+		imagingSvc:    services.NewImagingService(cfg.ImageMaxDPI),
+		sofficeSvc:    services.NewSofficeService(cfg.SofficePath),
+		perfStats:     services.NewPerformanceStats(redisClient, cfg.RedisPrefix),
+		leaseMisses:   make(map[int]bool),
+		jobQueue:      services.NewJobQueue(redisClient, cfg.QueueBackend, cfg.StreamGroup),
+		annotations:   services.NewJobAnnotations(redisClient, cfg.RedisPrefix, time.Duration(cfg.AnnotationTTL)*time.Second),
+		controls:      services.NewJobControls(redisClient, cfg.RedisPrefix),
+		tenantLimiter: services.NewTenantLimiter(redisClient, cfg.RedisPrefix),
+		jobSecrets:    services.NewJobSecrets(redisClient, cfg.RedisPrefix, time.Duration(cfg.PasswordTTL)*time.Second),
+		tempStore:     services.NewTempStore(cfg),
+		sources:       services.NewInputSources(awsCfg, cfg, storage, s3Limiter),
+		flags: services.NewFeatureFlags(
+			redisClient,
+			cfg.RedisPrefix+"conversion:flags",
+			time.Duration(cfg.FeatureFlagCacheTTL)*time.Second,
+		),
 	}
+
+	p.tenants = services.NewTenantConfigs(
+		redisClient,
+		cfg.RedisPrefix+"conversion:tenants",
+		time.Duration(cfg.TenantConfigCacheTTL)*time.Second,
+	)
+	p.events = services.NewEventRouter(awsCfg, cfg, p.tenants)
+
+	if cfg.AuditEnabled {
+		// Audit records go to S3 whatever the storage, for its object lock
+		auditS3, ok := storage.(*services.S3Service)
+		if !ok {
+			auditS3 = services.NewS3Service(awsCfg, cfg, s3Limiter)
+		}
+		p.audits = services.NewAuditWriter(services.NewAuditService(cfg, dbSvc, auditS3), cfg.AuditQueueSize)
+		go p.audits.Run()
+	}
+	if cfg.ClamAVAddr != "" {
+		p.scanner = services.NewClamAV(cfg.ClamAVAddr)
+	}
+	if cfg.Rollups {
+		p.rollups = services.NewRollups()
+	}
+	if cfg.IdempotencyTTL > 0 {
+		p.completedJobs = services.NewCompletedJobs(redisClient, cfg.RedisPrefix, time.Duration(cfg.IdempotencyTTL)*time.Second)
+	}
+	p.setupBreakers()
+
+	return p
 }
 
 func (p *Pool) StartWorker(ctx context.Context, workerID int) {
-	log.Printf("[Worker %d] Starting", workerID)
+	p.work(logging.With(ctx, "worker_id", workerID), workerID, false)
+}
+
+// StartRetryLaneWorker runs a worker reserved for the user-initiated retry
+// lane, so a manual retry never waits behind the backlog.
+func (p *Pool) StartRetryLaneWorker(ctx context.Context, workerID int) {
+	p.work(logging.With(ctx, "worker_id", workerID, "lane", "retry"), workerID, true)
+}
+
+func (p *Pool) work(ctx context.Context, workerID int, retryLane bool) {
+	logger := logging.From(ctx)
+	logger.Info("Worker starting")
+	defer p.waitDetached()
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("[Worker %d] Shutting down", workerID)
+			logger.Info("Worker shutting down")
 			return
 		default:
+			// Standby deployments don't claim until promoted
+			if !p.IsActive() {
+				time.Sleep(time.Second)
+				continue
+			}
+
+			// Storage/DB maintenance may pause consumption entirely
+			mode := p.maintenanceMode()
+			if mode == schedule.ModePause {
+				time.Sleep(5 * time.Second)
+				continue
+			}
+
+			// A full temp disk would fail every job that claims now
+			if p.diskPressure.Load() {
+				time.Sleep(diskGuardInterval)
+				continue
+			}
+
+			// So would Gotenberg or S3 being down
+			if p.breakerOpen() {
+				time.Sleep(breakerPollInterval)
+				continue
+			}
+
+			// Don't take on more than the instance can hold
+			if p.saturated() {
+				time.Sleep(backpressureInterval)
+				continue
+			}
+
 			// Atomic pop from pending and push to processing
-			result, err := p.redisClient.BRPopLPush(
-				ctx,
-				p.config.PendingQueue,
-				p.config.ProcessingQueue,
-				30*time.Second,
-			).Result()
+			var result string
+			var err error
+			if retryLane {
+				result, err = p.claimRetryLane(ctx)
+			} else {
+				result, err = p.claim(ctx, mode)
+			}
 
 			if err == redis.Nil {
+				// In run-once mode an empty queue with no retries still
+				// scheduled means the backlog is drained
+				if p.runOnce {
+					if !p.hasDelayedRetries(ctx) {
+						logger.Info("Pending queue drained, exiting")
+						return
+					}
+					time.Sleep(time.Second)
+				}
 				// Timeout, no jobs available
 				continue
 			}
 
 			if err != nil {
-				log.Printf("[Worker %d] Redis error: %v", workerID, err)
-				time.Sleep(5 * time.Second)
+				logger.Error("Redis error", "error", err)
+				p.claimErrorBackoff(ctx)
 				continue
 			}
 
-			// Parse job
-			var job models.ConversionJob
-			if err := json.Unmarshal([]byte(result), &job); err != nil {
-				log.Printf("[Worker %d] Failed to parse job: %v", workerID, err)
-				// Remove malformed job from processing queue
-				p.redisClient.LRem(ctx, p.config.ProcessingQueue, 1, result)
+			// Make this claim's processing entry unique before anything acks it
+			result, claimed, err := p.tagClaim(ctx, result)
+			if err != nil {
+				logger.Warn("Failed to tag claim, acking by payload", "error", err)
+			}
+			if !claimed {
 				continue
 			}
 
-			// Process job
-			p.processJob(ctx, workerID, &job, result)
+			p.runClaim(ctx, workerID, result)
 		}
 	}
 }
 
-func (p *Pool) processJob(ctx context.Context, workerID int, job *models.ConversionJob, jobJSON string) {
-	log.Printf("[Worker %d] Processing conversion %d (file: %s)", workerID, job.ConversionID, job.FileGUID)
+// handleClaim validates a claimed job and processes it, or hands it off
+// when it can't or shouldn't run here now.
+func (p *Pool) handleClaim(ctx context.Context, workerID int, result string) {
+	// Only trusted producers may enqueue work
+	if !p.verifyJob(ctx, result) {
+		return
+	}
+
+	// Parse job
+	var job models.ConversionJob
+	if err := json.Unmarshal([]byte(result), &job); err != nil {
+		// Remove malformed job from processing queue
+		p.rejectJob(ctx, nil, result, models.RejectMalformed, fmt.Sprintf("Failed to parse job: %v", err))
+		return
+	}
+
+	// Correlate every log line for this job, reusing the producer's
+	// trace ID when it sent one
+	jobCtx := logging.With(logging.WithTraceID(ctx, job.TraceID),
+		"conversion_id", job.ConversionID,
+		"file_guid", job.FileGUID,
+	)
+	if len(job.Labels) > 0 {
+		jobCtx = logging.With(jobCtx, "labels", job.Labels)
+	}
+
+	// Never process another region's documents; hand them back
+	if job.Region != p.config.Region {
+		p.rerouteRegion(jobCtx, &job, result)
+		return
+	}
+
+	// Refuse jobs that can never succeed instead of burning retries
+	if reason, message := p.validateJob(&job); reason != "" {
+		p.rejectJob(jobCtx, &job, result, reason, message)
+		return
+	}
 
-	// Update DB status to processing
-	if err := p.dbSvc.UpdateConversionStatus(ctx, job.ConversionID, "processing", "", nil); err != nil {
-		log.Printf("[Worker %d] Failed to update DB status: %v", workerID, err)
+	// Trashing and restoring outputs converts nothing
+	if job.MovesOutputs() {
+		p.processOutputJob(jobCtx, workerID, &job, result)
+		return
+	}
+
+	// Leave kinds this instance isn't sized for to the instances that are
+	if kind := services.KindOf(&job); !p.takesKind(kind) {
+		p.handOffKind(jobCtx, &job, result, kind)
+		return
 	}
 
-	// Create timeout context
-	timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(job.Timeout)*time.Second)
+	// Operators can hold, skip or pin the engine of individual jobs
+	jobCtx, ok := p.applyAnnotations(jobCtx, &job, result)
+	if !ok {
+		return
+	}
+
+	// Producers can cancel a job or move its deadline after enqueueing it
+	if p.stopRequested(jobCtx, &job, result, "claimed") {
+		return
+	}
+
+	// Operators can raise or lower the priority of whole ingest channels
+	p.applyLabelPriority(jobCtx, &job)
+
+	// Off the fast path, normal priority work waits behind the backlog
+	if p.deferForCost(jobCtx, &job, result) {
+		return
+	}
+
+	// Nor may one tenant's bulk upload take every worker
+	if p.throttleTenant(jobCtx, &job, result) {
+		return
+	}
+
+	// One conversion per document at a time
+	releaseFile, ok := p.lockFile(jobCtx, workerID, &job, result)
+	if !ok {
+		return
+	}
+
+	// A copy of a job that already completed, which the file lock kept
+	// from running alongside it
+	if p.skipDuplicate(jobCtx, &job, result) {
+		releaseFile()
+		return
+	}
+
+	// Process job
+	p.processJob(jobCtx, workerID, &job, result)
+	releaseFile()
+}
+
+// SetMarkdownTemplate replaces the built-in HTML wrapper used for Markdown
+// inputs.
+func (p *Pool) SetMarkdownTemplate(tmpl []byte) {
+	p.gotenbergSvc.SetMarkdownTemplate(tmpl)
+}
+
+// SetRunOnce makes workers exit once the pending queue is empty instead of
+// blocking for new work.
+func (p *Pool) SetRunOnce(runOnce bool) {
+	p.runOnce = runOnce
+}
+
+// claim takes the next job from the priority queues in the order chosen by
+// the fair scheduler, after the kind queues of the kinds this instance
+// takes. During priority-only maintenance windows only the high priority
+// queue is consumed. When every queue is empty the worker waits according
+// to the claim strategy. Without reserved lane workers, the retry lane is
+// served first.
+func (p *Pool) claim(ctx context.Context, mode schedule.WindowMode) (string, error) {
+	if p.source != nil {
+		return p.receiveJob(ctx)
+	}
+
+	queues := make([]string, 0, len(models.Priorities)+1)
+	if p.config.RetryLaneWorkers <= 0 {
+		queues = append(queues, p.config.RetryLaneQueue)
+	}
+	order := p.scheduler.order()
+	if mode == schedule.ModePriorityOnly {
+		order = []models.Priority{models.PriorityHigh}
+	} else {
+		queues = append(queues, p.kindQueues()...)
+	}
+	for _, priority := range order {
+		queues = append(queues, p.pendingQueue(priority))
+	}
+
+	for _, queue := range queues {
+		result, err := p.moveToProcessing(ctx, queue)
+		if err != redis.Nil {
+			return result, err
+		}
+	}
+
+	if p.runOnce {
+		return "", redis.Nil
+	}
+	wait := p.config.HighPriorityQueue
+	if p.config.ClaimStrategy == ClaimBlockPreferred {
+		wait = queues[0]
+	}
+	return p.waitForJob(ctx, wait)
+}
+
+func (p *Pool) processJob(ctx context.Context, workerID int, job *models.ConversionJob, jobJSON string) {
+	logger := logging.From(ctx)
+	logger.Info("Processing conversion")
+
+	// Journal the claim locally so a crash can be reconciled on restart
+	journal := p.beginJournal(ctx, workerID, job, jobJSON)
+	defer journal.finish()
+
+	// Outputs are locked as they are written
+	ctx = p.withRetention(ctx, job)
+
+	p.markProcessing(ctx, job)
+
+	// Hold a lease so recovery doesn't reclaim the job mid-flight
+	releaseLease := p.startLease(ctx, workerID, job.ConversionID)
+	defer releaseLease()
+
+	// Create timeout context; it is narrowed to the adaptive timeout once the
+	// input size is known
+	timeoutCtx, cancel := context.WithTimeout(ctx, p.baseTimeout(job))
 	defer cancel()
 
 	// Track start time
 	startTime := time.Now()
-
-	// Download from S3
-	localInputPath, err := p.s3Svc.Download(timeoutCtx, job.InputS3Path, job.FileGUID, job.InputExtension)
+	audit := newAuditRecord(workerID, job)
+
+	// The input size enforces CONVERSION_MAX_INPUT_BYTES, and lets small
+	// jobs skip temp files altogether or use the fast temp directory
+	inputSize := int64(-1)
+	if (p.tempStore.FastEnabled() || p.config.StreamMaxBytes > 0 || p.config.MaxInputBytes > 0) && !job.IsMerge() {
+		if info, err := p.storage.Stat(timeoutCtx, job.InputS3Path); err == nil {
+			inputSize = info.Size
+		}
+	}
+	// Refuse inputs that would take the worker and Gotenberg down, before
+	// anything is downloaded
+	if message, tooLarge := p.inputTooLarge(inputSize); tooLarge {
+		p.rejectJobWith(ctx, job, jobJSON, models.RejectTooLarge, message, map[string]interface{}{"input_bytes": inputSize})
+		return
+	}
+	if err := p.resolvePassword(timeoutCtx, job); err != nil {
+		p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("Password lookup failed: %v", err))
+		return
+	}
+	convertOpts := p.convertOptions(ctx, job)
+	if p.streamable(ctx, job, inputSize, convertOpts) {
+		p.processStreamed(ctx, workerID, job, jobJSON, audit, journal, convertOpts, inputSize, startTime)
+		return
+	}
+	tempLease, err := p.tempStore.Reserve(strconv.Itoa(job.ConversionID), inputSize)
 	if err != nil {
-		p.handleJobFailure(ctx, workerID, job, jobJSON, fmt.Sprintf("S3 download failed: %v", err))
+		p.handleJobFailure(ctx, workerID, job, jobJSON, audit, err.Error())
+		return
+	}
+	defer tempLease.Release()
+	journal.TempDir = tempLease.Dir
+	localExtension := job.InputExtension
+	if job.IsMerge() {
+		localExtension = "pdf"
+	}
+	localInputPath := tempLease.LocalPath(job.FileGUID, localExtension)
+	journal.TempPrefix = localInputPath
+
+	// Download from S3; merge jobs fetch their parts when merging
+	declaredExtension := job.InputExtension
+	var scan *services.ScanResult
+	if !job.IsMerge() {
+		journal.setStage("downloading")
+		if err := p.storage.Download(timeoutCtx, job.InputS3Path, localInputPath); err != nil {
+			p.storage.Cleanup(localInputPath)
+			p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("S3 download failed: %v", err))
+			return
+		}
+		defer p.storage.Cleanup(localInputPath)
+
+		// Trust the content over a missing or wrong extension
+		if p.config.DetectFormat {
+			resolvedPath, err := p.detectFormat(ctx, job, localInputPath)
+			var rejection *contentRejection
+			if errors.As(err, &rejection) {
+				p.rejectJob(ctx, job, jobJSON, rejection.reason, rejection.message)
+				return
+			} else if err != nil {
+				logger.Warn("Format detection failed, keeping declared extension", "error", err)
+			} else if resolvedPath != localInputPath {
+				defer p.storage.Cleanup(resolvedPath)
+				localInputPath = resolvedPath
+				journal.TempPrefix = localInputPath
+			}
+
+			if job.InputExtension == "" {
+				p.rejectJob(ctx, job, jobJSON, models.RejectUnsupportedFormat, "file has no extension and its format could not be detected")
+				return
+			}
+			if !p.extensionSupported(job.InputExtension) {
+				p.rejectJob(ctx, job, jobJSON, models.RejectUnsupportedFormat, "file format ."+job.InputExtension+" is not supported")
+				return
+			}
+		}
+
+		// Nothing is converted before clamd has seen it
+		scan, err = p.scanInput(timeoutCtx, localInputPath)
+		var infected *infectedInput
+		if errors.As(err, &infected) {
+			p.rejectInfected(ctx, job, jobJSON, audit, infected)
+			return
+		} else if err != nil {
+			p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("Virus scan failed: %v", err))
+			return
+		}
+		audit.InputSHA256 = p.checksum(localInputPath)
+		if info, err := os.Stat(localInputPath); err == nil {
+			inputSize = info.Size()
+		}
+	}
+	if p.stopRequested(ctx, job, jobJSON, "downloaded") {
+		return
+	}
+	timeoutCtx, cancelAdaptive := context.WithDeadline(ctx, startTime.Add(p.jobTimeout(ctx, job, inputSize)))
+	defer cancelAdaptive()
+
+	// Route by format; emails are rendered with Chromium and may have their
+	// attachments converted and appended, and merge jobs combine their parts
+	journal.setStage("converting")
+	inspection := p.inspectSource(timeoutCtx, localInputPath, job.InputExtension)
+	var localOutputPath string
+	var emailAttachments []attachmentResult
+	switch {
+	case job.IsMerge():
+		audit.Engine = mergeAuditEngine
+		localOutputPath, err = p.mergeInputs(timeoutCtx, job, localInputPath, convertOpts)
+		var infected *infectedInput
+		if errors.As(err, &infected) {
+			p.rejectInfected(ctx, job, jobJSON, audit, infected)
+			return
+		} else if errors.Is(err, services.ErrPasswordRequired) {
+			p.rejectPasswordRequired(ctx, job, jobJSON, audit)
+			return
+		} else if errors.Is(err, services.ErrInputTooLarge) {
+			p.rejectJobWith(ctx, job, jobJSON, models.RejectTooLarge, err.Error(), nil)
+			return
+		} else if err != nil {
+			p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("Merge failed: %v", err))
+			return
+		}
+	case services.IsEmailExtension(job.InputExtension):
+		audit.Engine = emailAuditEngine
+		localOutputPath, emailAttachments, err = p.convertEmail(timeoutCtx, job, localInputPath, convertOpts)
+		if err != nil {
+			p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("Email conversion failed: %v", err))
+			return
+		}
+	case (len(job.Assets) > 0 || job.Header != "" || job.Footer != "") && services.IsHTMLExtension(job.InputExtension):
+		audit.Engine = htmlAuditEngine
+		localOutputPath, err = p.convertHTML(timeoutCtx, job, localInputPath, convertOpts)
+		if err != nil {
+			p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("HTML conversion failed: %v", err))
+			return
+		}
+	default:
+		localOutputPath, audit.Engine, err = p.convertFile(timeoutCtx, localInputPath, job.InputExtension, convertOpts)
+		if errors.Is(err, services.ErrPasswordRequired) {
+			p.rejectPasswordRequired(ctx, job, jobJSON, audit)
+			return
+		} else if err != nil {
+			if p.routes.For(job.InputExtension) == services.RouteMarkdown {
+				err = p.captureChromiumDebug(ctx, job, err, localInputPath, func(ctx context.Context, outputPath string, failOnConsole bool) error {
+					return p.gotenbergSvc.ScreenshotMarkdown(ctx, localInputPath, outputPath, failOnConsole)
+				})
+			}
+			p.handleJobFailure(ctx, workerID, job, jobJSON, audit, err.Error())
+			return
+		}
+	}
+	defer p.storage.Cleanup(localOutputPath)
+	if p.stopRequested(ctx, job, jobJSON, "converted") {
 		return
 	}
-	defer p.s3Svc.Cleanup(localInputPath)
+	audit.OutputSHA256 = p.checksum(localOutputPath)
+	annotations := p.annotate(timeoutCtx, inspection, localOutputPath, emailAttachments)
+
+	// The output's optional metadata follows. Each step logs its failure and
+	// leaves its field out, and the conversion still completes.
 
-	// Convert to PDF/A using LibreOffice endpoint (office files only)
-	localOutputPath, err := p.gotenbergSvc.ConvertToPDFA(timeoutCtx, localInputPath, job.InputExtension)
+	// Describe the output for search and billing
+	summary, err := p.pdfTools.Summary(timeoutCtx, localOutputPath)
 	if err != nil {
-		p.handleJobFailure(ctx, workerID, job, jobJSON, fmt.Sprintf("Office conversion failed: %v", err))
+		logger.Warn("PDF summary failed", "error", err)
+	}
+
+	// Detect the language for OCR and search analyzers
+	language := p.detectLanguage(timeoutCtx, localOutputPath)
+
+	// Score the text layer, so search knows whether to trust it
+	textQuality := p.textQuality(timeoutCtx, localOutputPath)
+
+	// Score tagged output for accessibility
+	var accessibility *services.AccessibilityReport
+	if convertOpts.Accessible {
+		if accessibility, err = p.pdfTools.Accessibility(timeoutCtx, localOutputPath); err != nil {
+			logger.Warn("Accessibility check failed", "error", err)
+		}
+	}
+
+	// Cut the output into parts when the job asks for chunked delivery
+	var splitParts []string
+	if job.Split != nil {
+		journal.setStage("splitting")
+		splitParts, err = p.gotenbergSvc.Split(timeoutCtx, localOutputPath, *job.Split, convertOpts)
+		if err != nil {
+			p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("PDF split failed: %v", err))
+			return
+		}
+		for _, part := range splitParts {
+			defer p.storage.Cleanup(part)
+		}
+	}
+
+	// Generate a per-job envelope key when outputs must be encrypted
+	dataKey, err := p.outputDataKey(timeoutCtx, job)
+	if err != nil {
+		p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("Encryption key generation failed: %v", err))
 		return
 	}
-	defer p.s3Svc.Cleanup(localOutputPath)
 
-	// Upload PDF to S3
-	if err := p.s3Svc.Upload(timeoutCtx, localOutputPath, job.OutputS3Path); err != nil {
-		p.handleJobFailure(ctx, workerID, job, jobJSON, fmt.Sprintf("S3 upload failed: %v", err))
+	if p.stopRequested(ctx, job, jobJSON, "uploading") {
 		return
 	}
+	journal.setStage("uploading")
+	outputPath := job.OutputS3Path
+	var artifacts map[string]string
+	var bundleEntries []services.BundleEntry
+	var dedup map[string]interface{}
+	var splitKeys []string
+	if job.Bundle {
+		// Package PDF/A, split parts and all artifacts into a single ZIP at
+		// the output key
+		bundleEntries, err = p.uploadBundle(timeoutCtx, job, localOutputPath, splitParts, dataKey)
+		if err != nil {
+			p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("Bundle upload failed: %v", err))
+			return
+		}
+	} else {
+		// Upload PDF to S3
+		outputPath, dedup, err = p.uploadPrimary(timeoutCtx, job, dataKey, localOutputPath)
+		if err != nil {
+			p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("S3 upload failed: %v", err))
+			return
+		}
+
+		if len(splitParts) > 0 {
+			splitKeys, err = p.uploadSplitParts(timeoutCtx, job, dataKey, splitParts)
+			if err != nil {
+				p.handleJobFailure(ctx, workerID, job, jobJSON, audit, err.Error())
+				return
+			}
+		}
+
+		// Produce any additional artifacts from the same conversion
+		artifacts, err = p.produceArtifacts(timeoutCtx, job, localOutputPath, dataKey)
+		if err != nil {
+			p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("Artifact generation failed: %v", err))
+			return
+		}
+	}
+
+	// Render previews for the frontend
+	thumbnails := p.produceThumbnails(timeoutCtx, job, localOutputPath, dataKey)
 
 	// Success - update DB and remove from processing queue
 	duration := time.Since(startTime)
@@ -119,36 +651,147 @@ func (p *Pool) processJob(ctx context.Context, workerID int, job *models.Convers
 		"worker_id":   workerID,
 		"duration_ms": duration.Milliseconds(),
 	}
+	if summary != nil {
+		metadata["pdf"] = summary
+	}
+	if len(artifacts) > 0 {
+		metadata["artifacts"] = artifacts
+	}
+	if len(bundleEntries) > 0 {
+		metadata["bundle"] = bundleEntries
+	}
+	if len(thumbnails) > 0 {
+		metadata["thumbnails"] = thumbnails
+	}
+	if len(splitKeys) > 0 {
+		metadata["split"] = splitKeys
+	}
+	if dataKey != nil {
+		metadata["encryption"] = dataKey.Metadata()
+	}
+	if dedup != nil {
+		metadata["dedup"] = dedup
+	}
+	if accessibility != nil {
+		metadata["accessibility"] = accessibility
+	}
+	if len(emailAttachments) > 0 {
+		metadata["attachments"] = emailAttachments
+	}
+	statusFields := map[string]interface{}{}
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotations
+		if encoded, err := json.Marshal(annotations); err == nil {
+			statusFields["annotations"] = string(encoded)
+		}
+	}
+	if language != nil {
+		metadata["language"] = language
+		statusFields["language"] = language.Code
+	}
+	if textQuality != nil {
+		metadata["text_quality"] = textQuality
+		statusFields["text_quality"] = textQuality.Score
+	}
+	if scan != nil {
+		metadata["scan"] = scan.Metadata()
+	}
+	if declaredExtension != job.InputExtension {
+		metadata["format"] = map[string]string{
+			"declared": declaredExtension,
+			"detected": job.InputExtension,
+		}
+	}
+
+	audit.Artifacts = artifacts
+	p.completeJob(ctx, job, jobJSON, audit, outputPath, metadata, statusFields, inputSize, duration)
+}
 
-	if err := p.dbSvc.UpdateConversionStatus(ctx, job.ConversionID, "completed", job.OutputS3Path, metadata); err != nil {
-		log.Printf("[Worker %d] Failed to update DB to completed: %v", workerID, err)
+// completeJob records a successful conversion whose output is at
+// outputPath: the database row and status hash, the ack, the audit record,
+// the completion event and the duration history.
+func (p *Pool) completeJob(ctx context.Context, job *models.ConversionJob, jobJSON string, audit *services.AuditRecord, outputPath string, metadata map[string]interface{}, statusFields map[string]interface{}, inputSize int64, duration time.Duration) {
+	logger := logging.From(ctx)
+	if retention, ok := services.RetentionFrom(ctx); ok {
+		metadata["retention"] = retention.Metadata()
 	}
+	p.dbUpdater.UpdateStatus(job.ConversionID, models.StatusCompleted, outputPath, metadata)
 
 	// Update Redis status hash
-	p.redisClient.HSet(ctx, fmt.Sprintf("conversion:status:%d", job.ConversionID), map[string]interface{}{
-		"status":     "completed",
-		"updated_at": time.Now().Format(time.RFC3339),
-	})
+	if err := p.statusStore.Set(ctx, job.ConversionID, models.StatusCompleted, statusFields); err != nil {
+		logStatusError(ctx, "Redis", err)
+	}
+	p.markCompleted(ctx, job, outputPath)
 
 	// Remove from processing queue
-	p.redisClient.LRem(ctx, p.config.ProcessingQueue, 1, jobJSON)
+	p.ack(ctx, jobJSON)
+
+	audit.OutputS3Path = outputPath
+	audit.DurationMs = duration.Milliseconds()
+	p.recordAudit(audit, "completed")
+	p.publishEvent(ctx, job, services.EventConversionCompleted, outputPath, "")
+
+	// Feed the duration history behind /api/estimate; a merge's duration
+	// says nothing about converting its format
+	if !job.IsMerge() {
+		if err := p.perfStats.Record(ctx, job.InputExtension, inputSize, duration); err != nil {
+			logger.Warn("Failed to record duration sample", "error", err)
+		}
+	}
 
-	log.Printf("[Worker %d] Conversion %d completed successfully (%.2fs)", workerID, job.ConversionID, duration.Seconds())
+	p.recordRollup(job, true, duration)
+	p.recordJobResult(job, "completed", duration)
+	p.counters.completed.Add(1)
+	logger.Info("Conversion completed successfully", "duration_ms", duration.Milliseconds())
 }
 
-func (p *Pool) handleJobFailure(ctx context.Context, workerID int, job *models.ConversionJob, jobJSON string, errorMsg string) {
-	log.Printf("[Worker %d] Conversion %d failed: %s", workerID, job.ConversionID, errorMsg)
+func (p *Pool) rerouteRegion(ctx context.Context, job *models.ConversionJob, jobJSON string) {
+	target := p.config.PendingQueueFor(string(job.Priority.Normalize()), job.Region)
+	if job.UserInitiated {
+		target = p.config.RetryLaneQueueFor(job.Region)
+	}
+	logging.From(ctx).Info("Conversion belongs to another region, rerouting", "region", job.Region, "queue", target)
+
+	// A source has no regional queues to hand the job to
+	if p.source != nil {
+		p.rejectJob(ctx, job, jobJSON, models.RejectMalformed, "job for region "+job.Region+" was sent to this region's queue")
+		return
+	}
 
-	// Remove from processing queue
-	p.redisClient.LRem(ctx, p.config.ProcessingQueue, 1, jobJSON)
+	if err := p.jobQueue.Push(ctx, target, p.sealPayload(ctx, jobJSON)); err != nil {
+		logging.From(ctx).Error("Failed to reroute conversion", "error", err)
+		return
+	}
+	p.ack(ctx, jobJSON)
+}
+
+func (p *Pool) handleJobFailure(ctx context.Context, workerID int, job *models.ConversionJob, jobJSON string, audit *services.AuditRecord, errorMsg string) {
+	// A conversion the shutdown cut off didn't fail; it runs again as it was
+	if errors.Is(context.Cause(ctx), errShuttingDown) {
+		p.requeueInterrupted(ctx, job, jobJSON)
+		return
+	}
+
+	logger := logging.From(ctx)
+	logger.Error("Conversion failed", "error", errorMsg, "retry_count", job.RetryCount)
+
+	audit.Error = errorMsg
+	audit.DurationMs = time.Since(audit.StartedAt).Milliseconds()
+	if job.RetryCount < job.MaxRetries {
+		p.recordAudit(audit, "retrying")
+	} else {
+		p.recordAudit(audit, "failed")
+	}
 
 	// Increment retry count in DB
-	p.dbSvc.IncrementRetryCount(ctx, job.ConversionID)
+	p.dbUpdater.IncrementRetryCount(job.ConversionID)
 
 	// Check if we should retry
 	if job.RetryCount < job.MaxRetries {
 		job.RetryCount++
+		p.sealPassword(ctx, job)
 		newJobJSON, _ := json.Marshal(job)
+		newJobJSON = p.signJob(jobJSON, newJobJSON)
 
 		// Calculate exponential backoff delay
 		delay := time.Duration(math.Pow(2, float64(job.RetryCount))) * time.Second
@@ -156,42 +799,58 @@ func (p *Pool) handleJobFailure(ctx context.Context, workerID int, job *models.C
 			delay = 30 * time.Second
 		}
 
-		// Schedule retry with delay
-		time.AfterFunc(delay, func() {
-			p.redisClient.LPush(context.Background(), p.config.PendingQueue, newJobJSON)
-			log.Printf("[Worker %d] Scheduled retry %d/%d for conversion %d in %v",
-				workerID, job.RetryCount, job.MaxRetries, job.ConversionID, delay)
-		})
+		// Schedule retry with delay (durable across restarts)
+		p.recordJobResult(job, "retried", 0)
+		p.counters.retried.Add(1)
+		if err := p.scheduleRetry(ctx, newJobJSON, delay); err != nil {
+			logger.Warn("Failed to schedule retry, requeueing now", "error", err)
+			p.enqueue(ctx, p.requeueTarget(job), string(newJobJSON))
+			delay = 0
+		} else {
+			logger.Info("Scheduled retry", "retry", job.RetryCount, "max_retries", job.MaxRetries, "delay", delay.String())
+		}
+		p.publishRetrySchedule(ctx, job, time.Now().Add(delay))
 	} else {
 		// Max retries reached - move to failed queue
-		p.redisClient.LPush(ctx, p.config.FailedQueue, jobJSON)
+		p.counters.failed.Add(1)
+		p.recordRollup(job, false, 0)
+		p.recordJobResult(job, "failed", 0)
+		p.pushFailed(ctx, jobJSON)
 
 		// Update DB status
-		p.dbSvc.UpdateConversionStatus(ctx, job.ConversionID, "failed", "", nil)
-		p.dbSvc.UpdateConversionError(ctx, job.ConversionID, errorMsg)
+		p.dbUpdater.UpdateStatus(job.ConversionID, models.StatusFailed, "", nil)
+		p.dbUpdater.UpdateError(job.ConversionID, errorMsg)
 
 		// Update Redis status
-		p.redisClient.HSet(ctx, fmt.Sprintf("conversion:status:%d", job.ConversionID), map[string]interface{}{
-			"status":     "failed",
-			"error":      errorMsg,
-			"updated_at": time.Now().Format(time.RFC3339),
-		})
+		if err := p.statusStore.Set(ctx, job.ConversionID, models.StatusFailed, map[string]interface{}{
+			"error":             errorMsg,
+			"next_retry_at":     "",
+			"retries_remaining": 0,
+		}); err != nil {
+			logStatusError(ctx, "Redis", err)
+		}
+		if p.config.DBRetryColumns {
+			p.dbUpdater.UpdateRetrySchedule(job.ConversionID, nil, 0)
+		}
 
-		log.Printf("[Worker %d] Conversion %d moved to failed queue after %d retries",
-			workerID, job.ConversionID, job.MaxRetries)
+		p.publishEvent(ctx, job, services.EventConversionFailed, "", errorMsg)
+		logger.Error("Conversion moved to failed queue", "retries", job.MaxRetries)
 	}
+
+	// Remove from processing queue once the retry or failure is recorded
+	p.ack(ctx, jobJSON)
 }
 
 func (p *Pool) RecoveryLoop(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
-	log.Println("[Recovery] Starting stale job recovery loop")
+	slog.Info("Starting stale job recovery loop", "component", "recovery")
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("[Recovery] Shutting down")
+			slog.Info("Recovery loop shutting down", "component", "recovery")
 			return
 		case <-ticker.C:
 			p.recoverStaleJobs(ctx)
@@ -200,41 +859,128 @@ func (p *Pool) RecoveryLoop(ctx context.Context) {
 }
 
 func (p *Pool) recoverStaleJobs(ctx context.Context) {
+	// A source redelivers abandoned messages itself
+	if !p.IsActive() || p.source != nil {
+		return
+	}
+	if p.jobQueue.Streams() {
+		p.recoverStaleStreamJobs(ctx)
+		return
+	}
+
 	// Get all jobs in processing queue
 	jobs, err := p.redisClient.LRange(ctx, p.config.ProcessingQueue, 0, -1).Result()
 	if err != nil {
-		log.Printf("[Recovery] Failed to get processing queue: %v", err)
+		slog.Error("Failed to get processing queue", "component", "recovery", "error", err)
 		return
 	}
 
 	recovered := 0
+	misses := make(map[int]bool)
 	for _, jobJSON := range jobs {
 		var job models.ConversionJob
 		if err := json.Unmarshal([]byte(jobJSON), &job); err != nil {
 			continue
 		}
 
-		// Check if job is stale (> 5 minutes in processing)
-		if time.Since(job.CreatedAt) > 5*time.Minute {
-			// Remove from processing
-			p.redisClient.LRem(ctx, p.config.ProcessingQueue, 1, jobJSON)
-
-			// Retry or fail
-			if job.RetryCount < job.MaxRetries {
-				job.RetryCount++
-				newJobJSON, _ := json.Marshal(job)
-				p.redisClient.LPush(ctx, p.config.PendingQueue, newJobJSON)
-				p.dbSvc.IncrementRetryCount(ctx, job.ConversionID)
-				recovered++
-			} else {
-				p.redisClient.LPush(ctx, p.config.FailedQueue, jobJSON)
-				p.dbSvc.UpdateConversionStatus(ctx, job.ConversionID, "failed", "", nil)
-				p.dbSvc.UpdateConversionError(ctx, job.ConversionID, "Job timeout - exceeded 5 minutes")
-			}
+		if p.isStale(ctx, &job, misses) && p.abandonJob(ctx, &job, jobJSON) {
+			recovered++
 		}
 	}
 
+	p.leaseMisses = misses
+
 	if recovered > 0 {
-		log.Printf("[Recovery] Recovered %d stale jobs", recovered)
+		slog.Info("Recovered stale jobs", "component", "recovery", "recovered", recovered)
+	}
+}
+
+// abandonJob takes a job whose worker is gone out of processing and retries
+// it, or fails it once its retries are used up. Reports whether it was
+// retried.
+func (p *Pool) abandonJob(ctx context.Context, job *models.ConversionJob, jobJSON string) bool {
+	// A job that keeps taking its workers down would loop forever
+	if p.config.MaxRecoveries > 0 && job.Recoveries >= p.config.MaxRecoveries {
+		p.escalateJob(ctx, job, jobJSON)
+		return false
+	}
+
+	p.ack(ctx, jobJSON)
+
+	if job.RetryCount < job.MaxRetries {
+		job.RetryCount++
+		job.Recoveries++
+		p.sealPassword(ctx, job)
+		newJobJSON, _ := json.Marshal(job)
+		p.enqueue(ctx, p.requeueTarget(job), string(p.signJob(jobJSON, newJobJSON)))
+		p.dbUpdater.IncrementRetryCount(job.ConversionID)
+		p.counters.recovered.Add(1)
+		return true
+	}
+
+	p.pushFailed(ctx, jobJSON)
+	if job.MovesOutputs() {
+		// The conversion itself completed long ago
+		return false
+	}
+	p.dbUpdater.UpdateStatus(job.ConversionID, models.StatusFailed, "", nil)
+	p.dbUpdater.UpdateError(job.ConversionID, "Job lease expired")
+	p.publishEvent(ctx, job, services.EventConversionFailed, "", "Job lease expired")
+	return false
+}
+
+// isStale reports whether a processing job has been abandoned. A job is
+// stale once its lease is missing on two consecutive passes, which leaves
+// room for a worker that claimed it but hasn't taken the lease yet. With
+// leases disabled it falls back to the job's age.
+func (p *Pool) isStale(ctx context.Context, job *models.ConversionJob, misses map[int]bool) bool {
+	if p.config.LeaseInterval <= 0 || p.config.LeaseTTL <= 0 {
+		return time.Since(job.CreatedAt) > 5*time.Minute
+	}
+
+	if p.hasLease(ctx, job.ConversionID) {
+		return false
+	}
+	if p.leaseMisses[job.ConversionID] {
+		return true
+	}
+	misses[job.ConversionID] = true
+	return false
+}
+
+func logStatusError(ctx context.Context, store string, err error) {
+	var illegal *models.ErrIllegalTransition
+	if errors.As(err, &illegal) {
+		logging.From(ctx).Warn("Status anomaly", "store", store, "error", err)
+		return
+	}
+	logging.From(ctx).Error("Failed to update status", "store", store, "error", err)
+}
+
+// markProcessing moves the job to processing in the database, in the
+// background, and in the status hash, from which completed and failed are
+// then allowed. A requeued job's hash was reset to pending.
+func (p *Pool) markProcessing(ctx context.Context, job *models.ConversionJob) {
+	p.dbUpdater.UpdateStatus(job.ConversionID, models.StatusProcessing, "", nil)
+	if err := p.statusStore.Set(ctx, job.ConversionID, models.StatusProcessing, nil); err != nil {
+		logStatusError(ctx, "Redis", err)
+	}
+}
+
+// publishRetrySchedule tells clients when a failed job will be retried and
+// how many attempts it has left, counting the scheduled one, so they needn't
+// show it as processing indefinitely. The status hash always gets
+// next_retry_at and retries_remaining; the DB row only with
+// DB_RETRY_COLUMNS.
+func (p *Pool) publishRetrySchedule(ctx context.Context, job *models.ConversionJob, nextRetryAt time.Time) {
+	remaining := job.MaxRetries - job.RetryCount + 1
+	if err := p.statusStore.Set(ctx, job.ConversionID, models.StatusProcessing, map[string]interface{}{
+		"next_retry_at":     nextRetryAt.UTC().Format(time.RFC3339),
+		"retries_remaining": remaining,
+	}); err != nil {
+		logStatusError(ctx, "Redis", err)
+	}
+	if p.config.DBRetryColumns {
+		p.dbUpdater.UpdateRetrySchedule(job.ConversionID, &nextRetryAt, remaining)
 	}
 }
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"strconv"
+	"sync"
+
+	"converter/models"
+)
+
+// defaultPriorityWeights give high priority most claims while guaranteeing
+// normal and low queues a share even under continuous high traffic.
+var defaultPriorityWeights = map[models.Priority]int{
+	models.PriorityHigh:   8,
+	models.PriorityNormal: 3,
+	models.PriorityLow:    1,
+}
+
+// fairScheduler picks which priority a worker should try first using smooth
+// weighted round-robin; the remaining queues are then tried in strict
+// priority order so no claim is wasted when the preferred queue is empty.
+type fairScheduler struct {
+	mu      sync.Mutex
+	weights []int
+	current []int
+}
+
+func newFairScheduler(configured map[string]string) *fairScheduler {
+	s := &fairScheduler{
+		weights: make([]int, len(models.Priorities)),
+		current: make([]int, len(models.Priorities)),
+	}
+	for i, priority := range models.Priorities {
+		s.weights[i] = defaultPriorityWeights[priority]
+		if value, ok := configured[string(priority)]; ok {
+			if weight, err := strconv.Atoi(value); err == nil && weight >= 0 {
+				s.weights[i] = weight
+			}
+		}
+	}
+	return s
+}
+
+// order returns the priorities to try for the next claim.
+func (s *fairScheduler) order() []models.Priority {
+	s.mu.Lock()
+	total, best := 0, -1
+	for i, weight := range s.weights {
+		s.current[i] += weight
+		total += weight
+		if weight > 0 && (best < 0 || s.current[i] > s.current[best]) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		s.current[best] -= total
+	}
+	s.mu.Unlock()
+
+	order := make([]models.Priority, 0, len(models.Priorities))
+	if best >= 0 {
+		order = append(order, models.Priorities[best])
+	}
+	for i, priority := range models.Priorities {
+		if i != best {
+			order = append(order, priority)
+		}
+	}
+	return order
+}
+
+func (p *Pool) pendingQueue(priority models.Priority) string {
+	switch priority.Normalize() {
+	case models.PriorityHigh:
+		return p.config.HighPriorityQueue
+	case models.PriorityLow:
+		return p.config.LowPriorityQueue
+	default:
+		return p.config.PendingQueue
+	}
+}
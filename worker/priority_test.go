@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"testing"
+
+	"converter/models"
+)
+
+func TestFairScheduler_Order(t *testing.T) {
+	t.Parallel()
+
+	s := newFairScheduler(map[string]string{"high": "2", "normal": "1", "low": "1"})
+
+	first := map[models.Priority]int{}
+	for i := 0; i < 40; i++ {
+		order := s.order()
+		if len(order) != 3 {
+			t.Fatalf("expected all three priorities, got %v", order)
+		}
+		first[order[0]]++
+	}
+
+	if first[models.PriorityHigh] != 20 || first[models.PriorityNormal] != 10 || first[models.PriorityLow] != 10 {
+		t.Fatalf("unexpected share of first picks: %v", first)
+	}
+}
+
+func TestFairScheduler_FallsBackInPriorityOrder(t *testing.T) {
+	t.Parallel()
+
+	s := newFairScheduler(map[string]string{"high": "0", "normal": "0", "low": "1"})
+	order := s.order()
+
+	want := []models.Priority{models.PriorityLow, models.PriorityHigh, models.PriorityNormal}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, order)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	rejectionStreamMaxLen = 10000
+	rejectionPayloadLimit = 4096
+)
+
+func init() {
+	metrics.Describe("conversion_rejections_total", "Jobs rejected before processing, by reason")
+}
+
+// validateJob runs the pre-processing checks that make a job pointless to
+// retry. Returns "" when the job may proceed.
+func (p *Pool) validateJob(job *models.ConversionJob) (models.RejectionReason, string) {
+	if err := services.ValidateJobLabels(job.Labels); err != nil {
+		return models.RejectMalformed, err.Error()
+	}
+
+	switch job.Type {
+	case "", models.JobTypeConvert:
+		if job.ConversionID == 0 || job.InputS3Path == "" || job.OutputS3Path == "" {
+			return models.RejectMalformed, "job is missing conversionId, inputS3Path or outputS3Path"
+		}
+	case models.JobTypeMerge:
+		if reason, message := p.validateMerge(job); reason != "" {
+			return reason, message
+		}
+	case models.JobTypeTrash, models.JobTypeRestore:
+		if job.ConversionID == 0 {
+			return models.RejectMalformed, "job is missing conversionId"
+		}
+		// Nothing is converted, so the checks below don't apply
+		return "", ""
+	default:
+		return models.RejectMalformed, "unknown job type " + string(job.Type)
+	}
+
+	if reason, message := p.validateAssets(job); reason != "" {
+		return reason, message
+	}
+	if reason, message := validatePrintTemplates(job); reason != "" {
+		return reason, message
+	}
+	if reason, message := validateOutputs(job); reason != "" {
+		return reason, message
+	}
+
+	if job.PDFAConformance != "" && !services.ValidPDFAConformance(job.PDFAConformance) {
+		return models.RejectMalformed, "unsupported PDF/A conformance " + job.PDFAConformance
+	}
+
+	if job.Split != nil {
+		if err := services.ValidateSplit(*job.Split); err != nil {
+			return models.RejectMalformed, err.Error()
+		}
+	}
+
+	// Gotenberg 7 would ignore these options and return a plain PDF/A
+	if job.Accessible || job.Flatten || job.Split != nil {
+		if feature := p.gotenbergSvc.MissingFeature(job.Accessible, job.Flatten, job.Split != nil); feature != "" {
+			return models.RejectMalformed, fmt.Sprintf("%s needs Gotenberg %d", feature, services.GotenbergV8)
+		}
+	}
+
+	if (job.Password != "" || job.PasswordRef != "") && p.gotenbergSvc.APIVersion() == services.GotenbergV7 {
+		return models.RejectMalformed, fmt.Sprintf("document passwords need Gotenberg %d", services.GotenbergV8)
+	}
+
+	if _, ok := p.retention[job.RetentionClass]; job.RetentionClass != "" && !ok {
+		return models.RejectMalformed, "unknown retention class " + job.RetentionClass
+	}
+
+	if job.CallbackURL != "" {
+		if err := p.events.ValidateCallbackURL(job.CallbackURL); err != nil {
+			return models.RejectMalformed, err.Error()
+		}
+	}
+
+	// With detection on, a missing or unsupported extension may still turn
+	// out to be a supported format once the content is sniffed
+	if !job.IsMerge() && !p.config.DetectFormat && !p.extensionSupported(job.InputExtension) {
+		return models.RejectUnsupportedFormat, "file extension ." + strings.ToLower(strings.TrimPrefix(job.InputExtension, ".")) + " is not supported"
+	}
+
+	return "", ""
+}
+
+// inputTooLarge reports whether an input of inputSize bytes exceeds
+// CONVERSION_MAX_INPUT_BYTES, with the message to reject it with. An unknown
+// (negative) size passes.
+func (p *Pool) inputTooLarge(inputSize int64) (string, bool) {
+	if p.config.MaxInputBytes <= 0 || inputSize <= p.config.MaxInputBytes {
+		return "", false
+	}
+	return fmt.Sprintf("input is %d bytes, the limit is %d", inputSize, p.config.MaxInputBytes), true
+}
+
+// finishAs ends a job found unconvertible while processing with a terminal
+// status of its own, without retries: the database row with metadata, the
+// status hash with the error and fields, the audit record and a
+// conversion.failed event.
+func (p *Pool) finishAs(ctx context.Context, job *models.ConversionJob, jobJSON string, audit *services.AuditRecord, status models.ConversionStatus, message string, fields map[string]interface{}, metadata map[string]interface{}) {
+	p.dbUpdater.UpdateStatus(job.ConversionID, status, "", metadata)
+	p.dbUpdater.UpdateError(job.ConversionID, message)
+	statusFields := map[string]interface{}{"error": message}
+	for name, value := range fields {
+		statusFields[name] = value
+	}
+	if err := p.statusStore.Set(ctx, job.ConversionID, status, statusFields); err != nil {
+		logStatusError(ctx, "Redis", err)
+	}
+	p.ack(ctx, jobJSON)
+
+	audit.Error = message
+	p.recordAudit(audit, string(status))
+	p.publishEvent(ctx, job, services.EventConversionFailed, "", message)
+	p.recordRollup(job, false, 0)
+}
+
+// rejectJob terminally refuses a job without retries and publishes the reason
+// to the rejections stream so the producer can tell the user immediately.
+// job may be nil when the payload couldn't be parsed at all.
+func (p *Pool) rejectJob(ctx context.Context, job *models.ConversionJob, jobJSON string, reason models.RejectionReason, message string) {
+	p.rejectJobWith(ctx, job, jobJSON, reason, message, nil)
+}
+
+// rejectJobWith rejects the job like rejectJob, adding details to the
+// rejection, the status hash and the conversion metadata.
+func (p *Pool) rejectJobWith(ctx context.Context, job *models.ConversionJob, jobJSON string, reason models.RejectionReason, message string, details map[string]interface{}) {
+	logging.From(ctx).Warn("Rejecting job", "reason", string(reason), "message", message)
+	metrics.Inc("conversion_rejections_total", "reason", string(reason))
+
+	values := map[string]interface{}{
+		"reason":      string(reason),
+		"message":     message,
+		"rejected_at": time.Now().Format(time.RFC3339),
+	}
+	for name, value := range details {
+		values[name] = value
+	}
+
+	if job != nil && job.ConversionID != 0 {
+		values["conversion_id"] = job.ConversionID
+		values["file_guid"] = job.FileGUID
+		values["user_id"] = job.UserID
+
+		statusFields := map[string]interface{}{
+			"error":            message,
+			"rejection_reason": string(reason),
+		}
+		for name, value := range details {
+			statusFields[name] = value
+		}
+		p.dbUpdater.UpdateStatus(job.ConversionID, models.StatusFailed, "", details)
+		p.dbUpdater.UpdateError(job.ConversionID, message)
+		if err := p.statusStore.Set(ctx, job.ConversionID, models.StatusFailed, statusFields); err != nil {
+			logStatusError(ctx, "Redis", err)
+		}
+		p.publishEvent(ctx, job, services.EventConversionFailed, "", message)
+		p.recordRollup(job, false, 0)
+	} else {
+		payload := services.RedactPassword(withoutClaimToken(jobJSON), nil)
+		if len(payload) > rejectionPayloadLimit {
+			payload = payload[:rejectionPayloadLimit]
+		}
+		values["payload"] = payload
+	}
+
+	if err := p.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.config.RejectionStream,
+		MaxLen: rejectionStreamMaxLen,
+		Approx: true,
+		Values: values,
+	}).Err(); err != nil {
+		logging.From(ctx).Error("Failed to publish rejection", "error", err)
+	}
+
+	// Acked last, so a source that commits offsets has the failed status
+	// written first
+	p.ack(ctx, jobJSON)
+}
@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestInputTooLarge(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{config: &config.Config{MaxInputBytes: 1000}}
+	cases := []struct {
+		size int64
+		want bool
+	}{
+		{1000, false},
+		{1001, true},
+		{-1, false},
+	}
+	for _, c := range cases {
+		if message, got := p.inputTooLarge(c.size); got != c.want {
+			t.Errorf("inputTooLarge(%d) = %v (%s), want %v", c.size, got, message, c.want)
+		}
+	}
+	if message, _ := p.inputTooLarge(2048); message != "input is 2048 bytes, the limit is 1000" {
+		t.Errorf("message = %q", message)
+	}
+
+	p.config.MaxInputBytes = 0
+	if _, got := p.inputTooLarge(1 << 40); got {
+		t.Error("no limit rejected a large input")
+	}
+}
+
+func TestValidateJob_PasswordNeedsGotenberg8(t *testing.T) {
+	t.Parallel()
+
+	job := &models.ConversionJob{ConversionID: 7, InputS3Path: "in/a.docx", OutputS3Path: "out/a.pdf", InputExtension: "docx", Password: "s3cret"}
+	for _, c := range []struct {
+		version int
+		want    models.RejectionReason
+	}{
+		{services.GotenbergV7, models.RejectMalformed},
+		{services.GotenbergV8, ""},
+	} {
+		p := &Pool{config: &config.Config{}, gotenbergSvc: services.NewGotenbergService("http://gotenberg:3000", 0, services.RequestIdentity{})}
+		if err := p.gotenbergSvc.SetAPIVersion(c.version); err != nil {
+			t.Fatal(err)
+		}
+		if reason, message := p.validateJob(job); reason != c.want {
+			t.Errorf("gotenberg %d: reason %q (%s), want %q", c.version, reason, message, c.want)
+		}
+	}
+}
+
+func TestHandleClaim_RejectedJobFails(t *testing.T) {
+	t.Parallel()
+
+	// The producer wrote the row and the status hash as pending
+	client, hashes := newFakeStatusClient(t)
+	if err := services.NewStatusStore(client).Set(context.Background(), 42, models.StatusPending, nil); err != nil {
+		t.Fatal(err)
+	}
+	db, rows := newFakeConversionsDB(t, map[int]string{42: string(models.StatusPending)})
+	dbUpdater := services.NewStatusUpdater(services.NewDatabaseServiceWithDB(db), 10, 0)
+	go dbUpdater.Run()
+
+	cfg := &config.Config{RejectionStream: "conversion:rejections"}
+	p := &Pool{
+		config:      cfg,
+		redisClient: client,
+		jobQueue:    services.NewJobQueue(client, services.QueueBackendList, ""),
+		statusStore: services.NewStatusStore(client),
+		dbUpdater:   dbUpdater,
+		events:      services.NewEventRouter(aws.Config{}, cfg, services.NewTenantConfigs(client, "conversion:tenants", time.Minute)),
+	}
+
+	// No outputS3Path, so validateJob rejects it as malformed
+	p.handleClaim(context.Background(), 1, `{"conversionId":42,"inputS3Path":"in/a.docx"}`)
+	dbUpdater.Close()
+
+	if got := rows.status(42); got != string(models.StatusFailed) {
+		t.Errorf("row status = %q, want failed", got)
+	}
+	if got := hashes.status(42); got != string(models.StatusFailed) {
+		t.Errorf("status hash = %q, want failed", got)
+	}
+}
+
+func TestValidateJob_Outputs(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{config: &config.Config{DetectFormat: true}, gotenbergSvc: services.NewGotenbergService("http://gotenberg:3000", 0, services.RequestIdentity{})}
+	cases := []struct {
+		name    string
+		outputs []models.OutputArtifact
+		bundle  bool
+		want    models.RejectionReason
+	}{
+		{"thumbnail", []models.OutputArtifact{{Kind: models.ArtifactThumbnail, S3Path: "out/a.png"}}, false, ""},
+		{"unknown kind", []models.OutputArtifact{{Kind: "docx", S3Path: "out/a.docx"}}, false, models.RejectMalformed},
+		{"no key", []models.OutputArtifact{{Kind: models.ArtifactText}}, false, models.RejectMalformed},
+		{"no key in a bundle", []models.OutputArtifact{{Kind: models.ArtifactText}}, true, ""},
+	}
+	for _, c := range cases {
+		job := &models.ConversionJob{ConversionID: 7, InputS3Path: "in/a.docx", OutputS3Path: "out/a.pdf", Outputs: c.outputs, Bundle: c.bundle}
+		if reason, message := p.validateJob(job); reason != c.want {
+			t.Errorf("%s: reason %q (%s), want %q", c.name, reason, message, c.want)
+		}
+	}
+}
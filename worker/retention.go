@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"converter/models"
+	"converter/services"
+)
+
+// SetRetentionClasses sets the classes jobs may name in retentionClass, as
+// parsed by services.ParseRetentionClasses.
+func (p *Pool) SetRetentionClasses(classes map[string]services.Retention) {
+	p.retention = classes
+}
+
+// withRetention makes every output the job uploads with the returned
+// context carry its retention class's Object Lock settings, retained until
+// the same time. Jobs without a class get ctx back.
+func (p *Pool) withRetention(ctx context.Context, job *models.ConversionJob) context.Context {
+	if job.RetentionClass == "" {
+		return ctx
+	}
+	return services.WithRetention(ctx, p.retention[job.RetentionClass].Applied(time.Now()))
+}
@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+)
+
+func TestRetentionClass(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{config: &config.Config{}}
+	p.SetRetentionClasses(map[string]services.Retention{
+		"90d": {Class: "90d", Mode: services.RetentionGovernance, Days: 90},
+	})
+	job := func(class string) *models.ConversionJob {
+		return &models.ConversionJob{ConversionID: 7, InputS3Path: "in/a.docx", OutputS3Path: "out/a.pdf", InputExtension: "docx", RetentionClass: class}
+	}
+
+	if reason, message := p.validateJob(job("7y")); reason != models.RejectMalformed {
+		t.Errorf("unknown class: reason %q (%s), want malformed", reason, message)
+	}
+	if reason, message := p.validateJob(job("90d")); reason != "" {
+		t.Errorf("known class rejected: %s", message)
+	}
+
+	if _, ok := services.RetentionFrom(p.withRetention(context.Background(), job(""))); ok {
+		t.Error("job without a class got a retention")
+	}
+	retention, ok := services.RetentionFrom(p.withRetention(context.Background(), job("90d")))
+	if !ok || retention.Mode != services.RetentionGovernance || retention.RetainUntil.IsZero() {
+		t.Errorf("retention = %+v, %v, want 90d applied", retention, ok)
+	}
+}
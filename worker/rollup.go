@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_rollup_flush_failures_total", "Failed writes of the hourly rollups to conversion_rollups; the counts are kept for the next flush")
+}
+
+// recordRollup counts a conversion that finished for good in the hourly
+// rollups: completed, or failed after its last retry or a rejection.
+func (p *Pool) recordRollup(job *models.ConversionJob, completed bool, duration time.Duration) {
+	if p.rollups == nil || job.MovesOutputs() {
+		return
+	}
+	p.rollups.Record(time.Now(), services.RollupExtension(job.InputExtension, job.IsMerge()), completed, duration)
+}
+
+// RollupLoop adds the counts accumulated by this instance to
+// conversion_rollups every METRICS_ROLLUP_INTERVAL seconds. The counts of
+// the last jobs are written by FlushRollups once the workers stopped.
+func (p *Pool) RollupLoop(ctx context.Context) {
+	interval := time.Duration(p.config.RollupInterval) * time.Second
+	if p.rollups == nil || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	slog.Info("Starting metrics rollups", "component", "rollup", "interval", interval.String())
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Metrics rollups shutting down", "component", "rollup")
+			return
+		case <-ticker.C:
+			p.FlushRollups(ctx)
+		}
+	}
+}
+
+// FlushRollups writes the accumulated counts. When the write fails they are
+// put back, so they are added with the next flush instead of being lost.
+func (p *Pool) FlushRollups(ctx context.Context) {
+	if p.rollups == nil {
+		return
+	}
+	rollups := p.rollups.Drain()
+	if len(rollups) == 0 {
+		return
+	}
+	if err := p.db.AddRollups(ctx, rollups); err != nil {
+		metrics.Inc("conversion_rollup_flush_failures_total")
+		slog.Error("Failed to write metrics rollups", "component", "rollup", "rows", len(rollups), "error", err)
+		p.rollups.Restore(rollups)
+		return
+	}
+	slog.Debug("Wrote metrics rollups", "component", "rollup", "rows", len(rollups))
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_scans_total", "Inputs scanned with clamd before conversion, by result (clean, infected, error)")
+}
+
+// infectedInput is returned for an input clamd flagged; the job is
+// finished as rejected_infected instead of being retried.
+type infectedInput struct {
+	result *services.ScanResult
+}
+
+func (e *infectedInput) Error() string {
+	return "input is infected with " + e.result.Signature
+}
+
+// scanInput scans a downloaded input with clamd. It returns nil without a
+// scanner, and an *infectedInput when a signature matches. Any other error
+// fails the job so it is retried: an input is never converted unscanned.
+func (p *Pool) scanInput(ctx context.Context, localPath string) (*services.ScanResult, error) {
+	if p.scanner == nil {
+		return nil, nil
+	}
+	result, err := p.scanner.ScanFile(ctx, localPath)
+	if err != nil {
+		metrics.Inc("conversion_scans_total", "result", "error")
+		return nil, err
+	}
+	if result.Infected {
+		metrics.Inc("conversion_scans_total", "result", "infected")
+		return result, &infectedInput{result: result}
+	}
+	metrics.Inc("conversion_scans_total", "result", "clean")
+	return result, nil
+}
+
+// rejectInfected finishes a job whose input is infected as rejected_infected,
+// without retries, recording the scan in the conversion metadata.
+func (p *Pool) rejectInfected(ctx context.Context, job *models.ConversionJob, jobJSON string, audit *services.AuditRecord, infected *infectedInput) {
+	logging.From(ctx).Warn("Rejecting infected input", "signature", infected.result.Signature)
+	p.finishAs(ctx, job, jobJSON, audit, models.StatusRejectedInfected, "Infected: "+infected.result.Signature,
+		map[string]interface{}{"signature": infected.result.Signature},
+		map[string]interface{}{"scan": infected.result.Metadata()})
+}
@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"converter/config"
+	"converter/logging"
+	"converter/models"
+	"converter/services"
+)
+
+const (
+	// shutdownReportTTL is how long a published shutdown report is kept.
+	shutdownReportTTL = 7 * 24 * time.Hour
+	// requeueTimeout bounds putting an interrupted claim back, which runs
+	// after its own context was cancelled.
+	requeueTimeout = 5 * time.Second
+)
+
+// errShuttingDown is the cause of a claim's context being cancelled when
+// SHUTDOWN_GRACE ran out before the claim finished.
+var errShuttingDown = errors.New("shutting down")
+
+// claimContext returns the context a claim is handled in, which outlives
+// the worker's ctx by SHUTDOWN_GRACE seconds: a shutdown stops the worker
+// from claiming at once, but gives the job it is running that long to
+// finish before interrupting it. Call cancel once the claim is handled.
+func (p *Pool) claimContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	claimCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	grace := time.Duration(p.config.ShutdownGrace) * time.Second
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel(errShuttingDown)
+		case <-claimCtx.Done():
+		}
+	})
+	return claimCtx, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+// requeueInterrupted puts a claim the shutdown interrupted back at the
+// tail of its queue as it was claimed, so it doesn't use a retry and isn't
+// left in processing until recovery finds it.
+func (p *Pool) requeueInterrupted(ctx context.Context, job *models.ConversionJob, jobJSON string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), requeueTimeout)
+	defer cancel()
+
+	logger := logging.From(ctx)
+	if err := p.enqueue(ctx, p.requeueTarget(job), p.sealPayload(ctx, jobJSON)); err != nil {
+		logger.Error("Failed to requeue interrupted conversion, leaving it to recovery", "error", err)
+		return
+	}
+	p.ack(ctx, jobJSON)
+	p.counters.requeued.Add(1)
+	logger.Warn("Shutdown interrupted conversion, requeued it", "grace", p.config.ShutdownGrace)
+}
+
+// ShutdownReport summarizes a run of the service when it stops, for deploy
+// tooling to tell whether the drain was clean.
+type ShutdownReport struct {
+	Instance  string    `json:"instance"`
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"startedAt"`
+	StoppedAt time.Time `json:"stoppedAt"`
+	// Graceful is false when the workers didn't stop within the shutdown
+	// timeout; their claims are counted as abandoned.
+	Graceful bool `json:"graceful"`
+	// Clean means the shutdown was graceful and abandoned no claim.
+	Clean bool `json:"clean"`
+	RunStats
+	// Queues holds the depth of every queue at exit, by the admin API's
+	// queue names. Queues whose depth can't be read are left out.
+	Queues map[string]int64 `json:"queues"`
+}
+
+// ShutdownReport builds the report of a run that started at startedAt.
+// Call it once the workers stopped, or gave up waiting for them.
+func (p *Pool) ShutdownReport(ctx context.Context, startedAt time.Time, graceful bool) *ShutdownReport {
+	report := &ShutdownReport{
+		Instance:  p.config.InstanceID,
+		Version:   config.Version,
+		StartedAt: startedAt.UTC(),
+		StoppedAt: time.Now().UTC(),
+		Graceful:  graceful,
+		RunStats:  p.Stats(),
+		Queues:    map[string]int64{},
+	}
+	// Claims still running were cut off by the exit
+	report.Abandoned += p.inFlight.Load()
+	report.Clean = graceful && report.Abandoned == 0
+
+	admin := services.NewQueueAdmin(p.redisClient, p.config, nil)
+	for _, name := range services.QueueNames() {
+		if depth, err := admin.Length(ctx, name); err == nil {
+			report.Queues[name] = depth
+		}
+	}
+	return report
+}
+
+// PublishShutdownReport logs the report and, with SHUTDOWN_REPORT_KEY set,
+// stores it as JSON at <key>:<instance ID> for a week.
+func (p *Pool) PublishShutdownReport(ctx context.Context, report *ShutdownReport) error {
+	level := slog.LevelInfo
+	if !report.Clean {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "Shutdown report", "component", "shutdown",
+		"clean", report.Clean,
+		"graceful", report.Graceful,
+		"completed", report.Completed,
+		"failed", report.Failed,
+		"retried", report.Retried,
+		"recovered", report.Recovered,
+		"requeued", report.Requeued,
+		"abandoned", report.Abandoned,
+		"queues", report.Queues,
+		"uptime", report.StoppedAt.Sub(report.StartedAt).Round(time.Second).String(),
+	)
+
+	if p.config.ShutdownReportKey == "" {
+		return nil
+	}
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode shutdown report: %w", err)
+	}
+	key := p.config.ShutdownReportKey + ":" + report.Instance
+	if err := p.redisClient.Set(ctx, key, payload, shutdownReportTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store shutdown report at %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"converter/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestShutdownReport(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{InstanceID: "worker-a", QueueBackend: "list"}
+	p := &Pool{config: cfg, redisClient: redis.NewClient(&redis.Options{Addr: "127.0.0.1:0", MaxRetries: -1})}
+	p.counters.completed.Add(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	acked := p.beginClaim(ctx, "acked")
+	interrupted := p.beginClaim(ctx, "interrupted")
+	p.unacked.Delete("acked")
+	cancel()
+	acked()
+	interrupted()
+	running := p.beginClaim(ctx, "running")
+	defer running()
+
+	started := time.Now().Add(-time.Hour)
+	report := p.ShutdownReport(context.Background(), started, true)
+	if report.Instance != "worker-a" || report.Completed != 3 || !report.StartedAt.Equal(started.UTC()) {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Abandoned != 2 {
+		t.Errorf("abandoned = %d, want the interrupted claim and the one still running", report.Abandoned)
+	}
+	if report.Clean {
+		t.Error("a shutdown that abandoned claims reported clean")
+	}
+	if len(report.Queues) != 0 {
+		t.Errorf("queues = %v without Redis, want none", report.Queues)
+	}
+}
+
+func TestClaimContext(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{config: &config.Config{ShutdownGrace: 3600}}
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	graceful, finish := p.claimContext(ctx)
+	stopWorkers()
+	time.Sleep(10 * time.Millisecond)
+	if graceful.Err() != nil {
+		t.Fatal("the shutdown interrupted a claim still in its grace period")
+	}
+	finish()
+	if errors.Is(context.Cause(graceful), errShuttingDown) {
+		t.Error("a claim that finished was reported as interrupted")
+	}
+
+	p = &Pool{config: &config.Config{ShutdownGrace: 0}}
+	ctx, stopWorkers = context.WithCancel(context.Background())
+	interrupted, finish := p.claimContext(ctx)
+	defer finish()
+	stopWorkers()
+	select {
+	case <-interrupted.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the claim wasn't interrupted after its grace period")
+	}
+	if !errors.Is(context.Cause(interrupted), errShuttingDown) {
+		t.Errorf("cause = %v, want errShuttingDown", context.Cause(interrupted))
+	}
+}
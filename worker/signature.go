@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"errors"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_job_signature_failures_total", "Claimed jobs that were unsigned or carried a signature no signing secret matches")
+}
+
+// verifyJob checks a claimed payload's signature when JOB_SIGNING_SECRETS is
+// set, so only producers holding a secret can enqueue work on a shared
+// Redis. A failing job is moved to the quarantine queue untouched: its
+// fields can't be trusted, so no status, event or rejection is published
+// for it. With JOB_SIGNATURE_ENFORCE=false failures are only logged and
+// counted, for rolling signing out to producers. Reports whether the job
+// may be processed.
+func (p *Pool) verifyJob(ctx context.Context, jobJSON string) bool {
+	if len(p.config.JobSigningSecrets) == 0 {
+		return true
+	}
+
+	err := services.VerifyJob(p.config.JobSigningSecrets, withoutClaimToken(jobJSON))
+	if err == nil {
+		return true
+	}
+
+	reason := "invalid"
+	if errors.Is(err, services.ErrJobUnsigned) {
+		reason = "unsigned"
+	}
+	metrics.Inc("conversion_job_signature_failures_total", "reason", reason)
+
+	if !p.config.JobSignatureEnforce {
+		logging.From(ctx).Warn("Job signature check failed, processing anyway", "reason", reason)
+		return true
+	}
+
+	logging.From(ctx).Warn("Quarantining job that failed the signature check", "reason", reason, "queue", p.config.QuarantineQueue)
+	if err := p.redisClient.LPush(ctx, p.config.QuarantineQueue, p.sealPayload(ctx, jobJSON)).Err(); err != nil {
+		// Leave it in processing for recovery rather than lose it
+		logging.From(ctx).Error("Failed to quarantine job", "error", err)
+		return false
+	}
+	p.ack(ctx, jobJSON)
+	return false
+}
+
+// signJob signs a payload the worker re-encoded from jobJSON for another
+// queue, such as a retry, with the first signing secret, since the
+// producer's signature only covers its own encoding. A payload whose
+// original wasn't validly signed stays unsigned, so retries and recovery
+// never launder a job that slipped through with enforcement off.
+func (p *Pool) signJob(jobJSON string, payload []byte) []byte {
+	secrets := p.config.JobSigningSecrets
+	if len(secrets) == 0 || services.VerifyJob(secrets, withoutClaimToken(jobJSON)) != nil {
+		return payload
+	}
+	return services.SignJob(secrets[0], payload)
+}
@@ -0,0 +1,154 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"converter/logging"
+	"converter/queue"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// sourceTokenPrefix marks claim tokens that carry a delivery handle of the
+// external queue source.
+const sourceTokenPrefix = "source:"
+
+// SetSource makes workers claim from an external queue instead of the
+// Redis queues. Priorities, the retry lane and the delayed set don't apply:
+// the source has one queue and delays retries itself.
+func (p *Pool) SetSource(source queue.Source, visibility time.Duration) {
+	p.source = source
+	p.visibility = visibility
+}
+
+// receiveJob claims the next job from the source, tagged with its delivery
+// handle so it can be acked like a Redis claim. It returns redis.Nil when
+// nothing arrived, as the Redis claims do.
+func (p *Pool) receiveJob(ctx context.Context) (string, error) {
+	wait := time.Duration(p.config.ClaimBlockTimeout) * time.Second
+	if p.config.ClaimStrategy == ClaimPoll || p.runOnce {
+		wait = 0
+	}
+
+	msg, err := p.source.Receive(ctx, wait)
+	if err != nil {
+		return "", err
+	}
+	if msg == nil {
+		if p.config.ClaimStrategy == ClaimPoll && !p.runOnce {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Duration(p.config.ClaimIdleSleepMs) * time.Millisecond):
+			}
+		}
+		return "", redis.Nil
+	}
+
+	tagged, ok := withClaimToken(msg.Body, sourceTokenPrefix+msg.Handle)
+	if !ok {
+		// Nothing to ack it by later; it is rejected as malformed anyway
+		p.source.Ack(ctx, msg.Handle)
+		return msg.Body, nil
+	}
+	return tagged, nil
+}
+
+// ackSource acks a delivery of the source. A source that commits offsets is
+// only acked once the status updates queued for the job have been written,
+// and gets the delivery back when one of them failed, so the database never
+// trails a job the source considers done.
+func (p *Pool) ackSource(ctx context.Context, jobJSON string, handle string) (int64, error) {
+	committer, ok := p.source.(queue.Committer)
+	if ok && p.dbUpdater != nil {
+		var job struct {
+			ConversionID int `json:"conversionId"`
+		}
+		json.Unmarshal([]byte(jobJSON), &job)
+
+		written := make(chan error, 1)
+		p.dbUpdater.Then(job.ConversionID, func(err error) { written <- err })
+		select {
+		case err := <-written:
+			if err != nil {
+				logging.From(ctx).Error("Status update failed, releasing message for redelivery", "error", err)
+				if rerr := committer.Release(ctx, handle); rerr != nil {
+					logging.From(ctx).Warn("Failed to release message", "error", rerr)
+				}
+				return 0, err
+			}
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	if err := p.source.Ack(ctx, handle); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// sourceHandle reads the delivery handle back from a claimed payload.
+func sourceHandle(jobJSON string) (string, bool) {
+	token, _, ok := splitClaimToken(jobJSON)
+	if !ok || !strings.HasPrefix(token, sourceTokenPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(token, sourceTokenPrefix), true
+}
+
+// holdClaim keeps a message claimed from the source hidden from other
+// workers for as long as it is handled, extending its visibility timeout
+// every third of it. The returned func stops extending.
+func (p *Pool) holdClaim(ctx context.Context, jobJSON string) func() {
+	handle, ok := sourceHandle(jobJSON)
+	if p.source == nil || !ok || p.visibility <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(p.visibility / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.source.Extend(ctx, handle, p.visibility); err != nil {
+					logging.From(ctx).Warn("Failed to extend message visibility", "error", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// enqueue hands a job to queue, or to the source when workers claim from
+// one.
+func (p *Pool) enqueue(ctx context.Context, queue string, payload string) error {
+	if p.source != nil {
+		return p.source.Send(ctx, payload, 0)
+	}
+	return p.jobQueue.Push(ctx, queue, payload)
+}
+
+// pushFailed parks a job whose retries are used up in the failed queue, or
+// in the source's dead-letter queue when it has one. A job that can't be
+// dead-lettered still goes to the failed queue rather than being lost.
+func (p *Pool) pushFailed(ctx context.Context, jobJSON string) {
+	payload := p.sealPayload(ctx, jobJSON)
+	if deadLetterer, ok := p.source.(queue.DeadLetterer); ok {
+		err := deadLetterer.DeadLetter(ctx, payload)
+		if err == nil {
+			return
+		}
+		logging.From(ctx).Error("Failed to dead-letter conversion, using the failed queue", "error", err)
+	}
+	p.redisClient.LPush(ctx, p.config.FailedQueue, payload)
+}
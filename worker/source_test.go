@@ -0,0 +1,150 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"converter/config"
+	"converter/queue"
+	"converter/services"
+)
+
+type fakeSource struct {
+	mu       sync.Mutex
+	messages []*queue.Message
+	acked    []string
+	sent     []string
+}
+
+func (f *fakeSource) Receive(context.Context, time.Duration) (*queue.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.messages) == 0 {
+		return nil, nil
+	}
+	msg := f.messages[0]
+	f.messages = f.messages[1:]
+	return msg, nil
+}
+
+func (f *fakeSource) Ack(_ context.Context, handle string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.acked = append(f.acked, handle)
+	return nil
+}
+
+func (f *fakeSource) Extend(context.Context, string, time.Duration) error {
+	return nil
+}
+
+func (f *fakeSource) Send(_ context.Context, body string, _ time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, body)
+	return nil
+}
+
+func TestReceiveJob_AcksByHandle(t *testing.T) {
+	t.Parallel()
+
+	source := &fakeSource{messages: []*queue.Message{
+		{Body: `{"conversionId":7}`, Handle: "AQEB+abc/def=="},
+		{Body: `not json`, Handle: "bad"},
+	}}
+	p := &Pool{config: &config.Config{ClaimStrategy: ClaimBlockHigh}}
+	p.SetSource(source, time.Minute)
+	ctx := context.Background()
+
+	jobJSON, err := p.receiveJob(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := withoutClaimToken(jobJSON); got != `{"conversionId":7}` {
+		t.Fatalf("payload = %q", got)
+	}
+	if n, err := p.ack(ctx, jobJSON); err != nil || n != 1 {
+		t.Fatalf("ack = %d, %v", n, err)
+	}
+	if len(source.acked) != 1 || source.acked[0] != "AQEB+abc/def==" {
+		t.Fatalf("acked = %v, want the delivery handle", source.acked)
+	}
+
+	// Payloads that can't carry a token are acked on receipt
+	raw, err := p.receiveJob(ctx)
+	if err != nil || raw != "not json" {
+		t.Fatalf("receiveJob = %q, %v", raw, err)
+	}
+	if len(source.acked) != 2 || source.acked[1] != "bad" {
+		t.Fatalf("acked = %v, want the malformed message acked", source.acked)
+	}
+}
+
+func TestEnqueue_SendsToSource(t *testing.T) {
+	t.Parallel()
+
+	source := &fakeSource{}
+	p := &Pool{config: &config.Config{}}
+	p.SetSource(source, time.Minute)
+
+	if err := p.enqueue(context.Background(), "conversion:pending", `{"conversionId":7}`); err != nil {
+		t.Fatal(err)
+	}
+	if len(source.sent) != 1 {
+		t.Fatalf("sent = %v, want one message", source.sent)
+	}
+}
+
+type deadLetterSource struct {
+	fakeSource
+	deadLettered []string
+}
+
+func (d *deadLetterSource) DeadLetter(_ context.Context, body string) error {
+	d.deadLettered = append(d.deadLettered, body)
+	return nil
+}
+
+func TestPushFailed_DeadLettersToSource(t *testing.T) {
+	t.Parallel()
+
+	source := &deadLetterSource{}
+	p := &Pool{config: &config.Config{FailedQueue: "conversion:failed"}}
+	p.SetSource(source, 0)
+
+	p.pushFailed(context.Background(), `{"claimToken":"source:1:42","conversionId":7}`)
+	if len(source.deadLettered) != 1 || source.deadLettered[0] != `{"conversionId":7}` {
+		t.Fatalf("deadLettered = %v, want the job without its claim token", source.deadLettered)
+	}
+}
+
+type committingSource struct {
+	fakeSource
+	released []string
+}
+
+func (c *committingSource) Release(_ context.Context, handle string) error {
+	c.released = append(c.released, handle)
+	return nil
+}
+
+func TestAck_CommitsAfterStatusWrites(t *testing.T) {
+	t.Parallel()
+
+	updater := services.NewStatusUpdater(nil, 10, 0)
+	go updater.Run()
+	defer updater.Close()
+
+	source := &committingSource{}
+	p := &Pool{config: &config.Config{}, dbUpdater: updater}
+	p.SetSource(source, 0)
+
+	if n, err := p.ack(context.Background(), `{"claimToken":"source:0:10","conversionId":7}`); err != nil || n != 1 {
+		t.Fatalf("ack = %d, %v", n, err)
+	}
+	if len(source.acked) != 1 || source.acked[0] != "0:10" || len(source.released) != 0 {
+		t.Fatalf("acked = %v, released = %v; want the offset committed", source.acked, source.released)
+	}
+}
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"converter/models"
+	"converter/services"
+)
+
+// splitPartName is a part's file name, both as an S3 key suffix and inside
+// a bundle.
+func splitPartName(index int) string {
+	return fmt.Sprintf("part-%03d.pdf", index+1)
+}
+
+// splitPartKey places part index under the job's s3Prefix, or next to the
+// primary output (report.pdf -> report.part-001.pdf) when none is given.
+func splitPartKey(job *models.ConversionJob, index int) string {
+	if job.Split.S3Prefix != "" {
+		return path.Join(job.Split.S3Prefix, splitPartName(index))
+	}
+	base := strings.TrimSuffix(job.OutputS3Path, path.Ext(job.OutputS3Path))
+	return base + "." + splitPartName(index)
+}
+
+// uploadSplitParts uploads every part and returns their keys in page order.
+func (p *Pool) uploadSplitParts(ctx context.Context, job *models.ConversionJob, dataKey *services.DataKey, parts []string) ([]string, error) {
+	keys := make([]string, 0, len(parts))
+	for i, part := range parts {
+		key := splitPartKey(job, i)
+		if err := p.uploadOutput(ctx, dataKey, part, key, "application/pdf"); err != nil {
+			return nil, fmt.Errorf("split part %d upload failed: %w", i+1, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
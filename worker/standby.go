@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const standbyPollInterval = 5 * time.Second
+
+// IsActive reports whether this deployment may claim and mutate jobs. A
+// standby deployment stays passive until its control key is promoted;
+// explicitly launched run-once batches are always active.
+func (p *Pool) IsActive() bool {
+	return p.runOnce || !p.config.Standby || p.promoted.Load()
+}
+
+// StandbyLoop watches the control key of a standby deployment and flips the
+// pool between passive and active. Removing the key demotes it again.
+func (p *Pool) StandbyLoop(ctx context.Context) {
+	if !p.config.Standby {
+		return
+	}
+
+	slog.Info("Running in standby mode, waiting for control key to be set to \"active\"", "component", "standby", "key", p.config.StandbyControlKey)
+
+	ticker := time.NewTicker(standbyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		p.checkPromotion(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) checkPromotion(ctx context.Context) {
+	value, err := p.redisClient.Get(ctx, p.config.StandbyControlKey).Result()
+	if err != nil && err != redis.Nil {
+		// Keep the current state on transient errors
+		slog.Error("Failed to read control key", "component", "standby", "error", err)
+		return
+	}
+
+	active := value == "active"
+	if p.promoted.Swap(active) != active {
+		if active {
+			slog.Info("Promoted, starting to claim jobs", "component", "standby")
+		} else {
+			slog.Info("Demoted, no longer claiming jobs", "component", "standby")
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package worker
+
+import "sync/atomic"
+
+// RunStats counts job outcomes for the lifetime of the pool.
+type RunStats struct {
+	Completed int64 `json:"completed"`
+	Failed    int64 `json:"failed"`
+	Retried   int64 `json:"retried"`
+	// Recovered counts the stale jobs of other workers that recovery put
+	// back in a queue.
+	Recovered int64 `json:"recovered"`
+	// Requeued counts the claims the shutdown interrupted after
+	// SHUTDOWN_GRACE and put back in their queue.
+	Requeued int64 `json:"requeued"`
+	// Abandoned counts the claims the shutdown interrupted before they
+	// were acked or requeued, which stay in processing until recovery
+	// requeues them.
+	Abandoned int64 `json:"abandoned"`
+}
+
+type runCounters struct {
+	completed atomic.Int64
+	failed    atomic.Int64
+	retried   atomic.Int64
+	recovered atomic.Int64
+	requeued  atomic.Int64
+	abandoned atomic.Int64
+}
+
+func (p *Pool) Stats() RunStats {
+	return RunStats{
+		Completed: p.counters.completed.Load(),
+		Failed:    p.counters.failed.Load(),
+		Retried:   p.counters.retried.Load(),
+		Recovered: p.counters.recovered.Load(),
+		Requeued:  p.counters.requeued.Load(),
+		Abandoned: p.counters.abandoned.Load(),
+	}
+}
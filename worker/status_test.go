@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+)
+
+func TestMarkProcessing_RequeuedJobCompletes(t *testing.T) {
+	t.Parallel()
+
+	// Requeueing resets the status hash to pending
+	ctx := context.Background()
+	client, hashes := newFakeStatusClient(t)
+	store := services.NewStatusStore(client)
+	if err := store.Set(ctx, 42, models.StatusPending, nil); err != nil {
+		t.Fatal(err)
+	}
+	var illegal *models.ErrIllegalTransition
+	if err := store.Set(ctx, 42, models.StatusCompleted, nil); !errors.As(err, &illegal) {
+		t.Fatalf("pending -> completed: %v, want ErrIllegalTransition", err)
+	}
+
+	db, rows := newFakeConversionsDB(t, map[int]string{42: string(models.StatusPending)})
+	dbUpdater := services.NewStatusUpdater(services.NewDatabaseServiceWithDB(db), 10, 0)
+	go dbUpdater.Run()
+	p := &Pool{config: &config.Config{}, statusStore: store, dbUpdater: dbUpdater}
+
+	p.markProcessing(ctx, &models.ConversionJob{ConversionID: 42})
+	dbUpdater.Close()
+	if got := hashes.status(42); got != string(models.StatusProcessing) {
+		t.Fatalf("status hash = %q, want processing", got)
+	}
+	if got := rows.status(42); got != string(models.StatusProcessing) {
+		t.Fatalf("row status = %q, want processing", got)
+	}
+
+	if err := store.Set(ctx, 42, models.StatusCompleted, nil); err != nil {
+		t.Fatalf("processing -> completed: %v", err)
+	}
+	if got := hashes.status(42); got != string(models.StatusCompleted) {
+		t.Errorf("status hash = %q, want completed", got)
+	}
+}
@@ -0,0 +1,237 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"converter/migrate"
+	"converter/models"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const streamRecoveryBatch = 100
+
+// promoteStreamScript is promoteScript for the stream backend: it moves one
+// due member of the delayed set (KEYS[1]) onto the job stream KEYS[2].
+var promoteStreamScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 1 then
+	redis.call('XADD', KEYS[2], '*', ARGV[2], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// ageStreamScript moves waiting entry ARGV[2] of the low priority stream
+// (KEYS[1]) to the pending stream (KEYS[2]). It refuses entries a worker has
+// claimed in the meantime, which the consumer group (ARGV[1]) lists as
+// pending. Returns 0 when the entry was claimed or is gone.
+var ageStreamScript = redis.NewScript(`
+if #redis.call('XPENDING', KEYS[1], ARGV[1], ARGV[2], ARGV[2], 1) > 0 then
+	return 0
+end
+if redis.call('XDEL', KEYS[1], ARGV[2]) == 0 then
+	return 0
+end
+redis.call('XADD', KEYS[2], '*', ARGV[3], ARGV[4])
+return 1
+`)
+
+// streamToken is the claim token of a stream entry. It names the entry so
+// the job can be acked without matching its payload.
+func streamToken(key string, id string) string {
+	return id + "@" + key
+}
+
+// streamClaim reads the stream key and entry ID back from a claimed payload.
+func streamClaim(jobJSON string) (key string, id string, ok bool) {
+	token, _, ok := splitClaimToken(jobJSON)
+	if !ok {
+		return "", "", false
+	}
+	id, key, ok = strings.Cut(token, "@")
+	if !ok || id == "" || key == "" {
+		return "", "", false
+	}
+	return key, id, true
+}
+
+// consumer names this instance in the consumer group. Every worker of the
+// instance shares it; the group only uses it to track who holds what.
+func (p *Pool) consumer() string {
+	return p.config.InstanceID
+}
+
+// readStream claims the next waiting entry of queue's stream for the
+// consumer group, blocking up to block when block is positive. A group
+// that doesn't exist yet is created from the start of the stream, so jobs
+// migrated before the first worker started are claimed too.
+func (p *Pool) readStream(ctx context.Context, queue string, block time.Duration) (string, error) {
+	key := services.StreamKey(queue)
+	if block <= 0 {
+		block = -1
+	}
+	args := &redis.XReadGroupArgs{
+		Group:    p.config.StreamGroup,
+		Consumer: p.consumer(),
+		Streams:  []string{key, ">"},
+		Count:    1,
+		Block:    block,
+	}
+
+	streams, err := p.redisClient.XReadGroup(ctx, args).Result()
+	if services.IsNoGroup(err) {
+		if err := p.createStreamGroup(ctx, key); err != nil {
+			return "", err
+		}
+		streams, err = p.redisClient.XReadGroup(ctx, args).Result()
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return "", redis.Nil
+	}
+
+	return p.tagStreamEntry(ctx, key, streams[0].Messages[0]), nil
+}
+
+func (p *Pool) createStreamGroup(ctx context.Context, key string) error {
+	err := p.redisClient.XGroupCreateMkStream(ctx, key, p.config.StreamGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// tagStreamEntry embeds the entry's claim token in its payload. Payloads
+// that aren't JSON objects can't carry one, so they are acked right away and
+// rejected as malformed like on the list backend.
+func (p *Pool) tagStreamEntry(ctx context.Context, key string, msg redis.XMessage) string {
+	payload := services.StreamPayload(msg)
+	tagged, ok := withClaimToken(payload, streamToken(key, msg.ID))
+	if !ok {
+		p.ackStream(ctx, key, msg.ID)
+		return payload
+	}
+	return tagged
+}
+
+func (p *Pool) ackStream(ctx context.Context, key string, id string) (int64, error) {
+	pipe := p.redisClient.TxPipeline()
+	acked := pipe.XAck(ctx, key, p.config.StreamGroup, id)
+	pipe.XDel(ctx, key, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return acked.Val(), nil
+}
+
+// claimableQueues are the queues this deployment's workers claim from.
+func (p *Pool) claimableQueues() []string {
+	queues := []string{p.config.RetryLaneQueue, p.config.HighPriorityQueue, p.config.PendingQueue, p.config.LowPriorityQueue}
+	for _, kind := range services.JobKinds() {
+		queues = append(queues, p.config.KindQueueFor(kind, p.config.Region))
+	}
+	return queues
+}
+
+// staleAfter is how long a claimed entry may go unacked before recovery
+// looks at it: a lease TTL, so a live worker has long taken its lease, or
+// the fixed age used when leases are off.
+func (p *Pool) staleAfter() time.Duration {
+	if p.config.LeaseInterval <= 0 || p.config.LeaseTTL <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(p.config.LeaseTTL) * time.Second
+}
+
+// recoverStaleStreamJobs takes over entries that have been pending longer
+// than staleAfter with XAUTOCLAIM and recovers those whose worker no longer
+// holds the lease. Entries of live long-running conversions stay pending
+// under this instance until their worker acks them.
+func (p *Pool) recoverStaleStreamJobs(ctx context.Context) {
+	recovered := 0
+	for _, queue := range p.claimableQueues() {
+		key := services.StreamKey(queue)
+		start := "0-0"
+		for {
+			msgs, next, err := p.redisClient.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   key,
+				Group:    p.config.StreamGroup,
+				Consumer: p.consumer(),
+				MinIdle:  p.staleAfter(),
+				Start:    start,
+				Count:    streamRecoveryBatch,
+			}).Result()
+			if services.IsNoGroup(err) {
+				break
+			}
+			if err != nil {
+				slog.Error("Failed to claim stale entries", "component", "recovery", "stream", key, "error", err)
+				break
+			}
+
+			for _, msg := range msgs {
+				jobJSON := p.tagStreamEntry(ctx, key, msg)
+				var job models.ConversionJob
+				if err := json.Unmarshal([]byte(jobJSON), &job); err != nil {
+					continue
+				}
+				if p.config.LeaseInterval > 0 && p.config.LeaseTTL > 0 && p.hasLease(ctx, job.ConversionID) {
+					continue
+				}
+				if p.abandonJob(ctx, &job, jobJSON) {
+					recovered++
+				}
+			}
+
+			if next == "0-0" || next == "" {
+				break
+			}
+			start = next
+		}
+	}
+
+	if recovered > 0 {
+		slog.Info("Recovered stale jobs", "component", "recovery", "recovered", recovered)
+	}
+}
+
+// promoteAgedStreamJobs is promoteAgedJobs for the stream backend. Promoted
+// jobs join the back of the pending stream, since a stream can't be pushed
+// to from the front.
+func (p *Pool) promoteAgedStreamJobs(ctx context.Context, threshold time.Duration) int {
+	msgs, err := p.jobQueue.WaitingEntries(ctx, p.config.LowPriorityQueue, agingScanSize)
+	if err != nil {
+		slog.Error("Failed to read low priority queue", "component", "aging", "error", err)
+		return 0
+	}
+
+	promoted := 0
+	for _, msg := range msgs {
+		payload := services.StreamPayload(msg)
+		var job models.ConversionJob
+		if err := json.Unmarshal([]byte(payload), &job); err != nil {
+			continue
+		}
+		if job.CreatedAt.IsZero() || time.Since(job.CreatedAt) < threshold {
+			continue
+		}
+
+		moved, err := ageStreamScript.Run(ctx, p.redisClient,
+			[]string{services.StreamKey(p.config.LowPriorityQueue), services.StreamKey(p.config.PendingQueue)},
+			p.config.StreamGroup, msg.ID, migrate.StreamField, payload,
+		).Int()
+		if err != nil {
+			slog.Error("Failed to promote conversion", "component", "aging", "conversion_id", job.ConversionID, "error", err)
+			continue
+		}
+		promoted += moved
+	}
+	return promoted
+}
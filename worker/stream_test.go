@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"testing"
+
+	"converter/services"
+)
+
+func TestStreamClaim(t *testing.T) {
+	t.Parallel()
+
+	key := services.StreamKey("pp:conversion:pending:high")
+	raw := `{"conversionId":7,"fileGuid":"abc"}`
+	tagged, ok := withClaimToken(raw, streamToken(key, "1700000000000-3"))
+	if !ok {
+		t.Fatal("withClaimToken refused a JSON object")
+	}
+
+	gotKey, gotID, ok := streamClaim(tagged)
+	if !ok || gotKey != key || gotID != "1700000000000-3" {
+		t.Fatalf("streamClaim = %q, %q, %v; want %q, 1700000000000-3, true", gotKey, gotID, ok, key)
+	}
+	if got := withoutClaimToken(tagged); got != raw {
+		t.Fatalf("withoutClaimToken = %q, want %q", got, raw)
+	}
+}
+
+func TestStreamClaim_Untagged(t *testing.T) {
+	t.Parallel()
+
+	for _, jobJSON := range []string{
+		`{"conversionId":7}`,
+		`{"claimToken":"0123abcd","conversionId":7}`,
+		`{"claimToken":"@key","conversionId":7}`,
+		`not json`,
+	} {
+		if _, _, ok := streamClaim(jobJSON); ok {
+			t.Errorf("streamClaim(%q) reported a stream claim", jobJSON)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_streamed_total", "Conversions streamed from storage through Gotenberg back to storage without temp files")
+}
+
+// convertOptions are the Gotenberg switches for the job: its own, the
+// deployment's and the feature flags', with the engine the cost policy picks.
+func (p *Pool) convertOptions(ctx context.Context, job *models.ConversionJob) services.ConvertOptions {
+	opts := services.ConvertOptions{
+		Accessible:  job.Accessible || p.config.PDFUA || p.flags.Enabled(ctx, FlagAccessiblePDF, job.UserID),
+		Conformance: p.config.PDFAConformance,
+		Flatten:     job.Flatten,
+		Engine:      p.costEngine(ctx, job),
+		Header:      job.Header,
+		Footer:      job.Footer,
+		Password:    job.Password,
+	}
+	if job.PDFAConformance != "" {
+		opts.Conformance = job.PDFAConformance
+	}
+	return opts
+}
+
+// streamable reports whether the job can be converted without temp files:
+// CONVERSION_STREAM_MAX_BYTES is set and the input is known to fit it, the
+// input is an office document for Gotenberg, and the job asks for nothing
+// that needs the output as a file. Inputs are never streamed past a virus
+// scanner, which needs them on disk. Retries always take the file path, so an
+// input whose extension is wrong still gets format detection.
+func (p *Pool) streamable(ctx context.Context, job *models.ConversionJob, inputSize int64, opts services.ConvertOptions) bool {
+	if p.config.StreamMaxBytes <= 0 || p.scanner != nil || inputSize < 0 || inputSize > p.config.StreamMaxBytes {
+		return false
+	}
+	if job.RetryCount > 0 || job.InputExtension == "" || strings.EqualFold(job.InputExtension, "pdf") {
+		return false
+	}
+	if services.KindOf(job) != services.KindOffice || opts.Engine == services.EngineSoffice {
+		return false
+	}
+	// Each of these reads or rewrites the output file
+	if opts.Accessible || len(job.Outputs) > 0 || job.Bundle || job.Split != nil ||
+		job.Thumbnails || p.config.Thumbnails ||
+		job.EncryptionKeyID != "" || p.config.OutputEncryptionKeyID != "" {
+		return false
+	}
+	return !p.dedupEnabled(ctx, job.UserID)
+}
+
+// processStreamed converts a streamable job by piping the input from
+// storage into Gotenberg and Gotenberg's PDF back to storage. Nothing is
+// written locally, so the steps that inspect the output (summary,
+// language, annotations) are skipped.
+func (p *Pool) processStreamed(ctx context.Context, workerID int, job *models.ConversionJob, jobJSON string, audit *services.AuditRecord, journal *journalEntry, opts services.ConvertOptions, inputSize int64, startTime time.Time) {
+	if p.stopRequested(ctx, job, jobJSON, "streaming") {
+		return
+	}
+	journal.setStage("streaming")
+	timeoutCtx, cancel := context.WithDeadline(ctx, startTime.Add(p.jobTimeout(ctx, job, inputSize)))
+	defer cancel()
+
+	input, err := p.storage.Open(timeoutCtx, job.InputS3Path)
+	if err != nil {
+		p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("S3 download failed: %v", err))
+		return
+	}
+	defer input.Close()
+
+	audit.Engine = auditEngine
+	inputReader, inputSum := p.auditHash(input)
+	output, err := p.gotenbergSvc.ConvertStream(timeoutCtx, inputReader, streamName(job), opts)
+	if errors.Is(err, services.ErrPasswordRequired) {
+		p.rejectPasswordRequired(ctx, job, jobJSON, audit)
+		return
+	} else if err != nil {
+		p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("office conversion failed: %v", err))
+		return
+	}
+	defer output.Close()
+
+	outputReader, outputSum := p.auditHash(output)
+	if err := p.storage.UploadStream(timeoutCtx, outputReader, job.OutputS3Path, "application/pdf"); err != nil {
+		p.handleJobFailure(ctx, workerID, job, jobJSON, audit, fmt.Sprintf("S3 upload failed: %v", err))
+		return
+	}
+	audit.InputSHA256 = inputSum()
+	audit.OutputSHA256 = outputSum()
+	metrics.Inc("conversion_streamed_total")
+
+	duration := time.Since(startTime)
+	metadata := map[string]interface{}{
+		"worker_id":   workerID,
+		"duration_ms": duration.Milliseconds(),
+		"streamed":    true,
+	}
+	logging.From(ctx).Debug("Streamed conversion without temp files", "input_bytes", inputSize)
+	p.completeJob(ctx, job, jobJSON, audit, job.OutputS3Path, metadata, map[string]interface{}{}, inputSize, duration)
+}
+
+// streamName is the file name a streamed input is sent to Gotenberg under,
+// which tells LibreOffice its format.
+func streamName(job *models.ConversionJob) string {
+	name := job.FileGUID
+	if name == "" {
+		name = "input"
+	}
+	return name + "." + strings.ToLower(job.InputExtension)
+}
+
+// auditHash hashes what is read through r for the audit trail, like
+// checksum does for files; without auditing r is returned as it is.
+func (p *Pool) auditHash(r io.Reader) (io.Reader, func() string) {
+	if p.audits == nil {
+		return r, func() string { return "" }
+	}
+	h := sha256.New()
+	return io.TeeReader(r, h), func() string { return hex.EncodeToString(h.Sum(nil)) }
+}
@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStreamable(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{
+		config: &config.Config{StreamMaxBytes: 1024},
+		flags:  services.NewFeatureFlags(redis.NewClient(&redis.Options{Addr: "127.0.0.1:0", MaxRetries: -1}), "flags", time.Minute),
+	}
+	opts := services.ConvertOptions{Engine: services.EngineGotenberg}
+	docx := func(edit func(*models.ConversionJob)) *models.ConversionJob {
+		job := &models.ConversionJob{InputExtension: "docx"}
+		if edit != nil {
+			edit(job)
+		}
+		return job
+	}
+
+	cases := []struct {
+		name string
+		job  *models.ConversionJob
+		size int64
+		opts services.ConvertOptions
+		want bool
+	}{
+		{"small office input", docx(nil), 512, opts, true},
+		{"unknown size", docx(nil), -1, opts, false},
+		{"too large", docx(nil), 2048, opts, false},
+		{"retry", docx(func(j *models.ConversionJob) { j.RetryCount = 1 }), 512, opts, false},
+		{"pdf input", &models.ConversionJob{InputExtension: "pdf"}, 512, opts, false},
+		{"markdown input", &models.ConversionJob{InputExtension: "md"}, 512, opts, false},
+		{"local engine", docx(nil), 512, services.ConvertOptions{Engine: services.EngineSoffice}, false},
+		{"accessible", docx(nil), 512, services.ConvertOptions{Engine: services.EngineGotenberg, Accessible: true}, false},
+		{"split", docx(func(j *models.ConversionJob) { j.Split = &models.SplitOptions{} }), 512, opts, false},
+		{"encrypted", docx(func(j *models.ConversionJob) { j.EncryptionKeyID = "key" }), 512, opts, false},
+	}
+	for _, c := range cases {
+		if got := p.streamable(context.Background(), c.job, c.size, c.opts); got != c.want {
+			t.Errorf("%s: streamable = %v, want %v", c.name, got, c.want)
+		}
+	}
+	scanning := &Pool{config: p.config, flags: p.flags, scanner: services.NewClamAV("127.0.0.1:3310")}
+	if scanning.streamable(context.Background(), docx(nil), 512, opts) {
+		t.Error("inputs must not be streamed past the virus scanner")
+	}
+}
@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+)
+
+func init() {
+	metrics.Describe("conversion_tenant_throttled_total", "Jobs requeued to wait for their slot because their tenant was over its rate limit")
+}
+
+// reservationGrace is how long a throttled job's reservation outlives its
+// wait, for the delayed set to hand it back. Past that it takes a new slot.
+const reservationGrace = time.Hour
+
+// tenantRate is the tenant's rate limit in jobs per second and its burst:
+// TENANT_RATE_LIMIT and TENANT_RATE_BURST unless its tenant config
+// overrides them. A rate of 0 means no limit.
+func (p *Pool) tenantRate(ctx context.Context, tenantID int) (float64, int) {
+	rate, burst := p.config.TenantRateLimit, p.config.TenantRateBurst
+	tenant := p.tenants.Get(ctx, tenantID)
+	if tenant.RateLimit != nil {
+		rate = *tenant.RateLimit
+	}
+	if tenant.RateBurst > 0 {
+		burst = tenant.RateBurst
+	}
+	return rate, burst
+}
+
+// throttleTenant requeues a job whose tenant is over its rate limit, to
+// the tail of its queue once the slot it reserved comes up, instead of
+// processing it now. Jobs without a userId and user-initiated retries are
+// never throttled, and a job that already waited for its slot isn't again:
+// the limiter holds its reservation, which the job redeems once. A limiter
+// that can't be reached lets the job through. Reports whether the job was
+// handed off.
+func (p *Pool) throttleTenant(ctx context.Context, job *models.ConversionJob, jobJSON string) bool {
+	logger := logging.From(ctx)
+	if reservation := job.RateReservation; reservation != "" {
+		job.RateReservation = ""
+		held, err := p.tenantLimiter.Redeem(ctx, reservation)
+		if err != nil {
+			logger.Warn("Failed to check tenant rate reservation, processing now", "error", err)
+			return false
+		}
+		if held {
+			return false
+		}
+	}
+	if job.UserID == 0 || job.UserInitiated {
+		return false
+	}
+	rate, burst := p.tenantRate(ctx, job.UserID)
+	if rate <= 0 {
+		return false
+	}
+
+	wait, err := p.tenantLimiter.Reserve(ctx, job.UserID, rate, burst)
+	if err != nil {
+		logger.Warn("Failed to check tenant rate limit, processing now", "error", err)
+		return false
+	}
+	if wait == 0 {
+		return false
+	}
+
+	reservation, err := p.tenantLimiter.Hold(ctx, wait+reservationGrace)
+	if err != nil {
+		logger.Warn("Failed to hold tenant rate reservation, processing now", "error", err)
+		return false
+	}
+	reserved := *job
+	reserved.RateReservation = reservation
+	p.sealPassword(ctx, &reserved)
+	payload, err := json.Marshal(reserved)
+	if err != nil {
+		return false
+	}
+	if err := p.scheduleRetry(ctx, p.signJob(jobJSON, payload), wait); err != nil {
+		logger.Error("Failed to requeue throttled conversion, processing it now", "error", err)
+		return false
+	}
+	p.ack(ctx, jobJSON)
+
+	logger.Info("Tenant is over its rate limit, requeueing conversion", "user_id", job.UserID,
+		"rate", rate, "burst", burst, "delay", wait.Round(time.Millisecond).String())
+	metrics.Inc("conversion_tenant_throttled_total")
+	return true
+}
@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestThrottleTenant_LetsJobsThrough(t *testing.T) {
+	t.Parallel()
+
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0", MaxRetries: -1})
+	p := &Pool{
+		config:        &config.Config{TenantRateLimit: 1, TenantRateBurst: 10},
+		tenants:       services.NewTenantConfigs(client, "tenants", time.Minute),
+		tenantLimiter: services.NewTenantLimiter(client, ""),
+	}
+
+	if rate, burst := p.tenantRate(context.Background(), 7); rate != 1 || burst != 10 {
+		t.Fatalf("tenantRate() = %v, %d; want the deployment's 1, 10", rate, burst)
+	}
+
+	reserved := &models.ConversionJob{UserID: 7, RateReservation: "abc"}
+	cases := []struct {
+		name string
+		job  *models.ConversionJob
+	}{
+		{"no tenant", &models.ConversionJob{}},
+		{"user-initiated", &models.ConversionJob{UserID: 7, UserInitiated: true}},
+		{"slot already reserved", reserved},
+		{"limiter unreachable", &models.ConversionJob{UserID: 7}},
+	}
+	for _, c := range cases {
+		if p.throttleTenant(context.Background(), c.job, "{}") {
+			t.Errorf("%s: job was throttled", c.name)
+		}
+	}
+	if reserved.RateReservation != "" {
+		t.Error("reservation kept after the job was let through, so its retries would skip the limit")
+	}
+}
@@ -0,0 +1,22 @@
+package worker
+
+import (
+	"context"
+
+	"converter/logging"
+	"converter/services"
+)
+
+// textQuality scores the text layer of the output's first
+// TEXT_QUALITY_PAGES pages, or returns nil when scoring is off or fails.
+func (p *Pool) textQuality(ctx context.Context, pdfPath string) *services.TextQuality {
+	if p.config.TextQualityPages <= 0 {
+		return nil
+	}
+	quality, err := p.pdfTools.TextQuality(ctx, pdfPath, p.config.TextQualityPages)
+	if err != nil {
+		logging.From(ctx).Warn("Text quality scoring failed", "error", err)
+		return nil
+	}
+	return quality
+}
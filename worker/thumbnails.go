@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"converter/logging"
+	"converter/models"
+	"converter/services"
+)
+
+// defaultThumbnailSizes are the preview widths used when THUMBNAIL_SIZES is
+// unset.
+var defaultThumbnailSizes = map[string]int{"small": 160, "medium": 320, "large": 640}
+
+// ParseThumbnailSizes reads THUMBNAIL_SIZES ("small=160,large=640") into
+// widths in pixels, falling back to the defaults when none are set.
+func ParseThumbnailSizes(spec map[string]string) (map[string]int, error) {
+	if len(spec) == 0 {
+		return defaultThumbnailSizes, nil
+	}
+	sizes := make(map[string]int, len(spec))
+	for name, value := range spec {
+		width, err := strconv.Atoi(value)
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("thumbnail size %s must be a positive width, got %q", name, value)
+		}
+		if name == "" || strings.ContainsAny(name, "/.") {
+			return nil, fmt.Errorf("invalid thumbnail size name %q", name)
+		}
+		sizes[name] = width
+	}
+	return sizes, nil
+}
+
+// ValidThumbnailFormat reports whether THUMBNAIL_FORMAT is one the worker
+// can produce.
+func ValidThumbnailFormat(format string) bool {
+	return format == "png" || format == "webp"
+}
+
+func (p *Pool) SetThumbnailSizes(sizes map[string]int) {
+	p.thumbnailSizes = sizes
+}
+
+// produceThumbnails renders the first page at every configured size and
+// uploads each preview under THUMBNAIL_S3_PREFIX/<fileGuid>/<size>.<format>.
+// Previews are a convenience, so a failure is logged and leaves the
+// conversion successful. Returns size -> key for metadata.
+func (p *Pool) produceThumbnails(ctx context.Context, job *models.ConversionJob, pdfPath string, dataKey *services.DataKey) map[string]string {
+	if !job.Thumbnails && !p.config.Thumbnails {
+		return nil
+	}
+	sizes := p.thumbnailSizes
+	if sizes == nil {
+		sizes = defaultThumbnailSizes
+	}
+
+	names := make([]string, 0, len(sizes))
+	for name := range sizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	keys := make(map[string]string, len(sizes))
+	for _, name := range names {
+		key, err := p.uploadThumbnail(ctx, job, pdfPath, name, sizes[name], dataKey)
+		if err != nil {
+			logging.From(ctx).Warn("Thumbnail generation failed", "size", name, "error", err)
+			continue
+		}
+		keys[name] = key
+	}
+	return keys
+}
+
+func (p *Pool) uploadThumbnail(ctx context.Context, job *models.ConversionJob, pdfPath string, name string, width int, dataKey *services.DataKey) (string, error) {
+	localPath, err := p.pdfTools.RenderThumbnail(ctx, pdfPath, width)
+	if err != nil {
+		return "", err
+	}
+	defer p.storage.Cleanup(localPath)
+
+	format := p.config.ThumbnailFormat
+	contentType := "image/png"
+	if format == "webp" {
+		webpPath, err := p.imagingSvc.ToWebP(ctx, localPath)
+		if err != nil {
+			return "", err
+		}
+		defer p.storage.Cleanup(webpPath)
+		localPath = webpPath
+		contentType = "image/webp"
+	} else {
+		format = "png"
+	}
+
+	key := path.Join(p.config.ThumbnailS3Prefix, job.FileGUID, name+"."+format)
+	if err := p.uploadOutput(ctx, dataKey, localPath, key, contentType); err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseThumbnailSizes(t *testing.T) {
+	t.Parallel()
+
+	sizes, err := ParseThumbnailSizes(nil)
+	if err != nil || !reflect.DeepEqual(sizes, defaultThumbnailSizes) {
+		t.Errorf("ParseThumbnailSizes(nil) = %v, %v, want the defaults", sizes, err)
+	}
+
+	sizes, err = ParseThumbnailSizes(map[string]string{"card": "240", "hero": "1200"})
+	if err != nil {
+		t.Fatalf("ParseThumbnailSizes() error = %v", err)
+	}
+	if want := map[string]int{"card": 240, "hero": 1200}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("ParseThumbnailSizes() = %v, want %v", sizes, want)
+	}
+
+	for _, bad := range []map[string]string{{"small": "0"}, {"small": "wide"}, {"a/b": "100"}} {
+		if _, err := ParseThumbnailSizes(bad); err == nil {
+			t.Errorf("ParseThumbnailSizes(%v) accepted an invalid size", bad)
+		}
+	}
+}
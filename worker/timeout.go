@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"converter/config"
+	"converter/logging"
+	"converter/models"
+)
+
+// baseTimeout is the job's own timeout, or CONVERSION_TIMEOUT when the
+// producer didn't set one.
+func (p *Pool) baseTimeout(job *models.ConversionJob) time.Duration {
+	if job.Timeout > 0 {
+		return time.Duration(job.Timeout) * time.Second
+	}
+	return time.Duration(p.config.ConversionTimeout) * time.Second
+}
+
+// LongestTimeout is the longest timeout a job without its own gets: the
+// adaptive maximum when adaptive timeouts are on, else CONVERSION_TIMEOUT.
+func LongestTimeout(cfg *config.Config) time.Duration {
+	longest := cfg.ConversionTimeout
+	if cfg.AdaptiveTimeout && cfg.AdaptiveTimeoutMax > longest {
+		longest = cfg.AdaptiveTimeoutMax
+	}
+	return time.Duration(longest) * time.Second
+}
+
+// jobTimeout picks the timeout for a downloaded input. With adaptive
+// timeouts enabled it is derived from the p99 duration recorded for the
+// extension and size bucket, so slow formats get longer and fast ones
+// shorter than the one global value; without enough history it falls back
+// to baseTimeout.
+func (p *Pool) jobTimeout(ctx context.Context, job *models.ConversionJob, inputSize int64) time.Duration {
+	base := p.baseTimeout(job)
+	if !p.config.AdaptiveTimeout {
+		return base
+	}
+
+	stats, err := p.perfStats.Percentiles(ctx, job.InputExtension, inputSize)
+	if err != nil {
+		logging.From(ctx).Warn("Failed to load duration history, using base timeout", "timeout", base.String(), "error", err)
+		return base
+	}
+
+	timeout, ok := stats.SuggestedTimeout(
+		p.config.AdaptiveTimeoutFactor,
+		p.config.AdaptiveTimeoutMinSamples,
+		time.Duration(p.config.AdaptiveTimeoutMin)*time.Second,
+		time.Duration(p.config.AdaptiveTimeoutMax)*time.Second,
+	)
+	if !ok {
+		return base
+	}
+	return timeout
+}
@@ -0,0 +1,218 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"path"
+	"strings"
+	"time"
+
+	"converter/logging"
+	"converter/metrics"
+	"converter/models"
+	"converter/services"
+)
+
+// trashPurgeBatch caps the conversions purged per sweep.
+const trashPurgeBatch = 100
+
+var errNotInTrash = errors.New("conversion outputs are not in the trash")
+
+func init() {
+	metrics.Describe("conversion_outputs_trashed_total", "Conversions whose outputs were moved to the trash")
+	metrics.Describe("conversion_outputs_restored_total", "Conversions whose outputs were restored from the trash")
+	metrics.Describe("conversion_outputs_purged_total", "Conversions whose trashed outputs were deleted after their retention")
+}
+
+// trashKey is where an output is kept while it is in the trash.
+func (p *Pool) trashKey(key string) string {
+	return path.Join(p.config.TrashPrefix, key)
+}
+
+// processOutputJob trashes or restores an earlier conversion's outputs. The
+// conversion's status is left alone: it stays completed either way.
+func (p *Pool) processOutputJob(ctx context.Context, workerID int, job *models.ConversionJob, jobJSON string) {
+	// Hold a lease so recovery doesn't reclaim the job mid-flight
+	releaseLease := p.startLease(ctx, workerID, job.ConversionID)
+	defer releaseLease()
+
+	var err error
+	if job.Type == models.JobTypeTrash {
+		err = p.trashOutputs(ctx, job)
+	} else {
+		err = p.restoreOutputs(ctx, job)
+	}
+	if err != nil {
+		p.handleOutputJobFailure(ctx, job, jobJSON, err)
+		return
+	}
+
+	if job.Type == models.JobTypeTrash {
+		metrics.Inc("conversion_outputs_trashed_total")
+		p.publishEvent(ctx, job, services.EventConversionTrashed, "", "")
+	} else {
+		metrics.Inc("conversion_outputs_restored_total")
+		p.publishEvent(ctx, job, services.EventConversionRestored, "", "")
+	}
+	p.ack(ctx, jobJSON)
+}
+
+// trashOutputs moves the conversion's outputs under TRASH_PREFIX. The row is
+// recorded first, so outputs a crash left half moved can still be restored
+// or purged. Deduplicated outputs may be shared with other conversions and
+// stay where they are.
+func (p *Pool) trashOutputs(ctx context.Context, job *models.ConversionJob) error {
+	keys, err := p.db.ConversionOutputKeys(ctx, job.ConversionID)
+	if err != nil {
+		return err
+	}
+	var owned []string
+	for _, key := range keys {
+		if p.config.DedupPrefix != "" && strings.HasPrefix(key, p.config.DedupPrefix+"/") {
+			continue
+		}
+		owned = append(owned, key)
+	}
+
+	now := time.Now()
+	if err := p.db.RecordTrash(ctx, services.TrashedConversion{
+		ConversionID: job.ConversionID,
+		Keys:         owned,
+		TrashedAt:    now,
+		PurgeAfter:   now.AddDate(0, 0, p.config.TrashRetentionDays),
+	}); err != nil {
+		return err
+	}
+
+	for _, key := range owned {
+		if err := p.moveObject(ctx, key, p.trashKey(key)); err != nil {
+			return err
+		}
+	}
+	logging.From(ctx).Info("Moved conversion outputs to the trash", "objects", len(owned), "skipped_shared", len(keys)-len(owned))
+	return nil
+}
+
+// restoreOutputs moves trashed outputs back and drops the trash row.
+func (p *Pool) restoreOutputs(ctx context.Context, job *models.ConversionJob) error {
+	trashed, err := p.db.TrashedOutputs(ctx, job.ConversionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errNotInTrash
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, key := range trashed.Keys {
+		if err := p.moveObject(ctx, p.trashKey(key), key); err != nil {
+			return err
+		}
+	}
+	if err := p.db.DeleteTrash(ctx, job.ConversionID); err != nil {
+		return err
+	}
+	logging.From(ctx).Info("Restored conversion outputs from the trash", "objects", len(trashed.Keys))
+	return nil
+}
+
+// moveObject copies from to to and deletes from. A source that is gone
+// while the destination exists was moved by an earlier attempt.
+func (p *Pool) moveObject(ctx context.Context, from string, to string) error {
+	err := p.storage.Copy(ctx, from, to)
+	if errors.Is(err, services.ErrObjectNotFound) {
+		if _, statErr := p.storage.Stat(ctx, to); statErr == nil {
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move %s: %w", from, err)
+	}
+	if err := p.storage.Delete(ctx, from); err != nil {
+		return fmt.Errorf("failed to move %s: %w", from, err)
+	}
+	return nil
+}
+
+// handleOutputJobFailure retries a trash or restore job with backoff, and
+// moves it to the failed queue once its retries are used up or it can never
+// succeed.
+func (p *Pool) handleOutputJobFailure(ctx context.Context, job *models.ConversionJob, jobJSON string, err error) {
+	logger := logging.From(ctx)
+	logger.Error("Output job failed", "type", string(job.Type), "error", err, "retry_count", job.RetryCount)
+
+	if job.RetryCount < job.MaxRetries && !errors.Is(err, errNotInTrash) {
+		job.RetryCount++
+		newJobJSON, _ := json.Marshal(job)
+		newJobJSON = p.signJob(jobJSON, newJobJSON)
+
+		delay := time.Duration(math.Pow(2, float64(job.RetryCount))) * time.Second
+		if delay > 30*time.Second {
+			delay = 30 * time.Second
+		}
+		if err := p.scheduleRetry(ctx, newJobJSON, delay); err != nil {
+			logger.Warn("Failed to schedule retry, requeueing now", "error", err)
+			p.enqueue(ctx, p.requeueTarget(job), string(newJobJSON))
+		}
+	} else {
+		p.pushFailed(ctx, jobJSON)
+	}
+	p.ack(ctx, jobJSON)
+}
+
+// TrashPurgeLoop deletes trashed outputs once they are past
+// TRASH_RETENTION_DAYS, every TRASH_PURGE_INTERVAL seconds.
+func (p *Pool) TrashPurgeLoop(ctx context.Context) {
+	interval := time.Duration(p.config.TrashPurgeInterval) * time.Second
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	slog.Info("Starting trash purge", "component", "trash", "interval", interval.String())
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Trash purge shutting down", "component", "trash")
+			return
+		case <-ticker.C:
+			p.purgeTrash(ctx)
+		}
+	}
+}
+
+func (p *Pool) purgeTrash(ctx context.Context) {
+	if !p.IsActive() {
+		return
+	}
+
+	expired, err := p.db.ExpiredTrash(ctx, time.Now(), trashPurgeBatch)
+	if err != nil {
+		slog.Error("Failed to read expired trash", "component", "trash", "error", err)
+		return
+	}
+	for _, trashed := range expired {
+		if err := p.purgeTrashed(ctx, trashed); err != nil {
+			slog.Error("Failed to purge trashed outputs", "component", "trash", "conversion_id", trashed.ConversionID, "error", err)
+			continue
+		}
+		metrics.Inc("conversion_outputs_purged_total")
+		slog.Info("Purged trashed outputs", "component", "trash", "conversion_id", trashed.ConversionID, "objects", len(trashed.Keys))
+	}
+}
+
+func (p *Pool) purgeTrashed(ctx context.Context, trashed services.TrashedConversion) error {
+	for _, key := range trashed.Keys {
+		if err := p.storage.Delete(ctx, p.trashKey(key)); err != nil {
+			return err
+		}
+	}
+	return p.db.DeleteTrash(ctx, trashed.ConversionID)
+}
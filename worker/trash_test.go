@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"converter/config"
+	"converter/models"
+	"converter/services"
+)
+
+// memoryStorage keeps objects as their names, which is all moves need.
+type memoryStorage struct {
+	services.Storage
+	objects map[string]bool
+	copies  int
+}
+
+func (m *memoryStorage) Stat(_ context.Context, key string) (*services.ObjectInfo, error) {
+	if !m.objects[key] {
+		return nil, services.ErrObjectNotFound
+	}
+	return &services.ObjectInfo{}, nil
+}
+
+func (m *memoryStorage) Copy(_ context.Context, from string, to string) error {
+	if !m.objects[from] {
+		return services.ErrObjectNotFound
+	}
+	m.copies++
+	m.objects[to] = true
+	return nil
+}
+
+func (m *memoryStorage) Delete(_ context.Context, key string) error {
+	delete(m.objects, key)
+	return nil
+}
+
+func TestMoveObject(t *testing.T) {
+	t.Parallel()
+
+	storage := &memoryStorage{objects: map[string]bool{"out/a.pdf": true}}
+	p := &Pool{config: &config.Config{TrashPrefix: "trash"}, storage: storage}
+	ctx := context.Background()
+
+	if err := p.moveObject(ctx, "out/a.pdf", p.trashKey("out/a.pdf")); err != nil {
+		t.Fatal(err)
+	}
+	if storage.objects["out/a.pdf"] || !storage.objects["trash/out/a.pdf"] {
+		t.Fatalf("objects after move = %v", storage.objects)
+	}
+
+	// A retry after the move went through finds the object already moved
+	if err := p.moveObject(ctx, "out/a.pdf", "trash/out/a.pdf"); err != nil {
+		t.Errorf("repeated move = %v", err)
+	}
+	if storage.copies != 1 {
+		t.Errorf("copies = %d, want 1", storage.copies)
+	}
+
+	if err := p.moveObject(ctx, "out/missing.pdf", "trash/out/missing.pdf"); err == nil {
+		t.Error("moving a missing object succeeded")
+	}
+}
+
+func TestValidateOutputJob(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{config: &config.Config{SupportedExtensions: []string{"pdf"}}}
+	for _, jobType := range []models.JobType{models.JobTypeTrash, models.JobTypeRestore} {
+		if got, message := p.validateJob(&models.ConversionJob{Type: jobType, ConversionID: 7}); got != "" {
+			t.Errorf("%s: validateJob() = %q (%s), want it accepted", jobType, got, message)
+		}
+		if got, _ := p.validateJob(&models.ConversionJob{Type: jobType}); got != models.RejectMalformed {
+			t.Errorf("%s without a conversion: validateJob() = %q, want %q", jobType, got, models.RejectMalformed)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"converter/metrics"
+	"converter/services"
+)
+
+// warmupTimeout bounds a warm-up conversion, which waits for LibreOffice
+// to start when it isn't running.
+const warmupTimeout = 2 * time.Minute
+
+func init() {
+	metrics.Describe("conversion_gotenberg_warmups_total", "Warm-up conversions sent to Gotenberg's LibreOffice route, by result (warmed, failed)")
+}
+
+// WarmupLoop keeps LibreOffice warm: it sends a warm-up conversion at
+// startup and whenever Gotenberg hasn't converted an office document for
+// GOTENBERG_WARMUP_IDLE seconds, so the first job after a quiet period
+// doesn't take several times longer than the rest.
+func (p *Pool) WarmupLoop(ctx context.Context) {
+	idle := time.Duration(p.config.GotenbergWarmupIdle) * time.Second
+	p.warmGotenberg(ctx)
+
+	ticker := time.NewTicker(idle / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(p.gotenbergSvc.LastLibreOffice()) >= idle {
+				p.warmGotenberg(ctx)
+			}
+		}
+	}
+}
+
+func (p *Pool) warmGotenberg(ctx context.Context) {
+	warmCtx, cancel := context.WithTimeout(ctx, warmupTimeout)
+	defer cancel()
+
+	started := time.Now()
+	if err := p.gotenbergSvc.Warm(warmCtx, services.ConvertOptions{Conformance: p.config.PDFAConformance}); err != nil {
+		if ctx.Err() == nil {
+			metrics.Inc("conversion_gotenberg_warmups_total", "result", "failed")
+			slog.Warn("Gotenberg warm-up failed", "component", "warmup", "error", err)
+		}
+		return
+	}
+	metrics.Inc("conversion_gotenberg_warmups_total", "result", "warmed")
+	slog.Info("Warmed up LibreOffice", "component", "warmup", "duration_ms", time.Since(started).Milliseconds())
+}
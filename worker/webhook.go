@@ -0,0 +1,218 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"converter/metrics"
+	"converter/services"
+)
+
+func init() {
+	metrics.Describe("conversion_webhook_in_flight", "Conversions waiting for Gotenberg's webhook after their worker moved on to the next job")
+	metrics.Describe("conversion_webhook_callbacks_total", "Gotenberg webhook callbacks, by result (converted, failed, unknown)")
+}
+
+// webhookConversions tracks the office conversions submitted in webhook
+// mode until Gotenberg calls back.
+type webhookConversions struct {
+	baseURL string
+	// slots bounds the conversions whose worker was handed back
+	slots   chan struct{}
+	mu      sync.Mutex
+	pending map[string]*pendingConversion
+	// running counts the claims handled off the worker goroutines
+	running sync.WaitGroup
+}
+
+type pendingConversion struct {
+	outputPath string
+	done       chan error
+}
+
+// detachKey holds the function that hands a claim's worker back while the
+// claim waits for a webhook.
+type detachKey struct{}
+
+// EnableWebhooks has office conversions delivered by Gotenberg's webhook at
+// baseURL, which must reach WebhookHandler. Up to maxInFlight claims can
+// wait for their callback without holding a worker.
+func (p *Pool) EnableWebhooks(baseURL string, maxInFlight int) {
+	p.webhooks = &webhookConversions{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		slots:   make(chan struct{}, maxInFlight),
+		pending: make(map[string]*pendingConversion),
+	}
+}
+
+// runClaim handles a claim. In webhook mode it runs on its own goroutine,
+// and the worker returns as soon as the claim waits for a webhook, so it
+// can claim the next job.
+func (p *Pool) runClaim(ctx context.Context, workerID int, result string) {
+	done := p.beginClaim(ctx, result)
+	// A shutdown lets the claim finish or requeues it rather than cut it off
+	ctx, cancel := p.claimContext(ctx)
+	// Keep the claim from being redelivered while it is handled
+	release := p.holdClaim(ctx, result)
+	if p.webhooks == nil {
+		p.handleClaim(ctx, workerID, result)
+		release()
+		cancel()
+		done()
+		return
+	}
+
+	handedBack := make(chan struct{})
+	finished := make(chan struct{})
+	var once sync.Once
+	detached := false
+	detach := func() {
+		once.Do(func() {
+			// Without a free slot the claim keeps its worker
+			select {
+			case p.webhooks.slots <- struct{}{}:
+				detached = true
+				metrics.Set("conversion_webhook_in_flight", int64(len(p.webhooks.slots)))
+				close(handedBack)
+			default:
+			}
+		})
+	}
+
+	p.webhooks.running.Add(1)
+	go func() {
+		defer p.webhooks.running.Done()
+		defer close(finished)
+		p.handleClaim(context.WithValue(ctx, detachKey{}, detach), workerID, result)
+		release()
+		cancel()
+		done()
+		if detached {
+			<-p.webhooks.slots
+			metrics.Set("conversion_webhook_in_flight", int64(len(p.webhooks.slots)))
+		}
+	}()
+
+	select {
+	case <-finished:
+	case <-handedBack:
+	}
+}
+
+// waitDetached waits for the claims still waiting for a webhook.
+func (p *Pool) waitDetached() {
+	if p.webhooks != nil {
+		p.webhooks.running.Wait()
+	}
+}
+
+// convertOffice converts an office document with Gotenberg's LibreOffice
+// route, in webhook mode by submitting it and waiting for the callback
+// without holding the worker.
+func (p *Pool) convertOffice(ctx context.Context, localPath string, extension string, opts services.ConvertOptions) (string, error) {
+	if p.webhooks == nil {
+		return p.gotenbergSvc.ConvertToPDFA(ctx, localPath, extension, opts)
+	}
+
+	token, err := webhookToken()
+	if err != nil {
+		return "", err
+	}
+	pending := &pendingConversion{outputPath: localPath + ".converted.pdf", done: make(chan error, 1)}
+	p.webhooks.mu.Lock()
+	p.webhooks.pending[token] = pending
+	p.webhooks.mu.Unlock()
+
+	hook := services.Webhook{
+		URL:      p.webhooks.baseURL + "/gotenberg/" + token,
+		ErrorURL: p.webhooks.baseURL + "/gotenberg/" + token + "/error",
+	}
+	if err := p.gotenbergSvc.SubmitToPDFA(ctx, localPath, opts, hook); err != nil {
+		p.webhooks.take(token)
+		return "", err
+	}
+
+	if detach, ok := ctx.Value(detachKey{}).(func()); ok {
+		detach()
+	}
+
+	select {
+	case err := <-pending.done:
+		if err != nil {
+			return "", err
+		}
+		return pending.outputPath, nil
+	case <-ctx.Done():
+		if p.webhooks.take(token) == nil {
+			// The callback is being saved; drop what it wrote
+			if err := <-pending.done; err == nil {
+				os.Remove(pending.outputPath)
+			}
+		}
+		return "", fmt.Errorf("gotenberg webhook: %w", ctx.Err())
+	}
+}
+
+// take removes a pending conversion, returning nil when the callback or
+// the waiting job already took it.
+func (w *webhookConversions) take(token string) *pendingConversion {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pending := w.pending[token]
+	delete(w.pending, token)
+	return pending
+}
+
+func webhookToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate webhook token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// WebhookHandler receives Gotenberg's callbacks: the PDF at
+// /gotenberg/{token} and the failure at /gotenberg/{token}/error. Tokens
+// are random and used once, so unknown ones get a 404.
+func (p *Pool) WebhookHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /gotenberg/{token}", func(w http.ResponseWriter, r *http.Request) {
+		p.receiveWebhook(w, r, false)
+	})
+	mux.HandleFunc("POST /gotenberg/{token}/error", func(w http.ResponseWriter, r *http.Request) {
+		p.receiveWebhook(w, r, true)
+	})
+	return mux
+}
+
+func (p *Pool) receiveWebhook(w http.ResponseWriter, r *http.Request, failed bool) {
+	pending := p.webhooks.take(r.PathValue("token"))
+	if pending == nil {
+		metrics.Inc("conversion_webhook_callbacks_total", "result", "unknown")
+		http.NotFound(w, r)
+		return
+	}
+
+	var err error
+	if failed {
+		metrics.Inc("conversion_webhook_callbacks_total", "result", "failed")
+		err = services.WebhookError(r)
+	} else {
+		metrics.Inc("conversion_webhook_callbacks_total", "result", "converted")
+		if err = p.gotenbergSvc.SaveWebhookResult(r, pending.outputPath); err != nil {
+			os.Remove(pending.outputPath)
+		}
+	}
+	if err != nil && !failed {
+		slog.Warn("Failed to save Gotenberg webhook result", "component", "webhook", "error", err)
+	}
+	pending.done <- err
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"converter/services"
+)
+
+func TestConvertOffice_Webhook(t *testing.T) {
+	t.Parallel()
+
+	p := &Pool{}
+	hooks := httptest.NewServer(p.WebhookHandler())
+	defer hooks.Close()
+
+	// Gotenberg accepts the conversion, then calls back with the PDF or,
+	// for locked.docx, the failure
+	gotenberg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, header, err := r.FormFile("files")
+		if err != nil {
+			t.Errorf("no file in form: %v", err)
+		}
+		target, body, contentType := r.Header.Get("Gotenberg-Webhook-Url"), "%PDF-1.7 converted", "application/pdf"
+		if header != nil && header.Filename == "locked.docx" {
+			target, body, contentType = r.Header.Get("Gotenberg-Webhook-Error-Url"), `{"status":400,"message":"A password may be required"}`, "application/json"
+		}
+		w.WriteHeader(http.StatusNoContent)
+		go func() {
+			resp, err := http.Post(target, contentType, strings.NewReader(body))
+			if err != nil {
+				t.Errorf("callback failed: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}))
+	defer gotenberg.Close()
+
+	p.gotenbergSvc = services.NewGotenbergService(gotenberg.URL, 0, services.RequestIdentity{})
+	p.EnableWebhooks(hooks.URL+"/", 1)
+
+	dir := t.TempDir()
+	input := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("document"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	detached := 0
+	ctx := context.WithValue(context.Background(), detachKey{}, func() { detached++ })
+	outputPath, err := p.convertOffice(ctx, input("report.docx"), "docx", services.ConvertOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(outputPath); string(content) != "%PDF-1.7 converted" {
+		t.Errorf("output = %q", content)
+	}
+	if detached != 1 {
+		t.Errorf("worker handed back %d times, want 1", detached)
+	}
+
+	if _, err := p.convertOffice(context.Background(), input("locked.docx"), "docx", services.ConvertOptions{}); !errors.Is(err, services.ErrPasswordRequired) {
+		t.Errorf("expected ErrPasswordRequired, got %v", err)
+	}
+	if len(p.webhooks.pending) != 0 {
+		t.Errorf("%d conversions left pending", len(p.webhooks.pending))
+	}
+
+	resp, err := http.Post(hooks.URL+"/gotenberg/unknown", "application/pdf", strings.NewReader("%PDF-1.7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown token answered %d", resp.StatusCode)
+	}
+}